@@ -0,0 +1,180 @@
+// Package analysis runs canned analytical queries over audit archives exported by `audriver export` in an embedded
+// DuckDB database, for quick offline investigations without access to the production database.
+//
+// The package does not depend on a DuckDB driver; open the database with one, e.g. github.com/marcboeker/go-duckdb:
+//
+//	db, err := sql.Open("duckdb", "")
+//	if err != nil {
+//		return err
+//	}
+//	if err := analysis.Load(ctx, db, "audit-2025-01.jsonl", "audit-2025-02.parquet"); err != nil {
+//		return err
+//	}
+//	tables, err := analysis.TopTables(ctx, db, 10)
+package analysis
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/mickamy/go-sql-audit-driver/internal/postgres"
+)
+
+// View is the view over the loaded archives the canned queries read, which may also be queried directly.
+// Its columns are those of the archives, with modified_at as a UTC timestamp.
+const View = "audit_records"
+
+// readers are the DuckDB table functions reading archives, by file extension.
+var readers = map[string]string{
+	".parquet": "read_parquet(%s, union_by_name = true)",
+	".json":    "read_json_auto(%s, format = 'newline_delimited', union_by_name = true)",
+	".jsonl":   "read_json_auto(%s, format = 'newline_delimited', union_by_name = true)",
+	".ndjson":  "read_json_auto(%s, format = 'newline_delimited', union_by_name = true)",
+	".csv":     "read_csv_auto(%s, header = true, union_by_name = true)",
+}
+
+// Load creates View over the archives at paths, replacing any previous one. Archives are read by their extension as
+// Parquet (.parquet), JSON lines (.json, .jsonl, .ndjson), or CSV (.csv), optionally compressed with gzip (.gz),
+// and paths may be glob patterns, e.g. "audit-2025-*.jsonl". The archives are read on every query rather than copied.
+func Load(ctx context.Context, db *sql.DB, paths ...string) error {
+	query, err := loadSQL(paths)
+	if err != nil {
+		return err
+	}
+	if _, err := db.ExecContext(ctx, query); err != nil {
+		return fmt.Errorf("failed to load audit archives: %w", err)
+	}
+	return nil
+}
+
+// loadSQL returns the statement creating View over the archives at paths.
+func loadSQL(paths []string) (string, error) {
+	if len(paths) == 0 {
+		return "", errors.New("no audit archives to load")
+	}
+
+	// one reader per format, in the order of their first archive
+	var (
+		formats []string
+		files   = map[string][]string{}
+	)
+	for _, path := range paths {
+		ext := strings.ToLower(filepath.Ext(strings.TrimSuffix(path, ".gz")))
+		if _, ok := readers[ext]; !ok {
+			return "", fmt.Errorf("unsupported audit archive: %s", path)
+		}
+		if ext == ".jsonl" || ext == ".ndjson" {
+			ext = ".json"
+		}
+		if _, ok := files[ext]; !ok {
+			formats = append(formats, ext)
+		}
+		// DuckDB quotes string literals like PostgreSQL
+		files[ext] = append(files[ext], postgres.QuoteLiteral(path))
+	}
+
+	selects := make([]string, len(formats))
+	for i, ext := range formats {
+		// timestamps are normalized to UTC, as archives of different formats type them differently
+		selects[i] = "SELECT * REPLACE (CAST(CAST(modified_at AS VARCHAR) AS TIMESTAMP) AS modified_at) FROM " +
+			fmt.Sprintf(readers[ext], "["+strings.Join(files[ext], ", ")+"]")
+	}
+	return "CREATE OR REPLACE VIEW " + View + " AS " + strings.Join(selects, " UNION ALL BY NAME "), nil
+}
+
+// TableActivity is the number of modifications of a table.
+type TableActivity struct {
+	Table   string `json:"table"`
+	Inserts int64  `json:"inserts"`
+	Updates int64  `json:"updates"`
+	Deletes int64  `json:"deletes"`
+	Total   int64  `json:"total"`
+
+	// Operators is the number of distinct operators that modified the table.
+	Operators int64 `json:"operators"`
+}
+
+// TopTables returns the limit most frequently modified tables of the loaded archives, most modified first.
+func TopTables(ctx context.Context, db *sql.DB, limit int) ([]TableActivity, error) {
+	query := `SELECT table_name,
+       count(*) FILTER (WHERE action = 'insert'),
+       count(*) FILTER (WHERE action = 'update'),
+       count(*) FILTER (WHERE action = 'delete'),
+       count(*),
+       count(DISTINCT operator_id)
+FROM ` + View + `
+GROUP BY table_name
+ORDER BY count(*) DESC, table_name
+LIMIT ?`
+	rows, err := db.QueryContext(ctx, query, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query top tables: %w", err)
+	}
+	defer func(rows *sql.Rows) {
+		_ = rows.Close()
+	}(rows)
+
+	var tables []TableActivity
+	for rows.Next() {
+		var table TableActivity
+		if err := rows.Scan(&table.Table, &table.Inserts, &table.Updates, &table.Deletes, &table.Total, &table.Operators); err != nil {
+			return nil, fmt.Errorf("failed to scan table activity: %w", err)
+		}
+		tables = append(tables, table)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query top tables: %w", err)
+	}
+	return tables, nil
+}
+
+// HeatmapCell is the number of modifications of an operator in an hour of a weekday (UTC).
+type HeatmapCell struct {
+	OperatorID    string       `json:"operator_id"`
+	Weekday       time.Weekday `json:"weekday"`
+	Hour          int          `json:"hour"`
+	Modifications int64        `json:"modifications"`
+}
+
+// OperatorHeatmap returns the activity of the operators of the loaded archives per hour of the week (UTC), e.g. to
+// spot operators active at unusual hours. Hours without modifications are left out. Only the operators given are
+// included, if any.
+func OperatorHeatmap(ctx context.Context, db *sql.DB, operatorIDs ...string) ([]HeatmapCell, error) {
+	var where string
+	args := make([]any, len(operatorIDs))
+	if len(operatorIDs) > 0 {
+		where = "\nWHERE CAST(operator_id AS VARCHAR) IN (?" + strings.Repeat(", ?", len(operatorIDs)-1) + ")"
+		for i, operatorID := range operatorIDs {
+			args[i] = operatorID
+		}
+	}
+	query := `SELECT CAST(operator_id AS VARCHAR), dayofweek(modified_at), hour(modified_at), count(*)
+FROM ` + View + where + `
+GROUP BY ALL
+ORDER BY ALL`
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query operator heatmap: %w", err)
+	}
+	defer func(rows *sql.Rows) {
+		_ = rows.Close()
+	}(rows)
+
+	var cells []HeatmapCell
+	for rows.Next() {
+		var cell HeatmapCell
+		if err := rows.Scan(&cell.OperatorID, &cell.Weekday, &cell.Hour, &cell.Modifications); err != nil {
+			return nil, fmt.Errorf("failed to scan heatmap cell: %w", err)
+		}
+		cells = append(cells, cell)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query operator heatmap: %w", err)
+	}
+	return cells, nil
+}
@@ -0,0 +1,68 @@
+package analysis_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mickamy/go-sql-audit-driver/analysis"
+)
+
+// TestLoadSQL tests the view created over audit archives of different formats
+func TestLoadSQL(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name     string
+		paths    []string
+		expected string
+		err      string
+	}{
+		{
+			name:  "json_lines",
+			paths: []string{"audit-2025-01.jsonl", "audit-2025-02.ndjson.gz"},
+			expected: "CREATE OR REPLACE VIEW audit_records AS " +
+				"SELECT * REPLACE (CAST(CAST(modified_at AS VARCHAR) AS TIMESTAMP) AS modified_at) FROM " +
+				"read_json_auto(['audit-2025-01.jsonl', 'audit-2025-02.ndjson.gz'], format = 'newline_delimited', union_by_name = true)",
+		},
+		{
+			name:  "mixed_formats",
+			paths: []string{"audit-*.parquet", "o'brien.csv", "audit.json"},
+			expected: "CREATE OR REPLACE VIEW audit_records AS " +
+				"SELECT * REPLACE (CAST(CAST(modified_at AS VARCHAR) AS TIMESTAMP) AS modified_at) FROM " +
+				"read_parquet(['audit-*.parquet'], union_by_name = true) UNION ALL BY NAME " +
+				"SELECT * REPLACE (CAST(CAST(modified_at AS VARCHAR) AS TIMESTAMP) AS modified_at) FROM " +
+				"read_csv_auto(['o''brien.csv'], header = true, union_by_name = true) UNION ALL BY NAME " +
+				"SELECT * REPLACE (CAST(CAST(modified_at AS VARCHAR) AS TIMESTAMP) AS modified_at) FROM " +
+				"read_json_auto(['audit.json'], format = 'newline_delimited', union_by_name = true)",
+		},
+		{
+			name:  "unsupported",
+			paths: []string{"audit.tar.gz"},
+			err:   "unsupported audit archive: audit.tar.gz",
+		},
+		{
+			name: "none",
+			err:  "no audit archives to load",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// act
+			query, err := analysis.LoadSQL(tc.paths)
+
+			// assert
+			if tc.err != "" {
+				assert.EqualError(t, err, tc.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, query)
+		})
+	}
+}
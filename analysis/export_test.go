@@ -0,0 +1,4 @@
+package analysis
+
+// LoadSQL returns the statement Load executes, exported for tests without a DuckDB driver.
+var LoadSQL = loadSQL
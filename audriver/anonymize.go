@@ -0,0 +1,156 @@
+package audriver
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql/driver"
+	"encoding/binary"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"github.com/mickamy/go-sql-audit-driver/internal/postgres"
+)
+
+// Anonymizer replaces literal values of statements with fakes before they are stored, e.g. in staging environments
+// receiving production-like traffic, so that their audit tables do not accumulate real PII.
+// Values are passed as text, e.g. "42" for integers. Anonymizers should be deterministic, so that records of the same
+// value can still be correlated.
+type Anonymizer interface {
+	Anonymize(value string) string
+}
+
+// AnonymizerFunc is a function that implements Anonymizer.
+type AnonymizerFunc func(value string) string
+
+// Anonymize calls f(value).
+func (f AnonymizerFunc) Anonymize(value string) string {
+	return f(value)
+}
+
+// FormatPreservingAnonymizer returns an anonymizer replacing values with deterministic fakes keyed by secret that keep
+// their format: letters are replaced with letters of the same case and digits with digits, and other characters are
+// kept, so that e.g. emails still look like emails and phone numbers keep their punctuation. UUIDs are replaced with
+// UUIDs. Equal values get equal fakes, but fakes cannot be reversed without the secret.
+func FormatPreservingAnonymizer(secret []byte) Anonymizer {
+	return AnonymizerFunc(func(value string) string {
+		mac := hmac.New(sha256.New, secret)
+		mac.Write([]byte(value))
+		seed := mac.Sum(nil)
+
+		if len(value) == 36 && uuid.Validate(value) == nil {
+			id, _ := uuid.FromBytes(seed[:16])
+			return id.String()
+		}
+
+		// a keystream of HMACs of the seed and a counter, one byte per character
+		var (
+			fake   = []byte(value)
+			stream []byte
+		)
+		for i, c := range fake {
+			if len(stream) == 0 {
+				block := hmac.New(sha256.New, secret)
+				block.Write(seed)
+				block.Write(binary.BigEndian.AppendUint64(nil, uint64(i)))
+				stream = block.Sum(nil)
+			}
+			r := stream[0]
+			stream = stream[1:]
+			switch {
+			case c >= 'a' && c <= 'z':
+				fake[i] = 'a' + r%26
+			case c >= 'A' && c <= 'Z':
+				fake[i] = 'A' + r%26
+			case c >= '0' && c <= '9':
+				fake[i] = '0' + r%10
+			}
+		}
+		return string(fake)
+	})
+}
+
+// anonymizeLiterals replaces the string, dollar-quoted, and numeric literals of sql with fakes.
+// Placeholders, identifiers, and comments are kept.
+func (b *databaseModificationBuilder) anonymizeLiterals(sql string) string {
+	var anonymized strings.Builder
+	last := 0
+	replace := func(start, end int, literal string) {
+		anonymized.WriteString(sql[last:start])
+		anonymized.WriteString(literal)
+		last = end
+	}
+	for i := 0; i < len(sql); i++ {
+		end := postgres.SkipLiteral(sql, i)
+		switch c := sql[i]; {
+		case end > i && c == '\'':
+			value := sql[i+1 : end]
+			if end-1 > i && sql[end-1] == '\'' {
+				value = sql[i+1 : end-1]
+			}
+			replace(i, end, postgres.QuoteLiteral(b.anonymizer.Anonymize(strings.ReplaceAll(value, "''", "'"))))
+			i = end - 1
+		case end > i && c == '$':
+			tag := sql[i : strings.IndexByte(sql[i+1:], '$')+i+2]
+			if end-len(tag) >= i+len(tag) {
+				replace(i, end, tag+b.anonymizer.Anonymize(sql[i+len(tag):end-len(tag)])+tag)
+			}
+			i = end - 1
+		case end > i:
+			// quoted identifiers and comments
+			i = end - 1
+		case c >= '0' && c <= '9' && isWordBoundary(sql, i-1):
+			end = i
+			for end < len(sql) && (isWordChar(sql[end]) || sql[end] == '.') {
+				end++
+			}
+			fake := b.anonymizer.Anonymize(sql[i:end])
+			if _, err := strconv.ParseFloat(fake, 64); err != nil && fake != sql[i:end] {
+				// fakes that are no longer numbers, e.g. redacted ones, are quoted as their arguments are
+				fake = postgres.QuoteLiteral(fake)
+			}
+			replace(i, end, fake)
+			i = end - 1
+		}
+	}
+	if last == 0 {
+		return sql
+	}
+	anonymized.WriteString(sql[last:])
+	return anonymized.String()
+}
+
+// anonymizeArgs returns args with their string, byte, numeric, and UUID values replaced with fakes.
+// Fakes of numbers and UUIDs are kept as such if they still parse, and as text otherwise, e.g. if they are redacted.
+func (b *databaseModificationBuilder) anonymizeArgs(args []driver.NamedValue) []driver.NamedValue {
+	anonymized := make([]driver.NamedValue, len(args))
+	for i, arg := range args {
+		switch v := arg.Value.(type) {
+		case string:
+			arg.Value = b.anonymizer.Anonymize(v)
+		case []byte:
+			arg.Value = []byte(b.anonymizer.Anonymize(string(v)))
+		case uuid.UUID:
+			fake := b.anonymizer.Anonymize(v.String())
+			arg.Value = fake
+			if id, err := uuid.Parse(fake); err == nil {
+				arg.Value = id
+			}
+		case int64:
+			fake := b.anonymizer.Anonymize(strconv.FormatInt(v, 10))
+			arg.Value = fake
+			if n, err := strconv.ParseInt(fake, 10, 64); err == nil {
+				arg.Value = n
+			}
+		case float64:
+			fake := b.anonymizer.Anonymize(strconv.FormatFloat(v, 'f', -1, 64))
+			arg.Value = fake
+			if f, err := strconv.ParseFloat(fake, 64); err == nil {
+				arg.Value = f
+			}
+		}
+		anonymized[i] = arg
+	}
+	return anonymized
+}
@@ -0,0 +1,89 @@
+package audriver
+
+import (
+	"database/sql/driver"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ArgsMetadataKey is the key of the arguments of statements in the metadata of database modifications.
+const ArgsMetadataKey = "args"
+
+// Types of captured arguments.
+const (
+	ArgTypeNull      = "null"
+	ArgTypeInt       = "int64"
+	ArgTypeFloat     = "float64"
+	ArgTypeBool      = "bool"
+	ArgTypeString    = "string"
+	ArgTypeUUID      = "uuid"
+	ArgTypeBytes     = "bytes"
+	ArgTypeTimestamp = "timestamp"
+)
+
+// CapturedArg is an argument of a statement recorded with WithArgCapture.
+// Values keep their type in JSON, so that consumers can filter numerically, e.g.
+// metadata -> 'args' @> '[{"ordinal": 1, "value": 42}]' or (metadata -> 'args' -> 0 ->> 'value')::bigint > 100.
+type CapturedArg struct {
+	// Ordinal is the position of the argument, starting at 1, e.g. 1 for $1.
+	Ordinal int `json:"ordinal"`
+
+	// Name is the name of the argument, if it is a named argument.
+	Name string `json:"name,omitempty"`
+
+	// Type is one of the ArgType constants, or the Go type of values of other types, e.g. "main.Status".
+	Type string `json:"type"`
+
+	// Value is the value as a JSON number for integers and floats, a JSON boolean for booleans, null for nulls,
+	// the canonical string for UUIDs, RFC 3339 with nanoseconds for timestamps, hex for bytes,
+	// and the formatted value for values of other types.
+	Value any `json:"value"`
+}
+
+// captureArgs returns the arguments of a statement with their types.
+func captureArgs(args []driver.NamedValue) []CapturedArg {
+	captured := make([]CapturedArg, len(args))
+	for i, arg := range args {
+		typ, value := captureValue(arg.Value)
+		captured[i] = CapturedArg{Ordinal: arg.Ordinal, Name: arg.Name, Type: typ, Value: value}
+	}
+	return captured
+}
+
+// captureValue returns the type and the JSON value of v.
+func captureValue(v any) (string, any) {
+	switch v := v.(type) {
+	case nil:
+		return ArgTypeNull, nil
+	case int64:
+		return ArgTypeInt, v
+	case int:
+		return ArgTypeInt, int64(v)
+	case int32:
+		return ArgTypeInt, int64(v)
+	case float64:
+		return ArgTypeFloat, v
+	case float32:
+		return ArgTypeFloat, float64(v)
+	case bool:
+		return ArgTypeBool, v
+	case string:
+		if len(v) == 36 && uuid.Validate(v) == nil {
+			return ArgTypeUUID, v
+		}
+		return ArgTypeString, v
+	case uuid.UUID:
+		return ArgTypeUUID, v.String()
+	case []byte:
+		return ArgTypeBytes, hex.EncodeToString(v)
+	case time.Time:
+		return ArgTypeTimestamp, v.Format(time.RFC3339Nano)
+	case fmt.Stringer:
+		return fmt.Sprintf("%T", v), v.String()
+	default:
+		return fmt.Sprintf("%T", v), fmt.Sprintf("%v", v)
+	}
+}
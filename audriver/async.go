@@ -0,0 +1,152 @@
+package audriver
+
+import (
+	"context"
+	"fmt"
+	"hash/maphash"
+	"slices"
+	"sync"
+)
+
+// BackpressurePolicy is what happens to audit records written with WithAsyncLogging when their queue is full.
+type BackpressurePolicy string
+
+const (
+	// BackpressureBlock waits for room in the queue, or for the context of the statement to be done, slowing down
+	// statements and commits to the pace of the sink. It is the default.
+	BackpressureBlock BackpressurePolicy = "block"
+
+	// BackpressureDrop drops the records, counting them in AuditStats().DroppedRecords, so that statements never wait
+	// for the sink.
+	BackpressureDrop BackpressurePolicy = "drop"
+
+	// BackpressureError fails the write of the records with ErrAuditQueueFull. As records are enqueued once their
+	// statements and transactions are committed, the failure is reported to the error handler and the records are put
+	// into the dead letter queue, rather than failing them.
+	BackpressureError BackpressurePolicy = "error"
+)
+
+// asyncLogging is the configuration of WithAsyncLogging.
+type asyncLogging struct {
+	queueSize int
+	workers   int
+}
+
+// asyncWriter is an AuditSink enqueueing audit records to be written to sink by background workers, so that
+// statements do not wait for the sink. Records are queued by their execution ID, each worker having its own queue,
+// so that the records of an execution reach the sink in order.
+type asyncWriter struct {
+	sink    AuditSink
+	policy  BackpressurePolicy
+	builder *databaseModificationBuilder
+
+	seed   maphash.Seed
+	queues []chan asyncWrite
+
+	// mu guards closing the queues against writes enqueueing records.
+	mu     sync.RWMutex
+	closed bool
+	wg     sync.WaitGroup
+}
+
+// asyncWrite is a write of records enqueued, with the context of their statement.
+type asyncWrite struct {
+	ctx  context.Context
+	mods []DatabaseModification
+}
+
+func newAsyncWriter(b *databaseModificationBuilder, sink AuditSink, cfg asyncLogging, policy BackpressurePolicy) *asyncWriter {
+	workers := max(cfg.workers, 1)
+	w := &asyncWriter{sink: sink, policy: policy, builder: b, seed: maphash.MakeSeed()}
+	w.queues = make([]chan asyncWrite, workers)
+	for i := range w.queues {
+		w.queues[i] = make(chan asyncWrite, (max(cfg.queueSize, 0)+workers-1)/workers)
+		w.wg.Add(1)
+		go w.work(w.queues[i])
+	}
+	return w
+}
+
+// String describes the sink records are written to.
+func (w *asyncWriter) String() string {
+	return describe(w.sink)
+}
+
+// Write enqueues mods to the queues of their executions, applying the backpressure policy to full queues.
+// After close, mods are written to the sink directly.
+func (w *asyncWriter) Write(ctx context.Context, mods []DatabaseModification) error {
+	w.mu.RLock()
+	if w.closed {
+		w.mu.RUnlock()
+		return writeSink(ctx, w.sink, mods)
+	}
+	defer w.mu.RUnlock()
+
+	return splitByExecution(mods, w.queue, func(queue chan asyncWrite, mods []DatabaseModification) error {
+		return w.enqueue(ctx, queue, mods)
+	})
+}
+
+// queue returns the queue of the records of executionID.
+func (w *asyncWriter) queue(executionID string) chan asyncWrite {
+	return w.queues[executionPartition(w.seed, executionID, len(w.queues))]
+}
+
+// enqueue enqueues mods to queue, applying the backpressure policy if it is full.
+func (w *asyncWriter) enqueue(ctx context.Context, queue chan asyncWrite, mods []DatabaseModification) error {
+	// mods are released to the pool of the transaction after Write returns, and written after the statement is done
+	write := asyncWrite{ctx: context.WithoutCancel(ctx), mods: slices.Clone(mods)}
+	select {
+	case queue <- write:
+		return nil
+	default:
+	}
+
+	switch w.policy {
+	case BackpressureDrop:
+		w.builder.stats.droppedRecords.Add(int64(len(mods)))
+		return nil
+	case BackpressureError:
+		return fmt.Errorf("%w: %d writes queued", ErrAuditQueueFull, cap(queue))
+	default:
+		select {
+		case queue <- write:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// work writes the records of queue until it is closed.
+func (w *asyncWriter) work(queue <-chan asyncWrite) {
+	defer w.wg.Done()
+	for write := range queue {
+		_ = w.builder.writeDetached(write.ctx, w.sink, write.mods)
+	}
+}
+
+// close stops enqueueing records and waits for the workers to write the queued ones, or for ctx to be done.
+// Records written meanwhile wait for the queued ones, so that they do not overtake them.
+func (w *asyncWriter) close(ctx context.Context) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.closed {
+		w.closed = true
+		for _, queue := range w.queues {
+			close(queue)
+		}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		w.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("failed to drain audit queue: %w", ctx.Err())
+	}
+}
@@ -14,6 +14,7 @@ import (
 )
 
 const (
+	adminDSN  = "user=postgres password=password dbname=audriver host=localhost port=5432 sslmode=disable"
 	readerDSN = "user=audriver_reader password=password dbname=audriver host=localhost port=5432 sslmode=disable"
 	writerDSN = "user=audriver_writer password=password dbname=audriver host=localhost port=5432 sslmode=disable"
 )
@@ -21,6 +22,7 @@ const (
 func init() {
 	txdb.Register("txdb_writer", "postgres", writerDSN)
 	txdb.Register("txdb_reader", "postgres", readerDSN)
+	txdb.Register("txdb_admin", "postgres", adminDSN)
 }
 
 func setUpReaderTestDB(t *testing.T) *sql.DB {
@@ -43,13 +45,32 @@ func setUpReaderTestDB(t *testing.T) *sql.DB {
 	return db
 }
 
-func setUpWriterTestDB(t *testing.T) *sql.DB {
+func setUpWriterTestDB(t *testing.T, options ...audriver.Option) *sql.DB {
 	t.Helper()
 
 	driverName := fmt.Sprintf("writer_test_%s_%d", t.Name(), gofakeit.Number(1000, 9999))
 
 	baseDriver := txdb.New("postgres", writerDSN)
-	auditDriver := audriver.New(baseDriver)
+	auditDriver := audriver.New(baseDriver, options...)
+
+	sql.Register(driverName, auditDriver)
+
+	db, err := sql.Open(driverName, driverName)
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		_ = db.Close()
+	})
+
+	return db
+}
+
+func setUpFakeTestDB(t *testing.T, baseDriver *fakeDriver, options ...audriver.Option) *sql.DB {
+	t.Helper()
+
+	driverName := fmt.Sprintf("fake_test_%s_%d", t.Name(), gofakeit.Number(1000, 9999))
+
+	auditDriver := audriver.New(baseDriver, options...)
 
 	sql.Register(driverName, auditDriver)
 
@@ -0,0 +1,69 @@
+package audriver
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"strconv"
+)
+
+// backendPID caches the PostgreSQL backend PID of a connection, shared by the connection and its transactions.
+type backendPID struct {
+	pid int64
+}
+
+// recordBackendPID records the backend PID of conn on mods, querying it once per connection.
+func recordBackendPID(ctx context.Context, conn driver.Conn, cache *backendPID, mods []DatabaseModification) error {
+	if cache.pid == 0 {
+		values, err := queryRow(ctx, conn, "SELECT pg_backend_pid()", nil)
+		if err != nil {
+			return fmt.Errorf("failed to query backend PID: %w", err)
+		}
+		if len(values) < 1 {
+			return fmt.Errorf("failed to query backend PID: no rows")
+		}
+		if cache.pid, err = asInt64(values[0]); err != nil {
+			return fmt.Errorf("failed to parse backend PID: %w", err)
+		}
+	}
+	for i := range mods {
+		mods[i].BackendPID = cache.pid
+	}
+	return nil
+}
+
+// recordTransactionID records the backend PID and the transaction ID of the transaction in progress on mods,
+// querying them once per transaction.
+func (tc *txConn) recordTransactionID(ctx context.Context, mods []DatabaseModification) error {
+	if tc.transactionID == 0 {
+		values, err := queryRow(ctx, tc.Conn, "SELECT pg_backend_pid(), txid_current()", nil)
+		if err != nil {
+			return fmt.Errorf("failed to query transaction ID: %w", err)
+		}
+		if len(values) < 2 {
+			return fmt.Errorf("failed to query transaction ID: no rows")
+		}
+		if tc.backend.pid, err = asInt64(values[0]); err != nil {
+			return fmt.Errorf("failed to parse backend PID: %w", err)
+		}
+		if tc.transactionID, err = asInt64(values[1]); err != nil {
+			return fmt.Errorf("failed to parse transaction ID: %w", err)
+		}
+	}
+	for i := range mods {
+		mods[i].BackendPID = tc.backend.pid
+		mods[i].TransactionID = tc.transactionID
+	}
+	return nil
+}
+
+func asInt64(v driver.Value) (int64, error) {
+	switch v := v.(type) {
+	case int64:
+		return v, nil
+	case nil:
+		return 0, fmt.Errorf("unexpected NULL")
+	default:
+		return strconv.ParseInt(asString(v), 10, 64)
+	}
+}
@@ -0,0 +1,420 @@
+package audriver
+
+import (
+	"context"
+	"crypto/md5"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/mickamy/go-sql-audit-driver/internal/postgres"
+)
+
+// BackfillMetadataKey is the metadata key under which Backfill records the replication slot and the WAL position of
+// the change a modification was reconstructed from.
+const BackfillMetadataKey = "backfill"
+
+// DefaultBackfillBatchSize is the default number of changes Backfill decodes per round trip.
+const DefaultBackfillBatchSize = 10000
+
+// backfillNamespace is the namespace of the name-based UUIDs derived for backfilled modifications from their WAL
+// position, so that backfilling the same changes twice does not record them twice.
+var backfillNamespace = uuid.MustParse("5f0c7a3e-2b1d-4e8a-9c6f-0d3b8e4a7f21")
+
+// BackfillConfig configures Backfill.
+type BackfillConfig struct {
+	// Slot is the logical replication slot to consume, created with the wal2json output plugin, e.g. with
+	// SELECT pg_create_logical_replication_slot('audriver_backfill', 'wal2json').
+	Slot string
+
+	// OperatorID is recorded as operator_id for backfilled modifications.
+	// It defaults to DefaultTriggerOperatorID, and must be a UUID if operator_id is a UUID column.
+	OperatorID string
+
+	// Tables restricts backfilling to these tables, optionally schema-qualified. All tables are backfilled if empty.
+	Tables []string
+
+	// From and To restrict backfilling to transactions committed within [From, To), e.g. the window in which auditing
+	// was disabled. Transactions committed before From are consumed and skipped; Backfill stops at the first
+	// transaction committed at or after To, leaving it in the slot. Zero values do not restrict.
+	From time.Time
+	To   time.Time
+
+	// AuditTable is the audit table written to, optionally schema-qualified. It defaults to DefaultAuditTable.
+	AuditTable string
+
+	// BatchSize is the number of changes decoded per round trip. It defaults to DefaultBackfillBatchSize.
+	BatchSize int
+
+	// DryRun reports what would be backfilled without writing records or consuming the slot.
+	DryRun bool
+}
+
+// BackfillResult summarizes a Backfill run.
+type BackfillResult struct {
+	// Transactions is the number of transactions modifications were backfilled for.
+	Transactions int `json:"transactions"`
+
+	// Modifications is the number of modifications backfilled.
+	Modifications int `json:"modifications"`
+
+	// Audited is the number of transactions skipped because they were already audited.
+	Audited int `json:"audited"`
+
+	// Skipped is the number of transactions skipped because they were committed before From or modified only
+	// tables that are not backfilled.
+	Skipped int `json:"skipped"`
+
+	// LSN is the position the slot was consumed up to, empty if nothing was consumed.
+	LSN string `json:"lsn,omitempty"`
+}
+
+// Backfill closes gaps of the audit trail, e.g. while auditing was disabled or bypassed, by reconstructing
+// modifications from the changes of a logical replication slot decoded with wal2json.
+//
+// Each inserted, updated, or deleted row becomes a modification of its table, with a statement reconstructed from
+// the decoded row values, the commit time as modified_at, and an execution ID derived from the transaction ID, as by
+// the triggers of GenerateTriggerSQL. Transactions writing the audit table itself were audited and are skipped, and
+// so are changes of the audit table. Records are written with IDs derived from the WAL position of their change,
+// before the slot is advanced past it, so that an interrupted run can be resumed without recording changes twice.
+//
+// Backfill returns once the slot has no more changes, or at the first transaction committed at or after cfg.To.
+func Backfill(ctx context.Context, db *sql.DB, cfg BackfillConfig) (BackfillResult, error) {
+	if cfg.Slot == "" {
+		return BackfillResult{}, errors.New("replication slot is required")
+	}
+	decoder := newBackfillDecoder(cfg)
+	batchSize := cfg.BatchSize
+	if batchSize <= 0 {
+		batchSize = DefaultBackfillBatchSize
+	}
+
+	var result BackfillResult
+	for {
+		// dry runs do not advance the slot, so they peek past the changes decoded before
+		changes, err := peekChanges(ctx, db, cfg.Slot, decoder.decoded+batchSize)
+		if err != nil {
+			return result, err
+		}
+		if cfg.DryRun {
+			changes = changes[min(decoder.decoded, len(changes)):]
+		}
+		mods, consumed, done, err := decoder.decode(changes, &result)
+		if err != nil {
+			return result, err
+		}
+		if !cfg.DryRun && len(mods) > 0 {
+			if err := writeBackfill(ctx, db, decoder.auditTable, mods); err != nil {
+				return result, err
+			}
+		}
+		if consumed != "" {
+			if !cfg.DryRun {
+				query := "SELECT pg_replication_slot_advance($1, $2::pg_lsn)"
+				if _, err := db.ExecContext(ctx, query, cfg.Slot, consumed); err != nil {
+					return result, fmt.Errorf("failed to advance replication slot: %w", err)
+				}
+			}
+			result.LSN = consumed
+		}
+		if done || consumed == "" {
+			return result, nil
+		}
+	}
+}
+
+// walChange is a change of a logical replication slot.
+type walChange struct {
+	lsn  string
+	data string
+}
+
+// peekChanges reads at least n changes of whole transactions from slot without consuming them.
+func peekChanges(ctx context.Context, db *sql.DB, slot string, n int) ([]walChange, error) {
+	query := `SELECT lsn::text, data FROM pg_logical_slot_peek_changes($1, NULL, $2,
+    'format-version', '2', 'include-xids', '1', 'include-timestamp', '1', 'include-pk', '1')`
+	rows, err := db.QueryContext(ctx, query, slot, n)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read replication slot: %w", err)
+	}
+	defer func(rows *sql.Rows) {
+		_ = rows.Close()
+	}(rows)
+
+	var changes []walChange
+	for rows.Next() {
+		var change walChange
+		if err := rows.Scan(&change.lsn, &change.data); err != nil {
+			return nil, fmt.Errorf("failed to scan change: %w", err)
+		}
+		changes = append(changes, change)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read replication slot: %w", err)
+	}
+	return changes, nil
+}
+
+// writeBackfill writes mods into table, skipping modifications backfilled before.
+func writeBackfill(ctx context.Context, db *sql.DB, table string, mods []DatabaseModification) error {
+	for chunk := range slices.Chunk(mods, 1000) {
+		query, namedArgs, err := buildInsert(PostgreSQL, table, chunk)
+		if err != nil {
+			return err
+		}
+		args := make([]any, len(namedArgs))
+		for i, arg := range namedArgs {
+			args[i] = arg.Value
+		}
+		if _, err := db.ExecContext(ctx, query+PostgreSQL.ignoreConflicts(), args...); err != nil {
+			return fmt.Errorf("failed to write backfilled modifications: %w", err)
+		}
+	}
+	return nil
+}
+
+// walMessage is a message of wal2json format version 2.
+type walMessage struct {
+	Action    string      `json:"action"`
+	XID       int64       `json:"xid"`
+	Timestamp string      `json:"timestamp"`
+	Schema    string      `json:"schema"`
+	Table     string      `json:"table"`
+	Columns   []walColumn `json:"columns"`
+	Identity  []walColumn `json:"identity"`
+	PK        []walColumn `json:"pk"`
+}
+
+// walColumn is a column of a row of a wal2json message.
+type walColumn struct {
+	Name  string `json:"name"`
+	Value any    `json:"value"`
+}
+
+// backfillDecoder reconstructs modifications from wal2json messages, a transaction at a time.
+type backfillDecoder struct {
+	cfg         BackfillConfig
+	operatorID  string
+	auditSchema string
+	auditTable  string
+
+	// decoded is the number of changes decoded without advancing the slot, in dry runs.
+	decoded int
+
+	// the transaction being decoded
+	mods    []DatabaseModification
+	audited bool
+	begunAt time.Time
+}
+
+func newBackfillDecoder(cfg BackfillConfig) *backfillDecoder {
+	d := &backfillDecoder{cfg: cfg, operatorID: cfg.OperatorID, auditTable: cfg.AuditTable}
+	if d.operatorID == "" {
+		d.operatorID = DefaultTriggerOperatorID
+	}
+	if d.auditTable == "" {
+		d.auditTable = DefaultAuditTable
+	}
+	d.auditSchema, _ = splitQualifiedName(d.auditTable)
+	return d
+}
+
+// decode decodes changes of whole transactions, adding them to result. It returns the modifications of the
+// transactions to backfill, the position to advance the slot to, and whether a transaction committed at or after
+// To was reached.
+func (d *backfillDecoder) decode(changes []walChange, result *BackfillResult) ([]DatabaseModification, string, bool, error) {
+	var (
+		mods     []DatabaseModification
+		consumed string
+	)
+	for _, change := range changes {
+		decoder := json.NewDecoder(strings.NewReader(change.data))
+		decoder.UseNumber()
+		var msg walMessage
+		if err := decoder.Decode(&msg); err != nil {
+			return nil, "", false, fmt.Errorf("failed to decode change at %s; is the slot using wal2json?: %w", change.lsn, err)
+		}
+
+		switch msg.Action {
+		case "B":
+			d.mods, d.audited = d.mods[:0], false
+			d.begunAt, _ = parseWalTimestamp(msg.Timestamp)
+		case "I", "U", "D":
+			if d.isAuditTable(msg.Schema, msg.Table) {
+				d.audited = true
+				continue
+			}
+			if d.backfills(msg.Schema, msg.Table) {
+				d.mods = append(d.mods, d.modification(change.lsn, msg))
+			}
+		case "C":
+			committedAt, err := parseWalTimestamp(msg.Timestamp)
+			if err != nil {
+				committedAt = d.begunAt
+			}
+			if !d.cfg.To.IsZero() && !committedAt.Before(d.cfg.To) {
+				return mods, consumed, true, nil
+			}
+			switch {
+			case d.audited:
+				result.Audited++
+			case len(d.mods) == 0 || !d.cfg.From.IsZero() && committedAt.Before(d.cfg.From):
+				result.Skipped++
+			default:
+				for i := range d.mods {
+					d.mods[i].ModifiedAt = committedAt
+				}
+				mods = append(mods, d.mods...)
+				result.Transactions++
+				result.Modifications += len(d.mods)
+			}
+			d.mods = nil
+			consumed = change.lsn
+		}
+		if d.cfg.DryRun {
+			d.decoded++
+		}
+	}
+	return mods, consumed, false, nil
+}
+
+// isAuditTable reports whether schema.table is the audit table.
+func (d *backfillDecoder) isAuditTable(schema, table string) bool {
+	_, auditTable := splitQualifiedName(d.auditTable)
+	return table == auditTable && (d.auditSchema == "" || schema == d.auditSchema)
+}
+
+// backfills reports whether modifications of schema.table are backfilled.
+func (d *backfillDecoder) backfills(schema, table string) bool {
+	if len(d.cfg.Tables) == 0 {
+		return true
+	}
+	for _, name := range d.cfg.Tables {
+		s, t := splitQualifiedName(name)
+		if t == table && (s == "" || s == schema) {
+			return true
+		}
+	}
+	return false
+}
+
+// modification reconstructs the modification of the row of msg, decoded at lsn.
+func (d *backfillDecoder) modification(lsn string, msg walMessage) DatabaseModification {
+	// the key of updated and deleted rows is their replica identity; of inserted rows, the primary key columns
+	key := msg.Identity
+	if len(key) == 0 {
+		for _, pk := range msg.PK {
+			if i := slices.IndexFunc(msg.Columns, func(c walColumn) bool { return c.Name == pk.Name }); i >= 0 {
+				key = append(key, msg.Columns[i])
+			}
+		}
+	}
+
+	table := postgres.QuoteIdentifier(msg.Schema) + "." + postgres.QuoteIdentifier(msg.Table)
+	var (
+		query  strings.Builder
+		action DatabaseModificationAction
+	)
+	switch msg.Action {
+	case "I":
+		action = DatabaseModificationActionInsert
+		names, values := make([]string, len(msg.Columns)), make([]string, len(msg.Columns))
+		for i, column := range msg.Columns {
+			names[i], values[i] = postgres.QuoteIdentifier(column.Name), walLiteral(column.Value)
+		}
+		query.WriteString("INSERT INTO " + table + " (" + strings.Join(names, ", ") + ") VALUES (" + strings.Join(values, ", ") + ")")
+	case "U":
+		action = DatabaseModificationActionUpdate
+		assignments := make([]string, len(msg.Columns))
+		for i, column := range msg.Columns {
+			assignments[i] = postgres.QuoteIdentifier(column.Name) + " = " + walLiteral(column.Value)
+		}
+		query.WriteString("UPDATE " + table + " SET " + strings.Join(assignments, ", ") + walWhere(key))
+	default:
+		action = DatabaseModificationActionDelete
+		query.WriteString("DELETE FROM " + table + walWhere(key))
+	}
+
+	mod := DatabaseModification{
+		ID:            uuid.NewSHA1(backfillNamespace, []byte(lsn)).String(),
+		OperatorID:    d.operatorID,
+		ExecutionID:   walExecutionID(msg.XID),
+		SchemaName:    msg.Schema,
+		TableName:     msg.Table,
+		Action:        action,
+		SQL:           query.String(),
+		TransactionID: msg.XID,
+	}
+	// only simple keys identify records by a single ID
+	if len(key) == 1 && key[0].Value != nil {
+		mod.RecordIDs = []string{fmt.Sprint(key[0].Value)}
+	}
+	mod.SetMetadata(BackfillMetadataKey, map[string]any{"slot": d.cfg.Slot, "lsn": lsn})
+	return mod
+}
+
+// walWhere returns the WHERE clause matching the row with key, or an empty string for rows without a key.
+func walWhere(key []walColumn) string {
+	if len(key) == 0 {
+		return ""
+	}
+	conditions := make([]string, len(key))
+	for i, column := range key {
+		if column.Value == nil {
+			conditions[i] = postgres.QuoteIdentifier(column.Name) + " IS NULL"
+			continue
+		}
+		conditions[i] = postgres.QuoteIdentifier(column.Name) + " = " + walLiteral(column.Value)
+	}
+	return " WHERE " + strings.Join(conditions, " AND ")
+}
+
+// walLiteral formats a value decoded by wal2json as an SQL literal.
+func walLiteral(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return "NULL"
+	case bool:
+		return strings.ToUpper(strconv.FormatBool(v))
+	case json.Number:
+		return v.String()
+	case string:
+		return postgres.QuoteLiteral(v)
+	default:
+		data, _ := json.Marshal(v)
+		return postgres.QuoteLiteral(string(data))
+	}
+}
+
+// walExecutionID derives the execution ID of the modifications of transaction xid as the triggers of
+// GenerateTriggerSQL do, so that modifications backfilled and recorded by triggers for a transaction share it.
+func walExecutionID(xid int64) string {
+	sum := md5.Sum([]byte(strconv.FormatInt(xid, 10)))
+	id, _ := uuid.FromBytes(sum[:])
+	return id.String()
+}
+
+// parseWalTimestamp parses a timestamp of wal2json, formatted as timestamptz values are by PostgreSQL.
+func parseWalTimestamp(value string) (time.Time, error) {
+	for _, layout := range []string{"2006-01-02 15:04:05.999999-07", "2006-01-02 15:04:05.999999-07:00"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid timestamp: %q", value)
+}
+
+// splitQualifiedName splits a possibly schema-qualified name into its schema and name.
+func splitQualifiedName(name string) (string, string) {
+	if i := strings.LastIndexByte(name, '.'); i >= 0 {
+		return name[:i], name[i+1:]
+	}
+	return "", name
+}
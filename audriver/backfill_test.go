@@ -0,0 +1,116 @@
+package audriver_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mickamy/go-sql-audit-driver/audriver"
+)
+
+// TestDecodeBackfill tests reconstructing modifications from wal2json changes
+func TestDecodeBackfill(t *testing.T) {
+	t.Parallel()
+
+	changes := [][2]string{
+		// an unaudited transaction
+		{"0/1000", `{"action":"B","xid":700,"timestamp":"2025-01-02 03:04:05.5+00"}`},
+		{"0/1010", `{"action":"I","xid":700,"schema":"public","table":"users","columns":[{"name":"id","type":"integer","value":1},{"name":"name","type":"text","value":"O'Brien"},{"name":"active","type":"boolean","value":true},{"name":"note","type":"text","value":null}],"pk":[{"name":"id","type":"integer"}]}`},
+		{"0/1020", `{"action":"U","xid":700,"schema":"public","table":"users","columns":[{"name":"id","type":"integer","value":1},{"name":"score","type":"numeric","value":1.50}],"identity":[{"name":"id","type":"integer","value":1}]}`},
+		{"0/1030", `{"action":"D","xid":700,"schema":"billing","table":"invoices","identity":[{"name":"id","type":"bigint","value":9},{"name":"year","type":"integer","value":2025}]}`},
+		{"0/1040", `{"action":"C","xid":700,"timestamp":"2025-01-02 03:04:06.25+00"}`},
+		// an audited transaction
+		{"0/2000", `{"action":"B","xid":701,"timestamp":"2025-01-02 04:00:00+00"}`},
+		{"0/2010", `{"action":"I","xid":701,"schema":"public","table":"users","columns":[{"name":"id","type":"integer","value":2}],"pk":[{"name":"id","type":"integer"}]}`},
+		{"0/2020", `{"action":"I","xid":701,"schema":"public","table":"database_modifications","columns":[{"name":"id","type":"uuid","value":"0b4e8e2c-3f6c-4d8e-9a57-3d1f0e2b6c11"}]}`},
+		{"0/2030", `{"action":"C","xid":701,"timestamp":"2025-01-02 04:00:00+00"}`},
+		// a transaction after the window
+		{"0/3000", `{"action":"B","xid":702,"timestamp":"2025-01-03 00:00:00+00"}`},
+		{"0/3010", `{"action":"D","xid":702,"schema":"public","table":"users","identity":[{"name":"id","type":"integer","value":1}]}`},
+		{"0/3020", `{"action":"C","xid":702,"timestamp":"2025-01-03 00:00:00+00"}`},
+	}
+
+	t.Run("window", func(t *testing.T) {
+		t.Parallel()
+
+		// act
+		mods, result, consumed, done, err := audriver.DecodeBackfill(audriver.BackfillConfig{
+			Slot: "audriver_backfill",
+			To:   time.Date(2025, 1, 3, 0, 0, 0, 0, time.UTC),
+		}, changes)
+
+		// assert
+		require.NoError(t, err)
+		assert.True(t, done, "decoding should stop at the first transaction after the window")
+		assert.Equal(t, "0/2030", consumed)
+		assert.Equal(t, audriver.BackfillResult{Transactions: 1, Modifications: 3, Audited: 1}, result)
+		require.Len(t, mods, 3)
+
+		assert.Equal(t, audriver.DatabaseModificationActionInsert, mods[0].Action)
+		assert.Equal(t, `INSERT INTO "public"."users" ("id", "name", "active", "note") VALUES (1, 'O''Brien', TRUE, NULL)`, mods[0].SQL)
+		assert.Equal(t, []string{"1"}, mods[0].RecordIDs)
+		assert.Equal(t, audriver.DefaultTriggerOperatorID, mods[0].OperatorID)
+		assert.Equal(t, "public", mods[0].SchemaName)
+		assert.Equal(t, "users", mods[0].TableName)
+		assert.Equal(t, int64(700), mods[0].TransactionID)
+		assert.Equal(t, time.Date(2025, 1, 2, 3, 4, 6, 250000000, time.UTC), mods[0].ModifiedAt.UTC(), "modifications should be dated at the commit")
+		assert.Equal(t, map[string]any{"backfill": map[string]any{"slot": "audriver_backfill", "lsn": "0/1010"}}, mods[0].Metadata)
+
+		assert.Equal(t, `UPDATE "public"."users" SET "id" = 1, "score" = 1.50 WHERE "id" = 1`, mods[1].SQL)
+		assert.Equal(t, `DELETE FROM "billing"."invoices" WHERE "id" = 9 AND "year" = 2025`, mods[2].SQL)
+		assert.Empty(t, mods[2].RecordIDs, "composite keys should not be recorded as record IDs")
+
+		for _, mod := range mods {
+			assert.Equal(t, mods[0].ExecutionID, mod.ExecutionID, "modifications of a transaction should share an execution ID")
+		}
+		assert.NotEqual(t, mods[0].ID, mods[1].ID)
+	})
+
+	t.Run("deterministic_ids", func(t *testing.T) {
+		t.Parallel()
+
+		// act
+		first, _, _, _, err := audriver.DecodeBackfill(audriver.BackfillConfig{Slot: "audriver_backfill"}, changes)
+		require.NoError(t, err)
+		second, result, consumed, done, err := audriver.DecodeBackfill(audriver.BackfillConfig{Slot: "audriver_backfill"}, changes)
+
+		// assert
+		require.NoError(t, err)
+		assert.False(t, done)
+		assert.Equal(t, "0/3020", consumed)
+		assert.Equal(t, 2, result.Transactions)
+		require.Len(t, second, 4)
+		for i := range first {
+			assert.Equal(t, first[i].ID, second[i].ID, "backfilling changes again should derive the same IDs")
+		}
+	})
+
+	t.Run("tables", func(t *testing.T) {
+		t.Parallel()
+
+		// act
+		mods, result, _, _, err := audriver.DecodeBackfill(audriver.BackfillConfig{
+			Slot:   "audriver_backfill",
+			Tables: []string{"billing.invoices"},
+			From:   time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC),
+		}, changes)
+
+		// assert
+		require.NoError(t, err)
+		require.Len(t, mods, 1)
+		assert.Equal(t, "invoices", mods[0].TableName)
+		assert.Equal(t, audriver.BackfillResult{Transactions: 1, Modifications: 1, Audited: 1, Skipped: 1}, result)
+	})
+
+	t.Run("not_wal2json", func(t *testing.T) {
+		t.Parallel()
+
+		// act
+		_, _, _, _, err := audriver.DecodeBackfill(audriver.BackfillConfig{Slot: "audriver_backfill"}, [][2]string{{"0/1000", "BEGIN 700"}})
+
+		// assert
+		assert.ErrorContains(t, err, "wal2json")
+	})
+}
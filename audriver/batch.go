@@ -0,0 +1,218 @@
+package audriver
+
+import (
+	"context"
+	"errors"
+	"hash/maphash"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// batchWindow is the window audit records are batched within before they are written to the sink; see WithBatchWindow.
+type batchWindow struct {
+	maxRecords int
+	maxBytes   int
+	maxLatency time.Duration
+}
+
+// batcher is an AuditSink buffering audit records and writing them to sink in batches, when the window is full or
+// its latency elapsed. Failures of batches are reported to the error handler, as the statements are done by then.
+// Records are partitioned by their execution ID: batches of a partition are written one at a time, so that records of
+// an execution reach the sink in order, while those of other executions are written concurrently.
+type batcher struct {
+	sink    AuditSink
+	window  batchWindow
+	builder *databaseModificationBuilder
+
+	seed       maphash.Seed
+	partitions []*batchPartition
+}
+
+// batchPartition is the window of the records of some executions.
+type batchPartition struct {
+	*batcher
+
+	mu      sync.Mutex
+	pending []DatabaseModification
+	size    int
+	timer   *time.Timer
+	closed  bool
+
+	// flushMu serializes writes of the partition, so that records reach the sink in the order they were buffered.
+	flushMu sync.Mutex
+}
+
+func newBatcher(b *databaseModificationBuilder, sink AuditSink, window batchWindow) *batcher {
+	w := &batcher{sink: sink, window: window, builder: b, seed: maphash.MakeSeed()}
+	w.partitions = make([]*batchPartition, runtime.GOMAXPROCS(0))
+	for i := range w.partitions {
+		w.partitions[i] = &batchPartition{batcher: w}
+	}
+	return w
+}
+
+// String describes the sink batches are written to.
+func (w *batcher) String() string {
+	return describe(w.sink)
+}
+
+// Write buffers mods in the partitions of their executions, writing the batch of a partition if its window is full.
+// After close, mods are written to the sink directly.
+func (w *batcher) Write(ctx context.Context, mods []DatabaseModification) error {
+	return splitByExecution(mods, w.partition, func(p *batchPartition, mods []DatabaseModification) error {
+		return p.write(ctx, mods)
+	})
+}
+
+// partition returns the partition of the records of executionID.
+func (w *batcher) partition(executionID string) *batchPartition {
+	return w.partitions[executionPartition(w.seed, executionID, len(w.partitions))]
+}
+
+// executionPartition returns the index of the partition of the records of executionID among n partitions.
+func executionPartition(seed maphash.Seed, executionID string, n int) int {
+	if n == 1 {
+		return 0
+	}
+	return int(maphash.String(seed, executionID) % uint64(n))
+}
+
+// splitByExecution calls write with the mods of each partition returned by partition for their execution IDs, in the
+// order of their first records, keeping the order of the records of each partition. It returns the errors joined.
+func splitByExecution[P comparable](mods []DatabaseModification, partition func(executionID string) P, write func(p P, mods []DatabaseModification) error) error {
+	first := partition(mods[0].ExecutionID)
+	split := false
+	for i := range mods {
+		if partition(mods[i].ExecutionID) != first {
+			split = true
+			break
+		}
+	}
+	if !split {
+		return write(first, mods)
+	}
+
+	// records of a transaction usually share their execution; others are split by partition, keeping their order
+	byPartition := make(map[P][]DatabaseModification)
+	var order []P
+	for i := range mods {
+		p := partition(mods[i].ExecutionID)
+		if _, ok := byPartition[p]; !ok {
+			order = append(order, p)
+		}
+		byPartition[p] = append(byPartition[p], mods[i])
+	}
+	var errs []error
+	for _, p := range order {
+		if err := write(p, byPartition[p]); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// close writes the buffered records and stops batching, so that later records are written to the sink directly.
+func (w *batcher) close(ctx context.Context) error {
+	var errs []error
+	for _, p := range w.partitions {
+		if err := p.close(ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// write buffers mods, writing the batch if the window is full.
+func (p *batchPartition) write(ctx context.Context, mods []DatabaseModification) error {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		// in turn with batches being written, so that the records do not overtake them
+		p.flushMu.Lock()
+		defer p.flushMu.Unlock()
+		return writeSink(ctx, p.sink, mods)
+	}
+	// mods are released to the pool of the transaction after Write returns
+	p.pending = append(p.pending, mods...)
+	for i := range mods {
+		p.size += recordSize(mods[i])
+	}
+	full := p.window.maxRecords > 0 && len(p.pending) >= p.window.maxRecords ||
+		p.window.maxBytes > 0 && p.size >= p.window.maxBytes
+	if !full && p.timer == nil && p.window.maxLatency > 0 {
+		p.timer = time.AfterFunc(p.window.maxLatency, func() {
+			_ = p.flush(context.Background())
+		})
+	}
+	p.mu.Unlock()
+
+	if full {
+		// the writer filling the window writes it, so that buffered records are bounded by the window
+		_ = p.flush(context.WithoutCancel(ctx))
+	}
+	return nil
+}
+
+// flush writes the buffered records to the sink with writeDetached.
+func (p *batchPartition) flush(ctx context.Context) error {
+	p.flushMu.Lock()
+	defer p.flushMu.Unlock()
+	return p.flushLocked(ctx)
+}
+
+// flushLocked is flush with flushMu held.
+func (p *batchPartition) flushLocked(ctx context.Context) error {
+	p.mu.Lock()
+	batch := p.pending
+	p.pending, p.size = nil, 0
+	if p.timer != nil {
+		p.timer.Stop()
+		p.timer = nil
+	}
+	p.mu.Unlock()
+
+	if len(batch) == 0 {
+		return nil
+	}
+	return p.builder.writeDetached(ctx, p.sink, batch)
+}
+
+// close writes the buffered records and stops batching.
+func (p *batchPartition) close(ctx context.Context) error {
+	// records written directly after close wait for flushMu, so that they do not overtake the buffered ones
+	p.flushMu.Lock()
+	defer p.flushMu.Unlock()
+	p.mu.Lock()
+	p.closed = true
+	p.mu.Unlock()
+	return p.flushLocked(ctx)
+}
+
+// recordSize approximates the encoded size of mod by the lengths of its values, for windows limited in bytes.
+func recordSize(mod DatabaseModification) int {
+	// the modification time, flags, and other fixed-size values
+	size := 64
+	size += len(mod.ID) + len(mod.OperatorID) + len(mod.ExecutionID) + len(mod.TableName) + len(mod.Action) + len(mod.SQL)
+	for _, ids := range [][]string{mod.RecordIDs, mod.SourceTables, mod.ChangedColumns} {
+		for _, id := range ids {
+			size += len(id)
+		}
+	}
+	return size
+}
+
+// Shutdown writes the audit records queued by WithAsyncLogging and buffered by WithBatchWindow to the sink, and stops
+// queueing and batching, so that records of statements executed afterwards are written to the sink directly.
+// It returns the error of the last batch, if any, or of ctx if it is done before the queued records are written.
+func (d *Driver) Shutdown(ctx context.Context) error {
+	if d.async != nil {
+		if err := d.async.close(ctx); err != nil {
+			return err
+		}
+	}
+	if d.batcher == nil {
+		return nil
+	}
+	return d.batcher.close(ctx)
+}
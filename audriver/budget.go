@@ -0,0 +1,111 @@
+package audriver
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+type auditBudgetKey struct{}
+
+// BudgetAction is what happens to modifications exceeding the budget of WithAuditBudget.
+type BudgetAction string
+
+const (
+	// BudgetActionWarn records modifications exceeding the budget as usual, and reports ErrAuditBudgetExceeded to the
+	// error handler once.
+	BudgetActionWarn BudgetAction = "warn"
+
+	// BudgetActionAggregate reports ErrAuditBudgetExceeded to the error handler once, and records only the first
+	// modification exceeding the budget of each table and action, as an ExactnessAggregated record standing for it and
+	// the following ones.
+	BudgetActionAggregate BudgetAction = "aggregate"
+
+	// BudgetActionBlock rejects statements exceeding the budget with ErrAuditBudgetExceeded before executing them.
+	BudgetActionBlock BudgetAction = "block"
+)
+
+// AuditBudgetMetadataKey is the key of the budget in the metadata of records aggregated by BudgetActionAggregate.
+const AuditBudgetMetadataKey = "audit_budget"
+
+// auditBudget counts the audited modifications of the statements executed with a context.
+type auditBudget struct {
+	max    int
+	action BudgetAction
+
+	mu         sync.Mutex
+	used       int
+	warned     bool
+	aggregated map[tableAction]bool
+}
+
+// WithAuditBudget returns a context allowing statements executed with it to audit at most max modifications,
+// e.g. set by a middleware along with the execution ID of a request, to catch runaway endpoints unexpectedly modifying
+// thousands of rows. Statements beyond the budget are handled by action.
+// The budget is shared by all statements executed with the returned context and contexts derived from it.
+func WithAuditBudget(ctx context.Context, max int, action BudgetAction) context.Context {
+	return context.WithValue(ctx, auditBudgetKey{}, &auditBudget{max: max, action: action})
+}
+
+// applyAuditBudget charges mods to the audit budget of ctx, if any, and returns the modifications to record.
+// It returns an error wrapping ErrAuditBudgetExceeded if the statement is blocked.
+func (b *databaseModificationBuilder) applyAuditBudget(ctx context.Context, mods []DatabaseModification) ([]DatabaseModification, error) {
+	budget, ok := ctx.Value(auditBudgetKey{}).(*auditBudget)
+	if !ok || len(mods) == 0 {
+		return mods, nil
+	}
+
+	kept, within, warn := budget.charge(mods)
+	if within == len(mods) {
+		return mods, nil
+	}
+	err := fmt.Errorf("%w: %s on %s exceeds the budget of %d modifications of execution %s", ErrAuditBudgetExceeded, mods[0].Action, mods[0].TableName, budget.max, mods[0].ExecutionID)
+	if budget.action == BudgetActionBlock {
+		b.handleError(ctx, err, ErrorStageBuild, mods)
+		return nil, err
+	}
+	// the error handler is invoked without holding the budget, so that it may execute statements with ctx
+	if warn {
+		b.handleError(ctx, err, ErrorStageBuild, mods[within:])
+	}
+	b.stats.skippedBudget.Add(int64(len(mods) - len(kept)))
+	return kept, nil
+}
+
+// charge charges mods to the budget and returns the modifications to record, the number of them within the budget,
+// and whether the budget is exceeded for the first time.
+func (budget *auditBudget) charge(mods []DatabaseModification) ([]DatabaseModification, int, bool) {
+	budget.mu.Lock()
+	defer budget.mu.Unlock()
+
+	within := min(max(budget.max-budget.used, 0), len(mods))
+	if within == len(mods) {
+		budget.used += len(mods)
+		return mods, within, false
+	}
+	if budget.action == BudgetActionBlock {
+		return nil, within, false
+	}
+	budget.used += len(mods)
+	warn := !budget.warned
+	budget.warned = true
+	if budget.action != BudgetActionAggregate {
+		return mods, within, warn
+	}
+
+	if budget.aggregated == nil {
+		budget.aggregated = map[tableAction]bool{}
+	}
+	kept := mods[:within]
+	for i := within; i < len(mods); i++ {
+		key := tableAction{schema: mods[i].SchemaName, table: mods[i].TableName, action: mods[i].Action}
+		if budget.aggregated[key] {
+			continue
+		}
+		budget.aggregated[key] = true
+		mods[i].Exactness = ExactnessAggregated
+		mods[i].SetMetadata(AuditBudgetMetadataKey, budget.max)
+		kept = append(kept, mods[i])
+	}
+	return kept, within, warn
+}
@@ -1,13 +1,37 @@
 package audriver
 
+import (
+	"sync"
+)
+
+// maxPooledModifications is the capacity up to which buffered modification slices are reused.
+const maxPooledModifications = 1024
+
+// modificationSlices reuses the slices transactions buffer modifications in.
+var modificationSlices = sync.Pool{
+	New: func() any {
+		ms := make([]DatabaseModification, 0, 16)
+		return &ms
+	},
+}
+
 type buffer struct {
 	ms []DatabaseModification
 }
 
-func (b *buffer) add(op DatabaseModification) {
-	b.ms = append(b.ms, op)
+func (b *buffer) add(ops ...DatabaseModification) {
+	if b.ms == nil {
+		b.ms = (*modificationSlices.Get().(*[]DatabaseModification))[:0]
+	}
+	b.ms = append(b.ms, ops...)
+}
+
+func (b *buffer) len() int {
+	return len(b.ms)
 }
 
+// drain returns the buffered modifications and empties the buffer.
+// The returned slice should be passed to releaseModifications once it is no longer used.
 func (b *buffer) drain() []DatabaseModification {
 	if len(b.ms) == 0 {
 		return nil
@@ -17,3 +41,13 @@ func (b *buffer) drain() []DatabaseModification {
 	b.ms = nil
 	return ms
 }
+
+// releaseModifications returns a slice obtained from buffer.drain to the pool.
+func releaseModifications(ms []DatabaseModification) {
+	if ms == nil || cap(ms) > maxPooledModifications {
+		return
+	}
+	clear(ms)
+	ms = ms[:0]
+	modificationSlices.Put(&ms)
+}
@@ -4,12 +4,13 @@ import (
 	"context"
 	"database/sql/driver"
 	"fmt"
+	"maps"
+	"math/rand/v2"
 	"regexp"
+	"slices"
 	"time"
 
 	"github.com/google/uuid"
-
-	"github.com/mickamy/go-sql-audit-driver/internal/postgres"
 )
 
 // IDGenerator generates unique IDs for database modifications.
@@ -54,6 +55,49 @@ type databaseModificationBuilder struct {
 	operatorIDExtractor  OperatorIDExtractor
 	executionIDExtractor ExecutionIDExtractor
 	tableFilters         TableFilters
+	actions              map[DatabaseModificationAction]bool
+	ignoreSQLPatterns    []*regexp.Regexp
+	splitMultiRowInserts bool
+	strictParsing        bool
+	logCorrelation       bool
+	queryAnnotation      bool
+	rewriters            []QueryRewriter
+	errorHandler         ErrorHandler
+	transactionHandler   TransactionHandler
+	slowAuditThreshold   time.Duration
+	slowAuditLogger      SlowAuditLogger
+	loadShedder          *loadShedder
+	operatorRates        *operatorRates
+	retry                *AuditRetry
+	contextSnapshotter   ContextSnapshotter
+	clientIPEnricher     ClientIPEnricher
+	shardKeyExtractors   map[string]ShardKeyExtractor
+	tableEnrichers       map[string][]TableEnricher
+	flushThreshold       int
+	sampleRates          map[string]float64
+	auditTable           string
+	stagingTable         string
+	sessionStaging       bool
+	backendIDs           bool
+	commitLSN            bool
+	rowEstimateThreshold int64
+	wherePredicates      bool
+	changedColumns       bool
+	sourceTables         bool
+	schemaNames          bool
+	argCapture           bool
+	anonymizer           Anonymizer
+	checksums            bool
+	dialect              Dialect
+	sink                 AuditSink
+	batchWindow          *batchWindow
+	deadLetterQueue      DeadLetterQueue
+	asyncLogging         *asyncLogging
+	backpressure         BackpressurePolicy
+	rowCapture           bool
+
+	operators *operatorCache
+	stats     *auditStats
 }
 
 func (b *databaseModificationBuilder) fillDefaults() {
@@ -75,17 +119,94 @@ func (b *databaseModificationBuilder) fillDefaults() {
 	if b.tableFilters == nil {
 		b.tableFilters = []TableFilter{}
 	}
+	if b.auditTable == "" {
+		b.auditTable = DefaultAuditTable
+	}
+	if b.dialect == "" {
+		b.dialect = PostgreSQL
+	}
+	if b.stats == nil {
+		b.stats = &auditStats{}
+	}
+	if b.backpressure == "" {
+		b.backpressure = BackpressureBlock
+	}
+}
+
+// writeTable returns the table audit records are written to: the staging table if staging is enabled,
+// or the audit table.
+func (b *databaseModificationBuilder) writeTable() string {
+	if b.stagingTable != "" {
+		return b.stagingTable
+	}
+	return b.auditTable
+}
+
+// clone returns a copy of the builder that shares no slices or maps with it,
+// so that it is not affected by later changes to the values passed to options.
+func (b *databaseModificationBuilder) clone() *databaseModificationBuilder {
+	c := *b
+	c.tableFilters = slices.Clone(b.tableFilters)
+	c.actions = maps.Clone(b.actions)
+	c.ignoreSQLPatterns = slices.Clone(b.ignoreSQLPatterns)
+	c.rewriters = slices.Clone(b.rewriters)
+	c.sampleRates = maps.Clone(b.sampleRates)
+	c.shardKeyExtractors = maps.Clone(b.shardKeyExtractors)
+	if b.tableEnrichers != nil {
+		c.tableEnrichers = make(map[string][]TableEnricher, len(b.tableEnrichers))
+		for table, enrichers := range b.tableEnrichers {
+			c.tableEnrichers[table] = slices.Clone(enrichers)
+		}
+	}
+	return &c
 }
 
-// build creates a DatabaseModification from the provided SQL statement and arguments.
-func (b *databaseModificationBuilder) build(ctx context.Context, sql string, args []driver.NamedValue) (*DatabaseModification, error) {
-	if !isDML(sql) {
+// build creates DatabaseModifications from the provided SQL statement and arguments.
+// It returns a single modification per statement, unless multi-row inserts are split into one modification per row.
+// Panics of user-supplied extractors and filters are returned as errors wrapping ErrPanicRecovered.
+func (b *databaseModificationBuilder) build(ctx context.Context, sql string, args []driver.NamedValue) (mods []DatabaseModification, err error) {
+	defer recoverPanic(&err)
+	defer func(start time.Time) {
+		if err == nil {
+			b.detectSlowAudit(ctx, ErrorStageBuild, mods, start)
+		}
+	}(time.Now())
+
+	// statements are parsed with PostgreSQL lexical rules, so literals and comments of other dialects are blanked out
+	parsed := b.dialect.normalize(sql)
+	if !isDML(parsed) {
+		b.stats.skippedNonDML.Add(1)
+		return nil, nil
+	}
+	if b.isIgnored(sql) {
+		b.stats.skippedFiltered.Add(1)
 		return nil, nil
 	}
 
-	ta, err := parseTableAction(sql)
+	ta, err := parseTableAction(parsed)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse action and table from SQL: %w", err)
+		if b.strictParsing {
+			return nil, fmt.Errorf("%w: %w", ErrUnclassifiedStatement, err)
+		}
+		ta = tableAction{action: DatabaseModificationActionUnknown}
+	}
+
+	if !b.shouldLog(ctx, ta) {
+		b.stats.skippedFiltered.Add(1)
+		return nil, nil
+	}
+
+	exactness := ExactnessExact
+	rate, ok := b.sampleRates[ta.table]
+	if shedRate, shed := b.loadShedder.sampleRate(ta.table); shed && (!ok || shedRate < rate) {
+		rate, ok = shedRate, true
+	}
+	if ok {
+		if rand.Float64() >= rate {
+			b.stats.skippedSampled.Add(1)
+			return nil, nil
+		}
+		exactness = ExactnessSampled
 	}
 
 	operatorID, err := b.operatorIDExtractor.ExtractOperatorID(ctx)
@@ -98,27 +219,196 @@ func (b *databaseModificationBuilder) build(ctx context.Context, sql string, arg
 		return nil, fmt.Errorf("failed to extract execution ID: %w", err)
 	}
 
-	fullSQL := postgres.InterpolateSQL(sql, args)
+	fullSQLs := b.formatSQL(sql, args, ta.action)
+	var schema string
+	if b.schemaNames {
+		schema = ta.schema
+	}
+	var sourceTables, changedColumns []string
+	if b.sourceTables {
+		sourceTables = parseSourceTables(parsed, ta)
+	}
+	if b.changedColumns {
+		changedColumns = parseChangedColumns(parsed, ta)
+	}
+
+	var (
+		operator    Operator
+		operatorErr error
+	)
+	if b.operators != nil {
+		operator, operatorErr = b.operators.resolve(ctx, operatorID)
+	}
+	snapshot, snapshotErr := b.snapshotContext(ctx)
+
+	idempotencyKey, _ := GetIdempotencyKey(ctx)
+	parentExecutionID, _ := GetParentExecutionID(ctx)
+	step, _ := GetStep(ctx)
+
+	var clientIP string
+	client, _ := GetClientInfo(ctx)
+	if client.IP.IsValid() {
+		clientIP = client.IP.String()
+	}
+
+	modifiedAt := time.Now()
+	mods = make([]DatabaseModification, len(fullSQLs))
+	for i, fullSQL := range fullSQLs {
+		mods[i] = DatabaseModification{
+			ID:                b.idGenerator.GenerateID(),
+			OperatorID:        operatorID,
+			OperatorName:      operator.Name,
+			OperatorEmail:     operator.Email,
+			ExecutionID:       executionID,
+			SchemaName:        schema,
+			TableName:         ta.table,
+			Action:            ta.action,
+			SQL:               fullSQL,
+			ModifiedAt:        modifiedAt,
+			SourceTables:      sourceTables,
+			ChangedColumns:    changedColumns,
+			Exactness:         exactness,
+			ClientIP:          clientIP,
+			UserAgent:         client.UserAgent,
+			Device:            client.Device,
+			IdempotencyKey:    idempotencyKey,
+			ParentExecutionID: parentExecutionID,
+			Step:              step,
+		}
+		if snapshot != nil {
+			mods[i].Metadata = map[string]any{ContextSnapshotKey: snapshot}
+		}
+	}
+	if idempotencyKey != "" {
+		idempotentIDs{}.assign(mods)
+	}
+	b.describeArgs(mods, args)
+
+	if b.clientIPEnricher != nil && clientIP != "" {
+		if err := EnrichClientIP(ctx, &mods[0], b.clientIPEnricher); err != nil {
+			b.handleError(ctx, err, ErrorStageBuild, mods)
+		} else if annotations, ok := mods[0].Metadata[ClientIPMetadataKey]; ok {
+			// rows of a split insert share the client, so it is looked up once
+			for i := 1; i < len(mods); i++ {
+				mods[i].SetMetadata(ClientIPMetadataKey, annotations)
+			}
+		}
+	}
+
+	if err := b.enrichTable(ctx, mods, args); err != nil {
+		b.handleError(ctx, err, ErrorStageBuild, mods)
+	}
+
+	if err := b.extractShardKey(ctx, mods); err != nil {
+		b.handleError(ctx, err, ErrorStageBuild, mods)
+	}
+
+	// the modification is still audited, only less readable
+	if operatorErr != nil {
+		b.handleError(ctx, operatorErr, ErrorStageBuild, mods)
+	}
+	if snapshotErr != nil {
+		b.handleError(ctx, snapshotErr, ErrorStageBuild, mods)
+	}
+
+	return mods, nil
+}
 
-	return &DatabaseModification{
-		ID:          b.idGenerator.GenerateID(),
-		OperatorID:  operatorID,
-		ExecutionID: executionID,
-		TableName:   ta.table,
-		Action:      ta.action,
-		SQL:         fullSQL,
-		ModifiedAt:  time.Now(),
-	}, nil
+// formatSQL interpolates args into sql, split into one statement per row for multi-row inserts if enabled.
+// Literals and arguments are replaced with fakes first if an anonymizer is set.
+func (b *databaseModificationBuilder) formatSQL(sql string, args []driver.NamedValue, action DatabaseModificationAction) []string {
+	if b.anonymizer != nil {
+		sql, args = b.anonymizeLiterals(sql), b.anonymizeArgs(args)
+	}
+	fullSQLs := []string{b.dialect.interpolate(sql, args)}
+	if b.splitMultiRowInserts && action == DatabaseModificationActionInsert {
+		if rows := splitInsertRows(fullSQLs[0]); rows != nil {
+			fullSQLs = rows
+		}
+	}
+	return fullSQLs
 }
 
-func (b *databaseModificationBuilder) isFiltered(tableName string) bool {
-	return b.tableFilters.ShouldLog(tableName)
+// describeArgs records the metadata derived from the arguments of mods, if enabled.
+func (b *databaseModificationBuilder) describeArgs(mods []DatabaseModification, args []driver.NamedValue) {
+	if b.argCapture && len(args) > 0 {
+		if b.anonymizer != nil {
+			args = b.anonymizeArgs(args)
+		}
+		captured := captureArgs(args)
+		for i := range mods {
+			mods[i].SetMetadata(ArgsMetadataKey, captured)
+		}
+	}
+	if b.wherePredicates {
+		predicates := parseWherePredicates(mods[0].SQL)
+		for i := range mods {
+			if len(predicates) > 0 {
+				mods[i].SetMetadata(WherePredicatesMetadataKey, predicates)
+			} else {
+				delete(mods[i].Metadata, WherePredicatesMetadataKey)
+			}
+		}
+	}
+}
+
+// reformat updates mods built from sql with args as converted by the statement they were executed with,
+// so that audit records show the values the database received.
+func (b *databaseModificationBuilder) reformat(mods []DatabaseModification, sql string, args []driver.NamedValue) {
+	if len(mods) == 0 {
+		return
+	}
+	fullSQLs := b.formatSQL(sql, args, mods[0].Action)
+	if len(fullSQLs) != len(mods) {
+		return
+	}
+	for i := range mods {
+		mods[i].SQL = fullSQLs[i]
+	}
+	b.describeArgs(mods, args)
+	if mods[0].IdempotencyKey != "" {
+		idempotentIDs{}.assign(mods)
+	}
+}
+
+// isIgnored reports whether the raw SQL statement matches any of the ignore patterns.
+func (b *databaseModificationBuilder) isIgnored(sql string) bool {
+	for _, pattern := range b.ignoreSQLPatterns {
+		if pattern.MatchString(sql) {
+			return true
+		}
+	}
+	return false
+}
+
+// shouldLog reports whether the parsed action on the table should be audited.
+// Table filters may be overridden or extended through ctx.
+func (b *databaseModificationBuilder) shouldLog(ctx context.Context, ta tableAction) bool {
+	if b.actions != nil && !b.actions[ta.action] {
+		return false
+	}
+	if ta.action == DatabaseModificationActionUnknown {
+		// the table is unknown, so table filters cannot exclude the statement
+		return true
+	}
+	return getTableFilters(ctx, b.tableFilters).ShouldLog(ta.table)
 }
 
 var (
-	dmlRegexp = regexp.MustCompile(`(?i)^\s*(INSERT|UPDATE|DELETE)\b`)
+	dmlRegexp  = regexp.MustCompile(`(?i)^\s*(INSERT|UPDATE|DELETE)\b`)
+	withRegexp = regexp.MustCompile(`(?i)^\s*WITH\b`)
 )
 
+// isDML reports whether sql modifies data: if it is an INSERT, UPDATE, or DELETE statement,
+// or a WITH query whose main statement or common table expressions are.
 func isDML(sql string) bool {
-	return dmlRegexp.MatchString(sql)
+	sql = sql[skipLeadingComments(sql):]
+	if dmlRegexp.MatchString(sql) {
+		return true
+	}
+	if !withRegexp.MatchString(sql) {
+		return false
+	}
+	_, err := parseTableAction(sql)
+	return err == nil
 }
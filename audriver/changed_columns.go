@@ -0,0 +1,94 @@
+package audriver
+
+import (
+	"strings"
+)
+
+// parseChangedColumns returns the columns assigned by the SET clause of an UPDATE statement, in order of appearance
+// and without duplicates, e.g. "email" and "name" for UPDATE users SET email = $1, name = $2 WHERE id = $3.
+// Assignments to fields or elements of a column, e.g. SET address.city = $1 or SET tags[1] = $1, change the column.
+func parseChangedColumns(sql string, ta tableAction) []string {
+	if ta.action != DatabaseModificationActionUpdate {
+		return nil
+	}
+	masked := maskLiterals(sql)
+	set := indexTopLevelKeyword(masked[ta.end:], "SET")
+	if set < 0 {
+		return nil
+	}
+
+	var (
+		columns []string
+		seen    = map[string]bool{}
+	)
+	add := func(name string) {
+		// fields of composite columns are qualified with the column
+		if i := strings.IndexByte(name, '.'); i >= 0 {
+			name = name[:i]
+		}
+		if name != "" && !seen[name] {
+			seen[name] = true
+			columns = append(columns, name)
+		}
+	}
+
+	var (
+		target   = true
+		depth    int
+		lastWord string
+	)
+	for i := ta.end + set + len("SET"); i < len(masked); i++ {
+		c := masked[i]
+		if target && !isSpace(c) {
+			target = false
+			if c == '(' {
+				// SET (a, b) = (...)
+				closing := indexClosingParen(masked, i)
+				if closing < 0 {
+					break
+				}
+				for _, name := range splitTopLevel(masked[i+1 : closing]) {
+					column, _ := readIdentifier(name, skipSpaces(name, 0))
+					add(column)
+				}
+				i = closing
+				continue
+			}
+			column, end := readIdentifier(masked, i)
+			add(column)
+			if end > i {
+				i = end - 1
+			}
+			continue
+		}
+
+		switch {
+		case c == '"' || c == '`':
+			i = skipQuoted(masked, i)
+		case c == '(':
+			depth++
+		case c == ')':
+			if depth == 0 {
+				// the end of a data-modifying common table expression
+				return columns
+			}
+			depth--
+		case depth > 0:
+			// within expressions of values
+		case c == ',':
+			target = true
+		case c == ';':
+			return columns
+		case isWordChar(c) && isWordBoundary(masked, i-1):
+			word, end := readWord(masked, i)
+			word = strings.ToUpper(word)
+			switch {
+			case word == "WHERE", word == "RETURNING", word == "FROM" && lastWord != "DISTINCT":
+				return columns
+			}
+			lastWord = word
+			i = end - 1
+		}
+	}
+	return columns
+}
@@ -0,0 +1,94 @@
+package audriver
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// Checksum returns the hex-encoded SHA-256 checksum of the content of mod, as recorded in the checksum column with
+// WithRecordChecksums. It depends on nothing but mod, so that the copies of a record fanned out to several sinks share
+// it, e.g. to deduplicate them in ETL, and any copy can be verified with VerifyChecksum.
+//
+// The checksum is computed over the JSON encoding of mod without its checksum, with the UUIDs in lowercase,
+// ModifiedAt in UTC at the microsecond precision of PostgreSQL, the metadata and captured rows as decoded from JSON,
+// and an ExactnessExact exactness left out, so that a record read back from the audit table has the checksum it was
+// written with. Fields without values are left out of the encoding, so that fields added in later versions do not
+// change the checksums of records without them.
+func Checksum(mod DatabaseModification) string {
+	mod.Checksum = ""
+	mod.ID = strings.ToLower(mod.ID)
+	mod.OperatorID = strings.ToLower(mod.OperatorID)
+	mod.ExecutionID = strings.ToLower(mod.ExecutionID)
+	mod.ParentExecutionID = strings.ToLower(mod.ParentExecutionID)
+	mod.ModifiedAt = mod.ModifiedAt.UTC().Truncate(time.Microsecond)
+	if mod.Exactness == ExactnessExact {
+		mod.Exactness = ""
+	}
+	mod.Metadata = canonicalMetadata(mod.Metadata)
+	mod.OldValues = canonicalJSON(mod.OldValues)
+	mod.NewValues = canonicalJSON(mod.NewValues)
+
+	data, _ := json.Marshal(mod)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// canonicalMetadata returns metadata as read back from the audit table: values such as structs decoded into maps,
+// whose keys are encoded in order, and numbers as encoded. Metadata that cannot be encoded is not written, and nil.
+func canonicalMetadata(metadata map[string]any) map[string]any {
+	if len(metadata) == 0 {
+		return nil
+	}
+	data, err := json.Marshal(metadata)
+	if err != nil {
+		return nil
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var canonical map[string]any
+	if err := decoder.Decode(&canonical); err != nil {
+		return nil
+	}
+	return canonical
+}
+
+// canonicalJSON returns data as encoded after being read back from a JSONB column, which normalizes whitespace and
+// the order of keys: decoded and encoded again, with keys in order and numbers as encoded.
+func canonicalJSON(data json.RawMessage) json.RawMessage {
+	if data == nil {
+		return nil
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var v any
+	if err := decoder.Decode(&v); err != nil {
+		return data
+	}
+	canonical, err := json.Marshal(v)
+	if err != nil {
+		return data
+	}
+	return canonical
+}
+
+// VerifyChecksum reports whether mod has a checksum matching its content, i.e. it was not modified after the checksum
+// was computed.
+func VerifyChecksum(mod DatabaseModification) bool {
+	return mod.Checksum != "" && mod.Checksum == Checksum(mod)
+}
+
+// setChecksums sets the checksums of mods that have none, if enabled.
+func (b *databaseModificationBuilder) setChecksums(mods []DatabaseModification) {
+	if !b.checksums {
+		return
+	}
+	for i := range mods {
+		if mods[i].Checksum == "" {
+			mods[i].Checksum = Checksum(mods[i])
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package codec
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"time"
+
+	"github.com/mickamy/go-sql-audit-driver/audriver"
+)
+
+// Avro encodes records in the Avro binary encoding of AvroSchema, without the schema, as Avro messages of Kafka do.
+// Writer writes Avro object container files holding the schema.
+var Avro Codec = avroCodec{}
+
+// AvroSchema is the schema of the records Avro encodes. Fields without values are null, except for required ones.
+var AvroSchema = avroSchema()
+
+// avroField is a field of an Avro record schema.
+type avroField struct {
+	Name    string `json:"name"`
+	Type    any    `json:"type"`
+	Default any    `json:"default"`
+}
+
+func avroSchema() string {
+	schemaFields := make([]avroField, len(fields))
+	for i, f := range fields {
+		var typ any
+		switch f.kind {
+		case kindString, kindMetadata:
+			typ = "string"
+		case kindBool:
+			typ = "boolean"
+		case kindInt:
+			typ = "long"
+		case kindStrings:
+			typ = map[string]any{"type": "array", "items": "string"}
+		case kindTime:
+			typ = map[string]any{"type": "long", "logicalType": "timestamp-micros"}
+		}
+		if f.required {
+			schemaFields[i] = avroField{Name: f.name, Type: typ}
+			continue
+		}
+		schemaFields[i] = avroField{Name: f.name, Type: []any{"null", typ}}
+	}
+
+	schema, _ := json.Marshal(struct {
+		Type      string      `json:"type"`
+		Name      string      `json:"name"`
+		Namespace string      `json:"namespace"`
+		Fields    []avroField `json:"fields"`
+	}{Type: "record", Name: "DatabaseModification", Namespace: "audriver", Fields: schemaFields})
+	return string(schema)
+}
+
+// MarshalJSON leaves the default out of required fields, which have none.
+func (f avroField) MarshalJSON() ([]byte, error) {
+	if _, nullable := f.Type.([]any); !nullable {
+		return json.Marshal(struct {
+			Name string `json:"name"`
+			Type any    `json:"type"`
+		}{f.Name, f.Type})
+	}
+	type plain avroField
+	return json.Marshal(plain(f))
+}
+
+type avroCodec struct{}
+
+func (avroCodec) Name() string {
+	return "avro"
+}
+
+func (avroCodec) ContentType() string {
+	return "avro/binary"
+}
+
+func (avroCodec) Encode(mod audriver.DatabaseModification) ([]byte, error) {
+	buf := make([]byte, 0, 256+len(mod.SQL))
+	for _, f := range fields {
+		v := f.value(mod)
+		if !f.required {
+			// the index of the branch of the union with null
+			if empty(v) {
+				buf = binary.AppendVarint(buf, 0)
+				continue
+			}
+			buf = binary.AppendVarint(buf, 1)
+		}
+		switch v := v.(type) {
+		case string:
+			buf = appendAvroString(buf, v)
+		case bool:
+			buf = append(buf, 1)
+		case int64:
+			buf = binary.AppendVarint(buf, v)
+		case []string:
+			buf = binary.AppendVarint(buf, int64(len(v)))
+			for _, s := range v {
+				buf = appendAvroString(buf, s)
+			}
+			buf = binary.AppendVarint(buf, 0)
+		case time.Time:
+			buf = binary.AppendVarint(buf, v.UnixMicro())
+		case map[string]any:
+			metadata, err := metadataJSON(v)
+			if err != nil {
+				return nil, err
+			}
+			buf = appendAvroString(buf, metadata)
+		}
+	}
+	return buf, nil
+}
+
+// appendAvroString appends s as an Avro string or bytes value.
+func appendAvroString(buf []byte, s string) []byte {
+	buf = binary.AppendVarint(buf, int64(len(s)))
+	return append(buf, s...)
+}
@@ -0,0 +1,170 @@
+// Package codec serializes audit records for sinks, so that the same records can feed systems with different format
+// requirements: JSON, MessagePack, Protocol Buffers, or Avro.
+//
+// A codec is selected per sink, e.g. for the live stream:
+//
+//	server := stream.New(authorizer, stream.WithCodec(codec.Protobuf))
+//
+// or for files and other byte streams, framed as the format expects with a Writer:
+//
+//	w := codec.NewWriter(f, codec.Avro)
+//	for _, mod := range mods {
+//		if err := w.Write(mod); err != nil {
+//			return err
+//		}
+//	}
+//	return w.Flush()
+//
+// The binary codecs encode the fields of audriver.DatabaseModification in its order, with the metadata as a JSON
+// document in the schema-based formats; see ProtobufSchema and AvroSchema. A Registry registers these schemas with a
+// Confluent Schema Registry for consumers of Kafka topics:
+//
+//	registered, err := codec.NewRegistry("http://localhost:8081").Register(ctx, codec.Avro, "audit")
+package codec
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/mickamy/go-sql-audit-driver/audriver"
+)
+
+// Codec encodes audit records.
+type Codec interface {
+	// Name identifies the codec, e.g. "json".
+	Name() string
+
+	// ContentType is the media type of an encoded record, e.g. "application/json".
+	ContentType() string
+
+	// Encode returns the encoding of mod.
+	Encode(mod audriver.DatabaseModification) ([]byte, error)
+}
+
+// Codecs are the built-in codecs, by name.
+var Codecs = map[string]Codec{
+	JSON.Name():     JSON,
+	MsgPack.Name():  MsgPack,
+	Protobuf.Name(): Protobuf,
+	Avro.Name():     Avro,
+}
+
+// ByName returns the built-in codec named name, e.g. from a flag.
+func ByName(name string) (Codec, error) {
+	codec, ok := Codecs[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown codec: %s", name)
+	}
+	return codec, nil
+}
+
+// JSON encodes records as JSON documents, as encoding/json encodes audriver.DatabaseModification.
+var JSON Codec = jsonCodec{}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Name() string {
+	return "json"
+}
+
+func (jsonCodec) ContentType() string {
+	return "application/json"
+}
+
+func (jsonCodec) Encode(mod audriver.DatabaseModification) ([]byte, error) {
+	data, err := json.Marshal(mod)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode record: %w", err)
+	}
+	return data, nil
+}
+
+// kind is the type of a field of a record.
+type kind int
+
+const (
+	kindString kind = iota
+	kindBool
+	kindInt
+	kindStrings
+	kindTime
+	kindMetadata
+)
+
+// field is a field of a record as encoded by the binary codecs.
+type field struct {
+	// name is the name of the field in the JSON encoding and the schemas.
+	name string
+
+	kind kind
+
+	// required fields are encoded even without a value, and are not nullable in AvroSchema.
+	required bool
+
+	// value returns the value of the field of mod, or the zero value of its kind if mod has none.
+	value func(mod audriver.DatabaseModification) any
+}
+
+// fields are the fields of records, numbered from 1 in ProtobufSchema.
+var fields = []field{
+	{name: "id", kind: kindString, required: true, value: func(mod audriver.DatabaseModification) any { return mod.ID }},
+	{name: "operator_id", kind: kindString, required: true, value: func(mod audriver.DatabaseModification) any { return mod.OperatorID }},
+	{name: "operator_name", kind: kindString, value: func(mod audriver.DatabaseModification) any { return mod.OperatorName }},
+	{name: "operator_email", kind: kindString, value: func(mod audriver.DatabaseModification) any { return mod.OperatorEmail }},
+	{name: "execution_id", kind: kindString, required: true, value: func(mod audriver.DatabaseModification) any { return mod.ExecutionID }},
+	{name: "schema_name", kind: kindString, value: func(mod audriver.DatabaseModification) any { return mod.SchemaName }},
+	{name: "table_name", kind: kindString, required: true, value: func(mod audriver.DatabaseModification) any { return mod.TableName }},
+	{name: "foreign_table", kind: kindBool, value: func(mod audriver.DatabaseModification) any { return mod.Foreign }},
+	{name: "action", kind: kindString, required: true, value: func(mod audriver.DatabaseModification) any { return mod.Action.String() }},
+	{name: "sql", kind: kindString, required: true, value: func(mod audriver.DatabaseModification) any { return mod.SQL }},
+	{name: "modified_at", kind: kindTime, required: true, value: func(mod audriver.DatabaseModification) any { return mod.ModifiedAt }},
+	{name: "record_ids", kind: kindStrings, value: func(mod audriver.DatabaseModification) any { return mod.RecordIDs }},
+	{name: "source_tables", kind: kindStrings, value: func(mod audriver.DatabaseModification) any { return mod.SourceTables }},
+	{name: "changed_columns", kind: kindStrings, value: func(mod audriver.DatabaseModification) any { return mod.ChangedColumns }},
+	{name: "exactness", kind: kindString, value: func(mod audriver.DatabaseModification) any { return string(mod.Exactness) }},
+	{name: "shard", kind: kindString, value: func(mod audriver.DatabaseModification) any { return mod.Shard }},
+	{name: "shard_key", kind: kindString, value: func(mod audriver.DatabaseModification) any { return mod.ShardKey }},
+	{name: "backend_pid", kind: kindInt, value: func(mod audriver.DatabaseModification) any { return mod.BackendPID }},
+	{name: "transaction_id", kind: kindInt, value: func(mod audriver.DatabaseModification) any { return mod.TransactionID }},
+	{name: "estimated_rows", kind: kindInt, value: func(mod audriver.DatabaseModification) any { return mod.EstimatedRows }},
+	{name: "idempotency_key", kind: kindString, value: func(mod audriver.DatabaseModification) any { return mod.IdempotencyKey }},
+	{name: "parent_execution_id", kind: kindString, value: func(mod audriver.DatabaseModification) any { return mod.ParentExecutionID }},
+	{name: "step", kind: kindString, value: func(mod audriver.DatabaseModification) any { return mod.Step }},
+	{name: "client_ip", kind: kindString, value: func(mod audriver.DatabaseModification) any { return mod.ClientIP }},
+	{name: "user_agent", kind: kindString, value: func(mod audriver.DatabaseModification) any { return mod.UserAgent }},
+	{name: "device", kind: kindString, value: func(mod audriver.DatabaseModification) any { return mod.Device }},
+	{name: "checksum", kind: kindString, value: func(mod audriver.DatabaseModification) any { return mod.Checksum }},
+	{name: "metadata", kind: kindMetadata, value: func(mod audriver.DatabaseModification) any { return mod.Metadata }},
+	// fields added later are appended, so that the numbers of protobuf fields are kept
+	{name: "old_values", kind: kindString, value: func(mod audriver.DatabaseModification) any { return string(mod.OldValues) }},
+	{name: "new_values", kind: kindString, value: func(mod audriver.DatabaseModification) any { return string(mod.NewValues) }},
+}
+
+// empty reports whether v is the zero value of its kind, i.e. the record has no value for the field.
+func empty(v any) bool {
+	switch v := v.(type) {
+	case string:
+		return v == ""
+	case bool:
+		return !v
+	case int64:
+		return v == 0
+	case []string:
+		return len(v) == 0
+	case time.Time:
+		return v.IsZero()
+	case map[string]any:
+		return len(v) == 0
+	}
+	return v == nil
+}
+
+// metadataJSON returns the JSON document of metadata, as written to the metadata column.
+func metadataJSON(metadata map[string]any) (string, error) {
+	data, err := json.Marshal(metadata)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode metadata: %w", err)
+	}
+	return string(data), nil
+}
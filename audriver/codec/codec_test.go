@@ -0,0 +1,260 @@
+package codec_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mickamy/go-sql-audit-driver/audriver"
+	"github.com/mickamy/go-sql-audit-driver/audriver/codec"
+	"github.com/mickamy/go-sql-audit-driver/audriver/compress"
+)
+
+// mod is a record with a few fields of each kind.
+var mod = audriver.DatabaseModification{
+	ID:         "a",
+	TableName:  "t",
+	Action:     audriver.DatabaseModificationActionInsert,
+	ModifiedAt: time.UnixMicro(1),
+	RecordIDs:  []string{"1", "2"},
+	BackendPID: 300,
+}
+
+func TestByName(t *testing.T) {
+	t.Parallel()
+
+	for _, name := range []string{"json", "msgpack", "protobuf", "avro"} {
+		c, err := codec.ByName(name)
+		require.NoError(t, err)
+		assert.Equal(t, name, c.Name())
+		assert.NotEmpty(t, c.ContentType())
+	}
+	_, err := codec.ByName("xml")
+	assert.Error(t, err)
+}
+
+func TestJSON(t *testing.T) {
+	t.Parallel()
+
+	// act
+	got, err := codec.JSON.Encode(mod)
+
+	// assert
+	require.NoError(t, err)
+	expected, err := json.Marshal(mod)
+	require.NoError(t, err)
+	assert.Equal(t, expected, got)
+}
+
+func TestProtobuf(t *testing.T) {
+	t.Parallel()
+
+	// act
+	got, err := codec.Protobuf.Encode(mod)
+
+	// assert
+	require.NoError(t, err)
+	assert.Equal(t, []byte{
+		0x0a, 1, 'a', // 1: id
+		0x3a, 1, 't', // 7: table_name
+		0x4a, 6, 'i', 'n', 's', 'e', 'r', 't', // 9: action
+		0x58, 1, // 11: modified_at
+		0x62, 1, '1', 0x62, 1, '2', // 12: record_ids
+		0x90, 0x01, 0xac, 0x02, // 18: backend_pid
+	}, got)
+	assert.Contains(t, codec.ProtobufSchema, "  int64 backend_pid = 18;\n")
+}
+
+func TestAvro(t *testing.T) {
+	t.Parallel()
+
+	// act
+	got, err := codec.Avro.Encode(mod)
+
+	// assert
+	require.NoError(t, err)
+	assert.Equal(t, []byte{
+		2, 'a', // id
+		0,    // operator_id
+		0, 0, // operator_name, operator_email: null
+		0,      // execution_id
+		0,      // schema_name: null
+		2, 't', // table_name
+		0,                                // foreign_table: null
+		12, 'i', 'n', 's', 'e', 'r', 't', // action
+		0,                       // sql
+		2,                       // modified_at
+		2, 4, 2, '1', 2, '2', 0, // record_ids
+		0, 0, 0, 0, 0, // source_tables, changed_columns, exactness, shard, shard_key: null
+		2, 0xd8, 0x04, // backend_pid
+		0, 0, 0, 0, 0, 0, 0, 0, 0, 0, // transaction_id to metadata: null
+		0, 0, // old_values, new_values: null
+	}, got)
+
+	var schema map[string]any
+	require.NoError(t, json.Unmarshal([]byte(codec.AvroSchema), &schema), "the schema should be JSON")
+	assert.Equal(t, "DatabaseModification", schema["name"])
+}
+
+func TestMsgPack(t *testing.T) {
+	t.Parallel()
+
+	// arrange
+	str := func(s string) []byte {
+		return append([]byte{0xa0 | byte(len(s))}, s...)
+	}
+	mod := audriver.DatabaseModification{
+		ID:         "a",
+		Action:     audriver.DatabaseModificationActionInsert,
+		ModifiedAt: time.Unix(1, 2),
+		Metadata:   map[string]any{"n": 1, "s": struct{ A string }{A: "x"}},
+	}
+
+	// act
+	got, err := codec.MsgPack.Encode(mod)
+
+	// assert
+	require.NoError(t, err)
+	expected := bytes.Join([][]byte{
+		{0x88},
+		str("id"), str("a"),
+		str("operator_id"), str(""),
+		str("execution_id"), str(""),
+		str("table_name"), str(""),
+		str("action"), str("insert"),
+		str("sql"), str(""),
+		str("modified_at"), {0xc7, 12, 0xff, 0, 0, 0, 2, 0, 0, 0, 0, 0, 0, 0, 1},
+		str("metadata"), {0x82}, str("n"), {0x01}, str("s"), {0x81}, str("A"), str("x"),
+	}, nil)
+	assert.Equal(t, expected, got)
+}
+
+func TestWriter(t *testing.T) {
+	t.Parallel()
+
+	t.Run("json", func(t *testing.T) {
+		t.Parallel()
+
+		// arrange
+		var out bytes.Buffer
+		w := codec.NewWriter(&out, codec.JSON)
+
+		// act
+		require.NoError(t, w.Write(mod))
+		require.NoError(t, w.Write(mod))
+		require.NoError(t, w.Flush())
+
+		// assert
+		record, err := codec.JSON.Encode(mod)
+		require.NoError(t, err)
+		assert.Equal(t, string(record)+"\n"+string(record)+"\n", out.String())
+	})
+
+	t.Run("protobuf", func(t *testing.T) {
+		t.Parallel()
+
+		// arrange
+		var out bytes.Buffer
+		w := codec.NewWriter(&out, codec.Protobuf)
+
+		// act
+		require.NoError(t, w.Write(mod))
+		require.NoError(t, w.Flush())
+
+		// assert
+		record, err := codec.Protobuf.Encode(mod)
+		require.NoError(t, err)
+		assert.Equal(t, append([]byte{byte(len(record))}, record...), out.Bytes(), "messages should be length-delimited")
+	})
+
+	t.Run("avro", func(t *testing.T) {
+		t.Parallel()
+
+		// arrange
+		var out bytes.Buffer
+		w := codec.NewWriter(&out, codec.Avro)
+
+		// act
+		require.NoError(t, w.Write(mod))
+		require.NoError(t, w.Write(mod))
+		require.NoError(t, w.Flush())
+
+		// assert
+		data := out.Bytes()
+		require.True(t, bytes.HasPrefix(data, []byte("Obj\x01")))
+		assert.Contains(t, string(data), codec.AvroSchema)
+		sync := data[len(data)-16:]
+		assert.Equal(t, 2, bytes.Count(data, sync), "the header and the block should end with the sync marker")
+
+		record, err := codec.Avro.Encode(mod)
+		require.NoError(t, err)
+		block := binary.AppendVarint(binary.AppendVarint(nil, 2), int64(len(record)*2))
+		block = append(append(block, record...), record...)
+		assert.Equal(t, block, data[len(data)-16-len(block):len(data)-16])
+	})
+
+	t.Run("avro without records", func(t *testing.T) {
+		t.Parallel()
+
+		// arrange
+		var out bytes.Buffer
+		w := codec.NewWriter(&out, codec.Avro)
+
+		// act
+		require.NoError(t, w.Flush())
+
+		// assert
+		assert.True(t, bytes.HasPrefix(out.Bytes(), []byte("Obj\x01")), "empty exports should be valid files")
+	})
+}
+
+func TestSink(t *testing.T) {
+	t.Parallel()
+
+	// arrange
+	var out bytes.Buffer
+	sink := codec.NewSink(&out, codec.JSON)
+
+	// act
+	err := sink.Write(t.Context(), []audriver.DatabaseModification{mod, mod})
+
+	// assert
+	require.NoError(t, err)
+	record, err := codec.JSON.Encode(mod)
+	require.NoError(t, err)
+	assert.Equal(t, string(record)+"\n"+string(record)+"\n", out.String())
+	assert.Equal(t, int64(1), sink.CompressionStats().Batches)
+}
+
+func TestSink_Compression(t *testing.T) {
+	t.Parallel()
+
+	// arrange
+	var out bytes.Buffer
+	sink := codec.NewSink(&out, codec.JSON, codec.WithCompression(compress.Gzip))
+	batch := slices.Repeat([]audriver.DatabaseModification{mod}, 100)
+
+	// act
+	require.NoError(t, sink.Write(t.Context(), batch))
+	require.NoError(t, sink.Write(t.Context(), batch))
+
+	// assert
+	r, err := gzip.NewReader(&out)
+	require.NoError(t, err)
+	decompressed, err := io.ReadAll(r)
+	require.NoError(t, err)
+	assert.Equal(t, 200, bytes.Count(decompressed, []byte("\n")), "batches should decompress as one stream")
+
+	stats := sink.CompressionStats()
+	assert.Equal(t, int64(2), stats.Batches)
+	assert.Equal(t, int64(len(decompressed)), stats.Bytes)
+	assert.Greater(t, stats.Ratio(), 1.0)
+}
@@ -0,0 +1,157 @@
+package codec
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"math"
+	"slices"
+	"time"
+
+	"github.com/mickamy/go-sql-audit-driver/audriver"
+)
+
+// MsgPack encodes records as MessagePack maps keyed by the names of the JSON encoding, leaving out fields without
+// values as the JSON encoding does. The modification time is a timestamp extension, and the metadata a nested map.
+var MsgPack Codec = msgpackCodec{}
+
+type msgpackCodec struct{}
+
+func (msgpackCodec) Name() string {
+	return "msgpack"
+}
+
+func (msgpackCodec) ContentType() string {
+	return "application/vnd.msgpack"
+}
+
+func (msgpackCodec) Encode(mod audriver.DatabaseModification) ([]byte, error) {
+	values := make([]any, 0, len(fields))
+	names := make([]string, 0, len(fields))
+	for _, f := range fields {
+		v := f.value(mod)
+		if !f.required && empty(v) {
+			continue
+		}
+		if metadata, ok := v.(map[string]any); ok {
+			// values such as structs are encoded as they are in JSON
+			data, err := json.Marshal(metadata)
+			if err != nil {
+				return nil, fmt.Errorf("failed to encode metadata: %w", err)
+			}
+			v = nil
+			if err := json.Unmarshal(data, &v); err != nil {
+				return nil, fmt.Errorf("failed to encode metadata: %w", err)
+			}
+		}
+		names = append(names, f.name)
+		values = append(values, v)
+	}
+
+	buf := make([]byte, 0, 256+len(mod.SQL))
+	buf = appendMsgpackMapHeader(buf, len(names))
+	for i, name := range names {
+		buf = appendMsgpackString(buf, name)
+		buf = appendMsgpack(buf, values[i])
+	}
+	return buf, nil
+}
+
+// appendMsgpack appends the encoding of v, a value of a field or of decoded JSON.
+func appendMsgpack(buf []byte, v any) []byte {
+	switch v := v.(type) {
+	case nil:
+		return append(buf, 0xc0)
+	case bool:
+		if v {
+			return append(buf, 0xc3)
+		}
+		return append(buf, 0xc2)
+	case string:
+		return appendMsgpackString(buf, v)
+	case int64:
+		return appendMsgpackInt(buf, v)
+	case float64:
+		if v == math.Trunc(v) && math.Abs(v) < 1<<63 {
+			return appendMsgpackInt(buf, int64(v))
+		}
+		return binary.BigEndian.AppendUint64(append(buf, 0xcb), math.Float64bits(v))
+	case time.Time:
+		// timestamp 96 of the timestamp extension type -1
+		buf = append(buf, 0xc7, 12, 0xff)
+		buf = binary.BigEndian.AppendUint32(buf, uint32(v.Nanosecond()))
+		return binary.BigEndian.AppendUint64(buf, uint64(v.Unix()))
+	case []string:
+		buf = appendMsgpackArrayHeader(buf, len(v))
+		for _, s := range v {
+			buf = appendMsgpackString(buf, s)
+		}
+		return buf
+	case []any:
+		buf = appendMsgpackArrayHeader(buf, len(v))
+		for _, e := range v {
+			buf = appendMsgpack(buf, e)
+		}
+		return buf
+	case map[string]any:
+		buf = appendMsgpackMapHeader(buf, len(v))
+		// keys in order, so that encodings are deterministic
+		for _, key := range slices.Sorted(maps.Keys(v)) {
+			buf = appendMsgpackString(buf, key)
+			buf = appendMsgpack(buf, v[key])
+		}
+		return buf
+	}
+	return append(buf, 0xc0)
+}
+
+func appendMsgpackInt(buf []byte, v int64) []byte {
+	switch {
+	case v >= 0 && v <= math.MaxInt8:
+		return append(buf, byte(v))
+	case v < 0 && v >= -32:
+		return append(buf, byte(v))
+	case v >= math.MinInt8 && v <= math.MaxInt8:
+		return append(buf, 0xd0, byte(v))
+	case v >= math.MinInt16 && v <= math.MaxInt16:
+		return binary.BigEndian.AppendUint16(append(buf, 0xd1), uint16(v))
+	case v >= math.MinInt32 && v <= math.MaxInt32:
+		return binary.BigEndian.AppendUint32(append(buf, 0xd2), uint32(v))
+	}
+	return binary.BigEndian.AppendUint64(append(buf, 0xd3), uint64(v))
+}
+
+func appendMsgpackString(buf []byte, s string) []byte {
+	switch n := len(s); {
+	case n < 32:
+		buf = append(buf, 0xa0|byte(n))
+	case n <= math.MaxUint8:
+		buf = append(buf, 0xd9, byte(n))
+	case n <= math.MaxUint16:
+		buf = binary.BigEndian.AppendUint16(append(buf, 0xda), uint16(n))
+	default:
+		buf = binary.BigEndian.AppendUint32(append(buf, 0xdb), uint32(n))
+	}
+	return append(buf, s...)
+}
+
+func appendMsgpackArrayHeader(buf []byte, n int) []byte {
+	switch {
+	case n < 16:
+		return append(buf, 0x90|byte(n))
+	case n <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(buf, 0xdc), uint16(n))
+	}
+	return binary.BigEndian.AppendUint32(append(buf, 0xdd), uint32(n))
+}
+
+func appendMsgpackMapHeader(buf []byte, n int) []byte {
+	switch {
+	case n < 16:
+		return append(buf, 0x80|byte(n))
+	case n <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(buf, 0xde), uint16(n))
+	}
+	return binary.BigEndian.AppendUint32(append(buf, 0xdf), uint32(n))
+}
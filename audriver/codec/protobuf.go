@@ -0,0 +1,113 @@
+package codec
+
+import (
+	"encoding/binary"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mickamy/go-sql-audit-driver/audriver"
+)
+
+// Protobuf encodes records as messages of ProtobufSchema.
+var Protobuf Codec = protobufCodec{}
+
+// ProtobufSchema is the schema of the messages Protobuf encodes, e.g. to generate consumers with protoc.
+var ProtobufSchema = protobufSchema()
+
+func protobufSchema() string {
+	var b strings.Builder
+	b.WriteString(`syntax = "proto3";
+
+package audriver.v1;
+
+// DatabaseModification is an audit record of audriver.
+message DatabaseModification {
+`)
+	for i, f := range fields {
+		var typ string
+		switch f.kind {
+		case kindString:
+			typ = "string"
+		case kindBool:
+			typ = "bool"
+		case kindInt:
+			typ = "int64"
+		case kindStrings:
+			typ = "repeated string"
+		case kindTime:
+			typ = "int64"
+		case kindMetadata:
+			typ = "string"
+		}
+		b.WriteString("  " + typ + " " + f.name + " = " + strconv.Itoa(i+1) + ";")
+		switch f.kind {
+		case kindTime:
+			b.WriteString(" // microseconds since the Unix epoch")
+		case kindMetadata:
+			b.WriteString(" // JSON document")
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+type protobufCodec struct{}
+
+func (protobufCodec) Name() string {
+	return "protobuf"
+}
+
+func (protobufCodec) ContentType() string {
+	return "application/x-protobuf"
+}
+
+// Protobuf wire types.
+const (
+	wireVarint = 0
+	wireBytes  = 2
+)
+
+func (protobufCodec) Encode(mod audriver.DatabaseModification) ([]byte, error) {
+	buf := make([]byte, 0, 256+len(mod.SQL))
+	for i, f := range fields {
+		number := uint64(i + 1)
+		v := f.value(mod)
+		// proto3 leaves fields of zero values out
+		if empty(v) {
+			continue
+		}
+		switch v := v.(type) {
+		case string:
+			buf = appendProtoBytes(buf, number, v)
+		case bool:
+			buf = binary.AppendUvarint(buf, number<<3|wireVarint)
+			buf = append(buf, 1)
+		case int64:
+			buf = binary.AppendUvarint(buf, number<<3|wireVarint)
+			buf = binary.AppendUvarint(buf, uint64(v))
+		case []string:
+			for _, s := range v {
+				buf = appendProtoBytes(buf, number, s)
+			}
+		case time.Time:
+			buf = binary.AppendUvarint(buf, number<<3|wireVarint)
+			buf = binary.AppendUvarint(buf, uint64(v.UnixMicro()))
+		case map[string]any:
+			metadata, err := metadataJSON(v)
+			if err != nil {
+				return nil, err
+			}
+			buf = appendProtoBytes(buf, number, metadata)
+		}
+	}
+	return buf, nil
+}
+
+// appendProtoBytes appends the length-delimited field number holding s.
+func appendProtoBytes(buf []byte, number uint64, s string) []byte {
+	buf = binary.AppendUvarint(buf, number<<3|wireBytes)
+	buf = binary.AppendUvarint(buf, uint64(len(s)))
+	return append(buf, s...)
+}
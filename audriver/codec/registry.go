@@ -0,0 +1,171 @@
+package codec
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/mickamy/go-sql-audit-driver/audriver"
+)
+
+// SubjectNameStrategy names the subject the schema of records of a topic is registered under, given the fully
+// qualified name of the record, as the strategies of Confluent serializers do.
+type SubjectNameStrategy func(topic string, record string) string
+
+var (
+	// TopicNameStrategy names subjects after the topic, e.g. audit-value. It is the default of Confluent serializers.
+	TopicNameStrategy SubjectNameStrategy = func(topic string, _ string) string {
+		return topic + "-value"
+	}
+
+	// RecordNameStrategy names subjects after the record, e.g. audriver.DatabaseModification, sharing the schema
+	// across topics.
+	RecordNameStrategy SubjectNameStrategy = func(_ string, record string) string {
+		return record
+	}
+
+	// TopicRecordNameStrategy names subjects after the topic and the record, e.g. audit-audriver.DatabaseModification.
+	TopicRecordNameStrategy SubjectNameStrategy = func(topic string, record string) string {
+		return topic + "-" + record
+	}
+)
+
+// Registry registers the schemas of codecs with a Confluent Schema Registry, so that consumers, e.g. of Kafka topics,
+// decode records with managed schemas. Records are then encoded in the wire format of Confluent serializers.
+type Registry struct {
+	// URL is the base URL of the schema registry, e.g. http://localhost:8081.
+	URL string
+
+	// Username and Password authenticate with HTTP basic authentication if set, e.g. with the API key and secret of
+	// Confluent Cloud.
+	Username string
+	Password string
+
+	// SubjectNameStrategy names the subjects schemas are registered under. It defaults to TopicNameStrategy.
+	SubjectNameStrategy SubjectNameStrategy
+
+	// Client is the HTTP client of the registry. It defaults to http.DefaultClient.
+	Client *http.Client
+}
+
+// NewRegistry returns a client of the schema registry at url.
+func NewRegistry(url string) *Registry {
+	return &Registry{URL: url}
+}
+
+// Registered is a codec encoding records in the wire format of Confluent serializers: a zero byte, the ID of the
+// registered schema as a 4-byte big-endian integer, and, for Protobuf, the index of the message in the schema,
+// followed by the encoding of the underlying codec.
+type Registered struct {
+	Codec
+
+	// Subject is the subject the schema is registered under.
+	Subject string
+
+	// SchemaID is the ID of the schema in the registry.
+	SchemaID int
+
+	prefix []byte
+}
+
+// Encode returns the encoding of mod prefixed with the ID of the schema.
+func (c *Registered) Encode(mod audriver.DatabaseModification) ([]byte, error) {
+	data, err := c.Codec.Encode(mod)
+	if err != nil {
+		return nil, err
+	}
+	return append(c.prefix[:len(c.prefix):len(c.prefix)], data...), nil
+}
+
+// Register registers the schema of c, Avro or Protobuf, for the records of topic and returns a codec encoding them
+// as c does, with the ID of the schema. Registering a schema registered before returns its ID; the registry rejects
+// schemas incompatible with earlier versions of the subject, as configured for it.
+func (r *Registry) Register(ctx context.Context, c Codec, topic string) (*Registered, error) {
+	var (
+		schemaType string
+		schema     string
+		record     string
+	)
+	switch c {
+	case Avro:
+		schemaType, schema, record = "AVRO", AvroSchema, "audriver.DatabaseModification"
+	case Protobuf:
+		schemaType, schema, record = "PROTOBUF", ProtobufSchema, "audriver.v1.DatabaseModification"
+	default:
+		return nil, fmt.Errorf("codec %s has no schema to register", c.Name())
+	}
+
+	strategy := r.SubjectNameStrategy
+	if strategy == nil {
+		strategy = TopicNameStrategy
+	}
+	subject := strategy(topic, record)
+	id, err := r.register(ctx, subject, schemaType, schema)
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := binary.BigEndian.AppendUint32([]byte{0}, uint32(id))
+	if c == Protobuf {
+		// the message indexes of the first message of the schema
+		prefix = append(prefix, 0)
+	}
+	return &Registered{Codec: c, Subject: subject, SchemaID: id, prefix: prefix}, nil
+}
+
+// register registers schema under subject and returns its ID.
+func (r *Registry) register(ctx context.Context, subject string, schemaType string, schema string) (int, error) {
+	payload := map[string]string{"schema": schema}
+	if schemaType != "AVRO" {
+		// Avro is the default, and registries predating other types reject the field
+		payload["schemaType"] = schemaType
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return 0, fmt.Errorf("failed to encode schema: %w", err)
+	}
+
+	endpoint := strings.TrimSuffix(r.URL, "/") + "/subjects/" + url.PathEscape(subject) + "/versions"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(data))
+	if err != nil {
+		return 0, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/vnd.schemaregistry.v1+json")
+	if r.Username != "" || r.Password != "" {
+		req.SetBasicAuth(r.Username, r.Password)
+	}
+
+	client := r.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to register schema of subject %s: %w", subject, err)
+	}
+	defer func(body io.ReadCloser) {
+		_ = body.Close()
+	}(res.Body)
+
+	body, err := io.ReadAll(io.LimitReader(res.Body, 1<<16))
+	if err != nil {
+		return 0, fmt.Errorf("failed to register schema of subject %s: %w", subject, err)
+	}
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return 0, errors.New("failed to register schema of subject " + subject + ": " + res.Status + ": " + strings.TrimSpace(string(body)))
+	}
+	var registered struct {
+		ID int `json:"id"`
+	}
+	if err := json.Unmarshal(body, &registered); err != nil {
+		return 0, fmt.Errorf("failed to decode registered schema of subject %s: %w", subject, err)
+	}
+	return registered.ID, nil
+}
@@ -0,0 +1,118 @@
+package codec_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mickamy/go-sql-audit-driver/audriver/codec"
+)
+
+func TestRegistry_Register(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name       string
+		codec      codec.Codec
+		strategy   codec.SubjectNameStrategy
+		subject    string
+		schemaType string
+		prefix     []byte
+	}{
+		{
+			name:    "avro",
+			codec:   codec.Avro,
+			subject: "audit-value",
+			prefix:  []byte{0, 0, 0, 1, 0x2c},
+		},
+		{
+			name:       "protobuf",
+			codec:      codec.Protobuf,
+			strategy:   codec.TopicRecordNameStrategy,
+			subject:    "audit-audriver.v1.DatabaseModification",
+			schemaType: "PROTOBUF",
+			prefix:     []byte{0, 0, 0, 1, 0x2c, 0},
+		},
+		{
+			name:     "record name",
+			codec:    codec.Avro,
+			strategy: codec.RecordNameStrategy,
+			subject:  "audriver.DatabaseModification",
+			prefix:   []byte{0, 0, 0, 1, 0x2c},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// arrange
+			var (
+				path    string
+				payload map[string]string
+				user    string
+			)
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				path = r.URL.Path
+				user, _, _ = r.BasicAuth()
+				_ = json.NewDecoder(r.Body).Decode(&payload)
+				_, _ = w.Write([]byte(`{"id": 300}`))
+			}))
+			t.Cleanup(server.Close)
+			registry := codec.NewRegistry(server.URL)
+			registry.Username, registry.Password = "key", "secret"
+			registry.SubjectNameStrategy = tc.strategy
+
+			// act
+			registered, err := registry.Register(t.Context(), tc.codec, "audit")
+
+			// assert
+			require.NoError(t, err)
+			assert.Equal(t, "/subjects/"+tc.subject+"/versions", path)
+			assert.Equal(t, "key", user)
+			assert.Equal(t, tc.schemaType, payload["schemaType"])
+			assert.NotEmpty(t, payload["schema"])
+			assert.Equal(t, tc.subject, registered.Subject)
+			assert.Equal(t, 300, registered.SchemaID)
+
+			got, err := registered.Encode(mod)
+			require.NoError(t, err)
+			record, err := tc.codec.Encode(mod)
+			require.NoError(t, err)
+			assert.Equal(t, append(tc.prefix, record...), got)
+		})
+	}
+
+	t.Run("rejected", func(t *testing.T) {
+		t.Parallel()
+
+		// arrange
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusConflict)
+			_, _ = w.Write([]byte(`{"error_code": 409, "message": "Schema being registered is incompatible with an earlier schema"}`))
+		}))
+		t.Cleanup(server.Close)
+
+		// act
+		_, err := codec.NewRegistry(server.URL).Register(t.Context(), codec.Avro, "audit")
+
+		// assert
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "incompatible")
+	})
+
+	t.Run("without schema", func(t *testing.T) {
+		t.Parallel()
+
+		// act
+		_, err := codec.NewRegistry("http://localhost").Register(t.Context(), codec.JSON, "audit")
+
+		// assert
+		assert.Error(t, err)
+	})
+}
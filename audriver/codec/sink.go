@@ -0,0 +1,80 @@
+package codec
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/mickamy/go-sql-audit-driver/audriver"
+	"github.com/mickamy/go-sql-audit-driver/audriver/compress"
+)
+
+// SinkOption configures a Sink.
+type SinkOption func(*Sink)
+
+// WithCompression compresses each batch of records with c, e.g. compress.Gzip. Batches are complete gzip members,
+// Snappy streams, or zstd frames, so that the written file decompresses as one stream. Sinks leave batches uncompressed by default.
+func WithCompression(c compress.Compressor) SinkOption {
+	return func(s *Sink) {
+		s.compressor = compress.NewMetered(c)
+	}
+}
+
+// Sink is an audriver.AuditSink writing audit records to an io.Writer, e.g. a file, framed by a Writer.
+// It is safe for concurrent use.
+type Sink struct {
+	mu         sync.Mutex
+	w          io.Writer
+	writer     *Writer
+	batch      bytes.Buffer
+	compressor *compress.Metered
+}
+
+// NewSink returns a sink writing records encoded with codec to w, e.g. for WithAuditSink:
+//
+//	audriver.WithAuditSink(codec.NewSink(f, codec.JSON, codec.WithCompression(compress.Gzip)))
+func NewSink(w io.Writer, codec Codec, options ...SinkOption) *Sink {
+	s := &Sink{w: w, compressor: compress.NewMetered(compress.None)}
+	s.writer = NewWriter(&s.batch, codec)
+	for _, opt := range options {
+		opt(s)
+	}
+	return s
+}
+
+// Write writes mods as a batch, so that they are written when Write returns.
+func (s *Sink) Write(_ context.Context, mods []audriver.DatabaseModification) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	defer s.batch.Reset()
+
+	for _, mod := range mods {
+		if err := s.writer.Write(mod); err != nil {
+			return err
+		}
+	}
+	if err := s.writer.Flush(); err != nil {
+		return err
+	}
+	if s.batch.Len() == 0 {
+		return nil
+	}
+
+	data, err := s.compressor.Compress(s.batch.Bytes())
+	if err != nil {
+		return err
+	}
+	if _, err := s.w.Write(data); err != nil {
+		return fmt.Errorf("failed to write records: %w", err)
+	}
+	return nil
+}
+
+// CompressionStats returns the batches written so far, and their sizes before and after compression.
+func (s *Sink) CompressionStats() compress.Stats {
+	return s.compressor.Stats()
+}
+
+var _ audriver.AuditSink = (*Sink)(nil)
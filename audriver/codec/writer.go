@@ -0,0 +1,97 @@
+package codec
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/mickamy/go-sql-audit-driver/audriver"
+)
+
+// avroBlockSize is the number of records of a block of an Avro object container file.
+const avroBlockSize = 1000
+
+// Writer writes a stream of records encoded with a codec, framed as its format expects:
+// JSON as JSON lines, MessagePack as concatenated values, Avro as an object container file holding AvroSchema,
+// and Protobuf and other codecs as records prefixed with their length as a varint, as delimited protobuf messages are.
+type Writer struct {
+	w     io.Writer
+	codec Codec
+
+	// the block and sync marker of Avro object container files
+	block   bytes.Buffer
+	records int
+	sync    []byte
+}
+
+// NewWriter returns a writer of records encoded with codec to w. Flush the writer after the last record.
+func NewWriter(w io.Writer, codec Codec) *Writer {
+	return &Writer{w: w, codec: codec}
+}
+
+// Write writes mod. Avro records are buffered into blocks until Flush.
+func (w *Writer) Write(mod audriver.DatabaseModification) error {
+	data, err := w.codec.Encode(mod)
+	if err != nil {
+		return err
+	}
+
+	switch w.codec {
+	case JSON:
+		data = append(data, '\n')
+	case MsgPack:
+	case Avro:
+		w.block.Write(data)
+		w.records++
+		if w.records < avroBlockSize {
+			return nil
+		}
+		return w.Flush()
+	default:
+		data = append(binary.AppendUvarint(make([]byte, 0, len(data)+binary.MaxVarintLen64), uint64(len(data))), data...)
+	}
+	if _, err := w.w.Write(data); err != nil {
+		return fmt.Errorf("failed to write record: %w", err)
+	}
+	return nil
+}
+
+// Flush writes the buffered records. For Avro, it writes the header of the object container file first, even without
+// records, so that an empty export is a valid file.
+func (w *Writer) Flush() error {
+	if w.codec != Avro {
+		return nil
+	}
+
+	var out []byte
+	if w.sync == nil {
+		w.sync = make([]byte, 16)
+		_, _ = rand.Read(w.sync)
+		out = append(out, 'O', 'b', 'j', 1)
+		// the metadata map of the file, as a block of 2 entries
+		out = binary.AppendVarint(out, 2)
+		out = appendAvroString(out, "avro.schema")
+		out = appendAvroString(out, AvroSchema)
+		out = appendAvroString(out, "avro.codec")
+		out = appendAvroString(out, "null")
+		out = binary.AppendVarint(out, 0)
+		out = append(out, w.sync...)
+	}
+	if w.records > 0 {
+		out = binary.AppendVarint(out, int64(w.records))
+		out = binary.AppendVarint(out, int64(w.block.Len()))
+		out = append(out, w.block.Bytes()...)
+		out = append(out, w.sync...)
+		w.block.Reset()
+		w.records = 0
+	}
+	if len(out) == 0 {
+		return nil
+	}
+	if _, err := w.w.Write(out); err != nil {
+		return fmt.Errorf("failed to write records: %w", err)
+	}
+	return nil
+}
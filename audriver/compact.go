@@ -0,0 +1,138 @@
+package audriver
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/mickamy/go-sql-audit-driver/internal/postgres"
+)
+
+// CompactionMetadataKey is the metadata key under which aggregates written by Compact record what they stand for:
+// the number of records, the granularity, and the first and last modification times.
+const CompactionMetadataKey = "compaction"
+
+// CompactionGranularity is what a compacted aggregate stands for.
+type CompactionGranularity string
+
+const (
+	// CompactByTransaction collapses the records of each execution into one aggregate per table and action.
+	CompactByTransaction CompactionGranularity = "transaction"
+
+	// CompactByDay collapses the records of each day into one aggregate per table, action, and operator.
+	CompactByDay CompactionGranularity = "day"
+)
+
+// CompactionConfig configures Compact.
+type CompactionConfig struct {
+	// OlderThan is the age, rounded down to whole UTC days, after which records are compacted.
+	OlderThan time.Duration
+
+	// Granularity is what aggregates stand for. It defaults to CompactByTransaction.
+	Granularity CompactionGranularity
+
+	// Tables restricts compaction to the records of these tables. All tables are compacted if empty.
+	Tables []string
+
+	// AuditTable is the audit table to compact, optionally schema-qualified. It defaults to DefaultAuditTable.
+	AuditTable string
+}
+
+// CompactionResult summarizes a Compact run.
+type CompactionResult struct {
+	// Compacted is the number of records replaced with aggregates.
+	Compacted int64 `json:"compacted"`
+
+	// Aggregates is the number of aggregates written.
+	Aggregates int64 `json:"aggregates"`
+}
+
+// Compact keeps the audit table small while preserving long-term trends, by collapsing records older than
+// cfg.OlderThan into ExactnessAggregated records standing for them, e.g. from a daily job:
+//
+//	result, err := audriver.Compact(ctx, adminDB, audriver.CompactionConfig{OlderThan: 90 * 24 * time.Hour})
+//
+// Aggregates keep the operator, execution (by transaction), schema, table, action, and shard key of the records they
+// stand for, the union of their changed columns, and the first modification time, and record the number of records
+// and the time range under CompactionMetadataKey in the metadata column. The statements and record IDs are dropped.
+// Aggregates are never compacted again.
+//
+// Records are compacted a UTC day at a time, each in a single statement, so that an interrupted run leaves no
+// partially compacted day and can be repeated. The audit table needs the columns of the latest migration, and db a
+// role allowed to delete from it; tables protected with ProtectImmutability only by members of its CompactionRole.
+func Compact(ctx context.Context, db *sql.DB, cfg CompactionConfig) (CompactionResult, error) {
+	if cfg.OlderThan <= 0 {
+		return CompactionResult{}, errors.New("compaction age must be positive")
+	}
+	if cfg.Granularity == "" {
+		cfg.Granularity = CompactByTransaction
+	}
+	if cfg.Granularity != CompactByTransaction && cfg.Granularity != CompactByDay {
+		return CompactionResult{}, fmt.Errorf("unsupported compaction granularity: %s", cfg.Granularity)
+	}
+	if cfg.AuditTable == "" {
+		cfg.AuditTable = DefaultAuditTable
+	}
+	table := quoteQualifiedIdentifier(cfg.AuditTable)
+	until := time.Now().Add(-cfg.OlderThan).UTC().Truncate(24 * time.Hour)
+
+	tables := any(nil)
+	if len(cfg.Tables) > 0 {
+		tables = postgres.FormatArray(cfg.Tables)
+	}
+	const compactable = "exactness <> 'aggregated' AND ($1::text[] IS NULL OR table_name = ANY ($1::text[]))"
+
+	var first sql.NullTime
+	query := "SELECT min(modified_at) FROM " + table + " WHERE " + compactable + " AND modified_at < $2"
+	if err := db.QueryRowContext(ctx, query, tables, until).Scan(&first); err != nil {
+		return CompactionResult{}, fmt.Errorf("failed to find records to compact: %w", err)
+	}
+
+	var result CompactionResult
+	if !first.Valid {
+		return result, nil
+	}
+	query = compactionSQL(table, compactable, cfg.Granularity)
+	for day := first.Time.UTC().Truncate(24 * time.Hour); day.Before(until); day = day.Add(24 * time.Hour) {
+		var compacted, aggregates int64
+		if err := db.QueryRowContext(ctx, query, tables, day, day.Add(24*time.Hour)).Scan(&compacted, &aggregates); err != nil {
+			return result, fmt.Errorf("failed to compact records of %s: %w", day.Format(time.DateOnly), err)
+		}
+		result.Compacted += compacted
+		result.Aggregates += aggregates
+	}
+	return result, nil
+}
+
+// compactionSQL returns the statement replacing the compactable records of table modified within [$2, $3) with
+// aggregates of granularity, returning the number of records replaced and of aggregates written.
+func compactionSQL(table, compactable string, granularity CompactionGranularity) string {
+	executionID := "execution_id"
+	if granularity == CompactByDay {
+		// one derived execution per day, table, action, and operator, so that compacting a day again is recognizable
+		executionID = "md5(concat_ws('/', $2::text, operator_id, schema_name, table_name, action, shard_key))::uuid"
+	}
+
+	return `WITH compacted AS (
+    DELETE FROM ` + table + `
+    WHERE ` + compactable + ` AND modified_at >= $2 AND modified_at < $3
+    RETURNING *
+), aggregates AS (
+    INSERT INTO ` + table + ` (id, operator_id, execution_id, schema_name, table_name, action, sql, modified_at,
+                               changed_columns, shard_key, exactness, metadata)
+    SELECT gen_random_uuid(), operator_id, ` + executionID + `, schema_name, table_name, action,
+           '-- ' || count(DISTINCT id) || ' ' || action || ' statements compacted', min(modified_at),
+           NULLIF(array_remove(array_agg(DISTINCT changed_column ORDER BY changed_column), NULL), '{}'),
+           shard_key, 'aggregated',
+           jsonb_build_object('` + CompactionMetadataKey + `', jsonb_build_object(
+               'count', count(DISTINCT id), 'granularity', '` + string(granularity) + `',
+               'first', min(modified_at), 'last', max(modified_at)))
+    FROM compacted
+    LEFT JOIN LATERAL unnest(compacted.changed_columns) changed_column ON TRUE
+    GROUP BY operator_id, ` + executionID + `, schema_name, table_name, action, shard_key
+    RETURNING 1
+)
+SELECT (SELECT count(*) FROM compacted), (SELECT count(*) FROM aggregates)`
+}
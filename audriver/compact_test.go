@@ -0,0 +1,103 @@
+package audriver_test
+
+import (
+	"database/sql"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mickamy/go-sql-audit-driver/audriver"
+)
+
+// TestCompact tests collapsing old audit records into aggregates
+func TestCompact(t *testing.T) {
+	t.Parallel()
+
+	operatorID := uuid.New().String()
+	old := time.Now().AddDate(0, 0, -100).UTC().Truncate(24 * time.Hour).Add(12 * time.Hour)
+	table := "compact_" + uuid.New().String()[:8]
+
+	// insertRecords inserts records of table into db, two executions of two updates each a day ago and 100 days ago.
+	insertRecords := func(t *testing.T, db *sql.DB) {
+		t.Helper()
+		for _, modifiedAt := range []time.Time{old, time.Now().AddDate(0, 0, -1)} {
+			for range 2 {
+				executionID := uuid.New().String()
+				for _, column := range []string{"name", "email"} {
+					_, err := db.ExecContext(t.Context(), `INSERT INTO database_modifications
+						(id, operator_id, execution_id, table_name, action, sql, modified_at, changed_columns)
+						VALUES ($1, $2, $3, $4, 'update', 'UPDATE ...', $5, $6)`,
+						uuid.New().String(), operatorID, executionID, table, modifiedAt, pq.Array([]string{column}))
+					require.NoError(t, err)
+				}
+			}
+		}
+	}
+
+	testCases := []struct {
+		name        string
+		granularity audriver.CompactionGranularity
+		aggregates  int64
+	}{
+		{name: "transaction", granularity: audriver.CompactByTransaction, aggregates: 2},
+		{name: "day", granularity: audriver.CompactByDay, aggregates: 1},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// arrange
+			db, err := sql.Open("txdb_writer", uuid.New().String())
+			require.NoError(t, err)
+			t.Cleanup(func() {
+				_ = db.Close()
+			})
+			insertRecords(t, db)
+			cfg := audriver.CompactionConfig{OlderThan: 30 * 24 * time.Hour, Granularity: tc.granularity, Tables: []string{table}}
+
+			// act
+			result, err := audriver.Compact(t.Context(), db, cfg)
+			require.NoError(t, err)
+			again, err := audriver.Compact(t.Context(), db, cfg)
+			require.NoError(t, err)
+
+			// assert
+			assert.Equal(t, audriver.CompactionResult{Compacted: 4, Aggregates: tc.aggregates}, result)
+			assert.Zero(t, again, "aggregates should not be compacted again")
+
+			var exact int
+			query := "SELECT count(*) FROM database_modifications WHERE table_name = $1 AND exactness = 'exact'"
+			require.NoError(t, db.QueryRowContext(t.Context(), query, table).Scan(&exact))
+			assert.Equal(t, 4, exact, "recent records should be kept")
+
+			var (
+				changedColumns []string
+				metadata       []byte
+			)
+			query = "SELECT changed_columns, metadata FROM database_modifications WHERE table_name = $1 AND exactness = 'aggregated' LIMIT 1"
+			require.NoError(t, db.QueryRowContext(t.Context(), query, table).Scan(pq.Array(&changedColumns), &metadata))
+			assert.Equal(t, []string{"email", "name"}, changedColumns)
+			var compaction map[string]map[string]any
+			require.NoError(t, json.Unmarshal(metadata, &compaction))
+			assert.Equal(t, float64(4/tc.aggregates), compaction[audriver.CompactionMetadataKey]["count"])
+			assert.Equal(t, string(tc.granularity), compaction[audriver.CompactionMetadataKey]["granularity"])
+		})
+	}
+
+	t.Run("invalid_config", func(t *testing.T) {
+		t.Parallel()
+
+		// act
+		_, err := audriver.Compact(t.Context(), nil, audriver.CompactionConfig{})
+
+		// assert
+		assert.ErrorContains(t, err, "compaction age")
+	})
+}
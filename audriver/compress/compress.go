@@ -0,0 +1,139 @@
+// Package compress compresses the batch payloads of sinks, e.g. files written by codec.Sink, with gzip, Snappy, zstd,
+// or other algorithms plugged in as a Compressor, such as brotli:
+//
+//	compress.Compressors["br"] = compress.Func("br", func(data []byte) ([]byte, error) {
+//		var buf bytes.Buffer
+//		w := brotli.NewWriter(&buf) // github.com/andybalholm/brotli
+//		if _, err := w.Write(data); err != nil {
+//			return nil, err
+//		}
+//		if err := w.Close(); err != nil {
+//			return nil, err
+//		}
+//		return buf.Bytes(), nil
+//	})
+//
+// Each compressed batch is a complete gzip member, Snappy stream, or zstd frame, so batches can be concatenated, e.g.
+// appended to a file, and decompressed as one stream. Metered reports the compression ratio of a compressor.
+package compress
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"sync/atomic"
+)
+
+// Compressor compresses batch payloads.
+type Compressor interface {
+	// Name identifies the compression, e.g. "gzip", as the Content-Encoding of HTTP or the codec of Kafka do.
+	Name() string
+
+	// Compress returns the compression of data.
+	Compress(data []byte) ([]byte, error)
+}
+
+// Func returns a compressor named name compressing with fn.
+func Func(name string, fn func(data []byte) ([]byte, error)) Compressor {
+	return funcCompressor{name: name, fn: fn}
+}
+
+type funcCompressor struct {
+	name string
+	fn   func(data []byte) ([]byte, error)
+}
+
+func (c funcCompressor) Name() string {
+	return c.name
+}
+
+func (c funcCompressor) Compress(data []byte) ([]byte, error) {
+	return c.fn(data)
+}
+
+// Compressors are the available compressors, by name. Compressors depending on other modules, such as brotli, are
+// added by the application.
+var Compressors = map[string]Compressor{
+	None.Name():   None,
+	Gzip.Name():   Gzip,
+	Snappy.Name(): Snappy,
+	Zstd.Name():   Zstd,
+}
+
+// ByName returns the compressor named name, e.g. from a flag.
+func ByName(name string) (Compressor, error) {
+	c, ok := Compressors[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown compression: %s", name)
+	}
+	return c, nil
+}
+
+// None leaves payloads uncompressed. It is the default of sinks.
+var None Compressor = Func("none", func(data []byte) ([]byte, error) {
+	return data, nil
+})
+
+// Gzip compresses payloads as gzip members with the default compression level.
+var Gzip Compressor = Func("gzip", func(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.Grow(len(data) / 4)
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(data); err != nil {
+		return nil, fmt.Errorf("failed to compress payload: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return nil, fmt.Errorf("failed to compress payload: %w", err)
+	}
+	return buf.Bytes(), nil
+})
+
+// Stats are the payloads compressed by a Metered compressor.
+type Stats struct {
+	// Batches is the number of payloads compressed.
+	Batches int64
+
+	// Bytes and CompressedBytes are the sizes of the payloads before and after compression.
+	Bytes           int64
+	CompressedBytes int64
+}
+
+// Ratio returns the compression ratio, i.e. Bytes per CompressedBytes, or 0 if nothing was compressed.
+func (s Stats) Ratio() float64 {
+	if s.CompressedBytes == 0 {
+		return 0
+	}
+	return float64(s.Bytes) / float64(s.CompressedBytes)
+}
+
+// Metered is a compressor counting the payloads compressed with the underlying one, e.g. to export the compression
+// ratio as a metric.
+type Metered struct {
+	Compressor
+
+	batches    atomic.Int64
+	bytes      atomic.Int64
+	compressed atomic.Int64
+}
+
+// NewMetered returns a metered c.
+func NewMetered(c Compressor) *Metered {
+	return &Metered{Compressor: c}
+}
+
+// Compress compresses data with the underlying compressor and counts it.
+func (m *Metered) Compress(data []byte) ([]byte, error) {
+	compressed, err := m.Compressor.Compress(data)
+	if err != nil {
+		return nil, err
+	}
+	m.batches.Add(1)
+	m.bytes.Add(int64(len(data)))
+	m.compressed.Add(int64(len(compressed)))
+	return compressed, nil
+}
+
+// Stats returns the payloads compressed so far.
+func (m *Metered) Stats() Stats {
+	return Stats{Batches: m.batches.Load(), Bytes: m.bytes.Load(), CompressedBytes: m.compressed.Load()}
+}
@@ -0,0 +1,225 @@
+package compress_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math/rand/v2"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mickamy/go-sql-audit-driver/audriver/compress"
+)
+
+// payloads are batches of various sizes and compressibility.
+var payloads = map[string][]byte{
+	"empty":    nil,
+	"short":    []byte("abc"),
+	"records":  []byte(strings.Repeat(`{"id":"a","table_name":"users","action":"update","sql":"UPDATE users SET name = 'x'"}`+"\n", 2000)),
+	"random":   randomBytes(100_000),
+	"repeated": bytes.Repeat([]byte{'a'}, 200_000),
+}
+
+func randomBytes(n int) []byte {
+	r := rand.New(rand.NewPCG(1, 2))
+	data := make([]byte, n)
+	for i := range data {
+		data[i] = byte(r.Uint32())
+	}
+	return data
+}
+
+func TestByName(t *testing.T) {
+	t.Parallel()
+
+	for _, name := range []string{"none", "gzip", "snappy", "zstd"} {
+		c, err := compress.ByName(name)
+		require.NoError(t, err)
+		assert.Equal(t, name, c.Name())
+	}
+	_, err := compress.ByName("brotli")
+	assert.Error(t, err, "brotli should be plugged in by the application")
+}
+
+func TestGzip(t *testing.T) {
+	t.Parallel()
+
+	for name, payload := range payloads {
+		// act
+		got, err := compress.Gzip.Compress(payload)
+
+		// assert
+		require.NoError(t, err, name)
+		r, err := gzip.NewReader(bytes.NewReader(got))
+		require.NoError(t, err, name)
+		decompressed, err := io.ReadAll(r)
+		require.NoError(t, err, name)
+		assert.Equal(t, len(payload), len(decompressed), name)
+		assert.True(t, bytes.Equal(payload, decompressed), name)
+	}
+}
+
+func TestSnappy(t *testing.T) {
+	t.Parallel()
+
+	for name, payload := range payloads {
+		// act
+		got, err := compress.Snappy.Compress(payload)
+
+		// assert
+		require.NoError(t, err, name)
+		decompressed, err := decodeSnappyStream(got)
+		require.NoError(t, err, name)
+		assert.True(t, bytes.Equal(payload, decompressed), name)
+	}
+
+	compressed, err := compress.Snappy.Compress(payloads["records"])
+	require.NoError(t, err)
+	assert.Less(t, len(compressed), len(payloads["records"])/10, "repetitive payloads should be compressed")
+	compressed, err = compress.Snappy.Compress(payloads["random"])
+	require.NoError(t, err)
+	assert.Less(t, len(compressed), len(payloads["random"])+100, "incompressible payloads should be stored as they are")
+}
+
+func FuzzSnappy(f *testing.F) {
+	for _, payload := range payloads {
+		// short seeds keep mutating fast
+		f.Add(payload[:min(len(payload), 4096)])
+	}
+	f.Fuzz(func(t *testing.T, payload []byte) {
+		compressed, err := compress.Snappy.Compress(payload)
+		require.NoError(t, err)
+		decompressed, err := decodeSnappyStream(compressed)
+		require.NoError(t, err)
+		assert.True(t, bytes.Equal(payload, decompressed))
+	})
+}
+
+func TestMetered(t *testing.T) {
+	t.Parallel()
+
+	// arrange
+	metered := compress.NewMetered(compress.Gzip)
+
+	// act
+	_, err := metered.Compress(payloads["records"])
+	require.NoError(t, err)
+	_, err = metered.Compress(payloads["records"])
+	require.NoError(t, err)
+
+	// assert
+	stats := metered.Stats()
+	assert.Equal(t, int64(2), stats.Batches)
+	assert.Equal(t, int64(2*len(payloads["records"])), stats.Bytes)
+	assert.Greater(t, stats.Ratio(), 10.0)
+	assert.Equal(t, "gzip", metered.Name())
+	assert.Zero(t, compress.Stats{}.Ratio())
+}
+
+// decodeSnappyStream decodes a stream of the Snappy framing format.
+func decodeSnappyStream(data []byte) ([]byte, error) {
+	if !bytes.HasPrefix(data, []byte("\xff\x06\x00\x00sNaPpY")) {
+		return nil, errors.New("missing stream identifier")
+	}
+	data = data[10:]
+	var out []byte
+	for len(data) > 0 {
+		if len(data) < 8 {
+			return nil, errors.New("truncated chunk")
+		}
+		kind, n := data[0], int(data[1])|int(data[2])<<8|int(data[3])<<16
+		if n < 4 || len(data) < 4+n {
+			return nil, errors.New("truncated chunk")
+		}
+		body := data[4 : 4+n]
+		data = data[4+n:]
+		chunk := body[4:]
+		if kind > 0x01 {
+			return nil, fmt.Errorf("unexpected chunk type %#x", kind)
+		}
+		if len(chunk) > 65536 && kind == 0x01 {
+			return nil, errors.New("chunk exceeds 65536 bytes")
+		}
+		if kind == 0x00 {
+			var err error
+			if chunk, err = decodeSnappyBlock(chunk); err != nil {
+				return nil, err
+			}
+		}
+		crc := crc32.Checksum(chunk, crc32.MakeTable(crc32.Castagnoli))
+		if binary.LittleEndian.Uint32(body) != (crc>>15|crc<<17)+0xa282ead8 {
+			return nil, errors.New("checksum mismatch")
+		}
+		out = append(out, chunk...)
+	}
+	return out, nil
+}
+
+// decodeSnappyBlock decodes the Snappy block format, of at most 65536 bytes in streams.
+func decodeSnappyBlock(src []byte) ([]byte, error) {
+	length, n := binary.Uvarint(src)
+	if n <= 0 || length > 65536 {
+		return nil, errors.New("invalid block length")
+	}
+	src = src[n:]
+	dst := make([]byte, 0, length)
+	for len(src) > 0 {
+		tag := src[0]
+		var offset, size int
+		switch tag & 3 {
+		case 0:
+			size = int(tag>>2) + 1
+			src = src[1:]
+			if size > 60 {
+				bytes := size - 60
+				if len(src) < bytes {
+					return nil, errors.New("truncated literal")
+				}
+				size = 1
+				for i := range bytes {
+					size += int(src[i]) << (8 * i)
+				}
+				src = src[bytes:]
+			}
+			if len(src) < size {
+				return nil, errors.New("truncated literal")
+			}
+			dst = append(dst, src[:size]...)
+			src = src[size:]
+			continue
+		case 1:
+			if len(src) < 2 {
+				return nil, errors.New("truncated copy")
+			}
+			size = int(tag>>2&7) + 4
+			offset = int(tag>>5)<<8 | int(src[1])
+			src = src[2:]
+		case 2:
+			if len(src) < 3 {
+				return nil, errors.New("truncated copy")
+			}
+			size = int(tag>>2) + 1
+			offset = int(binary.LittleEndian.Uint16(src[1:]))
+			src = src[3:]
+		default:
+			return nil, errors.New("unexpected 4-byte offset")
+		}
+		if offset == 0 || offset > len(dst) {
+			return nil, errors.New("invalid offset")
+		}
+		for i := 0; i < size; i++ {
+			dst = append(dst, dst[len(dst)-offset])
+		}
+	}
+	if uint64(len(dst)) != length || len(dst) > 65536 {
+		return nil, errors.New("length mismatch")
+	}
+	return dst, nil
+}
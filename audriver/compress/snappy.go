@@ -0,0 +1,115 @@
+package compress
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+)
+
+// Snappy compresses payloads as streams of the Snappy framing format, as written by snappy.NewBufferedWriter of
+// github.com/golang/snappy and read by its snappy.NewReader.
+var Snappy Compressor = Func("snappy", func(data []byte) ([]byte, error) {
+	return appendSnappyStream(nil, data), nil
+})
+
+const (
+	// snappyMaxChunk is the maximum size of the uncompressed data of a chunk of the framing format.
+	snappyMaxChunk = 65536
+
+	snappyChunkCompressed   = 0x00
+	snappyChunkUncompressed = 0x01
+)
+
+// snappyStreamIdentifier is the chunk starting a stream of the framing format.
+var snappyStreamIdentifier = []byte{0xff, 6, 0, 0, 's', 'N', 'a', 'P', 'p', 'Y'}
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// appendSnappyStream appends data as a stream of the framing format to dst.
+func appendSnappyStream(dst []byte, data []byte) []byte {
+	dst = append(dst, snappyStreamIdentifier...)
+	for len(data) > 0 {
+		chunk := data[:min(len(data), snappyMaxChunk)]
+		data = data[len(chunk):]
+
+		crc := crc32.Checksum(chunk, castagnoli)
+		masked := (crc>>15 | crc<<17) + 0xa282ead8
+
+		block := appendSnappyBlock(nil, chunk)
+		kind := byte(snappyChunkCompressed)
+		if len(block) >= len(chunk) {
+			// incompressible chunks are stored as they are
+			kind, block = snappyChunkUncompressed, chunk
+		}
+		n := len(block) + 4
+		dst = append(dst, kind, byte(n), byte(n>>8), byte(n>>16))
+		dst = binary.LittleEndian.AppendUint32(dst, masked)
+		dst = append(dst, block...)
+	}
+	return dst
+}
+
+// appendSnappyBlock appends src, of at most snappyMaxChunk bytes, compressed in the Snappy block format to dst:
+// its length, and literals and copies of earlier data found by hashing 4-byte sequences.
+func appendSnappyBlock(dst []byte, src []byte) []byte {
+	dst = binary.AppendUvarint(dst, uint64(len(src)))
+
+	const tableBits = 14
+	var table [1 << tableBits]int32 // positions + 1 by hash
+	literal := 0
+	for i := 0; i+4 <= len(src); {
+		v := binary.LittleEndian.Uint32(src[i:])
+		h := (v * 0x1e35a7bd) >> (32 - tableBits)
+		candidate := int(table[h]) - 1
+		table[h] = int32(i + 1)
+		if candidate < 0 || binary.LittleEndian.Uint32(src[candidate:]) != v {
+			i++
+			continue
+		}
+
+		length := 4
+		for i+length < len(src) && src[candidate+length] == src[i+length] {
+			length++
+		}
+		dst = appendSnappyLiteral(dst, src[literal:i])
+		dst = appendSnappyCopy(dst, i-candidate, length)
+		i += length
+		literal = i
+	}
+	return appendSnappyLiteral(dst, src[literal:])
+}
+
+// appendSnappyLiteral appends a literal element of lit, if it is not empty.
+func appendSnappyLiteral(dst []byte, lit []byte) []byte {
+	if len(lit) == 0 {
+		return dst
+	}
+	switch n := len(lit) - 1; {
+	case n < 60:
+		dst = append(dst, byte(n)<<2)
+	case n < 1<<8:
+		dst = append(dst, 60<<2, byte(n))
+	case n < 1<<16:
+		dst = append(dst, 61<<2, byte(n), byte(n>>8))
+	default:
+		dst = append(dst, 62<<2, byte(n), byte(n>>8), byte(n>>16))
+	}
+	return append(dst, lit...)
+}
+
+// appendSnappyCopy appends copy elements of length bytes at offset, which is less than snappyMaxChunk,
+// with 1-byte offsets where they fit and 2-byte offsets of at most 64 bytes otherwise.
+func appendSnappyCopy(dst []byte, offset int, length int) []byte {
+	for length >= 68 {
+		dst = append(dst, 63<<2|2, byte(offset), byte(offset>>8))
+		length -= 64
+	}
+	if length > 64 {
+		// leaves at least 4 bytes for the last copy
+		dst = append(dst, 59<<2|2, byte(offset), byte(offset>>8))
+		length -= 60
+	}
+	if length >= 12 || offset >= 2048 {
+		return append(dst, byte(length-1)<<2|2, byte(offset), byte(offset>>8))
+	}
+	return append(dst, byte(offset>>8)<<5|byte(length-4)<<2|1, byte(offset))
+}
@@ -0,0 +1,335 @@
+package compress
+
+import (
+	"encoding/binary"
+	"math/bits"
+)
+
+// Zstd compresses payloads as Zstandard frames (RFC 8878), as read by the zstd command or zstd.NewReader of
+// github.com/klauspost/compress/zstd. Matches are found within blocks of 128 KiB and encoded with the predefined
+// FSE tables, and literals are stored uncompressed, which trades some ratio for a compressor without dependencies.
+var Zstd Compressor = Func("zstd", func(data []byte) ([]byte, error) {
+	return appendZstdFrame(nil, data), nil
+})
+
+const (
+	zstdMagic = 0xfd2fb528
+
+	// zstdBlockSize is the maximum size of the decompressed data of a block, and the window of matches.
+	zstdBlockSize = 128 << 10
+
+	// zstdWindowDescriptor is a window of 128 KiB: 2^(10 + exponent 7), without mantissa.
+	zstdWindowDescriptor = 7 << 3
+
+	zstdBlockRaw        = 0
+	zstdBlockCompressed = 2
+
+	// zstdMinMatch is the length of the sequences hashed to find matches.
+	zstdMinMatch = 4
+)
+
+// appendZstdFrame appends data as a Zstandard frame to dst: its header with the content size, followed by blocks.
+func appendZstdFrame(dst []byte, data []byte) []byte {
+	dst = binary.LittleEndian.AppendUint32(dst, zstdMagic)
+	// Frame_Content_Size_flag 3 (8 bytes), without single segment, checksum, or dictionary
+	dst = append(dst, 3<<6, zstdWindowDescriptor)
+	dst = binary.LittleEndian.AppendUint64(dst, uint64(len(data)))
+
+	if len(data) == 0 {
+		return appendZstdBlockHeader(dst, true, zstdBlockRaw, 0)
+	}
+	for len(data) > 0 {
+		block := data[:min(len(data), zstdBlockSize)]
+		data = data[len(block):]
+		last := len(data) == 0
+
+		compressed := appendZstdBlock(nil, block)
+		if len(compressed) >= len(block) {
+			// incompressible blocks are stored as they are
+			dst = appendZstdBlockHeader(dst, last, zstdBlockRaw, len(block))
+			dst = append(dst, block...)
+			continue
+		}
+		dst = appendZstdBlockHeader(dst, last, zstdBlockCompressed, len(compressed))
+		dst = append(dst, compressed...)
+	}
+	return dst
+}
+
+func appendZstdBlockHeader(dst []byte, last bool, blockType int, size int) []byte {
+	header := uint32(size)<<3 | uint32(blockType)<<1
+	if last {
+		header |= 1
+	}
+	return append(dst, byte(header), byte(header>>8), byte(header>>16))
+}
+
+// zstdSequence is a sequence of a block: literals followed by a match.
+type zstdSequence struct {
+	literals int
+	match    int
+	offset   int
+}
+
+// appendZstdBlock appends the content of a compressed block of src to dst: the literals section with the literals
+// stored uncompressed, and the sequences section with the matches found by hashing 4-byte sequences.
+func appendZstdBlock(dst []byte, src []byte) []byte {
+	var (
+		literals  []byte
+		sequences []zstdSequence
+	)
+	const tableBits = 14
+	var table [1 << tableBits]int32 // positions + 1 by hash
+	literal := 0
+	for i := 0; i+zstdMinMatch <= len(src); {
+		v := binary.LittleEndian.Uint32(src[i:])
+		h := (v * 0x1e35a7bd) >> (32 - tableBits)
+		candidate := int(table[h]) - 1
+		table[h] = int32(i + 1)
+		if candidate < 0 || binary.LittleEndian.Uint32(src[candidate:]) != v {
+			i++
+			continue
+		}
+
+		length := zstdMinMatch
+		for i+length < len(src) && src[candidate+length] == src[i+length] {
+			length++
+		}
+		literals = append(literals, src[literal:i]...)
+		sequences = append(sequences, zstdSequence{literals: i - literal, match: length, offset: i - candidate})
+		i += length
+		literal = i
+	}
+	literals = append(literals, src[literal:]...)
+
+	dst = appendZstdLiterals(dst, literals)
+	return appendZstdSequences(dst, sequences)
+}
+
+// appendZstdLiterals appends the literals section of raw literals: its header, of 1 to 3 bytes by the size of the
+// literals, followed by them.
+func appendZstdLiterals(dst []byte, literals []byte) []byte {
+	switch n := len(literals); {
+	case n < 1<<5:
+		dst = append(dst, byte(n<<3))
+	case n < 1<<12:
+		dst = append(dst, byte(n<<4)|1<<2, byte(n>>4))
+	default:
+		dst = append(dst, byte(n<<4)|3<<2, byte(n>>4), byte(n>>12))
+	}
+	return append(dst, literals...)
+}
+
+// appendZstdSequences appends the sequences section: the number of sequences, the compression modes of their codes,
+// which are all the predefined FSE tables, and the bitstream of the sequences, written from the last sequence to
+// the first as it is read backwards.
+func appendZstdSequences(dst []byte, sequences []zstdSequence) []byte {
+	switch n := len(sequences); {
+	case n < 128:
+		dst = append(dst, byte(n))
+	case n < 0x7f00:
+		dst = append(dst, byte(n>>8)+128, byte(n))
+	default:
+		dst = append(dst, 0xff, byte(n-0x7f00), byte((n-0x7f00)>>8))
+	}
+	if len(sequences) == 0 {
+		return dst
+	}
+	dst = append(dst, 0) // predefined modes
+
+	type codes struct {
+		ll, ml, of                uint8
+		llExtra, mlExtra, ofExtra uint32
+	}
+	encode := func(s zstdSequence) codes {
+		ll, llExtra := zstdLengthCodeOf(s.literals, zstdLiteralLengthCodes[:])
+		ml, mlExtra := zstdLengthCodeOf(s.match-3, zstdMatchLengthCodes[:])
+		// offsets are written as new offsets, offset + 3, rather than as repeated ones
+		offset := uint32(s.offset + 3)
+		of := uint8(bits.Len32(offset) - 1)
+		return codes{ll: ll, ml: ml, of: of, llExtra: llExtra, mlExtra: mlExtra, ofExtra: offset - 1<<of}
+	}
+	writeExtra := func(w *zstdBitWriter, c codes) {
+		w.addBits(c.llExtra, zstdLiteralLengthCodes[c.ll].bits)
+		w.addBits(c.mlExtra, zstdMatchLengthCodes[c.ml].bits)
+		w.addBits(c.ofExtra, c.of)
+	}
+
+	var w zstdBitWriter
+	last := encode(sequences[len(sequences)-1])
+	ml := zstdMatchLengthTable.initState(last.ml)
+	of := zstdOffsetTable.initState(last.of)
+	ll := zstdLiteralLengthTable.initState(last.ll)
+	writeExtra(&w, last)
+	for i := len(sequences) - 2; i >= 0; i-- {
+		c := encode(sequences[i])
+		zstdOffsetTable.encode(&w, &of, c.of)
+		zstdMatchLengthTable.encode(&w, &ml, c.ml)
+		zstdLiteralLengthTable.encode(&w, &ll, c.ll)
+		writeExtra(&w, c)
+	}
+	w.addBits(ml, zstdMatchLengthTable.log)
+	w.addBits(of, zstdOffsetTable.log)
+	w.addBits(ll, zstdLiteralLengthTable.log)
+	return w.close(dst)
+}
+
+// zstdLengthCode is the code of a literal or match length: its baseline and the number of extra bits that follow.
+type zstdLengthCode struct {
+	baseline uint32
+	bits     uint8
+}
+
+// zstdLengthCodeOf returns the code of length and its extra bits: the largest code whose baseline is at most length.
+func zstdLengthCodeOf(length int, codes []zstdLengthCode) (uint8, uint32) {
+	code := len(codes) - 1
+	for codes[code].baseline > uint32(length) {
+		code--
+	}
+	return uint8(code), uint32(length) - codes[code].baseline
+}
+
+// zstdLiteralLengthCodes are the codes of literal lengths; lengths below 16 are their own codes.
+var zstdLiteralLengthCodes = [36]zstdLengthCode{
+	{0, 0}, {1, 0}, {2, 0}, {3, 0}, {4, 0}, {5, 0}, {6, 0}, {7, 0},
+	{8, 0}, {9, 0}, {10, 0}, {11, 0}, {12, 0}, {13, 0}, {14, 0}, {15, 0},
+	{16, 1}, {18, 1}, {20, 1}, {22, 1}, {24, 2}, {28, 2}, {32, 3}, {40, 3},
+	{48, 4}, {64, 6}, {128, 7}, {256, 8}, {512, 9}, {1024, 10}, {2048, 11}, {4096, 12},
+	{8192, 13}, {16384, 14}, {32768, 15}, {65536, 16},
+}
+
+// zstdMatchLengthCodes are the codes of match lengths less 3; lengths below 35 are their own codes.
+var zstdMatchLengthCodes = [53]zstdLengthCode{
+	{0, 0}, {1, 0}, {2, 0}, {3, 0}, {4, 0}, {5, 0}, {6, 0}, {7, 0},
+	{8, 0}, {9, 0}, {10, 0}, {11, 0}, {12, 0}, {13, 0}, {14, 0}, {15, 0},
+	{16, 0}, {17, 0}, {18, 0}, {19, 0}, {20, 0}, {21, 0}, {22, 0}, {23, 0},
+	{24, 0}, {25, 0}, {26, 0}, {27, 0}, {28, 0}, {29, 0}, {30, 0}, {31, 0},
+	{32, 1}, {34, 1}, {36, 1}, {38, 1}, {40, 2}, {44, 2}, {48, 3}, {56, 3},
+	{64, 4}, {80, 4}, {96, 5}, {128, 7}, {256, 8}, {512, 9}, {1024, 10}, {2048, 11},
+	{4096, 12}, {8192, 13}, {16384, 14}, {32768, 15}, {65536, 16},
+}
+
+// The predefined FSE tables of the codes of literal lengths, match lengths, and offsets.
+var (
+	zstdLiteralLengthTable = newZstdFSETable(6, []int16{
+		4, 3, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 1, 1, 1, 2, 2, 2, 2, 2, 2, 2, 2, 2, 3, 2, 1, 1, 1, 1, 1,
+		-1, -1, -1, -1,
+	})
+	zstdMatchLengthTable = newZstdFSETable(6, []int16{
+		1, 4, 3, 2, 2, 2, 2, 2, 2, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1,
+		1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, -1, -1, -1, -1, -1, -1, -1,
+	})
+	zstdOffsetTable = newZstdFSETable(5, []int16{
+		1, 1, 1, 1, 1, 1, 2, 2, 2, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, -1, -1, -1, -1, -1,
+	})
+)
+
+// zstdFSETable is the encoding table of an FSE distribution, of 2^log states.
+type zstdFSETable struct {
+	log uint8
+
+	// states are the states by symbol, offset by the table size, as the decoding table spreads them.
+	states []uint32
+
+	// symbols are the transforms of the symbols from the current state to the next one.
+	symbols []zstdFSESymbol
+}
+
+type zstdFSESymbol struct {
+	deltaBits  uint32
+	deltaState int32
+}
+
+// newZstdFSETable returns the encoding table of the normalized distribution counts, where -1 is a probability
+// "less than 1", spreading the symbols over the states as the decoding table does.
+func newZstdFSETable(log uint8, counts []int16) *zstdFSETable {
+	size := uint32(1) << log
+	symbols := make([]int, size)
+	cumulative := make([]uint32, len(counts)+1)
+	high := size - 1
+	for s, count := range counts {
+		if count == -1 {
+			cumulative[s+1] = cumulative[s] + 1
+			symbols[high] = s
+			high--
+		} else {
+			cumulative[s+1] = cumulative[s] + uint32(count)
+		}
+	}
+
+	step, mask, position := size>>1+size>>3+3, size-1, uint32(0)
+	for s, count := range counts {
+		for range max(count, 0) {
+			symbols[position] = s
+			position = (position + step) & mask
+			for position > high {
+				position = (position + step) & mask
+			}
+		}
+	}
+
+	t := &zstdFSETable{log: log, states: make([]uint32, size), symbols: make([]zstdFSESymbol, len(counts))}
+	next := append([]uint32(nil), cumulative...)
+	for u, s := range symbols {
+		t.states[next[s]] = size + uint32(u)
+		next[s]++
+	}
+
+	var total int32
+	for s, count := range counts {
+		switch count {
+		case -1, 1:
+			t.symbols[s] = zstdFSESymbol{deltaBits: uint32(log)<<16 - size, deltaState: total - 1}
+			total++
+		default:
+			maxBits := uint32(log) - uint32(bits.Len32(uint32(count-1))-1)
+			t.symbols[s] = zstdFSESymbol{deltaBits: maxBits<<16 - uint32(count)<<maxBits, deltaState: total - int32(count)}
+			total += int32(count)
+		}
+	}
+	return t
+}
+
+// initState returns the state encoding the last symbol of a stream, which is read first.
+func (t *zstdFSETable) initState(symbol uint8) uint32 {
+	s := t.symbols[symbol]
+	nbBits := (s.deltaBits + 1<<15) >> 16
+	value := nbBits<<16 - s.deltaBits
+	return t.states[int32(value>>nbBits)+s.deltaState]
+}
+
+// encode writes the bits of state leading to symbol, and moves state to the one of symbol.
+func (t *zstdFSETable) encode(w *zstdBitWriter, state *uint32, symbol uint8) {
+	s := t.symbols[symbol]
+	nbBits := (*state + s.deltaBits) >> 16
+	w.addBits(*state, uint8(nbBits))
+	*state = t.states[int32(*state>>nbBits)+s.deltaState]
+}
+
+// zstdBitWriter writes a bitstream read backwards: bits are appended from the lowest, and the stream is closed by a
+// 1 bit marking its end.
+type zstdBitWriter struct {
+	data  []byte
+	bits  uint64
+	count uint8
+}
+
+// addBits appends the low n bits of v.
+func (w *zstdBitWriter) addBits(v uint32, n uint8) {
+	w.bits |= uint64(v&(1<<n-1)) << w.count
+	w.count += n
+	for w.count >= 8 {
+		w.data = append(w.data, byte(w.bits))
+		w.bits >>= 8
+		w.count -= 8
+	}
+}
+
+// close appends the stream, closed by its end mark, to dst.
+func (w *zstdBitWriter) close(dst []byte) []byte {
+	w.addBits(1, 1)
+	if w.count > 0 {
+		w.data = append(w.data, byte(w.bits))
+	}
+	return append(dst, w.data...)
+}
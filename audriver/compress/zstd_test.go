@@ -0,0 +1,375 @@
+package compress_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/bits"
+	"os/exec"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mickamy/go-sql-audit-driver/audriver/compress"
+)
+
+func TestZstd(t *testing.T) {
+	t.Parallel()
+
+	for name, payload := range payloads {
+		// act
+		got, err := compress.Zstd.Compress(payload)
+
+		// assert
+		require.NoError(t, err, name)
+		decompressed, err := decodeZstdFrame(got)
+		require.NoError(t, err, name)
+		assert.True(t, bytes.Equal(payload, decompressed), name)
+	}
+
+	compressed, err := compress.Zstd.Compress(payloads["records"])
+	require.NoError(t, err)
+	assert.Less(t, len(compressed), len(payloads["records"])/10, "repetitive payloads should be compressed")
+	compressed, err = compress.Zstd.Compress(payloads["random"])
+	require.NoError(t, err)
+	assert.Less(t, len(compressed), len(payloads["random"])+100, "incompressible payloads should be stored as they are")
+}
+
+// TestZstd_Reference tests that the reference implementation decompresses batches, appended as they are to files
+func TestZstd_Reference(t *testing.T) {
+	t.Parallel()
+
+	zstd, err := exec.LookPath("zstd")
+	if err != nil {
+		t.Skip("the zstd command is not installed")
+	}
+
+	// arrange
+	var (
+		stream []byte
+		want   []byte
+	)
+	for _, name := range []string{"empty", "short", "records", "random", "repeated"} {
+		compressed, err := compress.Zstd.Compress(payloads[name])
+		require.NoError(t, err, name)
+		stream = append(stream, compressed...)
+		want = append(want, payloads[name]...)
+	}
+
+	// act
+	cmd := exec.CommandContext(t.Context(), zstd, "--decompress", "--stdout")
+	cmd.Stdin = bytes.NewReader(stream)
+	got, err := cmd.Output()
+
+	// assert
+	require.NoError(t, err)
+	assert.True(t, bytes.Equal(want, got))
+}
+
+func FuzzZstd(f *testing.F) {
+	for _, payload := range payloads {
+		// short seeds keep mutating fast
+		f.Add(payload[:min(len(payload), 4096)])
+	}
+	f.Fuzz(func(t *testing.T, payload []byte) {
+		compressed, err := compress.Zstd.Compress(payload)
+		require.NoError(t, err)
+		decompressed, err := decodeZstdFrame(compressed)
+		require.NoError(t, err)
+		assert.True(t, bytes.Equal(payload, decompressed))
+	})
+}
+
+// decodeZstdFrame decodes a Zstandard frame (RFC 8878) whose literals are raw or RLE and whose sequences use the
+// predefined FSE tables.
+func decodeZstdFrame(data []byte) ([]byte, error) {
+	if len(data) < 6 || binary.LittleEndian.Uint32(data) != 0xfd2fb528 {
+		return nil, errors.New("missing magic number")
+	}
+	descriptor := data[4]
+	singleSegment := descriptor>>5&1 == 1
+	checksum := descriptor>>2&1 == 1
+	pos := 5
+	if !singleSegment {
+		pos++
+	}
+	pos += []int{0, 1, 2, 4}[descriptor&3]
+	contentSizeBytes := []int{0, 2, 4, 8}[descriptor>>6]
+	if contentSizeBytes == 0 && singleSegment {
+		contentSizeBytes = 1
+	}
+	if len(data) < pos+contentSizeBytes {
+		return nil, errors.New("truncated frame header")
+	}
+	contentSize := -1
+	switch contentSizeBytes {
+	case 1:
+		contentSize = int(data[pos])
+	case 2:
+		contentSize = int(binary.LittleEndian.Uint16(data[pos:])) + 256
+	case 4:
+		contentSize = int(binary.LittleEndian.Uint32(data[pos:]))
+	case 8:
+		contentSize = int(binary.LittleEndian.Uint64(data[pos:]))
+	}
+	data = data[pos+contentSizeBytes:]
+
+	var (
+		out     []byte
+		offsets = [3]int{1, 4, 8}
+	)
+	for last := false; !last; {
+		if len(data) < 3 {
+			return nil, errors.New("truncated block header")
+		}
+		header := int(data[0]) | int(data[1])<<8 | int(data[2])<<16
+		last = header&1 == 1
+		size := header >> 3
+		data = data[3:]
+		switch header >> 1 & 3 {
+		case 0:
+			if len(data) < size {
+				return nil, errors.New("truncated raw block")
+			}
+			out = append(out, data[:size]...)
+		case 1:
+			if len(data) < 1 {
+				return nil, errors.New("truncated RLE block")
+			}
+			out = append(out, bytes.Repeat(data[:1], size)...)
+			size = 1
+		case 2:
+			if len(data) < size {
+				return nil, errors.New("truncated compressed block")
+			}
+			var err error
+			if out, err = decodeZstdBlock(out, data[:size], &offsets); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, errors.New("reserved block type")
+		}
+		data = data[size:]
+	}
+	if checksum {
+		data = data[4:]
+	}
+	if len(data) > 0 {
+		return nil, errors.New("trailing data")
+	}
+	if contentSize >= 0 && contentSize != len(out) {
+		return nil, fmt.Errorf("content size %d, decoded %d", contentSize, len(out))
+	}
+	return out, nil
+}
+
+// decodeZstdBlock decodes a compressed block, appending its content to out.
+func decodeZstdBlock(out []byte, block []byte, offsets *[3]int) ([]byte, error) {
+	// literals section
+	if len(block) < 1 {
+		return nil, errors.New("missing literals section")
+	}
+	literalsType, sizeFormat := block[0]&3, block[0]>>2&3
+	if literalsType > 1 {
+		return nil, errors.New("compressed literals are not expected")
+	}
+	var size, headerSize int
+	switch sizeFormat {
+	case 0, 2:
+		size, headerSize = int(block[0]>>3), 1
+	case 1:
+		size, headerSize = int(block[0]>>4)+int(block[1])<<4, 2
+	case 3:
+		size, headerSize = int(block[0]>>4)+int(block[1])<<4+int(block[2])<<12, 3
+	}
+	block = block[headerSize:]
+	var literals []byte
+	if literalsType == 0 {
+		if len(block) < size {
+			return nil, errors.New("truncated literals")
+		}
+		literals, block = block[:size], block[size:]
+	} else {
+		literals, block = bytes.Repeat(block[:1], size), block[1:]
+	}
+
+	// sequences section
+	if len(block) < 1 {
+		return nil, errors.New("missing sequences section")
+	}
+	count := int(block[0])
+	switch {
+	case count == 0:
+		return append(out, literals...), nil
+	case count < 128:
+		block = block[1:]
+	case count < 255:
+		count, block = (count-128)<<8+int(block[1]), block[2:]
+	default:
+		count, block = int(binary.LittleEndian.Uint16(block[1:]))+0x7f00, block[3:]
+	}
+	if block[0] != 0 {
+		return nil, errors.New("only predefined FSE tables are expected")
+	}
+	r, err := newBackwardBitReader(block[1:])
+	if err != nil {
+		return nil, err
+	}
+
+	llTable, ofTable, mlTable := fseDecodingTable(6, predefinedLiteralLengths), fseDecodingTable(5, predefinedOffsets), fseDecodingTable(6, predefinedMatchLengths)
+	ll, of, ml := r.read(6), r.read(5), r.read(6)
+	for i := range count {
+		ofCode, mlCode, llCode := ofTable[of].symbol, mlTable[ml].symbol, llTable[ll].symbol
+		offsetValue := 1<<ofCode + r.read(int(ofCode))
+		matchLength := matchLengthBaselines[mlCode] + r.read(matchLengthBits[mlCode])
+		literalLength := literalLengthBaselines[llCode] + r.read(literalLengthBits[llCode])
+
+		var offset int
+		if offsetValue > 3 {
+			offset = offsetValue - 3
+			offsets[0], offsets[1], offsets[2] = offset, offsets[0], offsets[1]
+		} else {
+			index := offsetValue - 1
+			if literalLength == 0 {
+				index++
+			}
+			switch index {
+			case 0:
+				offset = offsets[0]
+			case 1:
+				offset = offsets[1]
+				offsets[0], offsets[1] = offset, offsets[0]
+			case 2:
+				offset = offsets[2]
+				offsets[0], offsets[1], offsets[2] = offset, offsets[0], offsets[1]
+			default:
+				offset = offsets[0] - 1
+				offsets[0], offsets[1], offsets[2] = offset, offsets[0], offsets[1]
+			}
+		}
+
+		if literalLength > len(literals) {
+			return nil, errors.New("literal length exceeds the literals")
+		}
+		out = append(out, literals[:literalLength]...)
+		literals = literals[literalLength:]
+		if offset <= 0 || offset > len(out) {
+			return nil, fmt.Errorf("invalid offset %d", offset)
+		}
+		for range matchLength {
+			out = append(out, out[len(out)-offset])
+		}
+
+		if i < count-1 {
+			ll = llTable[ll].baseline + r.read(int(llTable[ll].bits))
+			ml = mlTable[ml].baseline + r.read(int(mlTable[ml].bits))
+			of = ofTable[of].baseline + r.read(int(ofTable[of].bits))
+		}
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	if r.pos != 0 {
+		return nil, fmt.Errorf("%d bits of the sequences left", r.pos)
+	}
+	return append(out, literals...), nil
+}
+
+var (
+	predefinedLiteralLengths = []int{
+		4, 3, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 1, 1, 1, 2, 2, 2, 2, 2, 2, 2, 2, 2, 3, 2, 1, 1, 1, 1, 1, -1, -1, -1, -1,
+	}
+	predefinedMatchLengths = []int{
+		1, 4, 3, 2, 2, 2, 2, 2, 2, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1,
+		1, 1, 1, 1, 1, 1, 1, 1, -1, -1, -1, -1, -1, -1, -1,
+	}
+	predefinedOffsets = []int{
+		1, 1, 1, 1, 1, 1, 2, 2, 2, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, -1, -1, -1, -1, -1,
+	}
+
+	literalLengthBaselines = []int{
+		0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 18, 20, 22, 24, 28, 32, 40, 48, 64, 128, 256, 512,
+		1024, 2048, 4096, 8192, 16384, 32768, 65536,
+	}
+	literalLengthBits = []int{
+		0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1, 1, 1, 1, 2, 2, 3, 3, 4, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16,
+	}
+	matchLengthBaselines = []int{
+		3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18, 19, 20, 21, 22, 23, 24, 25, 26, 27, 28, 29, 30, 31, 32,
+		33, 34, 35, 37, 39, 41, 43, 47, 51, 59, 67, 83, 99, 131, 259, 515, 1027, 2051, 4099, 8195, 16387, 32771, 65539,
+	}
+	matchLengthBits = []int{
+		0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1, 1, 1, 1, 2, 2,
+		3, 3, 4, 4, 5, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16,
+	}
+)
+
+// fseState is a state of an FSE decoding table.
+type fseState struct {
+	symbol   int
+	bits     uint8
+	baseline int
+}
+
+// fseDecodingTable builds the FSE decoding table of a normalized distribution as RFC 8878 describes it.
+func fseDecodingTable(log uint8, counts []int) []fseState {
+	size := 1 << log
+	table := make([]fseState, size)
+	high := size - 1
+	next := make([]int, len(counts))
+	for s, count := range counts {
+		if count == -1 {
+			table[high].symbol = s
+			high--
+			next[s] = 1
+		} else {
+			next[s] = count
+		}
+	}
+	position, step := 0, size>>1+size>>3+3
+	for s, count := range counts {
+		for range max(count, 0) {
+			table[position].symbol = s
+			for position = (position + step) & (size - 1); position > high; position = (position + step) & (size - 1) {
+			}
+		}
+	}
+	for i := range table {
+		x := next[table[i].symbol]
+		next[table[i].symbol]++
+		table[i].bits = log - uint8(bits.Len(uint(x))-1)
+		table[i].baseline = x<<table[i].bits - size
+	}
+	return table
+}
+
+// backwardBitReader reads a bitstream from its end mark backwards.
+type backwardBitReader struct {
+	data []byte
+	pos  int
+	err  error
+}
+
+func newBackwardBitReader(data []byte) (*backwardBitReader, error) {
+	if len(data) == 0 || data[len(data)-1] == 0 {
+		return nil, errors.New("missing end mark")
+	}
+	return &backwardBitReader{data: data, pos: (len(data)-1)*8 + bits.Len8(data[len(data)-1]) - 1}, nil
+}
+
+// read reads the next n bits, the last written first.
+func (r *backwardBitReader) read(n int) int {
+	if n > r.pos {
+		r.err = errors.New("bitstream overread")
+		r.pos = n
+	}
+	r.pos -= n
+	var v int
+	for i := range n {
+		bit := r.pos + i
+		v |= int(r.data[bit/8]>>(bit%8)&1) << i
+	}
+	return v
+}
@@ -0,0 +1,225 @@
+package audriver
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+)
+
+// driverConfig is the effective configuration of a Driver as reported by ConfigJSON.
+// Extension points such as extractors and handlers are described by their type, or by their String method
+// if they implement fmt.Stringer, as built-in table filters do.
+type driverConfig struct {
+	Dialect                 string              `json:"dialect"`
+	ReadOnly                bool                `json:"read_only"`
+	AuditTable              string              `json:"audit_table"`
+	AuditSink               string              `json:"audit_sink,omitempty"`
+	StagingTable            string              `json:"staging_table,omitempty"`
+	SessionStaging          bool                `json:"session_staging"`
+	AuditRole               string              `json:"audit_role,omitempty"`
+	Logger                  string              `json:"logger"`
+	IDGenerator             string              `json:"id_generator"`
+	OperatorIDExtractor     string              `json:"operator_id_extractor"`
+	ExecutionIDExtractor    string              `json:"execution_id_extractor"`
+	TableFilters            []string            `json:"table_filters"`
+	Actions                 []string            `json:"actions,omitempty"`
+	IgnoreSQLPatterns       []string            `json:"ignore_sql_patterns,omitempty"`
+	QueryRewriters          []string            `json:"query_rewriters,omitempty"`
+	SplitMultiRowInserts    bool                `json:"split_multi_row_inserts"`
+	StrictParsing           bool                `json:"strict_parsing"`
+	LogCorrelation          bool                `json:"log_correlation"`
+	QueryAnnotation         bool                `json:"query_annotation"`
+	SchemaResolution        []string            `json:"schema_resolution,omitempty"`
+	OperatorResolver        string              `json:"operator_resolver,omitempty"`
+	ContextSnapshotter      string              `json:"context_snapshotter,omitempty"`
+	ClientIPEnricher        string              `json:"client_ip_enricher,omitempty"`
+	ShardKeyExtractors      map[string]string   `json:"shard_key_extractors,omitempty"`
+	TableEnrichers          map[string][]string `json:"table_enrichers,omitempty"`
+	ArgCapture              bool                `json:"arg_capture"`
+	Anonymizer              string              `json:"anonymizer,omitempty"`
+	WherePredicates         bool                `json:"where_predicates"`
+	SchemaNames             bool                `json:"schema_names"`
+	SourceTables            bool                `json:"source_tables"`
+	ChangedColumns          bool                `json:"changed_columns"`
+	BackendIDs              bool                `json:"backend_ids"`
+	CommitLSN               bool                `json:"commit_lsn"`
+	RecordChecksums         bool                `json:"record_checksums"`
+	ErrorHandler            bool                `json:"error_handler"`
+	TransactionHandler      bool                `json:"transaction_handler"`
+	SampleRates             map[string]float64  `json:"sample_rates,omitempty"`
+	EnablementRate          float64             `json:"enablement_rate"`
+	LoadShedding            *loadSheddingConfig `json:"load_shedding,omitempty"`
+	OperatorRateAnomaly     *operatorRateConfig `json:"operator_rate_anomaly,omitempty"`
+	Retry                   *retryConfig        `json:"retry,omitempty"`
+	BatchWindow             *batchWindowConfig  `json:"batch_window,omitempty"`
+	AsyncLogging            *asyncLoggingConfig `json:"async_logging,omitempty"`
+	DeadLetterQueue         string              `json:"dead_letter_queue,omitempty"`
+	FlushThreshold          int                 `json:"flush_threshold,omitempty"`
+	SlowAuditThreshold      string              `json:"slow_audit_threshold,omitempty"`
+	RowEstimateThreshold    int64               `json:"row_estimate_threshold,omitempty"`
+	RowCapture              bool                `json:"row_capture"`
+	AuditStatementCacheSize int                 `json:"audit_statement_cache_size,omitempty"`
+}
+
+type loadSheddingConfig struct {
+	Latency    string   `json:"latency"`
+	Sustain    string   `json:"sustain"`
+	Tables     []string `json:"tables"`
+	SampleRate float64  `json:"sample_rate"`
+}
+
+type operatorRateConfig struct {
+	Threshold int64  `json:"threshold"`
+	Window    string `json:"window"`
+}
+
+type batchWindowConfig struct {
+	MaxRecords int    `json:"max_records,omitempty"`
+	MaxBytes   int    `json:"max_bytes,omitempty"`
+	MaxLatency string `json:"max_latency,omitempty"`
+}
+
+type asyncLoggingConfig struct {
+	QueueSize    int    `json:"queue_size"`
+	Workers      int    `json:"workers"`
+	Backpressure string `json:"backpressure"`
+}
+
+type retryConfig struct {
+	MaxRetries int    `json:"max_retries"`
+	Backoff    string `json:"backoff"`
+	MaxBackoff string `json:"max_backoff,omitempty"`
+}
+
+// ConfigJSON returns the effective configuration of the driver as JSON, e.g. for a /debug endpoint,
+// so that operators can introspect how auditing is configured at runtime.
+// Functions and interfaces supplied with options are described by their type, or by their String method if any.
+func (d *Driver) ConfigJSON() ([]byte, error) {
+	b := d.builder
+	cfg := driverConfig{
+		Dialect:                 string(b.dialect),
+		ReadOnly:                d.readOnly,
+		AuditTable:              b.auditTable,
+		AuditSink:               describe(b.sink),
+		DeadLetterQueue:         describe(b.deadLetterQueue),
+		StagingTable:            b.stagingTable,
+		SessionStaging:          b.sessionStaging,
+		AuditRole:               d.auditRole,
+		Logger:                  describe(d.logger),
+		IDGenerator:             describe(b.idGenerator),
+		OperatorIDExtractor:     describe(b.operatorIDExtractor),
+		ExecutionIDExtractor:    describe(b.executionIDExtractor),
+		TableFilters:            make([]string, 0, len(b.tableFilters)),
+		SplitMultiRowInserts:    b.splitMultiRowInserts,
+		StrictParsing:           b.strictParsing,
+		LogCorrelation:          b.logCorrelation,
+		QueryAnnotation:         b.queryAnnotation,
+		ContextSnapshotter:      describe(b.contextSnapshotter),
+		ClientIPEnricher:        describe(b.clientIPEnricher),
+		ArgCapture:              b.argCapture,
+		Anonymizer:              describe(b.anonymizer),
+		WherePredicates:         b.wherePredicates,
+		SchemaNames:             b.schemaNames,
+		SourceTables:            b.sourceTables,
+		ChangedColumns:          b.changedColumns,
+		BackendIDs:              b.backendIDs,
+		CommitLSN:               b.commitLSN,
+		RecordChecksums:         b.checksums,
+		ErrorHandler:            b.errorHandler != nil,
+		TransactionHandler:      b.transactionHandler != nil,
+		SampleRates:             b.sampleRates,
+		EnablementRate:          d.enablementRate,
+		FlushThreshold:          b.flushThreshold,
+		RowEstimateThreshold:    b.rowEstimateThreshold,
+		RowCapture:              b.rowCapture,
+		AuditStatementCacheSize: d.auditStatementCacheSize,
+	}
+	for _, filter := range b.tableFilters {
+		cfg.TableFilters = append(cfg.TableFilters, describe(filter))
+	}
+	for action, enabled := range b.actions {
+		if enabled {
+			cfg.Actions = append(cfg.Actions, action.String())
+		}
+	}
+	slices.Sort(cfg.Actions)
+	for _, pattern := range b.ignoreSQLPatterns {
+		cfg.IgnoreSQLPatterns = append(cfg.IgnoreSQLPatterns, pattern.String())
+	}
+	for _, rewriter := range b.rewriters {
+		cfg.QueryRewriters = append(cfg.QueryRewriters, describe(rewriter))
+	}
+	if d.schemaResolver != nil {
+		cfg.SchemaResolution = append([]string{}, d.schemaResolver.searchPath...)
+	}
+	if b.operators != nil {
+		cfg.OperatorResolver = describe(b.operators.resolver)
+	}
+	if len(b.shardKeyExtractors) > 0 {
+		cfg.ShardKeyExtractors = make(map[string]string, len(b.shardKeyExtractors))
+		for _, table := range slices.Sorted(maps.Keys(b.shardKeyExtractors)) {
+			cfg.ShardKeyExtractors[table] = describe(b.shardKeyExtractors[table])
+		}
+	}
+	if len(b.tableEnrichers) > 0 {
+		cfg.TableEnrichers = make(map[string][]string, len(b.tableEnrichers))
+		for table, enrichers := range b.tableEnrichers {
+			for _, enricher := range enrichers {
+				cfg.TableEnrichers[table] = append(cfg.TableEnrichers[table], describe(enricher))
+			}
+		}
+	}
+	if s := b.loadShedder; s != nil {
+		cfg.LoadShedding = &loadSheddingConfig{
+			Latency:    s.cfg.Latency.String(),
+			Sustain:    s.cfg.Sustain.String(),
+			Tables:     s.cfg.Tables,
+			SampleRate: s.cfg.SampleRate,
+		}
+	}
+	if r := b.operatorRates; r != nil {
+		cfg.OperatorRateAnomaly = &operatorRateConfig{Threshold: r.cfg.Threshold, Window: r.cfg.Window.String()}
+	}
+	if r := b.retry; r != nil {
+		cfg.Retry = &retryConfig{MaxRetries: r.MaxRetries, Backoff: r.Backoff.String()}
+		if r.MaxBackoff > 0 {
+			cfg.Retry.MaxBackoff = r.MaxBackoff.String()
+		}
+	}
+	if w := d.batcher; w != nil {
+		cfg.BatchWindow = &batchWindowConfig{MaxRecords: w.window.maxRecords, MaxBytes: w.window.maxBytes}
+		if w.window.maxLatency > 0 {
+			cfg.BatchWindow.MaxLatency = w.window.maxLatency.String()
+		}
+	}
+	if w := d.async; w != nil {
+		cfg.AsyncLogging = &asyncLoggingConfig{
+			QueueSize:    b.asyncLogging.queueSize,
+			Workers:      len(w.queues),
+			Backpressure: string(w.policy),
+		}
+	}
+	if b.slowAuditThreshold > 0 {
+		cfg.SlowAuditThreshold = b.slowAuditThreshold.String()
+	}
+
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode configuration: %w", err)
+	}
+	return data, nil
+}
+
+// describe describes an extension point supplied with an option: by its String method if it has one,
+// or else by its type. It returns an empty string for nil.
+func describe(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case fmt.Stringer:
+		return v.String()
+	default:
+		return fmt.Sprintf("%T", v)
+	}
+}
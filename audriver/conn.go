@@ -2,98 +2,347 @@ package audriver
 
 import (
 	"context"
+	"database/sql"
 	"database/sql/driver"
 	"errors"
 	"fmt"
-	"strings"
+	"strconv"
+	"sync"
+	"time"
 )
 
 type Conn struct {
 	driver.Conn
-	builder  *databaseModificationBuilder
-	readOnly bool
-	logger   Logger
+	builder   *databaseModificationBuilder
+	readOnly  bool
+	auditRole string
+	logger    Logger
+
+	schemaResolver *schemaResolver
+
+	// stmts caches prepared audit inserts, if enabled.
+	stmts *stmtCache
+
+	// shard is the name of the shard of the connection if it is opened by a ShardRouter.
+	shard string
+
+	// unaudited reports whether auditing of the connection is disabled by WithEnablementRate.
+	unaudited bool
+
+	// backend caches the backend PID of the connection if backend IDs are recorded.
+	backend backendPID
+
+	// moves tracks moves of audit records staged with WithSessionStaging; sessionStagingCreated reports whether the
+	// staging table exists in the session, and sessionStagingPending whether records may be left to move.
+	moves                 sync.WaitGroup
+	sessionStagingCreated bool
+	sessionStagingPending bool
+
+	// tx is the transaction in progress on this connection, if any.
+	// database/sql executes statements of a transaction on the connection, not on the driver.Tx.
+	tx *loggingTx
 }
 
 func (c *Conn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	c.awaitSessionStaging()
+
 	opts.ReadOnly = c.readOnly
-	conn, ok := c.Conn.(driver.ConnBeginTx)
-	if !ok {
-		return nil, errors.New("connection does not support BeginTx")
+	tx, err := beginTx(ctx, c.Conn, opts)
+	if err != nil {
+		return nil, err
 	}
 
 	buf := &buffer{}
 
-	tx, err := conn.BeginTx(ctx, opts)
+	c.tx = &loggingTx{
+		_ctx:    ctx,
+		Tx:      tx,
+		begunAt: time.Now(),
+		conn: &txConn{
+			Conn:           c.Conn,
+			buf:            buf,
+			builder:        c.builder,
+			readOnly:       c.readOnly,
+			unaudited:      !c.auditing(ctx),
+			schemaResolver: c.schemaResolver,
+			shard:          c.shard,
+			backend:        &c.backend,
+		},
+		owner:     c,
+		buf:       buf,
+		auditRole: c.auditRole,
+		logger:    c.logger,
+	}
+	c.tx.conn.flush = c.tx.log
+
+	return c.tx, nil
+}
+
+// Begin starts an audited transaction without a context, for callers that do not use BeginTx as database/sql does.
+// Audit records of the transaction are flushed with the context of its last audited statement.
+func (c *Conn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+// beginTx starts a transaction on conn, falling back to the legacy Begin for connections that do not implement
+// driver.ConnBeginTx, as database/sql does. Such transactions support neither isolation levels nor read-only
+// transactions, and ctx is only checked once Begin returns, rolling the transaction back if it is done.
+func beginTx(ctx context.Context, conn driver.Conn, opts driver.TxOptions) (driver.Tx, error) {
+	if conn, ok := conn.(driver.ConnBeginTx); ok {
+		return conn.BeginTx(ctx, opts)
+	}
+	if opts.Isolation != driver.IsolationLevel(sql.LevelDefault) {
+		return nil, fmt.Errorf("%w: non-default isolation levels are not supported", ErrUnsupportedConn)
+	}
+	if opts.ReadOnly {
+		return nil, fmt.Errorf("%w: read-only transactions are not supported", ErrUnsupportedConn)
+	}
+
+	//lint:ignore SA1019 the connection implements nothing else
+	tx, err := conn.Begin()
 	if err != nil {
 		return nil, err
 	}
-
-	return &loggingTx{
-		_ctx: ctx,
-		Tx:   tx,
-		conn: &txConn{
-			Conn:     c.Conn,
-			buf:      buf,
-			builder:  c.builder,
-			readOnly: c.readOnly,
-		},
-		buf:    buf,
-		logger: c.logger,
-	}, nil
+	if err := ctx.Err(); err != nil {
+		_ = tx.Rollback()
+		return nil, err
+	}
+	return tx, nil
 }
 
 // ExecContext implements the ExecContext method for the audit connection.
 // It logs database modifications if the SQL statement is a modifying statement.
 func (c *Conn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
-	execCtx, ok := c.Conn.(driver.ExecerContext)
-	if !ok {
-		return nil, errors.New("connection does not support ExecContext")
+	c.awaitSessionStaging()
+	if c.tx != nil {
+		return c.tx.conn.ExecContext(ctx, query, args)
+	}
+
+	query, err := c.builder.rewrite(ctx, query)
+	if err != nil {
+		return nil, err
 	}
 
-	if c.readOnly {
-		return execCtx.ExecContext(ctx, query, args)
+	if c.readOnly || !c.auditing(ctx) {
+		return execContext(ctx, c.Conn, c.builder.annotate(ctx, query, nil), args)
 	}
 
-	// modifying SQL statements outside of transactions are logged directly
-	mod, err := c.builder.build(ctx, query, args)
+	mods, err := c.builder.build(ctx, query, args)
 	if err != nil {
+		c.builder.handleError(ctx, err, ErrorStageBuild, nil)
 		return nil, fmt.Errorf("failed to build database modification: %w", err)
 	}
-	if mod != nil {
-		if err := c.logModification(ctx, *mod); err != nil {
-			return nil, fmt.Errorf("failed to log database modification: %w", err)
+	tagShard(mods, c.shard)
+	if c.builder.backendIDs && len(mods) > 0 {
+		if err := recordBackendPID(ctx, c.Conn, &c.backend, mods); err != nil {
+			c.builder.handleError(ctx, err, ErrorStageBuild, mods)
 		}
 	}
+	if err := c.builder.shedBackpressure(ctx, mods); err != nil {
+		return nil, err
+	}
+	if err := c.builder.guardRowEstimate(ctx, c.Conn, false, query, args, mods); err != nil {
+		return nil, err
+	}
+	if mods, err = c.builder.applyAuditBudget(ctx, mods); err != nil {
+		return nil, err
+	}
 
-	return execCtx.ExecContext(ctx, query, args)
+	if len(mods) == 0 {
+		res, _, err := c.builder.execCapturingRows(ctx, c.Conn, query, args, mods)
+		return res, err
+	}
+
+	if c.builder.bufferedAfterCommit() {
+		// the statement is committed once it is executed, before its records are handed off
+		return c.execLogging(ctx, query, args, mods, false)
+	}
+
+	// modifying SQL statements outside of transactions are executed and logged in a transaction of their own,
+	// so that they are not applied without their audit records while their results are available to the records
+	tx, err := beginTx(ctx, c.Conn, driver.TxOptions{})
+	if err != nil {
+		c.builder.handleError(ctx, err, ErrorStageExecute, mods)
+		return nil, fmt.Errorf("failed to begin audited statement: %w", err)
+	}
+	res, err := c.execLogging(ctx, query, args, mods, true)
+	if err != nil {
+		_ = tx.Rollback()
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		c.builder.handleError(ctx, err, ErrorStageExecute, mods)
+		return nil, fmt.Errorf("failed to commit audited statement: %w", err)
+	}
+
+	return res, nil
 }
 
-// logModification inserts a single database modification directly into the database.
-func (c *Conn) logModification(ctx context.Context, mod DatabaseModification) error {
-	execCtx, ok := c.Conn.(driver.ExecerContext)
+// execLogging executes a modifying statement and logs its modifications directly, inTx reporting whether the statement
+// is executed in a transaction of its own.
+func (c *Conn) execLogging(ctx context.Context, query string, args []driver.NamedValue, mods []DatabaseModification, inTx bool) (driver.Result, error) {
+	res, converted, err := c.builder.execCapturingRows(ctx, c.Conn, query, args, mods)
+	if err != nil {
+		c.builder.handleError(ctx, err, ErrorStageExecute, mods)
+		return nil, err
+	}
+	if converted != nil {
+		c.builder.reformat(mods, query, converted)
+	}
+
+	captureResult(mods, res)
+	if err := resolveSchema(ctx, c.Conn, c.schemaResolver, mods); err != nil {
+		c.builder.handleError(ctx, err, ErrorStageBuild, mods)
+		return nil, err
+	}
+	assignAutocommitIDs(ctx, mods)
+	if job := jobFromContext(ctx); job != nil {
+		job.add(mods)
+		return res, nil
+	}
+	if c.builder.bufferedAfterCommit() {
+		if err := c.logModifications(ctx, mods, inTx); err != nil {
+			c.builder.handOffFailed(ctx, err, mods)
+		}
+		return res, nil
+	}
+	if err := c.logModifications(ctx, mods, inTx); err != nil {
+		c.builder.handleError(ctx, err, ErrorStageFlush, mods)
+		return nil, fmt.Errorf("failed to log database modification: %w", err)
+	}
+
+	return res, nil
+}
+
+// QueryContext executes queries on the underlying connection.
+// It returns driver.ErrSkip if the underlying connection does not implement driver.QueryerContext,
+// so that database/sql falls back to a prepared statement.
+func (c *Conn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	c.awaitSessionStaging()
+	if c.tx != nil {
+		return c.tx.conn.QueryContext(ctx, query, args)
+	}
+
+	query, err := c.builder.rewrite(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	queryCtx, ok := c.Conn.(driver.QueryerContext)
 	if !ok {
-		return errors.New("connection does not support ExecContext for direct logging")
-	}
-
-	_, err := execCtx.ExecContext(
-		ctx,
-		`INSERT INTO database_modifications (id, operator_id, execution_id, table_name, action, sql, modified_at) VALUES ($1, $2, $3, $4, $5, $6, $7)`,
-		[]driver.NamedValue{
-			{Name: "id", Value: mod.ID},
-			{Name: "operator_id", Value: mod.OperatorID},
-			{Name: "execution_id", Value: mod.ExecutionID},
-			{Name: "table_name", Value: mod.TableName},
-			{Name: "action", Value: mod.Action.String()},
-			{Name: "sql", Value: mod.SQL},
-			{Name: "modified_at", Value: mod.ModifiedAt},
-		},
-	)
+		return nil, driver.ErrSkip
+	}
+	return queryCtx.QueryContext(ctx, c.builder.annotate(ctx, query, nil), args)
+}
 
+// PrepareContext prepares statements on the underlying connection.
+func (c *Conn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	c.awaitSessionStaging()
+	query, err := c.builder.rewrite(ctx, query)
 	if err != nil {
-		c.logger.Log(ctx, mod)
+		return nil, err
+	}
+	query = c.builder.annotate(ctx, query, nil)
+
+	if prepareCtx, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		return prepareCtx.PrepareContext(ctx, query)
 	}
+	return c.Conn.Prepare(query)
+}
+
+// Close closes the cached audit insert statements and the underlying connection.
+// Audit records left staged by WithSessionStaging are moved first.
+func (c *Conn) Close() error {
+	c.awaitSessionStaging()
+	if c.sessionStagingPending {
+		ctx := context.Background()
+		if err := c.moveSessionStagingNow(ctx); err != nil {
+			c.builder.handleError(ctx, err, ErrorStageFlush, nil)
+		}
+	}
+	c.stmts.close()
+	return c.Conn.Close()
+}
+
+// Ping implements driver.Pinger by pinging the underlying connection if it supports it.
+func (c *Conn) Ping(ctx context.Context) error {
+	c.awaitSessionStaging()
+	if pinger, ok := c.Conn.(driver.Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+	return nil
+}
+
+// ResetSession implements driver.SessionResetter by resetting the underlying connection if it supports it.
+func (c *Conn) ResetSession(ctx context.Context) error {
+	c.awaitSessionStaging()
+	if resetter, ok := c.Conn.(driver.SessionResetter); ok {
+		return resetter.ResetSession(ctx)
+	}
+	return nil
+}
+
+// IsValid implements driver.Validator by validating the underlying connection if it supports it.
+func (c *Conn) IsValid() bool {
+	if validator, ok := c.Conn.(driver.Validator); ok {
+		return validator.IsValid()
+	}
+	return true
+}
 
+// CheckNamedValue implements driver.NamedValueChecker by delegating to the underlying connection,
+// so that driver-specific argument types are accepted. It returns driver.ErrSkip if that is not supported.
+func (c *Conn) CheckNamedValue(nv *driver.NamedValue) error {
+	if checker, ok := c.Conn.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(nv)
+	}
+	return driver.ErrSkip
+}
+
+// logModifications inserts the database modifications of a single statement directly into the database.
+// If inTx, the statement is executed in a transaction of its own, and retried inserts are each made behind a savepoint,
+// as PostgreSQL aborts the transaction on the first failed insert.
+func (c *Conn) logModifications(ctx context.Context, mods []DatabaseModification, inTx bool) error {
+	c.awaitSessionStaging()
+	write := func() error {
+		return writeModifications(ctx, c.Conn, c.builder, c.builder.writeTable(), c.auditRole, c.stmts, mods)
+	}
+	if inTx && c.builder.retry != nil && c.builder.sink == nil {
+		insert := write
+		write = func() error {
+			return withSavepoint(ctx, c.Conn, auditRetrySavepoint, insert)
+		}
+	}
+	if err := c.builder.retryWrite(ctx, write); err != nil {
+		return err
+	}
+	c.builder.stats.written(len(mods))
+	c.builder.trackOperatorRates(ctx, mods)
+
+	c.builder.notifyLogger(ctx, c.logger, mods)
+
+	return nil
+}
+
+// auditRetrySavepoint is the savepoint retried audit inserts of statements executed in a transaction of their own are
+// made behind.
+const auditRetrySavepoint = "audriver_audit_insert"
+
+// withSavepoint calls fn behind the savepoint name, rolling back to it if fn fails, so that the failure does not abort
+// the transaction conn is in.
+func withSavepoint(ctx context.Context, conn driver.Conn, name string, fn func() error) error {
+	if _, err := execContext(ctx, conn, "SAVEPOINT "+name, nil); err != nil {
+		return fmt.Errorf("failed to create savepoint: %w", err)
+	}
+	err := fn()
+	if err != nil {
+		if _, rollbackErr := execContext(ctx, conn, "ROLLBACK TO SAVEPOINT "+name, nil); rollbackErr != nil {
+			return errors.Join(err, fmt.Errorf("failed to roll back to savepoint: %w", rollbackErr))
+		}
+	}
+	if _, releaseErr := execContext(ctx, conn, "RELEASE SAVEPOINT "+name, nil); releaseErr != nil {
+		return errors.Join(err, fmt.Errorf("failed to release savepoint: %w", releaseErr))
+	}
 	return err
 }
 
@@ -103,147 +352,509 @@ type txConn struct {
 	buf      *buffer
 	builder  *databaseModificationBuilder
 	readOnly bool
+
+	// unaudited reports whether auditing of the transaction is disabled by WithEnablementRate or WithAuditEnabled.
+	unaudited bool
+
+	schemaResolver *schemaResolver
+	shard          string
+
+	// backend is the backend PID cache of the connection, and transactionID the ID of the transaction once queried.
+	backend       *backendPID
+	transactionID int64
+
+	// idempotentIDs numbers modifications with an idempotency key within the transaction.
+	idempotentIDs idempotentIDs
+
+	// lastCtx is the context of the last statement that buffered modifications.
+	lastCtx context.Context
+
+	// flush writes buffered modifications within the transaction once the flush threshold is reached.
+	flush func(ctx context.Context, mods []DatabaseModification) error
+
+	// statements, rowsAffected, modifications, and executionID summarize the transaction for the transaction handler.
+	statements    int
+	rowsAffected  int64
+	modifications int
+	executionID   string
 }
 
 // ExecContext executes SQL statements within a transaction.
 // It builds a DatabaseModification from the SQL statement and arguments, and buffers it for later logging.
 func (tc *txConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
-	execCtx, ok := tc.Conn.(driver.ExecerContext)
-	if !ok {
-		return nil, errors.New("connection does not support ExecContext")
+	query, err := tc.builder.rewrite(ctx, query)
+	if err != nil {
+		return nil, err
 	}
 
-	if tc.readOnly {
-		return execCtx.ExecContext(ctx, query, args)
+	if tc.readOnly || tc.unaudited {
+		return execContext(ctx, tc.Conn, tc.builder.annotate(ctx, query, nil), args)
 	}
 
-	mod, err := tc.builder.build(ctx, query, args)
+	mods, err := tc.builder.build(ctx, query, args)
 	if err != nil {
+		tc.builder.handleError(ctx, err, ErrorStageBuild, nil)
 		return nil, fmt.Errorf("failed to build database modification: %w", err)
 	}
+	tagShard(mods, tc.shard)
+	if tc.builder.backendIDs && len(mods) > 0 {
+		if err := tc.recordTransactionID(ctx, mods); err != nil {
+			tc.builder.handleError(ctx, err, ErrorStageBuild, mods)
+		}
+	}
+	if err := tc.builder.shedBackpressure(ctx, mods); err != nil {
+		return nil, err
+	}
+	if err := tc.builder.guardRowEstimate(ctx, tc.Conn, true, query, args, mods); err != nil {
+		return nil, err
+	}
+	if mods, err = tc.builder.applyAuditBudget(ctx, mods); err != nil {
+		return nil, err
+	}
 
-	res, err := execCtx.ExecContext(ctx, query, args)
+	res, converted, err := tc.builder.execCapturingRows(ctx, tc.Conn, query, args, mods)
 	if err != nil {
+		if len(mods) > 0 {
+			tc.builder.handleError(ctx, err, ErrorStageExecute, mods)
+		}
 		return res, err
 	}
-	if mod != nil {
-		tc.buf.add(*mod)
+	if converted != nil {
+		tc.builder.reformat(mods, query, converted)
+	}
+	tc.count(res, mods)
+	if len(mods) > 0 {
+		captureResult(mods, res)
+		if err := resolveSchema(ctx, tc.Conn, tc.schemaResolver, mods); err != nil {
+			tc.builder.handleError(ctx, err, ErrorStageBuild, mods)
+			return nil, err
+		}
+		if hasIdempotencyKey(mods) {
+			if tc.idempotentIDs == nil {
+				tc.idempotentIDs = idempotentIDs{}
+			}
+			tc.idempotentIDs.assign(mods)
+		}
+		tc.buf.add(mods...)
+		tc.lastCtx = ctx
+		if err := tc.flushIfFull(ctx); err != nil {
+			return nil, err
+		}
 	}
 
 	return res, nil
 }
 
+// count records an executed statement and its modifications in the transaction summary.
+func (tc *txConn) count(res driver.Result, mods []DatabaseModification) {
+	tc.statements++
+	if res != nil {
+		if n, err := res.RowsAffected(); err == nil {
+			tc.rowsAffected += n
+		}
+	}
+	tc.modifications += len(mods)
+	if tc.executionID == "" && len(mods) > 0 {
+		tc.executionID = mods[0].ExecutionID
+	}
+}
+
+// flushIfFull writes the buffered modifications into the transaction if the flush threshold is reached,
+// bounding the memory held by large transactions. The writes are committed or rolled back with the transaction.
+// Records of batch windows and async logging are held until commit, as they cannot be rolled back.
+func (tc *txConn) flushIfFull(ctx context.Context) error {
+	if tc.builder.flushThreshold <= 0 || tc.buf.len() < tc.builder.flushThreshold || tc.builder.bufferedAfterCommit() {
+		return nil
+	}
+
+	mods := tc.buf.drain()
+	defer releaseModifications(mods)
+
+	if err := tc.flush(ctx, mods); err != nil {
+		tc.builder.handleError(ctx, err, ErrorStageFlush, mods)
+		return &FlushError{Err: err}
+	}
+	return nil
+}
+
 // QueryContext executes read-only queries within a transaction.
+// It returns driver.ErrSkip if the underlying connection does not implement driver.QueryerContext.
 func (tc *txConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	query, err := tc.builder.rewrite(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
 	queryCtx, ok := tc.Conn.(driver.QueryerContext)
 	if !ok {
-		return nil, errors.New("connection does not support QueryContext")
+		return nil, driver.ErrSkip
 	}
-	return queryCtx.QueryContext(ctx, query, args)
+	return queryCtx.QueryContext(ctx, tc.builder.annotate(ctx, query, nil), args)
 }
 
 // PrepareContext prepares statements within a transaction.
 func (tc *txConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
-	prepareCtx, ok := tc.Conn.(driver.ConnPrepareContext)
-	if !ok {
-		return nil, errors.New("connection does not support PrepareContext")
+	query, err := tc.builder.rewrite(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	query = tc.builder.annotate(ctx, query, nil)
+
+	if prepareCtx, ok := tc.Conn.(driver.ConnPrepareContext); ok {
+		return prepareCtx.PrepareContext(ctx, query)
 	}
-	return prepareCtx.PrepareContext(ctx, query)
+	return tc.Conn.Prepare(query)
 }
 
 // loggingTx is a wrapper around driver.Tx that logs database modifications within a transaction.
 type loggingTx struct {
 	_ctx context.Context
 	driver.Tx
-	conn   *txConn
-	buf    *buffer
-	logger Logger
+	begunAt   time.Time
+	conn      *txConn
+	owner     *Conn
+	buf       *buffer
+	auditRole string
+	logger    Logger
+
+	// sessionStaged reports whether audit records of the transaction are staged with WithSessionStaging.
+	sessionStaged bool
+
+	// commitLSN is the WAL insert location after the transaction was committed, if captured.
+	commitLSN string
 }
 
-func (tx *loggingTx) ctx() context.Context {
-	if tx._ctx != nil {
-		return tx._ctx
+// ctx returns the context the buffered modifications are flushed and the transaction is summarized with:
+// the context of BeginTx, unless it carries neither an operator nor an execution ID, e.g. for db.Begin or drivers calling
+// Begin, in which case the context of the last statement that buffered modifications is used.
+// Values and the deadline of that statement context are kept, but not its cancellation, as it usually ends with the
+// statement. The returned function must be called once the context is no longer used.
+func (tx *loggingTx) ctx() (context.Context, context.CancelFunc) {
+	ctx := tx._ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if hasAuditValues(ctx) || tx.conn.lastCtx == nil {
+		return ctx, func() {}
+	}
+	last := context.WithoutCancel(tx.conn.lastCtx)
+	if deadline, ok := tx.conn.lastCtx.Deadline(); ok {
+		return context.WithDeadline(last, deadline)
+	}
+	return last, func() {}
+}
+
+// hasAuditValues reports whether ctx carries an operator or execution ID.
+func hasAuditValues(ctx context.Context) bool {
+	if _, err := GetOperatorID(ctx); err == nil {
+		return true
+	}
+	_, err := GetExecutionID(ctx)
+	return err == nil
+}
+
+// release detaches the transaction from its connection once it is finished.
+func (tx *loggingTx) release() {
+	if tx.owner != nil && tx.owner.tx == tx {
+		tx.owner.tx = nil
 	}
-	return context.Background()
 }
 
 // Commit commits the transaction and flushes any buffered logs to the database.
 func (tx *loggingTx) Commit() error {
+	defer tx.release()
+
 	modifications := tx.buf.drain()
-	ctx := tx.ctx()
-	if len(modifications) > 0 {
+	defer releaseModifications(modifications)
+
+	ctx, cancel := tx.ctx()
+	defer cancel()
+	afterCommit := tx.conn.builder.bufferedAfterCommit()
+	if len(modifications) > 0 && !afterCommit {
 		if err := tx.log(ctx, modifications); err != nil {
+			tx.conn.builder.handleError(ctx, err, ErrorStageFlush, modifications)
+			tx.summarize(ctx, TransactionFailed)
 			if rollbackErr := tx.Tx.Rollback(); rollbackErr != nil {
-				return fmt.Errorf("failed to rollback after audriver logging error: %v (original error: %w)", rollbackErr, err)
+				return errors.Join(&FlushError{Err: err}, &RollbackError{Err: rollbackErr})
 			}
-			return fmt.Errorf("failed to flush logs in transaction: %w", err)
+			return &FlushError{Err: err}
 		}
 	}
 
-	if err := ctx.Err(); err != nil {
+	if tx._ctx != nil && tx._ctx.Err() != nil {
+		tx.summarize(ctx, TransactionFailed)
 		_ = tx.Tx.Rollback()
-		return err
+		return tx._ctx.Err()
 	}
 
-	return tx.Tx.Commit()
+	if err := tx.Tx.Commit(); err != nil {
+		tx.summarize(ctx, TransactionFailed)
+		return err
+	}
+	tx.captureCommitLSN(ctx)
+	if afterCommit {
+		if err := tx.log(ctx, modifications); err != nil {
+			tx.conn.builder.handOffFailed(ctx, err, modifications)
+		}
+	}
+	if tx.sessionStaged {
+		tx.owner.moveSessionStaging()
+	}
+	tx.summarize(ctx, TransactionCommitted)
+	return nil
 }
 
 // Rollback rolls back the transaction and drains the buffer.
 func (tx *loggingTx) Rollback() error {
-	_ = tx.buf.drain()
+	defer tx.release()
+
+	releaseModifications(tx.buf.drain())
+	ctx, cancel := tx.ctx()
+	defer cancel()
+	tx.summarize(ctx, TransactionRolledBack)
 	return tx.Tx.Rollback()
 }
 
+// summarize passes the summary of the finished transaction to the transaction handler, if any.
+func (tx *loggingTx) summarize(ctx context.Context, outcome TransactionOutcome) {
+	tx.conn.builder.handleTransaction(ctx, TransactionSummary{
+		ExecutionID:   tx.conn.executionID,
+		Outcome:       outcome,
+		Duration:      time.Since(tx.begunAt),
+		Statements:    tx.conn.statements,
+		RowsAffected:  tx.conn.rowsAffected,
+		Modifications: tx.conn.modifications,
+		CommitLSN:     tx.commitLSN,
+	})
+}
+
+// captureCommitLSN queries the WAL insert location after a transaction with audited modifications was committed,
+// if enabled. Failures are passed to the error handler, as the transaction has already been committed.
+func (tx *loggingTx) captureCommitLSN(ctx context.Context) {
+	if !tx.conn.builder.commitLSN || tx.conn.modifications == 0 {
+		return
+	}
+	values, err := queryRow(ctx, tx.conn.Conn, "SELECT pg_current_wal_insert_lsn()", nil)
+	if err != nil {
+		tx.conn.builder.handleError(ctx, fmt.Errorf("failed to query commit LSN: %w", err), ErrorStageFlush, nil)
+		return
+	}
+	if len(values) > 0 {
+		tx.commitLSN = asString(values[0])
+	}
+}
+
 // log inserts all buffered database modifications in a single batch operation.
 func (tx *loggingTx) log(ctx context.Context, modifications []DatabaseModification) error {
 	if len(modifications) == 0 {
 		return nil
 	}
 
-	execCtx, ok := tx.Tx.(driver.ExecerContext)
-	if !ok {
-		return errors.New("transaction does not support ExecContext for logging")
-	}
-
-	valuesClauses := make([]string, len(modifications))
-	args := make([]driver.NamedValue, 0, len(modifications)*7)
-
-	for i, mod := range modifications {
-		baseIndex := i * 7
-		valuesClauses[i] = fmt.Sprintf("($%d, $%d, $%d, $%d, $%d, $%d, $%d)",
-			baseIndex+1, baseIndex+2, baseIndex+3, baseIndex+4, baseIndex+5, baseIndex+6, baseIndex+7)
-		args = append(args,
-			driver.NamedValue{Ordinal: baseIndex + 1, Value: mod.ID},
-			driver.NamedValue{Ordinal: baseIndex + 2, Value: mod.OperatorID},
-			driver.NamedValue{Ordinal: baseIndex + 3, Value: mod.ExecutionID},
-			driver.NamedValue{Ordinal: baseIndex + 4, Value: mod.TableName},
-			driver.NamedValue{Ordinal: baseIndex + 5, Value: mod.Action.String()},
-			driver.NamedValue{Ordinal: baseIndex + 6, Value: mod.SQL},
-			driver.NamedValue{Ordinal: baseIndex + 7, Value: mod.ModifiedAt},
-		)
-	}
-
-	query := fmt.Sprintf(
-		`INSERT INTO database_modifications (id, operator_id, execution_id, table_name, action, sql, modified_at) VALUES %s`,
-		strings.Join(valuesClauses, ", "),
+	table := tx.conn.builder.writeTable()
+	if tx.conn.builder.sessionStaging && tx.conn.builder.sink == nil {
+		if err := tx.createSessionStaging(ctx); err != nil {
+			return err
+		}
+		table = sessionStagingTable
+	}
+	if err := writeModifications(ctx, tx.conn.Conn, tx.conn.builder, table, tx.auditRole, tx.owner.stmts, modifications); err != nil {
+		return fmt.Errorf("failed to batch insert database modifications: %w", err)
+	}
+	tx.conn.builder.stats.written(len(modifications))
+	tx.conn.builder.trackOperatorRates(ctx, modifications)
+
+	tx.conn.builder.notifyLogger(ctx, tx.logger, modifications)
+
+	return nil
+}
+
+// writeModifications inserts modifications into table on conn as the given audit role,
+// using prepared statements of stmts for batches small enough to be cached, or writes them to the sink if any.
+func writeModifications(ctx context.Context, conn driver.Conn, b *databaseModificationBuilder, table string, role string, stmts *stmtCache, modifications []DatabaseModification) error {
+	if len(modifications) > maxCachedBatchSize {
+		stmts = nil
+	}
+
+	defer func(start time.Time) {
+		b.loadShedder.observe(time.Since(start), time.Now())
+		b.detectSlowAudit(ctx, ErrorStageFlush, modifications, start)
+	}(time.Now())
+
+	b.setChecksums(modifications)
+	if b.sink != nil {
+		if err := writeSink(ctx, b.sink, modifications); err != nil {
+			return classifyAuditError(err)
+		}
+		return nil
+	}
+
+	query, args, err := buildInsert(b.dialect, table, modifications)
+	if err != nil {
+		return err
+	}
+	switch {
+	case table == b.stagingTable:
+		// audit records retried after an ambiguous failure are staged once
+		query += b.dialect.ignoreConflicts()
+	case table != sessionStagingTable && hasIdempotencyKey(modifications):
+		// audit records of retried operations are recorded once; session staging dedupes when moving instead
+		query += b.dialect.ignoreConflicts()
+	}
+	err = asAuditRole(ctx, conn, b.dialect, role, func() error {
+		_, err := stmts.exec(ctx, conn, query, args)
+		return err
+	})
+	if err != nil {
+		return classifyAuditError(err)
+	}
+	return nil
+}
+
+// tagShard sets the shard of mods, if the connection belongs to a ShardRouter.
+func tagShard(mods []DatabaseModification, shard string) {
+	if shard == "" {
+		return
+	}
+	for i := range mods {
+		mods[i].Shard = shard
+	}
+}
+
+// resolveSchema resolves the schema of mods on conn if schema resolution is enabled.
+func resolveSchema(ctx context.Context, conn driver.Conn, resolver *schemaResolver, mods []DatabaseModification) error {
+	if resolver == nil {
+		return nil
+	}
+	return resolver.resolve(ctx, conn, mods)
+}
+
+// captureResult records result metadata of an executed statement on its modifications.
+// If a multi-row insert was split into several modifications, the last insert ID is recorded on the first one.
+func captureResult(mods []DatabaseModification, res driver.Result) {
+	if len(mods) == 0 || mods[0].Action != DatabaseModificationActionInsert || res == nil {
+		return
+	}
+
+	// LastInsertId is only supported by some drivers, e.g. MySQL; others return an error.
+	if id, err := res.LastInsertId(); err == nil && id > 0 {
+		mods[0].RecordIDs = append(mods[0].RecordIDs, strconv.FormatInt(id, 10))
+	}
+}
+
+// execContext executes query on conn.
+// It falls back to a prepared statement if conn does not implement driver.ExecerContext or returns driver.ErrSkip,
+// so that the statement is executed here rather than by database/sql bypassing the audit connection.
+func execContext(ctx context.Context, conn driver.Conn, query string, args []driver.NamedValue) (driver.Result, error) {
+	res, _, err := execConverted(ctx, conn, query, args)
+	return res, err
+}
+
+// execConverted is execContext returning the arguments as converted by the prepared statement of the fallback,
+// or nil if they are executed as given.
+// database/sql only converts arguments with the driver.NamedValueChecker of the connection before ExecContext,
+// so the checker or driver.ColumnConverter of the statement is applied here, as database/sql does for prepared statements.
+func execConverted(ctx context.Context, conn driver.Conn, query string, args []driver.NamedValue) (driver.Result, []driver.NamedValue, error) {
+	if execCtx, ok := conn.(driver.ExecerContext); ok {
+		res, err := execCtx.ExecContext(ctx, query, args)
+		if !errors.Is(err, driver.ErrSkip) {
+			return res, nil, err
+		}
+	}
+
+	var (
+		stmt driver.Stmt
+		err  error
 	)
+	if prepareCtx, ok := conn.(driver.ConnPrepareContext); ok {
+		stmt, err = prepareCtx.PrepareContext(ctx, query)
+	} else {
+		stmt, err = conn.Prepare(query)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	defer func(stmt driver.Stmt) {
+		_ = stmt.Close()
+	}(stmt)
 
-	_, err := execCtx.ExecContext(ctx, query, args)
+	stmtExecCtx, ok := stmt.(driver.StmtExecContext)
+	if !ok {
+		return nil, nil, fmt.Errorf("%w: statement does not support ExecContext", ErrUnsupportedConn)
+	}
+	converted, err := convertArgs(stmt, args)
 	if err != nil {
-		return fmt.Errorf("failed to batch insert database modifications: %w", err)
+		return nil, nil, err
+	}
+	if converted == nil {
+		res, err := stmtExecCtx.ExecContext(ctx, args)
+		return res, nil, err
+	}
+	res, err := stmtExecCtx.ExecContext(ctx, converted)
+	return res, converted, err
+}
+
+// convertArgs converts args with the driver.NamedValueChecker or driver.ColumnConverter of stmt, if it implements any.
+// Arguments the statement skips are kept as given, and those it removes, e.g. options of the driver, are dropped.
+func convertArgs(stmt driver.Stmt, args []driver.NamedValue) ([]driver.NamedValue, error) {
+	checker, _ := stmt.(driver.NamedValueChecker)
+	converter, _ := stmt.(driver.ColumnConverter)
+	if checker == nil && converter == nil {
+		return nil, nil
 	}
 
-	for _, mod := range modifications {
-		tx.logger.Log(ctx, mod)
+	converted := make([]driver.NamedValue, 0, len(args))
+	for _, arg := range args {
+		err := driver.ErrSkip
+		if checker != nil {
+			err = checker.CheckNamedValue(&arg)
+		}
+		if errors.Is(err, driver.ErrSkip) && converter != nil {
+			arg.Value, err = converter.ColumnConverter(arg.Ordinal - 1).ConvertValue(arg.Value)
+			if err == nil && !driver.IsValue(arg.Value) {
+				err = fmt.Errorf("column converter returned unsupported type %T", arg.Value)
+			}
+		}
+		switch {
+		case errors.Is(err, driver.ErrRemoveArgument):
+			continue
+		case err != nil && !errors.Is(err, driver.ErrSkip):
+			return nil, fmt.Errorf("failed to convert argument $%d: %w", arg.Ordinal, err)
+		}
+		converted = append(converted, arg)
 	}
+	return converted, nil
+}
 
-	return nil
+// asAuditRole runs fn with the session switched to the given role of dialect.
+// If role is empty, fn is run as the current role.
+func asAuditRole(ctx context.Context, conn driver.Conn, dialect Dialect, role string, fn func() error) error {
+	if role == "" {
+		return fn()
+	}
+
+	if _, err := execContext(ctx, conn, "SET ROLE "+dialect.quoteIdentifier(role), nil); err != nil {
+		return fmt.Errorf("failed to set audit role: %w", err)
+	}
+
+	err := fn()
+
+	if _, resetErr := execContext(ctx, conn, dialect.resetRole(), nil); resetErr != nil && err == nil {
+		return fmt.Errorf("failed to reset audit role: %w", resetErr)
+	}
+
+	return err
 }
 
 var (
-	_ driver.Conn          = (*Conn)(nil)
-	_ driver.ConnBeginTx   = (*Conn)(nil)
-	_ driver.ExecerContext = (*Conn)(nil)
+	_ driver.Conn               = (*Conn)(nil)
+	_ driver.ConnBeginTx        = (*Conn)(nil)
+	_ driver.ExecerContext      = (*Conn)(nil)
+	_ driver.QueryerContext     = (*Conn)(nil)
+	_ driver.ConnPrepareContext = (*Conn)(nil)
+	_ driver.Pinger             = (*Conn)(nil)
+	_ driver.SessionResetter    = (*Conn)(nil)
+	_ driver.Validator          = (*Conn)(nil)
+	_ driver.NamedValueChecker  = (*Conn)(nil)
 
 	_ driver.ConnPrepareContext = (*txConn)(nil)
 	_ driver.ExecerContext      = (*txConn)(nil)
@@ -2,11 +2,28 @@ package audriver
 
 import (
 	"context"
-	"fmt"
+	"net/netip"
 )
 
 type operatorIDKey struct{}
 type executionIDKey struct{}
+type extraTableFiltersKey struct{}
+type tableFiltersOverrideKey struct{}
+type clientInfoKey struct{}
+type stepKey struct{}
+type parentExecutionIDKey struct{}
+
+// ClientInfo describes the client from which an operator performs modifications.
+type ClientInfo struct {
+	// IP is the IP address of the client.
+	IP netip.Addr
+
+	// UserAgent is the user agent of the client, e.g. the User-Agent header of an HTTP request.
+	UserAgent string
+
+	// Device describes the device of the client, e.g. "iPhone" or a device ID.
+	Device string
+}
 
 func WithOperatorID(ctx context.Context, operatorID string) context.Context {
 	return context.WithValue(ctx, operatorIDKey{}, operatorID)
@@ -19,7 +36,7 @@ func WithExecutionID(ctx context.Context, executionID string) context.Context {
 func GetOperatorID(ctx context.Context) (string, error) {
 	operatorID, ok := ctx.Value(operatorIDKey{}).(string)
 	if !ok || operatorID == "" {
-		return "", fmt.Errorf("operator ID not found in context")
+		return "", ErrMissingOperatorID
 	}
 	return operatorID, nil
 }
@@ -27,7 +44,70 @@ func GetOperatorID(ctx context.Context) (string, error) {
 func GetExecutionID(ctx context.Context) (string, error) {
 	executionID, ok := ctx.Value(executionIDKey{}).(string)
 	if !ok || executionID == "" {
-		return "", fmt.Errorf("execution ID not found in context")
+		return "", ErrMissingExecutionID
 	}
 	return executionID, nil
 }
+
+// WithClientInfo returns a context carrying the client information recorded with modifications executed with it.
+func WithClientInfo(ctx context.Context, info ClientInfo) context.Context {
+	return context.WithValue(ctx, clientInfoKey{}, info)
+}
+
+// GetClientInfo returns the client information of ctx, if any.
+func GetClientInfo(ctx context.Context) (ClientInfo, bool) {
+	info, ok := ctx.Value(clientInfoKey{}).(ClientInfo)
+	return info, ok
+}
+
+// WithParentExecutionID returns a context linking the execution of modifications executed with it to the execution
+// of the operation that spawned it, e.g. of a request fanning out work to workers, recorded in parent_execution_id.
+func WithParentExecutionID(ctx context.Context, parentExecutionID string) context.Context {
+	return context.WithValue(ctx, parentExecutionIDKey{}, parentExecutionID)
+}
+
+// GetParentExecutionID returns the parent execution ID of ctx, if any.
+func GetParentExecutionID(ctx context.Context) (string, bool) {
+	parentExecutionID, ok := ctx.Value(parentExecutionIDKey{}).(string)
+	return parentExecutionID, ok && parentExecutionID != ""
+}
+
+// WithStep returns a context tagging modifications executed with it with the step of a multi-step workflow,
+// e.g. "validate", "apply", or "notify", recorded in step. Steps share the execution ID of the workflow.
+func WithStep(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, stepKey{}, name)
+}
+
+// GetStep returns the workflow step of ctx, if any.
+func GetStep(ctx context.Context) (string, bool) {
+	step, ok := ctx.Value(stepKey{}).(string)
+	return step, ok && step != ""
+}
+
+// WithExtraTableFilter adds a table filter applied in addition to the driver's filters
+// for modifications executed with the returned context, e.g. to exclude staging tables of an import.
+func WithExtraTableFilter(ctx context.Context, filter TableFilter) context.Context {
+	filters, _ := ctx.Value(extraTableFiltersKey{}).(TableFilters)
+	extended := make(TableFilters, 0, len(filters)+1)
+	extended = append(extended, filters...)
+	extended = append(extended, filter)
+	return context.WithValue(ctx, extraTableFiltersKey{}, extended)
+}
+
+// WithTableFilterOverride replaces the driver's table filters with the given filters
+// for modifications executed with the returned context. Calling it without filters audits all tables.
+// Filters added by WithExtraTableFilter are still applied.
+func WithTableFilterOverride(ctx context.Context, filters ...TableFilter) context.Context {
+	return context.WithValue(ctx, tableFiltersOverrideKey{}, TableFilters(filters))
+}
+
+func getTableFilters(ctx context.Context, defaults TableFilters) TableFilters {
+	filters := defaults
+	if override, ok := ctx.Value(tableFiltersOverrideKey{}).(TableFilters); ok {
+		filters = override
+	}
+	if extra, ok := ctx.Value(extraTableFiltersKey{}).(TableFilters); ok {
+		filters = append(append(TableFilters{}, filters...), extra...)
+	}
+	return filters
+}
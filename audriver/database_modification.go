@@ -1,6 +1,7 @@
 package audriver
 
 import (
+	"encoding/json"
 	"time"
 )
 
@@ -14,27 +15,135 @@ const (
 	DatabaseModificationActionInsert DatabaseModificationAction = "insert"
 	DatabaseModificationActionUpdate DatabaseModificationAction = "update"
 	DatabaseModificationActionDelete DatabaseModificationAction = "delete"
+
+	// DatabaseModificationActionUnknown is recorded for modifying statements whose action and table could not be parsed,
+	// so that coverage gaps are visible rather than silent.
+	DatabaseModificationActionUnknown DatabaseModificationAction = "unknown"
+)
+
+// Exactness tells whether a record stands for exactly one modification.
+type Exactness string
+
+const (
+	// ExactnessExact records stand for exactly the modification they describe.
+	ExactnessExact Exactness = "exact"
+
+	// ExactnessSampled records were kept by sampling; other modifications of the same table were not recorded.
+	ExactnessSampled Exactness = "sampled"
+
+	// ExactnessAggregated records summarize several modifications.
+	ExactnessAggregated Exactness = "aggregated"
 )
 
 // DatabaseModification represents a database modification performed by an operator.
 type DatabaseModification struct {
-	ID string
+	ID string `json:"id"`
 
 	// OperatorID is the id of the operator who performed the modification.
-	OperatorID string
+	OperatorID string `json:"operator_id"`
+
+	// OperatorName is the name of the operator, if resolved with an OperatorResolver.
+	OperatorName string `json:"operator_name,omitempty"`
+
+	// OperatorEmail is the email of the operator, if resolved with an OperatorResolver.
+	OperatorEmail string `json:"operator_email,omitempty"`
 
 	// ExecutionID is a unique identifier for the execution that triggered the modification.
-	ExecutionID string
+	ExecutionID string `json:"execution_id"`
+
+	// SchemaName is the schema of the table being modified, e.g., "public".
+	// It is set if the table is schema-qualified in the statement or schema resolution is enabled.
+	SchemaName string `json:"schema_name,omitempty"`
 
-	// TableName is the name of the table being modified, e.g., "users", "orders".
-	TableName string
+	// TableName is the name of the table being modified without its schema, e.g., "users", "orders".
+	TableName string `json:"table_name"`
+
+	// Foreign reports whether the table being modified is a foreign table.
+	// It is only detected if schema resolution is enabled.
+	Foreign bool `json:"foreign_table,omitempty"`
 
 	// Action is the type of modification performed, e.g., "create", "update", "delete".
-	Action DatabaseModificationAction
+	Action DatabaseModificationAction `json:"action"`
 
 	// SQL is the raw SQL query executed for the modification.
-	SQL string
+	SQL string `json:"sql"`
 
 	// ModifiedAt is the timestamp when the modification was performed.
-	ModifiedAt time.Time
+	ModifiedAt time.Time `json:"modified_at"`
+
+	// RecordIDs are the IDs of the records affected by the modification, if known.
+	// For inserts, the last insert ID is captured on drivers supporting it, e.g. MySQL.
+	RecordIDs []string `json:"record_ids,omitempty"`
+
+	// SourceTables are the tables the modification reads from besides its target table, e.g. "b" and "c" for
+	// INSERT INTO a SELECT ... FROM b JOIN c, UPDATE a SET ... FROM b JOIN c, or DELETE FROM a USING b, c.
+	SourceTables []string `json:"source_tables,omitempty"`
+
+	// ChangedColumns are the columns assigned by the SET clause of an UPDATE, e.g. "email" and "name" for
+	// UPDATE users SET email = $1, name = $2.
+	ChangedColumns []string `json:"changed_columns,omitempty"`
+
+	// Exactness tells whether counts of records can be trusted literally, e.g. ExactnessSampled if sampling is enabled.
+	Exactness Exactness `json:"exactness,omitempty"`
+
+	// Shard is the shard the modification was executed on, if executed through a ShardRouter.
+	Shard string `json:"shard,omitempty"`
+
+	// ShardKey is the shard key of the modification, e.g. its tenant ID, if extracted with WithShardKeyExtractors.
+	ShardKey string `json:"shard_key,omitempty"`
+
+	// BackendPID is the PostgreSQL backend PID of the session that executed the modification,
+	// if recorded with WithBackendIDs.
+	BackendPID int64 `json:"backend_pid,omitempty"`
+
+	// TransactionID is the PostgreSQL transaction ID of the modification, if executed within a transaction
+	// and recorded with WithBackendIDs.
+	TransactionID int64 `json:"transaction_id,omitempty"`
+
+	// EstimatedRows is the number of rows the planner estimated the modification to affect,
+	// if estimated with WithRowEstimateGuard.
+	EstimatedRows int64 `json:"estimated_rows,omitempty"`
+
+	// IdempotencyKey is the key of the operation the modification belongs to, if set with WithIdempotencyKey.
+	IdempotencyKey string `json:"idempotency_key,omitempty"`
+
+	// ParentExecutionID is the execution ID of the operation that spawned the execution of the modification,
+	// if set with WithParentExecutionID.
+	ParentExecutionID string `json:"parent_execution_id,omitempty"`
+
+	// Step is the step of a workflow the modification was executed in, if set with WithStep.
+	Step string `json:"step,omitempty"`
+
+	// ClientIP is the IP address of the client, if set with WithClientInfo.
+	ClientIP string `json:"client_ip,omitempty"`
+
+	// UserAgent is the user agent of the client, if set with WithClientInfo.
+	UserAgent string `json:"user_agent,omitempty"`
+
+	// Device is the device of the client, if set with WithClientInfo.
+	Device string `json:"device,omitempty"`
+
+	// Checksum is the checksum of the content of the modification, if recorded with WithRecordChecksums.
+	// See Checksum.
+	Checksum string `json:"checksum,omitempty"`
+
+	// OldValues is the JSON array of the rows an UPDATE or DELETE statement modified as they were before it, as objects
+	// keyed by column, if captured with WithRowCapture.
+	OldValues json.RawMessage `json:"old_values,omitempty"`
+
+	// NewValues is the JSON array of the rows an UPDATE statement modified as they are after it, if captured with
+	// WithRowCapture.
+	NewValues json.RawMessage `json:"new_values,omitempty"`
+
+	// Metadata is a JSON document of additional information, e.g. the context snapshot under ContextSnapshotKey.
+	Metadata map[string]any `json:"metadata,omitempty"`
+}
+
+// SetMetadata sets key of the metadata of the modification to value, e.g. in a TableEnricher.
+// value must be JSON-encodable: writing a record whose metadata cannot be encoded fails.
+func (m *DatabaseModification) SetMetadata(key string, value any) {
+	if m.Metadata == nil {
+		m.Metadata = make(map[string]any, 1)
+	}
+	m.Metadata[key] = value
 }
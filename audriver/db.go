@@ -0,0 +1,84 @@
+package audriver
+
+import (
+	"context"
+	"database/sql"
+)
+
+// DB wraps a *sql.DB opened with an audit driver, binding the operator and execution IDs at construction
+// so that statements cannot be executed without them.
+type DB struct {
+	db          *sql.DB
+	operatorID  string
+	executionID string
+}
+
+// NewDB creates a DB executing statements on db with the given operator and execution IDs.
+func NewDB(db *sql.DB, operatorID string, executionID string) *DB {
+	return &DB{
+		db:          db,
+		operatorID:  operatorID,
+		executionID: executionID,
+	}
+}
+
+// ExecContext executes a statement with the operator and execution IDs of the DB.
+func (d *DB) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	return d.db.ExecContext(d.context(ctx), query, args...)
+}
+
+// QueryContext executes a query with the operator and execution IDs of the DB.
+func (d *DB) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	return d.db.QueryContext(d.context(ctx), query, args...)
+}
+
+// QueryRowContext executes a query returning at most one row with the operator and execution IDs of the DB.
+func (d *DB) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	return d.db.QueryRowContext(d.context(ctx), query, args...)
+}
+
+// BeginTx starts a transaction whose statements are executed with the operator and execution IDs of the DB.
+func (d *DB) BeginTx(ctx context.Context, opts *sql.TxOptions) (*Tx, error) {
+	ctx = d.context(ctx)
+	tx, err := d.db.BeginTx(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &Tx{tx: tx, db: d}, nil
+}
+
+func (d *DB) context(ctx context.Context) context.Context {
+	ctx = WithOperatorID(ctx, d.operatorID)
+	return WithExecutionID(ctx, d.executionID)
+}
+
+// Tx wraps a *sql.Tx started by DB.BeginTx, executing statements with the operator and execution IDs of the DB.
+type Tx struct {
+	tx *sql.Tx
+	db *DB
+}
+
+// ExecContext executes a statement within the transaction.
+func (tx *Tx) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	return tx.tx.ExecContext(tx.db.context(ctx), query, args...)
+}
+
+// QueryContext executes a query within the transaction.
+func (tx *Tx) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	return tx.tx.QueryContext(tx.db.context(ctx), query, args...)
+}
+
+// QueryRowContext executes a query returning at most one row within the transaction.
+func (tx *Tx) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	return tx.tx.QueryRowContext(tx.db.context(ctx), query, args...)
+}
+
+// Commit commits the transaction, writing its audit records.
+func (tx *Tx) Commit() error {
+	return tx.tx.Commit()
+}
+
+// Rollback aborts the transaction, discarding its audit records.
+func (tx *Tx) Rollback() error {
+	return tx.tx.Rollback()
+}
@@ -0,0 +1,41 @@
+package audriver_test
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mickamy/go-sql-audit-driver/audriver"
+)
+
+// TestDB tests that statements executed through DB and Tx carry the bound operator and execution IDs
+func TestDB(t *testing.T) {
+	t.Parallel()
+
+	// arrange
+	baseDriver := &fakeDriver{}
+	operatorID := uuid.New().String()
+	executionID := uuid.New().String()
+	db := audriver.NewDB(setUpFakeTestDB(t, baseDriver), operatorID, executionID)
+
+	// act
+	_, err := db.ExecContext(t.Context(), "INSERT INTO users (id) VALUES ($1)", "1")
+	require.NoError(t, err)
+
+	tx, err := db.BeginTx(t.Context(), nil)
+	require.NoError(t, err)
+	_, err = tx.ExecContext(t.Context(), "UPDATE users SET name = $1", "John")
+	require.NoError(t, err)
+	require.NoError(t, tx.Commit())
+
+	// assert
+	inserts := baseDriver.auditInserts()
+	require.Len(t, inserts, 2)
+	for _, insert := range inserts {
+		assert.Equal(t, operatorID, insert.value("operator_id"))
+		assert.Equal(t, executionID, insert.value("execution_id"))
+	}
+	assert.Equal(t, "UPDATE users SET name = 'John'", inserts[1].value("sql"))
+}
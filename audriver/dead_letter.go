@@ -0,0 +1,238 @@
+package audriver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// DeadLetter is an audit record that could not be written to the sink, with the reason.
+type DeadLetter struct {
+	Modification DatabaseModification `json:"modification"`
+
+	// Error is the error of the last attempt to write the record.
+	Error string `json:"error"`
+
+	// FailedAt is when the record was given up on.
+	FailedAt time.Time `json:"failed_at"`
+}
+
+// DeadLetterQueue stores audit records that could not be written to the sink, e.g. in a file, a table, or a topic,
+// until they are redelivered with Driver.Redeliver.
+type DeadLetterQueue interface {
+	// Put stores letters.
+	Put(ctx context.Context, letters []DeadLetter) error
+
+	// Replay passes the stored letters to write in batches, in the order they were put, and removes the letters of
+	// batches written. It stops at the first batch write fails, keeping it and the letters after it.
+	Replay(ctx context.Context, write func(ctx context.Context, letters []DeadLetter) error) error
+}
+
+// putDeadLetters stores mods that failed with err in the dead letter queue, if any.
+// Failures of the queue are reported to the error handler, as the records are lost then.
+func (b *databaseModificationBuilder) putDeadLetters(ctx context.Context, err error, mods []DatabaseModification) {
+	if b.deadLetterQueue == nil {
+		return
+	}
+	now := time.Now()
+	letters := make([]DeadLetter, len(mods))
+	for i := range mods {
+		letters[i] = DeadLetter{Modification: mods[i], Error: err.Error(), FailedAt: now}
+	}
+	if err := b.deadLetterQueue.Put(ctx, letters); err != nil {
+		b.handleError(ctx, fmt.Errorf("failed to put dead letters: %w", err), ErrorStageFlush, mods)
+		return
+	}
+	b.stats.deadLetters.Add(int64(len(mods)))
+}
+
+// Redeliver writes the audit records of the dead letter queue of WithDeadLetterQueue to the sink, e.g. after the
+// incident that failed them is resolved, and removes them from the queue. It returns the number of records written;
+// if a batch fails, it and the records after it are kept in the queue for the next call.
+func (d *Driver) Redeliver(ctx context.Context) (int, error) {
+	b := d.builder
+	if b.deadLetterQueue == nil {
+		return 0, nil
+	}
+	// redelivered records are written as they are batched in the queue, rather than queued again
+	sink := b.sink
+	if d.async != nil {
+		sink = d.async.sink
+	}
+	if d.batcher != nil {
+		sink = d.batcher.sink
+	}
+	if sink == nil {
+		return 0, errors.New("failed to redeliver dead letters: no audit sink")
+	}
+
+	var written int
+	err := b.deadLetterQueue.Replay(ctx, func(ctx context.Context, letters []DeadLetter) error {
+		mods := make([]DatabaseModification, len(letters))
+		for i := range letters {
+			mods[i] = letters[i].Modification
+		}
+		if err := writeSink(ctx, sink, mods); err != nil {
+			return classifyAuditError(err)
+		}
+		written += len(mods)
+		return nil
+	})
+	if err != nil {
+		return written, fmt.Errorf("failed to redeliver dead letters: %w", err)
+	}
+	return written, nil
+}
+
+// maxDeadLetterBatchSize is the number of letters FileDeadLetterQueue replays in a batch.
+const maxDeadLetterBatchSize = 1000
+
+// FileDeadLetterQueue is a DeadLetterQueue storing letters in a file as JSON documents, framed as length-prefixed,
+// CRC-checked entries. Entries torn by a crash while putting letters are skipped when the file is read, and dropped
+// when it is rewritten, so that the letters put before and after them are kept.
+type FileDeadLetterQueue struct {
+	path string
+
+	// mu serializes puts and replays, so that letters put during a replay are not lost when the file is rewritten.
+	mu sync.Mutex
+}
+
+// NewFileDeadLetterQueue returns a dead letter queue storing letters in the file at path, created on the first put.
+func NewFileDeadLetterQueue(path string) *FileDeadLetterQueue {
+	return &FileDeadLetterQueue{path: path}
+}
+
+// String describes the queue by its file.
+func (q *FileDeadLetterQueue) String() string {
+	return "file " + q.path
+}
+
+// Put appends letters to the file, syncing it so that they survive a crash.
+func (q *FileDeadLetterQueue) Put(_ context.Context, letters []DeadLetter) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	f, err := os.OpenFile(q.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open dead letter file: %w", err)
+	}
+	if err := writeDeadLetters(f, letters); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("failed to sync dead letter file: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to close dead letter file: %w", err)
+	}
+	return nil
+}
+
+// Replay passes the letters of the file to write in batches, and rewrites the file with the letters not written,
+// dropping torn entries.
+func (q *FileDeadLetterQueue) Replay(ctx context.Context, write func(ctx context.Context, letters []DeadLetter) error) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	letters, skipped, err := q.read()
+	if err != nil {
+		return err
+	}
+
+	var writeErr error
+	remaining := letters
+	for len(remaining) > 0 {
+		batch := remaining[:min(len(remaining), maxDeadLetterBatchSize)]
+		if writeErr = write(ctx, batch); writeErr != nil {
+			break
+		}
+		remaining = remaining[len(batch):]
+	}
+	if len(remaining) == len(letters) && skipped == 0 {
+		return writeErr
+	}
+
+	if err := q.rewrite(remaining); err != nil {
+		return errors.Join(writeErr, err)
+	}
+	return writeErr
+}
+
+// read reads the letters of the file, if it exists, and returns the number of bytes of torn entries skipped.
+func (q *FileDeadLetterQueue) read() ([]DeadLetter, int, error) {
+	data, err := os.ReadFile(q.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, 0, nil
+	}
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read dead letter file: %w", err)
+	}
+
+	var letters []DeadLetter
+	skipped, err := scanSpool(data, func(payload []byte) error {
+		var letter DeadLetter
+		if err := json.Unmarshal(payload, &letter); err != nil {
+			return fmt.Errorf("failed to decode dead letter: %w", err)
+		}
+		letters = append(letters, letter)
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return letters, skipped, nil
+}
+
+// rewrite replaces the file with letters, or removes it if there are none, through a temporary file renamed over it,
+// so that a crash leaves either the old letters or the new ones.
+func (q *FileDeadLetterQueue) rewrite(letters []DeadLetter) error {
+	if len(letters) == 0 {
+		if err := os.Remove(q.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to remove dead letter file: %w", err)
+		}
+		return nil
+	}
+
+	tmp := q.path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_TRUNC|os.O_CREATE, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to create dead letter file: %w", err)
+	}
+	err = writeDeadLetters(f, letters)
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp, q.path)
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("failed to rewrite dead letter file: %w", err)
+	}
+	return nil
+}
+
+// writeDeadLetters writes letters to f as spool entries, in a single write.
+func writeDeadLetters(f *os.File, letters []DeadLetter) error {
+	var data []byte
+	for _, letter := range letters {
+		payload, err := json.Marshal(letter)
+		if err != nil {
+			return fmt.Errorf("failed to encode dead letter: %w", err)
+		}
+		data = appendSpoolEntry(data, payload)
+	}
+	if _, err := f.Write(data); err != nil {
+		return fmt.Errorf("failed to write dead letters: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package audriver
+
+import (
+	"database/sql/driver"
+	"strconv"
+
+	"github.com/mickamy/go-sql-audit-driver/internal/mysql"
+	"github.com/mickamy/go-sql-audit-driver/internal/postgres"
+	"github.com/mickamy/go-sql-audit-driver/internal/sqlite"
+)
+
+// Dialect is the SQL dialect of the audited database, which determines how statements are parsed and interpolated
+// and how audit records are written.
+type Dialect string
+
+const (
+	// PostgreSQL is the dialect of PostgreSQL, e.g. for lib/pq or pgx, with $1 placeholders. It is the default.
+	PostgreSQL Dialect = "postgres"
+
+	// MySQL is the dialect of MySQL, e.g. for go-sql-driver/mysql, with ? placeholders, backtick-quoted identifiers,
+	// and backslash escapes in strings. Arrays such as record_ids are written as JSON arrays.
+	MySQL Dialect = "mysql"
+
+	// SQLite is the dialect of SQLite, e.g. for mattn/go-sqlite3, with ?, ?NNN, and named placeholders such as :name.
+	// Arrays such as record_ids are written as JSON arrays.
+	SQLite Dialect = "sqlite"
+)
+
+// normalize returns sql rewritten for the PostgreSQL lexical rules statements are parsed with, keeping offsets.
+func (d Dialect) normalize(sql string) string {
+	switch d {
+	case MySQL:
+		return mysql.Normalize(sql)
+	case SQLite:
+		return sqlite.Normalize(sql)
+	}
+	return sql
+}
+
+// interpolate replaces the placeholders of sql with args.
+func (d Dialect) interpolate(sql string, args []driver.NamedValue) string {
+	switch d {
+	case MySQL:
+		return mysql.InterpolateSQL(sql, args)
+	case SQLite:
+		return sqlite.InterpolateSQL(sql, args)
+	}
+	return postgres.InterpolateSQL(sql, args)
+}
+
+// appendPlaceholder appends the placeholder of the nth argument to buf.
+func (d Dialect) appendPlaceholder(buf []byte, n int) []byte {
+	switch d {
+	case MySQL:
+		return append(buf, '?')
+	case SQLite:
+		return strconv.AppendInt(append(buf, '?'), int64(n), 10)
+	}
+	return strconv.AppendInt(append(buf, '$'), int64(n), 10)
+}
+
+// formatArray formats values for an array column of the audit table.
+func (d Dialect) formatArray(values []string) string {
+	switch d {
+	case MySQL:
+		return mysql.FormatArray(values)
+	case SQLite:
+		return sqlite.FormatArray(values)
+	}
+	return postgres.FormatArray(values)
+}
+
+// quoteIdentifier quotes an identifier such as a role name.
+func (d Dialect) quoteIdentifier(name string) string {
+	if d == MySQL {
+		return mysql.QuoteIdentifier(name)
+	}
+	return postgres.QuoteIdentifier(name)
+}
+
+// quoteColumn quotes a column of the audit table for dialects reserving some of their names, e.g. sql in MySQL.
+func (d Dialect) quoteColumn(name string) string {
+	if d == MySQL {
+		return mysql.QuoteIdentifier(name)
+	}
+	return name
+}
+
+// resetRole returns the statement switching the session back to its original role.
+func (d Dialect) resetRole() string {
+	if d == MySQL {
+		return "SET ROLE DEFAULT"
+	}
+	return "RESET ROLE"
+}
+
+// lockRows returns the locking clause of a SELECT of rows about to be modified, if the dialect has one.
+func (d Dialect) lockRows() string {
+	if d == SQLite {
+		return ""
+	}
+	return " FOR UPDATE"
+}
+
+// ignoreConflicts returns the clause of an audit insert skipping records whose ID was written before.
+func (d Dialect) ignoreConflicts() string {
+	if d == MySQL {
+		return " ON DUPLICATE KEY UPDATE id = id"
+	}
+	return " ON CONFLICT (id) DO NOTHING"
+}
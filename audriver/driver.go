@@ -2,8 +2,14 @@ package audriver
 
 import (
 	"database/sql/driver"
+	"regexp"
+	"slices"
+	"time"
 )
 
+// Option configures a Driver at construction.
+// Options must only be passed to the constructors; the resulting configuration is copied and never changes afterward,
+// so later changes to slices passed to options do not affect the driver.
 type Option func(*Driver)
 
 // WithLogger sets the logger for database modifications.
@@ -34,25 +40,492 @@ func WithExecutionIDExtractor(extractor ExecutionIDExtractor) Option {
 	}
 }
 
+// WithTableFilters audits only statements whose modified table passes all filters; tables a statement only reads are
+// not checked.
 func WithTableFilters(filters ...TableFilter) Option {
 	return func(d *Driver) {
 		d.builder.tableFilters = filters
 	}
 }
 
+// WithActions limits auditing to the given actions, e.g. only inserts and deletes.
+// By default, all actions are audited.
+func WithActions(actions ...DatabaseModificationAction) Option {
+	return func(d *Driver) {
+		d.builder.actions = make(map[DatabaseModificationAction]bool, len(actions))
+		for _, action := range actions {
+			d.builder.actions[action] = true
+		}
+	}
+}
+
+// WithIgnoreSQLPatterns excludes statements whose raw SQL text matches any of the given patterns,
+// e.g. heartbeat updates or advisory-lock bookkeeping.
+func WithIgnoreSQLPatterns(patterns ...*regexp.Regexp) Option {
+	return func(d *Driver) {
+		d.builder.ignoreSQLPatterns = patterns
+	}
+}
+
+// WithSplitMultiRowInserts records one modification per row for multi-row INSERT ... VALUES statements,
+// each with the values of its own row, instead of a single modification for the whole statement.
+func WithSplitMultiRowInserts(split bool) Option {
+	return func(d *Driver) {
+		d.builder.splitMultiRowInserts = split
+	}
+}
+
+// WithSchemaResolution resolves the schema of modified tables against the given search path
+// using the PostgreSQL catalog, and flags modifications of foreign tables.
+// If no schemas are given, the search path of the session is used. Results are cached per driver.
+// It implies WithSchemaNames, and the audit table must have the schema_name and foreign_table columns.
+func WithSchemaResolution(searchPath ...string) Option {
+	return func(d *Driver) {
+		d.schemaResolver = &schemaResolver{searchPath: searchPath}
+	}
+}
+
+// WithSchemaNames records the schema modified tables are qualified with in statements, e.g. "billing" for
+// UPDATE billing.invoices, in the schema_name column. The audit table must have the column; see GenerateSchemaSQL.
+func WithSchemaNames(enabled bool) Option {
+	return func(d *Driver) {
+		d.builder.schemaNames = enabled
+	}
+}
+
+// WithStrictParsing rejects execution of modifying statements whose action and table cannot be parsed,
+// returning ErrUnclassifiedStatement, instead of recording them with the unknown action.
+// Use it for deployments where unaudited writes are unacceptable.
+func WithStrictParsing(strict bool) Option {
+	return func(d *Driver) {
+		d.builder.strictParsing = strict
+	}
+}
+
+// WithLogCorrelation appends the execution ID to audited statements as a SQL comment,
+// e.g. /* audriver_execution_id=... */, so that database logs such as pgAudit can be correlated
+// with audit records. The recorded SQL does not include the comment.
+func WithLogCorrelation(enabled bool) Option {
+	return func(d *Driver) {
+		d.builder.logCorrelation = enabled
+	}
+}
+
+// WithQueryAnnotation appends the operator and execution IDs of the context to outgoing statements
+// as a sqlcommenter comment, e.g. /*execution_id='...',operator_id='...'*/, so that database logs,
+// pg_stat_activity, and slow query logs can be correlated with audit records.
+// Unlike WithLogCorrelation, it annotates all statements, including queries.
+func WithQueryAnnotation(enabled bool) Option {
+	return func(d *Driver) {
+		d.builder.queryAnnotation = enabled
+	}
+}
+
+// WithQueryRewriters rewrites statements with the given rewriters, in order, before they are executed.
+// Modifying statements are recorded with the rewritten SQL.
+func WithQueryRewriters(rewriters ...QueryRewriter) Option {
+	return func(d *Driver) {
+		d.builder.rewriters = append(d.builder.rewriters, rewriters...)
+	}
+}
+
+// WithErrorHandler sets a handler invoked when building modifications, executing audited statements,
+// or writing audit records fails, so that failures can be alerted on without parsing error strings.
+func WithErrorHandler(handler ErrorHandler) Option {
+	return func(d *Driver) {
+		d.builder.errorHandler = handler
+	}
+}
+
+// WithTransactionHandler sets a handler invoked with the duration, statement count, and rows affected
+// of each transaction when it is committed or rolled back, e.g. to alert on heavyweight transactions.
+func WithTransactionHandler(handler TransactionHandler) Option {
+	return func(d *Driver) {
+		d.builder.transactionHandler = handler
+	}
+}
+
+// WithFlushThreshold writes the buffered modifications of a transaction into the transaction
+// whenever n modifications are buffered, instead of holding all of them in memory until commit.
+// Flushed records are still committed or rolled back with the transaction, but the logger is notified on flush.
+// With WithBatchWindow or WithAsyncLogging, records are held until commit, as those handed off cannot be rolled back.
+func WithFlushThreshold(n int) Option {
+	return func(d *Driver) {
+		d.builder.flushThreshold = n
+	}
+}
+
+// WithSlowAuditThreshold warns when building or writing audit records takes longer than threshold,
+// helping to notice when auditing itself becomes the bottleneck. Slow audits are counted in AuditStats
+// and passed to the logger if it implements SlowAuditLogger.
+func WithSlowAuditThreshold(threshold time.Duration) Option {
+	return func(d *Driver) {
+		d.builder.slowAuditThreshold = threshold
+	}
+}
+
+// WithLoadShedding degrades auditing gracefully when audit writes stay slow: hot tables are sampled
+// until writes are fast again, and each degradation window is reported for compliance review.
+func WithLoadShedding(cfg LoadShedding) Option {
+	return func(d *Driver) {
+		d.builder.loadShedder = newLoadShedder(cfg)
+	}
+}
+
+// WithOperatorRateAnomaly tracks the modification rates of operators, reported by AuditStats, and reports operators
+// exceeding the threshold of cfg, e.g. to alert on compromised credentials or runaway scripts.
+func WithOperatorRateAnomaly(cfg OperatorRateAnomaly) Option {
+	return func(d *Driver) {
+		d.builder.operatorRates = newOperatorRates(cfg)
+	}
+}
+
+// WithAuditRetry retries audit writes outside of transactions failing with transient errors, e.g. serialization
+// failures or brief failovers, with exponential backoff, instead of dropping the records and failing the statement.
+func WithAuditRetry(cfg AuditRetry) Option {
+	return func(d *Driver) {
+		retry := cfg.withDefaults()
+		d.builder.retry = &retry
+	}
+}
+
+// WithAuditStatementCache prepares audit inserts once per connection and reuses them,
+// caching up to size statements per connection. Audit inserts differ by batch size and the optional columns in use,
+// so single-row inserts and common transaction sizes are cached; large batches are never prepared.
+func WithAuditStatementCache(size int) Option {
+	return func(d *Driver) {
+		d.auditStatementCacheSize = size
+	}
+}
+
+// WithTableSampling records only a fraction of the modifications of the given tables, e.g. 0.1 for 10%,
+// for high-volume tables where complete records are not required. Kept records are marked ExactnessSampled.
+func WithTableSampling(rates map[string]float64) Option {
+	return func(d *Driver) {
+		d.builder.sampleRates = rates
+	}
+}
+
+// WithOperatorResolver records the name and email of operators resolved by resolver along with their IDs,
+// so that audit reports are human-readable. Resolved operators are cached per driver.
+// If resolution fails, the modification is recorded without them and the error is passed to the error handler.
+func WithOperatorResolver(resolver OperatorResolver) Option {
+	return func(d *Driver) {
+		d.builder.operators = &operatorCache{resolver: resolver}
+	}
+}
+
+// WithContextSnapshotter stores the context values snapshotted by snapshotter with each modification,
+// as a JSON document under ContextSnapshotKey in the metadata column, e.g. metadata->'context'->>'locale'.
+// If snapshotting fails, the modification is recorded without it and the error is passed to the error handler.
+func WithContextSnapshotter(snapshotter ContextSnapshotter) Option {
+	return func(d *Driver) {
+		d.builder.contextSnapshotter = snapshotter
+	}
+}
+
+// WithClientIPEnricher annotates the client IP set with WithClientInfo when modifications are recorded,
+// storing the annotations under ClientIPMetadataKey in the metadata column, e.g. metadata->'client_ip'->>'country'.
+// To annotate records later instead, e.g. in reports, use EnrichClientIP on records read with the query package.
+// If enrichment fails, the modification is recorded without it and the error is passed to the error handler.
+func WithClientIPEnricher(enricher ClientIPEnricher) Option {
+	return func(d *Driver) {
+		d.builder.clientIPEnricher = enricher
+	}
+}
+
+// WithTableEnricher registers an enricher adding domain fields to modifications of table, e.g. into their metadata.
+// Several enrichers may be registered for a table; they run in the order they were registered.
+// If an enricher fails, the modification is recorded as enriched so far and the error is passed to the error handler.
+func WithTableEnricher(table string, enricher TableEnricher) Option {
+	return func(d *Driver) {
+		if d.builder.tableEnrichers == nil {
+			d.builder.tableEnrichers = make(map[string][]TableEnricher)
+		}
+		d.builder.tableEnrichers[table] = append(d.builder.tableEnrichers[table], enricher)
+	}
+}
+
+// WithShardKeyExtractors records the shard key of modifications of sharded tables, e.g. their tenant ID,
+// extracted by the extractor of their table, in the shard_key column for tenant-scoped audit queries.
+// Use ShardKeyColumn to read the key from a column of the statement.
+// If extraction fails, the modification is recorded without it and the error is passed to the error handler.
+func WithShardKeyExtractors(extractors map[string]ShardKeyExtractor) Option {
+	return func(d *Driver) {
+		d.builder.shardKeyExtractors = extractors
+	}
+}
+
+// WithStaging writes audit records to the unlogged staging table of cfg with INSERT ... ON CONFLICT DO NOTHING,
+// for the hottest deployments, leaving them to a StagingMover to move into the durable audit table.
+// Records are lost if PostgreSQL crashes before they are moved; see Staging.
+func WithStaging(cfg Staging) Option {
+	return func(d *Driver) {
+		d.builder.stagingTable = cfg.withDefaults().Table
+	}
+}
+
+// WithSessionStaging writes audit records of transactions to a temporary table of the session instead,
+// and moves them to the audit table in the background once the transaction is committed, so that audit I/O is kept
+// off the commit path. Records are moved in order of commit, before the connection executes anything else,
+// but are lost if the application or its connection dies before they are moved.
+// The audit role, or the current role without one, needs the TEMPORARY privilege on the database.
+func WithSessionStaging(enabled bool) Option {
+	return func(d *Driver) {
+		d.builder.sessionStaging = enabled
+	}
+}
+
+// WithArgCapture records the arguments of statements as CapturedArgs under ArgsMetadataKey in the metadata, besides
+// interpolating them into the SQL. Arguments keep their type, e.g. integers are JSON numbers and UUIDs and timestamps
+// are tagged as such, so that consumers can filter by them without parsing SQL.
+func WithArgCapture(enabled bool) Option {
+	return func(d *Driver) {
+		d.builder.argCapture = enabled
+	}
+}
+
+// WithAnonymizer replaces the literal values of statements, both inline and interpolated arguments, with fakes of
+// anonymizer before they are stored, e.g. FormatPreservingAnonymizer for staging environments receiving
+// production-like traffic, or RedactionRules to replace only values matching some patterns. Captured arguments and
+// WHERE predicates are derived from the fakes.
+// Client information, context snapshots, and metadata of enrichers are stored as given.
+func WithAnonymizer(anonymizer Anonymizer) Option {
+	return func(d *Driver) {
+		d.builder.anonymizer = anonymizer
+	}
+}
+
+// WithWherePredicates records the simple comparisons of the WHERE clause of UPDATE and DELETE statements with their
+// interpolated values, e.g. user_id = 'X', as WherePredicates under WherePredicatesMetadataKey in the metadata, so that
+// audit records can be queried structurally, e.g. for all deletes targeting a user.
+// Only conjunctions are recorded, so that each predicate holds for every affected row; see WherePredicate.
+func WithWherePredicates(enabled bool) Option {
+	return func(d *Driver) {
+		d.builder.wherePredicates = enabled
+	}
+}
+
+// WithSourceTables records the tables modifications read from besides their target table, e.g. of INSERT ... SELECT,
+// UPDATE ... FROM, or DELETE ... USING statements, in the source_tables column, for lineage of copied data.
+// The audit table must have the column; see GenerateSchemaSQL.
+func WithSourceTables(enabled bool) Option {
+	return func(d *Driver) {
+		d.builder.sourceTables = enabled
+	}
+}
+
+// WithChangedColumns records the columns assigned by the SET clause of UPDATE statements in the changed_columns column,
+// e.g. to find who changed a column without parsing SQL. The audit table must have the column; see GenerateSchemaSQL.
+func WithChangedColumns(enabled bool) Option {
+	return func(d *Driver) {
+		d.builder.changedColumns = enabled
+	}
+}
+
+// WithBackendIDs records the PostgreSQL backend PID (pg_backend_pid()) and, for statements within transactions,
+// the transaction ID (txid_current()) of modifications, so that DBAs can correlate audit records with server logs,
+// lock waits, and replication positions. The PID is queried once per connection and the transaction ID once per
+// transaction. If querying fails, the modification is recorded without them and the error is passed to the error handler.
+func WithBackendIDs(enabled bool) Option {
+	return func(d *Driver) {
+		d.builder.backendIDs = enabled
+	}
+}
+
+// WithCommitLSN captures the WAL insert location (pg_current_wal_insert_lsn()) right after transactions with audited
+// modifications are committed, as TransactionSummary.CommitLSN of the transaction handler, so that a point-in-time
+// recovery target can be chosen precisely relative to an audited change. It costs a query per such transaction.
+func WithCommitLSN(enabled bool) Option {
+	return func(d *Driver) {
+		d.builder.commitLSN = enabled
+	}
+}
+
+// WithRecordChecksums records the Checksum of the content of each modification in the checksum column, computed right
+// before it is written, e.g. to deduplicate records fanned out to several sinks in ETL or to verify copies of them.
+// Loggers see the checksum of the records they are passed.
+func WithRecordChecksums(enabled bool) Option {
+	return func(d *Driver) {
+		d.builder.checksums = enabled
+	}
+}
+
+// WithRowEstimateGuard estimates the rows each UPDATE and DELETE statement affects with EXPLAIN before executing it,
+// and records the estimate with its modifications. Statements estimated to affect more than threshold rows are blocked
+// with ErrRowEstimateExceeded unless their context is approved with WithRowEstimateApproval.
+// Each guarded statement is planned twice, so the guard is meant for operational tools rather than hot paths.
+// Within transactions, statements are explained behind a savepoint, so that failures do not abort the transaction.
+func WithRowEstimateGuard(threshold int64) Option {
+	return func(d *Driver) {
+		d.builder.rowEstimateThreshold = threshold
+	}
+}
+
+// WithRowCapture records the rows each UPDATE and DELETE statement modifies in OldValues and NewValues of its
+// modifications, as JSON arrays of objects keyed by column, e.g. for compliance requirements to keep the values
+// changed. Old values are selected by the target table and WHERE clause of the statement before it is executed, with
+// FOR UPDATE except on SQLite, and new values are returned with RETURNING *, or selected again after the statement on
+// MySQL, missing rows no longer matching the WHERE clause. Up to 1000 rows are kept per statement; modifications of
+// more are marked with RowCaptureTruncatedMetadataKey in their metadata. Values are replaced with fakes if an
+// anonymizer is set. Statements reading other tables, e.g. UPDATE ... FROM or joins, are not captured; failures to
+// capture are reported to the error handler without failing the statement.
+// Each captured statement runs one or two more queries, so row capture is meant for tables where the values matter.
+func WithRowCapture(enabled bool) Option {
+	return func(d *Driver) {
+		d.builder.rowCapture = enabled
+	}
+}
+
+// WithHooksConnTimeout limits how long hooks created by NewHooks wait for a connection of their pool to write audit
+// records with, 5 seconds by default. A negative timeout waits as long as the context of the statement allows.
+// The driver itself ignores it.
+func WithHooksConnTimeout(timeout time.Duration) Option {
+	return func(d *Driver) {
+		d.hooksConnTimeout = timeout
+	}
+}
+
+// WithEnablementRate audits only a fraction of connections, e.g. 0.1 for 10%, to roll auditing out gradually
+// in high-traffic systems. Whether a connection is audited is decided when it is opened, and can be overridden
+// per request with WithAuditEnabled. The decisions and the rate are reported in AuditStats.
+func WithEnablementRate(rate float64) Option {
+	return func(d *Driver) {
+		d.enablementRate = min(max(rate, 0), 1)
+	}
+}
+
+// WithDialect sets the SQL dialect of the wrapped driver, e.g. MySQL for go-sql-driver/mysql or SQLite for
+// mattn/go-sqlite3. It defaults to PostgreSQL.
+// The dialect determines how placeholders are interpolated, how statements are parsed, and how audit records are
+// inserted; the audit tables of other dialects are created by the scripts in the mysql and sqlite directories.
+// Options querying PostgreSQL, such as WithSchemaResolution, WithBackendIDs, WithCommitLSN, WithRowEstimateGuard,
+// WithStaging, and WithSessionStaging, are not supported with other dialects, nor is WithAuditRole with SQLite.
+func WithDialect(dialect Dialect) Option {
+	return func(d *Driver) {
+		d.builder.dialect = dialect
+	}
+}
+
+// WithAuditSink writes audit records to sink instead of inserting them into the audit table on the audited connection,
+// e.g. to send them to a separate database with DBSink, a message queue, or a file. Checksums, retries, load shedding,
+// and loggers apply to records written to the sink as they do to inserted ones, while options of the audit table, such
+// as WithAuditTable, WithAuditRole, WithStaging, and WithSessionStaging, are ignored.
+// Records of transactions are written before the transaction is committed, and failures roll it back.
+func WithAuditSink(sink AuditSink) Option {
+	return func(d *Driver) {
+		d.builder.sink = sink
+	}
+}
+
+// WithBatchWindow batches audit records written to the sink of WithAuditSink, trading latency for throughput:
+// records are buffered until maxRecords of them or maxBytes of their approximate size are buffered, or maxLatency
+// elapsed since the first of them, and then written with a single Write. Zero limits do not apply.
+// Records are batched in partitions by their execution ID, each with its own window, and batches of a partition are
+// written one at a time, so that the records of an execution reach the sink in the order of their statements.
+// Records of transactions are buffered once the transaction is committed, so that records of transactions failing to
+// commit are never written, and records of statements outside of transactions once the statement is executed. As
+// records are written after their statements and transactions are done, failures are reported to the error handler
+// rather than failing them, and loggers are notified of records once they are buffered.
+// Call Driver.Shutdown before the program exits to write the buffered records. Without a sink, the window is ignored.
+func WithBatchWindow(maxRecords int, maxBytes int, maxLatency time.Duration) Option {
+	return func(d *Driver) {
+		d.builder.batchWindow = &batchWindow{maxRecords: maxRecords, maxBytes: maxBytes, maxLatency: maxLatency}
+	}
+}
+
+// WithAsyncLogging writes audit records to the sink of WithAuditSink by workers in the background, so that statements
+// and commits do not wait for the sink: records are enqueued to bounded queues holding queueSize writes in total, one
+// for each worker, and written by the workers, retried and put into the dead letter queue as batches of WithBatchWindow
+// are, or batched if WithBatchWindow is set as well. Records are queued by their execution ID, so that the records of
+// an execution reach the sink in order.
+// Records are enqueued once their statements and transactions are committed, so that records of transactions failing
+// to commit are never written. Full queues are handled by the policy of WithBackpressurePolicy. Failures are reported
+// to the error handler, and loggers are notified of records once they are enqueued.
+// Call Driver.Shutdown before the program exits to write the queued records. Without a sink, async logging is ignored.
+func WithAsyncLogging(queueSize int, workers int) Option {
+	return func(d *Driver) {
+		d.builder.asyncLogging = &asyncLogging{queueSize: queueSize, workers: workers}
+	}
+}
+
+// WithBackpressurePolicy sets what happens to audit records when the queue of WithAsyncLogging is full.
+// The default is BackpressureBlock.
+func WithBackpressurePolicy(policy BackpressurePolicy) Option {
+	return func(d *Driver) {
+		d.builder.backpressure = policy
+	}
+}
+
+// WithDeadLetterQueue puts the audit records of batches of WithBatchWindow and writes of WithAsyncLogging that failed
+// to be written, after the retries of WithAuditRetry if any, into queue with the reason, instead of dropping them after reporting them to the
+// error handler. Driver.Redeliver writes them to the sink after the incident.
+func WithDeadLetterQueue(queue DeadLetterQueue) Option {
+	return func(d *Driver) {
+		d.builder.deadLetterQueue = queue
+	}
+}
+
+// WithAuditTable writes audit records to the given table, optionally schema-qualified,
+// instead of DefaultAuditTable. The name is written into statements as is.
+func WithAuditTable(table string) Option {
+	return func(d *Driver) {
+		d.builder.auditTable = table
+	}
+}
+
 func WithReadOnly(readOnly bool) Option {
 	return func(d *Driver) {
 		d.readOnly = readOnly
 	}
 }
 
+// WithAuditRole sets the database role used to write audit records.
+// Audit inserts are wrapped in SET ROLE / RESET ROLE, so the application role only needs
+// membership in the audit role instead of INSERT on the audit table itself.
+func WithAuditRole(role string) Option {
+	return func(d *Driver) {
+		d.auditRole = role
+	}
+}
+
 // Driver is a wrapper around a standard SQL driver that logs database modifications.
 // It implements the driver.Driver interface and provides additional functionality for auditing.
 type Driver struct {
 	driver.Driver
-	builder  *databaseModificationBuilder
-	readOnly bool
-	logger   Logger
+	builder   *databaseModificationBuilder
+	readOnly  bool
+	auditRole string
+	logger    Logger
+
+	schemaResolver *schemaResolver
+
+	auditStatementCacheSize int
+
+	// enablementRate is the fraction of connections audited.
+	enablementRate float64
+
+	// hooksConnTimeout limits waiting for a connection to write audit records with, for hooks created by NewHooks.
+	hooksConnTimeout time.Duration
+
+	// batcher batches records written to the sink, if WithBatchWindow is set.
+	batcher *batcher
+
+	// async writes records to the sink, or to the batcher, in the background, if WithAsyncLogging is set.
+	async *asyncWriter
+}
+
+// NewInstrumented creates an audit driver on top of base instrumented by instrument,
+// e.g. otelsql, layering them so that both see every statement:
+//
+//	audriver.NewInstrumented(&pq.Driver{}, func(d driver.Driver) driver.Driver {
+//		return otelsql.WrapDriver(d, otelsql.WithAttributes(semconv.DBSystemPostgreSQL))
+//	}, audriver.WithTableFilters(filters...))
+//
+// The instrumentation wraps base and audriver wraps the instrumentation, so audit inserts are traced
+// along with application statements, and audriver's optional interfaces are not hidden behind another wrapper.
+func NewInstrumented(base driver.Driver, instrument func(driver.Driver) driver.Driver, options ...Option) driver.Driver {
+	return newAuditDriver(instrument(base), options...)
 }
 
 // NewDriver creates a new audit driver from a driver.Driver
@@ -80,10 +553,11 @@ func New(d interface{}, options ...Option) driver.Driver {
 	return newAuditDriver(baseDriver, options...)
 }
 
-func newAuditDriver(d driver.Driver, options ...Option) driver.Driver {
+func newAuditDriver(d driver.Driver, options ...Option) *Driver {
 	drv := &Driver{
-		Driver:  d,
-		builder: &databaseModificationBuilder{},
+		Driver:         d,
+		builder:        &databaseModificationBuilder{},
+		enablementRate: 1,
 	}
 
 	for _, option := range options {
@@ -91,10 +565,27 @@ func newAuditDriver(d driver.Driver, options ...Option) driver.Driver {
 	}
 
 	drv.builder.fillDefaults()
+	drv.builder = drv.builder.clone()
+	if drv.builder.batchWindow != nil && drv.builder.sink != nil {
+		drv.batcher = newBatcher(drv.builder, drv.builder.sink, *drv.builder.batchWindow)
+		drv.builder.sink = drv.batcher
+	}
+	if drv.builder.asyncLogging != nil && drv.builder.sink != nil {
+		drv.async = newAsyncWriter(drv.builder, drv.builder.sink, *drv.builder.asyncLogging, drv.builder.backpressure)
+		drv.builder.sink = drv.async
+	}
+	if drv.schemaResolver != nil {
+		// tables qualified with a schema in the statement are looked up in that schema
+		drv.builder.schemaNames = true
+		drv.schemaResolver = &schemaResolver{searchPath: slices.Clone(drv.schemaResolver.searchPath)}
+	}
 
 	if drv.logger == nil {
 		drv.logger = &noopLogger{}
 	}
+	if logger, ok := drv.logger.(SlowAuditLogger); ok {
+		drv.builder.slowAuditLogger = logger
+	}
 
 	return drv
 }
@@ -104,7 +595,16 @@ func (d *Driver) Open(name string) (driver.Conn, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &Conn{Conn: conn, builder: d.builder, readOnly: d.readOnly, logger: d.logger}, nil
+	return &Conn{
+		Conn:           conn,
+		builder:        d.builder,
+		readOnly:       d.readOnly,
+		auditRole:      d.auditRole,
+		logger:         d.logger,
+		schemaResolver: d.schemaResolver,
+		stmts:          newStmtCache(d.auditStatementCacheSize),
+		unaudited:      !d.decideEnablement(),
+	}, nil
 }
 
 var (
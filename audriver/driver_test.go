@@ -3,8 +3,20 @@ package audriver_test
 import (
 	"context"
 	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/brianvoe/gofakeit/v7"
 	"github.com/google/uuid"
@@ -469,3 +481,4143 @@ func TestAuditDriver_ConcurrentOperations(t *testing.T) {
 	require.NoError(t, err)
 	assert.GreaterOrEqual(t, totalCount, numGoroutines*operationsPerGoroutine, "all concurrent operations should be logged")
 }
+
+// TestAuditDriver_AuditRole tests that audit records are written under the configured audit role
+func TestAuditDriver_AuditRole(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	opID := uuid.New()
+	execID := uuid.New()
+	ctx = audriver.WithOperatorID(ctx, opID.String())
+	ctx = audriver.WithExecutionID(ctx, execID.String())
+
+	testCases := []struct {
+		name      string
+		operation func(ctx context.Context, db *sql.DB, userID string)
+	}{
+		{
+			name: "direct_insert",
+			operation: func(ctx context.Context, db *sql.DB, userID string) {
+				_, err := db.ExecContext(ctx, `INSERT INTO "users" ("id", "name", "email") VALUES ($1, $2, $3)`, userID, gofakeit.Name(), gofakeit.Email())
+				require.NoError(t, err)
+			},
+		},
+		{
+			name: "transactional_insert",
+			operation: func(ctx context.Context, db *sql.DB, userID string) {
+				tx, err := db.BeginTx(ctx, nil)
+				require.NoError(t, err)
+
+				_, err = tx.ExecContext(ctx, `INSERT INTO "users" ("id", "name", "email") VALUES ($1, $2, $3)`, userID, gofakeit.Name(), gofakeit.Email())
+				require.NoError(t, err)
+
+				err = tx.Commit()
+				require.NoError(t, err)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// arrange
+			db := setUpWriterTestDB(t, audriver.WithAuditRole("audriver_auditor"))
+
+			// act
+			tc.operation(ctx, db, uuid.New().String())
+
+			// assert
+			var count int
+			err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM database_modifications WHERE execution_id = $1", execID.String()).Scan(&count)
+			require.NoError(t, err)
+			assert.Equal(t, 1, count)
+
+			var currentUser string
+			err = db.QueryRowContext(ctx, "SELECT current_user").Scan(&currentUser)
+			require.NoError(t, err)
+			assert.Equal(t, "audriver_writer", currentUser, "role should be reset after writing audit records")
+		})
+	}
+}
+
+// TestAuditDriver_ErrSkip tests that drivers returning driver.ErrSkip are executed via prepared statements and audited once
+func TestAuditDriver_ErrSkip(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	ctx = audriver.WithOperatorID(ctx, uuid.New().String())
+	ctx = audriver.WithExecutionID(ctx, uuid.New().String())
+
+	const query = `INSERT INTO "users" ("id", "name", "email") VALUES ($1, $2, $3)`
+
+	testCases := []struct {
+		name      string
+		operation func(ctx context.Context, db *sql.DB)
+	}{
+		{
+			name: "direct_insert",
+			operation: func(ctx context.Context, db *sql.DB) {
+				_, err := db.ExecContext(ctx, query, uuid.New().String(), gofakeit.Name(), gofakeit.Email())
+				require.NoError(t, err)
+			},
+		},
+		{
+			name: "transactional_insert",
+			operation: func(ctx context.Context, db *sql.DB) {
+				tx, err := db.BeginTx(ctx, nil)
+				require.NoError(t, err)
+
+				_, err = tx.ExecContext(ctx, query, uuid.New().String(), gofakeit.Name(), gofakeit.Email())
+				require.NoError(t, err)
+
+				err = tx.Commit()
+				require.NoError(t, err)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// arrange
+			baseDriver := &fakeDriver{skipExec: true}
+			db := setUpFakeTestDB(t, baseDriver)
+
+			// act
+			tc.operation(ctx, db)
+
+			// assert
+			executed := baseDriver.executed()
+			require.Len(t, executed, 2)
+			assert.Len(t, baseDriver.auditInserts(), 1)
+			for _, exec := range executed {
+				assert.True(t, exec.prepared, "statement should be executed via prepared statement: %s", exec.query)
+			}
+		})
+	}
+}
+
+// TestAuditDriver_ErrSkipConvertedArgs tests that statements executed via prepared statements are audited
+// with the arguments as converted by the statement
+func TestAuditDriver_ErrSkipConvertedArgs(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	ctx = audriver.WithOperatorID(ctx, uuid.New().String())
+	ctx = audriver.WithExecutionID(ctx, uuid.New().String())
+
+	const query = `UPDATE "users" SET "status" = $1 WHERE "id" = $2`
+
+	// statuses are stored as names, like a driver mapping enum codes
+	statuses := map[int64]string{1: "active", 2: "banned"}
+	checkNamedValue := func(nv *driver.NamedValue) error {
+		if code, ok := nv.Value.(int64); ok && nv.Ordinal == 1 {
+			nv.Value = statuses[code]
+			return nil
+		}
+		return driver.ErrSkip
+	}
+
+	testCases := []struct {
+		name      string
+		operation func(ctx context.Context, db *sql.DB, id string)
+	}{
+		{
+			name: "direct_update",
+			operation: func(ctx context.Context, db *sql.DB, id string) {
+				_, err := db.ExecContext(ctx, query, 2, id)
+				require.NoError(t, err)
+			},
+		},
+		{
+			name: "transactional_update",
+			operation: func(ctx context.Context, db *sql.DB, id string) {
+				tx, err := db.BeginTx(ctx, nil)
+				require.NoError(t, err)
+
+				_, err = tx.ExecContext(ctx, query, 2, id)
+				require.NoError(t, err)
+
+				err = tx.Commit()
+				require.NoError(t, err)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// arrange
+			baseDriver := &fakeDriver{skipExec: true, checkNamedValue: checkNamedValue}
+			db := setUpFakeTestDB(t, baseDriver)
+			id := uuid.New().String()
+
+			// act
+			tc.operation(ctx, db, id)
+
+			// assert
+			executed := baseDriver.executed()
+			require.NotEmpty(t, executed)
+			assert.Equal(t, "banned", executed[0].args[0].Value)
+			inserts := baseDriver.auditInserts()
+			require.Len(t, inserts, 1)
+			assert.Equal(t, `UPDATE "users" SET "status" = 'banned' WHERE "id" = '`+id+`'`, inserts[0].value("sql"))
+		})
+	}
+}
+
+// TestAuditDriver_AutocommitAudit tests that statements outside of transactions are committed only with their audit records
+func TestAuditDriver_AutocommitAudit(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	ctx = audriver.WithOperatorID(ctx, uuid.New().String())
+	ctx = audriver.WithExecutionID(ctx, uuid.New().String())
+
+	testCases := []struct {
+		name      string
+		query     string
+		auditErr  error
+		commits   int
+		rollbacks int
+	}{
+		{name: "audited", query: `UPDATE "users" SET "name" = $1`, commits: 1},
+		{name: "audit_failed", query: `UPDATE "users" SET "name" = $1`, auditErr: errors.New("audit table is unavailable"), rollbacks: 1},
+		{name: "not_modifying", query: `SET search_path = $1`},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// arrange
+			baseDriver := &fakeDriver{auditErr: tc.auditErr}
+			db := setUpFakeTestDB(t, baseDriver)
+
+			// act
+			_, err := db.ExecContext(ctx, tc.query, gofakeit.Name())
+
+			// assert
+			if tc.auditErr != nil {
+				assert.ErrorIs(t, err, tc.auditErr)
+			} else {
+				assert.NoError(t, err)
+			}
+			commits, rollbacks := baseDriver.finished()
+			assert.Equal(t, tc.commits, commits)
+			assert.Equal(t, tc.rollbacks, rollbacks)
+		})
+	}
+}
+
+// TestAuditDriver_RecordIDs tests that the last insert ID is captured when the base driver supports it
+func TestAuditDriver_RecordIDs(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	ctx = audriver.WithOperatorID(ctx, uuid.New().String())
+	ctx = audriver.WithExecutionID(ctx, uuid.New().String())
+
+	testCases := []struct {
+		name         string
+		lastInsertID int64
+		query        string
+		expected     any
+	}{
+		{
+			name:         "insert_with_last_insert_id",
+			lastInsertID: 42,
+			query:        "INSERT INTO `users` (`name`, `email`) VALUES (?, ?)",
+			expected:     `{"42"}`,
+		},
+		{
+			name:         "insert_without_last_insert_id",
+			lastInsertID: 0,
+			query:        "INSERT INTO `users` (`name`, `email`) VALUES (?, ?)",
+			expected:     nil,
+		},
+		{
+			name:         "update_ignores_last_insert_id",
+			lastInsertID: 42,
+			query:        "UPDATE `users` SET `name` = ? WHERE `email` = ?",
+			expected:     nil,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// arrange
+			baseDriver := &fakeDriver{lastInsertID: tc.lastInsertID}
+			db := setUpFakeTestDB(t, baseDriver)
+
+			// act
+			_, err := db.ExecContext(ctx, tc.query, gofakeit.Name(), gofakeit.Email())
+			require.NoError(t, err)
+
+			// assert
+			inserts := baseDriver.auditInserts()
+			require.Len(t, inserts, 1)
+			assert.Equal(t, tc.expected, inserts[0].value("record_ids"))
+		})
+	}
+}
+
+// TestAuditDriver_TransactionBuffering tests that modifications within a transaction are written in a single batch on commit
+func TestAuditDriver_TransactionBuffering(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	ctx = audriver.WithOperatorID(ctx, uuid.New().String())
+	ctx = audriver.WithExecutionID(ctx, uuid.New().String())
+
+	// arrange
+	baseDriver := &fakeDriver{}
+	db := setUpFakeTestDB(t, baseDriver)
+
+	tx, err := db.BeginTx(ctx, nil)
+	require.NoError(t, err)
+
+	// act
+	_, err = tx.ExecContext(ctx, `INSERT INTO "users" ("id", "name", "email") VALUES ($1, $2, $3)`, uuid.New().String(), gofakeit.Name(), gofakeit.Email())
+	require.NoError(t, err)
+	_, err = tx.ExecContext(ctx, `UPDATE "users" SET "name" = $1`, gofakeit.Name())
+	require.NoError(t, err)
+
+	// assert
+	assert.Empty(t, baseDriver.auditInserts(), "modifications should be buffered until commit")
+
+	err = tx.Commit()
+	require.NoError(t, err)
+
+	inserts := baseDriver.auditInserts()
+	require.Len(t, inserts, 1)
+	assert.Len(t, inserts[0].args, 2*7, "only required columns should be written by default")
+}
+
+// TestAuditDriver_SplitMultiRowInserts tests that multi-row inserts can be recorded per row
+func TestAuditDriver_SplitMultiRowInserts(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	ctx = audriver.WithOperatorID(ctx, uuid.New().String())
+	ctx = audriver.WithExecutionID(ctx, uuid.New().String())
+
+	const query = `INSERT INTO "users" ("id", "name") VALUES ($1, $2), ($3, concat($4, '(x), y')) ON CONFLICT ("id") DO NOTHING`
+
+	testCases := []struct {
+		name     string
+		split    bool
+		expected []any
+	}{
+		{
+			name:  "split_enabled",
+			split: true,
+			expected: []any{
+				`INSERT INTO "users" ("id", "name") VALUES ('1', 'a') ON CONFLICT ("id") DO NOTHING`,
+				`INSERT INTO "users" ("id", "name") VALUES ('2', concat('b', '(x), y')) ON CONFLICT ("id") DO NOTHING`,
+			},
+		},
+		{
+			name:  "split_disabled",
+			split: false,
+			expected: []any{
+				`INSERT INTO "users" ("id", "name") VALUES ('1', 'a'), ('2', concat('b', '(x), y')) ON CONFLICT ("id") DO NOTHING`,
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// arrange
+			baseDriver := &fakeDriver{}
+			db := setUpFakeTestDB(t, baseDriver, audriver.WithSplitMultiRowInserts(tc.split))
+
+			// act
+			_, err := db.ExecContext(ctx, query, "1", "a", "2", "b")
+			require.NoError(t, err)
+
+			// assert
+			inserts := baseDriver.auditInserts()
+			require.Len(t, inserts, 1)
+			assert.Equal(t, tc.expected, inserts[0].values("sql"))
+		})
+	}
+}
+
+// TestAuditDriver_SourceTables tests that tables read by modifications besides their target are recorded
+func TestAuditDriver_SourceTables(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	ctx = audriver.WithOperatorID(ctx, uuid.New().String())
+	ctx = audriver.WithExecutionID(ctx, uuid.New().String())
+
+	testCases := []struct {
+		name     string
+		query    string
+		expected any
+	}{
+		{
+			name:     "insert_select_join",
+			query:    `INSERT INTO "a" ("id", "name") SELECT b.id, c.name FROM "b" JOIN c ON c.id = b.id WHERE b.created_at > $1`,
+			expected: `{"b","c"}`,
+		},
+		{
+			name:     "insert_select_comma_list",
+			query:    `INSERT INTO a SELECT x.id FROM public.b AS x, c y LEFT JOIN d ON d.id = y.id WHERE x.id = $1`,
+			expected: `{"public.b","c","d"}`,
+		},
+		{
+			name:     "insert_select_ignores_functions",
+			query:    `INSERT INTO a (id, year) SELECT g, EXTRACT(YEAR FROM now()) FROM generate_series(1, $1) g`,
+			expected: nil,
+		},
+		{
+			name:     "insert_values",
+			query:    `INSERT INTO a (id, name) VALUES ($1, 'from b')`,
+			expected: nil,
+		},
+		{
+			name:     "update_subquery",
+			query:    `UPDATE a SET name = (SELECT name FROM b WHERE b.id = $1)`,
+			expected: `{"b"}`,
+		},
+		{
+			name:     "update_from_join",
+			query:    `UPDATE "a" SET "name" = b.name FROM "b" JOIN c USING (id) WHERE a.id = b.id AND c.kind = $1`,
+			expected: `{"b","c"}`,
+		},
+		{
+			name:     "update_without_source",
+			query:    `UPDATE a SET name = $1`,
+			expected: nil,
+		},
+		{
+			name:     "delete_using",
+			query:    `DELETE FROM a USING b, c AS x WHERE a.id = b.id AND x.id = $1`,
+			expected: `{"b","c"}`,
+		},
+		{
+			name:     "delete_without_source",
+			query:    `DELETE FROM a WHERE id = $1`,
+			expected: nil,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// arrange
+			baseDriver := &fakeDriver{}
+			db := setUpFakeTestDB(t, baseDriver, audriver.WithSourceTables(true))
+
+			// act
+			_, err := db.ExecContext(ctx, tc.query, "1")
+			require.NoError(t, err)
+
+			// assert
+			inserts := baseDriver.auditInserts()
+			require.Len(t, inserts, 1)
+			assert.Equal(t, tc.expected, inserts[0].value("source_tables"))
+		})
+	}
+}
+
+// TestAuditDriver_ChangedColumns tests recording the columns assigned by the SET clause of updates
+func TestAuditDriver_ChangedColumns(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	ctx = audriver.WithOperatorID(ctx, uuid.New().String())
+	ctx = audriver.WithExecutionID(ctx, uuid.New().String())
+
+	testCases := []struct {
+		name     string
+		query    string
+		expected any
+	}{
+		{
+			name:     "update",
+			query:    `UPDATE users SET email = $1, "Name" = 'a, b = c' WHERE id = 1`,
+			expected: `{"email","Name"}`,
+		},
+		{
+			name:     "alias_and_expressions",
+			query:    `UPDATE users AS u SET age = COALESCE(age, 0) + $1, updated_at = now() FROM teams t WHERE t.id = u.team_id`,
+			expected: `{"age","updated_at"}`,
+		},
+		{
+			name:     "column_list",
+			query:    `UPDATE users SET (name, age) = (SELECT name, age FROM people WHERE id = $1), name = DEFAULT`,
+			expected: `{"name","age"}`,
+		},
+		{
+			name:     "fields_and_elements",
+			query:    `UPDATE users SET address.city = $1, tags[1] = 'x' RETURNING id`,
+			expected: `{"address","tags"}`,
+		},
+		{
+			name:     "is_distinct_from",
+			query:    `UPDATE users SET active = status IS DISTINCT FROM 'x', age = $1`,
+			expected: `{"active","age"}`,
+		},
+		{
+			name:     "data_modifying_cte",
+			query:    `WITH moved AS (UPDATE users SET team_id = $1 WHERE id = 2 RETURNING id) SELECT * FROM moved`,
+			expected: `{"team_id"}`,
+		},
+		{
+			name:     "delete",
+			query:    `DELETE FROM users WHERE email = $1`,
+			expected: nil,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// arrange
+			baseDriver := &fakeDriver{}
+			db := setUpFakeTestDB(t, baseDriver, audriver.WithChangedColumns(true))
+
+			// act
+			_, err := db.ExecContext(ctx, tc.query, "1")
+			require.NoError(t, err)
+
+			// assert
+			inserts := baseDriver.auditInserts()
+			require.Len(t, inserts, 1)
+			assert.Equal(t, tc.expected, inserts[0].value("changed_columns"))
+		})
+	}
+}
+
+// legacyAuditColumns are the columns of the audit table as created by the first release; see
+// postgres/05_create_legacy_audit_table.sql.
+var legacyAuditColumns = []string{"id", "operator_id", "execution_id", "table_name", "action", "sql", "modified_at"}
+
+// legacyStatements are statements whose audit records must fit the legacy audit table without opting into more columns.
+var legacyStatements = []string{
+	`UPDATE users SET name = 'legacy' WHERE id = $1`,
+	`INSERT INTO users (id, name, email) SELECT gen_random_uuid(), name, 'copy-' || email FROM users WHERE id = $1`,
+	`DELETE FROM public.users WHERE id = $1`,
+}
+
+// TestAuditDriver_LegacyAuditColumns tests that audit records are written to the columns of audit tables created by
+// the first release unless more are opted into
+func TestAuditDriver_LegacyAuditColumns(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	ctx = audriver.WithOperatorID(ctx, uuid.New().String())
+	ctx = audriver.WithExecutionID(ctx, uuid.New().String())
+
+	for _, query := range legacyStatements {
+		t.Run(query, func(t *testing.T) {
+			t.Parallel()
+
+			// arrange
+			baseDriver := &fakeDriver{}
+			db := setUpFakeTestDB(t, baseDriver)
+
+			// act
+			_, err := db.ExecContext(ctx, query, uuid.New().String())
+			require.NoError(t, err)
+
+			// assert
+			inserts := baseDriver.auditInserts()
+			require.Len(t, inserts, 1)
+			assert.Subset(t, legacyAuditColumns, inserts[0].columns())
+		})
+	}
+}
+
+// TestAuditDriver_LegacyAuditTable tests writing audit records to an audit table created by the first release
+func TestAuditDriver_LegacyAuditTable(t *testing.T) {
+	t.Parallel()
+
+	executionID := uuid.New().String()
+	ctx := t.Context()
+	ctx = audriver.WithOperatorID(ctx, uuid.New().String())
+	ctx = audriver.WithExecutionID(ctx, executionID)
+
+	// arrange
+	db := setUpWriterTestDB(t, audriver.WithAuditTable("legacy_database_modifications"))
+	userID := uuid.New().String()
+	_, err := db.ExecContext(ctx, `INSERT INTO users (id, name, email) VALUES ($1, $2, $3)`, userID, gofakeit.Name(), gofakeit.Email())
+	require.NoError(t, err)
+
+	// act
+	for _, query := range legacyStatements {
+		_, err := db.ExecContext(ctx, query, userID)
+
+		// assert
+		require.NoError(t, err, query)
+	}
+	var count int
+	require.NoError(t, db.QueryRowContext(ctx, "SELECT count(*) FROM legacy_database_modifications WHERE execution_id = $1", executionID).Scan(&count))
+	assert.Equal(t, 1+len(legacyStatements), count)
+}
+
+// TestAuditDriver_ArgCapture tests that captured arguments keep their types
+func TestAuditDriver_ArgCapture(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	ctx = audriver.WithOperatorID(ctx, uuid.New().String())
+	ctx = audriver.WithExecutionID(ctx, uuid.New().String())
+
+	// arrange
+	baseDriver := &fakeDriver{}
+	db := setUpFakeTestDB(t, baseDriver, audriver.WithArgCapture(true))
+	id := uuid.MustParse("0b4e8e2c-3f6c-4d8e-9a57-3d1f0e2b6c11")
+	createdAt := time.Date(2024, 1, 2, 3, 4, 5, 600, time.UTC)
+
+	// act
+	_, err := db.ExecContext(ctx, "INSERT INTO users (id, age, score, active, name, avatar, created_at, deleted_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)",
+		id, 42, 1.5, true, "John", []byte{0xca, 0xfe}, createdAt, nil)
+	require.NoError(t, err)
+	_, err = db.ExecContext(ctx, "DELETE FROM sessions")
+	require.NoError(t, err)
+
+	// assert
+	inserts := baseDriver.auditInserts()
+	require.Len(t, inserts, 2)
+	require.IsType(t, "", inserts[0].value("metadata"))
+	assert.JSONEq(t, `{"args": [
+		{"ordinal": 1, "type": "uuid", "value": "0b4e8e2c-3f6c-4d8e-9a57-3d1f0e2b6c11"},
+		{"ordinal": 2, "type": "int64", "value": 42},
+		{"ordinal": 3, "type": "float64", "value": 1.5},
+		{"ordinal": 4, "type": "bool", "value": true},
+		{"ordinal": 5, "type": "string", "value": "John"},
+		{"ordinal": 6, "type": "bytes", "value": "cafe"},
+		{"ordinal": 7, "type": "timestamp", "value": "2024-01-02T03:04:05.0000006Z"},
+		{"ordinal": 8, "type": "null", "value": null}
+	]}`, inserts[0].value("metadata").(string))
+	assert.Nil(t, inserts[1].value("metadata"), "statements without arguments should not capture any")
+}
+
+// TestAuditDriver_Anonymizer tests replacing literals and arguments with fakes of the anonymizer
+func TestAuditDriver_Anonymizer(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	ctx = audriver.WithOperatorID(ctx, uuid.New().String())
+	ctx = audriver.WithExecutionID(ctx, uuid.New().String())
+
+	// arrange
+	anonymizer := audriver.FormatPreservingAnonymizer([]byte("secret"))
+	baseDriver := &fakeDriver{}
+	db := setUpFakeTestDB(t, baseDriver, audriver.WithAnonymizer(anonymizer), audriver.WithArgCapture(true))
+	fakeEmail := anonymizer.Anonymize("john@example.com")
+	fakeAge := anonymizer.Anonymize("42")
+
+	// act
+	_, err := db.ExecContext(ctx, `UPDATE "users1" SET email = $1, age = 42 WHERE name = 'O''Brien' /* 7 */`, "john@example.com")
+	require.NoError(t, err)
+	_, err = db.ExecContext(ctx, "DELETE FROM users WHERE email = $1", "john@example.com")
+	require.NoError(t, err)
+
+	// assert
+	assert.NotEqual(t, "john@example.com", fakeEmail)
+	assert.Regexp(t, `^[a-z]{4}@[a-z]{7}\.[a-z]{3}$`, fakeEmail, "fakes should keep the format of values")
+	assert.Regexp(t, `^[0-9]{2}$`, fakeAge, "fakes of numbers should be numbers")
+
+	inserts := baseDriver.auditInserts()
+	require.Len(t, inserts, 2)
+	assert.Equal(t,
+		`UPDATE "users1" SET email = '`+fakeEmail+`', age = `+fakeAge+` WHERE name = '`+strings.ReplaceAll(anonymizer.Anonymize("O'Brien"), "'", "''")+`' /* 7 */`,
+		inserts[0].value("sql"),
+		"identifiers and comments should be kept",
+	)
+	assert.Equal(t, "DELETE FROM users WHERE email = '"+fakeEmail+"'", inserts[1].value("sql"), "fakes should be deterministic")
+	assert.NotContains(t, inserts[1].value("metadata"), "john@example.com", "captured arguments should be anonymized")
+}
+
+// recordingLogger records the modifications it is passed.
+type recordingLogger struct {
+	mu   sync.Mutex
+	mods []audriver.DatabaseModification
+}
+
+func (l *recordingLogger) Log(_ context.Context, mod audriver.DatabaseModification) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.mods = append(l.mods, mod)
+}
+
+// TestAuditDriver_RecordChecksums tests recording content checksums verifiable on copies read back from the audit table
+func TestAuditDriver_RecordChecksums(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	ctx = audriver.WithOperatorID(ctx, strings.ToUpper(uuid.New().String()))
+	ctx = audriver.WithExecutionID(ctx, uuid.New().String())
+
+	// arrange
+	baseDriver := &fakeDriver{}
+	logger := &recordingLogger{}
+	db := setUpFakeTestDB(t, baseDriver, audriver.WithRecordChecksums(true), audriver.WithArgCapture(true), audriver.WithLogger(logger))
+
+	// act
+	_, err := db.ExecContext(ctx, "UPDATE users SET name = $1 WHERE id = $2", "John", 42)
+	require.NoError(t, err)
+
+	// assert
+	inserts := baseDriver.auditInserts()
+	require.Len(t, inserts, 1)
+	require.Len(t, logger.mods, 1)
+	mod := logger.mods[0]
+	assert.Regexp(t, `^[0-9a-f]{64}$`, mod.Checksum)
+	assert.Equal(t, mod.Checksum, inserts[0].value("checksum"), "loggers should see the checksum written")
+	assert.True(t, audriver.VerifyChecksum(mod))
+
+	// a copy as read back from the audit table
+	stored := mod
+	stored.OperatorID = strings.ToLower(mod.OperatorID)
+	stored.ModifiedAt = mod.ModifiedAt.Truncate(time.Microsecond).In(time.FixedZone("JST", 9*60*60))
+	stored.Exactness = audriver.ExactnessExact
+	stored.Metadata = nil
+	require.NoError(t, json.Unmarshal([]byte(inserts[0].value("metadata").(string)), &stored.Metadata))
+	assert.True(t, audriver.VerifyChecksum(stored), "copies read back should verify")
+
+	tampered := stored
+	tampered.SQL = "UPDATE users SET name = 'Jane' WHERE id = 42"
+	assert.False(t, audriver.VerifyChecksum(tampered), "modified copies should not verify")
+	assert.False(t, audriver.VerifyChecksum(audriver.DatabaseModification{}), "records without checksums should not verify")
+}
+
+// TestAuditDriver_AuditSink tests writing audit records to a sink instead of the audit table
+func TestAuditDriver_AuditSink(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	ctx = audriver.WithOperatorID(ctx, uuid.New().String())
+	ctx = audriver.WithExecutionID(ctx, uuid.New().String())
+
+	t.Run("written", func(t *testing.T) {
+		t.Parallel()
+
+		// arrange
+		var (
+			mu      sync.Mutex
+			written [][]audriver.DatabaseModification
+		)
+		sink := audriver.AuditSinkFunc(func(_ context.Context, mods []audriver.DatabaseModification) error {
+			mu.Lock()
+			defer mu.Unlock()
+			written = append(written, slices.Clone(mods))
+			return nil
+		})
+		baseDriver := &fakeDriver{}
+		logger := &recordingLogger{}
+		db := setUpFakeTestDB(t, baseDriver, audriver.WithAuditSink(sink), audriver.WithRecordChecksums(true), audriver.WithLogger(logger))
+
+		// act
+		_, err := db.ExecContext(ctx, "DELETE FROM sessions WHERE id = $1", 1)
+		require.NoError(t, err)
+		tx, err := db.BeginTx(ctx, nil)
+		require.NoError(t, err)
+		_, err = tx.ExecContext(ctx, "INSERT INTO users (name) VALUES ($1)", "John")
+		require.NoError(t, err)
+		_, err = tx.ExecContext(ctx, "UPDATE users SET name = $1", "Jane")
+		require.NoError(t, err)
+		mu.Lock()
+		beforeCommit := len(written)
+		mu.Unlock()
+		require.NoError(t, tx.Commit())
+
+		// assert
+		assert.Empty(t, baseDriver.auditInserts(), "records should not be inserted on the connection")
+		assert.Equal(t, 1, beforeCommit, "records of transactions should be written on commit")
+		require.Len(t, written, 2)
+		assert.Equal(t, "DELETE FROM sessions WHERE id = '1'", written[0][0].SQL)
+		require.Len(t, written[1], 2)
+		assert.Equal(t, audriver.DatabaseModificationActionInsert, written[1][0].Action)
+		assert.Equal(t, audriver.DatabaseModificationActionUpdate, written[1][1].Action)
+		assert.NotEmpty(t, written[1][0].Checksum)
+		assert.Len(t, logger.mods, 3, "loggers should see records written to the sink")
+	})
+
+	t.Run("failed", func(t *testing.T) {
+		t.Parallel()
+
+		// arrange
+		sinkErr := errors.New("queue unavailable")
+		baseDriver := &fakeDriver{}
+		db := setUpFakeTestDB(t, baseDriver, audriver.WithAuditSink(audriver.AuditSinkFunc(func(context.Context, []audriver.DatabaseModification) error {
+			return sinkErr
+		})))
+
+		// act
+		tx, err := db.BeginTx(ctx, nil)
+		require.NoError(t, err)
+		_, err = tx.ExecContext(ctx, "DELETE FROM sessions")
+		require.NoError(t, err)
+		err = tx.Commit()
+
+		// assert
+		assert.ErrorIs(t, err, audriver.ErrAuditWriteFailed)
+		assert.ErrorIs(t, err, sinkErr)
+		var flushErr *audriver.FlushError
+		assert.ErrorAs(t, err, &flushErr, "the transaction should be rolled back")
+	})
+
+	t.Run("panicked", func(t *testing.T) {
+		t.Parallel()
+
+		// arrange
+		db := setUpFakeTestDB(t, &fakeDriver{}, audriver.WithAuditSink(audriver.AuditSinkFunc(func(context.Context, []audriver.DatabaseModification) error {
+			panic("boom")
+		})))
+
+		// act
+		_, err := db.ExecContext(ctx, "DELETE FROM sessions")
+
+		// assert
+		assert.ErrorIs(t, err, audriver.ErrPanicRecovered)
+	})
+}
+
+// TestAuditDriver_BatchWindow tests batching records written to the sink
+func TestAuditDriver_BatchWindow(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	ctx = audriver.WithOperatorID(ctx, uuid.New().String())
+	ctx = audriver.WithExecutionID(ctx, uuid.New().String())
+
+	// batchSink records the sizes of the batches written to it
+	type batchSink struct {
+		mu      sync.Mutex
+		batches []int
+	}
+	newSink := func() (*batchSink, audriver.AuditSink) {
+		s := &batchSink{}
+		return s, audriver.AuditSinkFunc(func(_ context.Context, mods []audriver.DatabaseModification) error {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			s.batches = append(s.batches, len(mods))
+			return nil
+		})
+	}
+	batches := func(s *batchSink) []int {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return slices.Clone(s.batches)
+	}
+
+	t.Run("records", func(t *testing.T) {
+		t.Parallel()
+
+		// arrange
+		s, sink := newSink()
+		db := setUpFakeTestDB(t, &fakeDriver{}, audriver.WithAuditSink(sink), audriver.WithBatchWindow(3, 0, 0))
+
+		// act
+		for range 7 {
+			_, err := db.ExecContext(ctx, "DELETE FROM sessions")
+			require.NoError(t, err)
+		}
+		beforeShutdown := batches(s)
+		require.NoError(t, db.Driver().(*audriver.Driver).Shutdown(ctx))
+
+		// assert
+		assert.Equal(t, []int{3, 3}, beforeShutdown)
+		assert.Equal(t, []int{3, 3, 1}, batches(s), "buffered records should be written on shutdown")
+
+		_, err := db.ExecContext(ctx, "DELETE FROM sessions")
+		require.NoError(t, err)
+		assert.Equal(t, []int{3, 3, 1, 1}, batches(s), "records should be written directly after shutdown")
+	})
+
+	t.Run("bytes", func(t *testing.T) {
+		t.Parallel()
+
+		// arrange
+		s, sink := newSink()
+		db := setUpFakeTestDB(t, &fakeDriver{}, audriver.WithAuditSink(sink), audriver.WithBatchWindow(0, 1000, 0))
+
+		// act
+		_, err := db.ExecContext(ctx, "DELETE FROM sessions")
+		require.NoError(t, err)
+		_, err = db.ExecContext(ctx, "DELETE FROM sessions WHERE token = $1", strings.Repeat("x", 1000))
+		require.NoError(t, err)
+
+		// assert
+		assert.Equal(t, []int{2}, batches(s))
+	})
+
+	t.Run("latency", func(t *testing.T) {
+		t.Parallel()
+
+		// arrange
+		s, sink := newSink()
+		db := setUpFakeTestDB(t, &fakeDriver{}, audriver.WithAuditSink(sink), audriver.WithBatchWindow(100, 0, 10*time.Millisecond))
+
+		// act
+		_, err := db.ExecContext(ctx, "DELETE FROM sessions")
+		require.NoError(t, err)
+		_, err = db.ExecContext(ctx, "DELETE FROM users")
+		require.NoError(t, err)
+
+		// assert
+		assert.Eventually(t, func() bool {
+			return slices.Equal(batches(s), []int{2})
+		}, time.Second, time.Millisecond)
+	})
+
+	t.Run("ordered by execution", func(t *testing.T) {
+		t.Parallel()
+
+		// arrange
+		var (
+			mu       sync.Mutex
+			received = map[string][]string{}
+		)
+		sink := audriver.AuditSinkFunc(func(_ context.Context, mods []audriver.DatabaseModification) error {
+			time.Sleep(time.Millisecond)
+			mu.Lock()
+			defer mu.Unlock()
+			for _, mod := range mods {
+				received[mod.ExecutionID] = append(received[mod.ExecutionID], mod.SQL)
+			}
+			return nil
+		})
+		db := setUpFakeTestDB(t, &fakeDriver{}, audriver.WithAuditSink(sink), audriver.WithBatchWindow(2, 0, time.Millisecond))
+		const executions, statements = 8, 20
+
+		// act
+		var wg sync.WaitGroup
+		for e := range executions {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				ctx := audriver.WithExecutionID(ctx, fmt.Sprint("execution-", e))
+				for i := range statements {
+					_, err := db.ExecContext(ctx, "DELETE FROM sessions WHERE id = $1", i)
+					assert.NoError(t, err)
+				}
+			}()
+		}
+		wg.Wait()
+		require.NoError(t, db.Driver().(*audriver.Driver).Shutdown(ctx))
+
+		// assert
+		require.Len(t, received, executions)
+		for execution, sqls := range received {
+			expected := make([]string, statements)
+			for i := range expected {
+				expected[i] = fmt.Sprintf("DELETE FROM sessions WHERE id = '%d'", i)
+			}
+			assert.Equal(t, expected, sqls, "records of %s should be delivered in order", execution)
+		}
+	})
+
+	t.Run("failed", func(t *testing.T) {
+		t.Parallel()
+
+		// arrange
+		sinkErr := errors.New("queue unavailable")
+		var handled atomic.Int32
+		db := setUpFakeTestDB(t, &fakeDriver{},
+			audriver.WithAuditSink(audriver.AuditSinkFunc(func(context.Context, []audriver.DatabaseModification) error {
+				return sinkErr
+			})),
+			audriver.WithBatchWindow(2, 0, 0),
+			audriver.WithErrorHandler(func(_ context.Context, err error, stage audriver.ErrorStage, _ *audriver.DatabaseModification) {
+				if errors.Is(err, sinkErr) && stage == audriver.ErrorStageFlush {
+					handled.Add(1)
+				}
+			}),
+		)
+
+		// act
+		_, err1 := db.ExecContext(ctx, "DELETE FROM sessions")
+		_, err2 := db.ExecContext(ctx, "DELETE FROM users")
+
+		// assert
+		assert.NoError(t, err1)
+		assert.NoError(t, err2, "statements should not fail after they are done")
+		assert.Equal(t, int32(2), handled.Load(), "failures should be reported for each record of the batch")
+		assert.NoError(t, db.Driver().(*audriver.Driver).Shutdown(ctx))
+	})
+
+	t.Run("transactions", func(t *testing.T) {
+		t.Parallel()
+
+		testCases := []struct {
+			name      string
+			commitErr error
+			expected  []int
+		}{
+			{
+				name:     "committed",
+				expected: []int{2},
+			},
+			{
+				name:      "failed_commit",
+				commitErr: errors.New("could not serialize access"),
+			},
+		}
+
+		for _, tc := range testCases {
+			tc := tc
+			t.Run(tc.name, func(t *testing.T) {
+				t.Parallel()
+
+				// arrange
+				s, sink := newSink()
+				db := setUpFakeTestDB(t, &fakeDriver{commitErr: tc.commitErr},
+					audriver.WithAuditSink(sink),
+					audriver.WithBatchWindow(1, 0, 0),
+					audriver.WithFlushThreshold(1),
+				)
+				tx, err := db.BeginTx(ctx, nil)
+				require.NoError(t, err)
+
+				// act
+				_, err = tx.ExecContext(ctx, "DELETE FROM sessions")
+				require.NoError(t, err)
+				_, err = tx.ExecContext(ctx, "DELETE FROM users")
+				require.NoError(t, err)
+				beforeCommit := batches(s)
+				err = tx.Commit()
+				require.NoError(t, db.Driver().(*audriver.Driver).Shutdown(ctx))
+
+				// assert
+				assert.ErrorIs(t, err, tc.commitErr)
+				assert.Empty(t, beforeCommit, "records should not be buffered before commit")
+				assert.Equal(t, tc.expected, batches(s))
+			})
+		}
+	})
+}
+
+// TestAuditDriver_DeadLetterQueue tests putting records of failed batches into the dead letter queue and redelivering them
+func TestAuditDriver_DeadLetterQueue(t *testing.T) {
+	t.Parallel()
+
+	// arrange
+	ctx := t.Context()
+	ctx = audriver.WithOperatorID(ctx, uuid.New().String())
+	ctx = audriver.WithExecutionID(ctx, uuid.New().String())
+
+	var (
+		mu      sync.Mutex
+		failing = true
+		written []string
+	)
+	sinkErr := errors.New("queue unavailable")
+	sink := audriver.AuditSinkFunc(func(_ context.Context, mods []audriver.DatabaseModification) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if failing {
+			return sinkErr
+		}
+		for _, mod := range mods {
+			written = append(written, mod.SQL)
+		}
+		return nil
+	})
+	queue := audriver.NewFileDeadLetterQueue(filepath.Join(t.TempDir(), "dead_letters"))
+	db := setUpFakeTestDB(t, &fakeDriver{},
+		audriver.WithAuditSink(sink),
+		audriver.WithBatchWindow(2, 0, 0),
+		audriver.WithAuditRetry(audriver.AuditRetry{MaxRetries: 2, Backoff: time.Millisecond, Retryable: func(error) bool { return true }}),
+		audriver.WithDeadLetterQueue(queue),
+	)
+	auditDriver := db.Driver().(*audriver.Driver)
+
+	// act
+	_, err := db.ExecContext(ctx, "DELETE FROM sessions")
+	require.NoError(t, err)
+	_, err = db.ExecContext(ctx, "DELETE FROM users")
+	require.NoError(t, err)
+
+	// assert
+	stats := auditDriver.AuditStats()
+	assert.Equal(t, int64(2), stats.RetriedWrites)
+	assert.Equal(t, int64(2), stats.DeadLetters)
+
+	var letters []audriver.DeadLetter
+	err = queue.Replay(ctx, func(_ context.Context, batch []audriver.DeadLetter) error {
+		letters = append(letters, batch...)
+		return errors.New("inspected only")
+	})
+	require.Error(t, err)
+	require.Len(t, letters, 2)
+	assert.Equal(t, "DELETE FROM sessions", letters[0].Modification.SQL)
+	assert.Contains(t, letters[0].Error, "queue unavailable", "letters should hold the reason")
+	assert.False(t, letters[0].FailedAt.IsZero())
+
+	n, err := auditDriver.Redeliver(ctx)
+	assert.ErrorIs(t, err, sinkErr)
+	assert.Zero(t, n, "records should be kept while the sink fails")
+
+	mu.Lock()
+	failing = false
+	mu.Unlock()
+	n, err = auditDriver.Redeliver(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+	assert.Equal(t, []string{"DELETE FROM sessions", "DELETE FROM users"}, written)
+
+	n, err = auditDriver.Redeliver(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, n, "redelivered records should be removed from the queue")
+}
+
+// TestAuditDriver_AsyncLogging tests writing audit records by background workers with each backpressure policy
+func TestAuditDriver_AsyncLogging(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name            string
+		policy          audriver.BackpressurePolicy
+		expectedErr     error
+		expectedDropped int64
+	}{
+		{
+			name:        "block",
+			policy:      audriver.BackpressureBlock,
+			expectedErr: audriver.ErrAuditTimeout,
+		},
+		{
+			name:            "drop",
+			policy:          audriver.BackpressureDrop,
+			expectedDropped: 1,
+		},
+		{
+			name:        "error",
+			policy:      audriver.BackpressureError,
+			expectedErr: audriver.ErrAuditQueueFull,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// arrange
+			ctx := t.Context()
+			ctx = audriver.WithOperatorID(ctx, uuid.New().String())
+			ctx = audriver.WithExecutionID(ctx, uuid.New().String())
+
+			var (
+				mu      sync.Mutex
+				written []string
+				started = make(chan struct{}, 1)
+				release = make(chan struct{})
+			)
+			sink := audriver.AuditSinkFunc(func(_ context.Context, mods []audriver.DatabaseModification) error {
+				select {
+				case started <- struct{}{}:
+				default:
+				}
+				<-release
+				mu.Lock()
+				defer mu.Unlock()
+				for _, mod := range mods {
+					written = append(written, mod.SQL)
+				}
+				return nil
+			})
+			var handled []error
+			db := setUpFakeTestDB(t, &fakeDriver{},
+				audriver.WithAuditSink(sink),
+				audriver.WithAsyncLogging(1, 1),
+				audriver.WithBackpressurePolicy(tc.policy),
+				audriver.WithErrorHandler(func(_ context.Context, err error, stage audriver.ErrorStage, _ *audriver.DatabaseModification) {
+					if stage == audriver.ErrorStageFlush {
+						handled = append(handled, err)
+					}
+				}),
+			)
+			auditDriver := db.Driver().(*audriver.Driver)
+
+			// act
+			_, err := db.ExecContext(ctx, "DELETE FROM sessions")
+			require.NoError(t, err, "statements should not wait for the sink")
+			<-started
+			_, err = db.ExecContext(ctx, "DELETE FROM users")
+			require.NoError(t, err, "records should be queued while the worker is busy")
+
+			fullCtx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+			defer cancel()
+			_, fullErr := db.ExecContext(fullCtx, "DELETE FROM posts")
+
+			close(release)
+			require.NoError(t, auditDriver.Shutdown(ctx))
+
+			// assert
+			assert.NoError(t, fullErr, "statements should not fail once they are committed")
+			if tc.expectedErr != nil {
+				require.Len(t, handled, 1, "failures to enqueue should be reported")
+				assert.ErrorIs(t, handled[0], audriver.ErrAuditWriteFailed)
+				assert.ErrorIs(t, handled[0], tc.expectedErr)
+			} else {
+				assert.Empty(t, handled)
+			}
+			assert.Equal(t, tc.expectedDropped, auditDriver.AuditStats().DroppedRecords)
+			assert.Equal(t, []string{"DELETE FROM sessions", "DELETE FROM users"}, written, "queued records should be written in order on shutdown")
+
+			_, err = db.ExecContext(ctx, "DELETE FROM comments")
+			require.NoError(t, err)
+			assert.Equal(t, "DELETE FROM comments", written[len(written)-1], "records should be written directly after shutdown")
+		})
+	}
+}
+
+// TestAuditDriver_AsyncLogging_Transactions tests enqueueing records of transactions only once they are committed
+func TestAuditDriver_AsyncLogging_Transactions(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name      string
+		commitErr error
+		expected  []string
+	}{
+		{
+			name:     "committed",
+			expected: []string{"DELETE FROM sessions", "DELETE FROM users"},
+		},
+		{
+			name:      "failed_commit",
+			commitErr: errors.New("could not serialize access"),
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// arrange
+			ctx := t.Context()
+			ctx = audriver.WithOperatorID(ctx, uuid.New().String())
+			ctx = audriver.WithExecutionID(ctx, uuid.New().String())
+
+			var (
+				mu      sync.Mutex
+				written []string
+			)
+			sink := audriver.AuditSinkFunc(func(_ context.Context, mods []audriver.DatabaseModification) error {
+				mu.Lock()
+				defer mu.Unlock()
+				for _, mod := range mods {
+					written = append(written, mod.SQL)
+				}
+				return nil
+			})
+			db := setUpFakeTestDB(t, &fakeDriver{commitErr: tc.commitErr},
+				audriver.WithAuditSink(sink),
+				audriver.WithAsyncLogging(10, 1),
+				audriver.WithFlushThreshold(1),
+			)
+			tx, err := db.BeginTx(ctx, nil)
+			require.NoError(t, err)
+
+			// act
+			_, err = tx.ExecContext(ctx, "DELETE FROM sessions")
+			require.NoError(t, err)
+			_, err = tx.ExecContext(ctx, "DELETE FROM users")
+			require.NoError(t, err)
+			err = tx.Commit()
+			require.NoError(t, db.Driver().(*audriver.Driver).Shutdown(ctx))
+
+			// assert
+			assert.ErrorIs(t, err, tc.commitErr)
+			assert.Equal(t, tc.expected, written, "records of transactions failing to commit should not be written")
+		})
+	}
+}
+
+// TestAuditDriver_BackpressureShedding tests the pressure level of async logging and shedding non-critical statements
+func TestAuditDriver_BackpressureShedding(t *testing.T) {
+	t.Parallel()
+
+	// arrange
+	ctx := t.Context()
+	ctx = audriver.WithOperatorID(ctx, uuid.New().String())
+	ctx = audriver.WithExecutionID(ctx, uuid.New().String())
+	nonCritical := audriver.WithBackpressureShedding(ctx, 0.5)
+
+	started := make(chan struct{}, 1)
+	release := make(chan struct{})
+	sink := audriver.AuditSinkFunc(func(context.Context, []audriver.DatabaseModification) error {
+		select {
+		case started <- struct{}{}:
+		default:
+		}
+		<-release
+		return nil
+	})
+	baseDriver := &fakeDriver{}
+	db := setUpFakeTestDB(t, baseDriver, audriver.WithAuditSink(sink), audriver.WithAsyncLogging(2, 1))
+	auditDriver := db.Driver().(*audriver.Driver)
+
+	// act
+	_, err := db.ExecContext(ctx, "DELETE FROM sessions")
+	require.NoError(t, err)
+	<-started
+	idle := auditDriver.PressureLevel()
+	_, err = db.ExecContext(nonCritical, "DELETE FROM caches")
+	require.NoError(t, err, "non-critical statements should be executed below the level")
+	half := auditDriver.PressureLevel()
+	_, shedErr := db.ExecContext(nonCritical, "DELETE FROM caches WHERE id = 1")
+	_, criticalErr := db.ExecContext(ctx, "DELETE FROM users")
+	full := auditDriver.PressureLevel()
+
+	close(release)
+	require.NoError(t, auditDriver.Shutdown(ctx))
+
+	// assert
+	assert.Zero(t, idle)
+	assert.Equal(t, 0.5, half)
+	assert.Equal(t, 1.0, full)
+	assert.ErrorIs(t, shedErr, audriver.ErrAuditBackpressure)
+	assert.NoError(t, criticalErr, "critical statements should not be shed")
+	for _, exec := range baseDriver.executed() {
+		assert.NotEqual(t, "DELETE FROM caches WHERE id = 1", exec.query, "shed statements should not be executed")
+	}
+	assert.Zero(t, auditDriver.PressureLevel(), "the pressure should be released on shutdown")
+}
+
+// TestFileDeadLetterQueue_TornWrites tests recovering the letters of dead letter files torn by crashes
+func TestFileDeadLetterQueue_TornWrites(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name     string
+		tear     func(data []byte) []byte
+		expected []string
+	}{
+		{
+			name:     "torn last entry",
+			tear:     func(data []byte) []byte { return data[:len(data)-3] },
+			expected: []string{"first", "third"},
+		},
+		{
+			name:     "torn header",
+			tear:     func(data []byte) []byte { return append(data, "ASP1\x00"...) },
+			expected: []string{"first", "second", "third"},
+		},
+		{
+			name: "corrupted entry",
+			tear: func(data []byte) []byte {
+				data[len(data)-2] ^= 0xff
+				return data
+			},
+			expected: []string{"first", "third"},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// arrange
+			ctx := t.Context()
+			path := filepath.Join(t.TempDir(), "dead_letters")
+			queue := audriver.NewFileDeadLetterQueue(path)
+			put := func(sql string) {
+				letter := audriver.DeadLetter{Modification: audriver.DatabaseModification{SQL: sql}, Error: "failed"}
+				require.NoError(t, queue.Put(ctx, []audriver.DeadLetter{letter}))
+			}
+			put("first")
+			put("second")
+			data, err := os.ReadFile(path)
+			require.NoError(t, err)
+			require.NoError(t, os.WriteFile(path, tc.tear(data), 0o600))
+			put("third")
+
+			// act
+			var replayed []string
+			err = queue.Replay(ctx, func(_ context.Context, letters []audriver.DeadLetter) error {
+				for _, letter := range letters {
+					replayed = append(replayed, letter.Modification.SQL)
+				}
+				return errors.New("inspected only")
+			})
+
+			// assert
+			require.Error(t, err)
+			assert.Equal(t, tc.expected, replayed, "letters around torn entries should be kept")
+
+			cleanPath := filepath.Join(t.TempDir(), "clean")
+			clean := audriver.NewFileDeadLetterQueue(cleanPath)
+			for _, sql := range tc.expected {
+				letter := audriver.DeadLetter{Modification: audriver.DatabaseModification{SQL: sql}, Error: "failed"}
+				require.NoError(t, clean.Put(ctx, []audriver.DeadLetter{letter}))
+			}
+			expected, err := os.ReadFile(cleanPath)
+			require.NoError(t, err)
+			repaired, err := os.ReadFile(path)
+			require.NoError(t, err)
+			assert.Equal(t, expected, repaired, "torn entries should be dropped on replay")
+
+			replayed = nil
+			require.NoError(t, queue.Replay(ctx, func(_ context.Context, letters []audriver.DeadLetter) error {
+				for _, letter := range letters {
+					replayed = append(replayed, letter.Modification.SQL)
+				}
+				return nil
+			}))
+			assert.Equal(t, tc.expected, replayed)
+			assert.NoFileExists(t, path, "the file should be removed once replayed")
+		})
+	}
+}
+
+// TestDBSink tests inserting audit records into a separate database
+func TestDBSink(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name    string
+		records int
+		sink    audriver.DBSink
+		inserts []string
+	}{
+		{
+			name:    "single statement",
+			records: 2,
+			inserts: []string{"INSERT INTO database_modifications "},
+		},
+		{
+			name:    "chunked",
+			records: 1001,
+			sink:    audriver.DBSink{Table: "audit.modifications", Dialect: audriver.MySQL},
+			inserts: []string{"INSERT INTO audit.modifications ", "INSERT INTO audit.modifications "},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// arrange
+			baseDriver := &fakeDriver{}
+			driverName := fmt.Sprintf("fake_test_%s_%d", t.Name(), gofakeit.Number(1000, 9999))
+			sql.Register(driverName, baseDriver)
+			db, err := sql.Open(driverName, driverName)
+			require.NoError(t, err)
+			t.Cleanup(func() {
+				_ = db.Close()
+			})
+			sink := tc.sink
+			sink.DB = db
+			mods := make([]audriver.DatabaseModification, tc.records)
+			for i := range mods {
+				mods[i] = audriver.DatabaseModification{ID: uuid.New().String(), TableName: "users", Action: audriver.DatabaseModificationActionDelete}
+			}
+
+			// act
+			err = sink.Write(t.Context(), mods)
+
+			// assert
+			require.NoError(t, err)
+			execs := baseDriver.executed()
+			require.Len(t, execs, len(tc.inserts))
+			rows := 0
+			for i, exec := range execs {
+				assert.True(t, strings.HasPrefix(exec.query, tc.inserts[i]), exec.query)
+				rows += len(exec.values("id"))
+			}
+			assert.Equal(t, tc.records, rows)
+		})
+	}
+}
+
+// TestAuditDriver_MySQL tests parsing and interpolating MySQL statements and writing their audit records with MySQL syntax
+func TestAuditDriver_MySQL(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	ctx = audriver.WithOperatorID(ctx, uuid.New().String())
+	ctx = audriver.WithExecutionID(ctx, uuid.New().String())
+
+	// arrange
+	baseDriver := &fakeDriver{lastInsertID: 7}
+	logger := &recordingLogger{}
+	db := setUpFakeTestDB(t, baseDriver, audriver.WithDialect(audriver.MySQL), audriver.WithAuditRole("audriver_writer"), audriver.WithLogger(logger), audriver.WithChangedColumns(true), audriver.WithSchemaNames(true))
+
+	// act
+	_, err := db.ExecContext(ctx, "UPDATE `app`.`users` SET name = ?, note = 'it\\'s ? # UPDATE t' WHERE id = ? # DELETE FROM t", `O'Brien \ Sons`, 42)
+	require.NoError(t, err)
+	_, err = db.ExecContext(ctx, "INSERT INTO `users` (name) VALUES (?)", "John")
+	require.NoError(t, err)
+
+	// assert
+	require.Len(t, logger.mods, 2)
+	update := logger.mods[0]
+	assert.Equal(t, "app", update.SchemaName)
+	assert.Equal(t, "users", update.TableName)
+	assert.Equal(t, audriver.DatabaseModificationActionUpdate, update.Action)
+	assert.Equal(t, "UPDATE `app`.`users` SET name = 'O''Brien \\\\ Sons', note = 'it\\'s ? # UPDATE t' WHERE id = '42' # DELETE FROM t", update.SQL)
+	assert.Equal(t, []string{"name", "note"}, update.ChangedColumns)
+
+	inserts := baseDriver.auditInserts()
+	require.Len(t, inserts, 2)
+	assert.NotContains(t, inserts[0].query, "$")
+	assert.Contains(t, inserts[0].query, "VALUES (?, ?, ?")
+	assert.Contains(t, inserts[0].query, "`sql`", "reserved words should be quoted")
+	assert.Equal(t, `["name","note"]`, inserts[0].value("changed_columns"), "arrays should be JSON")
+	assert.Equal(t, `["7"]`, inserts[1].value("record_ids"))
+
+	var roles []string
+	for _, exec := range baseDriver.executed() {
+		if strings.HasPrefix(exec.query, "SET ROLE") {
+			roles = append(roles, exec.query)
+		}
+	}
+	assert.Equal(t, []string{"SET ROLE `audriver_writer`", "SET ROLE DEFAULT", "SET ROLE `audriver_writer`", "SET ROLE DEFAULT"}, roles)
+}
+
+// TestAuditDriver_SQLite tests parsing and interpolating SQLite statements and writing their audit records with SQLite
+// syntax
+func TestAuditDriver_SQLite(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	ctx = audriver.WithOperatorID(ctx, uuid.New().String())
+	ctx = audriver.WithExecutionID(ctx, uuid.New().String())
+
+	// arrange
+	baseDriver := &fakeDriver{lastInsertID: 7}
+	logger := &recordingLogger{}
+	db := setUpFakeTestDB(t, baseDriver, audriver.WithDialect(audriver.SQLite), audriver.WithLogger(logger), audriver.WithChangedColumns(true), audriver.WithSchemaNames(true))
+
+	// act
+	_, err := db.ExecContext(ctx, "UPDATE [users] SET name = :name, note = '-- DELETE FROM t' WHERE id = ?2 -- UPDATE t", sql.Named("name", "O'Brien"), 42)
+	require.NoError(t, err)
+	_, err = db.ExecContext(ctx, `INSERT INTO "users" (name) VALUES (?)`, "John")
+	require.NoError(t, err)
+
+	// assert
+	require.Len(t, logger.mods, 2)
+	update := logger.mods[0]
+	assert.Equal(t, "users", update.TableName)
+	assert.Equal(t, audriver.DatabaseModificationActionUpdate, update.Action)
+	assert.Equal(t, "UPDATE [users] SET name = 'O''Brien', note = '-- DELETE FROM t' WHERE id = '42' -- UPDATE t", update.SQL)
+	assert.Equal(t, []string{"name", "note"}, update.ChangedColumns)
+
+	inserts := baseDriver.auditInserts()
+	require.Len(t, inserts, 2)
+	assert.Contains(t, inserts[0].query, "VALUES (?1, ?2, ?3")
+	assert.Equal(t, `["name","note"]`, inserts[0].value("changed_columns"), "arrays should be JSON")
+	assert.Equal(t, `["7"]`, inserts[1].value("record_ids"))
+}
+
+// TestAuditDriver_WherePredicates tests recording simple comparisons of WHERE clauses in the metadata column
+func TestAuditDriver_WherePredicates(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	ctx = audriver.WithOperatorID(ctx, uuid.New().String())
+	ctx = audriver.WithExecutionID(ctx, uuid.New().String())
+
+	testCases := []struct {
+		name     string
+		query    string
+		args     []any
+		expected any
+	}{
+		{
+			name:     "delete",
+			query:    `DELETE FROM sessions WHERE user_id = $1`,
+			args:     []any{"u1"},
+			expected: `{"where": [{"column": "user_id", "op": "=", "value": "u1"}]}`,
+		},
+		{
+			name:  "conjunction",
+			query: `UPDATE users u SET active = false WHERE u.tenant_id = $1 AND age BETWEEN 1 AND 2 AND "Status" NOT IN ('a', 'b''c') AND deleted_at IS NULL AND name != 'x' RETURNING id`,
+			args:  []any{42},
+			expected: `{"where": [
+				{"column": "tenant_id", "op": "=", "value": "42"},
+				{"column": "Status", "op": "NOT IN", "values": ["a", "b'c"]},
+				{"column": "deleted_at", "op": "IS NULL"},
+				{"column": "name", "op": "<>", "value": "x"}
+			]}`,
+		},
+		{
+			name:     "non_literal_comparisons",
+			query:    `DELETE FROM orders WHERE created_at < now() AND id IN (SELECT order_id FROM refunds) AND note LIKE $1`,
+			args:     []any{"%or%"},
+			expected: `{"where": [{"column": "note", "op": "LIKE", "value": "%or%"}]}`,
+		},
+		{
+			name:     "disjunction",
+			query:    `DELETE FROM sessions WHERE user_id = $1 OR expired`,
+			args:     []any{"u1"},
+			expected: nil,
+		},
+		{
+			name:     "insert",
+			query:    `INSERT INTO sessions (user_id) VALUES ($1)`,
+			args:     []any{"u1"},
+			expected: nil,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// arrange
+			baseDriver := &fakeDriver{}
+			db := setUpFakeTestDB(t, baseDriver, audriver.WithWherePredicates(true))
+
+			// act
+			_, err := db.ExecContext(ctx, tc.query, tc.args...)
+			require.NoError(t, err)
+
+			// assert
+			inserts := baseDriver.auditInserts()
+			require.Len(t, inserts, 1)
+			metadata := inserts[0].value("metadata")
+			if tc.expected == nil {
+				assert.Nil(t, metadata)
+				return
+			}
+			require.IsType(t, "", metadata)
+			assert.JSONEq(t, tc.expected.(string), metadata.(string))
+		})
+	}
+}
+
+// TestAuditDriver_SchemaResolution tests that schemas and foreign tables are resolved against the catalog
+func TestAuditDriver_SchemaResolution(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	ctx = audriver.WithOperatorID(ctx, uuid.New().String())
+	ctx = audriver.WithExecutionID(ctx, uuid.New().String())
+
+	catalog := map[string][]driver.Value{
+		"users":        {"app", "r"},
+		"remote_users": {"public", "f"},
+	}
+
+	testCases := []struct {
+		name            string
+		query           string
+		resolve         bool
+		schemaNames     bool
+		expectedSchema  any
+		expectedTable   any
+		expectedForeign any
+	}{
+		{
+			name:            "unqualified_table_is_resolved",
+			query:           `INSERT INTO users (id) VALUES ($1)`,
+			resolve:         true,
+			expectedSchema:  "app",
+			expectedTable:   "users",
+			expectedForeign: nil,
+		},
+		{
+			name:            "foreign_table_is_flagged",
+			query:           `UPDATE "remote_users" SET name = 'name' WHERE id = $1`,
+			resolve:         true,
+			expectedSchema:  "public",
+			expectedTable:   "remote_users",
+			expectedForeign: true,
+		},
+		{
+			name:            "qualified_table_without_resolution",
+			query:           `DELETE FROM "other"."users" WHERE id = $1`,
+			schemaNames:     true,
+			expectedSchema:  "other",
+			expectedTable:   "users",
+			expectedForeign: nil,
+		},
+		{
+			name:            "quoted_identifiers_with_dots",
+			query:           `UPDATE "my.schema"."user.table" SET name = 'a.b' WHERE id = $1`,
+			schemaNames:     true,
+			expectedSchema:  "my.schema",
+			expectedTable:   "user.table",
+			expectedForeign: nil,
+		},
+		{
+			name:            "quoted_table_with_dot",
+			query:           `DELETE FROM other."user.table" WHERE id = $1`,
+			schemaNames:     true,
+			expectedSchema:  "other",
+			expectedTable:   "user.table",
+			expectedForeign: nil,
+		},
+		{
+			name:            "qualified_table_without_schema_names",
+			query:           `DELETE FROM "other"."users" WHERE id = $1`,
+			expectedSchema:  nil,
+			expectedTable:   "users",
+			expectedForeign: nil,
+		},
+		{
+			name:            "unqualified_table_without_resolution",
+			query:           `DELETE FROM users WHERE id = $1`,
+			schemaNames:     true,
+			expectedSchema:  nil,
+			expectedTable:   "users",
+			expectedForeign: nil,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// arrange
+			baseDriver := &fakeDriver{
+				query: func(query string, args []driver.NamedValue) ([]string, [][]driver.Value) {
+					row, ok := catalog[args[0].Value.(string)]
+					if !ok {
+						return []string{"nspname", "relkind"}, nil
+					}
+					return []string{"nspname", "relkind"}, [][]driver.Value{row}
+				},
+			}
+			options := []audriver.Option{audriver.WithSchemaNames(tc.schemaNames)}
+			if tc.resolve {
+				options = append(options, audriver.WithSchemaResolution("app", "public"))
+			}
+			db := setUpFakeTestDB(t, baseDriver, options...)
+
+			// act
+			_, err := db.ExecContext(ctx, tc.query, uuid.New().String())
+			require.NoError(t, err)
+
+			// assert
+			inserts := baseDriver.auditInserts()
+			require.Len(t, inserts, 1)
+			assert.Equal(t, tc.expectedSchema, inserts[0].value("schema_name"))
+			assert.Equal(t, tc.expectedTable, inserts[0].value("table_name"))
+			assert.Equal(t, tc.expectedForeign, inserts[0].value("foreign_table"))
+		})
+	}
+}
+
+// TestAuditDriver_UnknownAction tests that modifying statements which cannot be parsed are recorded as unknown
+func TestAuditDriver_UnknownAction(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	ctx = audriver.WithOperatorID(ctx, uuid.New().String())
+	ctx = audriver.WithExecutionID(ctx, uuid.New().String())
+
+	testCases := []struct {
+		name  string
+		query string
+	}{
+		{
+			name:  "insert_ignore",
+			query: "INSERT IGNORE INTO `users` (`id`) VALUES (?)",
+		},
+		{
+			name:  "multi_table_delete",
+			query: "DELETE u FROM `users` u JOIN `orders` o ON o.user_id = u.id WHERE o.id = ?",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// arrange
+			baseDriver := &fakeDriver{}
+			db := setUpFakeTestDB(t, baseDriver, audriver.WithTableFilters(audriver.NewIncludePatternFilter("orders")))
+
+			// act
+			_, err := db.ExecContext(ctx, tc.query, "1")
+			require.NoError(t, err)
+
+			// assert
+			inserts := baseDriver.auditInserts()
+			require.Len(t, inserts, 1)
+			assert.Equal(t, audriver.DatabaseModificationActionUnknown.String(), inserts[0].value("action"))
+			assert.Equal(t, "", inserts[0].value("table_name"))
+			assert.Equal(t, tc.query, inserts[0].value("sql"))
+		})
+	}
+}
+
+// TestAuditDriver_Classification tests classifying statements with comments, literals, and common table expressions
+func TestAuditDriver_Classification(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	ctx = audriver.WithOperatorID(ctx, uuid.New().String())
+	ctx = audriver.WithExecutionID(ctx, uuid.New().String())
+
+	testCases := []struct {
+		name           string
+		query          string
+		expectedAction any
+		expectedTable  any
+	}{
+		{
+			name:           "nested_comments",
+			query:          "/* outer /* inner */ still a comment */ DELETE FROM users WHERE id = $1",
+			expectedAction: "delete",
+			expectedTable:  "users",
+		},
+		{
+			name:           "keyword_in_string",
+			query:          "UPDATE users SET note = 'INSERT INTO orders' WHERE id = $1",
+			expectedAction: "update",
+			expectedTable:  "users",
+		},
+		{
+			name:           "keyword_in_dollar_quoted_string",
+			query:          "UPDATE users SET note = $$DELETE FROM orders$$ WHERE id = $1",
+			expectedAction: "update",
+			expectedTable:  "users",
+		},
+		{
+			name:           "with_recursive",
+			query:          "WITH RECURSIVE t(n) AS (SELECT 1 UNION ALL SELECT n + 1 FROM t WHERE n < $1) DELETE FROM users WHERE id IN (SELECT n FROM t)",
+			expectedAction: "delete",
+			expectedTable:  "users",
+		},
+		{
+			name:           "data_modifying_cte",
+			query:          "WITH d AS (DELETE FROM sessions WHERE user_id = $1 RETURNING id) SELECT count(*) FROM d",
+			expectedAction: "delete",
+			expectedTable:  "sessions",
+		},
+		{
+			name:  "cte_locking_rows",
+			query: "WITH l AS (SELECT id FROM users WHERE id = $1 FOR UPDATE) SELECT * FROM l",
+		},
+		{
+			name:  "keyword_in_comment",
+			query: "SELECT $1 -- DELETE FROM users",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// arrange
+			baseDriver := &fakeDriver{}
+			db := setUpFakeTestDB(t, baseDriver)
+
+			// act
+			_, err := db.ExecContext(ctx, tc.query, "1")
+			require.NoError(t, err)
+
+			// assert
+			inserts := baseDriver.auditInserts()
+			if tc.expectedAction == nil {
+				assert.Empty(t, inserts)
+				return
+			}
+			require.Len(t, inserts, 1)
+			assert.Equal(t, tc.expectedAction, inserts[0].value("action"))
+			assert.Equal(t, tc.expectedTable, inserts[0].value("table_name"))
+		})
+	}
+}
+
+// TestAuditDriver_StrictParsing tests that unparseable modifying statements are rejected in strict parsing mode
+func TestAuditDriver_StrictParsing(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	ctx = audriver.WithOperatorID(ctx, uuid.New().String())
+	ctx = audriver.WithExecutionID(ctx, uuid.New().String())
+
+	// arrange
+	baseDriver := &fakeDriver{}
+	db := setUpFakeTestDB(t, baseDriver, audriver.WithStrictParsing(true))
+
+	// act
+	_, err := db.ExecContext(ctx, "INSERT IGNORE INTO `users` (`id`) VALUES (?)", "1")
+
+	// assert
+	require.Error(t, err)
+	assert.ErrorIs(t, err, audriver.ErrUnclassifiedStatement)
+	assert.Empty(t, baseDriver.executed(), "unclassified statements should not be executed")
+
+	// act - classifiable statements are still executed
+	_, err = db.ExecContext(ctx, "INSERT INTO `users` (`id`) VALUES (?)", "1")
+
+	// assert
+	require.NoError(t, err)
+	assert.Len(t, baseDriver.auditInserts(), 1)
+}
+
+// TestAuditDriver_LogCorrelation tests that audited statements carry the execution ID as a comment
+func TestAuditDriver_LogCorrelation(t *testing.T) {
+	t.Parallel()
+
+	executionID := uuid.New().String()
+	ctx := t.Context()
+	ctx = audriver.WithOperatorID(ctx, uuid.New().String())
+	ctx = audriver.WithExecutionID(ctx, executionID)
+
+	// arrange
+	baseDriver := &fakeDriver{}
+	db := setUpFakeTestDB(t, baseDriver, audriver.WithLogCorrelation(true))
+	query := "INSERT INTO users (id) VALUES ($1)"
+
+	// act
+	_, err := db.ExecContext(ctx, query, "1")
+	require.NoError(t, err)
+	_, err = db.ExecContext(ctx, "CREATE TABLE tmp (id INT)")
+	require.NoError(t, err)
+
+	// assert
+	executed := baseDriver.executed()
+	require.Len(t, executed, 3)
+	assert.Equal(t, query+" /* audriver_execution_id="+executionID+" */", executed[0].query)
+	assert.Equal(t, "CREATE TABLE tmp (id INT)", executed[2].query, "unaudited statements should not be annotated")
+
+	inserts := baseDriver.auditInserts()
+	require.Len(t, inserts, 1)
+	assert.Equal(t, "INSERT INTO users (id) VALUES ('1')", inserts[0].value("sql"), "recorded SQL should not include the comment")
+}
+
+// TestAuditDriver_QueryAnnotation tests that outgoing statements carry sqlcommenter comments with the audit identifiers
+func TestAuditDriver_QueryAnnotation(t *testing.T) {
+	t.Parallel()
+
+	// arrange
+	var queries []string
+	baseDriver := &fakeDriver{
+		query: func(query string, _ []driver.NamedValue) ([]string, [][]driver.Value) {
+			queries = append(queries, query)
+			return nil, nil
+		},
+	}
+	db := setUpFakeTestDB(t, baseDriver, audriver.WithQueryAnnotation(true))
+
+	ctx := t.Context()
+	ctx = audriver.WithOperatorID(ctx, "operator 1")
+	ctx = audriver.WithExecutionID(ctx, "execution/1")
+	comment := " /*execution_id='execution%2F1',operator_id='operator%201'*/"
+
+	// act
+	_, err := db.ExecContext(ctx, "INSERT INTO users (id) VALUES ($1)", "1")
+	require.NoError(t, err)
+	rows, err := db.QueryContext(ctx, "SELECT id FROM users")
+	require.NoError(t, err)
+	require.NoError(t, rows.Close())
+	rows, err = db.QueryContext(t.Context(), "SELECT 1")
+	require.NoError(t, err)
+	require.NoError(t, rows.Close())
+
+	// assert
+	executed := baseDriver.executed()
+	require.NotEmpty(t, executed)
+	assert.Equal(t, "INSERT INTO users (id) VALUES ($1)"+comment, executed[0].query)
+	assert.Equal(t, []string{"SELECT id FROM users" + comment, "SELECT 1"}, queries)
+
+	inserts := baseDriver.auditInserts()
+	require.Len(t, inserts, 1)
+	assert.Equal(t, "INSERT INTO users (id) VALUES ('1')", inserts[0].value("sql"), "recorded SQL should not include the comment")
+}
+
+// TestAuditDriver_QueryRewriters tests that statements are executed and recorded as rewritten
+func TestAuditDriver_QueryRewriters(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	ctx = audriver.WithOperatorID(ctx, uuid.New().String())
+	ctx = audriver.WithExecutionID(ctx, uuid.New().String())
+
+	hint := audriver.QueryRewriterFunc(func(_ context.Context, query string) (string, error) {
+		return "/*+ SeqScan(users) */ " + query, nil
+	})
+	errRejected := errors.New("rejected")
+	reject := audriver.QueryRewriterFunc(func(_ context.Context, query string) (string, error) {
+		if strings.Contains(query, "DELETE") {
+			return "", errRejected
+		}
+		return query, nil
+	})
+
+	// arrange
+	baseDriver := &fakeDriver{}
+	db := setUpFakeTestDB(t, baseDriver, audriver.WithQueryRewriters(hint, reject))
+
+	// act
+	_, err := db.ExecContext(ctx, "UPDATE users SET name = $1", "John")
+	require.NoError(t, err)
+	_, deleteErr := db.ExecContext(ctx, "DELETE FROM users")
+
+	// assert
+	require.ErrorIs(t, deleteErr, errRejected)
+
+	executed := baseDriver.executed()
+	require.Len(t, executed, 2)
+	assert.Equal(t, "/*+ SeqScan(users) */ UPDATE users SET name = $1", executed[0].query)
+
+	inserts := baseDriver.auditInserts()
+	require.Len(t, inserts, 1)
+	assert.Equal(t, "/*+ SeqScan(users) */ UPDATE users SET name = 'John'", inserts[0].value("sql"))
+}
+
+// instrumentedDriver is a driver.Driver wrapper counting opened connections, standing in for instrumentation like otelsql.
+type instrumentedDriver struct {
+	driver.Driver
+	opened int
+}
+
+func (d *instrumentedDriver) Open(name string) (driver.Conn, error) {
+	d.opened++
+	return d.Driver.Open(name)
+}
+
+// TestAuditDriver_NewInstrumented tests that the audit driver is layered on top of the instrumented driver
+func TestAuditDriver_NewInstrumented(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	ctx = audriver.WithOperatorID(ctx, uuid.New().String())
+	ctx = audriver.WithExecutionID(ctx, uuid.New().String())
+
+	// arrange
+	baseDriver := &fakeDriver{}
+	instrumented := &instrumentedDriver{}
+	auditDriver := audriver.NewInstrumented(baseDriver, func(d driver.Driver) driver.Driver {
+		instrumented.Driver = d
+		return instrumented
+	})
+	driverName := fmt.Sprintf("fake_instrumented_%s_%d", t.Name(), gofakeit.Number(1000, 9999))
+	sql.Register(driverName, auditDriver)
+	db, err := sql.Open(driverName, driverName)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = db.Close()
+	})
+
+	// act
+	require.NoError(t, db.PingContext(ctx))
+	_, err = db.ExecContext(ctx, "INSERT INTO users (id) VALUES ($1)", "1")
+
+	// assert
+	require.NoError(t, err)
+	assert.Equal(t, 1, instrumented.opened)
+	assert.Len(t, baseDriver.auditInserts(), 1)
+}
+
+// TestAuditDriver_Errors tests that audit failures can be distinguished with errors.Is
+func TestAuditDriver_Errors(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name        string
+		operatorID  string
+		executionID string
+		auditErr    error
+		want        error
+	}{
+		{
+			name:        "missing_operator_id",
+			executionID: uuid.New().String(),
+			want:        audriver.ErrMissingOperatorID,
+		},
+		{
+			name:       "missing_execution_id",
+			operatorID: uuid.New().String(),
+			want:       audriver.ErrMissingExecutionID,
+		},
+		{
+			name:        "audit_write_failed",
+			operatorID:  uuid.New().String(),
+			executionID: uuid.New().String(),
+			auditErr:    errors.New("permission denied for table database_modifications"),
+			want:        audriver.ErrAuditWriteFailed,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// arrange
+			ctx := t.Context()
+			if tc.operatorID != "" {
+				ctx = audriver.WithOperatorID(ctx, tc.operatorID)
+			}
+			if tc.executionID != "" {
+				ctx = audriver.WithExecutionID(ctx, tc.executionID)
+			}
+			baseDriver := &fakeDriver{auditErr: tc.auditErr}
+			db := setUpFakeTestDB(t, baseDriver)
+
+			// act
+			_, err := db.ExecContext(ctx, "DELETE FROM users")
+
+			// assert
+			assert.ErrorIs(t, err, tc.want)
+		})
+	}
+}
+
+// TestAuditDriver_ErrorHandler tests that the error handler is invoked with the failing stage and modification
+func TestAuditDriver_ErrorHandler(t *testing.T) {
+	t.Parallel()
+
+	type call struct {
+		stage audriver.ErrorStage
+		sql   string
+	}
+
+	testCases := []struct {
+		name       string
+		operatorID string
+		auditErr   error
+		want       call
+	}{
+		{
+			name: "build",
+			want: call{stage: audriver.ErrorStageBuild},
+		},
+		{
+			name:       "flush",
+			operatorID: uuid.New().String(),
+			auditErr:   errors.New("connection reset"),
+			want:       call{stage: audriver.ErrorStageFlush, sql: "DELETE FROM users"},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// arrange
+			ctx := audriver.WithExecutionID(t.Context(), uuid.New().String())
+			if tc.operatorID != "" {
+				ctx = audriver.WithOperatorID(ctx, tc.operatorID)
+			}
+
+			var calls []call
+			handler := func(_ context.Context, err error, stage audriver.ErrorStage, mod *audriver.DatabaseModification) {
+				require.Error(t, err)
+				c := call{stage: stage}
+				if mod != nil {
+					c.sql = mod.SQL
+				}
+				calls = append(calls, c)
+			}
+			baseDriver := &fakeDriver{auditErr: tc.auditErr}
+			db := setUpFakeTestDB(t, baseDriver, audriver.WithErrorHandler(handler))
+
+			// act
+			_, err := db.ExecContext(ctx, "DELETE FROM users")
+
+			// assert
+			require.Error(t, err)
+			assert.Equal(t, []call{tc.want}, calls)
+		})
+	}
+}
+
+// TestAuditDriver_CommitFlushError tests that failures of flushing on commit and of the following rollback can be inspected
+func TestAuditDriver_CommitFlushError(t *testing.T) {
+	t.Parallel()
+
+	flushErr := errors.New("permission denied for table database_modifications")
+	rollbackErr := errors.New("connection reset")
+
+	testCases := []struct {
+		name        string
+		rollbackErr error
+	}{
+		{
+			name: "flush",
+		},
+		{
+			name:        "flush_and_rollback",
+			rollbackErr: rollbackErr,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// arrange
+			ctx := audriver.WithOperatorID(t.Context(), uuid.New().String())
+			ctx = audriver.WithExecutionID(ctx, uuid.New().String())
+			baseDriver := &fakeDriver{auditErr: flushErr, rollbackErr: tc.rollbackErr}
+			db := setUpFakeTestDB(t, baseDriver)
+
+			tx, err := db.BeginTx(ctx, nil)
+			require.NoError(t, err)
+			_, err = tx.ExecContext(ctx, "DELETE FROM users")
+			require.NoError(t, err)
+
+			// act
+			err = tx.Commit()
+
+			// assert
+			var flush *audriver.FlushError
+			require.ErrorAs(t, err, &flush)
+			assert.ErrorIs(t, flush, flushErr)
+			assert.ErrorIs(t, err, audriver.ErrAuditWriteFailed)
+
+			var rollback *audriver.RollbackError
+			if tc.rollbackErr == nil {
+				assert.False(t, errors.As(err, &rollback))
+				return
+			}
+			require.ErrorAs(t, err, &rollback)
+			assert.ErrorIs(t, rollback, tc.rollbackErr)
+			assert.NotErrorIs(t, flush, tc.rollbackErr)
+		})
+	}
+}
+
+// sqlStateError is a driver error carrying a SQLSTATE code, like those of lib/pq and pgx.
+type sqlStateError string
+
+func (e sqlStateError) Error() string {
+	return "pq: error " + string(e)
+}
+
+func (e sqlStateError) SQLState() string {
+	return string(e)
+}
+
+// TestAuditDriver_AuditErrorClassification tests classifying audit write failures for retry decisions
+func TestAuditDriver_AuditErrorClassification(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name      string
+		auditErr  error
+		want      error
+		retryable bool
+	}{
+		{
+			name:      "statement_timeout",
+			auditErr:  sqlStateError("57014"),
+			want:      audriver.ErrAuditTimeout,
+			retryable: true,
+		},
+		{
+			name:      "context_deadline",
+			auditErr:  context.DeadlineExceeded,
+			want:      audriver.ErrAuditTimeout,
+			retryable: true,
+		},
+		{
+			name:      "lock_timeout",
+			auditErr:  sqlStateError("55P03"),
+			want:      audriver.ErrAuditLockContention,
+			retryable: true,
+		},
+		{
+			name:      "deadlock",
+			auditErr:  fmt.Errorf("wrapped: %w", sqlStateError("40P01")),
+			want:      audriver.ErrAuditLockContention,
+			retryable: true,
+		},
+		{
+			name:      "permission_denied",
+			auditErr:  sqlStateError("42501"),
+			want:      audriver.ErrAuditWriteFailed,
+			retryable: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := t.Context()
+			ctx = audriver.WithOperatorID(ctx, uuid.New().String())
+			ctx = audriver.WithExecutionID(ctx, uuid.New().String())
+
+			// arrange
+			baseDriver := &fakeDriver{auditErr: tc.auditErr}
+			db := setUpFakeTestDB(t, baseDriver)
+
+			// act
+			_, err := db.ExecContext(ctx, "DELETE FROM users")
+
+			// assert
+			require.ErrorIs(t, err, audriver.ErrAuditWriteFailed)
+			require.ErrorIs(t, err, tc.want)
+			require.ErrorIs(t, err, tc.auditErr)
+			assert.Equal(t, tc.retryable, audriver.AuditRetryable(err))
+		})
+	}
+}
+
+// TestAuditDriver_AuditRetry tests retrying audit writes failing with transient errors
+func TestAuditDriver_AuditRetry(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name          string
+		auditErr      error
+		auditFailures int
+		wantErr       bool
+		wantRetries   int64
+	}{
+		{
+			name:          "recovers",
+			auditErr:      sqlStateError("40001"),
+			auditFailures: 2,
+			wantRetries:   2,
+		},
+		{
+			name:          "failover",
+			auditErr:      sqlStateError("57P01"),
+			auditFailures: 1,
+			wantRetries:   1,
+		},
+		{
+			name:          "exhausted",
+			auditErr:      sqlStateError("55P03"),
+			auditFailures: 4,
+			wantErr:       true,
+			wantRetries:   3,
+		},
+		{
+			name:          "not_retryable",
+			auditErr:      sqlStateError("42501"),
+			auditFailures: 1,
+			wantErr:       true,
+			wantRetries:   0,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := t.Context()
+			ctx = audriver.WithOperatorID(ctx, uuid.New().String())
+			ctx = audriver.WithExecutionID(ctx, uuid.New().String())
+
+			// arrange
+			// failed inserts abort the transaction the statement is executed in, as on PostgreSQL
+			baseDriver := &fakeDriver{auditErr: tc.auditErr, auditFailures: tc.auditFailures, abortTransactions: true}
+			auditDriver := audriver.New(baseDriver, audriver.WithAuditRetry(audriver.AuditRetry{
+				MaxRetries: 3,
+				Backoff:    time.Millisecond,
+			}))
+			driverName := fmt.Sprintf("fake_retry_%s_%d", t.Name(), gofakeit.Number(1000, 9999))
+			sql.Register(driverName, auditDriver)
+			db, err := sql.Open(driverName, driverName)
+			require.NoError(t, err)
+			t.Cleanup(func() {
+				_ = db.Close()
+			})
+
+			// act
+			_, err = db.ExecContext(ctx, "DELETE FROM users")
+
+			// assert
+			if tc.wantErr {
+				require.ErrorIs(t, err, tc.auditErr)
+				assert.Empty(t, baseDriver.auditInserts())
+			} else {
+				require.NoError(t, err)
+				assert.Len(t, baseDriver.auditInserts(), 1)
+			}
+			assert.Equal(t, tc.wantRetries, auditDriver.(*audriver.Driver).AuditStats().RetriedWrites)
+		})
+	}
+}
+
+// TestAuditDriver_PanicRecovery tests that panics of user-supplied code are recovered and reported
+func TestAuditDriver_PanicRecovery(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	ctx = audriver.WithOperatorID(ctx, uuid.New().String())
+	ctx = audriver.WithExecutionID(ctx, uuid.New().String())
+
+	testCases := []struct {
+		name      string
+		option    audriver.Option
+		wantErr   bool
+		wantStage audriver.ErrorStage
+	}{
+		{
+			name: "extractor",
+			option: audriver.WithOperatorIDExtractor(audriver.OperatorIDExtractorFunc(func(context.Context) (string, error) {
+				panic("extractor bug")
+			})),
+			wantErr:   true,
+			wantStage: audriver.ErrorStageBuild,
+		},
+		{
+			name: "table_filter",
+			option: audriver.WithTableFilters(audriver.TableFilterFunc(func(string) bool {
+				panic("filter bug")
+			})),
+			wantErr:   true,
+			wantStage: audriver.ErrorStageBuild,
+		},
+		{
+			name:      "logger",
+			option:    audriver.WithLogger(panicLogger{}),
+			wantErr:   false,
+			wantStage: audriver.ErrorStageFlush,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// arrange
+			var handled []error
+			var stages []audriver.ErrorStage
+			handler := func(_ context.Context, err error, stage audriver.ErrorStage, _ *audriver.DatabaseModification) {
+				handled = append(handled, err)
+				stages = append(stages, stage)
+			}
+			baseDriver := &fakeDriver{}
+			db := setUpFakeTestDB(t, baseDriver, tc.option, audriver.WithErrorHandler(handler))
+
+			// act
+			_, err := db.ExecContext(ctx, "DELETE FROM users")
+
+			// assert
+			if tc.wantErr {
+				assert.ErrorIs(t, err, audriver.ErrPanicRecovered)
+			} else {
+				assert.NoError(t, err)
+			}
+			require.Len(t, handled, 1)
+			assert.ErrorIs(t, handled[0], audriver.ErrPanicRecovered)
+			assert.Equal(t, []audriver.ErrorStage{tc.wantStage}, stages)
+		})
+	}
+}
+
+type panicLogger struct{}
+
+func (panicLogger) Log(context.Context, audriver.DatabaseModification) {
+	panic("logger bug")
+}
+
+// TestAuditDriver_ConfigurationIsCopied tests that changes to values passed to options after construction are ignored
+func TestAuditDriver_ConfigurationIsCopied(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	ctx = audriver.WithOperatorID(ctx, uuid.New().String())
+	ctx = audriver.WithExecutionID(ctx, uuid.New().String())
+
+	// arrange
+	filters := []audriver.TableFilter{audriver.NewExcludePatternFilter("users")}
+	baseDriver := &fakeDriver{}
+	db := setUpFakeTestDB(t, baseDriver, audriver.WithTableFilters(filters...))
+	filters[0] = audriver.NewExcludePatternFilter("orders")
+
+	// act
+	_, err := db.ExecContext(ctx, "DELETE FROM users")
+	require.NoError(t, err)
+	_, err = db.ExecContext(ctx, "DELETE FROM orders")
+	require.NoError(t, err)
+
+	// assert
+	inserts := baseDriver.auditInserts()
+	require.Len(t, inserts, 1)
+	assert.Equal(t, "orders", inserts[0].value("table_name"))
+}
+
+// TestAuditDriver_ConcurrentConnections tests concurrent opens and executions sharing the driver configuration.
+// Run with -race to detect data races.
+func TestAuditDriver_ConcurrentConnections(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	ctx = audriver.WithOperatorID(ctx, uuid.New().String())
+	ctx = audriver.WithExecutionID(ctx, uuid.New().String())
+
+	// arrange
+	baseDriver := &fakeDriver{}
+	db := setUpFakeTestDB(t, baseDriver,
+		audriver.WithTableFilters(audriver.NewExcludePatternFilter("sessions")),
+		audriver.WithActions(audriver.DatabaseModificationActionInsert, audriver.DatabaseModificationActionUpdate),
+	)
+	db.SetMaxIdleConns(0) // force a new connection for each operation
+
+	const goroutines = 20
+
+	// act
+	var wg sync.WaitGroup
+	errs := make(chan error, goroutines*2)
+	for i := 0; i < goroutines; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			if _, err := db.ExecContext(ctx, "INSERT INTO users (id) VALUES ($1)", i); err != nil {
+				errs <- err
+				return
+			}
+
+			tx, err := db.BeginTx(ctx, nil)
+			if err != nil {
+				errs <- err
+				return
+			}
+			if _, err := tx.ExecContext(ctx, "UPDATE users SET name = $1 WHERE id = $2", "John", i); err != nil {
+				errs <- err
+				_ = tx.Rollback()
+				return
+			}
+			if err := tx.Commit(); err != nil {
+				errs <- err
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	// assert
+	for err := range errs {
+		require.NoError(t, err)
+	}
+	assert.Len(t, baseDriver.auditInserts(), goroutines*2)
+}
+
+func BenchmarkAuditDriver_Transaction(b *testing.B) {
+	ctx := b.Context()
+	ctx = audriver.WithOperatorID(ctx, uuid.New().String())
+	ctx = audriver.WithExecutionID(ctx, uuid.New().String())
+
+	driverName := fmt.Sprintf("fake_bench_%s_%d", b.Name(), gofakeit.Number(1000, 9999))
+	sql.Register(driverName, audriver.New(&discardDriver{}))
+	db, err := sql.Open(driverName, driverName)
+	require.NoError(b, err)
+	b.Cleanup(func() {
+		_ = db.Close()
+	})
+
+	b.ReportAllocs()
+	for b.Loop() {
+		tx, err := db.BeginTx(ctx, nil)
+		require.NoError(b, err)
+		for i := 0; i < 100; i++ {
+			_, err := tx.ExecContext(ctx, "UPDATE users SET name = $1, age = $2 WHERE id = $3", "John", i, "3f1c5a52-8d4e-4d1b-9a57-6f0e2b7c9d11")
+			require.NoError(b, err)
+		}
+		require.NoError(b, tx.Commit())
+	}
+}
+
+// TestAuditDriver_FlushThreshold tests that buffered modifications are flushed into the transaction at the threshold
+func TestAuditDriver_FlushThreshold(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	ctx = audriver.WithOperatorID(ctx, uuid.New().String())
+	ctx = audriver.WithExecutionID(ctx, uuid.New().String())
+
+	// arrange
+	baseDriver := &fakeDriver{}
+	db := setUpFakeTestDB(t, baseDriver, audriver.WithFlushThreshold(2))
+
+	tx, err := db.BeginTx(ctx, nil)
+	require.NoError(t, err)
+
+	// act
+	for i := 0; i < 5; i++ {
+		_, err = tx.ExecContext(ctx, `UPDATE "users" SET "age" = $1`, i)
+		require.NoError(t, err)
+	}
+
+	// assert
+	inserts := baseDriver.auditInserts()
+	require.Len(t, inserts, 2, "modifications should be flushed every 2 statements")
+	assert.Len(t, inserts[0].values("sql"), 2)
+	assert.Len(t, inserts[1].values("sql"), 2)
+
+	require.NoError(t, tx.Commit())
+
+	inserts = baseDriver.auditInserts()
+	require.Len(t, inserts, 3)
+	assert.Equal(t, []any{`UPDATE "users" SET "age" = '4'`}, inserts[2].values("sql"))
+}
+
+// TestAuditDriver_AuditStatementCache tests that audit inserts are prepared once per connection and reused
+func TestAuditDriver_AuditStatementCache(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	ctx = audriver.WithOperatorID(ctx, uuid.New().String())
+	ctx = audriver.WithExecutionID(ctx, uuid.New().String())
+
+	// arrange
+	baseDriver := &fakeDriver{}
+	db := setUpFakeTestDB(t, baseDriver, audriver.WithAuditStatementCache(4))
+	db.SetMaxOpenConns(1)
+
+	// act
+	for i := 0; i < 3; i++ {
+		_, err := db.ExecContext(ctx, "UPDATE users SET age = $1", i)
+		require.NoError(t, err)
+	}
+	tx, err := db.BeginTx(ctx, nil)
+	require.NoError(t, err)
+	_, err = tx.ExecContext(ctx, "UPDATE users SET age = $1", 3)
+	require.NoError(t, err)
+	require.NoError(t, tx.Commit())
+
+	// assert
+	inserts := baseDriver.auditInserts()
+	require.Len(t, inserts, 4)
+	for _, insert := range inserts {
+		assert.True(t, insert.prepared)
+	}
+	baseDriver.mu.Lock()
+	defer baseDriver.mu.Unlock()
+	assert.Equal(t, 1, baseDriver.prepares, "the single-row audit insert should be prepared once")
+}
+
+// TestAuditDriver_AuditStats tests the cumulative audit counters of the driver
+func TestAuditDriver_AuditStats(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	ctx = audriver.WithOperatorID(ctx, uuid.New().String())
+	ctx = audriver.WithExecutionID(ctx, uuid.New().String())
+
+	// arrange
+	baseDriver := &fakeDriver{}
+	auditDriver := audriver.New(baseDriver, audriver.WithTableFilters(audriver.NewExcludePatternFilter("sessions")))
+	driverName := fmt.Sprintf("fake_stats_%s_%d", t.Name(), gofakeit.Number(1000, 9999))
+	sql.Register(driverName, auditDriver)
+	db, err := sql.Open(driverName, driverName)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = db.Close()
+	})
+
+	// act
+	_, err = db.ExecContext(ctx, "UPDATE users SET age = $1", 1)
+	require.NoError(t, err)
+	_, err = db.ExecContext(ctx, "DELETE FROM sessions")
+	require.NoError(t, err)
+	_, err = db.ExecContext(ctx, "CREATE TABLE tmp (id INT)")
+	require.NoError(t, err)
+	_, err = db.ExecContext(t.Context(), "DELETE FROM users")
+	require.Error(t, err)
+
+	tx, err := db.BeginTx(ctx, nil)
+	require.NoError(t, err)
+	for i := 0; i < 3; i++ {
+		_, err = tx.ExecContext(ctx, "UPDATE users SET age = $1", i)
+		require.NoError(t, err)
+	}
+	require.NoError(t, tx.Commit())
+
+	// assert
+	stats := auditDriver.(*audriver.Driver).AuditStats()
+	assert.Equal(t, audriver.AuditStats{
+		Audited:         4,
+		SkippedFiltered: 1,
+		SkippedNonDML:   1,
+		Failed:          1,
+		FlushedBatches:  2,
+		MaxBatchSize:    3,
+		EnabledConns:    1,
+		EnablementRate:  1,
+	}, stats)
+}
+
+// TestAuditDriver_OperatorRateAnomaly tests tracking the modification rates of operators and reporting anomalies
+func TestAuditDriver_OperatorRateAnomaly(t *testing.T) {
+	t.Parallel()
+
+	// arrange
+	ctx := audriver.WithExecutionID(t.Context(), uuid.New().String())
+	runaway, regular := uuid.New().String(), uuid.New().String()
+
+	var (
+		mu        sync.Mutex
+		anomalies []audriver.OperatorRate
+	)
+	db := setUpFakeTestDB(t, &fakeDriver{}, audriver.WithOperatorRateAnomaly(audriver.OperatorRateAnomaly{
+		Threshold: 3,
+		Window:    time.Hour,
+		OnAnomaly: func(_ context.Context, rate audriver.OperatorRate) {
+			mu.Lock()
+			defer mu.Unlock()
+			anomalies = append(anomalies, rate)
+		},
+	}))
+
+	// act
+	runawayCtx := audriver.WithOperatorID(ctx, runaway)
+	tx, err := db.BeginTx(runawayCtx, nil)
+	require.NoError(t, err)
+	for i := 0; i < 2; i++ {
+		_, err = tx.ExecContext(runawayCtx, "UPDATE users SET age = $1", i)
+		require.NoError(t, err)
+	}
+	require.NoError(t, tx.Commit())
+	for i := 0; i < 3; i++ {
+		_, err = db.ExecContext(runawayCtx, "DELETE FROM sessions")
+		require.NoError(t, err)
+	}
+	_, err = db.ExecContext(audriver.WithOperatorID(ctx, regular), "DELETE FROM sessions")
+	require.NoError(t, err)
+
+	// assert
+	assert.Equal(t, []audriver.OperatorRate{{OperatorID: runaway, Modifications: 4, Window: time.Hour}}, anomalies,
+		"operators should be reported once per window")
+	stats := db.Driver().(*audriver.Driver).AuditStats()
+	assert.Equal(t, int64(1), stats.RateAnomalies)
+	assert.Equal(t, map[string]int64{runaway: 5, regular: 1}, stats.OperatorRates)
+}
+
+// TestAuditDriver_TableSampling tests that sampled tables are recorded partially and marked as sampled
+func TestAuditDriver_TableSampling(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	ctx = audriver.WithOperatorID(ctx, uuid.New().String())
+	ctx = audriver.WithExecutionID(ctx, uuid.New().String())
+
+	// arrange
+	baseDriver := &fakeDriver{}
+	db := setUpFakeTestDB(t, baseDriver, audriver.WithTableSampling(map[string]float64{
+		"page_views": 1,
+		"heartbeats": 0,
+	}))
+
+	tx, err := db.BeginTx(ctx, nil)
+	require.NoError(t, err)
+
+	// act
+	_, err = tx.ExecContext(ctx, "INSERT INTO page_views (path) VALUES ($1)", "/")
+	require.NoError(t, err)
+	_, err = tx.ExecContext(ctx, "INSERT INTO heartbeats (at) VALUES (now())")
+	require.NoError(t, err)
+	_, err = tx.ExecContext(ctx, "UPDATE users SET name = $1", "John")
+	require.NoError(t, err)
+	require.NoError(t, tx.Commit())
+
+	_, err = db.ExecContext(ctx, "DELETE FROM users")
+	require.NoError(t, err)
+
+	// assert
+	inserts := baseDriver.auditInserts()
+	require.Len(t, inserts, 2)
+	assert.Equal(t, []any{"page_views", "users"}, inserts[0].values("table_name"))
+	assert.Equal(t, []any{"sampled", "exact"}, inserts[0].values("exactness"))
+	assert.Nil(t, inserts[1].value("exactness"), "exactness should be omitted for batches of exact records")
+}
+
+// TestAuditDriver_AuditTable tests writing audit records to a custom table
+func TestAuditDriver_AuditTable(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	ctx = audriver.WithOperatorID(ctx, uuid.New().String())
+	ctx = audriver.WithExecutionID(ctx, uuid.New().String())
+
+	// arrange
+	baseDriver := &fakeDriver{}
+	db := setUpFakeTestDB(t, baseDriver, audriver.WithAuditTable("audit.modifications"))
+
+	// act
+	_, err := db.ExecContext(ctx, "UPDATE users SET age = $1", 1)
+	require.NoError(t, err)
+
+	// assert
+	assert.Empty(t, baseDriver.auditInserts())
+	executed := baseDriver.executed()
+	require.Len(t, executed, 2)
+	assert.True(t, strings.HasPrefix(executed[1].query, "INSERT INTO audit.modifications (id, operator_id,"))
+}
+
+// TestAuditDriver_OperatorResolver tests recording resolved operators
+func TestAuditDriver_OperatorResolver(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	ctx = audriver.WithExecutionID(ctx, uuid.New().String())
+
+	// arrange
+	var (
+		mu       sync.Mutex
+		calls    int
+		failures []audriver.ErrorStage
+	)
+	resolver := audriver.OperatorResolverFunc(func(_ context.Context, operatorID string) (audriver.Operator, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		calls++
+		if operatorID == "unknown" {
+			return audriver.Operator{}, errors.New("not found")
+		}
+		return audriver.Operator{Name: "Jane", Email: "jane@example.com"}, nil
+	})
+	baseDriver := &fakeDriver{}
+	db := setUpFakeTestDB(t, baseDriver,
+		audriver.WithOperatorResolver(resolver),
+		audriver.WithErrorHandler(func(_ context.Context, _ error, stage audriver.ErrorStage, _ *audriver.DatabaseModification) {
+			mu.Lock()
+			defer mu.Unlock()
+			failures = append(failures, stage)
+		}),
+	)
+
+	// act
+	for i := 0; i < 2; i++ {
+		_, err := db.ExecContext(audriver.WithOperatorID(ctx, "jane"), "UPDATE users SET age = $1", i)
+		require.NoError(t, err)
+	}
+	_, err := db.ExecContext(audriver.WithOperatorID(ctx, "unknown"), "DELETE FROM users")
+	require.NoError(t, err)
+
+	// assert
+	inserts := baseDriver.auditInserts()
+	require.Len(t, inserts, 3)
+	assert.Equal(t, "Jane", inserts[0].value("operator_name"))
+	assert.Equal(t, "jane@example.com", inserts[1].value("operator_email"))
+	assert.Nil(t, inserts[2].value("operator_name"), "unresolved operators should be recorded without a name")
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, 2, calls, "resolved operators should be cached")
+	assert.Equal(t, []audriver.ErrorStage{audriver.ErrorStageBuild}, failures)
+}
+
+// TestAuditDriver_TransactionHandler tests the summaries of finished transactions
+func TestAuditDriver_TransactionHandler(t *testing.T) {
+	t.Parallel()
+
+	executionID := uuid.New().String()
+	ctx := t.Context()
+	ctx = audriver.WithOperatorID(ctx, uuid.New().String())
+	ctx = audriver.WithExecutionID(ctx, executionID)
+
+	// arrange
+	var (
+		mu        sync.Mutex
+		summaries []audriver.TransactionSummary
+	)
+	baseDriver := &fakeDriver{}
+	db := setUpFakeTestDB(t, baseDriver, audriver.WithTransactionHandler(func(_ context.Context, summary audriver.TransactionSummary) {
+		mu.Lock()
+		defer mu.Unlock()
+		summaries = append(summaries, summary)
+	}))
+
+	// act
+	tx, err := db.BeginTx(ctx, nil)
+	require.NoError(t, err)
+	_, err = tx.ExecContext(ctx, "UPDATE users SET age = $1", 1)
+	require.NoError(t, err)
+	_, err = tx.ExecContext(ctx, "DELETE FROM sessions")
+	require.NoError(t, err)
+	_, err = tx.ExecContext(ctx, "SET LOCAL lock_timeout = '1s'")
+	require.NoError(t, err)
+	require.NoError(t, tx.Commit())
+
+	tx, err = db.BeginTx(ctx, nil)
+	require.NoError(t, err)
+	_, err = tx.ExecContext(ctx, "UPDATE users SET age = $1", 2)
+	require.NoError(t, err)
+	require.NoError(t, tx.Rollback())
+
+	// assert
+	mu.Lock()
+	defer mu.Unlock()
+	require.Len(t, summaries, 2)
+	assert.Equal(t, audriver.TransactionCommitted, summaries[0].Outcome)
+	assert.Equal(t, executionID, summaries[0].ExecutionID)
+	assert.Equal(t, 3, summaries[0].Statements)
+	assert.Equal(t, int64(3), summaries[0].RowsAffected)
+	assert.Equal(t, 2, summaries[0].Modifications)
+	assert.Positive(t, summaries[0].Duration)
+	assert.Equal(t, audriver.TransactionRolledBack, summaries[1].Outcome)
+	assert.Equal(t, 1, summaries[1].Statements)
+}
+
+// TestAuditDriver_TransactionContext tests that transactions begun without audit values in their context are flushed
+// with the context of their last audited statement
+func TestAuditDriver_TransactionContext(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name      string
+		beginOnly bool
+		readOnly  bool
+		isolation sql.IsolationLevel
+		wantErr   error
+	}{
+		{
+			name: "begin_tx",
+		},
+		{
+			name:      "begin_only_driver",
+			beginOnly: true,
+		},
+		{
+			name:      "begin_only_driver_read_only",
+			beginOnly: true,
+			readOnly:  true,
+			wantErr:   audriver.ErrUnsupportedConn,
+		},
+		{
+			name:      "begin_only_driver_isolation_level",
+			beginOnly: true,
+			isolation: sql.LevelSerializable,
+			wantErr:   audriver.ErrUnsupportedConn,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// arrange
+			operatorID := uuid.New().String()
+			ctx := audriver.WithOperatorID(t.Context(), operatorID)
+			ctx = audriver.WithExecutionID(ctx, uuid.New().String())
+			ctx, cancel := context.WithTimeout(ctx, time.Minute)
+			defer cancel()
+
+			var (
+				summaryCtx context.Context
+				summaries  int
+			)
+			options := []audriver.Option{
+				audriver.WithTransactionHandler(func(ctx context.Context, _ audriver.TransactionSummary) {
+					summaryCtx = ctx
+					summaries++
+				}),
+			}
+			if tc.readOnly {
+				options = append(options, audriver.WithReadOnly(true))
+			}
+			baseDriver := &fakeDriver{}
+			var base driver.Driver = baseDriver
+			if tc.beginOnly {
+				base = beginOnlyDriver{baseDriver}
+			}
+			driverName := fmt.Sprintf("transaction_context_test_%s_%d", tc.name, gofakeit.Number(1000, 9999))
+			sql.Register(driverName, audriver.New(base, options...))
+			db, err := sql.Open(driverName, driverName)
+			require.NoError(t, err)
+			t.Cleanup(func() {
+				_ = db.Close()
+			})
+
+			// act
+			var tx *sql.Tx
+			if tc.isolation != sql.LevelDefault {
+				tx, err = db.BeginTx(ctx, &sql.TxOptions{Isolation: tc.isolation})
+			} else {
+				tx, err = db.Begin()
+			}
+			if tc.wantErr != nil {
+				require.ErrorIs(t, err, tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			stmtCtx, stmtCancel := context.WithCancel(ctx)
+			_, err = tx.ExecContext(stmtCtx, "DELETE FROM users")
+			require.NoError(t, err)
+			stmtCancel()
+			err = tx.Commit()
+
+			// assert
+			require.NoError(t, err)
+			assert.Len(t, baseDriver.auditInserts(), 1)
+			require.Equal(t, 1, summaries)
+			require.NotNil(t, summaryCtx)
+			gotOperatorID, err := audriver.GetOperatorID(summaryCtx)
+			require.NoError(t, err)
+			assert.Equal(t, operatorID, gotOperatorID)
+			_, ok := summaryCtx.Deadline()
+			assert.True(t, ok, "the deadline of the statement should be kept")
+		})
+	}
+}
+
+// TestAuditDriver_CommitLSN tests capturing the WAL location of commits of transactions with modifications
+func TestAuditDriver_CommitLSN(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	ctx = audriver.WithOperatorID(ctx, uuid.New().String())
+	ctx = audriver.WithExecutionID(ctx, uuid.New().String())
+
+	// arrange
+	var summaries []audriver.TransactionSummary
+	baseDriver := &fakeDriver{
+		query: func(string, []driver.NamedValue) ([]string, [][]driver.Value) {
+			return []string{"pg_current_wal_insert_lsn"}, [][]driver.Value{{[]byte("0/16B3748")}}
+		},
+	}
+	db := setUpFakeTestDB(t, baseDriver,
+		audriver.WithCommitLSN(true),
+		audriver.WithTransactionHandler(func(_ context.Context, summary audriver.TransactionSummary) {
+			summaries = append(summaries, summary)
+		}),
+	)
+	db.SetMaxOpenConns(1)
+
+	// act
+	tx, err := db.BeginTx(ctx, nil)
+	require.NoError(t, err)
+	_, err = tx.ExecContext(ctx, "UPDATE users SET age = $1", 1)
+	require.NoError(t, err)
+	require.NoError(t, tx.Commit())
+
+	tx, err = db.BeginTx(ctx, nil)
+	require.NoError(t, err)
+	_, err = tx.ExecContext(ctx, "SET LOCAL lock_timeout = '1s'")
+	require.NoError(t, err)
+	require.NoError(t, tx.Commit())
+
+	// assert
+	require.Len(t, summaries, 2)
+	assert.Equal(t, "0/16B3748", summaries[0].CommitLSN)
+	assert.Empty(t, summaries[1].CommitLSN, "transactions without modifications should not be queried")
+}
+
+// TestAuditDriver_SlowAuditThreshold tests warnings about slow audits
+func TestAuditDriver_SlowAuditThreshold(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	ctx = audriver.WithExecutionID(ctx, uuid.New().String())
+
+	// arrange
+	logger := &slowAuditLogger{}
+	slowExtractor := audriver.OperatorIDExtractorFunc(func(ctx context.Context) (string, error) {
+		operatorID, err := audriver.GetOperatorID(ctx)
+		if operatorID == "slow" {
+			time.Sleep(20 * time.Millisecond)
+		}
+		return operatorID, err
+	})
+	auditDriver := audriver.New(&fakeDriver{},
+		audriver.WithLogger(logger),
+		audriver.WithOperatorIDExtractor(slowExtractor),
+		audriver.WithSlowAuditThreshold(10*time.Millisecond),
+	)
+	driverName := fmt.Sprintf("fake_slow_%s_%d", t.Name(), gofakeit.Number(1000, 9999))
+	sql.Register(driverName, auditDriver)
+	db, err := sql.Open(driverName, driverName)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = db.Close()
+	})
+
+	// act
+	_, err = db.ExecContext(audriver.WithOperatorID(ctx, "fast"), "UPDATE users SET age = $1", 1)
+	require.NoError(t, err)
+	_, err = db.ExecContext(audriver.WithOperatorID(ctx, "slow"), "UPDATE orders SET total = $1", 1)
+	require.NoError(t, err)
+
+	// assert
+	logger.mu.Lock()
+	defer logger.mu.Unlock()
+	require.Len(t, logger.slow, 1)
+	assert.Equal(t, audriver.ErrorStageBuild, logger.slow[0].Stage)
+	assert.Equal(t, []string{"orders"}, logger.slow[0].Tables)
+	assert.Equal(t, 1, logger.slow[0].BatchSize)
+	assert.GreaterOrEqual(t, logger.slow[0].Duration, 20*time.Millisecond)
+	assert.Equal(t, int64(1), auditDriver.(*audriver.Driver).AuditStats().SlowAudits)
+}
+
+type slowAuditLogger struct {
+	mu   sync.Mutex
+	slow []audriver.SlowAudit
+}
+
+func (l *slowAuditLogger) Log(context.Context, audriver.DatabaseModification) {}
+
+func (l *slowAuditLogger) LogSlowAudit(_ context.Context, audit audriver.SlowAudit) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.slow = append(l.slow, audit)
+}
+
+// TestAuditDriver_LoadShedding tests sampling hot tables while audit writes are slow
+func TestAuditDriver_LoadShedding(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	ctx = audriver.WithOperatorID(ctx, uuid.New().String())
+	ctx = audriver.WithExecutionID(ctx, uuid.New().String())
+
+	// arrange
+	var (
+		mu      sync.Mutex
+		windows []audriver.LoadSheddingWindow
+	)
+	baseDriver := &fakeDriver{auditDelay: 5 * time.Millisecond}
+	db := setUpFakeTestDB(t, baseDriver, audriver.WithLoadShedding(audriver.LoadShedding{
+		Latency:    time.Millisecond,
+		Tables:     []string{"events"},
+		SampleRate: 0,
+		OnChange: func(window audriver.LoadSheddingWindow) {
+			mu.Lock()
+			defer mu.Unlock()
+			windows = append(windows, window)
+		},
+	}))
+
+	// act
+	_, err := db.ExecContext(ctx, "INSERT INTO events (name) VALUES ($1)", "slow write")
+	require.NoError(t, err)
+	_, err = db.ExecContext(ctx, "INSERT INTO events (name) VALUES ($1)", "shed")
+	require.NoError(t, err)
+	_, err = db.ExecContext(ctx, "UPDATE users SET age = $1", 1)
+	require.NoError(t, err)
+	baseDriver.auditDelay = 0
+	_, err = db.ExecContext(ctx, "UPDATE users SET age = $1", 2)
+	require.NoError(t, err)
+	_, err = db.ExecContext(ctx, "INSERT INTO events (name) VALUES ($1)", "recovered")
+	require.NoError(t, err)
+
+	// assert
+	inserts := baseDriver.auditInserts()
+	require.Len(t, inserts, 4)
+	assert.Equal(t, "INSERT INTO events (name) VALUES ('slow write')", inserts[0].value("sql"))
+	assert.Equal(t, "users", inserts[1].value("table_name"))
+	assert.Equal(t, "INSERT INTO events (name) VALUES ('recovered')", inserts[3].value("sql"))
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.Len(t, windows, 2)
+	assert.True(t, windows[0].End.IsZero())
+	assert.Equal(t, []string{"events"}, windows[1].Tables)
+	assert.Equal(t, windows[0].Start, windows[1].Start)
+	assert.True(t, windows[1].End.After(windows[1].Start))
+}
+
+// TestAuditDriver_ContextSnapshotter tests storing context snapshots in the metadata column
+func TestAuditDriver_ContextSnapshotter(t *testing.T) {
+	t.Parallel()
+
+	type localeKey struct{}
+
+	ctx := t.Context()
+	ctx = audriver.WithOperatorID(ctx, uuid.New().String())
+	ctx = audriver.WithExecutionID(ctx, uuid.New().String())
+
+	// arrange
+	baseDriver := &fakeDriver{}
+	db := setUpFakeTestDB(t, baseDriver, audriver.WithContextSnapshotter(
+		audriver.ContextSnapshotterFunc(func(ctx context.Context) (map[string]any, error) {
+			locale, ok := ctx.Value(localeKey{}).(string)
+			if !ok {
+				return nil, nil
+			}
+			return map[string]any{"locale": locale, "path": "/users"}, nil
+		}),
+	))
+
+	// act
+	_, err := db.ExecContext(context.WithValue(ctx, localeKey{}, "ja-JP"), "UPDATE users SET age = $1", 1)
+	require.NoError(t, err)
+	_, err = db.ExecContext(ctx, "UPDATE users SET age = $1", 2)
+	require.NoError(t, err)
+
+	// assert
+	inserts := baseDriver.auditInserts()
+	require.Len(t, inserts, 2)
+	assert.JSONEq(t, `{"context": {"locale": "ja-JP", "path": "/users"}}`, inserts[0].value("metadata").(string))
+	assert.Nil(t, inserts[1].value("metadata"), "metadata should be omitted without a snapshot")
+}
+
+// TestAuditDriver_ClientInfo tests recording client information
+func TestAuditDriver_ClientInfo(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	ctx = audriver.WithOperatorID(ctx, uuid.New().String())
+	ctx = audriver.WithExecutionID(ctx, uuid.New().String())
+
+	// arrange
+	baseDriver := &fakeDriver{}
+	db := setUpFakeTestDB(t, baseDriver)
+	clientCtx := audriver.WithClientInfo(ctx, audriver.ClientInfo{
+		IP:        netip.MustParseAddr("192.0.2.1"),
+		UserAgent: "Mozilla/5.0",
+		Device:    "iPhone",
+	})
+
+	// act
+	_, err := db.ExecContext(clientCtx, "UPDATE users SET age = $1", 1)
+	require.NoError(t, err)
+	_, err = db.ExecContext(ctx, "UPDATE users SET age = $1", 2)
+	require.NoError(t, err)
+
+	// assert
+	inserts := baseDriver.auditInserts()
+	require.Len(t, inserts, 2)
+	assert.Equal(t, "192.0.2.1", inserts[0].value("client_ip"))
+	assert.Equal(t, "Mozilla/5.0", inserts[0].value("user_agent"))
+	assert.Equal(t, "iPhone", inserts[0].value("device"))
+	assert.Nil(t, inserts[1].value("client_ip"))
+}
+
+// TestAuditDriver_IdempotencyKey tests that modifications retried under the same idempotency key are recorded once
+func TestAuditDriver_IdempotencyKey(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	ctx = audriver.WithOperatorID(ctx, uuid.New().String())
+	ctx = audriver.WithExecutionID(ctx, uuid.New().String())
+
+	// arrange
+	baseDriver := &fakeDriver{}
+	db := setUpFakeTestDB(t, baseDriver)
+	keyCtx := audriver.WithIdempotencyKey(ctx, "order-42")
+	transfer := func() {
+		tx, err := db.BeginTx(keyCtx, nil)
+		require.NoError(t, err)
+		_, err = tx.ExecContext(keyCtx, "UPDATE accounts SET balance = balance - $1 WHERE id = $2", 10, 1)
+		require.NoError(t, err)
+		_, err = tx.ExecContext(keyCtx, "UPDATE accounts SET balance = balance - $1 WHERE id = $2", 10, 1)
+		require.NoError(t, err)
+		require.NoError(t, tx.Commit())
+	}
+
+	// act
+	transfer()
+	transfer()
+	_, err := db.ExecContext(keyCtx, "DELETE FROM carts WHERE id = $1", 1)
+	require.NoError(t, err)
+	_, err = db.ExecContext(keyCtx, "DELETE FROM carts WHERE id = $1", 1)
+	require.NoError(t, err)
+	_, err = db.ExecContext(audriver.WithIdempotencyKey(ctx, "order-42"), "DELETE FROM carts WHERE id = $1", 1)
+	require.NoError(t, err)
+	_, err = db.ExecContext(ctx, "DELETE FROM carts WHERE id = $1", 1)
+	require.NoError(t, err)
+
+	// assert
+	inserts := baseDriver.auditInserts()
+	require.Len(t, inserts, 6)
+
+	first, retried := inserts[0].values("id"), inserts[1].values("id")
+	require.Len(t, first, 2)
+	assert.NotEqual(t, first[0], first[1], "identical statements within a transaction should be kept")
+	assert.Equal(t, first, retried, "retried transactions should derive the same IDs")
+	assert.NotEqual(t, inserts[2].value("id"), inserts[3].value("id"), "identical autocommit statements should be kept")
+	assert.Equal(t, inserts[2].value("id"), inserts[4].value("id"), "retried autocommit statements should derive the same IDs")
+	for _, insert := range inserts[:5] {
+		assert.Equal(t, "order-42", insert.value("idempotency_key"))
+		assert.True(t, strings.HasSuffix(insert.query, " ON CONFLICT (id) DO NOTHING"), insert.query)
+	}
+
+	assert.Nil(t, inserts[5].value("idempotency_key"))
+	assert.NotEqual(t, inserts[3].value("id"), inserts[5].value("id"))
+	assert.NotContains(t, inserts[5].query, "ON CONFLICT")
+}
+
+// TestAuditDriver_ClientIPEnricher tests annotating client IPs in the metadata column
+func TestAuditDriver_ClientIPEnricher(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	ctx = audriver.WithOperatorID(ctx, uuid.New().String())
+	ctx = audriver.WithExecutionID(ctx, uuid.New().String())
+	ctx = audriver.WithClientInfo(ctx, audriver.ClientInfo{IP: netip.MustParseAddr("192.0.2.1")})
+
+	// arrange
+	baseDriver := &fakeDriver{}
+	db := setUpFakeTestDB(t, baseDriver,
+		audriver.WithSplitMultiRowInserts(true),
+		audriver.WithClientIPEnricher(audriver.ClientIPEnricherFunc(func(_ context.Context, ip netip.Addr) (map[string]any, error) {
+			return map[string]any{"country": "JP", "asn": 64496, "ip": ip.String()}, nil
+		})),
+	)
+
+	// act
+	_, err := db.ExecContext(ctx, "INSERT INTO users (name) VALUES ($1), ($2)", "John", "Jane")
+	require.NoError(t, err)
+
+	// assert
+	inserts := baseDriver.auditInserts()
+	require.Len(t, inserts, 1)
+	metadata := inserts[0].values("metadata")
+	require.Len(t, metadata, 2)
+	for _, m := range metadata {
+		assert.JSONEq(t, `{"client_ip": {"country": "JP", "asn": 64496, "ip": "192.0.2.1"}}`, m.(string))
+	}
+}
+
+// TestAuditDriver_TableEnricher tests adding domain fields parsed from statement arguments to the metadata column
+func TestAuditDriver_TableEnricher(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	ctx = audriver.WithOperatorID(ctx, uuid.New().String())
+	ctx = audriver.WithExecutionID(ctx, uuid.New().String())
+
+	// arrange
+	var failures []audriver.ErrorStage
+	baseDriver := &fakeDriver{}
+	db := setUpFakeTestDB(t, baseDriver,
+		audriver.WithErrorHandler(func(_ context.Context, _ error, stage audriver.ErrorStage, _ *audriver.DatabaseModification) {
+			failures = append(failures, stage)
+		}),
+		audriver.WithTableEnricher("orders", audriver.TableEnricherFunc(func(_ context.Context, mod *audriver.DatabaseModification, args []driver.NamedValue) error {
+			mod.SetMetadata("order_number", args[0].Value)
+			return nil
+		})),
+		audriver.WithTableEnricher("orders", audriver.TableEnricherFunc(func(_ context.Context, mod *audriver.DatabaseModification, args []driver.NamedValue) error {
+			mod.SetMetadata("amount", args[1].Value)
+			return nil
+		})),
+		audriver.WithTableEnricher("payments", audriver.TableEnricherFunc(func(context.Context, *audriver.DatabaseModification, []driver.NamedValue) error {
+			return errors.New("unknown currency")
+		})),
+	)
+
+	// act
+	_, err := db.ExecContext(ctx, "INSERT INTO orders (number, amount) VALUES ($1, $2)", "ORD-1", 1200)
+	require.NoError(t, err)
+	_, err = db.ExecContext(ctx, "INSERT INTO payments (amount) VALUES ($1)", 1200)
+	require.NoError(t, err)
+	_, err = db.ExecContext(ctx, "UPDATE users SET age = $1", 1)
+	require.NoError(t, err)
+
+	// assert
+	inserts := baseDriver.auditInserts()
+	require.Len(t, inserts, 3)
+	require.IsType(t, "", inserts[0].value("metadata"))
+	assert.JSONEq(t, `{"order_number": "ORD-1", "amount": 1200}`, inserts[0].value("metadata").(string))
+	assert.Nil(t, inserts[1].value("metadata"), "failed enrichments should not prevent auditing")
+	assert.Nil(t, inserts[2].value("metadata"))
+	assert.Equal(t, []audriver.ErrorStage{audriver.ErrorStageBuild}, failures)
+}
+
+// TestAuditDriver_TableEnricher_UnencodableMetadata tests that metadata which cannot be encoded fails the write
+func TestAuditDriver_TableEnricher_UnencodableMetadata(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	ctx = audriver.WithOperatorID(ctx, uuid.New().String())
+	ctx = audriver.WithExecutionID(ctx, uuid.New().String())
+
+	// arrange
+	var failures []audriver.ErrorStage
+	baseDriver := &fakeDriver{}
+	db := setUpFakeTestDB(t, baseDriver,
+		audriver.WithErrorHandler(func(_ context.Context, _ error, stage audriver.ErrorStage, _ *audriver.DatabaseModification) {
+			failures = append(failures, stage)
+		}),
+		audriver.WithTableEnricher("orders", audriver.TableEnricherFunc(func(_ context.Context, mod *audriver.DatabaseModification, _ []driver.NamedValue) error {
+			mod.SetMetadata("callback", func() {})
+			return nil
+		})),
+	)
+
+	// act
+	_, err := db.ExecContext(ctx, "INSERT INTO orders (number) VALUES ($1)", "ORD-1")
+
+	// assert
+	require.Error(t, err)
+	assert.ErrorContains(t, err, "failed to encode metadata of audit record")
+	assert.Empty(t, baseDriver.auditInserts())
+	assert.Equal(t, []audriver.ErrorStage{audriver.ErrorStageFlush}, failures)
+}
+
+// TestAuditDriver_ShardKeyExtractors tests recording shard keys extracted from statements
+func TestAuditDriver_ShardKeyExtractors(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name     string
+		sql      string
+		args     []any
+		expected any
+	}{
+		{
+			name:     "insert",
+			sql:      "INSERT INTO orders (id, tenant_id) VALUES ($1, $2)",
+			args:     []any{1, "acme"},
+			expected: "acme",
+		},
+		{
+			name:     "update",
+			sql:      "UPDATE orders SET status = $1 WHERE o.id = $2 AND o.tenant_id = $3",
+			args:     []any{"paid", 1, "it's"},
+			expected: "it's",
+		},
+		{
+			name:     "delete_numeric",
+			sql:      "DELETE FROM orders WHERE \"tenant_id\" = 42",
+			expected: "42",
+		},
+		{
+			name:     "no_key",
+			sql:      "UPDATE orders SET tenant_id = $1",
+			args:     []any{"acme"},
+			expected: nil,
+		},
+		{
+			name:     "unextracted_table",
+			sql:      "UPDATE users SET name = $1 WHERE tenant_id = $2",
+			args:     []any{"John", "acme"},
+			expected: nil,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := t.Context()
+			ctx = audriver.WithOperatorID(ctx, uuid.New().String())
+			ctx = audriver.WithExecutionID(ctx, uuid.New().String())
+
+			// arrange
+			baseDriver := &fakeDriver{}
+			db := setUpFakeTestDB(t, baseDriver, audriver.WithShardKeyExtractors(map[string]audriver.ShardKeyExtractor{
+				"orders": audriver.ShardKeyColumn("tenant_id"),
+			}))
+
+			// act
+			_, err := db.ExecContext(ctx, tc.sql, tc.args...)
+
+			// assert
+			require.NoError(t, err)
+			inserts := baseDriver.auditInserts()
+			require.Len(t, inserts, 1)
+			assert.Equal(t, tc.expected, inserts[0].value("shard_key"))
+		})
+	}
+}
+
+// TestAuditDriver_BackendIDs tests recording backend PIDs and transaction IDs, queried once per connection and transaction
+func TestAuditDriver_BackendIDs(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	ctx = audriver.WithOperatorID(ctx, uuid.New().String())
+	ctx = audriver.WithExecutionID(ctx, uuid.New().String())
+
+	// arrange
+	var queries []string
+	baseDriver := &fakeDriver{
+		query: func(query string, _ []driver.NamedValue) ([]string, [][]driver.Value) {
+			queries = append(queries, query)
+			if query == "SELECT pg_backend_pid()" {
+				return []string{"pg_backend_pid"}, [][]driver.Value{{int64(4242)}}
+			}
+			return []string{"pg_backend_pid", "txid_current"}, [][]driver.Value{{int64(4242), []byte("987654")}}
+		},
+	}
+	db := setUpFakeTestDB(t, baseDriver, audriver.WithBackendIDs(true))
+	db.SetMaxOpenConns(1)
+
+	// act
+	_, err := db.ExecContext(ctx, "UPDATE users SET age = $1", 1)
+	require.NoError(t, err)
+	_, err = db.ExecContext(ctx, "UPDATE users SET age = $1", 2)
+	require.NoError(t, err)
+
+	tx, err := db.BeginTx(ctx, nil)
+	require.NoError(t, err)
+	_, err = tx.ExecContext(ctx, "UPDATE users SET age = $1", 3)
+	require.NoError(t, err)
+	_, err = tx.ExecContext(ctx, "UPDATE users SET age = $1", 4)
+	require.NoError(t, err)
+	require.NoError(t, tx.Commit())
+
+	// assert
+	assert.Equal(t, []string{"SELECT pg_backend_pid()", "SELECT pg_backend_pid(), txid_current()"}, queries)
+	inserts := baseDriver.auditInserts()
+	require.Len(t, inserts, 3)
+	assert.Equal(t, int64(4242), inserts[0].value("backend_pid"))
+	assert.Nil(t, inserts[0].value("transaction_id"))
+	assert.Equal(t, []any{int64(4242), int64(4242)}, inserts[2].values("backend_pid"))
+	assert.Equal(t, []any{int64(987654), int64(987654)}, inserts[2].values("transaction_id"))
+}
+
+// TestAuditDriver_RowEstimateGuard tests blocking UPDATE and DELETE statements estimated to affect too many rows
+func TestAuditDriver_RowEstimateGuard(t *testing.T) {
+	t.Parallel()
+
+	plan := func(rows int) []byte {
+		return []byte(fmt.Sprintf(`[{"Plan": {"Node Type": "ModifyTable", "Plan Rows": 0, "Plans": [{"Node Type": "Seq Scan", "Plan Rows": %d}]}}]`, rows))
+	}
+
+	testCases := []struct {
+		name      string
+		sql       string
+		rows      int
+		approved  bool
+		wantErr   error
+		explained bool
+		estimated any
+	}{
+		{
+			name:      "below threshold",
+			sql:       "DELETE FROM users WHERE id = $1",
+			rows:      1,
+			explained: true,
+			estimated: int64(1),
+		},
+		{
+			name:      "above threshold",
+			sql:       "DELETE FROM users WHERE id > $1",
+			rows:      5000,
+			wantErr:   audriver.ErrRowEstimateExceeded,
+			explained: true,
+		},
+		{
+			name:      "above threshold with approval",
+			sql:       "UPDATE users SET age = age + 1 WHERE id > $1",
+			rows:      5000,
+			approved:  true,
+			explained: true,
+			estimated: int64(5000),
+		},
+		{
+			name: "insert",
+			sql:  "INSERT INTO users (id) VALUES ($1)",
+			rows: 5000,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := t.Context()
+			ctx = audriver.WithOperatorID(ctx, uuid.New().String())
+			ctx = audriver.WithExecutionID(ctx, uuid.New().String())
+			if tc.approved {
+				ctx = audriver.WithRowEstimateApproval(ctx)
+			}
+
+			// arrange
+			var explained []string
+			baseDriver := &fakeDriver{
+				query: func(query string, _ []driver.NamedValue) ([]string, [][]driver.Value) {
+					explained = append(explained, query)
+					return []string{"QUERY PLAN"}, [][]driver.Value{{plan(tc.rows)}}
+				},
+			}
+			db := setUpFakeTestDB(t, baseDriver, audriver.WithRowEstimateGuard(1000))
+
+			// act
+			_, err := db.ExecContext(ctx, tc.sql, 1)
+
+			// assert
+			if tc.explained {
+				assert.Equal(t, []string{"EXPLAIN (FORMAT JSON) " + tc.sql}, explained)
+			} else {
+				assert.Empty(t, explained)
+			}
+			inserts := baseDriver.auditInserts()
+			if tc.wantErr != nil {
+				assert.ErrorIs(t, err, tc.wantErr)
+				assert.Empty(t, baseDriver.executed())
+				return
+			}
+			require.NoError(t, err)
+			require.Len(t, inserts, 1)
+			assert.Equal(t, tc.estimated, inserts[0].value("estimated_rows"))
+		})
+	}
+}
+
+// TestAuditDriver_RowEstimateGuard_Transaction tests explaining statements within transactions behind a savepoint
+func TestAuditDriver_RowEstimateGuard_Transaction(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name      string
+		plan      string
+		wantExecs []string
+		estimated any
+	}{
+		{
+			name: "estimated",
+			plan: `[{"Plan": {"Node Type": "Seq Scan", "Plan Rows": 3}}]`,
+			wantExecs: []string{
+				"SAVEPOINT audriver_row_estimate",
+				"RELEASE SAVEPOINT audriver_row_estimate",
+				"DELETE FROM users WHERE id > $1",
+			},
+			estimated: int64(3),
+		},
+		{
+			name: "failed",
+			plan: `not a plan`,
+			wantExecs: []string{
+				"SAVEPOINT audriver_row_estimate",
+				"ROLLBACK TO SAVEPOINT audriver_row_estimate",
+				"RELEASE SAVEPOINT audriver_row_estimate",
+				"DELETE FROM users WHERE id > $1",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := t.Context()
+			ctx = audriver.WithOperatorID(ctx, uuid.New().String())
+			ctx = audriver.WithExecutionID(ctx, uuid.New().String())
+
+			// arrange
+			baseDriver := &fakeDriver{
+				query: func(string, []driver.NamedValue) ([]string, [][]driver.Value) {
+					return []string{"QUERY PLAN"}, [][]driver.Value{{[]byte(tc.plan)}}
+				},
+			}
+			db := setUpFakeTestDB(t, baseDriver, audriver.WithRowEstimateGuard(1000))
+			tx, err := db.BeginTx(ctx, nil)
+			require.NoError(t, err)
+
+			// act
+			_, err = tx.ExecContext(ctx, "DELETE FROM users WHERE id > $1", 1)
+			require.NoError(t, err, "failures to estimate should not fail the statement")
+			require.NoError(t, tx.Commit())
+
+			// assert
+			var execs []string
+			for _, exec := range baseDriver.executed() {
+				execs = append(execs, exec.query)
+			}
+			inserts := baseDriver.auditInserts()
+			require.Len(t, inserts, 1)
+			assert.Equal(t, tc.wantExecs, execs[:len(execs)-1])
+			assert.Equal(t, tc.estimated, inserts[0].value("estimated_rows"))
+		})
+	}
+}
+
+// TestAuditDriver_RowCapture tests capturing the rows modified by UPDATE and DELETE statements
+func TestAuditDriver_RowCapture(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name        string
+		dialect     audriver.Dialect
+		sql         string
+		args        []any
+		wantQueries []string
+		wantOld     any
+		wantNew     any
+		wantErr     bool
+	}{
+		{
+			name:    "update returning new values",
+			sql:     "UPDATE users SET name = $1 WHERE id = $2",
+			args:    []any{"Jane", 1},
+			wantOld: `[{"id":1,"name":"John"}]`,
+			wantNew: `[{"id":1,"name":"Jane"}]`,
+			wantQueries: []string{
+				"SELECT * FROM users WHERE id = '1' FOR UPDATE",
+				"UPDATE users SET name = $1 WHERE id = $2 RETURNING *",
+			},
+		},
+		{
+			name:        "delete returning old values",
+			sql:         "DELETE FROM users u WHERE u.id = $1;",
+			args:        []any{1},
+			wantOld:     `[{"id":1,"name":"John"}]`,
+			wantQueries: []string{"DELETE FROM users u WHERE u.id = $1 RETURNING *;"},
+		},
+		{
+			name:    "mysql selecting new values",
+			dialect: audriver.MySQL,
+			sql:     "UPDATE `users` SET name = ? WHERE id > ? ORDER BY id LIMIT 1",
+			args:    []any{"Jane", 0},
+			wantOld: `[{"id":1,"name":"John"}]`,
+			wantNew: `[{"id":1,"name":"Jane"}]`,
+			wantQueries: []string{
+				"SELECT * FROM `users` WHERE id > '0' ORDER BY id LIMIT 1 FOR UPDATE",
+				"SELECT * FROM `users` WHERE id > '0' ORDER BY id LIMIT 1",
+			},
+		},
+		{
+			name:        "sqlite",
+			dialect:     audriver.SQLite,
+			sql:         "DELETE FROM users WHERE id = ?",
+			args:        []any{1},
+			wantOld:     `[{"id":1,"name":"John"}]`,
+			wantQueries: []string{"DELETE FROM users WHERE id = ? RETURNING *"},
+		},
+		{
+			name:    "reading other tables",
+			sql:     "UPDATE users SET name = o.name FROM orders o WHERE o.user_id = users.id AND o.id = $1",
+			args:    []any{1},
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := t.Context()
+			ctx = audriver.WithOperatorID(ctx, uuid.New().String())
+			ctx = audriver.WithExecutionID(ctx, uuid.New().String())
+
+			// arrange
+			var (
+				queries []string
+				updated bool
+				handled []error
+			)
+			baseDriver := &fakeDriver{
+				query: func(query string, _ []driver.NamedValue) ([]string, [][]driver.Value) {
+					queries = append(queries, query)
+					name := "John"
+					if updated || strings.HasPrefix(query, "UPDATE") {
+						name = "Jane"
+					}
+					if strings.HasSuffix(query, "FOR UPDATE") && tc.dialect == audriver.MySQL {
+						updated = true
+					}
+					return []string{"id", "name"}, [][]driver.Value{{int64(1), []byte(name)}}
+				},
+			}
+			db := setUpFakeTestDB(t, baseDriver,
+				audriver.WithDialect(tc.dialect),
+				audriver.WithRowCapture(true),
+				audriver.WithErrorHandler(func(_ context.Context, err error, _ audriver.ErrorStage, _ *audriver.DatabaseModification) {
+					handled = append(handled, err)
+				}),
+			)
+
+			// act
+			res, err := db.ExecContext(ctx, tc.sql, tc.args...)
+
+			// assert
+			require.NoError(t, err)
+			affected, err := res.RowsAffected()
+			require.NoError(t, err)
+			assert.Equal(t, int64(1), affected)
+			assert.Equal(t, tc.wantQueries, queries)
+			assert.Equal(t, tc.wantErr, len(handled) > 0, "capture failures should be reported: %v", handled)
+
+			inserts := baseDriver.auditInserts()
+			require.Len(t, inserts, 1)
+			assert.Equal(t, tc.wantOld, inserts[0].value("old_values"))
+			assert.Equal(t, tc.wantNew, inserts[0].value("new_values"))
+		})
+	}
+}
+
+// TestAuditDriver_EnablementRate tests auditing a fraction of connections, overridden per request
+func TestAuditDriver_EnablementRate(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	ctx = audriver.WithOperatorID(ctx, uuid.New().String())
+	ctx = audriver.WithExecutionID(ctx, uuid.New().String())
+
+	// arrange
+	baseDriver := &fakeDriver{}
+	auditDriver := audriver.New(baseDriver, audriver.WithEnablementRate(0))
+	driverName := fmt.Sprintf("fake_enablement_%s_%d", t.Name(), gofakeit.Number(1000, 9999))
+	sql.Register(driverName, auditDriver)
+	db, err := sql.Open(driverName, driverName)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = db.Close()
+	})
+	db.SetMaxOpenConns(1)
+
+	// act
+	_, err = db.ExecContext(ctx, "UPDATE users SET age = $1", 1)
+	require.NoError(t, err)
+	_, err = db.ExecContext(audriver.WithAuditEnabled(ctx, true), "UPDATE users SET age = $1", 2)
+	require.NoError(t, err)
+
+	tx, err := db.BeginTx(audriver.WithAuditEnabled(ctx, true), nil)
+	require.NoError(t, err)
+	_, err = tx.ExecContext(ctx, "UPDATE users SET age = $1", 3)
+	require.NoError(t, err)
+	require.NoError(t, tx.Commit())
+
+	tx, err = db.BeginTx(ctx, nil)
+	require.NoError(t, err)
+	_, err = tx.ExecContext(ctx, "UPDATE users SET age = $1", 4)
+	require.NoError(t, err)
+	require.NoError(t, tx.Commit())
+
+	// assert
+	inserts := baseDriver.auditInserts()
+	require.Len(t, inserts, 2)
+	assert.Contains(t, inserts[0].value("sql"), "'2'")
+	assert.Contains(t, inserts[1].value("sql"), "'3'")
+	stats := auditDriver.(*audriver.Driver).AuditStats()
+	assert.Equal(t, int64(2), stats.SkippedDisabled)
+	assert.Equal(t, int64(0), stats.EnabledConns)
+	assert.Equal(t, int64(1), stats.DisabledConns)
+	assert.Equal(t, float64(0), stats.EnablementRate)
+}
+
+// TestAuditDriver_Profiles tests that profiles bundle options and are overridden by options passed after them
+func TestAuditDriver_Profiles(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name    string
+		options []audriver.Option
+		wantErr error
+	}{
+		{
+			name:    "strict",
+			options: []audriver.Option{audriver.ProfileStrict()},
+			wantErr: audriver.ErrUnclassifiedStatement,
+		},
+		{
+			name:    "balanced",
+			options: []audriver.Option{audriver.ProfileBalanced()},
+		},
+		{
+			name:    "development",
+			options: []audriver.Option{audriver.ProfileDevelopment()},
+			wantErr: audriver.ErrUnclassifiedStatement,
+		},
+		{
+			name:    "strict overridden",
+			options: []audriver.Option{audriver.ProfileStrict(), audriver.WithStrictParsing(false)},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := t.Context()
+			ctx = audriver.WithOperatorID(ctx, uuid.New().String())
+			ctx = audriver.WithExecutionID(ctx, uuid.New().String())
+
+			// arrange
+			baseDriver := &fakeDriver{}
+			db := setUpFakeTestDB(t, baseDriver, tc.options...)
+
+			// act
+			_, err := db.ExecContext(ctx, "INSERT IGNORE INTO `users` (`id`) VALUES (?)", "1")
+
+			// assert
+			if tc.wantErr != nil {
+				assert.ErrorIs(t, err, tc.wantErr)
+				assert.Empty(t, baseDriver.auditInserts())
+				return
+			}
+			require.NoError(t, err)
+			require.Len(t, baseDriver.auditInserts(), 1)
+			assert.Equal(t, "unknown", baseDriver.auditInserts()[0].value("action"))
+		})
+	}
+}
+
+// TestAuditDriver_Profiles_Config tests the effective configuration set by each profile
+func TestAuditDriver_Profiles_Config(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name     string
+		profile  audriver.Option
+		expected map[string]any
+	}{
+		{
+			name:    "strict",
+			profile: audriver.ProfileStrict(),
+			expected: map[string]any{
+				"strict_parsing":          true,
+				"split_multi_row_inserts": true,
+				"enablement_rate":         float64(1),
+				"retry":                   map[string]any{"max_retries": float64(3), "backoff": "50ms", "max_backoff": "1s"},
+				"anonymizer":              "card number redaction",
+				"arg_capture":             false,
+				"backend_ids":             false,
+				"query_annotation":        false,
+			},
+		},
+		{
+			name:    "balanced",
+			profile: audriver.ProfileBalanced(),
+			expected: map[string]any{
+				"strict_parsing":             false,
+				"split_multi_row_inserts":    false,
+				"enablement_rate":            float64(1),
+				"retry":                      map[string]any{"max_retries": float64(2), "backoff": "50ms", "max_backoff": "200ms"},
+				"audit_statement_cache_size": float64(32),
+				"flush_threshold":            float64(1000),
+				"slow_audit_threshold":       "100ms",
+				"anonymizer":                 "card number redaction",
+				"arg_capture":                false,
+				"backend_ids":                false,
+			},
+		},
+		{
+			name:    "development",
+			profile: audriver.ProfileDevelopment(),
+			expected: map[string]any{
+				"strict_parsing":       true,
+				"query_annotation":     true,
+				"slow_audit_threshold": "10ms",
+				"arg_capture":          false,
+				"backend_ids":          false,
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// arrange
+			auditDriver := audriver.New(&fakeDriver{}, tc.profile).(*audriver.Driver)
+
+			// act
+			data, err := auditDriver.ConfigJSON()
+
+			// assert
+			require.NoError(t, err)
+			var cfg map[string]any
+			require.NoError(t, json.Unmarshal(data, &cfg))
+			for key, expected := range tc.expected {
+				assert.Equal(t, expected, cfg[key], key)
+			}
+			if _, ok := tc.expected["anonymizer"]; !ok {
+				assert.NotContains(t, cfg, "anonymizer")
+			}
+			if _, ok := tc.expected["retry"]; !ok {
+				assert.NotContains(t, cfg, "retry")
+			}
+		})
+	}
+}
+
+// TestAuditDriver_ConfigJSON tests reporting the effective configuration of the driver
+func TestAuditDriver_ConfigJSON(t *testing.T) {
+	t.Parallel()
+
+	// arrange
+	auditDriver := audriver.New(&fakeDriver{},
+		audriver.WithTableFilters(audriver.NewExcludePatternFilter("sessions", "tmp_*")),
+		audriver.WithActions(audriver.DatabaseModificationActionDelete, audriver.DatabaseModificationActionUpdate),
+		audriver.WithIgnoreSQLPatterns(regexp.MustCompile(`pg_advisory`)),
+		audriver.WithAuditRetry(audriver.AuditRetry{MaxRetries: 3}),
+		audriver.WithEnablementRate(0.5),
+		audriver.WithAuditRole("audriver_writer"),
+		audriver.WithAuditSink(audriver.DBSink{}),
+		audriver.WithBatchWindow(100, 0, time.Second),
+	).(*audriver.Driver)
+
+	// act
+	data, err := auditDriver.ConfigJSON()
+
+	// assert
+	require.NoError(t, err)
+	var cfg map[string]any
+	require.NoError(t, json.Unmarshal(data, &cfg))
+	assert.Equal(t, "postgres", cfg["dialect"])
+	assert.Equal(t, "database_modifications", cfg["audit_table"])
+	assert.Equal(t, "audriver_writer", cfg["audit_role"])
+	assert.Equal(t, []any{`exclude patterns ["sessions" "tmp_*"]`}, cfg["table_filters"])
+	assert.Equal(t, []any{"delete", "update"}, cfg["actions"])
+	assert.Equal(t, []any{"pg_advisory"}, cfg["ignore_sql_patterns"])
+	assert.Equal(t, map[string]any{"max_retries": float64(3), "backoff": "50ms"}, cfg["retry"])
+	assert.Equal(t, "audriver.DBSink", cfg["audit_sink"], "batched sinks should be described as the sink")
+	assert.Equal(t, map[string]any{"max_records": float64(100), "max_latency": "1s"}, cfg["batch_window"])
+	assert.Equal(t, 0.5, cfg["enablement_rate"])
+	assert.Equal(t, "audriver.IDGeneratorFunc", cfg["id_generator"])
+	assert.Equal(t, false, cfg["strict_parsing"])
+	assert.NotContains(t, cfg, "load_shedding")
+}
+
+// TestAuditDriver_AuditBudget tests warning, aggregating, and blocking modifications exceeding the budget of a request
+func TestAuditDriver_AuditBudget(t *testing.T) {
+	t.Parallel()
+
+	statements := []string{
+		"INSERT INTO users (id) VALUES ($1)",
+		"INSERT INTO users (id) VALUES ($1)",
+		"UPDATE users SET age = age + 1 WHERE id = $1",
+		"UPDATE users SET age = age + 1 WHERE id = $1",
+	}
+
+	// exact records omit the exactness column
+	testCases := []struct {
+		name       string
+		action     audriver.BudgetAction
+		exactness  []any
+		reported   int
+		stmtErrors int
+	}{
+		{
+			name:      "warn",
+			action:    audriver.BudgetActionWarn,
+			exactness: []any{nil, nil, nil, nil},
+			reported:  1,
+		},
+		{
+			name:      "aggregate",
+			action:    audriver.BudgetActionAggregate,
+			exactness: []any{nil, nil, "aggregated"},
+			reported:  1,
+		},
+		{
+			name:       "block",
+			action:     audriver.BudgetActionBlock,
+			exactness:  []any{nil, nil},
+			reported:   2,
+			stmtErrors: 2,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := t.Context()
+			ctx = audriver.WithOperatorID(ctx, uuid.New().String())
+			ctx = audriver.WithExecutionID(ctx, uuid.New().String())
+			ctx = audriver.WithAuditBudget(ctx, 2, tc.action)
+
+			// arrange
+			var (
+				mu       sync.Mutex
+				reported []error
+			)
+			baseDriver := &fakeDriver{}
+			db := setUpFakeTestDB(t, baseDriver,
+				audriver.WithErrorHandler(func(_ context.Context, err error, _ audriver.ErrorStage, _ *audriver.DatabaseModification) {
+					mu.Lock()
+					defer mu.Unlock()
+					reported = append(reported, err)
+				}),
+			)
+
+			// act
+			var stmtErrors int
+			for _, statement := range statements {
+				if _, err := db.ExecContext(ctx, statement, 1); err != nil {
+					assert.ErrorIs(t, err, audriver.ErrAuditBudgetExceeded)
+					stmtErrors++
+				}
+			}
+
+			// assert
+			assert.Equal(t, tc.stmtErrors, stmtErrors)
+			require.Len(t, reported, tc.reported)
+			for _, err := range reported {
+				assert.ErrorIs(t, err, audriver.ErrAuditBudgetExceeded)
+			}
+			inserts := baseDriver.auditInserts()
+			exactness := make([]any, len(inserts))
+			for i, insert := range inserts {
+				exactness[i] = insert.value("exactness")
+			}
+			assert.Equal(t, tc.exactness, exactness)
+		})
+	}
+}
+
+// TestAuditDriver_Step tests that modifications are tagged with the workflow step of their context
+func TestAuditDriver_Step(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	ctx = audriver.WithOperatorID(ctx, uuid.New().String())
+	ctx = audriver.WithExecutionID(ctx, uuid.New().String())
+
+	// arrange
+	baseDriver := &fakeDriver{}
+	db := setUpFakeTestDB(t, baseDriver)
+
+	// act
+	_, err := db.ExecContext(audriver.WithStep(ctx, "validate"), "UPDATE orders SET status = $1", "validated")
+	require.NoError(t, err)
+	tx, err := db.BeginTx(ctx, nil)
+	require.NoError(t, err)
+	_, err = tx.ExecContext(audriver.WithStep(ctx, "apply"), "UPDATE accounts SET balance = balance - $1", 10)
+	require.NoError(t, err)
+	require.NoError(t, tx.Commit())
+	_, err = db.ExecContext(ctx, "INSERT INTO notifications (id) VALUES ($1)", 1)
+	require.NoError(t, err)
+
+	// assert
+	inserts := baseDriver.auditInserts()
+	require.Len(t, inserts, 3)
+	assert.Equal(t, "validate", inserts[0].value("step"))
+	assert.Equal(t, "apply", inserts[1].value("step"))
+	assert.Nil(t, inserts[2].value("step"))
+}
+
+// TestAuditDriver_ParentExecutionID tests that modifications of sub-operations are linked to the parent execution
+func TestAuditDriver_ParentExecutionID(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	ctx = audriver.WithOperatorID(ctx, uuid.New().String())
+	parentID := uuid.New().String()
+
+	// arrange
+	baseDriver := &fakeDriver{}
+	db := setUpFakeTestDB(t, baseDriver)
+	workerCtx := audriver.WithParentExecutionID(audriver.WithExecutionID(ctx, uuid.New().String()), parentID)
+
+	// act
+	_, err := db.ExecContext(audriver.WithExecutionID(ctx, parentID), "INSERT INTO jobs (id) VALUES ($1)", 1)
+	require.NoError(t, err)
+	_, err = db.ExecContext(workerCtx, "UPDATE jobs SET status = $1", "done")
+	require.NoError(t, err)
+
+	// assert
+	inserts := baseDriver.auditInserts()
+	require.Len(t, inserts, 2)
+	assert.Nil(t, inserts[0].value("parent_execution_id"))
+	assert.Equal(t, parentID, inserts[1].value("parent_execution_id"))
+}
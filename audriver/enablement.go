@@ -0,0 +1,45 @@
+package audriver
+
+import (
+	"context"
+	"math/rand/v2"
+)
+
+type auditEnabledKey struct{}
+
+// WithAuditEnabled returns a context that enables or disables auditing of statements executed with it,
+// overriding the decision of WithEnablementRate for the connection, e.g. to roll auditing out per request or tenant.
+// Transactions are audited or not as a whole, as decided by the context passed to BeginTx.
+func WithAuditEnabled(ctx context.Context, enabled bool) context.Context {
+	return context.WithValue(ctx, auditEnabledKey{}, enabled)
+}
+
+// GetAuditEnabled returns whether ctx enables auditing, if it decides.
+func GetAuditEnabled(ctx context.Context) (bool, bool) {
+	enabled, ok := ctx.Value(auditEnabledKey{}).(bool)
+	return enabled, ok
+}
+
+// decideEnablement decides whether a new connection is audited at the enablement rate, and counts the decision.
+func (d *Driver) decideEnablement() bool {
+	enabled := d.enablementRate >= 1 || rand.Float64() < d.enablementRate
+	if enabled {
+		d.builder.stats.enabledConns.Add(1)
+	} else {
+		d.builder.stats.disabledConns.Add(1)
+	}
+	return enabled
+}
+
+// auditing reports whether statements executed with ctx on the connection are audited:
+// as decided by ctx, if it does, or else for the connection.
+func (c *Conn) auditing(ctx context.Context) bool {
+	enabled, ok := GetAuditEnabled(ctx)
+	if !ok {
+		enabled = !c.unaudited
+	}
+	if !enabled {
+		c.builder.stats.skippedDisabled.Add(1)
+	}
+	return enabled
+}
@@ -0,0 +1,77 @@
+package audriver
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"net/netip"
+)
+
+// ClientIPMetadataKey is the key of client IP annotations in the metadata of database modifications.
+const ClientIPMetadataKey = "client_ip"
+
+// ClientIPEnricher annotates client IP addresses, e.g. with the country of a GeoIP lookup or the ASN of the network.
+type ClientIPEnricher interface {
+	EnrichClientIP(ctx context.Context, ip netip.Addr) (map[string]any, error)
+}
+
+// ClientIPEnricherFunc is a function type that implements the ClientIPEnricher interface.
+type ClientIPEnricherFunc func(ctx context.Context, ip netip.Addr) (map[string]any, error)
+
+func (f ClientIPEnricherFunc) EnrichClientIP(ctx context.Context, ip netip.Addr) (map[string]any, error) {
+	return f(ctx, ip)
+}
+
+// EnrichClientIP stores the annotations of the client IP of mod by enricher under ClientIPMetadataKey in its metadata.
+// It does nothing if mod has no client IP or the enricher returns no annotations.
+func EnrichClientIP(ctx context.Context, mod *DatabaseModification, enricher ClientIPEnricher) error {
+	if mod.ClientIP == "" {
+		return nil
+	}
+	ip, err := netip.ParseAddr(mod.ClientIP)
+	if err != nil {
+		return fmt.Errorf("failed to parse client IP: %w", err)
+	}
+
+	annotations, err := enricher.EnrichClientIP(ctx, ip)
+	if err != nil {
+		return fmt.Errorf("failed to enrich client IP %s: %w", mod.ClientIP, err)
+	}
+	if len(annotations) == 0 {
+		return nil
+	}
+
+	mod.SetMetadata(ClientIPMetadataKey, annotations)
+	return nil
+}
+
+// TableEnricher adds domain fields to modifications of a table, e.g. the order number and amount of an order parsed
+// from the statement arguments, typically into the metadata with SetMetadata, so that audit records are meaningful to
+// the business without post-processing. args are the arguments the statement is executed with.
+type TableEnricher interface {
+	EnrichModification(ctx context.Context, mod *DatabaseModification, args []driver.NamedValue) error
+}
+
+// TableEnricherFunc is a function type that implements the TableEnricher interface.
+type TableEnricherFunc func(ctx context.Context, mod *DatabaseModification, args []driver.NamedValue) error
+
+func (f TableEnricherFunc) EnrichModification(ctx context.Context, mod *DatabaseModification, args []driver.NamedValue) error {
+	return f(ctx, mod, args)
+}
+
+// enrichTable passes mods to the enrichers of their table, if any, in the order they were registered.
+// Rows of a split multi-row insert are enriched one by one, each with all arguments of the statement.
+func (b *databaseModificationBuilder) enrichTable(ctx context.Context, mods []DatabaseModification, args []driver.NamedValue) error {
+	if len(mods) == 0 {
+		return nil
+	}
+	enrichers := b.tableEnrichers[mods[0].TableName]
+	for i := range mods {
+		for _, enricher := range enrichers {
+			if err := enricher.EnrichModification(ctx, &mods[i], args); err != nil {
+				return fmt.Errorf("failed to enrich modification of table %s: %w", mods[i].TableName, err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,201 @@
+package audriver
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrMissingOperatorID is returned when the context carries no operator ID.
+	ErrMissingOperatorID = errors.New("operator ID not found in context")
+
+	// ErrMissingExecutionID is returned when the context carries no execution ID.
+	ErrMissingExecutionID = errors.New("execution ID not found in context")
+
+	// ErrAuditWriteFailed is returned when audit records cannot be written.
+	// For statements outside of transactions, the statement itself has already been executed.
+	ErrAuditWriteFailed = errors.New("failed to write audit records")
+
+	// ErrAuditTimeout is returned along with ErrAuditWriteFailed when an audit insert is canceled by statement_timeout
+	// or the deadline of its context. Retrying after backing off is safe; within a transaction, PostgreSQL aborts the
+	// transaction, so the whole transaction has to be retried. See AuditRetryable.
+	ErrAuditTimeout = errors.New("audit write timed out")
+
+	// ErrAuditLockContention is returned along with ErrAuditWriteFailed when an audit insert fails on a lock of the
+	// audit table or a concurrent transaction, i.e. lock_timeout, a deadlock, or a serialization failure. Retrying after backing off is safe; within a transaction,
+	// PostgreSQL aborts the transaction, so the whole transaction has to be retried. See AuditRetryable.
+	ErrAuditLockContention = errors.New("audit write failed on lock contention")
+
+	// ErrAuditUnavailable is returned along with ErrAuditWriteFailed when an audit insert fails because the database
+	// is briefly unavailable, e.g. during a failover. Retrying after backing off is safe. See AuditRetryable.
+	ErrAuditUnavailable = errors.New("audit database unavailable")
+
+	// ErrAuditQueueFull is reported to the error handler along with ErrAuditWriteFailed when the queue of
+	// WithAsyncLogging is full and BackpressureError is set.
+	ErrAuditQueueFull = errors.New("audit queue full")
+
+	// ErrAuditBackpressure is returned for modifying statements executed with a context of WithBackpressureShedding
+	// while the audit pipeline is saturated. Such statements are not executed.
+	ErrAuditBackpressure = errors.New("audit pipeline saturated")
+
+	// ErrUnsupportedConn is returned when the underlying connection lacks an interface audriver relies on.
+	ErrUnsupportedConn = errors.New("unsupported connection")
+
+	// ErrUnclassifiedStatement is returned in strict parsing mode for modifying statements
+	// whose action and table cannot be parsed. Such statements are not executed.
+	ErrUnclassifiedStatement = errors.New("unclassified modifying statement")
+
+	// ErrRowEstimateExceeded is returned when an UPDATE or DELETE statement is blocked by WithRowEstimateGuard
+	// because it is estimated to affect more rows than allowed without approval.
+	ErrRowEstimateExceeded = errors.New("estimated rows exceed the threshold")
+
+	// ErrAuditBudgetExceeded is returned when statements executed with a context of WithAuditBudget modify more than
+	// the budget allows and BudgetActionBlock is set, and reported to the error handler for the other actions.
+	ErrAuditBudgetExceeded = errors.New("audit budget exceeded")
+
+	// ErrPanicRecovered is returned when user-supplied code in the audit path, such as an extractor,
+	// table filter, or query rewriter, panics. The panic is recovered instead of crashing the application.
+	ErrPanicRecovered = errors.New("recovered from panic in audit path")
+
+	// ErrInvalidJWT is returned by JWTOperatorExtractor when the JWT of the context is malformed, not verified, or expired.
+	ErrInvalidJWT = errors.New("invalid JWT")
+
+	// ErrAuditTableMutable is returned by VerifyImmutability when the current role can modify existing audit records.
+	ErrAuditTableMutable = errors.New("audit table is mutable")
+)
+
+// FlushError is returned when the buffered audit records of a transaction cannot be written, on commit or when the
+// flush threshold is reached. On commit, the transaction is rolled back; if the rollback fails as well, the FlushError is
+// joined with a RollbackError, so that both can be inspected with errors.As.
+type FlushError struct {
+	Err error
+}
+
+func (e *FlushError) Error() string {
+	return "failed to flush logs in transaction: " + e.Err.Error()
+}
+
+func (e *FlushError) Unwrap() error {
+	return e.Err
+}
+
+// RollbackError is joined with a FlushError when the transaction cannot be rolled back after its audit records failed.
+// The state of the transaction is then up to the database, e.g. it is aborted when the connection is closed.
+type RollbackError struct {
+	Err error
+}
+
+func (e *RollbackError) Error() string {
+	return "failed to rollback after audriver logging error: " + e.Err.Error()
+}
+
+func (e *RollbackError) Unwrap() error {
+	return e.Err
+}
+
+// auditErrorClasses map SQLSTATE codes of PostgreSQL to the audit errors they are classified as.
+var auditErrorClasses = map[string]error{
+	"57014": ErrAuditTimeout,        // query_canceled, e.g. by statement_timeout
+	"55P03": ErrAuditLockContention, // lock_not_available, e.g. by lock_timeout
+	"40P01": ErrAuditLockContention, // deadlock_detected
+	"40001": ErrAuditLockContention, // serialization_failure
+	"57P01": ErrAuditUnavailable,    // admin_shutdown
+	"57P02": ErrAuditUnavailable,    // crash_shutdown
+	"57P03": ErrAuditUnavailable,    // cannot_connect_now
+	"08":    ErrAuditUnavailable,    // class of connection exceptions
+}
+
+// classifyAuditError wraps an error of writing audit records with ErrAuditWriteFailed,
+// and with ErrAuditTimeout, ErrAuditLockContention, or ErrAuditUnavailable if it is caused by a transient failure.
+// SQLSTATE codes are read from errors implementing SQLState() string, as those of lib/pq and pgx do.
+func classifyAuditError(err error) error {
+	var stateErr interface{ SQLState() string }
+	if errors.As(err, &stateErr) {
+		state := stateErr.SQLState()
+		class, ok := auditErrorClasses[state]
+		if !ok && len(state) == 5 {
+			class, ok = auditErrorClasses[state[:2]]
+		}
+		if ok {
+			return fmt.Errorf("%w: %w: %w", ErrAuditWriteFailed, class, err)
+		}
+	}
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return fmt.Errorf("%w: %w: %w", ErrAuditWriteFailed, ErrAuditTimeout, err)
+	case errors.Is(err, driver.ErrBadConn):
+		return fmt.Errorf("%w: %w: %w", ErrAuditWriteFailed, ErrAuditUnavailable, err)
+	}
+	return fmt.Errorf("%w: %w", ErrAuditWriteFailed, err)
+}
+
+// AuditRetryable reports whether err is caused by a transient failure of writing audit records,
+// i.e. ErrAuditTimeout, ErrAuditLockContention, or ErrAuditUnavailable, so that the operation may be retried after
+// backing off.
+// Statements executed outside of transactions have already been executed when their audit records fail,
+// so they must only be retried if they are idempotent.
+func AuditRetryable(err error) bool {
+	return errors.Is(err, ErrAuditTimeout) || errors.Is(err, ErrAuditLockContention) || errors.Is(err, ErrAuditUnavailable)
+}
+
+// ErrorStage identifies the step of the audit pipeline that failed.
+type ErrorStage string
+
+const (
+	// ErrorStageBuild is the building of database modifications before a statement is executed.
+	ErrorStageBuild ErrorStage = "build"
+	// ErrorStageExecute is the execution of an audited statement.
+	ErrorStageExecute ErrorStage = "execute"
+	// ErrorStageFlush is the writing of audit records.
+	ErrorStageFlush ErrorStage = "flush"
+)
+
+// ErrorHandler is invoked on audit pipeline failures, e.g. for alerting.
+// mod is the affected modification, or nil if no modification was built; it must not be retained after the handler returns.
+// The error is returned to the caller regardless of the handler.
+type ErrorHandler func(ctx context.Context, err error, stage ErrorStage, mod *DatabaseModification)
+
+// handleError invokes the error handler, if any, once per modification, or once with nil if there are none.
+func (b *databaseModificationBuilder) handleError(ctx context.Context, err error, stage ErrorStage, mods []DatabaseModification) {
+	b.stats.failed.Add(1)
+	if b.errorHandler == nil {
+		return
+	}
+	defer func() {
+		// a panicking error handler has nowhere left to report to
+		_ = recover()
+	}()
+	if len(mods) == 0 {
+		b.errorHandler(ctx, err, stage, nil)
+		return
+	}
+	for i := range mods {
+		b.errorHandler(ctx, err, stage, &mods[i])
+	}
+}
+
+// recoverPanic converts a panic into an error wrapping ErrPanicRecovered. It must be deferred.
+func recoverPanic(err *error) {
+	if r := recover(); r != nil {
+		*err = fmt.Errorf("%w: %v", ErrPanicRecovered, r)
+	}
+}
+
+// notifyLogger passes written modifications to logger.
+// Panics of the logger are reported to the error handler rather than returned, as the modifications are already written.
+func (b *databaseModificationBuilder) notifyLogger(ctx context.Context, logger Logger, mods []DatabaseModification) {
+	for i := range mods {
+		func() {
+			var err error
+			defer func() {
+				if err != nil {
+					b.handleError(ctx, err, ErrorStageFlush, mods[i:i+1])
+				}
+			}()
+			defer recoverPanic(&err)
+			logger.Log(ctx, mods[i])
+		}()
+	}
+}
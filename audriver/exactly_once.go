@@ -0,0 +1,323 @@
+package audriver
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// IdempotentSink is a sink deduplicating writes by an idempotency token, e.g. by committing the token along with the
+// records in a Kafka transaction, or passing it as the idempotency key of an HTTP API. Wrapped by ExactlyOnceSink,
+// it receives every batch of audit records exactly once, across retries and restarts.
+type IdempotentSink interface {
+	// WriteIdempotent writes mods as the batch identified by token. A batch is written again with the same token if
+	// its acknowledgement may have been lost, and must not be written twice then. Returning nil acknowledges the
+	// batch, so it must only be returned once the batch is durably written. mods must not be retained after
+	// WriteIdempotent returns.
+	WriteIdempotent(ctx context.Context, token string, mods []DatabaseModification) error
+}
+
+// ExactlyOnceSink is an AuditSink delivering audit records to an IdempotentSink exactly once. Batches are spooled to
+// a file before they are written, and acknowledged in the file once written, so that batches left unacknowledged by
+// failures or crashes are redelivered with their original tokens by Recover, e.g. at startup.
+// The spool is removed once no batch is pending, and rewritten with the pending batches only once enough batches are
+// acknowledged, so that it does not grow under steady load. Call Close to close the spool when the sink is not used
+// anymore.
+type ExactlyOnceSink struct {
+	sink IdempotentSink
+	path string
+
+	// mu serializes appends to the spool; pending are the tokens of the batches spooled but not acknowledged.
+	mu      sync.Mutex
+	pending map[string]bool
+
+	// file is the spool opened for appending, if it is; acked is the number of acknowledgements appended to it since
+	// it was created or rewritten.
+	file  *os.File
+	acked int
+}
+
+// exactlyOnceCompactionAcks is the number of acknowledgements appended to the spool before it is rewritten with the
+// pending batches only.
+const exactlyOnceCompactionAcks = 1000
+
+// exactlyOnceEntry is an entry of the spool of an ExactlyOnceSink: a batch to write, or the acknowledgement of one.
+type exactlyOnceEntry struct {
+	Token         string                 `json:"token"`
+	Modifications []DatabaseModification `json:"modifications,omitempty"`
+	Acked         bool                   `json:"acked,omitempty"`
+}
+
+// NewExactlyOnceSink returns an ExactlyOnceSink writing to sink and spooling batches to the file at path, which is
+// created on the first write. Batches left unacknowledged in the file by a previous process are kept until Recover
+// redelivers them.
+func NewExactlyOnceSink(sink IdempotentSink, path string) (*ExactlyOnceSink, error) {
+	s := &ExactlyOnceSink{sink: sink, path: path, pending: make(map[string]bool)}
+	batches, err := s.read()
+	if err != nil {
+		return nil, err
+	}
+	for _, batch := range batches {
+		s.pending[batch.Token] = true
+	}
+	return s, nil
+}
+
+// String describes the sink written to and the spool.
+func (s *ExactlyOnceSink) String() string {
+	return describe(s.sink) + " spooled to " + s.path
+}
+
+// Write spools mods, writes them to the sink with a token derived from their IDs, and acknowledges them. Writes of
+// the same records, e.g. by retries, carry the same token, so that the sink writes them once.
+func (s *ExactlyOnceSink) Write(ctx context.Context, mods []DatabaseModification) error {
+	if len(mods) == 0 {
+		return nil
+	}
+	token := batchToken(mods)
+	if err := s.spool(token, mods); err != nil {
+		return err
+	}
+	return s.deliver(ctx, token, mods)
+}
+
+// Pending returns the number of batches spooled but not acknowledged.
+func (s *ExactlyOnceSink) Pending() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.pending)
+}
+
+// Recover redelivers the unacknowledged batches of the spool in the order they were spooled, with their original
+// tokens. It returns the number of records redelivered, and stops at the first batch failing, which is kept for the
+// next call.
+func (s *ExactlyOnceSink) Recover(ctx context.Context) (int, error) {
+	s.mu.Lock()
+	batches, err := s.read()
+	s.mu.Unlock()
+	if err != nil {
+		return 0, err
+	}
+
+	var delivered int
+	for _, batch := range batches {
+		if err := s.deliver(ctx, batch.Token, batch.Modifications); err != nil {
+			return delivered, fmt.Errorf("failed to recover audit batch %s: %w", batch.Token, err)
+		}
+		delivered += len(batch.Modifications)
+	}
+	return delivered, nil
+}
+
+// deliver writes the batch of token to the sink and acknowledges it.
+func (s *ExactlyOnceSink) deliver(ctx context.Context, token string, mods []DatabaseModification) error {
+	if err := s.sink.WriteIdempotent(ctx, token, mods); err != nil {
+		return err
+	}
+	// a lost acknowledgement only makes the batch redelivered with its token
+	return s.ack(token)
+}
+
+// spool appends the batch of token to the spool unless it is pending already.
+func (s *ExactlyOnceSink) spool(token string, mods []DatabaseModification) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.pending[token] {
+		return nil
+	}
+	if err := s.append(exactlyOnceEntry{Token: token, Modifications: mods}); err != nil {
+		return err
+	}
+	s.pending[token] = true
+	return nil
+}
+
+// ack appends the acknowledgement of the batch of token to the spool, and removes the spool once no batch is pending,
+// or rewrites it once enough batches are acknowledged.
+func (s *ExactlyOnceSink) ack(token string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.pending[token] {
+		return nil
+	}
+	if len(s.pending) == 1 {
+		if err := s.remove(); err != nil {
+			return err
+		}
+	} else {
+		if err := s.append(exactlyOnceEntry{Token: token, Acked: true}); err != nil {
+			return err
+		}
+		s.acked++
+	}
+	delete(s.pending, token)
+
+	if s.acked >= exactlyOnceCompactionAcks {
+		// the batch is acknowledged either way; a spool failing to be rewritten is rewritten on a later acknowledgement
+		_ = s.compact()
+	}
+	return nil
+}
+
+// append appends entry to the spool, syncing it so that it survives a crash. The spool is kept open across appends.
+func (s *ExactlyOnceSink) append(entry exactlyOnceEntry) error {
+	payload, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to encode audit spool entry: %w", err)
+	}
+	if s.file == nil {
+		f, err := os.OpenFile(s.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+		if err != nil {
+			return fmt.Errorf("failed to open audit spool: %w", err)
+		}
+		s.file = f
+	}
+	if _, err := s.file.Write(appendSpoolEntry(nil, payload)); err != nil {
+		s.closeFile()
+		return fmt.Errorf("failed to write audit spool: %w", err)
+	}
+	if err := s.file.Sync(); err != nil {
+		s.closeFile()
+		return fmt.Errorf("failed to sync audit spool: %w", err)
+	}
+	return nil
+}
+
+// remove closes and removes the spool.
+func (s *ExactlyOnceSink) remove() error {
+	s.closeFile()
+	if err := os.Remove(s.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove audit spool: %w", err)
+	}
+	s.acked = 0
+	return nil
+}
+
+// compact rewrites the spool with its pending batches only, through a temporary file renamed over it, so that a crash
+// leaves either the old spool or the new one.
+func (s *ExactlyOnceSink) compact() error {
+	batches, err := s.read()
+	if err != nil {
+		return err
+	}
+	var data []byte
+	for _, batch := range batches {
+		payload, err := json.Marshal(batch)
+		if err != nil {
+			return fmt.Errorf("failed to encode audit spool entry: %w", err)
+		}
+		data = appendSpoolEntry(data, payload)
+	}
+
+	tmp := s.path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_TRUNC|os.O_CREATE, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to create audit spool: %w", err)
+	}
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp, s.path)
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("failed to rewrite audit spool: %w", err)
+	}
+
+	// the open file is the spool replaced by the rename
+	s.closeFile()
+	s.acked = 0
+	return nil
+}
+
+// closeFile closes the spool opened for appending, if it is.
+func (s *ExactlyOnceSink) closeFile() {
+	if s.file != nil {
+		_ = s.file.Close()
+		s.file = nil
+	}
+}
+
+// Close closes the spool. Batches pending in it are kept for Recover; later writes reopen it.
+func (s *ExactlyOnceSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Close()
+	s.file = nil
+	if err != nil {
+		return fmt.Errorf("failed to close audit spool: %w", err)
+	}
+	return nil
+}
+
+// read returns the unacknowledged batches of the spool in the order they were spooled, skipping torn entries.
+func (s *ExactlyOnceSink) read() ([]exactlyOnceEntry, error) {
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read audit spool: %w", err)
+	}
+
+	var batches []exactlyOnceEntry
+	acked := make(map[string]bool)
+	_, err = scanSpool(data, func(payload []byte) error {
+		var entry exactlyOnceEntry
+		if err := json.Unmarshal(payload, &entry); err != nil {
+			return fmt.Errorf("failed to decode audit spool entry: %w", err)
+		}
+		if entry.Acked {
+			acked[entry.Token] = true
+		} else {
+			batches = append(batches, entry)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	pending := batches[:0]
+	seen := make(map[string]bool, len(batches))
+	for _, batch := range batches {
+		if !acked[batch.Token] && !seen[batch.Token] {
+			seen[batch.Token] = true
+			pending = append(pending, batch)
+		}
+	}
+	return pending, nil
+}
+
+// batchToken returns the idempotency token of a batch of audit records, derived from their IDs.
+func batchToken(mods []DatabaseModification) string {
+	h := sha256.New()
+	for _, mod := range mods {
+		h.Write([]byte(mod.ID))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// WriteIdempotent writes mods like Write. Records written before are skipped by their IDs, so the token is not needed.
+func (s DBSink) WriteIdempotent(ctx context.Context, _ string, mods []DatabaseModification) error {
+	return s.Write(ctx, mods)
+}
+
+var (
+	_ AuditSink      = (*ExactlyOnceSink)(nil)
+	_ IdempotentSink = DBSink{}
+)
@@ -0,0 +1,202 @@
+package audriver_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mickamy/go-sql-audit-driver/audriver"
+)
+
+// idempotentSink is an audriver.IdempotentSink deduplicating batches by their tokens, like a Kafka transactional
+// producer committing the tokens with the records.
+type idempotentSink struct {
+	mu      sync.Mutex
+	tokens  map[string]bool
+	written []string
+	writes  int
+
+	// failures is the number of writes failing before the batch is written, and lostAcks the number of writes
+	// failing after it is written.
+	failures int
+	lostAcks int
+}
+
+func (s *idempotentSink) WriteIdempotent(_ context.Context, token string, mods []audriver.DatabaseModification) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.writes++
+	if s.failures > 0 {
+		s.failures--
+		return errors.New("sink is unavailable")
+	}
+	if s.tokens == nil {
+		s.tokens = make(map[string]bool)
+	}
+	if !s.tokens[token] {
+		s.tokens[token] = true
+		for _, mod := range mods {
+			s.written = append(s.written, mod.ID)
+		}
+	}
+	if s.lostAcks > 0 {
+		s.lostAcks--
+		return errors.New("connection reset")
+	}
+	return nil
+}
+
+func (s *idempotentSink) records() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.written...)
+}
+
+// TestExactlyOnceSink tests that batches are written exactly once across retries and restarts
+func TestExactlyOnceSink(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name     string
+		failures int
+		lostAcks int
+		pending  int
+	}{
+		{name: "acknowledged", pending: 0},
+		{name: "failed", failures: 1, pending: 1},
+		{name: "acknowledgement_lost", lostAcks: 1, pending: 1},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// arrange
+			ctx := t.Context()
+			path := filepath.Join(t.TempDir(), "spool")
+			sink := &idempotentSink{failures: tc.failures, lostAcks: tc.lostAcks}
+			exactlyOnce, err := audriver.NewExactlyOnceSink(sink, path)
+			require.NoError(t, err)
+			mods := []audriver.DatabaseModification{{ID: uuid.New().String()}, {ID: uuid.New().String()}}
+
+			// act
+			writeErr := exactlyOnce.Write(ctx, mods)
+			restarted, err := audriver.NewExactlyOnceSink(sink, path)
+			require.NoError(t, err)
+			pending := restarted.Pending()
+			recovered, recoverErr := restarted.Recover(ctx)
+
+			// assert
+			assert.Equal(t, tc.pending > 0, writeErr != nil)
+			assert.Equal(t, tc.pending, pending, "unacknowledged batches should survive restarts")
+			require.NoError(t, recoverErr)
+			assert.Equal(t, tc.pending*len(mods), recovered)
+			assert.Equal(t, []string{mods[0].ID, mods[1].ID}, sink.records(), "records should be written exactly once")
+			assert.Zero(t, restarted.Pending())
+			assert.NoFileExists(t, path, "the spool should be removed once every batch is acknowledged")
+		})
+	}
+}
+
+// TestExactlyOnceSink_Retry tests that retried writes of a batch carry its token
+func TestExactlyOnceSink_Retry(t *testing.T) {
+	t.Parallel()
+
+	// arrange
+	ctx := t.Context()
+	sink := &idempotentSink{lostAcks: 1}
+	exactlyOnce, err := audriver.NewExactlyOnceSink(sink, filepath.Join(t.TempDir(), "spool"))
+	require.NoError(t, err)
+	mods := []audriver.DatabaseModification{{ID: uuid.New().String()}}
+
+	// act
+	firstErr := exactlyOnce.Write(ctx, mods)
+	retryErr := exactlyOnce.Write(ctx, mods)
+
+	// assert
+	require.Error(t, firstErr)
+	require.NoError(t, retryErr)
+	assert.Equal(t, 2, sink.writes)
+	assert.Equal(t, []string{mods[0].ID}, sink.records())
+	assert.Zero(t, exactlyOnce.Pending())
+}
+
+// TestExactlyOnceSink_TornAck tests that batches whose acknowledgement was torn by a crash are redelivered
+func TestExactlyOnceSink_TornAck(t *testing.T) {
+	t.Parallel()
+
+	// arrange
+	ctx := t.Context()
+	path := filepath.Join(t.TempDir(), "spool")
+	sink := &idempotentSink{failures: 1}
+	exactlyOnce, err := audriver.NewExactlyOnceSink(sink, path)
+	require.NoError(t, err)
+	failed := []audriver.DatabaseModification{{ID: uuid.New().String()}}
+	written := []audriver.DatabaseModification{{ID: uuid.New().String()}}
+	require.Error(t, exactlyOnce.Write(ctx, failed))
+	require.NoError(t, exactlyOnce.Write(ctx, written))
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path, data[:len(data)-3], 0o600))
+
+	// act
+	restarted, err := audriver.NewExactlyOnceSink(sink, path)
+	require.NoError(t, err)
+	recovered, err := restarted.Recover(ctx)
+
+	// assert
+	require.NoError(t, err)
+	assert.Equal(t, 2, recovered, "both batches should be redelivered")
+	assert.Equal(t, []string{written[0].ID, failed[0].ID}, sink.records(), "the written batch should not be written again")
+}
+
+// TestExactlyOnceSink_Compaction tests that the spool is rewritten with the pending batches once enough batches are
+// acknowledged while others are pending
+func TestExactlyOnceSink_Compaction(t *testing.T) {
+	t.Parallel()
+
+	// arrange
+	ctx := t.Context()
+	path := filepath.Join(t.TempDir(), "spool")
+	sink := &idempotentSink{failures: 1}
+	exactlyOnce, err := audriver.NewExactlyOnceSink(sink, path)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = exactlyOnce.Close()
+	})
+	failed := []audriver.DatabaseModification{{ID: uuid.New().String()}}
+	require.Error(t, exactlyOnce.Write(ctx, failed))
+
+	// act
+	const batches = 2500
+	for range batches {
+		require.NoError(t, exactlyOnce.Write(ctx, []audriver.DatabaseModification{{ID: uuid.New().String()}}))
+	}
+
+	// assert
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	// the failed batch, and a batch and its acknowledgement for each batch acknowledged since the last rewrite
+	assert.Equal(t, 1+2*(batches%1000), bytes.Count(data, []byte("ASP1")), "acknowledged batches should be compacted away")
+	assert.Equal(t, 1, exactlyOnce.Pending())
+	assert.NoFileExists(t, path+".tmp")
+
+	require.NoError(t, exactlyOnce.Close())
+	restarted, err := audriver.NewExactlyOnceSink(sink, path)
+	require.NoError(t, err)
+	recovered, err := restarted.Recover(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, recovered, "only the pending batch should be redelivered")
+	assert.Equal(t, failed[0].ID, sink.records()[batches])
+	assert.NoFileExists(t, path)
+}
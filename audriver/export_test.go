@@ -0,0 +1,25 @@
+package audriver
+
+// Unexported functions of the SQL classification pipeline, exported for fuzz tests.
+var (
+	IsDML               = isDML
+	SkipLeadingComments = skipLeadingComments
+)
+
+// ParseTableAction parses the action and target table of sql.
+func ParseTableAction(sql string) (action DatabaseModificationAction, schema string, table string, end int, err error) {
+	ta, err := parseTableAction(sql)
+	return ta.action, ta.schema, ta.table, ta.end, err
+}
+
+// DecodeBackfill decodes wal2json changes, given as pairs of LSN and data, as Backfill does.
+// It returns the modifications to backfill, the position to advance the slot to, and whether To was reached.
+func DecodeBackfill(cfg BackfillConfig, changes [][2]string) ([]DatabaseModification, BackfillResult, string, bool, error) {
+	walChanges := make([]walChange, len(changes))
+	for i, change := range changes {
+		walChanges[i] = walChange{lsn: change[0], data: change[1]}
+	}
+	var result BackfillResult
+	mods, consumed, done, err := newBackfillDecoder(cfg).decode(walChanges, &result)
+	return mods, result, consumed, done, err
+}
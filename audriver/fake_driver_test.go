@@ -0,0 +1,404 @@
+package audriver_test
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// fakeDriver is an in-memory driver.Driver that records executed statements instead of running them.
+type fakeDriver struct {
+	mu       sync.Mutex
+	execs    []fakeExec
+	prepares int
+
+	// skipExec makes ExecContext return driver.ErrSkip to force the prepared statement path.
+	skipExec bool
+
+	// lastInsertID is returned by results of executed statements; zero means LastInsertId is not supported.
+	lastInsertID int64
+
+	// auditErr is returned by inserts into database_modifications if set.
+	auditErr error
+
+	// auditFailures limits the number of inserts into database_modifications failing with auditErr, if positive.
+	auditFailures int
+
+	// auditDelay delays inserts into database_modifications.
+	auditDelay time.Duration
+
+	// checkNamedValue converts arguments of prepared statements like a driver.NamedValueChecker if set.
+	checkNamedValue func(nv *driver.NamedValue) error
+
+	// abortTransactions makes a failed statement abort its transaction as PostgreSQL does: further statements fail
+	// until the transaction is rolled back, or rolled back to a savepoint, and commits fail.
+	abortTransactions bool
+
+	// commitErr is returned by commits of transactions if set.
+	commitErr error
+
+	// rollbackErr is returned by rollbacks of transactions if set.
+	rollbackErr error
+
+	// commits and rollbacks count the finished transactions.
+	commits   int
+	rollbacks int
+
+	// query returns the columns and rows of a query; no rows are returned if it is nil.
+	query func(query string, args []driver.NamedValue) ([]string, [][]driver.Value)
+}
+
+// fakeExec is a statement recorded by fakeDriver.
+type fakeExec struct {
+	query    string
+	args     []driver.NamedValue
+	prepared bool
+}
+
+func (d *fakeDriver) Open(string) (driver.Conn, error) {
+	return &fakeConn{driver: d}, nil
+}
+
+func (d *fakeDriver) record(exec fakeExec) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.execs = append(d.execs, exec)
+}
+
+// executed returns the statements executed so far.
+// finished returns the numbers of committed and rolled back transactions.
+func (d *fakeDriver) finished() (int, int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.commits, d.rollbacks
+}
+
+// failAudit returns the error of the next insert into database_modifications, if it fails.
+func (d *fakeDriver) failAudit() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	err := d.auditErr
+	if err != nil && d.auditFailures > 0 {
+		d.auditFailures--
+		if d.auditFailures == 0 {
+			d.auditErr = nil
+		}
+	}
+	return err
+}
+
+func (d *fakeDriver) executed() []fakeExec {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]fakeExec(nil), d.execs...)
+}
+
+func (d *fakeDriver) result() driver.Result {
+	return fakeResult{lastInsertID: d.lastInsertID}
+}
+
+// auditInserts returns the executed statements inserting into database_modifications.
+func (d *fakeDriver) auditInserts() []fakeExec {
+	var inserts []fakeExec
+	for _, exec := range d.executed() {
+		if strings.HasPrefix(exec.query, "INSERT INTO database_modifications") {
+			inserts = append(inserts, exec)
+		}
+	}
+	return inserts
+}
+
+// value returns the value inserted into column by the first row of an audit insert, or nil if the column is absent.
+func (e fakeExec) value(column string) any {
+	values := e.values(column)
+	if len(values) == 0 {
+		return nil
+	}
+	return values[0]
+}
+
+// columns returns the columns written by an audit insert.
+func (e fakeExec) columns() []string {
+	start := strings.Index(e.query, "(")
+	end := strings.Index(e.query, ")")
+	if start < 0 || end < start {
+		return nil
+	}
+	columns := strings.Split(e.query[start+1:end], ", ")
+	for i := range columns {
+		columns[i] = strings.Trim(columns[i], "`\"")
+	}
+	return columns
+}
+
+// values returns the values inserted into column by all rows of an audit insert.
+func (e fakeExec) values(column string) []any {
+	start := strings.Index(e.query, "(")
+	end := strings.Index(e.query, ")")
+	if start < 0 || end < start {
+		return nil
+	}
+	columns := strings.Split(e.query[start+1:end], ", ")
+	for i, name := range columns {
+		if strings.Trim(name, "`") != column {
+			continue
+		}
+		var values []any
+		for j := i; j < len(e.args); j += len(columns) {
+			values = append(values, e.args[j].Value)
+		}
+		return values
+	}
+	return nil
+}
+
+type fakeResult struct {
+	lastInsertID int64
+}
+
+func (r fakeResult) LastInsertId() (int64, error) {
+	if r.lastInsertID == 0 {
+		return 0, errors.New("LastInsertId is not supported")
+	}
+	return r.lastInsertID, nil
+}
+
+func (r fakeResult) RowsAffected() (int64, error) {
+	return 1, nil
+}
+
+type fakeConn struct {
+	driver *fakeDriver
+
+	// inTx and aborted report whether the connection is in a transaction, and whether it is aborted.
+	inTx    bool
+	aborted bool
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	c.driver.mu.Lock()
+	defer c.driver.mu.Unlock()
+	c.driver.prepares++
+	return &fakeStmt{conn: c, query: query}, nil
+}
+
+func (c *fakeConn) PrepareContext(_ context.Context, query string) (driver.Stmt, error) {
+	return c.Prepare(query)
+}
+
+func (c *fakeConn) Close() error {
+	return nil
+}
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	c.inTx = true
+	return &fakeTx{driver: c.driver, conn: c}, nil
+}
+
+func (c *fakeConn) BeginTx(context.Context, driver.TxOptions) (driver.Tx, error) {
+	return c.Begin()
+}
+
+func (c *fakeConn) ExecContext(_ context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if c.driver.skipExec {
+		return nil, driver.ErrSkip
+	}
+	if c.aborted {
+		if !strings.HasPrefix(query, "ROLLBACK TO SAVEPOINT") {
+			return nil, sqlStateError("25P02")
+		}
+		c.aborted = false
+	}
+	if strings.HasPrefix(query, "INSERT INTO database_modifications") {
+		time.Sleep(c.driver.auditDelay)
+		if err := c.driver.failAudit(); err != nil {
+			c.aborted = c.inTx && c.driver.abortTransactions
+			return nil, err
+		}
+	}
+	c.driver.record(fakeExec{query: query, args: args})
+	return c.driver.result(), nil
+}
+
+func (c *fakeConn) QueryContext(_ context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if c.driver.query == nil {
+		return &fakeRows{}, nil
+	}
+	columns, rows := c.driver.query(query, args)
+	return &fakeRows{columns: columns, rows: rows}, nil
+}
+
+type fakeStmt struct {
+	conn  *fakeConn
+	query string
+}
+
+func (s *fakeStmt) Close() error {
+	return nil
+}
+
+func (s *fakeStmt) NumInput() int {
+	return -1
+}
+
+func (s *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	named := make([]driver.NamedValue, len(args))
+	for i, arg := range args {
+		named[i] = driver.NamedValue{Ordinal: i + 1, Value: arg}
+	}
+	return s.ExecContext(context.Background(), named)
+}
+
+func (s *fakeStmt) ExecContext(_ context.Context, args []driver.NamedValue) (driver.Result, error) {
+	s.conn.driver.record(fakeExec{query: s.query, args: args, prepared: true})
+	return s.conn.driver.result(), nil
+}
+
+func (s *fakeStmt) CheckNamedValue(nv *driver.NamedValue) error {
+	if s.conn.driver.checkNamedValue == nil {
+		return driver.ErrSkip
+	}
+	return s.conn.driver.checkNamedValue(nv)
+}
+
+func (s *fakeStmt) Query([]driver.Value) (driver.Rows, error) {
+	return &fakeRows{}, nil
+}
+
+type fakeTx struct {
+	driver *fakeDriver
+	conn   *fakeConn
+}
+
+// finish ends the transaction on its connection, returning whether it was aborted.
+func (tx *fakeTx) finish() bool {
+	if tx.conn == nil {
+		return false
+	}
+	aborted := tx.conn.aborted
+	tx.conn.inTx, tx.conn.aborted = false, false
+	return aborted
+}
+
+func (tx *fakeTx) Commit() error {
+	if tx.finish() {
+		return sqlStateError("25P02")
+	}
+	if tx.driver != nil {
+		tx.driver.mu.Lock()
+		tx.driver.commits++
+		tx.driver.mu.Unlock()
+		return tx.driver.commitErr
+	}
+	return nil
+}
+
+func (tx *fakeTx) Rollback() error {
+	tx.finish()
+	if tx.driver == nil {
+		return nil
+	}
+	tx.driver.mu.Lock()
+	tx.driver.rollbacks++
+	tx.driver.mu.Unlock()
+	return tx.driver.rollbackErr
+}
+
+type fakeRows struct {
+	columns []string
+	rows    [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string {
+	return r.columns
+}
+
+func (r *fakeRows) Close() error {
+	return nil
+}
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
+
+// beginOnlyDriver is a fakeDriver whose connections start transactions with Begin only, like drivers predating
+// driver.ConnBeginTx.
+type beginOnlyDriver struct {
+	*fakeDriver
+}
+
+func (d beginOnlyDriver) Open(string) (driver.Conn, error) {
+	return &beginOnlyConn{conn: &fakeConn{driver: d.fakeDriver}}, nil
+}
+
+type beginOnlyConn struct {
+	conn *fakeConn
+}
+
+func (c *beginOnlyConn) Prepare(query string) (driver.Stmt, error) {
+	return c.conn.Prepare(query)
+}
+
+func (c *beginOnlyConn) Close() error {
+	return c.conn.Close()
+}
+
+func (c *beginOnlyConn) Begin() (driver.Tx, error) {
+	return c.conn.Begin()
+}
+
+func (c *beginOnlyConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	return c.conn.ExecContext(ctx, query, args)
+}
+
+func (c *beginOnlyConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	return c.conn.QueryContext(ctx, query, args)
+}
+
+// discardDriver is an in-memory driver.Driver that discards executed statements, for benchmarks.
+type discardDriver struct{}
+
+func (d *discardDriver) Open(string) (driver.Conn, error) {
+	return &discardConn{}, nil
+}
+
+type discardConn struct{}
+
+func (c *discardConn) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("prepared statements are not supported")
+}
+
+func (c *discardConn) Close() error {
+	return nil
+}
+
+func (c *discardConn) Begin() (driver.Tx, error) {
+	return &fakeTx{}, nil
+}
+
+func (c *discardConn) BeginTx(context.Context, driver.TxOptions) (driver.Tx, error) {
+	return &fakeTx{}, nil
+}
+
+func (c *discardConn) ExecContext(context.Context, string, []driver.NamedValue) (driver.Result, error) {
+	return fakeResult{}, nil
+}
+
+var (
+	_ driver.Driver             = (*fakeDriver)(nil)
+	_ driver.ConnBeginTx        = (*fakeConn)(nil)
+	_ driver.ConnPrepareContext = (*fakeConn)(nil)
+	_ driver.ExecerContext      = (*fakeConn)(nil)
+	_ driver.QueryerContext     = (*fakeConn)(nil)
+	_ driver.StmtExecContext    = (*fakeStmt)(nil)
+	_ driver.ExecerContext      = (*beginOnlyConn)(nil)
+)
@@ -1,11 +1,15 @@
 package audriver
 
 import (
+	"fmt"
 	"path/filepath"
 	"strings"
 )
 
 // TableFilter is an interface that defines a method to determine if a table should be logged.
+// Filters are checked against the table a statement modifies only: tables it merely reads, such as the sources of
+// INSERT ... SELECT, the FROM and JOIN relations of UPDATE ... FROM, and the USING relations of DELETE ... USING,
+// neither exclude nor include the statement.
 type TableFilter interface {
 	ShouldLog(tableName string) bool
 }
@@ -20,38 +24,57 @@ func (f TableFilterFunc) ShouldLog(tableName string) bool {
 
 // NewExcludePatternFilter creates a TableFilter that excludes tables matching any of the provided patterns.
 func NewExcludePatternFilter(patterns ...string) TableFilter {
-	return TableFilterFunc(func(tableName string) bool {
-		for _, pattern := range patterns {
-			if matched, _ := filepath.Match(pattern, tableName); matched {
-				return false
+	return describedTableFilter{
+		description: fmt.Sprintf("exclude patterns %q", patterns),
+		TableFilterFunc: TableFilterFunc(func(tableName string) bool {
+			for _, pattern := range patterns {
+				if matched, _ := filepath.Match(pattern, tableName); matched {
+					return false
+				}
 			}
-		}
-		return true
-	})
+			return true
+		}),
+	}
 }
 
 // NewExcludePrefixFilter creates a TableFilter that excludes tables with names starting with any of the provided prefixes.
 func NewExcludePrefixFilter(prefixes ...string) TableFilter {
-	return TableFilterFunc(func(tableName string) bool {
-		for _, prefix := range prefixes {
-			if strings.HasPrefix(tableName, prefix) {
-				return false
+	return describedTableFilter{
+		description: fmt.Sprintf("exclude prefixes %q", prefixes),
+		TableFilterFunc: TableFilterFunc(func(tableName string) bool {
+			for _, prefix := range prefixes {
+				if strings.HasPrefix(tableName, prefix) {
+					return false
+				}
 			}
-		}
-		return true
-	})
+			return true
+		}),
+	}
 }
 
 // NewIncludePatternFilter creates a TableFilter that includes only tables matching any of the provided patterns.
 func NewIncludePatternFilter(patterns ...string) TableFilter {
-	return TableFilterFunc(func(tableName string) bool {
-		for _, pattern := range patterns {
-			if matched, _ := filepath.Match(pattern, tableName); matched {
-				return true
+	return describedTableFilter{
+		description: fmt.Sprintf("include patterns %q", patterns),
+		TableFilterFunc: TableFilterFunc(func(tableName string) bool {
+			for _, pattern := range patterns {
+				if matched, _ := filepath.Match(pattern, tableName); matched {
+					return true
+				}
 			}
-		}
-		return false
-	})
+			return false
+		}),
+	}
+}
+
+// describedTableFilter is a built-in TableFilter that describes itself in Driver.ConfigJSON.
+type describedTableFilter struct {
+	TableFilterFunc
+	description string
+}
+
+func (f describedTableFilter) String() string {
+	return f.description
 }
 
 type TableFilters []TableFilter
@@ -0,0 +1,131 @@
+package audriver
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// TableFilterSource loads table filters from an external source, such as a database table or a config service.
+type TableFilterSource interface {
+	LoadTableFilters(ctx context.Context) (TableFilters, error)
+}
+
+// TableFilterSourceFunc is a function type that implements the TableFilterSource interface.
+type TableFilterSourceFunc func(ctx context.Context) (TableFilters, error)
+
+func (f TableFilterSourceFunc) LoadTableFilters(ctx context.Context) (TableFilters, error) {
+	return f(ctx)
+}
+
+// Table filter kinds understood by NewSQLTableFilterSource.
+const (
+	TableFilterKindExcludePattern = "exclude_pattern"
+	TableFilterKindExcludePrefix  = "exclude_prefix"
+	TableFilterKindIncludePattern = "include_pattern"
+)
+
+// NewSQLTableFilterSource creates a TableFilterSource reading filters from db with the given query.
+// The query must return two columns: the filter kind (one of the TableFilterKind constants) and the pattern or prefix.
+// Include patterns are combined, so a table is logged if it matches any of them.
+func NewSQLTableFilterSource(db *sql.DB, query string) TableFilterSource {
+	return TableFilterSourceFunc(func(ctx context.Context) (TableFilters, error) {
+		rows, err := db.QueryContext(ctx, query)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query table filters: %w", err)
+		}
+		defer func(rows *sql.Rows) {
+			_ = rows.Close()
+		}(rows)
+
+		var (
+			filters  TableFilters
+			includes []string
+		)
+		for rows.Next() {
+			var kind, pattern string
+			if err := rows.Scan(&kind, &pattern); err != nil {
+				return nil, fmt.Errorf("failed to scan table filter: %w", err)
+			}
+			switch kind {
+			case TableFilterKindExcludePattern:
+				filters = append(filters, NewExcludePatternFilter(pattern))
+			case TableFilterKindExcludePrefix:
+				filters = append(filters, NewExcludePrefixFilter(pattern))
+			case TableFilterKindIncludePattern:
+				includes = append(includes, pattern)
+			default:
+				return nil, fmt.Errorf("unknown table filter kind: %s", kind)
+			}
+		}
+		if err := rows.Err(); err != nil {
+			return nil, fmt.Errorf("failed to read table filters: %w", err)
+		}
+
+		if len(includes) > 0 {
+			filters = append(filters, NewIncludePatternFilter(includes...))
+		}
+
+		return filters, nil
+	})
+}
+
+// ReloadableTableFilter is a TableFilter whose filters are loaded from a TableFilterSource and can be refreshed
+// at runtime, so audit policy changes don't require a redeploy.
+type ReloadableTableFilter struct {
+	source  TableFilterSource
+	filters atomic.Pointer[TableFilters]
+}
+
+// NewReloadableTableFilter creates a ReloadableTableFilter and loads its initial filters from source.
+func NewReloadableTableFilter(ctx context.Context, source TableFilterSource) (*ReloadableTableFilter, error) {
+	f := &ReloadableTableFilter{source: source}
+	if err := f.Reload(ctx); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// ShouldLog checks if the table name should be logged based on the most recently loaded filters.
+func (f *ReloadableTableFilter) ShouldLog(tableName string) bool {
+	filters := f.filters.Load()
+	if filters == nil {
+		return true
+	}
+	return filters.ShouldLog(tableName)
+}
+
+// Reload loads filters from the source and replaces the current filters.
+// The current filters are kept if loading fails.
+func (f *ReloadableTableFilter) Reload(ctx context.Context) error {
+	filters, err := f.source.LoadTableFilters(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load table filters: %w", err)
+	}
+	f.filters.Store(&filters)
+	return nil
+}
+
+// Run reloads the filters every interval until ctx is done.
+// Reload errors are passed to onError if it is not nil.
+func (f *ReloadableTableFilter) Run(ctx context.Context, interval time.Duration, onError func(error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := f.Reload(ctx); err != nil && onError != nil {
+				onError(err)
+			}
+		}
+	}
+}
+
+var (
+	_ TableFilter = (*ReloadableTableFilter)(nil)
+)
@@ -0,0 +1,49 @@
+package audriver_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mickamy/go-sql-audit-driver/audriver"
+)
+
+// TestReloadableTableFilter tests that reloaded filters replace the current filters
+func TestReloadableTableFilter(t *testing.T) {
+	t.Parallel()
+
+	// arrange
+	var (
+		filters audriver.TableFilters
+		loadErr error
+	)
+	source := audriver.TableFilterSourceFunc(func(ctx context.Context) (audriver.TableFilters, error) {
+		return filters, loadErr
+	})
+
+	filters = audriver.TableFilters{audriver.NewExcludePrefixFilter("temp_")}
+	filter, err := audriver.NewReloadableTableFilter(t.Context(), source)
+	require.NoError(t, err)
+	assert.False(t, filter.ShouldLog("temp_users"))
+	assert.True(t, filter.ShouldLog("log_users"))
+
+	// act
+	filters = audriver.TableFilters{audriver.NewExcludePrefixFilter("log_")}
+	err = filter.Reload(t.Context())
+
+	// assert
+	require.NoError(t, err)
+	assert.True(t, filter.ShouldLog("temp_users"))
+	assert.False(t, filter.ShouldLog("log_users"))
+
+	// act - failed reloads keep the current filters
+	loadErr = errors.New("source unavailable")
+	err = filter.Reload(t.Context())
+
+	// assert
+	require.Error(t, err)
+	assert.False(t, filter.ShouldLog("log_users"))
+}
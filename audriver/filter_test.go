@@ -1,9 +1,11 @@
 package audriver_test
 
 import (
+	"context"
 	"database/sql"
 	"database/sql/driver"
 	"fmt"
+	"regexp"
 	"testing"
 
 	"github.com/DATA-DOG/go-txdb"
@@ -97,3 +99,281 @@ func TestAuditDriver_TableFilters(t *testing.T) {
 		})
 	}
 }
+
+// TestAuditDriver_TableFilters_TargetTable tests that filters are checked against the modified table only,
+// not the tables a statement reads
+func TestAuditDriver_TableFilters_TargetTable(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	ctx = audriver.WithOperatorID(ctx, uuid.New().String())
+	ctx = audriver.WithExecutionID(ctx, uuid.New().String())
+
+	exclude := audriver.NewExcludePrefixFilter("temp_")
+	include := audriver.NewIncludePatternFilter("users")
+
+	testCases := []struct {
+		name           string
+		filter         audriver.TableFilter
+		query          string
+		shouldBeLogged bool
+	}{
+		{
+			name:           "insert_select_from_excluded_table",
+			filter:         exclude,
+			query:          "INSERT INTO users (id, name) SELECT id, name FROM temp_import",
+			shouldBeLogged: true,
+		},
+		{
+			name:           "insert_select_into_excluded_table",
+			filter:         exclude,
+			query:          "INSERT INTO temp_import (id, name) SELECT id, name FROM users",
+			shouldBeLogged: false,
+		},
+		{
+			name:           "update_from_excluded_table",
+			filter:         exclude,
+			query:          "UPDATE users SET name = t.name FROM temp_names t JOIN temp_ids i ON i.id = t.id WHERE t.id = users.id",
+			shouldBeLogged: true,
+		},
+		{
+			name:           "delete_using_excluded_table",
+			filter:         exclude,
+			query:          "DELETE FROM users USING temp_ids WHERE users.id = temp_ids.id",
+			shouldBeLogged: true,
+		},
+		{
+			name:           "delete_with_subquery_on_excluded_table",
+			filter:         exclude,
+			query:          "DELETE FROM users WHERE id IN (SELECT id FROM temp_ids)",
+			shouldBeLogged: true,
+		},
+		{
+			name:           "insert_select_from_included_table",
+			filter:         include,
+			query:          "INSERT INTO orders (user_id) SELECT id FROM users",
+			shouldBeLogged: false,
+		},
+		{
+			name:           "update_included_table_from_other_table",
+			filter:         include,
+			query:          "UPDATE users SET plan = p.name FROM plans p WHERE p.id = users.plan_id",
+			shouldBeLogged: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// arrange
+			baseDriver := &fakeDriver{}
+			db := setUpFakeTestDB(t, baseDriver, audriver.WithTableFilters(tc.filter))
+
+			// act
+			_, err := db.ExecContext(ctx, tc.query)
+			require.NoError(t, err)
+
+			// assert
+			inserts := baseDriver.auditInserts()
+			if !tc.shouldBeLogged {
+				assert.Empty(t, inserts)
+				return
+			}
+			require.Len(t, inserts, 1)
+			assert.Equal(t, tc.query, inserts[0].value("sql"))
+		})
+	}
+}
+
+// TestAuditDriver_Actions tests that only the configured actions are audited
+func TestAuditDriver_Actions(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	ctx = audriver.WithOperatorID(ctx, uuid.New().String())
+	ctx = audriver.WithExecutionID(ctx, uuid.New().String())
+
+	testCases := []struct {
+		name           string
+		actions        []audriver.DatabaseModificationAction
+		query          string
+		shouldBeLogged bool
+	}{
+		{
+			name:           "insert_is_audited",
+			actions:        []audriver.DatabaseModificationAction{audriver.DatabaseModificationActionInsert, audriver.DatabaseModificationActionDelete},
+			query:          `INSERT INTO "users" ("id") VALUES ($1)`,
+			shouldBeLogged: true,
+		},
+		{
+			name:           "delete_is_audited",
+			actions:        []audriver.DatabaseModificationAction{audriver.DatabaseModificationActionInsert, audriver.DatabaseModificationActionDelete},
+			query:          `DELETE FROM "users" WHERE "id" = $1`,
+			shouldBeLogged: true,
+		},
+		{
+			name:           "update_is_skipped",
+			actions:        []audriver.DatabaseModificationAction{audriver.DatabaseModificationActionInsert, audriver.DatabaseModificationActionDelete},
+			query:          `UPDATE "users" SET "name" = 'name' WHERE "id" = $1`,
+			shouldBeLogged: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// arrange
+			baseDriver := &fakeDriver{}
+			db := setUpFakeTestDB(t, baseDriver, audriver.WithActions(tc.actions...))
+
+			// act
+			_, err := db.ExecContext(ctx, tc.query, uuid.New().String())
+			require.NoError(t, err)
+
+			// assert
+			if tc.shouldBeLogged {
+				assert.Len(t, baseDriver.auditInserts(), 1)
+			} else {
+				assert.Empty(t, baseDriver.auditInserts())
+			}
+		})
+	}
+}
+
+// TestAuditDriver_IgnoreSQLPatterns tests that statements matching ignore patterns are not audited
+func TestAuditDriver_IgnoreSQLPatterns(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	ctx = audriver.WithOperatorID(ctx, uuid.New().String())
+	ctx = audriver.WithExecutionID(ctx, uuid.New().String())
+
+	patterns := []*regexp.Regexp{
+		regexp.MustCompile(`(?i)^UPDATE\s+"?heartbeats"?`),
+		regexp.MustCompile(`pg_advisory`),
+	}
+
+	testCases := []struct {
+		name           string
+		query          string
+		shouldBeLogged bool
+	}{
+		{
+			name:           "heartbeat_update_is_ignored",
+			query:          `UPDATE "heartbeats" SET "beat_at" = now() WHERE "id" = $1`,
+			shouldBeLogged: false,
+		},
+		{
+			name:           "advisory_lock_bookkeeping_is_ignored",
+			query:          `INSERT INTO "locks" ("id", "key") VALUES ($1, pg_advisory_xact_lock(1))`,
+			shouldBeLogged: false,
+		},
+		{
+			name:           "other_statement_is_audited",
+			query:          `UPDATE "users" SET "name" = 'name' WHERE "id" = $1`,
+			shouldBeLogged: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// arrange
+			baseDriver := &fakeDriver{}
+			db := setUpFakeTestDB(t, baseDriver, audriver.WithIgnoreSQLPatterns(patterns...))
+
+			// act
+			_, err := db.ExecContext(ctx, tc.query, uuid.New().String())
+			require.NoError(t, err)
+
+			// assert
+			if tc.shouldBeLogged {
+				assert.Len(t, baseDriver.auditInserts(), 1)
+			} else {
+				assert.Empty(t, baseDriver.auditInserts())
+			}
+		})
+	}
+}
+
+// TestAuditDriver_ContextTableFilters tests table filters narrowed or broadened through the context
+func TestAuditDriver_ContextTableFilters(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	ctx = audriver.WithOperatorID(ctx, uuid.New().String())
+	ctx = audriver.WithExecutionID(ctx, uuid.New().String())
+
+	testCases := []struct {
+		name           string
+		filters        []audriver.TableFilter
+		ctx            func(ctx context.Context) context.Context
+		tableName      string
+		shouldBeLogged bool
+	}{
+		{
+			name:    "extra_filter_excludes_staging_table",
+			filters: nil,
+			ctx: func(ctx context.Context) context.Context {
+				return audriver.WithExtraTableFilter(ctx, audriver.NewExcludePrefixFilter("staging_"))
+			},
+			tableName:      "staging_users",
+			shouldBeLogged: false,
+		},
+		{
+			name:    "extra_filter_allows_others",
+			filters: nil,
+			ctx: func(ctx context.Context) context.Context {
+				return audriver.WithExtraTableFilter(ctx, audriver.NewExcludePrefixFilter("staging_"))
+			},
+			tableName:      "users",
+			shouldBeLogged: true,
+		},
+		{
+			name:    "extra_filter_keeps_driver_filters",
+			filters: []audriver.TableFilter{audriver.NewExcludePrefixFilter("temp_")},
+			ctx: func(ctx context.Context) context.Context {
+				return audriver.WithExtraTableFilter(ctx, audriver.NewExcludePrefixFilter("staging_"))
+			},
+			tableName:      "temp_users",
+			shouldBeLogged: false,
+		},
+		{
+			name:    "override_broadens_driver_filters",
+			filters: []audriver.TableFilter{audriver.NewExcludePrefixFilter("temp_")},
+			ctx: func(ctx context.Context) context.Context {
+				return audriver.WithTableFilterOverride(ctx)
+			},
+			tableName:      "temp_users",
+			shouldBeLogged: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// arrange
+			baseDriver := &fakeDriver{}
+			db := setUpFakeTestDB(t, baseDriver, audriver.WithTableFilters(tc.filters...))
+
+			// act
+			_, err := db.ExecContext(tc.ctx(ctx), fmt.Sprintf(`DELETE FROM "%s" WHERE "id" = $1`, tc.tableName), uuid.New().String())
+			require.NoError(t, err)
+
+			// assert
+			if tc.shouldBeLogged {
+				assert.Len(t, baseDriver.auditInserts(), 1)
+			} else {
+				assert.Empty(t, baseDriver.auditInserts())
+			}
+		})
+	}
+}
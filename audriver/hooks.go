@@ -0,0 +1,136 @@
+package audriver
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"time"
+)
+
+type hooksModificationsKey struct{}
+type hooksWriteKey struct{}
+
+// Hooks exposes the audit pipeline as hooks compatible with github.com/qustavo/sqlhooks,
+// for applications that already wrap their driver with sqlhooks:
+//
+//	sql.Register("postgres-audit", sqlhooks.Wrap(&pq.Driver{}, audriver.NewHooks(db)))
+//
+// Hooks have no access to the connection executing a statement, so audit records are written through db
+// right after each statement, outside of the application's transactions. A connection of db is taken for each
+// write while the statement's connection is still held, so db must not be the pool the statements are executed on,
+// or must allow more open connections than the application holds at once: otherwise writes wait for a connection,
+// until WithHooksConnTimeout elapses and they fail with ErrAuditTimeout. Options that need the connection,
+// such as WithSchemaResolution, WithQueryRewriters, WithQueryAnnotation, WithRowEstimateGuard, and WithEnablementRate,
+// have no effect; WithAuditEnabled is honored. Statements under WithIdempotencyKey are numbered as if executed
+// outside of transactions.
+type Hooks struct {
+	db          *sql.DB
+	builder     *databaseModificationBuilder
+	auditRole   string
+	logger      Logger
+	connTimeout time.Duration
+}
+
+// defaultHooksConnTimeout is how long hooks wait for a connection to write audit records with by default.
+const defaultHooksConnTimeout = 5 * time.Second
+
+// NewHooks creates hooks writing audit records to db with the given options.
+// db may itself be wrapped with the hooks; audit inserts are not audited.
+func NewHooks(db *sql.DB, options ...Option) *Hooks {
+	d := newAuditDriver(nil, options...)
+	connTimeout := d.hooksConnTimeout
+	if connTimeout == 0 {
+		connTimeout = defaultHooksConnTimeout
+	}
+	return &Hooks{
+		db:          db,
+		builder:     d.builder,
+		auditRole:   d.auditRole,
+		logger:      d.logger,
+		connTimeout: connTimeout,
+	}
+}
+
+// Before builds the database modifications of a statement before it is executed.
+// It returns an error, aborting the statement, if the modifications cannot be built.
+func (h *Hooks) Before(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
+	if ctx.Value(hooksWriteKey{}) != nil {
+		return ctx, nil
+	}
+	if enabled, ok := GetAuditEnabled(ctx); ok && !enabled {
+		h.builder.stats.skippedDisabled.Add(1)
+		return ctx, nil
+	}
+
+	named := make([]driver.NamedValue, len(args))
+	for i, arg := range args {
+		named[i] = driver.NamedValue{Ordinal: i + 1, Value: arg}
+	}
+
+	mods, err := h.builder.build(ctx, query, named)
+	if err != nil {
+		h.builder.handleError(ctx, err, ErrorStageBuild, nil)
+		return ctx, fmt.Errorf("failed to build database modification: %w", err)
+	}
+	if len(mods) == 0 {
+		return ctx, nil
+	}
+	assignAutocommitIDs(ctx, mods)
+
+	return context.WithValue(ctx, hooksModificationsKey{}, mods), nil
+}
+
+// After writes the database modifications built by Before once the statement has been executed.
+func (h *Hooks) After(ctx context.Context, _ string, _ ...interface{}) (context.Context, error) {
+	mods, ok := ctx.Value(hooksModificationsKey{}).([]DatabaseModification)
+	if !ok {
+		return ctx, nil
+	}
+
+	// each retry takes a connection from the pool, so that a broken connection is not reused
+	err := h.builder.retryWrite(ctx, func() error {
+		return h.write(ctx, mods)
+	})
+	if err != nil {
+		h.builder.handleError(ctx, err, ErrorStageFlush, mods)
+		return ctx, fmt.Errorf("failed to log database modification: %w", err)
+	}
+
+	h.builder.stats.written(len(mods))
+	h.builder.trackOperatorRates(ctx, mods)
+	h.builder.notifyLogger(ctx, h.logger, mods)
+
+	return ctx, nil
+}
+
+// write writes mods through a connection of the audit connection pool, waiting for one up to the connection timeout.
+func (h *Hooks) write(ctx context.Context, mods []DatabaseModification) error {
+	writeCtx := context.WithValue(ctx, hooksWriteKey{}, true)
+	conn, err := h.conn(writeCtx)
+	if err != nil {
+		return classifyAuditError(fmt.Errorf("failed to acquire audit connection: %w", err))
+	}
+	defer func(conn *sql.Conn) {
+		_ = conn.Close()
+	}(conn)
+
+	return conn.Raw(func(driverConn any) error {
+		dc, ok := driverConn.(driver.Conn)
+		if !ok {
+			return fmt.Errorf("%w: raw connection does not implement driver.Conn", ErrUnsupportedConn)
+		}
+		return writeModifications(writeCtx, dc, h.builder, h.builder.writeTable(), h.auditRole, nil, mods)
+	})
+}
+
+// conn takes a connection from the audit connection pool, waiting up to the connection timeout, which the write on it
+// is not limited by.
+func (h *Hooks) conn(ctx context.Context) (*sql.Conn, error) {
+	if h.connTimeout < 0 {
+		return h.db.Conn(ctx)
+	}
+	acquireCtx, cancel := context.WithTimeout(ctx, h.connTimeout)
+	defer cancel()
+	return h.db.Conn(acquireCtx)
+}
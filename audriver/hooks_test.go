@@ -0,0 +1,91 @@
+package audriver_test
+
+import (
+	"database/sql"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/brianvoe/gofakeit/v7"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mickamy/go-sql-audit-driver/audriver"
+)
+
+// TestHooks tests that hooks record modifications of statements executed between Before and After
+func TestHooks(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	ctx = audriver.WithOperatorID(ctx, uuid.New().String())
+	ctx = audriver.WithExecutionID(ctx, uuid.New().String())
+
+	// arrange
+	baseDriver := &fakeDriver{}
+	driverName := fmt.Sprintf("fake_hooks_%s_%d", t.Name(), gofakeit.Number(1000, 9999))
+	sql.Register(driverName, baseDriver)
+	db, err := sql.Open(driverName, driverName)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = db.Close()
+	})
+
+	hooks := audriver.NewHooks(db, audriver.WithActions(audriver.DatabaseModificationActionUpdate))
+
+	// act
+	updateCtx, err := hooks.Before(ctx, "UPDATE users SET name = $1", "John")
+	require.NoError(t, err)
+	_, err = hooks.After(updateCtx, "UPDATE users SET name = $1", "John")
+	require.NoError(t, err)
+
+	deleteCtx, err := hooks.Before(ctx, "DELETE FROM users")
+	require.NoError(t, err)
+	_, err = hooks.After(deleteCtx, "DELETE FROM users")
+	require.NoError(t, err)
+
+	// assert
+	inserts := baseDriver.auditInserts()
+	require.Len(t, inserts, 1)
+	assert.Equal(t, "UPDATE users SET name = 'John'", inserts[0].value("sql"))
+	assert.Equal(t, "users", inserts[0].value("table_name"))
+}
+
+// TestHooks_ConnTimeout tests that writes fail instead of waiting forever when the pool has no connection to spare
+func TestHooks_ConnTimeout(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	ctx = audriver.WithOperatorID(ctx, uuid.New().String())
+	ctx = audriver.WithExecutionID(ctx, uuid.New().String())
+
+	// arrange
+	baseDriver := &fakeDriver{}
+	driverName := fmt.Sprintf("fake_hooks_%s_%d", t.Name(), gofakeit.Number(1000, 9999))
+	sql.Register(driverName, baseDriver)
+	db, err := sql.Open(driverName, driverName)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = db.Close()
+	})
+	db.SetMaxOpenConns(1)
+	// the connection of the statement, held while After writes its records
+	conn, err := db.Conn(ctx)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = conn.Close()
+	})
+
+	hooks := audriver.NewHooks(db, audriver.WithHooksConnTimeout(10*time.Millisecond))
+
+	// act
+	deleteCtx, err := hooks.Before(ctx, "DELETE FROM users")
+	require.NoError(t, err)
+	_, err = hooks.After(deleteCtx, "DELETE FROM users")
+
+	// assert
+	assert.ErrorIs(t, err, audriver.ErrAuditWriteFailed)
+	assert.ErrorIs(t, err, audriver.ErrAuditTimeout)
+	assert.Empty(t, baseDriver.auditInserts())
+}
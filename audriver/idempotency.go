@@ -0,0 +1,90 @@
+package audriver
+
+import (
+	"context"
+	"strconv"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+type idempotencyKeyKey struct{}
+
+// idempotencyNamespace is the namespace of the name-based UUIDs derived for modifications with an idempotency key.
+var idempotencyNamespace = uuid.MustParse("ad6ca80d-0773-4d7e-97a5-a4131cbddde3")
+
+// WithIdempotencyKey returns a context tagging modifications executed with it with the application-supplied key,
+// e.g. the ID of the request or of the business operation, recorded in idempotency_key.
+//
+// Modifications with a key get IDs derived from the key and their SQL instead of generated ones, and are written with
+// ON CONFLICT (id) DO NOTHING, so that executing the same statements again under the same key, e.g. when an ORM
+// retries after a serialization failure or a commit whose outcome was unknown, does not record them twice.
+//
+// Identical statements executed several times under the key are numbered, so they are all kept: within a transaction
+// the numbering restarts with each transaction, and outside of transactions it continues across the statements
+// executed with the returned context. Call WithIdempotencyKey again for each retry of autocommit statements, so that
+// the retry derives the same IDs.
+func WithIdempotencyKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, idempotencyKeyKey{}, &idempotencyScope{key: key})
+}
+
+// GetIdempotencyKey returns the idempotency key of ctx, if any.
+func GetIdempotencyKey(ctx context.Context) (string, bool) {
+	scope, ok := ctx.Value(idempotencyKeyKey{}).(*idempotencyScope)
+	if !ok || scope.key == "" {
+		return "", false
+	}
+	return scope.key, true
+}
+
+// idempotencyScope is the idempotency key of a context, numbering the statements executed with it outside of
+// transactions.
+type idempotencyScope struct {
+	key string
+
+	mu  sync.Mutex
+	ids idempotentIDs
+}
+
+// assignAutocommitIDs derives the IDs of mods of a statement executed outside of a transaction, numbering them
+// within the idempotency scope of ctx.
+func assignAutocommitIDs(ctx context.Context, mods []DatabaseModification) {
+	scope, ok := ctx.Value(idempotencyKeyKey{}).(*idempotencyScope)
+	if !ok || !hasIdempotencyKey(mods) {
+		return
+	}
+
+	scope.mu.Lock()
+	defer scope.mu.Unlock()
+	if scope.ids == nil {
+		scope.ids = idempotentIDs{}
+	}
+	scope.ids.assign(mods)
+}
+
+// idempotentIDs counts modifications with identical SQL under the same idempotency key, e.g. within a transaction,
+// to derive their IDs, so that a retry executing the same statements derives the same IDs.
+type idempotentIDs map[string]int
+
+// assign derives the IDs of mods that have an idempotency key.
+func (ids idempotentIDs) assign(mods []DatabaseModification) {
+	for i := range mods {
+		if mods[i].IdempotencyKey == "" {
+			continue
+		}
+		name := mods[i].IdempotencyKey + "\x00" + mods[i].SQL
+		occurrence := ids[name]
+		ids[name] = occurrence + 1
+		mods[i].ID = uuid.NewSHA1(idempotencyNamespace, []byte(name+"\x00"+strconv.Itoa(occurrence))).String()
+	}
+}
+
+// hasIdempotencyKey reports whether any of mods has an idempotency key.
+func hasIdempotencyKey(mods []DatabaseModification) bool {
+	for i := range mods {
+		if mods[i].IdempotencyKey != "" {
+			return true
+		}
+	}
+	return false
+}
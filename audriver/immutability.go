@@ -0,0 +1,128 @@
+package audriver
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/mickamy/go-sql-audit-driver/internal/postgres"
+)
+
+// ImmutabilityConfig configures the protections installed by ProtectImmutability.
+type ImmutabilityConfig struct {
+	// AuditTable is the audit table to protect, optionally schema-qualified. It defaults to DefaultAuditTable.
+	AuditTable string
+
+	// Roles are the roles UPDATE, DELETE, and TRUNCATE on the audit table are revoked from, e.g. the application roles.
+	Roles []string
+
+	// CompactionRole is an existing role whose members may still DELETE audit records, so that Compact can be run
+	// by them, e.g. a NOLOGIN role granted to the compaction job only. It also needs the DELETE privilege.
+	// If empty, deletes are rejected for every role. UPDATE and TRUNCATE are always rejected.
+	CompactionRole string
+}
+
+// VerifyImmutability checks that the current role of db cannot UPDATE, DELETE, or TRUNCATE DefaultAuditTable.
+// It returns an error wrapping ErrAuditTableMutable listing the privileges held by the role.
+// Use VerifyTableImmutability for tables configured with WithAuditTable.
+func VerifyImmutability(ctx context.Context, db *sql.DB) error {
+	return VerifyTableImmutability(ctx, db, DefaultAuditTable)
+}
+
+// VerifyTableImmutability is like VerifyImmutability, but checks table, optionally schema-qualified.
+// table defaults to DefaultAuditTable if empty.
+func VerifyTableImmutability(ctx context.Context, db *sql.DB, table string) error {
+	if table == "" {
+		table = DefaultAuditTable
+	}
+
+	var (
+		role        string
+		canUpdate   bool
+		canDelete   bool
+		canTruncate bool
+	)
+	err := db.QueryRowContext(ctx, `SELECT
+    current_user,
+    has_table_privilege(current_user, $1, 'UPDATE'),
+    has_table_privilege(current_user, $1, 'DELETE'),
+    has_table_privilege(current_user, $1, 'TRUNCATE')`, quoteQualifiedIdentifier(table),
+	).Scan(&role, &canUpdate, &canDelete, &canTruncate)
+	if err != nil {
+		return fmt.Errorf("failed to check privileges on %s: %w", table, err)
+	}
+
+	var privileges []string
+	if canUpdate {
+		privileges = append(privileges, "UPDATE")
+	}
+	if canDelete {
+		privileges = append(privileges, "DELETE")
+	}
+	if canTruncate {
+		privileges = append(privileges, "TRUNCATE")
+	}
+	if len(privileges) > 0 {
+		return fmt.Errorf("%w: role %s has %s on %s", ErrAuditTableMutable, role, strings.Join(privileges, ", "), table)
+	}
+
+	return nil
+}
+
+// ProtectImmutability revokes UPDATE, DELETE, and TRUNCATE on the audit table from the roles of cfg and installs
+// triggers rejecting those operations for every role, including the table owner, except for deletes by members of
+// the compaction role. It must be run by a role allowed to alter the audit table.
+// The statements are executed in a single transaction, so nothing is changed if any of them fails.
+func ProtectImmutability(ctx context.Context, db *sql.DB, cfg ImmutabilityConfig) error {
+	if cfg.AuditTable == "" {
+		cfg.AuditTable = DefaultAuditTable
+	}
+	table := quoteQualifiedIdentifier(cfg.AuditTable)
+	compactionRole := ""
+	if cfg.CompactionRole != "" {
+		compactionRole = postgres.QuoteLiteral(cfg.CompactionRole)
+	}
+
+	statements := make([]string, 0, len(cfg.Roles)+5)
+	for _, role := range cfg.Roles {
+		statements = append(statements, fmt.Sprintf(
+			"REVOKE UPDATE, DELETE, TRUNCATE ON %s FROM %s", table, postgres.QuoteIdentifier(role),
+		))
+	}
+	statements = append(statements,
+		// the compaction role, if any, is the argument of the row trigger
+		`CREATE OR REPLACE FUNCTION audriver_reject_modification() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP = 'DELETE' AND TG_NARGS > 0 AND pg_catalog.pg_has_role(current_user, TG_ARGV[0], 'MEMBER') THEN
+        RETURN OLD;
+    END IF;
+    RAISE EXCEPTION '% is append-only: % is not allowed', TG_TABLE_NAME, TG_OP;
+END;
+$$ LANGUAGE plpgsql`,
+		`DROP TRIGGER IF EXISTS audriver_reject_modification ON `+table,
+		`CREATE TRIGGER audriver_reject_modification
+    BEFORE UPDATE OR DELETE ON `+table+`
+    FOR EACH ROW EXECUTE FUNCTION audriver_reject_modification(`+compactionRole+`)`,
+		`DROP TRIGGER IF EXISTS audriver_reject_truncate ON `+table,
+		`CREATE TRIGGER audriver_reject_truncate
+    BEFORE TRUNCATE ON `+table+`
+    FOR EACH STATEMENT EXECUTE FUNCTION audriver_reject_modification()`,
+	)
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	for _, statement := range statements {
+		if _, err := tx.ExecContext(ctx, statement); err != nil {
+			_ = tx.Rollback()
+			return fmt.Errorf("failed to protect %s: %w", cfg.AuditTable, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit protection of %s: %w", cfg.AuditTable, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,147 @@
+package audriver_test
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mickamy/go-sql-audit-driver/audriver"
+)
+
+// TestVerifyImmutability tests privilege verification on the audit table
+func TestVerifyImmutability(t *testing.T) {
+	t.Parallel()
+
+	t.Run("reader_cannot_modify", func(t *testing.T) {
+		t.Parallel()
+
+		// arrange
+		db := setUpReaderTestDB(t)
+
+		// act
+		err := audriver.VerifyImmutability(t.Context(), db)
+
+		// assert
+		assert.NoError(t, err)
+	})
+
+	t.Run("writer_can_modify", func(t *testing.T) {
+		t.Parallel()
+
+		// arrange
+		db := setUpWriterTestDB(t)
+
+		// act
+		err := audriver.VerifyImmutability(t.Context(), db)
+
+		// assert
+		require.Error(t, err)
+		assert.ErrorIs(t, err, audriver.ErrAuditTableMutable)
+		assert.Contains(t, err.Error(), "UPDATE")
+		assert.Contains(t, err.Error(), "DELETE")
+	})
+
+	t.Run("custom_table", func(t *testing.T) {
+		t.Parallel()
+
+		// arrange
+		db := setUpReaderTestDB(t)
+
+		// act
+		err := audriver.VerifyTableImmutability(t.Context(), db, "public.legacy_database_modifications")
+
+		// assert
+		assert.NoError(t, err)
+	})
+}
+
+// TestProtectImmutability tests that protected audit records can only be deleted by the compaction role
+func TestProtectImmutability(t *testing.T) {
+	t.Parallel()
+
+	// arrange
+	ctx := t.Context()
+	db, err := sql.Open("txdb_admin", uuid.New().String())
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = db.Close()
+	})
+	db.SetMaxOpenConns(1)
+	exec := func(query string, args ...any) error {
+		_, err := db.ExecContext(ctx, query, args...)
+		return err
+	}
+
+	table := "immutability_" + uuid.New().String()[:8]
+	for _, statement := range []string{
+		"CREATE ROLE audriver_test_compactor NOLOGIN",
+		"CREATE ROLE audriver_test_other NOLOGIN",
+		"GRANT SELECT, INSERT, UPDATE, DELETE ON database_modifications TO audriver_test_compactor, audriver_test_other",
+	} {
+		require.NoError(t, exec(statement))
+	}
+	require.NoError(t, exec(`INSERT INTO database_modifications (id, operator_id, execution_id, table_name, action, sql, modified_at)
+		VALUES ($1, $2, $3, $4, 'insert', 'INSERT ...', $5)`,
+		uuid.New().String(), uuid.New().String(), uuid.New().String(), table, time.Now().AddDate(0, 0, -60)))
+	require.NoError(t, audriver.ProtectImmutability(ctx, db, audriver.ImmutabilityConfig{
+		Roles:          []string{"audriver_writer"},
+		CompactionRole: "audriver_test_compactor",
+	}))
+
+	// attempt runs statement as role, rolling back its effects if it fails
+	attempt := func(role, statement string) error {
+		require.NoError(t, exec("SAVEPOINT attempt"))
+		require.NoError(t, exec("SET LOCAL ROLE "+role))
+		err := exec(statement, table)
+		if err != nil {
+			require.NoError(t, exec("ROLLBACK TO SAVEPOINT attempt"))
+		}
+		require.NoError(t, exec("RESET ROLE"))
+		return err
+	}
+
+	// act
+	updateErr := attempt("audriver_test_compactor", "UPDATE database_modifications SET sql = 'tampered' WHERE table_name = $1")
+	deleteErr := attempt("audriver_test_other", "DELETE FROM database_modifications WHERE table_name = $1")
+	writerErr := attempt("audriver_writer", "DELETE FROM database_modifications WHERE table_name = $1")
+	require.NoError(t, exec("SET LOCAL ROLE audriver_test_compactor"))
+	result, compactErr := audriver.Compact(ctx, db, audriver.CompactionConfig{OlderThan: 30 * 24 * time.Hour, Tables: []string{table}})
+	require.NoError(t, exec("RESET ROLE"))
+
+	// assert
+	assert.ErrorContains(t, updateErr, "append-only")
+	assert.ErrorContains(t, deleteErr, "append-only")
+	assert.ErrorContains(t, writerErr, "permission denied")
+	require.NoError(t, compactErr)
+	assert.Equal(t, audriver.CompactionResult{Compacted: 1, Aggregates: 1}, result)
+}
+
+// TestProtectImmutability_Rollback tests that nothing is installed if protecting the audit table fails
+func TestProtectImmutability_Rollback(t *testing.T) {
+	t.Parallel()
+
+	// arrange
+	ctx := t.Context()
+	db, err := sql.Open("txdb_admin", uuid.New().String())
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = db.Close()
+	})
+	db.SetMaxOpenConns(1)
+
+	// act
+	protectErr := audriver.ProtectImmutability(ctx, db, audriver.ImmutabilityConfig{
+		AuditTable: "missing_" + uuid.New().String()[:8],
+	})
+	var installed bool
+	scanErr := db.QueryRowContext(ctx, "SELECT to_regproc('audriver_reject_modification') IS NOT NULL").Scan(&installed)
+
+	// assert
+	require.Error(t, protectErr)
+	require.NoError(t, scanErr)
+	assert.False(t, installed)
+}
@@ -0,0 +1,422 @@
+// Package incident raises incidents, e.g. in PagerDuty or Opsgenie, when the audit pipeline itself degrades, so that
+// audit outages are treated like outages of the application rather than noticed in the next compliance review.
+//
+// A Monitor watches the signals the driver reports through its error handler, logger, and load shedding callback,
+// and triggers an incident per degraded condition, resolving it once the pipeline recovers:
+//
+//	monitor := incident.New(incident.NewPagerDuty(os.Getenv("PAGERDUTY_ROUTING_KEY")), incident.WithSource("orders-api"))
+//	defer monitor.Close()
+//	auditDriver := audriver.New(baseDriver,
+//		audriver.WithErrorHandler(monitor.ErrorHandler(nil)),
+//		audriver.WithLogger(monitor.Logger(logger)),
+//		audriver.WithLoadShedding(audriver.LoadShedding{ /* ... */ OnChange: monitor.LoadSheddingHandler(nil)}),
+//	)
+package incident
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mickamy/go-sql-audit-driver/audriver"
+)
+
+const (
+	// DefaultSource is the default source of incidents, identifying the application in the incident service.
+	DefaultSource = "audriver"
+
+	// DefaultFailureThreshold is the default number of failed audit writes within the failure window that triggers
+	// an incident.
+	DefaultFailureThreshold = 5
+
+	// DefaultFailureWindow is the default period failed audit writes are counted in.
+	DefaultFailureWindow = time.Minute
+
+	// DefaultBufferSize is the default number of events buffered before further ones are dropped.
+	DefaultBufferSize = 64
+)
+
+// Condition is a degraded condition of the audit pipeline.
+type Condition string
+
+const (
+	// ConditionWriteFailures is audit records repeatedly failing to be written, e.g. because the audit database is
+	// unavailable or the audit table is locked.
+	ConditionWriteFailures Condition = "write-failures"
+
+	// ConditionSchemaDrift is audit records failing to be written because the audit table does not match the driver,
+	// e.g. when a migration adding a column has not been applied or the table was dropped.
+	ConditionSchemaDrift Condition = "schema-drift"
+
+	// ConditionLoadShedding is the driver shedding load because audit writes stay slow, so that only a sample of the
+	// modifications of hot tables is recorded. See audriver.WithLoadShedding.
+	ConditionLoadShedding Condition = "load-shedding"
+)
+
+// Severity is the severity of an incident, as understood by PagerDuty.
+type Severity string
+
+const (
+	SeverityCritical Severity = "critical"
+	SeverityError    Severity = "error"
+	SeverityWarning  Severity = "warning"
+	SeverityInfo     Severity = "info"
+)
+
+// Action is what an event does to its incident.
+type Action string
+
+const (
+	// ActionTrigger opens the incident, or adds to it if it is open.
+	ActionTrigger Action = "trigger"
+
+	// ActionResolve resolves the incident.
+	ActionResolve Action = "resolve"
+)
+
+// Event triggers or resolves an incident of a condition.
+type Event struct {
+	Action    Action
+	Condition Condition
+
+	// Key identifies the incident of the condition, so that its events are deduplicated into one incident.
+	Key string
+
+	// Source is the application whose audit pipeline degraded.
+	Source string
+
+	// Summary describes the condition in a line.
+	Summary string
+
+	// Severity is the severity of the incident. It is empty when resolving.
+	Severity Severity
+
+	// Details are further facts about the condition, e.g. the latest error.
+	Details map[string]string
+
+	// Time is when the condition started or ended.
+	Time time.Time
+}
+
+// Notifier sends events to an incident service.
+type Notifier interface {
+	Notify(ctx context.Context, event Event) error
+}
+
+// NotifierFunc is a function that implements Notifier.
+type NotifierFunc func(ctx context.Context, event Event) error
+
+// Notify calls f(ctx, event).
+func (f NotifierFunc) Notify(ctx context.Context, event Event) error {
+	return f(ctx, event)
+}
+
+// Option configures a Monitor.
+type Option func(*Monitor)
+
+// WithSource sets the source of incidents, e.g. the name of the application. It defaults to DefaultSource, and is
+// part of the keys of incidents, so that the incidents of applications are kept apart.
+func WithSource(source string) Option {
+	return func(m *Monitor) {
+		m.source = source
+	}
+}
+
+// WithFailureThreshold triggers a ConditionWriteFailures incident once threshold audit writes fail within window.
+// It defaults to DefaultFailureThreshold within DefaultFailureWindow.
+func WithFailureThreshold(threshold int, window time.Duration) Option {
+	return func(m *Monitor) {
+		m.threshold, m.window = threshold, window
+	}
+}
+
+// WithBufferSize sets the number of events buffered for the background notifier. Events raised while the buffer is
+// full are dropped and counted by Dropped. It defaults to DefaultBufferSize.
+func WithBufferSize(size int) Option {
+	return func(m *Monitor) {
+		m.bufferSize = size
+	}
+}
+
+// WithErrorHandler sets a function called with errors sending events, e.g. to log them.
+// Events failing to be sent are not retried.
+func WithErrorHandler(handler func(error)) Option {
+	return func(m *Monitor) {
+		m.errorHandler = handler
+	}
+}
+
+// Monitor triggers and resolves incidents of the audit pipeline. Events are sent in the background, so that
+// failing audit writes never wait on the incident service.
+type Monitor struct {
+	notifier     Notifier
+	source       string
+	threshold    int
+	window       time.Duration
+	bufferSize   int
+	errorHandler func(error)
+
+	mu sync.Mutex
+	// failures are the times of the failed audit writes within the failure window
+	failures  []time.Time
+	lastError error
+	open      map[Condition]bool
+	// degraded is whether a condition resolved by successful writes is open, so that logging skips the lock otherwise
+	degraded atomic.Bool
+
+	events    chan Event
+	done      chan struct{}
+	stopped   chan struct{}
+	closeOnce sync.Once
+	dropped   atomic.Int64
+}
+
+// New returns a monitor sending the events of incidents to notifier. Close the monitor to send pending events.
+func New(notifier Notifier, opts ...Option) *Monitor {
+	m := &Monitor{
+		notifier:   notifier,
+		source:     DefaultSource,
+		threshold:  DefaultFailureThreshold,
+		window:     DefaultFailureWindow,
+		bufferSize: DefaultBufferSize,
+		open:       map[Condition]bool{},
+		done:       make(chan struct{}),
+		stopped:    make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	m.threshold = max(m.threshold, 1)
+	m.events = make(chan Event, m.bufferSize)
+	go m.run()
+	return m
+}
+
+// ErrorHandler returns an error handler for audriver.WithErrorHandler observing failed audit writes, which calls next,
+// if not nil, as well. Failures of building modifications and executing statements are errors of the application
+// rather than of the audit pipeline, and are only passed on, as are panics of loggers.
+func (m *Monitor) ErrorHandler(next audriver.ErrorHandler) audriver.ErrorHandler {
+	return func(ctx context.Context, err error, stage audriver.ErrorStage, mod *audriver.DatabaseModification) {
+		if stage == audriver.ErrorStageFlush && !errors.Is(err, audriver.ErrPanicRecovered) {
+			m.failed(err, time.Now())
+		}
+		if next != nil {
+			next(ctx, err, stage, mod)
+		}
+	}
+}
+
+// Logger returns a logger for audriver.WithLogger observing successfully written audit records, which resolve the
+// incidents of failing writes, and passes the records on to next, if not nil.
+func (m *Monitor) Logger(next audriver.Logger) audriver.Logger {
+	return logger{monitor: m, next: next}
+}
+
+// LoadSheddingHandler returns a callback for LoadShedding.OnChange triggering a ConditionLoadShedding incident while
+// load is shed, which calls next, if not nil, as well.
+func (m *Monitor) LoadSheddingHandler(next func(window audriver.LoadSheddingWindow)) func(window audriver.LoadSheddingWindow) {
+	return func(window audriver.LoadSheddingWindow) {
+		m.loadShedding(window)
+		if next != nil {
+			next(window)
+		}
+	}
+}
+
+// Dropped returns the number of events dropped because the buffer was full.
+func (m *Monitor) Dropped() int64 {
+	return m.dropped.Load()
+}
+
+// Close sends the pending events and stops the monitor. Events raised afterward are dropped.
+func (m *Monitor) Close() error {
+	m.closeOnce.Do(func() {
+		close(m.done)
+	})
+	<-m.stopped
+	return nil
+}
+
+// logger is the audriver.Logger of a Monitor.
+type logger struct {
+	monitor *Monitor
+	next    audriver.Logger
+}
+
+func (l logger) Log(ctx context.Context, mod audriver.DatabaseModification) {
+	l.monitor.written(time.Now())
+	if l.next != nil {
+		l.next.Log(ctx, mod)
+	}
+}
+
+// failed records an audit write failing with err at now.
+func (m *Monitor) failed(err error, now time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// the error handler is called once per modification of a failed write, with the same error
+	if sameError(err, m.lastError) && len(m.failures) > 0 && now.Sub(m.failures[len(m.failures)-1]) < time.Second {
+		return
+	}
+	m.lastError = err
+
+	if SchemaDrift(err) {
+		m.trigger(ConditionSchemaDrift, SeverityCritical, "audit table does not match the audit driver", err, now)
+		return
+	}
+
+	m.failures = slices.DeleteFunc(m.failures, func(t time.Time) bool { return now.Sub(t) >= m.window })
+	m.failures = append(m.failures, now)
+	if len(m.failures) >= m.threshold {
+		summary := fmt.Sprintf("%d audit writes failed within %s", len(m.failures), m.window)
+		m.trigger(ConditionWriteFailures, SeverityCritical, summary, err, now)
+	}
+}
+
+// written records an audit write succeeding at now, resolving the incidents of failing writes.
+func (m *Monitor) written(now time.Time) {
+	if !m.degraded.Load() {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.failures = m.failures[:0]
+	m.lastError = nil
+	m.resolve(ConditionWriteFailures, now)
+	m.resolve(ConditionSchemaDrift, now)
+	m.degraded.Store(false)
+}
+
+// loadShedding records the start or end of a load shedding window.
+func (m *Monitor) loadShedding(window audriver.LoadSheddingWindow) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !window.End.IsZero() {
+		m.resolve(ConditionLoadShedding, window.End)
+		return
+	}
+	summary := fmt.Sprintf("audit load shedding started, recording %g%% of the modifications of %s",
+		window.SampleRate*100, strings.Join(window.Tables, ", "))
+	m.trigger(ConditionLoadShedding, SeverityWarning, summary, nil, window.Start)
+}
+
+// trigger opens the incident of condition if it is not open. m.mu must be held.
+func (m *Monitor) trigger(condition Condition, severity Severity, summary string, err error, now time.Time) {
+	if m.open[condition] {
+		return
+	}
+	m.open[condition] = true
+	if condition != ConditionLoadShedding {
+		m.degraded.Store(true)
+	}
+
+	event := m.event(ActionTrigger, condition, now)
+	event.Summary = "[" + m.source + "] " + summary
+	event.Severity = severity
+	if err != nil {
+		event.Details = map[string]string{"error": err.Error()}
+	}
+	m.send(event)
+}
+
+// resolve resolves the incident of condition if it is open. m.mu must be held.
+func (m *Monitor) resolve(condition Condition, now time.Time) {
+	if !m.open[condition] {
+		return
+	}
+	delete(m.open, condition)
+
+	event := m.event(ActionResolve, condition, now)
+	event.Summary = "[" + m.source + "] " + string(condition) + " recovered"
+	m.send(event)
+}
+
+func (m *Monitor) event(action Action, condition Condition, now time.Time) Event {
+	return Event{
+		Action:    action,
+		Condition: condition,
+		Key:       m.source + "/" + string(condition),
+		Source:    m.source,
+		Time:      now,
+	}
+}
+
+// send queues event for the background notifier. It never blocks.
+func (m *Monitor) send(event Event) {
+	select {
+	case <-m.done:
+		m.dropped.Add(1)
+	case m.events <- event:
+	default:
+		m.dropped.Add(1)
+	}
+}
+
+// run sends the queued events in order.
+func (m *Monitor) run() {
+	defer close(m.stopped)
+
+	notify := func(event Event) {
+		if err := m.notifier.Notify(context.Background(), event); err != nil && m.errorHandler != nil {
+			m.errorHandler(err)
+		}
+	}
+	for {
+		select {
+		case event := <-m.events:
+			notify(event)
+		case <-m.done:
+			for len(m.events) > 0 {
+				notify(<-m.events)
+			}
+			return
+		}
+	}
+}
+
+// sameError reports whether a and b are the same error value, without panicking on incomparable errors.
+func sameError(a, b error) bool {
+	if a == nil || b == nil || reflect.TypeOf(a) != reflect.TypeOf(b) || !reflect.TypeOf(a).Comparable() {
+		return false
+	}
+	return a == b
+}
+
+// driftStates are the SQLSTATE codes of PostgreSQL of audit writes failing on a mismatched audit table.
+var driftStates = []string{
+	"42703", // undefined_column
+	"42P01", // undefined_table
+	"3F000", // invalid_schema_name
+	"42804", // datatype_mismatch
+	"22P02", // invalid_text_representation, e.g. of an enum value the table does not know
+}
+
+// SchemaDrift reports whether err is an audit write failing because the audit table does not match the driver, e.g.
+// lacking a column. SQLSTATE codes are read from errors implementing SQLState() string, as those of lib/pq and pgx do.
+func SchemaDrift(err error) bool {
+	var stateErr interface{ SQLState() string }
+	return errors.As(err, &stateErr) && slices.Contains(driftStates, stateErr.SQLState())
+}
+
+// priority maps severities to the priorities of Opsgenie.
+func priority(severity Severity) string {
+	switch severity {
+	case SeverityCritical:
+		return "P1"
+	case SeverityError:
+		return "P2"
+	case SeverityWarning:
+		return "P3"
+	}
+	return "P5"
+}
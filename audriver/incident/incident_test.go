@@ -0,0 +1,280 @@
+package incident_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mickamy/go-sql-audit-driver/audriver"
+	"github.com/mickamy/go-sql-audit-driver/audriver/incident"
+)
+
+// stateError is an error of a SQLSTATE code, like those of lib/pq and pgx.
+type stateError string
+
+func (e stateError) Error() string    { return "pq: SQLSTATE " + string(e) }
+func (e stateError) SQLState() string { return string(e) }
+
+// recorder is a notifier recording the events sent to it.
+type recorder struct {
+	mu     sync.Mutex
+	events []incident.Event
+}
+
+func (r *recorder) Notify(_ context.Context, event incident.Event) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, event)
+	return nil
+}
+
+func TestMonitor(t *testing.T) {
+	t.Parallel()
+
+	mods := []audriver.DatabaseModification{{TableName: "users"}, {TableName: "orders"}}
+	flushFailed := func(handler audriver.ErrorHandler, err error) {
+		// the driver calls the handler once per modification of a failed write
+		for i := range mods {
+			handler(t.Context(), err, audriver.ErrorStageFlush, &mods[i])
+		}
+	}
+
+	t.Run("write failures", func(t *testing.T) {
+		t.Parallel()
+
+		// arrange
+		notifier := &recorder{}
+		monitor := incident.New(notifier, incident.WithSource("orders-api"), incident.WithFailureThreshold(3, time.Minute))
+		var passed int
+		handler := monitor.ErrorHandler(func(context.Context, error, audriver.ErrorStage, *audriver.DatabaseModification) {
+			passed++
+		})
+		logger := monitor.Logger(nil)
+
+		// act
+		handler(t.Context(), audriver.ErrMissingOperatorID, audriver.ErrorStageBuild, nil)
+		for range 3 {
+			flushFailed(handler, errors.Join(audriver.ErrAuditWriteFailed, audriver.ErrAuditUnavailable))
+		}
+		flushFailed(handler, audriver.ErrAuditWriteFailed)
+		logger.Log(t.Context(), mods[0])
+		logger.Log(t.Context(), mods[1])
+		require.NoError(t, monitor.Close())
+
+		// assert
+		assert.Equal(t, 9, passed)
+		require.Len(t, notifier.events, 2)
+		trigger, resolve := notifier.events[0], notifier.events[1]
+		assert.Equal(t, incident.ActionTrigger, trigger.Action)
+		assert.Equal(t, incident.ConditionWriteFailures, trigger.Condition)
+		assert.Equal(t, "orders-api/write-failures", trigger.Key)
+		assert.Equal(t, incident.SeverityCritical, trigger.Severity)
+		assert.Equal(t, "[orders-api] 3 audit writes failed within 1m0s", trigger.Summary)
+		assert.Contains(t, trigger.Details["error"], "audit database unavailable")
+		assert.Equal(t, incident.ActionResolve, resolve.Action)
+		assert.Equal(t, trigger.Key, resolve.Key)
+	})
+
+	t.Run("below threshold", func(t *testing.T) {
+		t.Parallel()
+
+		// arrange
+		notifier := &recorder{}
+		monitor := incident.New(notifier)
+		handler := monitor.ErrorHandler(nil)
+
+		// act
+		for range incident.DefaultFailureThreshold - 1 {
+			flushFailed(handler, errors.New("connection refused"))
+		}
+		require.NoError(t, monitor.Close())
+
+		// assert
+		assert.Empty(t, notifier.events)
+	})
+
+	t.Run("schema drift", func(t *testing.T) {
+		t.Parallel()
+
+		// arrange
+		notifier := &recorder{}
+		monitor := incident.New(notifier)
+		handler := monitor.ErrorHandler(nil)
+
+		// act
+		flushFailed(handler, errors.Join(audriver.ErrAuditWriteFailed, stateError("42703")))
+		flushFailed(handler, errors.Join(audriver.ErrAuditWriteFailed, stateError("42703")))
+		monitor.Logger(nil).Log(t.Context(), mods[0])
+		require.NoError(t, monitor.Close())
+
+		// assert
+		require.Len(t, notifier.events, 2)
+		assert.Equal(t, incident.ConditionSchemaDrift, notifier.events[0].Condition)
+		assert.Equal(t, incident.ActionTrigger, notifier.events[0].Action)
+		assert.Equal(t, "audriver/schema-drift", notifier.events[0].Key)
+		assert.Equal(t, incident.ActionResolve, notifier.events[1].Action)
+	})
+
+	t.Run("load shedding", func(t *testing.T) {
+		t.Parallel()
+
+		// arrange
+		notifier := &recorder{}
+		monitor := incident.New(notifier)
+		var windows []audriver.LoadSheddingWindow
+		onChange := monitor.LoadSheddingHandler(func(window audriver.LoadSheddingWindow) {
+			windows = append(windows, window)
+		})
+		start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+		window := audriver.LoadSheddingWindow{Start: start, Tables: []string{"events"}, SampleRate: 0.1}
+
+		// act
+		onChange(window)
+		window.End = start.Add(time.Minute)
+		onChange(window)
+		require.NoError(t, monitor.Close())
+
+		// assert
+		assert.Len(t, windows, 2)
+		require.Len(t, notifier.events, 2)
+		assert.Equal(t, incident.SeverityWarning, notifier.events[0].Severity)
+		assert.Equal(t, "[audriver] audit load shedding started, recording 10% of the modifications of events", notifier.events[0].Summary)
+		assert.Equal(t, start, notifier.events[0].Time)
+		assert.Equal(t, incident.ActionResolve, notifier.events[1].Action)
+		assert.Equal(t, window.End, notifier.events[1].Time)
+	})
+}
+
+func TestSchemaDrift(t *testing.T) {
+	t.Parallel()
+
+	tcs := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "undefined column", err: stateError("42703"), want: true},
+		{name: "undefined table", err: errors.Join(audriver.ErrAuditWriteFailed, stateError("42P01")), want: true},
+		{name: "lock timeout", err: stateError("55P03"), want: false},
+		{name: "no SQLSTATE", err: errors.New("connection refused"), want: false},
+	}
+	for _, tc := range tcs {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// act
+			got := incident.SchemaDrift(tc.err)
+
+			// assert
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+// request is a request received by a test server.
+type request struct {
+	path string
+	auth string
+	body map[string]any
+}
+
+// server returns a test server recording the requests it receives.
+func server(t *testing.T) (*httptest.Server, func() []request) {
+	t.Helper()
+
+	var (
+		mu       sync.Mutex
+		requests []request
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		mu.Lock()
+		requests = append(requests, request{path: r.URL.RequestURI(), auth: r.Header.Get("Authorization"), body: body})
+		mu.Unlock()
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, func() []request {
+		mu.Lock()
+		defer mu.Unlock()
+		return requests
+	}
+}
+
+func TestPagerDuty(t *testing.T) {
+	t.Parallel()
+
+	// arrange
+	srv, requests := server(t)
+	pagerDuty := &incident.PagerDuty{RoutingKey: "routing-key", URL: srv.URL}
+	trigger := incident.Event{
+		Action: incident.ActionTrigger, Condition: incident.ConditionWriteFailures, Key: "api/write-failures",
+		Source: "api", Summary: "[api] 5 audit writes failed within 1m0s", Severity: incident.SeverityCritical,
+		Details: map[string]string{"error": "connection refused"}, Time: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+
+	// act
+	require.NoError(t, pagerDuty.Notify(t.Context(), trigger))
+	require.NoError(t, pagerDuty.Notify(t.Context(), incident.Event{Action: incident.ActionResolve, Key: trigger.Key}))
+
+	// assert
+	got := requests()
+	require.Len(t, got, 2)
+	assert.Equal(t, map[string]any{
+		"routing_key":  "routing-key",
+		"event_action": "trigger",
+		"dedup_key":    "api/write-failures",
+		"payload": map[string]any{
+			"summary":        "[api] 5 audit writes failed within 1m0s",
+			"source":         "api",
+			"severity":       "critical",
+			"timestamp":      "2025-01-01T00:00:00Z",
+			"component":      "audriver",
+			"class":          "write-failures",
+			"custom_details": map[string]any{"error": "connection refused"},
+		},
+	}, got[0].body)
+	assert.Equal(t, map[string]any{
+		"routing_key":  "routing-key",
+		"event_action": "resolve",
+		"dedup_key":    "api/write-failures",
+	}, got[1].body)
+}
+
+func TestOpsgenie(t *testing.T) {
+	t.Parallel()
+
+	// arrange
+	srv, requests := server(t)
+	opsgenie := &incident.Opsgenie{APIKey: "api-key", URL: srv.URL}
+	trigger := incident.Event{
+		Action: incident.ActionTrigger, Condition: incident.ConditionLoadShedding, Key: "api/load-shedding",
+		Source: "api", Summary: "[api] audit load shedding started", Severity: incident.SeverityWarning,
+		Time: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+
+	// act
+	require.NoError(t, opsgenie.Notify(t.Context(), trigger))
+	require.NoError(t, opsgenie.Notify(t.Context(), incident.Event{Action: incident.ActionResolve, Key: trigger.Key, Source: "api"}))
+
+	// assert
+	got := requests()
+	require.Len(t, got, 2)
+	assert.Equal(t, "/v2/alerts", got[0].path)
+	assert.Equal(t, "GenieKey api-key", got[0].auth)
+	assert.Equal(t, "api/load-shedding", got[0].body["alias"])
+	assert.Equal(t, "P3", got[0].body["priority"])
+	assert.Equal(t, map[string]any{"condition": "load-shedding", "since": "2025-01-01T00:00:00Z"}, got[0].body["details"])
+	assert.Equal(t, "/v2/alerts/api%2Fload-shedding/close?identifierType=alias", got[1].path)
+	assert.Equal(t, "api", got[1].body["source"])
+}
@@ -0,0 +1,143 @@
+package incident
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// DefaultPagerDutyURL is the URL of the PagerDuty Events API v2.
+const DefaultPagerDutyURL = "https://events.pagerduty.com/v2/enqueue"
+
+// DefaultOpsgenieURL is the base URL of the Opsgenie Alert API. EU accounts use https://api.eu.opsgenie.com.
+const DefaultOpsgenieURL = "https://api.opsgenie.com"
+
+// maxOpsgenieMessage is the number of characters of the message of an Opsgenie alert.
+const maxOpsgenieMessage = 130
+
+// PagerDuty sends events to a service of PagerDuty through the Events API v2, deduplicated by the key of the event.
+type PagerDuty struct {
+	// RoutingKey is the integration key of the Events API v2 integration of the service.
+	RoutingKey string
+
+	// URL is the URL of the Events API. It defaults to DefaultPagerDutyURL.
+	URL string
+
+	// Client is the HTTP client sending the events. It defaults to http.DefaultClient.
+	Client *http.Client
+}
+
+// NewPagerDuty returns a notifier sending events to the service integrated with routingKey.
+func NewPagerDuty(routingKey string) *PagerDuty {
+	return &PagerDuty{RoutingKey: routingKey}
+}
+
+// Notify triggers or resolves the PagerDuty incident of the key of event.
+func (p *PagerDuty) Notify(ctx context.Context, event Event) error {
+	payload := map[string]any{
+		"routing_key":  p.RoutingKey,
+		"event_action": string(event.Action),
+		"dedup_key":    event.Key,
+	}
+	if event.Action == ActionTrigger {
+		payload["payload"] = map[string]any{
+			"summary":        event.Summary,
+			"source":         event.Source,
+			"severity":       string(event.Severity),
+			"timestamp":      event.Time.UTC().Format(time.RFC3339),
+			"component":      "audriver",
+			"class":          string(event.Condition),
+			"custom_details": event.Details,
+		}
+	}
+	return post(ctx, p.Client, cmp.Or(p.URL, DefaultPagerDutyURL), nil, payload)
+}
+
+// Opsgenie sends events to Opsgenie through the Alert API, deduplicated by the key of the event as the alias of alerts.
+type Opsgenie struct {
+	// APIKey is the key of an API integration of the team receiving the alerts.
+	APIKey string
+
+	// URL is the base URL of the Alert API. It defaults to DefaultOpsgenieURL.
+	URL string
+
+	// Client is the HTTP client sending the events. It defaults to http.DefaultClient.
+	Client *http.Client
+}
+
+// NewOpsgenie returns a notifier sending events to the team integrated with apiKey.
+func NewOpsgenie(apiKey string) *Opsgenie {
+	return &Opsgenie{APIKey: apiKey}
+}
+
+// Notify creates or closes the Opsgenie alert aliased with the key of event. Severities map to priorities, from P1 for
+// SeverityCritical down to P5 for SeverityInfo.
+func (o *Opsgenie) Notify(ctx context.Context, event Event) error {
+	base := strings.TrimSuffix(cmp.Or(o.URL, DefaultOpsgenieURL), "/")
+	header := http.Header{"Authorization": {"GenieKey " + o.APIKey}}
+
+	if event.Action == ActionResolve {
+		endpoint := base + "/v2/alerts/" + url.PathEscape(event.Key) + "/close?identifierType=alias"
+		return post(ctx, o.Client, endpoint, header, map[string]any{"source": event.Source, "note": event.Summary})
+	}
+
+	details := maps.Clone(event.Details)
+	if details == nil {
+		details = map[string]string{}
+	}
+	details["condition"] = string(event.Condition)
+	details["since"] = event.Time.UTC().Format(time.RFC3339)
+	message := event.Summary
+	if r := []rune(message); len(r) > maxOpsgenieMessage {
+		message = string(r[:maxOpsgenieMessage-1]) + "…"
+	}
+	return post(ctx, o.Client, base+"/v2/alerts", header, map[string]any{
+		"message":     message,
+		"alias":       event.Key,
+		"description": event.Summary,
+		"priority":    priority(event.Severity),
+		"source":      event.Source,
+		"tags":        []string{"audriver", string(event.Condition)},
+		"details":     details,
+	})
+}
+
+// post posts payload as JSON to url with header.
+func post(ctx context.Context, client *http.Client, url string, header http.Header, payload any) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode event: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	maps.Copy(req.Header, header)
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+
+	if client == nil {
+		client = http.DefaultClient
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send event: %w", err)
+	}
+	defer func(body io.ReadCloser) {
+		_ = body.Close()
+	}(res.Body)
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(res.Body, 1<<16))
+		return errors.New("failed to send event: " + res.Status + ": " + strings.TrimSpace(string(body)))
+	}
+	return nil
+}
@@ -0,0 +1,245 @@
+package audriver
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// auditColumn describes a column of the audit table and how its value is taken from a DatabaseModification.
+type auditColumn struct {
+	name string
+
+	// definition is the type and constraints of the column in the DDL generated by GenerateSchemaSQL.
+	definition string
+
+	// optional columns are written only if at least one modification in the batch has a value,
+	// so that audit tables created before the column was introduced keep working.
+	optional bool
+
+	// value returns the value to insert, or nil if the modification has no value for an optional column,
+	// or an error if the value cannot be encoded. Arrays are returned as []string and formatted for the dialect
+	// by buildInsert.
+	value func(mod DatabaseModification) (any, error)
+
+	// fallback is written instead of nil for modifications without a value when the column is written.
+	fallback any
+}
+
+var auditColumns = []auditColumn{
+	{name: "id", definition: "UUID NOT NULL PRIMARY KEY", value: func(mod DatabaseModification) (any, error) { return mod.ID, nil }},
+	{name: "operator_id", definition: "UUID NOT NULL", value: func(mod DatabaseModification) (any, error) { return mod.OperatorID, nil }},
+	{name: "execution_id", definition: "UUID NOT NULL", value: func(mod DatabaseModification) (any, error) { return mod.ExecutionID, nil }},
+	{name: "table_name", definition: "VARCHAR(63) NOT NULL", value: func(mod DatabaseModification) (any, error) { return mod.TableName, nil }},
+	{name: "action", definition: auditActionType + " NOT NULL", value: func(mod DatabaseModification) (any, error) { return mod.Action.String(), nil }},
+	{name: "sql", definition: "TEXT NOT NULL", value: func(mod DatabaseModification) (any, error) { return mod.SQL, nil }},
+	{name: "modified_at", definition: "TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP", value: func(mod DatabaseModification) (any, error) { return mod.ModifiedAt, nil }},
+	{name: "record_ids", definition: "TEXT[]", optional: true, value: func(mod DatabaseModification) (any, error) {
+		if len(mod.RecordIDs) == 0 {
+			return nil, nil
+		}
+		return mod.RecordIDs, nil
+	}},
+	{name: "schema_name", definition: "VARCHAR(63)", optional: true, value: func(mod DatabaseModification) (any, error) {
+		if mod.SchemaName == "" {
+			return nil, nil
+		}
+		return mod.SchemaName, nil
+	}},
+	{name: "foreign_table", definition: "BOOLEAN", optional: true, value: func(mod DatabaseModification) (any, error) {
+		if !mod.Foreign {
+			return nil, nil
+		}
+		return true, nil
+	}},
+	{name: "source_tables", definition: "TEXT[]", optional: true, value: func(mod DatabaseModification) (any, error) {
+		if len(mod.SourceTables) == 0 {
+			return nil, nil
+		}
+		return mod.SourceTables, nil
+	}},
+	{name: "changed_columns", definition: "TEXT[]", optional: true, value: func(mod DatabaseModification) (any, error) {
+		if len(mod.ChangedColumns) == 0 {
+			return nil, nil
+		}
+		return mod.ChangedColumns, nil
+	}},
+	{name: "operator_name", definition: "TEXT", optional: true, value: func(mod DatabaseModification) (any, error) {
+		if mod.OperatorName == "" {
+			return nil, nil
+		}
+		return mod.OperatorName, nil
+	}},
+	{name: "operator_email", definition: "TEXT", optional: true, value: func(mod DatabaseModification) (any, error) {
+		if mod.OperatorEmail == "" {
+			return nil, nil
+		}
+		return mod.OperatorEmail, nil
+	}},
+	{name: "shard", definition: "VARCHAR(63)", optional: true, value: func(mod DatabaseModification) (any, error) {
+		if mod.Shard == "" {
+			return nil, nil
+		}
+		return mod.Shard, nil
+	}},
+	{name: "shard_key", definition: "TEXT", optional: true, value: func(mod DatabaseModification) (any, error) {
+		if mod.ShardKey == "" {
+			return nil, nil
+		}
+		return mod.ShardKey, nil
+	}},
+	{name: "backend_pid", definition: "INTEGER", optional: true, value: func(mod DatabaseModification) (any, error) {
+		if mod.BackendPID == 0 {
+			return nil, nil
+		}
+		return mod.BackendPID, nil
+	}},
+	{name: "transaction_id", definition: "BIGINT", optional: true, value: func(mod DatabaseModification) (any, error) {
+		if mod.TransactionID == 0 {
+			return nil, nil
+		}
+		return mod.TransactionID, nil
+	}},
+	{name: "estimated_rows", definition: "BIGINT", optional: true, value: func(mod DatabaseModification) (any, error) {
+		if mod.EstimatedRows == 0 {
+			return nil, nil
+		}
+		return mod.EstimatedRows, nil
+	}},
+	{name: "idempotency_key", definition: "TEXT", optional: true, value: func(mod DatabaseModification) (any, error) {
+		if mod.IdempotencyKey == "" {
+			return nil, nil
+		}
+		return mod.IdempotencyKey, nil
+	}},
+	{name: "parent_execution_id", definition: "UUID", optional: true, value: func(mod DatabaseModification) (any, error) {
+		if mod.ParentExecutionID == "" {
+			return nil, nil
+		}
+		return mod.ParentExecutionID, nil
+	}},
+	{name: "step", definition: "TEXT", optional: true, value: func(mod DatabaseModification) (any, error) {
+		if mod.Step == "" {
+			return nil, nil
+		}
+		return mod.Step, nil
+	}},
+	{name: "client_ip", definition: "INET", optional: true, value: func(mod DatabaseModification) (any, error) {
+		if mod.ClientIP == "" {
+			return nil, nil
+		}
+		return mod.ClientIP, nil
+	}},
+	{name: "user_agent", definition: "TEXT", optional: true, value: func(mod DatabaseModification) (any, error) {
+		if mod.UserAgent == "" {
+			return nil, nil
+		}
+		return mod.UserAgent, nil
+	}},
+	{name: "device", definition: "TEXT", optional: true, value: func(mod DatabaseModification) (any, error) {
+		if mod.Device == "" {
+			return nil, nil
+		}
+		return mod.Device, nil
+	}},
+	{name: "checksum", definition: "CHAR(64)", optional: true, value: func(mod DatabaseModification) (any, error) {
+		if mod.Checksum == "" {
+			return nil, nil
+		}
+		return mod.Checksum, nil
+	}},
+	{name: "old_values", definition: "JSONB", optional: true, value: func(mod DatabaseModification) (any, error) {
+		if mod.OldValues == nil {
+			return nil, nil
+		}
+		return string(mod.OldValues), nil
+	}},
+	{name: "new_values", definition: "JSONB", optional: true, value: func(mod DatabaseModification) (any, error) {
+		if mod.NewValues == nil {
+			return nil, nil
+		}
+		return string(mod.NewValues), nil
+	}},
+	{name: "metadata", definition: "JSONB", optional: true, value: func(mod DatabaseModification) (any, error) {
+		if len(mod.Metadata) == 0 {
+			return nil, nil
+		}
+		data, err := json.Marshal(mod.Metadata)
+		if err != nil {
+			return nil, err
+		}
+		return string(data), nil
+	}},
+	{name: "exactness", definition: "VARCHAR(16) NOT NULL DEFAULT 'exact'", optional: true, fallback: string(ExactnessExact), value: func(mod DatabaseModification) (any, error) {
+		if mod.Exactness == "" || mod.Exactness == ExactnessExact {
+			return nil, nil
+		}
+		return string(mod.Exactness), nil
+	}},
+}
+
+// buildInsert builds a single INSERT statement of dialect writing all modifications into table.
+// It returns an error if a value of a modification cannot be encoded, e.g. metadata that is not JSON-encodable.
+func buildInsert(dialect Dialect, table string, modifications []DatabaseModification) (string, []driver.NamedValue, error) {
+	// values of every column of each modification, so that each is computed once
+	values := make([][]any, len(modifications))
+	written := make([]bool, len(auditColumns))
+	for i, mod := range modifications {
+		values[i] = make([]any, len(auditColumns))
+		for j, column := range auditColumns {
+			value, err := column.value(mod)
+			if err != nil {
+				return "", nil, fmt.Errorf("failed to encode %s of audit record: %w", column.name, err)
+			}
+			values[i][j] = value
+			written[j] = written[j] || !column.optional || value != nil
+		}
+	}
+	columns := make([]int, 0, len(auditColumns))
+	for j := range auditColumns {
+		if written[j] {
+			columns = append(columns, j)
+		}
+	}
+
+	var query strings.Builder
+	query.Grow(64 + len(table) + len(columns)*16 + len(modifications)*len(columns)*6)
+	query.WriteString("INSERT INTO ")
+	query.WriteString(table)
+	query.WriteString(" (")
+	for i, j := range columns {
+		if i > 0 {
+			query.WriteString(", ")
+		}
+		query.WriteString(dialect.quoteColumn(auditColumns[j].name))
+	}
+	query.WriteString(") VALUES ")
+
+	args := make([]driver.NamedValue, 0, len(modifications)*len(columns))
+	var placeholder [20]byte
+	for i := range modifications {
+		if i > 0 {
+			query.WriteString(", ")
+		}
+		query.WriteByte('(')
+		for k, j := range columns {
+			if k > 0 {
+				query.WriteString(", ")
+			}
+			n := i*len(columns) + k + 1
+			query.Write(dialect.appendPlaceholder(placeholder[:0], n))
+			value := values[i][j]
+			switch v := value.(type) {
+			case nil:
+				value = auditColumns[j].fallback
+			case []string:
+				value = dialect.formatArray(v)
+			}
+			args = append(args, driver.NamedValue{Ordinal: n, Value: value})
+		}
+		query.WriteByte(')')
+	}
+
+	return query.String(), args, nil
+}
@@ -0,0 +1,48 @@
+package audriver
+
+// splitInsertRows splits a multi-row INSERT ... VALUES statement into one statement per row,
+// keeping the column list and any trailing clause such as ON CONFLICT or RETURNING.
+// It returns nil if the statement does not insert multiple rows from a VALUES list.
+func splitInsertRows(sql string) []string {
+	valuesIndex := indexTopLevelKeyword(sql, "VALUES")
+	if valuesIndex < 0 {
+		return nil
+	}
+
+	var (
+		rows  []string
+		start = -1
+		end   = valuesIndex + len("VALUES")
+	)
+	for i := end; ; {
+		i = skipSpaces(sql, i)
+		if i >= len(sql) || sql[i] != '(' {
+			return nil
+		}
+		closing := indexClosingParen(sql, i)
+		if closing < 0 {
+			return nil
+		}
+		if start < 0 {
+			start = i
+		}
+		rows = append(rows, sql[i:closing+1])
+		end = closing + 1
+
+		i = skipSpaces(sql, end)
+		if i >= len(sql) || sql[i] != ',' {
+			break
+		}
+		i++
+	}
+
+	if len(rows) < 2 {
+		return nil
+	}
+
+	statements := make([]string, len(rows))
+	for i, row := range rows {
+		statements[i] = sql[:start] + row + sql[end:]
+	}
+	return statements
+}
@@ -0,0 +1,110 @@
+package audriver
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"sync"
+)
+
+type jobKey struct{}
+
+// Job buffers the audit records of a long-running batch job, so that they are written in batches at checkpoints
+// instead of after every statement. If the job crashes, its audit trail is complete up to the last checkpoint,
+// and the sequence of the checkpoint tells how many of its modifications were recorded.
+//
+// Only statements executed outside of transactions are buffered; transactions write their records on commit as usual.
+type Job struct {
+	executionID string
+
+	// checkpointMu serializes checkpoints, so that buffered records are written once.
+	checkpointMu sync.Mutex
+
+	mu       sync.Mutex
+	pending  []DatabaseModification
+	sequence int64
+}
+
+// JobCheckpoint is the progress of a job recorded by Job.Checkpoint.
+type JobCheckpoint struct {
+	// ExecutionID is the execution ID of the job.
+	ExecutionID string
+
+	// Sequence is the number of modifications of the job written so far.
+	Sequence int64
+}
+
+// StartJob starts a job with the given job-scoped execution ID.
+// Statements executed with the returned context are recorded under the execution ID and buffered by the job
+// until Job.Checkpoint is called.
+func StartJob(ctx context.Context, executionID string) (context.Context, *Job) {
+	job := &Job{executionID: executionID}
+	ctx = WithExecutionID(ctx, executionID)
+	return context.WithValue(ctx, jobKey{}, job), job
+}
+
+// Checkpoint writes the buffered audit records of the job through db, which must be opened with an audit driver,
+// and returns the progress of the job. Call it periodically, e.g. every few thousand processed items.
+// If writing fails, the records stay buffered and are written by the next checkpoint.
+func (j *Job) Checkpoint(ctx context.Context, db *sql.DB) (JobCheckpoint, error) {
+	j.checkpointMu.Lock()
+	defer j.checkpointMu.Unlock()
+
+	j.mu.Lock()
+	mods := slices.Clone(j.pending)
+	j.mu.Unlock()
+
+	if len(mods) > 0 {
+		if err := j.write(ctx, db, mods); err != nil {
+			return j.progress(), err
+		}
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.pending = slices.Delete(j.pending, 0, len(mods))
+	j.sequence += int64(len(mods))
+	return JobCheckpoint{ExecutionID: j.executionID, Sequence: j.sequence}, nil
+}
+
+func (j *Job) write(ctx context.Context, db *sql.DB, mods []DatabaseModification) error {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to checkpoint job: %w", err)
+	}
+	defer func(conn *sql.Conn) {
+		_ = conn.Close()
+	}(conn)
+
+	return conn.Raw(func(driverConn any) error {
+		c, ok := driverConn.(*Conn)
+		if !ok {
+			return fmt.Errorf("%w: job checkpoints require a connection of an audit driver, got %T", ErrUnsupportedConn, driverConn)
+		}
+		if err := c.logModifications(ctx, mods, false); err != nil {
+			c.builder.handleError(ctx, err, ErrorStageFlush, mods)
+			return fmt.Errorf("failed to checkpoint job: %w", err)
+		}
+		return nil
+	})
+}
+
+// progress returns the progress of the job as of its last checkpoint.
+func (j *Job) progress() JobCheckpoint {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return JobCheckpoint{ExecutionID: j.executionID, Sequence: j.sequence}
+}
+
+func (j *Job) add(mods []DatabaseModification) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.pending = append(j.pending, mods...)
+}
+
+// jobFromContext returns the job of ctx, if any.
+func jobFromContext(ctx context.Context) *Job {
+	job, _ := ctx.Value(jobKey{}).(*Job)
+	return job
+}
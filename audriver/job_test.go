@@ -0,0 +1,51 @@
+package audriver_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mickamy/go-sql-audit-driver/audriver"
+)
+
+// TestJob tests buffering and checkpointing the audit records of a batch job
+func TestJob(t *testing.T) {
+	t.Parallel()
+
+	// arrange
+	baseDriver := &fakeDriver{}
+	db := setUpFakeTestDB(t, baseDriver)
+	ctx, job := audriver.StartJob(audriver.WithOperatorID(t.Context(), uuid.New().String()), "job-1")
+
+	// act
+	for i := 0; i < 3; i++ {
+		_, err := db.ExecContext(ctx, "UPDATE users SET age = $1", i)
+		require.NoError(t, err)
+	}
+	buffered := len(baseDriver.auditInserts())
+	first, err := job.Checkpoint(ctx, db)
+	require.NoError(t, err)
+
+	_, err = db.ExecContext(ctx, "DELETE FROM users")
+	require.NoError(t, err)
+	baseDriver.auditErr = errors.New("audit table unavailable")
+	failed, failedErr := job.Checkpoint(ctx, db)
+	baseDriver.auditErr = nil
+	retried, err := job.Checkpoint(ctx, db)
+	require.NoError(t, err)
+
+	// assert
+	assert.Zero(t, buffered, "records should be buffered until the checkpoint")
+	assert.Equal(t, audriver.JobCheckpoint{ExecutionID: "job-1", Sequence: 3}, first)
+	assert.ErrorIs(t, failedErr, audriver.ErrAuditWriteFailed)
+	assert.Equal(t, audriver.JobCheckpoint{ExecutionID: "job-1", Sequence: 3}, failed)
+	assert.Equal(t, audriver.JobCheckpoint{ExecutionID: "job-1", Sequence: 4}, retried)
+
+	inserts := baseDriver.auditInserts()
+	require.Len(t, inserts, 2)
+	assert.Equal(t, []any{"job-1", "job-1", "job-1"}, inserts[0].values("execution_id"))
+	assert.Equal(t, []any{"delete"}, inserts[1].values("action"))
+}
@@ -0,0 +1,200 @@
+package audriver
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+	"time"
+)
+
+type jwtKey struct{}
+
+// JWTKeyFunc returns the key verifying JWTs signed with alg by the key identified by kid:
+// a []byte secret for HS256, HS384, and HS512, an *rsa.PublicKey for RS256, RS384, RS512, PS256, PS384, and PS512,
+// or an *ecdsa.PublicKey for ES256, ES384, and ES512.
+type JWTKeyFunc func(alg string, kid string) (any, error)
+
+// WithJWT returns a context carrying the JWT of the current request, e.g. the bearer token of the Authorization header,
+// for JWTOperatorExtractor.
+func WithJWT(ctx context.Context, token string) context.Context {
+	return context.WithValue(ctx, jwtKey{}, token)
+}
+
+// JWTOperatorExtractor returns an OperatorIDExtractor taking the operator ID from the given claim, e.g. "sub",
+// of the JWT set with WithJWT. The token is verified with the key returned by keyfunc,
+// and expired or not yet valid tokens are rejected with ErrInvalidJWT.
+// Without a token, the operator ID set with WithOperatorID is used.
+func JWTOperatorExtractor(claim string, keyfunc JWTKeyFunc) OperatorIDExtractor {
+	return OperatorIDExtractorFunc(func(ctx context.Context) (string, error) {
+		token, ok := ctx.Value(jwtKey{}).(string)
+		if !ok || token == "" {
+			return GetOperatorID(ctx)
+		}
+
+		claims, err := verifyJWT(token, keyfunc, time.Now())
+		if err != nil {
+			return "", fmt.Errorf("%w: %w", ErrInvalidJWT, err)
+		}
+
+		switch v := claims[claim].(type) {
+		case string:
+			if v != "" {
+				return v, nil
+			}
+		case json.Number:
+			return v.String(), nil
+		}
+		return "", fmt.Errorf("%w: claim %s is missing", ErrInvalidJWT, claim)
+	})
+}
+
+// verifyJWT verifies the signature and validity period of a compact-serialized JWS and returns its claims.
+func verifyJWT(token string, keyfunc JWTKeyFunc, now time.Time) (map[string]any, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed token")
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeJWTSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("malformed header: %w", err)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("malformed signature: %w", err)
+	}
+
+	key, err := keyfunc(header.Alg, header.Kid)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get key: %w", err)
+	}
+	if err := verifyJWTSignature(header.Alg, key, parts[0]+"."+parts[1], signature); err != nil {
+		return nil, err
+	}
+
+	var claims map[string]any
+	if err := decodeJWTSegment(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("malformed claims: %w", err)
+	}
+	exp, ok, err := jwtTime(claims, "exp")
+	if err != nil {
+		return nil, err
+	}
+	if ok && !now.Before(exp) {
+		return nil, errors.New("token is expired")
+	}
+	nbf, ok, err := jwtTime(claims, "nbf")
+	if err != nil {
+		return nil, err
+	}
+	if ok && now.Before(nbf) {
+		return nil, errors.New("token is not valid yet")
+	}
+
+	return claims, nil
+}
+
+// jwtTime returns the time of the NumericDate claim name, and whether the claim is present. Claims present but not
+// numbers, e.g. strings or null, are rejected rather than ignored, lest malformed tokens never expire.
+func jwtTime(claims map[string]any, name string) (time.Time, bool, error) {
+	value, ok := claims[name]
+	if !ok {
+		return time.Time{}, false, nil
+	}
+	number, ok := value.(json.Number)
+	if !ok {
+		return time.Time{}, false, fmt.Errorf("malformed %s claim", name)
+	}
+	seconds, err := number.Float64()
+	if err != nil || math.IsNaN(seconds) || math.IsInf(seconds, 0) {
+		return time.Time{}, false, fmt.Errorf("malformed %s claim", name)
+	}
+	return time.Unix(int64(seconds), 0), true, nil
+}
+
+func decodeJWTSegment(segment string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	return decoder.Decode(v)
+}
+
+func verifyJWTSignature(alg string, key any, signed string, signature []byte) error {
+	var hash crypto.Hash
+	switch alg[min(2, len(alg)):] {
+	case "256":
+		hash = crypto.SHA256
+	case "384":
+		hash = crypto.SHA384
+	case "512":
+		hash = crypto.SHA512
+	default:
+		return fmt.Errorf("unsupported algorithm %q", alg)
+	}
+	h := hash.New()
+	h.Write([]byte(signed))
+	digest := h.Sum(nil)
+
+	invalid := errors.New("invalid signature")
+	switch alg[:2] {
+	case "HS":
+		secret, ok := key.([]byte)
+		if !ok {
+			return fmt.Errorf("key for %s must be []byte, got %T", alg, key)
+		}
+		mac := hmac.New(hash.New, secret)
+		mac.Write([]byte(signed))
+		if !hmac.Equal(signature, mac.Sum(nil)) {
+			return invalid
+		}
+	case "RS", "PS":
+		publicKey, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("key for %s must be *rsa.PublicKey, got %T", alg, key)
+		}
+		var err error
+		if alg[:2] == "RS" {
+			err = rsa.VerifyPKCS1v15(publicKey, hash, digest, signature)
+		} else {
+			err = rsa.VerifyPSS(publicKey, hash, digest, signature, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+		}
+		if err != nil {
+			return invalid
+		}
+	case "ES":
+		publicKey, ok := key.(*ecdsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("key for %s must be *ecdsa.PublicKey, got %T", alg, key)
+		}
+		// JWS encodes ECDSA signatures as the fixed-size concatenation of r and s
+		size := (publicKey.Curve.Params().BitSize + 7) / 8
+		if len(signature) != 2*size {
+			return invalid
+		}
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+		if !ecdsa.Verify(publicKey, digest, r, s) {
+			return invalid
+		}
+	default:
+		return fmt.Errorf("unsupported algorithm %q", alg)
+	}
+
+	return nil
+}
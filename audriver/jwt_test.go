@@ -0,0 +1,180 @@
+package audriver_test
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mickamy/go-sql-audit-driver/audriver"
+)
+
+// TestJWTOperatorExtractor tests extracting operator IDs from JWTs
+func TestJWTOperatorExtractor(t *testing.T) {
+	t.Parallel()
+
+	secret := []byte("secret")
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	keyfunc := func(alg string, _ string) (any, error) {
+		switch alg {
+		case "HS256":
+			return secret, nil
+		case "RS256":
+			return &rsaKey.PublicKey, nil
+		case "ES256":
+			return &ecKey.PublicKey, nil
+		}
+		return nil, errors.New("unexpected algorithm")
+	}
+
+	valid := map[string]any{"sub": "user-1", "exp": time.Now().Add(time.Hour).Unix()}
+
+	testCases := []struct {
+		name       string
+		token      string
+		operatorID string
+		want       string
+		wantErr    error
+	}{
+		{
+			name:  "hs256",
+			token: signJWT(t, "HS256", valid, secret),
+			want:  "user-1",
+		},
+		{
+			name:  "rs256",
+			token: signJWT(t, "RS256", valid, rsaKey),
+			want:  "user-1",
+		},
+		{
+			name:  "es256",
+			token: signJWT(t, "ES256", valid, ecKey),
+			want:  "user-1",
+		},
+		{
+			name:    "invalid_signature",
+			token:   signJWT(t, "HS256", valid, []byte("other")),
+			wantErr: audriver.ErrInvalidJWT,
+		},
+		{
+			name:    "expired",
+			token:   signJWT(t, "HS256", map[string]any{"sub": "user-1", "exp": time.Now().Add(-time.Hour).Unix()}, secret),
+			wantErr: audriver.ErrInvalidJWT,
+		},
+		{
+			name:    "not_valid_yet",
+			token:   signJWT(t, "HS256", map[string]any{"sub": "user-1", "nbf": time.Now().Add(time.Hour).Unix()}, secret),
+			wantErr: audriver.ErrInvalidJWT,
+		},
+		{
+			name:    "malformed_exp",
+			token:   signJWT(t, "HS256", map[string]any{"sub": "user-1", "exp": "2099-01-01T00:00:00Z"}, secret),
+			wantErr: audriver.ErrInvalidJWT,
+		},
+		{
+			name:    "null_exp",
+			token:   signJWT(t, "HS256", map[string]any{"sub": "user-1", "exp": nil}, secret),
+			wantErr: audriver.ErrInvalidJWT,
+		},
+		{
+			name:    "malformed_nbf",
+			token:   signJWT(t, "HS256", map[string]any{"sub": "user-1", "exp": time.Now().Add(time.Hour).Unix(), "nbf": "yesterday"}, secret),
+			wantErr: audriver.ErrInvalidJWT,
+		},
+		{
+			name:    "malformed_nbf_object",
+			token:   signJWT(t, "HS256", map[string]any{"sub": "user-1", "nbf": map[string]any{"seconds": 0}}, secret),
+			wantErr: audriver.ErrInvalidJWT,
+		},
+		{
+			name:  "valid_nbf",
+			token: signJWT(t, "HS256", map[string]any{"sub": "user-1", "nbf": time.Now().Add(-time.Hour).Unix()}, secret),
+			want:  "user-1",
+		},
+		{
+			name:    "missing_claim",
+			token:   signJWT(t, "HS256", map[string]any{"name": "user-1"}, secret),
+			wantErr: audriver.ErrInvalidJWT,
+		},
+		{
+			name:    "unsigned",
+			token:   signJWT(t, "none", valid, nil),
+			wantErr: audriver.ErrInvalidJWT,
+		},
+		{
+			name:       "no_token_falls_back_to_context",
+			operatorID: "operator-1",
+			want:       "operator-1",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// arrange
+			ctx := t.Context()
+			if tc.token != "" {
+				ctx = audriver.WithJWT(ctx, tc.token)
+			}
+			if tc.operatorID != "" {
+				ctx = audriver.WithOperatorID(ctx, tc.operatorID)
+			}
+			extractor := audriver.JWTOperatorExtractor("sub", keyfunc)
+
+			// act
+			got, err := extractor.ExtractOperatorID(ctx)
+
+			// assert
+			assert.ErrorIs(t, err, tc.wantErr)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+// signJWT signs claims as a compact JWS with alg and key.
+func signJWT(t *testing.T, alg string, claims map[string]any, key any) string {
+	t.Helper()
+
+	header, err := json.Marshal(map[string]string{"alg": alg, "typ": "JWT"})
+	require.NoError(t, err)
+	payload, err := json.Marshal(claims)
+	require.NoError(t, err)
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+
+	digest := sha256.Sum256([]byte(signed))
+	var signature []byte
+	switch k := key.(type) {
+	case []byte:
+		mac := hmac.New(sha256.New, k)
+		mac.Write([]byte(signed))
+		signature = mac.Sum(nil)
+	case *rsa.PrivateKey:
+		signature, err = rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, digest[:])
+		require.NoError(t, err)
+	case *ecdsa.PrivateKey:
+		r, s, err := ecdsa.Sign(rand.Reader, k, digest[:])
+		require.NoError(t, err)
+		signature = make([]byte, 64)
+		r.FillBytes(signature[:32])
+		s.FillBytes(signature[32:])
+	}
+
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
@@ -0,0 +1,106 @@
+package audriver
+
+import (
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// LoadShedding configures graceful degradation of auditing under sustained audit write latency.
+// While shedding load, only a fraction of the modifications of hot tables is recorded, marked ExactnessSampled.
+type LoadShedding struct {
+	// Latency is the audit write latency above which writes are slow.
+	Latency time.Duration
+
+	// Sustain is how long audit writes must stay slow before load is shed, and fast before shedding stops.
+	Sustain time.Duration
+
+	// Tables are the hot tables sampled while shedding load.
+	Tables []string
+
+	// SampleRate is the fraction of the modifications of Tables recorded while shedding load, e.g. 0.1 for 10%.
+	SampleRate float64
+
+	// OnChange, if set, is called when shedding starts, with a window without End, and when it stops,
+	// with the complete window, so that degradation windows can be recorded for compliance review.
+	OnChange func(window LoadSheddingWindow)
+}
+
+// LoadSheddingWindow is a period in which load was shed.
+type LoadSheddingWindow struct {
+	Start      time.Time
+	End        time.Time
+	Tables     []string
+	SampleRate float64
+}
+
+// loadShedder tracks audit write latency and decides whether load is shed. It is shared by all connections of a driver.
+type loadShedder struct {
+	cfg LoadShedding
+
+	shedding atomic.Bool
+
+	mu sync.Mutex
+	// since is when writes started being slow while not shedding, or fast while shedding; zero if they are not.
+	since  time.Time
+	window LoadSheddingWindow
+}
+
+func newLoadShedder(cfg LoadShedding) *loadShedder {
+	cfg.Tables = slices.Clone(cfg.Tables)
+	return &loadShedder{cfg: cfg}
+}
+
+// sampleRate returns the sample rate of table while shedding load, or false if its modifications are not shed.
+func (s *loadShedder) sampleRate(table string) (float64, bool) {
+	if s == nil || !s.shedding.Load() || !slices.Contains(s.cfg.Tables, table) {
+		return 0, false
+	}
+	return s.cfg.SampleRate, true
+}
+
+// observe records the latency of an audit write finished at now, starting or stopping shedding load
+// once writes have been slow or fast for the sustain period.
+func (s *loadShedder) observe(latency time.Duration, now time.Time) {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	shedding := s.shedding.Load()
+	if (latency > s.cfg.Latency) == shedding {
+		// writes are as expected in the current state
+		s.since = time.Time{}
+		return
+	}
+	if s.since.IsZero() {
+		s.since = now
+	}
+	if now.Sub(s.since) < s.cfg.Sustain {
+		return
+	}
+
+	s.since = time.Time{}
+	if shedding {
+		s.window.End = now
+		s.shedding.Store(false)
+	} else {
+		s.window = LoadSheddingWindow{Start: now, Tables: slices.Clone(s.cfg.Tables), SampleRate: s.cfg.SampleRate}
+		s.shedding.Store(true)
+	}
+	s.notify(s.window)
+}
+
+func (s *loadShedder) notify(window LoadSheddingWindow) {
+	if s.cfg.OnChange == nil {
+		return
+	}
+	defer func() {
+		// the audit write has already finished, so a panicking callback has nothing left to abort
+		_ = recover()
+	}()
+	s.cfg.OnChange(window)
+}
@@ -0,0 +1,349 @@
+// Package notify posts alerts to chat services, e.g. Slack or Microsoft Teams, when audit records match risk rules,
+// such as deletes of the payments table or updates of more than a thousand rows.
+//
+// A Notifier is an audriver.Logger, so it is notified of every record once it is written:
+//
+//	notifier := notify.New(notify.NewSlack(os.Getenv("SLACK_TOKEN"), "#audit-alerts"), []notify.Rule{
+//		{Name: "payments deleted", Tables: []string{"payments"}, Actions: []audriver.DatabaseModificationAction{"delete"}},
+//		{Name: "mass update", MinRows: 1000},
+//	})
+//	defer notifier.Close()
+//	auditDriver := audriver.New(baseDriver, audriver.WithLogger(notifier))
+//
+// Matching records of an execution are grouped into an alert, and later alerts of the same execution are posted as
+// replies to the thread of the first one on destinations supporting threads.
+package notify
+
+import (
+	"context"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+
+	"github.com/mickamy/go-sql-audit-driver/audriver"
+)
+
+const (
+	// DefaultGroupWindow is the default time matching records of an execution are collected for before an alert is posted.
+	DefaultGroupWindow = 5 * time.Second
+
+	// DefaultBufferSize is the default number of matching records buffered before further ones are dropped.
+	DefaultBufferSize = 1024
+
+	// maxAlertRecords is the number of records listed in an alert; the others are only counted.
+	maxAlertRecords = 10
+
+	// threadTTL is how long after its latest alert further alerts of an execution are posted to its thread.
+	threadTTL = 24 * time.Hour
+)
+
+// Rule matches high-risk audit records. Zero fields do not restrict the match.
+type Rule struct {
+	// Name names the rule in alerts.
+	Name string
+
+	// Tables are the tables whose records match.
+	Tables []string
+
+	// Actions are the actions of the records that match.
+	Actions []audriver.DatabaseModificationAction
+
+	// MinRows is the number of rows from which records match, counted as the estimated rows of the statement, if
+	// recorded with WithRowEstimateGuard, or else as the number of record IDs.
+	MinRows int64
+}
+
+// Matches reports whether mod matches the rule.
+func (r Rule) Matches(mod audriver.DatabaseModification) bool {
+	return (len(r.Tables) == 0 || slices.Contains(r.Tables, mod.TableName)) &&
+		(len(r.Actions) == 0 || slices.Contains(r.Actions, mod.Action)) &&
+		Rows(mod) >= r.MinRows
+}
+
+// Rows returns the number of rows mod modified, as far as known: the estimated rows of its statement, the number of
+// its record IDs, or 1.
+func Rows(mod audriver.DatabaseModification) int64 {
+	return max(mod.EstimatedRows, int64(len(mod.RecordIDs)), 1)
+}
+
+// Alert is a group of audit records of an execution matching a rule.
+type Alert struct {
+	// Rule is the name of the rule the records matched.
+	Rule string
+
+	ExecutionID  string
+	OperatorID   string
+	OperatorName string
+
+	// Records are the first matching records of the execution within the group window.
+	Records []audriver.DatabaseModification
+
+	// Count is the number of matching records of the execution within the group window, including those not listed.
+	Count int
+
+	// Suppressed is the number of alerts not posted since the previous alert because of the rate limit.
+	Suppressed int
+}
+
+// Destination posts alerts to a chat service.
+type Destination interface {
+	// Post posts alert, as a reply to thread if it is not empty, and returns the thread of the posted alert for
+	// replies, or an empty string if the service does not support threads.
+	Post(ctx context.Context, alert Alert, thread string) (string, error)
+}
+
+// Option configures a Notifier.
+type Option func(*Notifier)
+
+// WithGroupWindow sets the time matching records of an execution are collected for before they are posted as an
+// alert. It defaults to DefaultGroupWindow.
+func WithGroupWindow(window time.Duration) Option {
+	return func(n *Notifier) {
+		n.window = window
+	}
+}
+
+// WithRateLimit posts at most limit alerts per period, so that a runaway job does not flood the channel.
+// Alerts beyond the limit are dropped, and counted in the next alert posted. Alerts are not limited by default.
+func WithRateLimit(limit int, period time.Duration) Option {
+	return func(n *Notifier) {
+		n.limit, n.period = limit, period
+	}
+}
+
+// WithBufferSize sets the number of matching records buffered for the background poster. Records matching while
+// the buffer is full are dropped and counted by Dropped. It defaults to DefaultBufferSize.
+func WithBufferSize(size int) Option {
+	return func(n *Notifier) {
+		n.bufferSize = size
+	}
+}
+
+// WithErrorHandler sets a function called with errors posting alerts, e.g. to log them.
+// Alerts failing to post are not retried.
+func WithErrorHandler(handler func(error)) Option {
+	return func(n *Notifier) {
+		n.errorHandler = handler
+	}
+}
+
+// Notifier posts alerts for audit records matching its rules to a destination. It implements audriver.Logger.
+// Alerts are posted in the background, so that logging never blocks on the chat service.
+type Notifier struct {
+	destination  Destination
+	rules        []Rule
+	window       time.Duration
+	limit        int
+	period       time.Duration
+	bufferSize   int
+	errorHandler func(error)
+
+	matches   chan match
+	done      chan struct{}
+	stopped   chan struct{}
+	closeOnce sync.Once
+	dropped   atomic.Int64
+}
+
+// match is a record matching a rule.
+type match struct {
+	rule string
+	mod  audriver.DatabaseModification
+}
+
+// New returns a notifier posting alerts for the records matching any of rules to destination.
+// Records are matched against the first matching rule only. Close the notifier to post pending alerts.
+func New(destination Destination, rules []Rule, opts ...Option) *Notifier {
+	n := &Notifier{
+		destination: destination,
+		rules:       rules,
+		window:      DefaultGroupWindow,
+		bufferSize:  DefaultBufferSize,
+		done:        make(chan struct{}),
+		stopped:     make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(n)
+	}
+	n.matches = make(chan match, n.bufferSize)
+	go n.run()
+	return n
+}
+
+// Log queues an alert for mod if it matches a rule. It never blocks.
+func (n *Notifier) Log(_ context.Context, mod audriver.DatabaseModification) {
+	for _, rule := range n.rules {
+		if !rule.Matches(mod) {
+			continue
+		}
+		select {
+		case <-n.done:
+		case n.matches <- match{rule: rule.Name, mod: mod}:
+		default:
+			n.dropped.Add(1)
+		}
+		return
+	}
+}
+
+// Dropped returns the number of matching records dropped because the buffer was full.
+func (n *Notifier) Dropped() int64 {
+	return n.dropped.Load()
+}
+
+// Close posts the pending alerts and stops the notifier. Records logged afterward are ignored.
+func (n *Notifier) Close() error {
+	n.closeOnce.Do(func() {
+		close(n.done)
+	})
+	<-n.stopped
+	return nil
+}
+
+// group is an alert being collected.
+type group struct {
+	alert Alert
+	since time.Time
+}
+
+// thread is the thread of the alerts of an execution.
+type thread struct {
+	id       string
+	postedAt time.Time
+}
+
+// run collects matching records into alerts and posts them once their group window has passed.
+func (n *Notifier) run() {
+	defer close(n.stopped)
+
+	var (
+		pending    = map[string]*group{}
+		threads    = map[string]thread{}
+		posts      []time.Time
+		suppressed int
+	)
+	post := func(g *group, now time.Time) {
+		// a sliding window of the posts of the rate limit period
+		if n.limit > 0 {
+			posts = slices.DeleteFunc(posts, func(t time.Time) bool { return now.Sub(t) >= n.period })
+			if len(posts) >= n.limit {
+				suppressed++
+				return
+			}
+			posts = append(posts, now)
+		}
+		g.alert.Suppressed, suppressed = suppressed, 0
+
+		key := g.alert.ExecutionID
+		previous := threads[key]
+		if now.Sub(previous.postedAt) >= threadTTL {
+			previous.id = ""
+		}
+		id, err := n.destination.Post(context.Background(), g.alert, previous.id)
+		if err != nil {
+			if n.errorHandler != nil {
+				n.errorHandler(err)
+			}
+			return
+		}
+		if previous.id != "" {
+			id = previous.id
+		}
+		if id != "" {
+			threads[key] = thread{id: id, postedAt: now}
+		}
+	}
+	flush := func(now time.Time, all bool) {
+		for key, g := range pending {
+			if all || now.Sub(g.since) >= n.window {
+				post(g, now)
+				delete(pending, key)
+			}
+		}
+		for key, t := range threads {
+			if now.Sub(t.postedAt) >= threadTTL {
+				delete(threads, key)
+			}
+		}
+	}
+	add := func(m match, now time.Time) {
+		key := m.mod.ExecutionID
+		g, ok := pending[key]
+		if !ok {
+			g = &group{
+				alert: Alert{Rule: m.rule, ExecutionID: key, OperatorID: m.mod.OperatorID, OperatorName: m.mod.OperatorName},
+				since: now,
+			}
+			pending[key] = g
+		}
+		g.alert.Count++
+		if len(g.alert.Records) < maxAlertRecords {
+			g.alert.Records = append(g.alert.Records, m.mod)
+		}
+	}
+
+	ticker := time.NewTicker(max(n.window/2, 10*time.Millisecond))
+	defer ticker.Stop()
+	for {
+		select {
+		case m := <-n.matches:
+			add(m, time.Now())
+		case now := <-ticker.C:
+			flush(now, false)
+		case <-n.done:
+			for len(n.matches) > 0 {
+				add(<-n.matches, time.Now())
+			}
+			flush(time.Now(), true)
+			return
+		}
+	}
+}
+
+// maxSQLLength is the number of characters of statements shown in alerts.
+const maxSQLLength = 200
+
+// operator returns the name and ID of the operator of alert, or only the ID if the name is unknown.
+func operator(alert Alert) string {
+	if alert.OperatorName == "" {
+		return alert.OperatorID
+	}
+	return alert.OperatorName + " (" + alert.OperatorID + ")"
+}
+
+// modifications formats a number of modifications.
+func modifications(n int) string {
+	if n == 1 {
+		return "1 modification"
+	}
+	return strconv.Itoa(n) + " modifications"
+}
+
+// rows formats the number of rows mod modified.
+func rows(mod audriver.DatabaseModification) string {
+	switch n := Rows(mod); {
+	case mod.EstimatedRows > 0:
+		return "~" + strconv.FormatInt(n, 10) + " rows"
+	case n == 1:
+		return "1 row"
+	default:
+		return strconv.FormatInt(n, 10) + " rows"
+	}
+}
+
+// oneLine collapses the whitespace of sql into single spaces.
+func oneLine(sql string) string {
+	return strings.Join(strings.Fields(sql), " ")
+}
+
+// truncate shortens s to maxSQLLength characters.
+func truncate(s string) string {
+	if utf8.RuneCountInString(s) <= maxSQLLength {
+		return s
+	}
+	return string([]rune(s)[:maxSQLLength-1]) + "…"
+}
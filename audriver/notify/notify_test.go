@@ -0,0 +1,194 @@
+package notify_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mickamy/go-sql-audit-driver/audriver"
+	"github.com/mickamy/go-sql-audit-driver/audriver/notify"
+)
+
+// recorder is a destination recording the alerts posted to it, with a thread per first alert of an execution.
+type recorder struct {
+	mu      sync.Mutex
+	alerts  []notify.Alert
+	threads []string
+}
+
+func (r *recorder) Post(_ context.Context, alert notify.Alert, thread string) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.alerts = append(r.alerts, alert)
+	r.threads = append(r.threads, thread)
+	return "thread-" + strconv.Itoa(len(r.alerts)), nil
+}
+
+func (r *recorder) posted() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.alerts)
+}
+
+// TestRule_Matches tests matching records against risk rules
+func TestRule_Matches(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name     string
+		rule     notify.Rule
+		mod      audriver.DatabaseModification
+		expected bool
+	}{
+		{
+			name:     "table_and_action",
+			rule:     notify.Rule{Tables: []string{"payments"}, Actions: []audriver.DatabaseModificationAction{audriver.DatabaseModificationActionDelete}},
+			mod:      audriver.DatabaseModification{TableName: "payments", Action: audriver.DatabaseModificationActionDelete},
+			expected: true,
+		},
+		{
+			name:     "other_action",
+			rule:     notify.Rule{Tables: []string{"payments"}, Actions: []audriver.DatabaseModificationAction{audriver.DatabaseModificationActionDelete}},
+			mod:      audriver.DatabaseModification{TableName: "payments", Action: audriver.DatabaseModificationActionUpdate},
+			expected: false,
+		},
+		{
+			name:     "estimated_rows",
+			rule:     notify.Rule{MinRows: 1000},
+			mod:      audriver.DatabaseModification{TableName: "users", EstimatedRows: 1500},
+			expected: true,
+		},
+		{
+			name:     "few_record_ids",
+			rule:     notify.Rule{MinRows: 3},
+			mod:      audriver.DatabaseModification{TableName: "users", RecordIDs: []string{"1", "2"}},
+			expected: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// act
+			matches := tc.rule.Matches(tc.mod)
+
+			// assert
+			assert.Equal(t, tc.expected, matches)
+		})
+	}
+}
+
+// TestNotifier tests grouping, threading, and rate limiting of alerts
+func TestNotifier(t *testing.T) {
+	t.Parallel()
+
+	rules := []notify.Rule{
+		{Name: "payments deleted", Tables: []string{"payments"}, Actions: []audriver.DatabaseModificationAction{audriver.DatabaseModificationActionDelete}},
+		{Name: "mass update", MinRows: 1000},
+	}
+	deletePayment := func(executionID string) audriver.DatabaseModification {
+		return audriver.DatabaseModification{ExecutionID: executionID, OperatorID: "alice", TableName: "payments", Action: audriver.DatabaseModificationActionDelete}
+	}
+
+	t.Run("group_and_thread", func(t *testing.T) {
+		t.Parallel()
+
+		// arrange
+		destination := &recorder{}
+		notifier := notify.New(destination, rules, notify.WithGroupWindow(20*time.Millisecond))
+
+		// act
+		notifier.Log(t.Context(), deletePayment("e1"))
+		notifier.Log(t.Context(), deletePayment("e1"))
+		notifier.Log(t.Context(), audriver.DatabaseModification{ExecutionID: "e1", TableName: "users", Action: audriver.DatabaseModificationActionUpdate})
+		require.Eventually(t, func() bool { return destination.posted() == 1 }, 5*time.Second, 5*time.Millisecond)
+		notifier.Log(t.Context(), deletePayment("e1"))
+		notifier.Log(t.Context(), audriver.DatabaseModification{ExecutionID: "e2", OperatorID: "bob", TableName: "users", EstimatedRows: 5000})
+		require.NoError(t, notifier.Close())
+
+		// assert
+		require.Len(t, destination.alerts, 3)
+		assert.Equal(t, "payments deleted", destination.alerts[0].Rule)
+		assert.Equal(t, 2, destination.alerts[0].Count, "records of an execution should be grouped")
+		assert.Empty(t, destination.threads[0])
+
+		byExecution := map[string]int{}
+		for i, alert := range destination.alerts[1:] {
+			byExecution[alert.ExecutionID] = i + 1
+		}
+		assert.Equal(t, "thread-1", destination.threads[byExecution["e1"]], "later alerts of an execution should reply to its thread")
+		assert.Empty(t, destination.threads[byExecution["e2"]])
+		assert.Equal(t, "mass update", destination.alerts[byExecution["e2"]].Rule)
+	})
+
+	t.Run("rate_limit", func(t *testing.T) {
+		t.Parallel()
+
+		// arrange
+		destination := &recorder{}
+		notifier := notify.New(destination, rules, notify.WithGroupWindow(time.Millisecond), notify.WithRateLimit(1, time.Hour))
+
+		// act
+		for i := range 3 {
+			notifier.Log(t.Context(), deletePayment("e"+strconv.Itoa(i)))
+			time.Sleep(30 * time.Millisecond)
+		}
+		require.NoError(t, notifier.Close())
+
+		// assert
+		require.Len(t, destination.alerts, 1)
+		assert.Equal(t, "e0", destination.alerts[0].ExecutionID)
+	})
+}
+
+// TestSlack tests posting alerts with the Slack Web API
+func TestSlack(t *testing.T) {
+	t.Parallel()
+
+	// arrange
+	var (
+		mu       sync.Mutex
+		messages []map[string]string
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer xoxb-token", r.Header.Get("Authorization"))
+		var message map[string]string
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&message))
+		mu.Lock()
+		messages = append(messages, message)
+		mu.Unlock()
+		_, _ = w.Write([]byte(`{"ok": true, "ts": "1700000000.000100"}`))
+	}))
+	t.Cleanup(server.Close)
+	slack := &notify.Slack{Token: "xoxb-token", Channel: "#audit", APIURL: server.URL}
+	alert := notify.Alert{
+		Rule: "payments deleted", ExecutionID: "e1", OperatorID: "alice", OperatorName: "Alice", Count: 2,
+		Records: []audriver.DatabaseModification{{TableName: "payments", Action: audriver.DatabaseModificationActionDelete, SQL: "DELETE FROM payments\nWHERE amount < 10"}},
+	}
+
+	// act
+	thread, err := slack.Post(t.Context(), alert, "")
+	require.NoError(t, err)
+	_, err = slack.Post(t.Context(), alert, thread)
+	require.NoError(t, err)
+
+	// assert
+	assert.Equal(t, "1700000000.000100", thread)
+	require.Len(t, messages, 2)
+	assert.Equal(t, "#audit", messages[0]["channel"])
+	assert.Equal(t, ":rotating_light: *payments deleted*: 2 modifications by Alice (alice) in execution `e1`\n"+
+		"• delete on payments (1 row): `DELETE FROM payments WHERE amount &lt; 10`\n"+
+		"… and 1 modification more", messages[0]["text"])
+	assert.Empty(t, messages[0]["thread_ts"])
+	assert.Equal(t, thread, messages[1]["thread_ts"])
+}
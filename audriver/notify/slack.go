@@ -0,0 +1,135 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// DefaultSlackAPIURL is the URL of the Slack Web API method posting messages.
+const DefaultSlackAPIURL = "https://slack.com/api/chat.postMessage"
+
+// Slack posts alerts to a Slack channel, threading the alerts of an execution.
+type Slack struct {
+	// Token is the bot token posting the alerts, with the chat:write scope.
+	Token string
+
+	// Channel is the channel the alerts are posted to, e.g. "#audit-alerts" or a channel ID.
+	Channel string
+
+	// WebhookURL posts the alerts to an incoming webhook instead, if set. Incoming webhooks do not support threads.
+	WebhookURL string
+
+	// APIURL is the URL of chat.postMessage. It defaults to DefaultSlackAPIURL.
+	APIURL string
+
+	// Client is the HTTP client posting the alerts. It defaults to http.DefaultClient.
+	Client *http.Client
+}
+
+// NewSlack returns a destination posting alerts to channel with the bot token.
+func NewSlack(token, channel string) *Slack {
+	return &Slack{Token: token, Channel: channel}
+}
+
+// NewSlackWebhook returns a destination posting alerts to the Slack incoming webhook url, without threads.
+func NewSlackWebhook(url string) *Slack {
+	return &Slack{WebhookURL: url}
+}
+
+// Post posts alert as a message, in thread if it is not empty, and returns the timestamp of the message as its thread.
+func (s *Slack) Post(ctx context.Context, alert Alert, thread string) (string, error) {
+	message := map[string]string{"text": slackText(alert)}
+	if s.WebhookURL != "" {
+		_, err := postJSON(ctx, s.Client, s.WebhookURL, "", message)
+		return "", err
+	}
+
+	message["channel"] = s.Channel
+	if thread != "" {
+		message["thread_ts"] = thread
+	}
+	url := s.APIURL
+	if url == "" {
+		url = DefaultSlackAPIURL
+	}
+	body, err := postJSON(ctx, s.Client, url, s.Token, message)
+	if err != nil {
+		return "", err
+	}
+	var res struct {
+		OK    bool   `json:"ok"`
+		Error string `json:"error"`
+		TS    string `json:"ts"`
+	}
+	if err := json.Unmarshal(body, &res); err != nil {
+		return "", fmt.Errorf("failed to decode Slack response: %w", err)
+	}
+	if !res.OK {
+		return "", fmt.Errorf("failed to post Slack message: %s", res.Error)
+	}
+	return res.TS, nil
+}
+
+// slackEscaper escapes the control characters of Slack's mrkdwn.
+var slackEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// slackText formats alert as a Slack mrkdwn message.
+func slackText(alert Alert) string {
+	var b strings.Builder
+	_, _ = fmt.Fprintf(&b, ":rotating_light: *%s*: %s by %s in execution `%s`",
+		slackEscaper.Replace(alert.Rule), modifications(alert.Count), slackEscaper.Replace(operator(alert)), alert.ExecutionID)
+	for _, mod := range alert.Records {
+		_, _ = fmt.Fprintf(&b, "\n• %s on %s (%s): `%s`",
+			mod.Action, slackEscaper.Replace(mod.TableName), rows(mod), slackEscaper.Replace(truncate(oneLine(mod.SQL))))
+	}
+	if more := alert.Count - len(alert.Records); more > 0 {
+		_, _ = fmt.Fprintf(&b, "\n… and %s more", modifications(more))
+	}
+	if alert.Suppressed > 0 {
+		_, _ = fmt.Fprintf(&b, "\n_%d earlier alerts were suppressed by the rate limit_", alert.Suppressed)
+	}
+	return b.String()
+}
+
+// postJSON posts payload as JSON to url, authorized with the bearer token if it is not empty, and returns the body of
+// the response.
+func postJSON(ctx context.Context, client *http.Client, url, token string, payload any) ([]byte, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode alert: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	if client == nil {
+		client = http.DefaultClient
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to post alert: %w", err)
+	}
+	defer func(body io.ReadCloser) {
+		_ = body.Close()
+	}(res.Body)
+
+	body, err := io.ReadAll(io.LimitReader(res.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return nil, errors.New("failed to post alert: " + res.Status + ": " + strings.TrimSpace(string(body)))
+	}
+	return body, nil
+}
@@ -0,0 +1,67 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// Teams posts alerts to a Microsoft Teams channel as Adaptive Cards, through the webhook of a Workflows (Power
+// Automate) flow posting to the channel. Teams webhooks do not support threads, so every alert is a new message.
+type Teams struct {
+	// WebhookURL is the URL of the webhook.
+	WebhookURL string
+
+	// Client is the HTTP client posting the alerts. It defaults to http.DefaultClient.
+	Client *http.Client
+}
+
+// NewTeams returns a destination posting alerts to the Teams webhook url.
+func NewTeams(url string) *Teams {
+	return &Teams{WebhookURL: url}
+}
+
+// Post posts alert as an Adaptive Card. Teams does not support threads, so thread is ignored.
+func (t *Teams) Post(ctx context.Context, alert Alert, _ string) (string, error) {
+	_, err := postJSON(ctx, t.Client, t.WebhookURL, "", map[string]any{
+		"type": "message",
+		"attachments": []map[string]any{{
+			"contentType": "application/vnd.microsoft.card.adaptive",
+			"content":     teamsCard(alert),
+		}},
+	})
+	return "", err
+}
+
+// teamsCard formats alert as an Adaptive Card.
+func teamsCard(alert Alert) map[string]any {
+	body := []map[string]any{
+		{"type": "TextBlock", "text": "🚨 " + alert.Rule, "weight": "Bolder", "size": "Medium", "wrap": true},
+		{"type": "FactSet", "facts": []map[string]string{
+			{"title": "Operator", "value": operator(alert)},
+			{"title": "Execution", "value": alert.ExecutionID},
+			{"title": "Modifications", "value": fmt.Sprint(alert.Count)},
+		}},
+	}
+	for _, mod := range alert.Records {
+		body = append(body,
+			map[string]any{"type": "TextBlock", "text": fmt.Sprintf("%s on %s (%s)", mod.Action, mod.TableName, rows(mod)), "weight": "Bolder", "wrap": true},
+			map[string]any{"type": "TextBlock", "text": truncate(oneLine(mod.SQL)), "fontType": "Monospace", "wrap": true},
+		)
+	}
+	if more := alert.Count - len(alert.Records); more > 0 {
+		body = append(body, map[string]any{"type": "TextBlock", "text": "… and " + modifications(more) + " more", "wrap": true})
+	}
+	if alert.Suppressed > 0 {
+		body = append(body, map[string]any{
+			"type": "TextBlock", "text": fmt.Sprintf("%d earlier alerts were suppressed by the rate limit", alert.Suppressed),
+			"isSubtle": true, "wrap": true,
+		})
+	}
+	return map[string]any{
+		"$schema": "http://adaptivecards.io/schemas/adaptive-card.json",
+		"type":    "AdaptiveCard",
+		"version": "1.4",
+		"body":    body,
+	}
+}
@@ -0,0 +1,46 @@
+package audriver
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// Operator describes the person or system behind an operator ID, so that audit records are human-readable.
+type Operator struct {
+	Name  string
+	Email string
+}
+
+// OperatorResolver resolves operator IDs to operators, e.g. from a user directory.
+type OperatorResolver interface {
+	ResolveOperator(ctx context.Context, operatorID string) (Operator, error)
+}
+
+// OperatorResolverFunc is a function type that implements the OperatorResolver interface.
+type OperatorResolverFunc func(ctx context.Context, operatorID string) (Operator, error)
+
+func (f OperatorResolverFunc) ResolveOperator(ctx context.Context, operatorID string) (Operator, error) {
+	return f(ctx, operatorID)
+}
+
+// operatorCache resolves operators with a resolver, caching resolved operators per driver.
+// Failed resolutions are not cached, so they are retried on the next modification of the operator.
+type operatorCache struct {
+	resolver OperatorResolver
+	cache    sync.Map
+}
+
+func (c *operatorCache) resolve(ctx context.Context, operatorID string) (Operator, error) {
+	if cached, ok := c.cache.Load(operatorID); ok {
+		return cached.(Operator), nil
+	}
+
+	operator, err := c.resolver.ResolveOperator(ctx, operatorID)
+	if err != nil {
+		return Operator{}, fmt.Errorf("failed to resolve operator %s: %w", operatorID, err)
+	}
+	c.cache.Store(operatorID, operator)
+
+	return operator, nil
+}
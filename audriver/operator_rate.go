@@ -0,0 +1,157 @@
+package audriver
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// OperatorRateAnomaly configures tracking the modification rates of operators, to detect operators modifying
+// unusually many rows, e.g. compromised credentials or runaway scripts, early.
+type OperatorRateAnomaly struct {
+	// Threshold is the number of modifications an operator may make within Window; zero only tracks the rates.
+	Threshold int64
+
+	// Window is the period rates are measured over; it defaults to a minute.
+	Window time.Duration
+
+	// OnAnomaly, if set, is called with the context of the statement when an operator exceeds Threshold, at most once
+	// per operator and window.
+	OnAnomaly func(ctx context.Context, rate OperatorRate)
+}
+
+// OperatorRate is the number of modifications an operator made within the window of OperatorRateAnomaly.
+type OperatorRate struct {
+	OperatorID    string
+	Modifications int64
+	Window        time.Duration
+}
+
+// operatorRates counts the audited modifications of each operator. It is shared by all connections of a driver.
+// Rates are estimated by a sliding window: the count of the previous fixed window, weighted by its share of the last
+// window, plus the count of the current one.
+type operatorRates struct {
+	cfg OperatorRateAnomaly
+
+	mu        sync.Mutex
+	operators map[string]*operatorWindow
+	pruned    time.Time
+}
+
+// operatorWindow is the count of the modifications of an operator in the current and the previous fixed window.
+type operatorWindow struct {
+	start    time.Time
+	current  int64
+	previous int64
+
+	// alerted is whether the operator was reported in the current window.
+	alerted bool
+}
+
+func newOperatorRates(cfg OperatorRateAnomaly) *operatorRates {
+	if cfg.Window <= 0 {
+		cfg.Window = time.Minute
+	}
+	return &operatorRates{cfg: cfg, operators: make(map[string]*operatorWindow)}
+}
+
+// trackOperatorRates counts mods by their operators, and reports operators exceeding the threshold.
+func (b *databaseModificationBuilder) trackOperatorRates(ctx context.Context, mods []DatabaseModification) {
+	r := b.operatorRates
+	if r == nil || len(mods) == 0 {
+		return
+	}
+
+	var exceeded []OperatorRate
+	r.mu.Lock()
+	now := time.Now()
+	if now.Sub(r.pruned) >= r.cfg.Window {
+		r.prune(now)
+		r.pruned = now
+	}
+	for i := 0; i < len(mods); {
+		// modifications of a statement or transaction share their operator
+		operatorID, n := mods[i].OperatorID, 1
+		for i+n < len(mods) && mods[i+n].OperatorID == operatorID {
+			n++
+		}
+		i += n
+
+		w := r.operators[operatorID]
+		if w == nil {
+			w = &operatorWindow{}
+			r.operators[operatorID] = w
+		}
+		w.advance(now, r.cfg.Window)
+		w.current += int64(n)
+		if rate := w.rate(now, r.cfg.Window); r.cfg.Threshold > 0 && rate > r.cfg.Threshold && !w.alerted {
+			w.alerted = true
+			exceeded = append(exceeded, OperatorRate{OperatorID: operatorID, Modifications: rate, Window: r.cfg.Window})
+		}
+	}
+	r.mu.Unlock()
+
+	b.stats.rateAnomalies.Add(int64(len(exceeded)))
+	for _, rate := range exceeded {
+		r.notify(ctx, rate)
+	}
+}
+
+// snapshot returns the rates of the operators active within the last window.
+func (r *operatorRates) snapshot() map[string]int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	rates := make(map[string]int64, len(r.operators))
+	for operatorID, w := range r.operators {
+		w.advance(now, r.cfg.Window)
+		if rate := w.rate(now, r.cfg.Window); rate > 0 {
+			rates[operatorID] = rate
+		}
+	}
+	return rates
+}
+
+// prune forgets operators without modifications within the last window at now, so that memory is bounded by the
+// operators active recently.
+func (r *operatorRates) prune(now time.Time) {
+	for operatorID, w := range r.operators {
+		w.advance(now, r.cfg.Window)
+		if w.current == 0 && w.previous == 0 {
+			delete(r.operators, operatorID)
+		}
+	}
+}
+
+func (r *operatorRates) notify(ctx context.Context, rate OperatorRate) {
+	if r.cfg.OnAnomaly == nil {
+		return
+	}
+	defer func() {
+		// the modifications have already been audited, so a panicking callback has nothing left to abort
+		_ = recover()
+	}()
+	r.cfg.OnAnomaly(ctx, rate)
+}
+
+// advance moves the window to the fixed window of now.
+func (w *operatorWindow) advance(now time.Time, window time.Duration) {
+	start := now.Truncate(window)
+	switch {
+	case !start.After(w.start):
+		// now is in the current window, or before it if the clock stepped back
+		return
+	case start.Equal(w.start.Add(window)):
+		w.previous, w.current = w.current, 0
+	default:
+		w.previous, w.current = 0, 0
+	}
+	w.start = start
+	w.alerted = false
+}
+
+// rate returns the modifications within the last window at now, weighting the previous fixed window by its overlap.
+func (w *operatorWindow) rate(now time.Time, window time.Duration) int64 {
+	overlap := window - now.Sub(w.start)
+	return w.current + int64(float64(w.previous)*float64(overlap)/float64(window))
+}
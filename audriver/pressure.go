@@ -0,0 +1,65 @@
+package audriver
+
+import (
+	"context"
+	"fmt"
+)
+
+type backpressureSheddingKey struct{}
+
+// WithBackpressureShedding returns a context marking statements executed with it as non-critical, e.g. set by a
+// middleware for analytics or cache writes: while the pressure level of the audit pipeline is at least level, modifying
+// statements executed with it are rejected with ErrAuditBackpressure before they are executed, so that they are shed
+// instead of slowing down or failing critical ones. See Driver.PressureLevel.
+//
+// The pressure level only reflects how full the queues of WithAsyncLogging are: slow audits reported by
+// WithSlowAuditThreshold and retries of WithAuditRetry do not raise it, and without async logging nothing is shed.
+func WithBackpressureShedding(ctx context.Context, level float64) context.Context {
+	return context.WithValue(ctx, backpressureSheddingKey{}, level)
+}
+
+// PressureLevel returns the saturation of the audit pipeline, from 0 when it is idle to 1 when the queues of
+// WithAsyncLogging are full and statements are subject to the backpressure policy, for applications to shed
+// non-critical writes before the audit pipeline slows them down. It is 0 without async logging and after Shutdown.
+func (d *Driver) PressureLevel() float64 {
+	if d.async == nil {
+		return 0
+	}
+	return d.async.pressureLevel()
+}
+
+// pressureLevel returns the fraction of the capacity of the queues holding writes.
+func (w *asyncWriter) pressureLevel() float64 {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	if w.closed {
+		return 0
+	}
+	var queued, capacity int
+	for _, queue := range w.queues {
+		queued += len(queue)
+		capacity += cap(queue)
+	}
+	if capacity == 0 {
+		return 0
+	}
+	return float64(queued) / float64(capacity)
+}
+
+// shedBackpressure returns an error wrapping ErrAuditBackpressure if ctx is marked with WithBackpressureShedding and
+// the pressure level of the audit pipeline reached its level.
+func (b *databaseModificationBuilder) shedBackpressure(ctx context.Context, mods []DatabaseModification) error {
+	threshold, ok := ctx.Value(backpressureSheddingKey{}).(float64)
+	if !ok || len(mods) == 0 {
+		return nil
+	}
+	// the sink is the async writer if WithAsyncLogging is set
+	w, ok := b.sink.(*asyncWriter)
+	if !ok {
+		return nil
+	}
+	if level := w.pressureLevel(); level >= threshold {
+		return fmt.Errorf("%w: pressure level %.2f reached %.2f", ErrAuditBackpressure, level, threshold)
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package audriver
+
+import (
+	"time"
+)
+
+// ProfileStrict bundles options for deployments where every write must be audited exactly, e.g. under SOC 2 or PCI DSS.
+// It sets:
+//   - WithStrictParsing(true): unclassified modifying statements are rejected
+//   - WithSplitMultiRowInserts(true): multi-row inserts are recorded per row
+//   - WithTableSampling(nil): every table is audited
+//   - WithEnablementRate(1): every connection is audited
+//   - WithAuditRetry(AuditRetry{MaxRetries: 3, MaxBackoff: time.Second}): transient audit write failures are retried
+//     before failing the statement
+//   - WithAnonymizer(CardNumberRedaction()): payment card numbers are redacted
+//
+// Arguments are not captured separately from the SQL. Add WithBackendIDs(true) on PostgreSQL to correlate records with
+// server logs.
+func ProfileStrict() Option {
+	return profile(
+		WithStrictParsing(true),
+		WithSplitMultiRowInserts(true),
+		WithTableSampling(nil),
+		WithEnablementRate(1),
+		WithAuditRetry(AuditRetry{MaxRetries: 3, MaxBackoff: time.Second}),
+		WithAnonymizer(CardNumberRedaction()),
+	)
+}
+
+// ProfileBalanced bundles options for typical production deployments, trading little exactness for throughput.
+// It sets:
+//   - WithStrictParsing(false): unclassified modifying statements are recorded with the unknown action
+//   - WithAuditRetry(AuditRetry{MaxRetries: 2, MaxBackoff: 200 * time.Millisecond}): transient audit write failures
+//     are retried briefly
+//   - WithAuditStatementCache(32): audit inserts are prepared once per connection
+//   - WithFlushThreshold(1000): large transactions are flushed in batches
+//   - WithSlowAuditThreshold(100 * time.Millisecond): slow audits are reported
+//   - WithAnonymizer(CardNumberRedaction()): payment card numbers are redacted
+//
+// Arguments are not captured separately from the SQL, and sampling is left as configured.
+func ProfileBalanced() Option {
+	return profile(
+		WithStrictParsing(false),
+		WithAuditRetry(AuditRetry{MaxRetries: 2, MaxBackoff: 200 * time.Millisecond}),
+		WithAuditStatementCache(32),
+		WithFlushThreshold(1000),
+		WithSlowAuditThreshold(100*time.Millisecond),
+		WithAnonymizer(CardNumberRedaction()),
+	)
+}
+
+// ProfileDevelopment bundles options surfacing auditing problems early in development and tests.
+// It sets:
+//   - WithStrictParsing(true): unclassified modifying statements are rejected
+//   - WithQueryAnnotation(true): statements are annotated with their operator and execution IDs
+//   - WithSlowAuditThreshold(10 * time.Millisecond): audits slower than 10ms are reported
+//
+// Values are stored as given, so that statements can be debugged from their audit records.
+func ProfileDevelopment() Option {
+	return profile(
+		WithStrictParsing(true),
+		WithQueryAnnotation(true),
+		WithSlowAuditThreshold(10*time.Millisecond),
+	)
+}
+
+// profile combines options into one. Options passed after a profile override its settings.
+func profile(options ...Option) Option {
+	return func(d *Driver) {
+		for _, option := range options {
+			option(d)
+		}
+	}
+}
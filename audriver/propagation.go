@@ -0,0 +1,79 @@
+package audriver
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// ExecutionIDHeader is the HTTP header carrying the execution ID between services.
+	ExecutionIDHeader = "Audriver-Execution-Id"
+
+	// ExecutionIDMetadataKey is the gRPC metadata key carrying the execution ID between services.
+	ExecutionIDMetadataKey = "audriver-execution-id"
+)
+
+// InjectExecutionID sets the execution ID of ctx, if any, on the headers of an outgoing HTTP request.
+func InjectExecutionID(ctx context.Context, header http.Header) {
+	if executionID, err := GetExecutionID(ctx); err == nil {
+		header.Set(ExecutionIDHeader, executionID)
+	}
+}
+
+// ExecutionIDTransport returns an http.RoundTripper that propagates the execution ID of request contexts
+// to other services sharing the audit store, so that a logical operation can be correlated end-to-end:
+//
+//	client := &http.Client{Transport: audriver.ExecutionIDTransport(http.DefaultTransport)}
+//
+// If base is nil, http.DefaultTransport is used.
+func ExecutionIDTransport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		if _, err := GetExecutionID(req.Context()); err != nil {
+			return base.RoundTrip(req)
+		}
+		// a RoundTripper must not modify the request
+		req = req.Clone(req.Context())
+		InjectExecutionID(req.Context(), req.Header)
+		return base.RoundTrip(req)
+	})
+}
+
+// ExecutionIDMiddleware sets the execution ID of incoming HTTP requests carrying ExecutionIDHeader on their context,
+// so that modifications of the receiving service are recorded with the execution ID of the calling service.
+// The header is controlled by the caller, so execution IDs other than UUIDs are replaced by a new one rather than
+// recorded. Requests without the header are passed through unchanged.
+func ExecutionIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if header := r.Header.Get(ExecutionIDHeader); header != "" {
+			executionID, err := uuid.Parse(header)
+			if err != nil {
+				executionID = uuid.New()
+			}
+			r = r.WithContext(WithExecutionID(r.Context(), executionID.String()))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// ExecutionIDMetadata returns the execution ID of ctx, if any, as gRPC metadata key-value pairs
+// to append to outgoing calls, e.g. in a client interceptor:
+//
+//	ctx = metadata.AppendToOutgoingContext(ctx, audriver.ExecutionIDMetadata(ctx)...)
+func ExecutionIDMetadata(ctx context.Context) []string {
+	executionID, err := GetExecutionID(ctx)
+	if err != nil {
+		return nil
+	}
+	return []string{ExecutionIDMetadataKey, executionID}
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
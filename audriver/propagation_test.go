@@ -0,0 +1,126 @@
+package audriver_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mickamy/go-sql-audit-driver/audriver"
+)
+
+// TestExecutionIDPropagation tests propagating the execution ID from an HTTP client to an HTTP server
+func TestExecutionIDPropagation(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name        string
+		executionID string
+		want        string
+	}{
+		{name: "with_execution_id", executionID: "0b6f0b8e-52d4-4c4c-9a4e-3b1f3f5b0c2a", want: "0b6f0b8e-52d4-4c4c-9a4e-3b1f3f5b0c2a"},
+		{name: "without_execution_id", executionID: "", want: ""},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// arrange
+			var received string
+			server := httptest.NewServer(audriver.ExecutionIDMiddleware(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+				received, _ = audriver.GetExecutionID(r.Context())
+			})))
+			t.Cleanup(server.Close)
+			client := &http.Client{Transport: audriver.ExecutionIDTransport(nil)}
+
+			ctx := t.Context()
+			if tc.executionID != "" {
+				ctx = audriver.WithExecutionID(ctx, tc.executionID)
+			}
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+			require.NoError(t, err)
+
+			// act
+			res, err := client.Do(req)
+			require.NoError(t, err)
+			_ = res.Body.Close()
+
+			// assert
+			assert.Equal(t, tc.want, received)
+			assert.Empty(t, req.Header.Get(audriver.ExecutionIDHeader), "the original request should not be modified")
+		})
+	}
+}
+
+// TestExecutionIDMiddleware tests that execution IDs of incoming requests are validated
+func TestExecutionIDMiddleware(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name    string
+		header  string
+		want    string
+		present bool
+	}{
+		{name: "uuid", header: "0b6f0b8e-52d4-4c4c-9a4e-3b1f3f5b0c2a", want: "0b6f0b8e-52d4-4c4c-9a4e-3b1f3f5b0c2a", present: true},
+		{name: "uuid_normalized", header: "{0B6F0B8E-52D4-4C4C-9A4E-3B1F3F5B0C2A}", want: "0b6f0b8e-52d4-4c4c-9a4e-3b1f3f5b0c2a", present: true},
+		{name: "not_uuid", header: "exec-1", present: true},
+		{name: "injection", header: "'; DROP TABLE database_modifications; --", present: true},
+		{name: "missing", header: ""},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// arrange
+			var (
+				received string
+				err      error
+			)
+			handler := audriver.ExecutionIDMiddleware(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+				received, err = audriver.GetExecutionID(r.Context())
+			}))
+			req := httptest.NewRequestWithContext(t.Context(), http.MethodGet, "/", nil)
+			if tc.header != "" {
+				req.Header.Set(audriver.ExecutionIDHeader, tc.header)
+			}
+
+			// act
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			// assert
+			if !tc.present {
+				assert.ErrorIs(t, err, audriver.ErrMissingExecutionID)
+				return
+			}
+			require.NoError(t, err)
+			if tc.want != "" {
+				assert.Equal(t, tc.want, received)
+				return
+			}
+			_, parseErr := uuid.Parse(received)
+			assert.NoError(t, parseErr, "invalid execution IDs should be replaced by a new UUID")
+			assert.NotEqual(t, tc.header, received)
+		})
+	}
+}
+
+// TestExecutionIDMetadata tests the gRPC metadata of the execution ID
+func TestExecutionIDMetadata(t *testing.T) {
+	t.Parallel()
+
+	// act
+	withID := audriver.ExecutionIDMetadata(audriver.WithExecutionID(t.Context(), "exec-1"))
+	withoutID := audriver.ExecutionIDMetadata(t.Context())
+
+	// assert
+	assert.Equal(t, []string{audriver.ExecutionIDMetadataKey, "exec-1"}, withID)
+	assert.Empty(t, withoutID)
+}
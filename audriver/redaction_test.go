@@ -0,0 +1,216 @@
+package audriver_test
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mickamy/go-sql-audit-driver/audriver"
+)
+
+// TestRedactionRules tests redacting arguments and literals matching each rule, keeping values matching none of them
+func TestRedactionRules(t *testing.T) {
+	t.Parallel()
+
+	rules := audriver.RedactionRules{
+		{Pattern: regexp.MustCompile(`[\w.+-]+@[\w-]+\.[\w.]+`), Replacement: "[EMAIL]"},
+		{Pattern: regexp.MustCompile(`\b\d{4}-?\d{4}-?\d{4}-?\d{4}\b`)},
+	}
+
+	testCases := []struct {
+		name     string
+		sql      string
+		args     []any
+		expected string
+	}{
+		{
+			name:     "email_arg",
+			sql:      "UPDATE users SET email = $1",
+			args:     []any{"john@example.com"},
+			expected: "UPDATE users SET email = '[EMAIL]'",
+		},
+		{
+			name:     "email_literal",
+			sql:      "UPDATE users SET email = 'john@example.com'",
+			expected: "UPDATE users SET email = '[EMAIL]'",
+		},
+		{
+			name:     "email_within_value",
+			sql:      "UPDATE users SET note = $1",
+			args:     []any{"reach john@example.com after 5pm"},
+			expected: "UPDATE users SET note = 'reach [EMAIL] after 5pm'",
+		},
+		{
+			name:     "email_not_matching",
+			sql:      "UPDATE users SET email = $1 WHERE name = 'john at example'",
+			args:     []any{"john@localhost"},
+			expected: "UPDATE users SET email = 'john@localhost' WHERE name = 'john at example'",
+		},
+		{
+			name:     "email_in_comment",
+			sql:      "DELETE FROM users /* requested by john@example.com */",
+			expected: "DELETE FROM users /* requested by john@example.com */",
+		},
+		{
+			name:     "card_arg",
+			sql:      "INSERT INTO payments (card) VALUES ($1)",
+			args:     []any{"4111-1111-1111-1111"},
+			expected: "INSERT INTO payments (card) VALUES ('[REDACTED]')",
+		},
+		{
+			name:     "card_numeric_arg",
+			sql:      "INSERT INTO payments (card) VALUES ($1)",
+			args:     []any{int64(4111111111111111)},
+			expected: "INSERT INTO payments (card) VALUES ('[REDACTED]')",
+		},
+		{
+			name:     "card_numeric_literal",
+			sql:      "INSERT INTO payments (card) VALUES (4111111111111111)",
+			expected: "INSERT INTO payments (card) VALUES ('[REDACTED]')",
+		},
+		{
+			name:     "card_not_matching",
+			sql:      "UPDATE payments SET amount = $1 WHERE reference = '4111-1111'",
+			args:     []any{int64(4111)},
+			expected: "UPDATE payments SET amount = '4111' WHERE reference = '4111-1111'",
+		},
+		{
+			name:     "all_rules",
+			sql:      "UPDATE payments SET note = $1",
+			args:     []any{"john@example.com paid with 4111111111111111"},
+			expected: "UPDATE payments SET note = '[EMAIL] paid with [REDACTED]'",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := t.Context()
+			ctx = audriver.WithOperatorID(ctx, uuid.New().String())
+			ctx = audriver.WithExecutionID(ctx, uuid.New().String())
+
+			// arrange
+			baseDriver := &fakeDriver{}
+			db := setUpFakeTestDB(t, baseDriver, audriver.WithAnonymizer(rules))
+
+			// act
+			_, err := db.ExecContext(ctx, tc.sql, tc.args...)
+
+			// assert
+			require.NoError(t, err)
+			inserts := baseDriver.auditInserts()
+			require.Len(t, inserts, 1)
+			assert.Equal(t, tc.expected, inserts[0].value("sql"))
+		})
+	}
+}
+
+// TestNewRedactionRule tests compiling the patterns of redaction rules
+func TestNewRedactionRule(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name     string
+		pattern  string
+		value    string
+		expected string
+		wantErr  bool
+	}{
+		{name: "matching", pattern: `secret-\w+`, value: "token secret-abc", expected: "token [REDACTED]"},
+		{name: "not_matching", pattern: `secret-\w+`, value: "token public-abc", expected: "token public-abc"},
+		{name: "invalid", pattern: `secret-(`, wantErr: true},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// act
+			rule, err := audriver.NewRedactionRule(tc.pattern, "")
+
+			// assert
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, audriver.RedactionRules{rule}.Anonymize(tc.value))
+		})
+	}
+}
+
+// TestCardNumberRedaction tests redacting card numbers passing the Luhn check only
+func TestCardNumberRedaction(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name     string
+		value    string
+		expected string
+	}{
+		{name: "digits", value: "4111111111111111", expected: "[REDACTED]"},
+		{name: "grouped_by_spaces", value: "4111 1111 1111 1111", expected: "[REDACTED]"},
+		{name: "grouped_by_hyphens", value: "paid with 3782-822463-10005", expected: "paid with [REDACTED]"},
+		{name: "luhn_invalid", value: "4111111111111112", expected: "4111111111111112"},
+		{name: "too_short", value: "411111111111", expected: "411111111111"},
+		{name: "too_long", value: "12345678901234567897", expected: "12345678901234567897"},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// act
+			got := audriver.CardNumberRedaction().Anonymize(tc.value)
+
+			// assert
+			assert.Equal(t, tc.expected, got)
+		})
+	}
+}
+
+// TestReloadableRedactionRules tests that reloaded rules replace the current rules
+func TestReloadableRedactionRules(t *testing.T) {
+	t.Parallel()
+
+	// arrange
+	var (
+		rules   audriver.RedactionRules
+		loadErr error
+	)
+	source := audriver.RedactionRuleSourceFunc(func(ctx context.Context) (audriver.RedactionRules, error) {
+		return rules, loadErr
+	})
+
+	rules = audriver.RedactionRules{{Pattern: regexp.MustCompile(`\d+`)}}
+	redaction, err := audriver.NewReloadableRedactionRules(t.Context(), source)
+	require.NoError(t, err)
+	assert.Equal(t, "card [REDACTED]", redaction.Anonymize("card 4111"))
+	assert.Equal(t, "mail john@example.com", redaction.Anonymize("mail john@example.com"))
+
+	// act
+	rules = audriver.RedactionRules{{Pattern: regexp.MustCompile(`\S+@\S+`), Replacement: "[EMAIL]"}}
+	err = redaction.Reload(t.Context())
+
+	// assert
+	require.NoError(t, err)
+	assert.Equal(t, "card 4111", redaction.Anonymize("card 4111"))
+	assert.Equal(t, "mail [EMAIL]", redaction.Anonymize("mail john@example.com"))
+
+	// act - failed reloads keep the current rules
+	loadErr = errors.New("source unavailable")
+	err = redaction.Reload(t.Context())
+
+	// assert
+	require.Error(t, err)
+	assert.Equal(t, "mail [EMAIL]", redaction.Anonymize("mail john@example.com"))
+}
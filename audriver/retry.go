@@ -0,0 +1,63 @@
+package audriver
+
+import (
+	"context"
+	"time"
+)
+
+// AuditRetry configures retries of audit writes of statements executed outside of transactions, by Hooks, by
+// Job.Checkpoint, and of batches of WithBatchWindow. Statements outside of transactions are executed in a transaction
+// of their own, in which each retried insert is made behind a savepoint, as PostgreSQL aborts a transaction on its
+// first error. Audit writes within the application's transactions are not retried; retry the whole transaction instead.
+type AuditRetry struct {
+	// MaxRetries is the number of retries after the first attempt fails.
+	MaxRetries int
+
+	// Backoff is the delay before the first retry, doubled for each further retry. It defaults to 50ms.
+	Backoff time.Duration
+
+	// MaxBackoff caps the delay between retries, if set.
+	MaxBackoff time.Duration
+
+	// Retryable reports whether a failed write is retried. It defaults to AuditRetryable.
+	Retryable func(err error) bool
+}
+
+func (r AuditRetry) withDefaults() AuditRetry {
+	if r.Backoff <= 0 {
+		r.Backoff = 50 * time.Millisecond
+	}
+	if r.Retryable == nil {
+		r.Retryable = AuditRetryable
+	}
+	return r
+}
+
+// retryWrite calls write until it succeeds, fails with an error that is not retryable, or retries are exhausted.
+// It returns the error of the last attempt, or of the last attempt before ctx is done.
+func (b *databaseModificationBuilder) retryWrite(ctx context.Context, write func() error) error {
+	err := write()
+	if b.retry == nil {
+		return err
+	}
+
+	backoff := b.retry.Backoff
+	for retries := 0; err != nil && retries < b.retry.MaxRetries && b.retry.Retryable(err); retries++ {
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+
+		b.stats.retriedWrites.Add(1)
+		err = write()
+
+		backoff *= 2
+		if b.retry.MaxBackoff > 0 {
+			backoff = min(backoff, b.retry.MaxBackoff)
+		}
+	}
+	return err
+}
@@ -0,0 +1,95 @@
+package audriver
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// QueryRewriter rewrites statements before they are executed, e.g. to inject hints or enforce LIMITs.
+type QueryRewriter interface {
+	RewriteQuery(ctx context.Context, query string) (string, error)
+}
+
+// QueryRewriterFunc is a function type that implements the QueryRewriter interface.
+type QueryRewriterFunc func(ctx context.Context, query string) (string, error)
+
+func (f QueryRewriterFunc) RewriteQuery(ctx context.Context, query string) (string, error) {
+	return f(ctx, query)
+}
+
+// ExecutionIDCommentKey is the key of the execution ID comment appended to statements by WithLogCorrelation.
+const ExecutionIDCommentKey = "audriver_execution_id"
+
+// rewrite applies the configured query rewriters to query in order.
+func (b *databaseModificationBuilder) rewrite(ctx context.Context, query string) (_ string, err error) {
+	defer recoverPanic(&err)
+
+	for _, rewriter := range b.rewriters {
+		rewritten, err := rewriter.RewriteQuery(ctx, query)
+		if err != nil {
+			return "", fmt.Errorf("failed to rewrite query: %w", err)
+		}
+		query = rewritten
+	}
+	return query, nil
+}
+
+// annotate appends comments carrying audit identifiers to query after its modifications are built,
+// so that the comments are not recorded: the operator and execution IDs of ctx if query annotation is enabled,
+// and the execution ID of mods if log correlation is enabled.
+// A panic of an extractor leaves query unannotated.
+func (b *databaseModificationBuilder) annotate(ctx context.Context, query string, mods []DatabaseModification) (annotated string) {
+	defer func() {
+		if r := recover(); r != nil {
+			annotated = query
+		}
+	}()
+
+	if b.queryAnnotation {
+		commenter := sqlCommenter{
+			operatorIDExtractor:  b.operatorIDExtractor,
+			executionIDExtractor: b.executionIDExtractor,
+		}
+		query, _ = commenter.RewriteQuery(ctx, query)
+	}
+	if b.logCorrelation && len(mods) > 0 {
+		query += " /* " + ExecutionIDCommentKey + "=" + sanitizeComment(mods[0].ExecutionID) + " */"
+	}
+	return query
+}
+
+// sqlCommenter is a QueryRewriter appending the operator and execution IDs of the context
+// as a sqlcommenter comment, e.g. /*execution_id='...',operator_id='...'*/.
+type sqlCommenter struct {
+	operatorIDExtractor  OperatorIDExtractor
+	executionIDExtractor ExecutionIDExtractor
+}
+
+// RewriteQuery appends the comment to query. Queries are left unchanged if the context carries neither ID.
+func (c sqlCommenter) RewriteQuery(ctx context.Context, query string) (string, error) {
+	var pairs []string
+	if executionID, err := c.executionIDExtractor.ExtractExecutionID(ctx); err == nil && executionID != "" {
+		pairs = append(pairs, "execution_id="+sqlCommentValue(executionID))
+	}
+	if operatorID, err := c.operatorIDExtractor.ExtractOperatorID(ctx); err == nil && operatorID != "" {
+		pairs = append(pairs, "operator_id="+sqlCommentValue(operatorID))
+	}
+	if len(pairs) == 0 {
+		return query, nil
+	}
+	return query + " /*" + strings.Join(pairs, ",") + "*/", nil
+}
+
+// sqlCommentValue URL-encodes and quotes a value as specified by sqlcommenter.
+func sqlCommentValue(value string) string {
+	return "'" + strings.ReplaceAll(url.QueryEscape(value), "+", "%20") + "'"
+}
+
+// sanitizeComment removes sequences that would terminate a SQL block comment.
+func sanitizeComment(s string) string {
+	return strings.ReplaceAll(s, "*/", "")
+}
+
+var _ QueryRewriter = sqlCommenter{}
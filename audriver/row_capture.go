@@ -0,0 +1,267 @@
+package audriver
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/mickamy/go-sql-audit-driver/internal/postgres"
+)
+
+// RowCaptureTruncatedMetadataKey is the key of the metadata marking modifications of more rows than row capture keeps.
+const RowCaptureTruncatedMetadataKey = "row_capture_truncated"
+
+// maxCapturedRows is the number of rows row capture keeps of each statement.
+const maxCapturedRows = 1000
+
+// errRowCaptureUnsupported is returned for statements whose rows cannot be selected by their target table alone.
+var errRowCaptureUnsupported = errors.New("row capture does not support statements reading other tables")
+
+// execCapturingRows executes query on conn like execConverted, capturing the rows an UPDATE or DELETE statement
+// modifies into mods if WithRowCapture is enabled: old values are selected before the statement, and new values of
+// updates are returned with RETURNING *, or selected after the statement by dialects without RETURNING.
+// Failures to capture are reported to the error handler without failing the statement.
+func (b *databaseModificationBuilder) execCapturingRows(ctx context.Context, conn driver.Conn, query string, args []driver.NamedValue, mods []DatabaseModification) (driver.Result, []driver.NamedValue, error) {
+	if !b.rowCapture || len(mods) == 0 || mods[0].Action != DatabaseModificationActionUpdate && mods[0].Action != DatabaseModificationActionDelete {
+		return execConverted(ctx, conn, b.annotate(ctx, query, mods), args)
+	}
+	update := mods[0].Action == DatabaseModificationActionUpdate
+
+	selectQuery, hasReturning, err := rowSelect(b.dialect, b.dialect.interpolate(query, args))
+	if err != nil {
+		b.handleError(ctx, err, ErrorStageBuild, mods)
+		return execConverted(ctx, conn, b.annotate(ctx, query, mods), args)
+	}
+	// statements with a RETURNING clause of their own are executed as they are
+	returning := !hasReturning && b.dialect != MySQL
+
+	var oldValues, newValues *capturedRows
+	selectOld := func() {
+		if oldValues, err = b.queryCapturedRows(ctx, conn, selectQuery+b.dialect.lockRows(), nil); err != nil {
+			b.handleError(ctx, fmt.Errorf("failed to capture rows: %w", err), ErrorStageBuild, mods)
+		}
+	}
+	if update || !returning {
+		selectOld()
+	}
+
+	var (
+		res       driver.Result
+		converted []driver.NamedValue
+	)
+	if returning {
+		var rows *capturedRows
+		rows, err = b.queryCapturedRows(ctx, conn, b.annotate(ctx, withReturning(b.dialect, query), mods), args)
+		switch {
+		case errors.Is(err, driver.ErrSkip) || errors.Is(err, ErrUnsupportedConn):
+			// executed without RETURNING below
+			returning = false
+			if !update {
+				selectOld()
+			}
+		case err != nil:
+			return nil, nil, err
+		case update:
+			res, newValues = driver.RowsAffected(rows.count), rows
+		default:
+			res, oldValues = driver.RowsAffected(rows.count), rows
+		}
+	}
+	if !returning {
+		if res, converted, err = execConverted(ctx, conn, b.annotate(ctx, query, mods), args); err != nil {
+			return res, converted, err
+		}
+		if update {
+			// rows no longer matching the WHERE clause after the update are missed
+			if newValues, err = b.queryCapturedRows(ctx, conn, selectQuery, nil); err != nil {
+				b.handleError(ctx, fmt.Errorf("failed to capture rows: %w", err), ErrorStageBuild, mods)
+			}
+		}
+	}
+
+	for i := range mods {
+		if oldValues != nil {
+			mods[i].OldValues = oldValues.values
+		}
+		if newValues != nil {
+			mods[i].NewValues = newValues.values
+		}
+		if oldValues != nil && oldValues.truncated || newValues != nil && newValues.truncated {
+			mods[i].SetMetadata(RowCaptureTruncatedMetadataKey, true)
+		}
+	}
+	return res, converted, nil
+}
+
+// capturedRows are rows read by row capture.
+type capturedRows struct {
+	// values is the JSON array of the first maxCapturedRows rows, as objects keyed by column.
+	values json.RawMessage
+
+	// count is the number of rows read, and truncated whether it exceeds maxCapturedRows.
+	count     int64
+	truncated bool
+}
+
+// queryCapturedRows runs query on conn and reads its rows.
+func (b *databaseModificationBuilder) queryCapturedRows(ctx context.Context, conn driver.Conn, query string, args []driver.NamedValue) (*capturedRows, error) {
+	queryCtx, ok := conn.(driver.QueryerContext)
+	if !ok {
+		return nil, fmt.Errorf("%w: QueryContext is not supported", ErrUnsupportedConn)
+	}
+	rows, err := queryCtx.QueryContext(ctx, query, args)
+	if err != nil {
+		return nil, err
+	}
+	defer func(rows driver.Rows) {
+		_ = rows.Close()
+	}(rows)
+
+	var (
+		columns  = rows.Columns()
+		values   = make([]driver.Value, len(columns))
+		objects  = make([]map[string]any, 0)
+		captured = &capturedRows{}
+	)
+	for {
+		if err := rows.Next(values); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, err
+		}
+		captured.count++
+		if len(objects) == maxCapturedRows {
+			captured.truncated = true
+			continue
+		}
+		object := make(map[string]any, len(columns))
+		for i, column := range columns {
+			object[column] = b.capturedValue(values[i])
+		}
+		objects = append(objects, object)
+	}
+
+	if captured.values, err = json.Marshal(objects); err != nil {
+		return nil, fmt.Errorf("failed to encode rows: %w", err)
+	}
+	return captured, nil
+}
+
+// capturedValue returns v as it is encoded into captured rows: text as strings, and all values replaced with fakes
+// if an anonymizer is set.
+func (b *databaseModificationBuilder) capturedValue(v driver.Value) any {
+	if v == nil {
+		return nil
+	}
+	if text, ok := v.([]byte); ok && utf8.Valid(text) {
+		v = string(text)
+	}
+	if b.anonymizer != nil {
+		return b.anonymizer.Anonymize(asString(v))
+	}
+	return v
+}
+
+// rowSelect returns the SELECT of the rows an interpolated UPDATE or DELETE statement modifies, from its target
+// table and its WHERE, ORDER BY, and LIMIT clauses, and reports whether the statement has a RETURNING clause.
+// Statements reading other tables, e.g. UPDATE ... FROM, DELETE ... USING, joins, or WITH queries, are not supported.
+func rowSelect(dialect Dialect, sql string) (string, bool, error) {
+	parsed := dialect.normalize(sql)
+	ta, err := parseTableAction(parsed)
+	if err != nil {
+		return "", false, err
+	}
+	masked := maskLiteralContents(parsed)
+	end := len(strings.TrimRight(masked, " \t\r\n;"))
+	masked = masked[:end]
+
+	verb, verbEnd := readWord(masked, skipLeadingComments(masked))
+	var targetStart, aliasEnd, clauseStart int
+	switch strings.ToUpper(verb) {
+	case "DELETE":
+		from := indexTopLevelKeyword(masked[verbEnd:ta.end], "FROM")
+		if from < 0 {
+			return "", false, errRowCaptureUnsupported
+		}
+		targetStart = verbEnd + from + len("FROM")
+		aliasEnd = ta.end + indexFirstKeyword(masked[ta.end:], "WHERE", "ORDER", "LIMIT", "RETURNING", "USING")
+		clauseStart = aliasEnd
+	case "UPDATE":
+		set := indexTopLevelKeyword(masked[ta.end:], "SET")
+		if set < 0 {
+			return "", false, errRowCaptureUnsupported
+		}
+		targetStart = verbEnd
+		aliasEnd = ta.end + set
+		clauseStart = aliasEnd + len("SET") + indexFirstKeyword(masked[aliasEnd+len("SET"):], "WHERE", "ORDER", "LIMIT", "RETURNING", "FROM")
+	default:
+		return "", false, errRowCaptureUnsupported
+	}
+	alias := masked[ta.end:aliasEnd]
+	if strings.ContainsRune(alias, ',') || indexTopLevelKeyword(alias, "JOIN") >= 0 ||
+		indexTopLevelKeyword(masked[clauseStart:], "USING") == 0 || indexTopLevelKeyword(masked[clauseStart:], "FROM") == 0 {
+		return "", false, errRowCaptureUnsupported
+	}
+
+	clauseEnd := end
+	returning := indexTopLevelKeyword(masked[clauseStart:], "RETURNING")
+	if returning >= 0 {
+		clauseEnd = clauseStart + returning
+	}
+	query := "SELECT * FROM " + strings.TrimSpace(sql[targetStart:aliasEnd]) + " " + strings.TrimSpace(sql[clauseStart:clauseEnd])
+	return strings.TrimSpace(query), returning >= 0, nil
+}
+
+// indexFirstKeyword returns the index of the first of keywords outside of quotes and parentheses, or len(sql).
+func indexFirstKeyword(sql string, keywords ...string) int {
+	first := len(sql)
+	for _, keyword := range keywords {
+		if i := indexTopLevelKeyword(sql, keyword); i >= 0 && i < first {
+			first = i
+		}
+	}
+	return first
+}
+
+// withReturning returns query with a RETURNING * clause, before its trailing semicolon and comments.
+func withReturning(dialect Dialect, query string) string {
+	masked := maskLiteralContents(dialect.normalize(query))
+	end := len(strings.TrimRight(masked, " \t\r\n;"))
+	return query[:end] + " RETURNING *" + query[end:]
+}
+
+// maskLiteralContents returns sql with comments and the contents of string literals and dollar-quoted strings replaced
+// by spaces, like maskLiterals, but with literals kept quoted with single quotes, so that clauses ending with a literal
+// end with its closing quote and literals can be skipped by indexTopLevelKeyword.
+func maskLiteralContents(sql string) string {
+	var masked []byte
+	for i := 0; i < len(sql); i++ {
+		end := postgres.SkipLiteral(sql, i)
+		if end == i || sql[i] == '"' || sql[i] == '`' {
+			if end > i {
+				i = end - 1
+			}
+			continue
+		}
+		if masked == nil {
+			masked = []byte(sql)
+		}
+		for j := i; j < end; j++ {
+			masked[j] = ' '
+		}
+		if (sql[i] == '\'' || sql[i] == '$') && end-i >= 2 && sql[end-1] == sql[i] {
+			masked[i], masked[end-1] = '\'', '\''
+		}
+		i = end - 1
+	}
+	if masked == nil {
+		return sql
+	}
+	return string(masked)
+}
@@ -0,0 +1,106 @@
+package audriver
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+)
+
+type rowEstimateApprovalKey struct{}
+
+// WithRowEstimateApproval returns a context approving UPDATE and DELETE statements executed with it
+// to affect more rows than the threshold of WithRowEstimateGuard.
+func WithRowEstimateApproval(ctx context.Context) context.Context {
+	return context.WithValue(ctx, rowEstimateApprovalKey{}, true)
+}
+
+// IsRowEstimateApproved reports whether ctx approves statements exceeding the threshold of WithRowEstimateGuard.
+func IsRowEstimateApproved(ctx context.Context) bool {
+	approved, _ := ctx.Value(rowEstimateApprovalKey{}).(bool)
+	return approved
+}
+
+// explainPlan is the part of the output of EXPLAIN (FORMAT JSON) read for row estimates.
+type explainPlan struct {
+	NodeType string        `json:"Node Type"`
+	PlanRows float64       `json:"Plan Rows"`
+	Plans    []explainPlan `json:"Plans"`
+}
+
+// guardRowEstimate estimates the rows an UPDATE or DELETE statement affects with EXPLAIN, records the estimate on mods,
+// and returns an error wrapping ErrRowEstimateExceeded if it exceeds the threshold and ctx is not approved.
+// Failures to estimate are reported to the error handler without blocking the statement. Within transactions, the
+// statement is explained behind a savepoint, as PostgreSQL aborts transactions on errors.
+func (b *databaseModificationBuilder) guardRowEstimate(ctx context.Context, conn driver.Conn, inTx bool, query string, args []driver.NamedValue, mods []DatabaseModification) error {
+	if b.rowEstimateThreshold <= 0 || len(mods) == 0 {
+		return nil
+	}
+	if action := mods[0].Action; action != DatabaseModificationActionUpdate && action != DatabaseModificationActionDelete {
+		return nil
+	}
+
+	explain := estimateRows
+	if inTx {
+		explain = estimateRowsInTx
+	}
+	estimate, err := explain(ctx, conn, query, args)
+	if err != nil {
+		b.handleError(ctx, err, ErrorStageBuild, mods)
+		return nil
+	}
+	for i := range mods {
+		mods[i].EstimatedRows = estimate
+	}
+
+	if estimate > b.rowEstimateThreshold && !IsRowEstimateApproved(ctx) {
+		err := fmt.Errorf("%w: %s on %s is estimated to affect %d rows, more than %d", ErrRowEstimateExceeded, mods[0].Action, mods[0].TableName, estimate, b.rowEstimateThreshold)
+		b.handleError(ctx, err, ErrorStageBuild, mods)
+		return err
+	}
+	return nil
+}
+
+// rowEstimateSavepoint is the savepoint statements are explained behind within transactions.
+const rowEstimateSavepoint = "audriver_row_estimate"
+
+// estimateRowsInTx is estimateRows within a transaction, behind a savepoint rolled back to if explaining query fails,
+// so that the transaction is not aborted by a failure the statement would not have caused.
+func estimateRowsInTx(ctx context.Context, conn driver.Conn, query string, args []driver.NamedValue) (int64, error) {
+	var estimate int64
+	err := withSavepoint(ctx, conn, rowEstimateSavepoint, func() error {
+		var err error
+		estimate, err = estimateRows(ctx, conn, query, args)
+		return err
+	})
+	return estimate, err
+}
+
+// estimateRows returns the number of rows the planner estimates query to modify.
+func estimateRows(ctx context.Context, conn driver.Conn, query string, args []driver.NamedValue) (int64, error) {
+	values, err := queryRow(ctx, conn, "EXPLAIN (FORMAT JSON) "+query, args)
+	if err != nil {
+		return 0, fmt.Errorf("failed to explain statement: %w", err)
+	}
+	if len(values) == 0 {
+		return 0, fmt.Errorf("failed to explain statement: no plan")
+	}
+
+	var plans []struct {
+		Plan explainPlan `json:"Plan"`
+	}
+	if err := json.Unmarshal([]byte(asString(values[0])), &plans); err != nil {
+		return 0, fmt.Errorf("failed to parse plan: %w", err)
+	}
+	if len(plans) == 0 {
+		return 0, fmt.Errorf("failed to parse plan: no plan")
+	}
+
+	// the ModifyTable node estimates no rows since PostgreSQL 14 unless RETURNING is used; its input estimates the
+	// rows to modify
+	plan := plans[0].Plan
+	if plan.NodeType == "ModifyTable" && len(plan.Plans) > 0 {
+		plan = plan.Plans[0]
+	}
+	return int64(plan.PlanRows), nil
+}
@@ -0,0 +1,177 @@
+package audriver
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/mickamy/go-sql-audit-driver/internal/postgres"
+)
+
+// DefaultAuditTable is the table audit records are written to unless WithAuditTable is used.
+const DefaultAuditTable = "database_modifications"
+
+// SchemaConfig configures the audit schema generated by GenerateSchemaSQL.
+type SchemaConfig struct {
+	// Table is the audit table, optionally schema-qualified. It defaults to DefaultAuditTable
+	// and must match the table passed to WithAuditTable.
+	Table string
+
+	// OptionalColumns are the optional columns to create, e.g. record_ids or exactness. If nil, all optional columns
+	// are created. Optional columns are only written for records with a value, so omitted columns are fine as long as
+	// no enabled option populates them; writes of records with a value for an omitted column fail.
+	OptionalColumns []string
+
+	// AuditRole is a role to create and grant INSERT on the table, for use with WithAuditRole.
+	AuditRole string
+
+	// Writers are the application roles writing audit records. They are granted membership in AuditRole
+	// if it is set, or INSERT on the table otherwise.
+	Writers []string
+
+	// Readers are roles granted SELECT on the table, e.g. for exports and dashboards.
+	Readers []string
+}
+
+// auditActionType is the enum type of the action column created by GenerateSchemaSQL.
+const auditActionType = "database_modification_action"
+
+// auditActions are the values of auditActionType.
+var auditActions = []DatabaseModificationAction{
+	DatabaseModificationActionInsert,
+	DatabaseModificationActionUpdate,
+	DatabaseModificationActionDelete,
+	DatabaseModificationActionUnknown,
+}
+
+// auditIndexColumns are the columns of the audit table indexed by GenerateSchemaSQL.
+var auditIndexColumns = []string{"operator_id", "execution_id", "table_name", "modified_at"}
+
+// GenerateSchemaSQL generates PostgreSQL SQL creating the action enum type, the audit table, its indexes, and role
+// grants, so that infrastructure pipelines can own the schema, e.g. as the up migration of golang-migrate.
+// The action column has the database_modification_action enum type of postgres/01_create_database_modification_action.sql,
+// created in the current schema, so generate one audit table per schema.
+func GenerateSchemaSQL(cfg SchemaConfig) (string, error) {
+	table := schemaTable(cfg)
+	columns, err := schemaColumns(cfg.OptionalColumns)
+	if err != nil {
+		return "", err
+	}
+
+	width := 0
+	for _, column := range columns {
+		width = max(width, len(column.name))
+	}
+
+	var b strings.Builder
+	_, _ = fmt.Fprintf(&b, "CREATE TYPE %s AS ENUM (\n", auditActionType)
+	for i, action := range auditActions {
+		_, _ = fmt.Fprintf(&b, "    %s", postgres.QuoteLiteral(action.String()))
+		if i < len(auditActions)-1 {
+			b.WriteString(",")
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString(");\n\n")
+	_, _ = fmt.Fprintf(&b, "CREATE TABLE %s\n(\n", table)
+	for i, column := range columns {
+		_, _ = fmt.Fprintf(&b, "    %-*s %s", width, column.name, column.definition)
+		if i < len(columns)-1 {
+			b.WriteString(",")
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString(");\n\n")
+
+	indexPrefix := "idx_" + table[strings.LastIndex(table, ".")+1:] + "_"
+	for _, column := range auditIndexColumns {
+		_, _ = fmt.Fprintf(&b, "CREATE INDEX %s%s ON %s (%s);\n", indexPrefix, column, table, column)
+	}
+	if slices.ContainsFunc(columns, func(column auditColumn) bool { return column.name == "shard_key" }) {
+		// tenant-scoped audit queries filter by shard key
+		_, _ = fmt.Fprintf(&b, "CREATE INDEX %sshard_key ON %s (shard_key);\n", indexPrefix, table)
+	}
+	if slices.ContainsFunc(columns, func(column auditColumn) bool { return column.name == "parent_execution_id" }) {
+		// hierarchical audit queries look up child executions by their parent
+		_, _ = fmt.Fprintf(&b, "CREATE INDEX %sparent_execution_id ON %s (parent_execution_id);\n", indexPrefix, table)
+	}
+	if slices.ContainsFunc(columns, func(column auditColumn) bool { return column.name == "checksum" }) {
+		// sinks deduplicate and verify records by their checksum
+		_, _ = fmt.Fprintf(&b, "CREATE INDEX %schecksum ON %s (checksum);\n", indexPrefix, table)
+	}
+
+	grants := make([]string, 0, len(cfg.Writers)+len(cfg.Readers)+2)
+	writers := quoteIdentifiers(cfg.Writers)
+	if cfg.AuditRole != "" {
+		role := postgres.QuoteIdentifier(cfg.AuditRole)
+		grants = append(grants,
+			fmt.Sprintf("CREATE ROLE %s NOLOGIN;", role),
+			fmt.Sprintf("GRANT INSERT ON %s TO %s;", table, role),
+		)
+		if len(writers) > 0 {
+			grants = append(grants, fmt.Sprintf("GRANT %s TO %s;", role, strings.Join(writers, ", ")))
+		}
+	} else if len(writers) > 0 {
+		grants = append(grants, fmt.Sprintf("GRANT INSERT ON %s TO %s;", table, strings.Join(writers, ", ")))
+	}
+	if len(cfg.Readers) > 0 {
+		grants = append(grants, fmt.Sprintf("GRANT SELECT ON %s TO %s;", table, strings.Join(quoteIdentifiers(cfg.Readers), ", ")))
+	}
+	if len(grants) > 0 {
+		b.WriteString("\n")
+		b.WriteString(strings.Join(grants, "\n"))
+		b.WriteString("\n")
+	}
+
+	return b.String(), nil
+}
+
+// GenerateSchemaDownSQL generates SQL reverting GenerateSchemaSQL, e.g. as the down migration of golang-migrate.
+// Dropping the audit table deletes all audit records.
+func GenerateSchemaDownSQL(cfg SchemaConfig) string {
+	var b strings.Builder
+	_, _ = fmt.Fprintf(&b, "DROP TABLE IF EXISTS %s;\n", schemaTable(cfg))
+	_, _ = fmt.Fprintf(&b, "DROP TYPE IF EXISTS %s;\n", auditActionType)
+	if cfg.AuditRole != "" {
+		_, _ = fmt.Fprintf(&b, "DROP ROLE IF EXISTS %s;\n", postgres.QuoteIdentifier(cfg.AuditRole))
+	}
+	return b.String()
+}
+
+func schemaTable(cfg SchemaConfig) string {
+	if cfg.Table == "" {
+		return DefaultAuditTable
+	}
+	return cfg.Table
+}
+
+// schemaColumns returns the required columns and the given optional columns, or all columns if optional is nil.
+func schemaColumns(optional []string) ([]auditColumn, error) {
+	if optional == nil {
+		return auditColumns, nil
+	}
+
+	for _, name := range optional {
+		if !slices.ContainsFunc(auditColumns, func(column auditColumn) bool {
+			return column.optional && column.name == name
+		}) {
+			return nil, fmt.Errorf("unknown optional audit column: %s", name)
+		}
+	}
+
+	columns := make([]auditColumn, 0, len(auditColumns))
+	for _, column := range auditColumns {
+		if !column.optional || slices.Contains(optional, column.name) {
+			columns = append(columns, column)
+		}
+	}
+	return columns, nil
+}
+
+func quoteIdentifiers(names []string) []string {
+	quoted := make([]string, len(names))
+	for i, name := range names {
+		quoted[i] = postgres.QuoteIdentifier(name)
+	}
+	return quoted
+}
@@ -0,0 +1,133 @@
+package audriver
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/mickamy/go-sql-audit-driver/internal/postgres"
+)
+
+// resolvedTable is the result of resolving a table against the search path.
+type resolvedTable struct {
+	schema  string
+	foreign bool
+}
+
+// schemaResolver resolves the schema of modified tables against a search path using the PostgreSQL catalog,
+// and detects modifications of foreign tables. Results are cached per driver.
+type schemaResolver struct {
+	// searchPath is the list of schemas to resolve unqualified tables against.
+	// If it is empty, the search path of the session is used.
+	searchPath []string
+
+	cache sync.Map
+}
+
+// resolve sets the schema and foreign table flag of mods, which all target the same table.
+func (r *schemaResolver) resolve(ctx context.Context, conn driver.Conn, mods []DatabaseModification) error {
+	if len(mods) == 0 {
+		return nil
+	}
+
+	resolved, err := r.lookup(ctx, conn, mods[0].SchemaName, mods[0].TableName)
+	if err != nil {
+		return fmt.Errorf("failed to resolve schema of table %s: %w", mods[0].TableName, err)
+	}
+
+	for i := range mods {
+		if resolved.schema != "" {
+			mods[i].SchemaName = resolved.schema
+		}
+		mods[i].Foreign = resolved.foreign
+	}
+
+	return nil
+}
+
+func (r *schemaResolver) lookup(ctx context.Context, conn driver.Conn, schema string, table string) (resolvedTable, error) {
+	key := schema + "." + table
+	if cached, ok := r.cache.Load(key); ok {
+		return cached.(resolvedTable), nil
+	}
+
+	var (
+		query string
+		args  []driver.NamedValue
+	)
+	switch {
+	case schema != "":
+		query = `SELECT n.nspname, c.relkind::text FROM pg_class c JOIN pg_namespace n ON n.oid = c.relnamespace
+WHERE c.relname = $1 AND n.nspname = $2`
+		args = []driver.NamedValue{{Ordinal: 1, Value: table}, {Ordinal: 2, Value: schema}}
+	case len(r.searchPath) > 0:
+		query = `SELECT n.nspname, c.relkind::text FROM pg_class c JOIN pg_namespace n ON n.oid = c.relnamespace
+WHERE c.relname = $1 AND n.nspname = ANY($2::text[])
+ORDER BY array_position($2::text[], n.nspname::text) LIMIT 1`
+		args = []driver.NamedValue{{Ordinal: 1, Value: table}, {Ordinal: 2, Value: postgres.FormatArray(r.searchPath)}}
+	default:
+		query = `SELECT n.nspname, c.relkind::text FROM pg_class c JOIN pg_namespace n ON n.oid = c.relnamespace
+WHERE c.relname = $1 AND n.nspname = ANY(current_schemas(false))
+ORDER BY array_position(current_schemas(false), n.nspname) LIMIT 1`
+		args = []driver.NamedValue{{Ordinal: 1, Value: table}}
+	}
+
+	row, err := queryRow(ctx, conn, query, args)
+	if err != nil {
+		return resolvedTable{}, err
+	}
+	if row == nil {
+		// unknown tables are not cached, since they may be created later
+		return resolvedTable{schema: schema}, nil
+	}
+
+	resolved := resolvedTable{
+		schema:  asString(row[0]),
+		foreign: asString(row[1]) == "f",
+	}
+	r.cache.Store(key, resolved)
+
+	return resolved, nil
+}
+
+// queryRow runs query on conn and returns the values of the first row, or nil if there are no rows.
+func queryRow(ctx context.Context, conn driver.Conn, query string, args []driver.NamedValue) ([]driver.Value, error) {
+	queryCtx, ok := conn.(driver.QueryerContext)
+	if !ok {
+		return nil, fmt.Errorf("%w: QueryContext is not supported", ErrUnsupportedConn)
+	}
+
+	rows, err := queryCtx.QueryContext(ctx, query, args)
+	if err != nil {
+		return nil, err
+	}
+	defer func(rows driver.Rows) {
+		_ = rows.Close()
+	}(rows)
+
+	values := make([]driver.Value, len(rows.Columns()))
+	if err := rows.Next(values); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return values, nil
+}
+
+func asString(v driver.Value) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	case nil:
+		return ""
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,132 @@
+package audriver_test
+
+import (
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mickamy/go-sql-audit-driver/audriver"
+)
+
+// TestGenerateSchemaSQL tests the generated audit schema SQL
+func TestGenerateSchemaSQL(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name        string
+		cfg         audriver.SchemaConfig
+		contains    []string
+		notContains []string
+		down        string
+	}{
+		{
+			name: "defaults",
+			cfg:  audriver.SchemaConfig{},
+			contains: []string{
+				"CREATE TABLE database_modifications\n(\n    id                  UUID NOT NULL PRIMARY KEY,\n",
+				"    exactness           VARCHAR(16) NOT NULL DEFAULT 'exact'\n);\n",
+				"CREATE INDEX idx_database_modifications_execution_id ON database_modifications (execution_id);",
+				"CREATE INDEX idx_database_modifications_shard_key ON database_modifications (shard_key);",
+			},
+			notContains: []string{"GRANT"},
+			down:        "DROP TABLE IF EXISTS database_modifications;\nDROP TYPE IF EXISTS database_modification_action;\n",
+		},
+		{
+			name: "custom_table_columns_and_roles",
+			cfg: audriver.SchemaConfig{
+				Table:           "audit.modifications",
+				OptionalColumns: []string{"record_ids"},
+				AuditRole:       "audriver_auditor",
+				Writers:         []string{"app"},
+				Readers:         []string{"auditor", "dashboard"},
+			},
+			contains: []string{
+				"CREATE TABLE audit.modifications\n",
+				"    modified_at  TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,\n    record_ids   TEXT[]\n);\n",
+				"CREATE INDEX idx_modifications_operator_id ON audit.modifications (operator_id);",
+				`CREATE ROLE "audriver_auditor" NOLOGIN;`,
+				`GRANT INSERT ON audit.modifications TO "audriver_auditor";`,
+				`GRANT "audriver_auditor" TO "app";`,
+				`GRANT SELECT ON audit.modifications TO "auditor", "dashboard";`,
+			},
+			notContains: []string{"exactness", "schema_name", "shard_key"},
+			down:        "DROP TABLE IF EXISTS audit.modifications;\nDROP TYPE IF EXISTS database_modification_action;\nDROP ROLE IF EXISTS \"audriver_auditor\";\n",
+		},
+		{
+			name: "writers_without_audit_role",
+			cfg: audriver.SchemaConfig{
+				Writers: []string{"app", "worker"},
+			},
+			contains: []string{
+				`GRANT INSERT ON database_modifications TO "app", "worker";`,
+			},
+			notContains: []string{"CREATE ROLE"},
+			down:        "DROP TABLE IF EXISTS database_modifications;\nDROP TYPE IF EXISTS database_modification_action;\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// act
+			up, err := audriver.GenerateSchemaSQL(tc.cfg)
+			down := audriver.GenerateSchemaDownSQL(tc.cfg)
+
+			// assert
+			require.NoError(t, err)
+			for _, s := range tc.contains {
+				assert.Contains(t, up, s)
+			}
+			for _, s := range tc.notContains {
+				assert.NotContains(t, up, s)
+			}
+			assert.Equal(t, tc.down, down)
+		})
+	}
+}
+
+// TestGenerateSchemaSQL_UnknownColumn tests that unknown optional columns are rejected
+func TestGenerateSchemaSQL_UnknownColumn(t *testing.T) {
+	t.Parallel()
+
+	// act
+	_, err := audriver.GenerateSchemaSQL(audriver.SchemaConfig{OptionalColumns: []string{"operator_id"}})
+
+	// assert
+	assert.ErrorContains(t, err, "unknown optional audit column: operator_id")
+}
+
+// TestGenerateSchemaSQL_ShippedDDL tests that the generated schema matches the PostgreSQL DDL shipped in postgres/
+func TestGenerateSchemaSQL_ShippedDDL(t *testing.T) {
+	t.Parallel()
+
+	// arrange
+	actionType, err := os.ReadFile("../postgres/01_create_database_modification_action.sql")
+	require.NoError(t, err)
+	table, err := os.ReadFile("../postgres/02_create_database_modifications.sql")
+	require.NoError(t, err)
+	// columns maps the column definitions of a CREATE TABLE statement to their type and constraints
+	columns := func(ddl string) map[string]string {
+		definitions := map[string]string{}
+		for _, match := range regexp.MustCompile(`(?m)^    (\w+)\s+(.+?),?$`).FindAllStringSubmatch(ddl, -1) {
+			definitions[match[1]] = strings.Join(strings.Fields(match[2]), " ")
+		}
+		return definitions
+	}
+
+	// act
+	up, err := audriver.GenerateSchemaSQL(audriver.SchemaConfig{})
+
+	// assert
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(up, string(actionType)+"\n"), up)
+	shipped, generated := columns(string(table)), columns(up[strings.Index(up, "CREATE TABLE"):])
+	require.Equal(t, "database_modification_action NOT NULL", shipped["action"])
+	assert.Equal(t, shipped, generated)
+}
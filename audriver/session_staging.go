@@ -0,0 +1,65 @@
+package audriver
+
+import (
+	"context"
+	"fmt"
+)
+
+// sessionStagingTable is the temporary table audit records of transactions are staged in with WithSessionStaging.
+const sessionStagingTable = "pg_temp.audriver_session_staging"
+
+// createSessionStaging creates the temporary staging table of the session within the transaction,
+// unless it already exists. Creating it as the audit role lets the audit role write to it and move from it.
+func (tx *loggingTx) createSessionStaging(ctx context.Context) error {
+	if tx.owner.sessionStagingCreated || tx.sessionStaged {
+		tx.sessionStaged = true
+		return nil
+	}
+
+	query := fmt.Sprintf("CREATE TEMPORARY TABLE IF NOT EXISTS audriver_session_staging (LIKE %s INCLUDING DEFAULTS)", tx.conn.builder.writeTable())
+	err := asAuditRole(ctx, tx.conn.Conn, tx.conn.builder.dialect, tx.auditRole, func() error {
+		_, err := execContext(ctx, tx.conn.Conn, query, nil)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create session staging table: %w", err)
+	}
+	tx.sessionStaged = true
+	return nil
+}
+
+// moveSessionStaging moves the staged audit records of committed transactions to the audit table in the background.
+// Other methods of the connection wait for the move, as the connection cannot be used concurrently.
+func (c *Conn) moveSessionStaging() {
+	c.sessionStagingCreated = true
+	c.sessionStagingPending = true
+	c.moves.Add(1)
+	go func() {
+		defer c.moves.Done()
+		// the transaction's context may be canceled once it is committed
+		ctx := context.Background()
+		if err := c.moveSessionStagingNow(ctx); err != nil {
+			c.builder.handleError(ctx, err, ErrorStageFlush, nil)
+		}
+	}()
+}
+
+// moveSessionStagingNow moves the staged audit records of committed transactions to the audit table.
+// Records failing to be moved stay staged and are moved with those of the next transaction.
+func (c *Conn) moveSessionStagingNow(ctx context.Context) error {
+	query := fmt.Sprintf(`WITH moved AS (DELETE FROM %s RETURNING *) INSERT INTO %s SELECT * FROM moved ON CONFLICT (id) DO NOTHING`, sessionStagingTable, c.builder.writeTable())
+	err := asAuditRole(ctx, c.Conn, c.builder.dialect, c.auditRole, func() error {
+		_, err := execContext(ctx, c.Conn, query, nil)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to move staged audit records: %w", classifyAuditError(err))
+	}
+	c.sessionStagingPending = false
+	return nil
+}
+
+// awaitSessionStaging waits for staged audit records to be moved before the connection is used again.
+func (c *Conn) awaitSessionStaging() {
+	c.moves.Wait()
+}
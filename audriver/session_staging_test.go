@@ -0,0 +1,54 @@
+package audriver_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mickamy/go-sql-audit-driver/audriver"
+)
+
+// TestAuditDriver_SessionStaging tests staging audit records of transactions and moving them after commit
+func TestAuditDriver_SessionStaging(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	ctx = audriver.WithOperatorID(ctx, uuid.New().String())
+	ctx = audriver.WithExecutionID(ctx, uuid.New().String())
+
+	// arrange
+	baseDriver := &fakeDriver{}
+	db := setUpFakeTestDB(t, baseDriver, audriver.WithSessionStaging(true))
+	db.SetMaxOpenConns(1)
+
+	// act
+	for i := 0; i < 2; i++ {
+		tx, err := db.BeginTx(ctx, nil)
+		require.NoError(t, err)
+		_, err = tx.ExecContext(ctx, "UPDATE users SET age = $1", i)
+		require.NoError(t, err)
+		require.NoError(t, tx.Commit())
+	}
+	_, err := db.ExecContext(ctx, "SELECT 1")
+	require.NoError(t, err)
+
+	// assert
+	var statements []string
+	for _, exec := range baseDriver.executed() {
+		statements = append(statements, strings.SplitN(exec.query, " (", 2)[0])
+	}
+	assert.Equal(t, []string{
+		"UPDATE users SET age = $1",
+		"CREATE TEMPORARY TABLE IF NOT EXISTS audriver_session_staging",
+		"INSERT INTO pg_temp.audriver_session_staging",
+		"WITH moved AS",
+		"UPDATE users SET age = $1",
+		"INSERT INTO pg_temp.audriver_session_staging",
+		"WITH moved AS",
+		"SELECT 1",
+	}, statements)
+	assert.Equal(t, "WITH moved AS (DELETE FROM pg_temp.audriver_session_staging RETURNING *) INSERT INTO database_modifications SELECT * FROM moved ON CONFLICT (id) DO NOTHING", baseDriver.executed()[3].query)
+}
@@ -0,0 +1,228 @@
+package audriver
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+)
+
+type shardKey struct{}
+
+// ErrUnknownShard is returned when a statement is routed to a shard the ShardRouter does not know.
+var ErrUnknownShard = errors.New("unknown shard")
+
+// WithShard returns a context routing statements executed with it to the given shard of a ShardRouter.
+// Statements of a transaction are routed to the shard the transaction was begun on.
+func WithShard(ctx context.Context, shard string) context.Context {
+	return context.WithValue(ctx, shardKey{}, shard)
+}
+
+// GetShard returns the shard set with WithShard, if any.
+func GetShard(ctx context.Context) (string, bool) {
+	shard, ok := ctx.Value(shardKey{}).(string)
+	return shard, ok && shard != ""
+}
+
+// ShardRouter is a single audit driver fronting several physical databases, e.g. of a sharded deployment.
+// The data source name passed to sql.Open names the default shard, and WithShard routes statements to other shards.
+// Audit records are written to the shard of the modification and tagged with its name.
+type ShardRouter struct {
+	driver *Driver
+	shards map[string]string
+}
+
+// NewShardRouter creates a driver routing statements to shards, given as shard names mapped to data source names
+// of d:
+//
+//	sql.Register("postgres-audit", audriver.NewShardRouter(&pq.Driver{}, map[string]string{
+//		"tokyo":  "postgres://tokyo.example.com/app",
+//		"oregon": "postgres://oregon.example.com/app",
+//	}))
+//	db, err := sql.Open("postgres-audit", "tokyo")
+func NewShardRouter(d driver.Driver, shards map[string]string, options ...Option) *ShardRouter {
+	router := &ShardRouter{
+		driver: newAuditDriver(d, options...),
+		shards: make(map[string]string, len(shards)),
+	}
+	for name, dsn := range shards {
+		router.shards[name] = dsn
+	}
+	return router
+}
+
+// Open opens a connection whose statements are routed to the shard name unless the context names another shard.
+// Connections to shards are opened when first used.
+func (r *ShardRouter) Open(name string) (driver.Conn, error) {
+	conn := &shardedConn{router: r, defaultShard: name, conns: make(map[string]*Conn)}
+	if _, err := conn.open(name); err != nil {
+		return nil, err
+	}
+	return conn, nil
+}
+
+// AuditStats returns the cumulative audit counters of all shards.
+func (r *ShardRouter) AuditStats() AuditStats {
+	return r.driver.AuditStats()
+}
+
+// shardedConn routes statements to connections to the shards of a ShardRouter.
+type shardedConn struct {
+	router       *ShardRouter
+	defaultShard string
+	conns        map[string]*Conn
+
+	// tx is the connection of the transaction in progress, if any.
+	tx *Conn
+}
+
+// route returns the connection statements executed with ctx are routed to.
+func (c *shardedConn) route(ctx context.Context) (*Conn, error) {
+	if c.tx != nil {
+		return c.tx, nil
+	}
+	shard, ok := GetShard(ctx)
+	if !ok {
+		shard = c.defaultShard
+	}
+	return c.open(shard)
+}
+
+func (c *shardedConn) open(shard string) (*Conn, error) {
+	if conn, ok := c.conns[shard]; ok {
+		return conn, nil
+	}
+
+	dsn, ok := c.router.shards[shard]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownShard, shard)
+	}
+	conn, err := c.router.driver.Open(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open shard %s: %w", shard, err)
+	}
+
+	auditConn := conn.(*Conn)
+	auditConn.shard = shard
+	c.conns[shard] = auditConn
+	return auditConn, nil
+}
+
+func (c *shardedConn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+func (c *shardedConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	conn, err := c.route(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return conn.PrepareContext(ctx, query)
+}
+
+func (c *shardedConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *shardedConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	conn, err := c.route(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tx, err := conn.BeginTx(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	c.tx = conn
+	return &shardedTx{Tx: tx, conn: c}, nil
+}
+
+func (c *shardedConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	conn, err := c.route(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return conn.ExecContext(ctx, query, args)
+}
+
+func (c *shardedConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	conn, err := c.route(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return conn.QueryContext(ctx, query, args)
+}
+
+// Close closes the connections to all shards.
+func (c *shardedConn) Close() error {
+	var errs []error
+	for _, conn := range c.conns {
+		errs = append(errs, conn.Close())
+	}
+	return errors.Join(errs...)
+}
+
+func (c *shardedConn) Ping(ctx context.Context) error {
+	conn, err := c.route(ctx)
+	if err != nil {
+		return err
+	}
+	return conn.Ping(ctx)
+}
+
+func (c *shardedConn) ResetSession(ctx context.Context) error {
+	for _, conn := range c.conns {
+		if err := conn.ResetSession(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *shardedConn) IsValid() bool {
+	for _, conn := range c.conns {
+		if !conn.IsValid() {
+			return false
+		}
+	}
+	return true
+}
+
+// CheckNamedValue delegates to the connection to the default shard, as arguments are checked without a context.
+func (c *shardedConn) CheckNamedValue(nv *driver.NamedValue) error {
+	return c.conns[c.defaultShard].CheckNamedValue(nv)
+}
+
+// shardedTx detaches a finished transaction from its sharded connection.
+type shardedTx struct {
+	driver.Tx
+	conn *shardedConn
+}
+
+func (tx *shardedTx) Commit() error {
+	defer func() {
+		tx.conn.tx = nil
+	}()
+	return tx.Tx.Commit()
+}
+
+func (tx *shardedTx) Rollback() error {
+	defer func() {
+		tx.conn.tx = nil
+	}()
+	return tx.Tx.Rollback()
+}
+
+var (
+	_ driver.Driver = (*ShardRouter)(nil)
+
+	_ driver.Conn               = (*shardedConn)(nil)
+	_ driver.ConnBeginTx        = (*shardedConn)(nil)
+	_ driver.ExecerContext      = (*shardedConn)(nil)
+	_ driver.QueryerContext     = (*shardedConn)(nil)
+	_ driver.ConnPrepareContext = (*shardedConn)(nil)
+	_ driver.Pinger             = (*shardedConn)(nil)
+	_ driver.SessionResetter    = (*shardedConn)(nil)
+	_ driver.Validator          = (*shardedConn)(nil)
+	_ driver.NamedValueChecker  = (*shardedConn)(nil)
+)
@@ -0,0 +1,189 @@
+package audriver
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// ShardKeyExtractor extracts the shard key of a modification, e.g. its tenant ID,
+// so that audit records can be queried per tenant efficiently.
+type ShardKeyExtractor interface {
+	ExtractShardKey(ctx context.Context, mod DatabaseModification) (string, error)
+}
+
+// ShardKeyExtractorFunc is a function type that implements the ShardKeyExtractor interface.
+type ShardKeyExtractorFunc func(ctx context.Context, mod DatabaseModification) (string, error)
+
+func (f ShardKeyExtractorFunc) ExtractShardKey(ctx context.Context, mod DatabaseModification) (string, error) {
+	return f(ctx, mod)
+}
+
+// ShardKeyColumn returns a ShardKeyExtractor reading the value of column from the statement of a modification:
+// the value inserted into column by INSERT ... VALUES, or the value column is compared to with = in the WHERE clause
+// of UPDATE and DELETE. Modifications of multi-row inserts take the key of their first row, unless they are split
+// with WithSplitMultiRowInserts. If the statement has no such value, the shard key is left empty.
+func ShardKeyColumn(column string) ShardKeyExtractor {
+	return ShardKeyExtractorFunc(func(_ context.Context, mod DatabaseModification) (string, error) {
+		switch mod.Action {
+		case DatabaseModificationActionInsert:
+			return insertedValue(mod.SQL, column), nil
+		case DatabaseModificationActionUpdate, DatabaseModificationActionDelete:
+			return comparedValue(mod.SQL, column), nil
+		default:
+			return "", nil
+		}
+	})
+}
+
+// extractShardKey sets the shard key of mods with the extractor of their table, if any.
+func (b *databaseModificationBuilder) extractShardKey(ctx context.Context, mods []DatabaseModification) error {
+	if len(mods) == 0 {
+		return nil
+	}
+	extractor, ok := b.shardKeyExtractors[mods[0].TableName]
+	if !ok {
+		return nil
+	}
+
+	for i := range mods {
+		key, err := extractor.ExtractShardKey(ctx, mods[i])
+		if err != nil {
+			return fmt.Errorf("failed to extract shard key: %w", err)
+		}
+		mods[i].ShardKey = key
+	}
+	return nil
+}
+
+// insertedValue returns the value inserted into column by the first row of an INSERT ... VALUES statement.
+func insertedValue(sql string, column string) string {
+	ta, err := parseTableAction(sql)
+	if err != nil {
+		return ""
+	}
+	open := skipSpaces(sql, ta.end)
+	if open >= len(sql) || sql[open] != '(' {
+		return ""
+	}
+	closing := indexClosingParen(sql, open)
+	if closing < 0 {
+		return ""
+	}
+
+	index := -1
+	for i, name := range splitTopLevel(sql[open+1 : closing]) {
+		if identifier, _ := readIdentifier(name, skipSpaces(name, 0)); strings.EqualFold(identifier, column) {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		return ""
+	}
+
+	valuesIndex := indexTopLevelKeyword(sql[closing+1:], "VALUES")
+	if valuesIndex < 0 {
+		return ""
+	}
+	row := skipSpaces(sql, closing+1+valuesIndex+len("VALUES"))
+	if row >= len(sql) || sql[row] != '(' {
+		return ""
+	}
+	rowClosing := indexClosingParen(sql, row)
+	if rowClosing < 0 {
+		return ""
+	}
+	values := splitTopLevel(sql[row+1 : rowClosing])
+	if index >= len(values) {
+		return ""
+	}
+	return literalValue(values[index])
+}
+
+// comparedValue returns the value column is compared to with = in the WHERE clause of the statement.
+// The column may be qualified with a table or alias.
+func comparedValue(sql string, column string) string {
+	where := indexTopLevelKeyword(sql, "WHERE")
+	if where < 0 {
+		return ""
+	}
+
+	for i := where + len("WHERE"); i < len(sql); {
+		switch c := sql[i]; {
+		case c == '\'':
+			i = skipQuoted(sql, i) + 1
+		case c == '"' || c == '`' || isWordChar(c) && isWordBoundary(sql, i-1):
+			identifier, end := readIdentifier(sql, i)
+			if end == i {
+				i++
+				continue
+			}
+			name := identifier[strings.LastIndexByte(identifier, '.')+1:]
+			if j := skipSpaces(sql, end); strings.EqualFold(name, column) && j < len(sql) && sql[j] == '=' {
+				value := skipSpaces(sql, j+1)
+				return literalValue(sql[value:readLiteral(sql, value)])
+			}
+			i = end
+		default:
+			i++
+		}
+	}
+	return ""
+}
+
+// readLiteral returns the index after the string or numeric literal starting at i.
+func readLiteral(sql string, i int) int {
+	if i < len(sql) && sql[i] == '\'' {
+		return min(skipQuoted(sql, i)+1, len(sql))
+	}
+	end := i
+	if end < len(sql) && sql[end] == '-' {
+		end++
+	}
+	for end < len(sql) && (isWordChar(sql[end]) || sql[end] == '.') {
+		end++
+	}
+	return end
+}
+
+// literalValue returns the value of a string or numeric literal, or an empty string for NULL and other expressions.
+func literalValue(literal string) string {
+	literal = strings.TrimSpace(literal)
+	if strings.HasPrefix(literal, "'") {
+		end := skipQuoted(literal, 0)
+		if end >= len(literal) {
+			return ""
+		}
+		return strings.ReplaceAll(literal[1:end], "''", "'")
+	}
+	if literal == "" || strings.EqualFold(literal, "NULL") || readLiteral(literal, 0) != len(literal) {
+		return ""
+	}
+	return literal
+}
+
+// splitTopLevel splits a comma-separated list at commas outside of quotes and parentheses.
+func splitTopLevel(list string) []string {
+	var (
+		items []string
+		start int
+		depth int
+	)
+	for i := 0; i < len(list); i++ {
+		switch list[i] {
+		case '\'', '"', '`':
+			i = skipQuoted(list, i)
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				items = append(items, list[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(items, list[start:])
+}
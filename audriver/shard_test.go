@@ -0,0 +1,75 @@
+package audriver_test
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"testing"
+
+	"github.com/brianvoe/gofakeit/v7"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mickamy/go-sql-audit-driver/audriver"
+)
+
+// shardedDriver opens connections to the fake driver registered under the data source name.
+type shardedDriver map[string]*fakeDriver
+
+func (d shardedDriver) Open(name string) (driver.Conn, error) {
+	return d[name].Open(name)
+}
+
+// TestShardRouter tests routing statements to shards and tagging audit records with their shard
+func TestShardRouter(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	ctx = audriver.WithOperatorID(ctx, uuid.New().String())
+	ctx = audriver.WithExecutionID(ctx, uuid.New().String())
+
+	// arrange
+	tokyo, oregon := &fakeDriver{}, &fakeDriver{}
+	router := audriver.NewShardRouter(
+		shardedDriver{"tokyo-dsn": tokyo, "oregon-dsn": oregon},
+		map[string]string{"tokyo": "tokyo-dsn", "oregon": "oregon-dsn"},
+	)
+	driverName := fmt.Sprintf("sharded_test_%s_%d", t.Name(), gofakeit.Number(1000, 9999))
+	sql.Register(driverName, router)
+	db, err := sql.Open(driverName, "tokyo")
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = db.Close()
+	})
+	db.SetMaxOpenConns(1)
+
+	// act
+	_, err = db.ExecContext(ctx, "UPDATE users SET age = $1", 1)
+	require.NoError(t, err)
+	_, err = db.ExecContext(audriver.WithShard(ctx, "oregon"), "UPDATE users SET age = $1", 2)
+	require.NoError(t, err)
+
+	tx, err := db.BeginTx(audriver.WithShard(ctx, "oregon"), nil)
+	require.NoError(t, err)
+	_, err = tx.ExecContext(ctx, "DELETE FROM users WHERE id = $1", 3)
+	require.NoError(t, err)
+	require.NoError(t, tx.Commit())
+
+	_, err = db.ExecContext(audriver.WithShard(ctx, "frankfurt"), "UPDATE users SET age = $1", 4)
+
+	// assert
+	require.ErrorIs(t, err, audriver.ErrUnknownShard)
+
+	tokyoInserts := tokyo.auditInserts()
+	require.Len(t, tokyoInserts, 1)
+	assert.Equal(t, "tokyo", tokyoInserts[0].value("shard"))
+	assert.Equal(t, "UPDATE users SET age = '1'", tokyoInserts[0].value("sql"))
+
+	oregonInserts := oregon.auditInserts()
+	require.Len(t, oregonInserts, 2)
+	assert.Equal(t, "oregon", oregonInserts[0].value("shard"))
+	assert.Equal(t, "UPDATE users SET age = '2'", oregonInserts[0].value("sql"))
+	assert.Equal(t, "oregon", oregonInserts[1].value("shard"), "statements of a transaction should stay on its shard")
+	assert.Equal(t, "DELETE FROM users WHERE id = '3'", oregonInserts[1].value("sql"))
+}
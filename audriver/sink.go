@@ -0,0 +1,127 @@
+package audriver
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+)
+
+// AuditSink writes audit records to their destination, e.g. a separate database, a message queue, or a file.
+// Without a sink, audit records are inserted into the audit table on the audited connection, within the transaction
+// of the modifications; see WithAuditSink.
+type AuditSink interface {
+	// Write writes mods. Modifications of a transaction are written before it is committed, and the transaction is
+	// rolled back if Write fails. mods must not be retained after Write returns.
+	Write(ctx context.Context, mods []DatabaseModification) error
+}
+
+// AuditSinkFunc is a function type that implements the AuditSink interface.
+type AuditSinkFunc func(ctx context.Context, mods []DatabaseModification) error
+
+func (f AuditSinkFunc) Write(ctx context.Context, mods []DatabaseModification) error {
+	return f(ctx, mods)
+}
+
+// writeSink writes mods to sink. Panics of the sink are returned as errors wrapping ErrPanicRecovered.
+func writeSink(ctx context.Context, sink AuditSink, mods []DatabaseModification) (err error) {
+	defer recoverPanic(&err)
+	return sink.Write(ctx, mods)
+}
+
+// writeDetached writes mods to sink after their statements are done, e.g. batched by WithBatchWindow, retrying failures
+// with AuditRetry. As the statements cannot fail anymore, failures are reported to the error handler, and the records
+// are put into the dead letter queue.
+func (b *databaseModificationBuilder) writeDetached(ctx context.Context, sink AuditSink, mods []DatabaseModification) error {
+	err := b.retryWrite(ctx, func() error {
+		if err := writeSink(ctx, sink, mods); err != nil {
+			return classifyAuditError(err)
+		}
+		return nil
+	})
+	if err != nil {
+		b.handleError(ctx, err, ErrorStageFlush, mods)
+		b.putDeadLetters(ctx, err, mods)
+		return err
+	}
+	return nil
+}
+
+// bufferedAfterCommit reports whether records are written to the batch window of WithBatchWindow or the queues of
+// WithAsyncLogging, in which case records are handed to them once their statements and transactions are committed
+// rather than before: records handed off before a commit that fails could no longer be taken back.
+func (b *databaseModificationBuilder) bufferedAfterCommit() bool {
+	switch b.sink.(type) {
+	case *batcher, *asyncWriter:
+		return true
+	default:
+		return false
+	}
+}
+
+// handOffFailed reports a failure to hand mods of committed statements off to the batch window or the queues, which
+// cannot fail the statements anymore, to the error handler, and puts the records into the dead letter queue.
+func (b *databaseModificationBuilder) handOffFailed(ctx context.Context, err error, mods []DatabaseModification) {
+	b.handleError(ctx, err, ErrorStageFlush, mods)
+	b.putDeadLetters(ctx, err, mods)
+}
+
+// maxDBSinkBatchSize is the number of audit records a DBSink inserts with a single statement.
+const maxDBSinkBatchSize = 1000
+
+// DBSink is an AuditSink inserting audit records into the audit table of a separate database, e.g. a dedicated audit
+// database, so that audit writes do not load the audited one. Records are written outside of the audited transactions,
+// so a transaction rolled back after its records were written, e.g. because the commit failed, leaves them behind.
+type DBSink struct {
+	// DB is the database written to. It must be opened with the base driver rather than the audit driver.
+	DB *sql.DB
+
+	// Table is the audit table, optionally schema-qualified. It defaults to DefaultAuditTable.
+	Table string
+
+	// Dialect is the dialect of DB. It defaults to PostgreSQL.
+	Dialect Dialect
+}
+
+// Write inserts mods into the audit table, in a single transaction if they take several statements.
+// Records written before, e.g. by retries of ambiguous failures, are skipped.
+func (s DBSink) Write(ctx context.Context, mods []DatabaseModification) error {
+	if len(mods) <= maxDBSinkBatchSize {
+		return s.insert(ctx, s.DB, mods)
+	}
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin audit transaction: %w", err)
+	}
+	for chunk := range slices.Chunk(mods, maxDBSinkBatchSize) {
+		if err := s.insert(ctx, tx, chunk); err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit audit transaction: %w", err)
+	}
+	return nil
+}
+
+// insert inserts mods with a single statement.
+func (s DBSink) insert(ctx context.Context, db interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}, mods []DatabaseModification) error {
+	dialect := cmp.Or(s.Dialect, PostgreSQL)
+	query, namedArgs, err := buildInsert(dialect, cmp.Or(s.Table, DefaultAuditTable), mods)
+	if err != nil {
+		return err
+	}
+	args := make([]any, len(namedArgs))
+	for i, arg := range namedArgs {
+		args[i] = arg.Value
+	}
+	if _, err := db.ExecContext(ctx, query+dialect.ignoreConflicts(), args...); err != nil {
+		return fmt.Errorf("failed to insert audit records: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package audriver
+
+import (
+	"context"
+	"slices"
+	"time"
+)
+
+// SlowAudit describes building or writing audit records that took longer than the slow audit threshold.
+type SlowAudit struct {
+	// Stage is ErrorStageBuild for building modifications or ErrorStageFlush for writing audit records.
+	Stage ErrorStage
+
+	// Tables are the tables of the modifications.
+	Tables []string
+
+	// BatchSize is the number of modifications built or written.
+	BatchSize int
+
+	// Duration is the time building or writing took.
+	Duration time.Duration
+}
+
+// SlowAuditLogger is implemented by loggers warned about slow audits, see WithSlowAuditThreshold.
+type SlowAuditLogger interface {
+	LogSlowAudit(ctx context.Context, audit SlowAudit)
+}
+
+// detectSlowAudit reports building or writing mods as slow if it took longer than the slow audit threshold since start.
+func (b *databaseModificationBuilder) detectSlowAudit(ctx context.Context, stage ErrorStage, mods []DatabaseModification, start time.Time) {
+	if b.slowAuditThreshold <= 0 || len(mods) == 0 {
+		return
+	}
+	duration := time.Since(start)
+	if duration <= b.slowAuditThreshold {
+		return
+	}
+
+	b.stats.slowAudits.Add(1)
+	if b.slowAuditLogger == nil {
+		return
+	}
+
+	var tables []string
+	for _, mod := range mods {
+		if !slices.Contains(tables, mod.TableName) {
+			tables = append(tables, mod.TableName)
+		}
+	}
+
+	defer func() {
+		// a warning must not fail the statement it is about
+		_ = recover()
+	}()
+	b.slowAuditLogger.LogSlowAudit(ctx, SlowAudit{Stage: stage, Tables: tables, BatchSize: len(mods), Duration: duration})
+}
@@ -0,0 +1,43 @@
+package audriver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// ContextSnapshotKey is the key of the context snapshot in the metadata of database modifications.
+const ContextSnapshotKey = "context"
+
+// ContextSnapshotter snapshots selected context values, e.g. the locale, feature flags, or request path,
+// so that what the application was doing can be reconstructed from audit records.
+type ContextSnapshotter interface {
+	SnapshotContext(ctx context.Context) (map[string]any, error)
+}
+
+// ContextSnapshotterFunc is a function type that implements the ContextSnapshotter interface.
+type ContextSnapshotterFunc func(ctx context.Context) (map[string]any, error)
+
+func (f ContextSnapshotterFunc) SnapshotContext(ctx context.Context) (map[string]any, error) {
+	return f(ctx)
+}
+
+// snapshotContext returns the context snapshot of ctx, or nil if there is no snapshotter or the snapshot is empty.
+// Snapshots must be JSON-encodable, as they are stored in the metadata column.
+func (b *databaseModificationBuilder) snapshotContext(ctx context.Context) (map[string]any, error) {
+	if b.contextSnapshotter == nil {
+		return nil, nil
+	}
+
+	snapshot, err := b.contextSnapshotter.SnapshotContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to snapshot context: %w", err)
+	}
+	if len(snapshot) == 0 {
+		return nil, nil
+	}
+	if _, err := json.Marshal(snapshot); err != nil {
+		return nil, fmt.Errorf("failed to encode context snapshot: %w", err)
+	}
+	return snapshot, nil
+}
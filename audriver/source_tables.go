@@ -0,0 +1,191 @@
+package audriver
+
+import (
+	"strings"
+)
+
+// fromArgumentFunctions are functions whose arguments use FROM as a keyword rather than to reference a table,
+// e.g. EXTRACT(YEAR FROM created_at).
+var fromArgumentFunctions = map[string]bool{
+	"EXTRACT":   true,
+	"OVERLAY":   true,
+	"POSITION":  true,
+	"SUBSTRING": true,
+	"TRIM":      true,
+}
+
+// clauseKeywords are keywords that may follow a table reference, so they are not taken as a table alias.
+var clauseKeywords = map[string]bool{
+	"CROSS": true, "EXCEPT": true, "FETCH": true, "FOR": true, "FULL": true, "GROUP": true, "HAVING": true,
+	"INNER": true, "INTERSECT": true, "JOIN": true, "LEFT": true, "LIMIT": true, "NATURAL": true, "OFFSET": true,
+	"ON": true, "ORDER": true, "RETURNING": true, "RIGHT": true, "SET": true, "TABLESAMPLE": true, "UNION": true,
+	"USING": true, "WHERE": true, "WINDOW": true,
+}
+
+// parseSourceTables returns the tables read by a modification besides its target table:
+// the relations referenced by FROM and JOIN clauses of INSERT ... SELECT, UPDATE ... FROM, and subqueries,
+// and by USING clauses of DELETE ... USING. Table functions and subqueries in FROM are skipped.
+func parseSourceTables(sql string, ta tableAction) []string {
+	if ta.action == DatabaseModificationActionUnknown {
+		return nil
+	}
+	return scanTableReferences(sql, ta.end)
+}
+
+// scanTableReferences returns the relations referenced by FROM, JOIN, and USING clauses in sql starting at start,
+// in order of appearance and without duplicates.
+func scanTableReferences(sql string, start int) []string {
+	var (
+		tables   []string
+		seen     = map[string]bool{}
+		parens   []string
+		lastWord string
+	)
+	add := func(table string) {
+		if !seen[table] {
+			seen[table] = true
+			tables = append(tables, table)
+		}
+	}
+
+	for i := start; i < len(sql); {
+		c := sql[i]
+		switch {
+		case c == '\'' || c == '"' || c == '`':
+			i = skipQuoted(sql, i) + 1
+			lastWord = ""
+		case c == '(':
+			parens = append(parens, strings.ToUpper(lastWord))
+			lastWord = ""
+			i++
+		case c == ')':
+			if len(parens) > 0 {
+				parens = parens[:len(parens)-1]
+			}
+			lastWord = ""
+			i++
+		case isWordChar(c) && isWordBoundary(sql, i-1):
+			end := i
+			for end < len(sql) && isWordChar(sql[end]) {
+				end++
+			}
+			word := strings.ToUpper(sql[i:end])
+			inFromArgument := len(parens) > 0 && fromArgumentFunctions[parens[len(parens)-1]]
+			switch {
+			case word == "FROM" && !inFromArgument, word == "USING":
+				// JOIN ... USING (column) is skipped since it is followed by a parenthesis
+				end = readTableReferences(sql, end, true, add)
+			case word == "JOIN":
+				end = readTableReferences(sql, end, false, add)
+			}
+			lastWord = sql[i:end]
+			i = end
+		default:
+			if !isSpace(c) {
+				lastWord = ""
+			}
+			i++
+		}
+	}
+
+	return tables
+}
+
+// readTableReferences reads the table reference following a FROM, JOIN, or USING keyword ending at i,
+// or a comma-separated list of them if list is true, and passes each table name to add.
+// It returns the index after the last reference read.
+func readTableReferences(sql string, i int, list bool, add func(string)) int {
+	for {
+		j := skipSpaces(sql, i)
+		if j >= len(sql) || sql[j] == '(' {
+			return j
+		}
+
+		name, end := readIdentifier(sql, j)
+		if name == "" {
+			return j
+		}
+		if k := skipSpaces(sql, end); k < len(sql) && sql[k] == '(' {
+			// table function such as generate_series(...) or LATERAL (...)
+			return end
+		}
+		add(name)
+		if !list {
+			return end
+		}
+
+		end = skipAlias(sql, end)
+		k := skipSpaces(sql, end)
+		if k >= len(sql) || sql[k] != ',' {
+			return end
+		}
+		i = k + 1
+	}
+}
+
+// skipAlias skips an optional table alias, with or without AS, starting at i.
+func skipAlias(sql string, i int) int {
+	j := skipSpaces(sql, i)
+	word, end := readWord(sql, j)
+	if strings.EqualFold(word, "AS") {
+		_, end = readIdentifier(sql, skipSpaces(sql, end))
+		return end
+	}
+	if word == "" || clauseKeywords[strings.ToUpper(word)] {
+		return i
+	}
+	return end
+}
+
+// readIdentifier reads a possibly schema-qualified and quoted identifier starting at i.
+// It returns the identifier without quotes and the index after it, or an empty string if there is none.
+func readIdentifier(sql string, i int) (string, int) {
+	parts, end := readIdentifierParts(sql, i)
+	return strings.Join(parts, "."), end
+}
+
+// readIdentifierParts reads a possibly qualified identifier starting at i like readIdentifier, and returns its parts
+// unquoted, so that dots within quoted parts are not taken for qualifiers.
+func readIdentifierParts(sql string, i int) ([]string, int) {
+	var parts []string
+	for i < len(sql) {
+		var part string
+		switch sql[i] {
+		case '"', '`':
+			end := skipQuoted(sql, i)
+			if end >= len(sql) {
+				return nil, i
+			}
+			part = strings.ReplaceAll(sql[i+1:end], string(sql[i])+string(sql[i]), string(sql[i]))
+			i = end + 1
+		case '[':
+			end := strings.IndexByte(sql[i:], ']')
+			if end < 0 {
+				return nil, i
+			}
+			part = sql[i+1 : i+end]
+			i += end + 1
+		default:
+			part, i = readWord(sql, i)
+			if part == "" {
+				return nil, i
+			}
+		}
+		parts = append(parts, part)
+
+		if i >= len(sql) || sql[i] != '.' {
+			break
+		}
+		i++
+	}
+	return parts, i
+}
+
+// readWord reads an unquoted word starting at i and returns it with the index after it.
+func readWord(sql string, i int) (string, int) {
+	end := i
+	for end < len(sql) && isWordChar(sql[end]) {
+		end++
+	}
+	return sql[i:end], end
+}
@@ -0,0 +1,81 @@
+package audriver
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+)
+
+// Spool files, e.g. of FileDeadLetterQueue, are sequences of entries, each framed as:
+//
+//	magic   [4]byte  "ASP1"
+//	length  uint32   length of the payload, big-endian
+//	crc     uint32   CRC-32C of length and payload, big-endian
+//	payload [length]byte
+//
+// A crash while appending leaves a torn entry, which later appends follow. The magic lets scanSpool resynchronize
+// after it, and the checksum tells torn or otherwise corrupted entries from valid ones.
+var spoolMagic = []byte("ASP1")
+
+const (
+	// spoolHeaderSize is the size of the magic, length, and checksum of an entry.
+	spoolHeaderSize = 12
+
+	// maxSpoolEntrySize is the largest payload of an entry; larger lengths are taken for corruption.
+	maxSpoolEntrySize = 64 << 20
+)
+
+// spoolTable is the CRC-32C table of spool entries.
+var spoolTable = crc32.MakeTable(crc32.Castagnoli)
+
+// appendSpoolEntry appends payload to dst framed as a spool entry.
+func appendSpoolEntry(dst []byte, payload []byte) []byte {
+	dst = append(dst, spoolMagic...)
+	dst = binary.BigEndian.AppendUint32(dst, uint32(len(payload)))
+	dst = binary.BigEndian.AppendUint32(dst, spoolChecksum(dst[len(dst)-4:], payload))
+	return append(dst, payload...)
+}
+
+// scanSpool calls fn with the payload of each valid entry of data, in order, skipping torn and corrupted entries up
+// to the next magic. It returns the number of bytes skipped, and stops at the first error of fn.
+func scanSpool(data []byte, fn func(payload []byte) error) (int, error) {
+	skipped := 0
+	for len(data) > 0 {
+		if payload, ok := spoolEntry(data); ok {
+			if err := fn(payload); err != nil {
+				return skipped, err
+			}
+			data = data[spoolHeaderSize+len(payload):]
+			continue
+		}
+
+		next := bytes.Index(data[1:], spoolMagic)
+		if next < 0 {
+			return skipped + len(data), nil
+		}
+		skipped += next + 1
+		data = data[next+1:]
+	}
+	return skipped, nil
+}
+
+// spoolEntry returns the payload of the entry at the start of data, and whether the entry is complete and valid.
+func spoolEntry(data []byte) ([]byte, bool) {
+	if len(data) < spoolHeaderSize || !bytes.HasPrefix(data, spoolMagic) {
+		return nil, false
+	}
+	length := binary.BigEndian.Uint32(data[4:8])
+	if length > maxSpoolEntrySize || uint64(len(data)-spoolHeaderSize) < uint64(length) {
+		return nil, false
+	}
+	payload := data[spoolHeaderSize : spoolHeaderSize+int(length)]
+	if binary.BigEndian.Uint32(data[8:12]) != spoolChecksum(data[4:8], payload) {
+		return nil, false
+	}
+	return payload, true
+}
+
+// spoolChecksum returns the CRC-32C of the length and payload of an entry.
+func spoolChecksum(length []byte, payload []byte) uint32 {
+	return crc32.Update(crc32.Checksum(length, spoolTable), spoolTable, payload)
+}
@@ -0,0 +1,91 @@
+package audriver_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mickamy/go-sql-audit-driver/audriver"
+)
+
+// classificationSeeds are statements known to be hard to classify, used as seed corpus of the fuzzers.
+var classificationSeeds = []string{
+	"INSERT INTO users (id) VALUES ($1)",
+	"update ONLY public.users SET name = $1 WHERE id = $2",
+	"DELETE FROM \"my \"\"users\"\"\" WHERE id = $1",
+	"/* outer /* inner */ still a comment */ DELETE FROM users",
+	"-- comment\n/* comment */ UPDATE users SET age = 1",
+	"UPDATE users SET note = 'INSERT INTO orders' WHERE id = $1",
+	"UPDATE users SET note = E'it\\'s INSERT INTO orders' WHERE id = $1",
+	"UPDATE users SET note = $$DELETE FROM orders$$ WHERE id = $1",
+	"UPDATE users SET note = $body$ $$ DELETE FROM orders $body$ WHERE id = $1",
+	"WITH RECURSIVE t(n) AS (SELECT 1 UNION ALL SELECT n + 1 FROM t WHERE n < 3) DELETE FROM users WHERE id IN (SELECT n FROM t)",
+	"WITH d AS (DELETE FROM sessions RETURNING user_id) SELECT * FROM d",
+	"WITH l AS (SELECT id FROM users FOR NO KEY UPDATE) SELECT * FROM l",
+	"INSERT INTO users (id) VALUES (1) ON CONFLICT (id) DO UPDATE SET id = excluded.id",
+	"SELECT 1 -- DELETE FROM users",
+	"/* unterminated DELETE FROM users",
+	"INSERT INTO",
+	"WITH",
+	"'",
+	"$a$",
+}
+
+// FuzzIsDML tests that classifying statements does not panic and is not affected by leading comments
+func FuzzIsDML(f *testing.F) {
+	for _, seed := range classificationSeeds {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, sql string) {
+		got := audriver.IsDML(sql)
+		if prefixed := audriver.IsDML("/* c /* n */ */\n-- c\n" + sql); prefixed != got {
+			t.Errorf("IsDML(%q) = %v, but %v with leading comments", sql, got, prefixed)
+		}
+	})
+}
+
+// FuzzSkipLeadingComments tests that skipping leading comments stops at code or the end of the statement
+func FuzzSkipLeadingComments(f *testing.F) {
+	for _, seed := range classificationSeeds {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, sql string) {
+		i := audriver.SkipLeadingComments(sql)
+		if i < 0 || i > len(sql) {
+			t.Fatalf("SkipLeadingComments(%q) = %d, out of range", sql, i)
+		}
+		rest := sql[i:]
+		if strings.HasPrefix(rest, "--") || strings.HasPrefix(rest, "/*") || strings.TrimLeft(rest, " \t\r\n") != rest {
+			t.Errorf("SkipLeadingComments(%q) stopped before %q", sql, rest)
+		}
+	})
+}
+
+// FuzzParseTableAction tests that parsed actions and tables are consistent and not affected by leading comments
+func FuzzParseTableAction(f *testing.F) {
+	for _, seed := range classificationSeeds {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, sql string) {
+		action, schema, table, end, err := audriver.ParseTableAction(sql)
+		if err != nil {
+			return
+		}
+		switch action {
+		case audriver.DatabaseModificationActionInsert, audriver.DatabaseModificationActionUpdate, audriver.DatabaseModificationActionDelete:
+		default:
+			t.Errorf("ParseTableAction(%q) returned action %q", sql, action)
+		}
+		if table == "" || end <= 0 || end > len(sql) {
+			t.Errorf("ParseTableAction(%q) returned table %q ending at %d", sql, table, end)
+		}
+
+		const prefix = "/* c */ "
+		prefixedAction, prefixedSchema, prefixedTable, prefixedEnd, err := audriver.ParseTableAction(prefix + sql)
+		if err != nil || prefixedAction != action || prefixedSchema != schema || prefixedTable != table || prefixedEnd != end+len(prefix) {
+			t.Errorf("ParseTableAction(%q) differs with a leading comment", sql)
+		}
+	})
+}
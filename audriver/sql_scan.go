@@ -0,0 +1,140 @@
+package audriver
+
+import (
+	"strings"
+
+	"github.com/mickamy/go-sql-audit-driver/internal/postgres"
+)
+
+// indexTopLevelKeyword returns the index of the first occurrence of keyword outside of quotes and parentheses,
+// or -1 if there is none. The match is case-insensitive and must be a whole word.
+func indexTopLevelKeyword(sql string, keyword string) int {
+	depth := 0
+	for i := 0; i < len(sql); i++ {
+		switch c := sql[i]; c {
+		case '\'', '"', '`':
+			i = skipQuoted(sql, i)
+		case '(':
+			depth++
+		case ')':
+			depth--
+		default:
+			if depth == 0 && isWordBoundary(sql, i-1) && strings.HasPrefix(strings.ToUpper(sql[i:min(i+len(keyword), len(sql))]), keyword) && isWordBoundary(sql, i+len(keyword)) {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// indexClosingParen returns the index of the parenthesis closing the one at open, or -1 if it is unbalanced.
+func indexClosingParen(sql string, open int) int {
+	depth := 0
+	for i := open; i < len(sql); i++ {
+		switch sql[i] {
+		case '\'', '"', '`':
+			i = skipQuoted(sql, i)
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// skipQuoted returns the index of the quote closing the quoted string or identifier starting at start.
+// Doubled quotes are treated as escaped quotes.
+func skipQuoted(sql string, start int) int {
+	quote := sql[start]
+	for i := start + 1; i < len(sql); i++ {
+		if sql[i] != quote {
+			continue
+		}
+		if i+1 < len(sql) && sql[i+1] == quote {
+			i++
+			continue
+		}
+		return i
+	}
+	return len(sql)
+}
+
+func skipSpaces(sql string, i int) int {
+	for i < len(sql) && isSpace(sql[i]) {
+		i++
+	}
+	return i
+}
+
+// skipLeadingComments returns the index of the first byte of sql that is neither whitespace nor part of a comment.
+// Block comments may be nested.
+func skipLeadingComments(sql string) int {
+	i := skipSpaces(sql, 0)
+	for strings.HasPrefix(sql[i:], "--") || strings.HasPrefix(sql[i:], "/*") {
+		i = skipSpaces(sql, postgres.SkipLiteral(sql, i))
+	}
+	return i
+}
+
+// maskLiterals returns sql with the contents of string literals, dollar-quoted strings, and comments replaced by spaces,
+// so that keywords and parentheses are only found in code. Offsets and quoted identifiers are kept as is.
+func maskLiterals(sql string) string {
+	var masked []byte
+	for i := 0; i < len(sql); i++ {
+		end := postgres.SkipLiteral(sql, i)
+		if end == i || sql[i] == '"' || sql[i] == '`' {
+			if end > i {
+				i = end - 1
+			}
+			continue
+		}
+		if masked == nil {
+			masked = []byte(sql)
+		}
+		start := i
+		if sql[i] == '\'' || sql[i] == '$' {
+			// keep the opening quote so that the literal still separates words
+			start++
+		}
+		for j := start; j < end; j++ {
+			masked[j] = ' '
+		}
+		i = end - 1
+	}
+	if masked == nil {
+		return sql
+	}
+	return string(masked)
+}
+
+// parenDepth returns the depth of parentheses at index to of masked SQL, given the depth at index from.
+func parenDepth(masked string, from int, to int, depth int) int {
+	for j := from; j < to; j++ {
+		switch masked[j] {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		}
+	}
+	return depth
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+func isWordBoundary(sql string, i int) bool {
+	if i < 0 || i >= len(sql) {
+		return true
+	}
+	return !isWordChar(sql[i])
+}
+
+func isWordChar(c byte) bool {
+	return c == '_' || c == '$' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= 0x80
+}
@@ -0,0 +1,130 @@
+package audriver
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Staging configures writing audit records to an unlogged staging table, moved into the durable audit table in the
+// background by a StagingMover. This trades a durability window for write throughput: records not moved yet are lost
+// if PostgreSQL crashes, as unlogged tables are truncated on crash recovery.
+type Staging struct {
+	// Table is the unlogged staging table. It defaults to DefaultAuditTable with the suffix "_staging".
+	Table string
+
+	// AuditTable is the durable audit table records are moved into. It defaults to DefaultAuditTable.
+	AuditTable string
+
+	// BatchSize is the maximum number of records moved by a statement. It defaults to 1000.
+	BatchSize int
+
+	// Interval is how long the mover waits after the staging table is drained. It defaults to one second.
+	Interval time.Duration
+
+	// ErrorHandler, if set, is invoked when moving records fails. The mover keeps running regardless.
+	ErrorHandler func(ctx context.Context, err error)
+}
+
+func (s Staging) withDefaults() Staging {
+	if s.AuditTable == "" {
+		s.AuditTable = DefaultAuditTable
+	}
+	if s.Table == "" {
+		s.Table = DefaultAuditTable + "_staging"
+	}
+	if s.BatchSize <= 0 {
+		s.BatchSize = 1000
+	}
+	if s.Interval <= 0 {
+		s.Interval = time.Second
+	}
+	return s
+}
+
+// GenerateStagingSQL generates SQL creating the unlogged staging table, with the columns, defaults, and primary key
+// of the audit table, which must already exist.
+func GenerateStagingSQL(cfg Staging) string {
+	cfg = cfg.withDefaults()
+	return fmt.Sprintf("CREATE UNLOGGED TABLE IF NOT EXISTS %s (LIKE %s INCLUDING DEFAULTS INCLUDING CONSTRAINTS INCLUDING INDEXES);\n", cfg.Table, cfg.AuditTable)
+}
+
+// StagingMover moves audit records from the staging table into the durable audit table.
+// Several movers may run concurrently, e.g. one per application instance, as rows locked by one are skipped by others.
+type StagingMover struct {
+	db  *sql.DB
+	cfg Staging
+}
+
+// NewStagingMover creates a StagingMover moving records on db, which must not be opened with an audit driver.
+func NewStagingMover(db *sql.DB, cfg Staging) *StagingMover {
+	return &StagingMover{db: db, cfg: cfg.withDefaults()}
+}
+
+// Run moves records until ctx is done, waiting for the interval whenever the staging table is drained.
+func (m *StagingMover) Run(ctx context.Context) {
+	for {
+		moved, err := m.Move(ctx)
+		if err != nil && m.cfg.ErrorHandler != nil && ctx.Err() == nil {
+			m.cfg.ErrorHandler(ctx, err)
+		}
+		if err == nil && moved >= int64(m.cfg.BatchSize) {
+			continue
+		}
+
+		timer := time.NewTimer(m.cfg.Interval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+}
+
+// Move moves a batch of up to BatchSize records, oldest first, and returns the number of records moved.
+// Records already in the audit table, e.g. written twice by a retry, are dropped.
+func (m *StagingMover) Move(ctx context.Context) (int64, error) {
+	columns, err := m.columns(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	list := strings.Join(columns, ", ")
+	query := fmt.Sprintf(`WITH moved AS (
+    DELETE FROM %[1]s
+    WHERE id IN (SELECT id FROM %[1]s ORDER BY modified_at LIMIT $1 FOR UPDATE SKIP LOCKED)
+    RETURNING %[3]s
+)
+INSERT INTO %[2]s (%[3]s) SELECT %[3]s FROM moved ON CONFLICT (id) DO NOTHING`, m.cfg.Table, m.cfg.AuditTable, list)
+
+	res, err := m.db.ExecContext(ctx, query, m.cfg.BatchSize)
+	if err != nil {
+		return 0, fmt.Errorf("failed to move audit records from %s: %w", m.cfg.Table, err)
+	}
+	moved, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to count moved audit records: %w", err)
+	}
+	return moved, nil
+}
+
+// columns returns the columns of the staging table, so that records are moved by name
+// even if columns were added to the tables in different orders.
+func (m *StagingMover) columns(ctx context.Context) ([]string, error) {
+	rows, err := m.db.QueryContext(ctx, "SELECT * FROM "+m.cfg.Table+" LIMIT 0")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query %s columns: %w", m.cfg.Table, err)
+	}
+	defer func(rows *sql.Rows) {
+		_ = rows.Close()
+	}(rows)
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s columns: %w", m.cfg.Table, err)
+	}
+	return columns, nil
+}
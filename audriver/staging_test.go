@@ -0,0 +1,85 @@
+package audriver_test
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/brianvoe/gofakeit/v7"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mickamy/go-sql-audit-driver/audriver"
+)
+
+// TestAuditDriver_Staging tests writing audit records to the staging table
+func TestAuditDriver_Staging(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	ctx = audriver.WithOperatorID(ctx, uuid.New().String())
+	ctx = audriver.WithExecutionID(ctx, uuid.New().String())
+
+	// arrange
+	baseDriver := &fakeDriver{}
+	db := setUpFakeTestDB(t, baseDriver, audriver.WithStaging(audriver.Staging{}))
+
+	// act
+	_, err := db.ExecContext(ctx, "DELETE FROM users")
+
+	// assert
+	require.NoError(t, err)
+	inserts := baseDriver.auditInserts()
+	require.Len(t, inserts, 1)
+	assert.True(t, strings.HasPrefix(inserts[0].query, "INSERT INTO database_modifications_staging ("), inserts[0].query)
+	assert.True(t, strings.HasSuffix(inserts[0].query, " ON CONFLICT (id) DO NOTHING"), inserts[0].query)
+}
+
+// TestStagingMover_Move tests moving a batch of records from the staging table by column name
+func TestStagingMover_Move(t *testing.T) {
+	t.Parallel()
+
+	// arrange
+	baseDriver := &fakeDriver{
+		query: func(string, []driver.NamedValue) ([]string, [][]driver.Value) {
+			return []string{"id", "operator_id", "modified_at"}, nil
+		},
+	}
+	driverName := fmt.Sprintf("fake_staging_%s_%d", t.Name(), gofakeit.Number(1000, 9999))
+	sql.Register(driverName, baseDriver)
+	db, err := sql.Open(driverName, driverName)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = db.Close()
+	})
+	mover := audriver.NewStagingMover(db, audriver.Staging{Table: "audit.staging", AuditTable: "audit.modifications", BatchSize: 500})
+
+	// act
+	_, err = mover.Move(t.Context())
+
+	// assert
+	require.NoError(t, err)
+	executed := baseDriver.executed()
+	require.Len(t, executed, 1)
+	assert.Equal(t, `WITH moved AS (
+    DELETE FROM audit.staging
+    WHERE id IN (SELECT id FROM audit.staging ORDER BY modified_at LIMIT $1 FOR UPDATE SKIP LOCKED)
+    RETURNING id, operator_id, modified_at
+)
+INSERT INTO audit.modifications (id, operator_id, modified_at) SELECT id, operator_id, modified_at FROM moved ON CONFLICT (id) DO NOTHING`, executed[0].query)
+	assert.Equal(t, int64(500), executed[0].args[0].Value)
+}
+
+// TestGenerateStagingSQL tests the generated staging table SQL
+func TestGenerateStagingSQL(t *testing.T) {
+	t.Parallel()
+
+	// act
+	got := audriver.GenerateStagingSQL(audriver.Staging{})
+
+	// assert
+	assert.Equal(t, "CREATE UNLOGGED TABLE IF NOT EXISTS database_modifications_staging (LIKE database_modifications INCLUDING DEFAULTS INCLUDING CONSTRAINTS INCLUDING INDEXES);\n", got)
+}
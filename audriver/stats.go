@@ -0,0 +1,94 @@
+package audriver
+
+import (
+	"sync/atomic"
+)
+
+// AuditStats contains cumulative audit counters of a Driver, in the spirit of sql.DBStats.
+type AuditStats struct {
+	Audited         int64 // Modifications written to the audit table.
+	SkippedFiltered int64 // Modifying statements skipped by table filters, action filters, or ignore patterns.
+	SkippedNonDML   int64 // Executed statements skipped because they do not modify data.
+	SkippedSampled  int64 // Modifying statements skipped by sampling.
+	Failed          int64 // Failures of building modifications, executing audited statements, or writing audit records.
+	FlushedBatches  int64 // Audit inserts written, each holding one or more modifications.
+	MaxBatchSize    int64 // Largest number of modifications written by a single audit insert.
+	SlowAudits      int64 // Builds and writes of audit records slower than the slow audit threshold.
+	RetriedWrites   int64 // Retries of audit writes that failed with transient errors.
+	SkippedDisabled int64 // Statements and transactions not audited by WithEnablementRate or WithAuditEnabled.
+	SkippedBudget   int64 // Modifications exceeding the budget of WithAuditBudget folded into aggregated records.
+	DeadLetters     int64 // Modifications of batches that failed to be written, put into the dead letter queue.
+	DroppedRecords  int64 // Modifications dropped by BackpressureDrop because the queue of WithAsyncLogging was full.
+	RateAnomalies   int64 // Operators exceeding the threshold of WithOperatorRateAnomaly, once per operator and window.
+
+	EnabledConns   int64   // Connections opened with auditing enabled by the enablement rate.
+	DisabledConns  int64   // Connections opened with auditing disabled by the enablement rate.
+	EnablementRate float64 // Fraction of connections audited, set with WithEnablementRate.
+
+	// OperatorRates are the modifications of the operators active within the window of WithOperatorRateAnomaly,
+	// by operator ID, or nil without it.
+	OperatorRates map[string]int64
+}
+
+// auditStats holds the counters of AuditStats, shared by all connections of a Driver.
+type auditStats struct {
+	audited         atomic.Int64
+	skippedFiltered atomic.Int64
+	skippedNonDML   atomic.Int64
+	skippedSampled  atomic.Int64
+	failed          atomic.Int64
+	flushedBatches  atomic.Int64
+	maxBatchSize    atomic.Int64
+	slowAudits      atomic.Int64
+	retriedWrites   atomic.Int64
+	skippedDisabled atomic.Int64
+	skippedBudget   atomic.Int64
+	deadLetters     atomic.Int64
+	droppedRecords  atomic.Int64
+	rateAnomalies   atomic.Int64
+	enabledConns    atomic.Int64
+	disabledConns   atomic.Int64
+}
+
+// written records a successfully written batch of n modifications.
+func (s *auditStats) written(n int) {
+	s.audited.Add(int64(n))
+	s.flushedBatches.Add(1)
+	for {
+		current := s.maxBatchSize.Load()
+		if int64(n) <= current || s.maxBatchSize.CompareAndSwap(current, int64(n)) {
+			return
+		}
+	}
+}
+
+func (s *auditStats) snapshot() AuditStats {
+	return AuditStats{
+		Audited:         s.audited.Load(),
+		SkippedFiltered: s.skippedFiltered.Load(),
+		SkippedNonDML:   s.skippedNonDML.Load(),
+		SkippedSampled:  s.skippedSampled.Load(),
+		Failed:          s.failed.Load(),
+		FlushedBatches:  s.flushedBatches.Load(),
+		MaxBatchSize:    s.maxBatchSize.Load(),
+		SlowAudits:      s.slowAudits.Load(),
+		RetriedWrites:   s.retriedWrites.Load(),
+		SkippedDisabled: s.skippedDisabled.Load(),
+		SkippedBudget:   s.skippedBudget.Load(),
+		DeadLetters:     s.deadLetters.Load(),
+		DroppedRecords:  s.droppedRecords.Load(),
+		RateAnomalies:   s.rateAnomalies.Load(),
+		EnabledConns:    s.enabledConns.Load(),
+		DisabledConns:   s.disabledConns.Load(),
+	}
+}
+
+// AuditStats returns the cumulative audit counters of the driver, for monitoring agents to poll.
+func (d *Driver) AuditStats() AuditStats {
+	stats := d.builder.stats.snapshot()
+	stats.EnablementRate = d.enablementRate
+	if r := d.builder.operatorRates; r != nil {
+		stats.OperatorRates = r.snapshot()
+	}
+	return stats
+}
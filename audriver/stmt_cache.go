@@ -0,0 +1,109 @@
+package audriver
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+)
+
+// maxCachedBatchSize is the number of modifications up to which audit inserts are prepared and cached.
+// Larger batches are rare enough that their statements are executed without preparing.
+const maxCachedBatchSize = 32
+
+// stmtCache caches prepared audit insert statements of a connection, evicting the oldest when full.
+// Like the connection itself, it is not safe for concurrent use.
+type stmtCache struct {
+	size  int
+	stmts map[string]driver.Stmt
+	order []string
+}
+
+func newStmtCache(size int) *stmtCache {
+	if size <= 0 {
+		return nil
+	}
+	return &stmtCache{
+		size:  size,
+		stmts: make(map[string]driver.Stmt, size),
+	}
+}
+
+// exec executes query on conn with a cached prepared statement, preparing it if needed.
+// A nil cache executes query directly.
+func (c *stmtCache) exec(ctx context.Context, conn driver.Conn, query string, args []driver.NamedValue) (driver.Result, error) {
+	if c == nil {
+		return execContext(ctx, conn, query, args)
+	}
+
+	stmt, err := c.get(ctx, conn, query)
+	if err != nil {
+		return nil, err
+	}
+
+	stmtExecCtx, ok := stmt.(driver.StmtExecContext)
+	if !ok {
+		c.evict(query)
+		return nil, fmt.Errorf("%w: statement does not support ExecContext", ErrUnsupportedConn)
+	}
+
+	res, err := stmtExecCtx.ExecContext(ctx, args)
+	if err != nil {
+		// the statement may have been invalidated, e.g. by a schema change
+		c.evict(query)
+	}
+	return res, err
+}
+
+func (c *stmtCache) get(ctx context.Context, conn driver.Conn, query string) (driver.Stmt, error) {
+	if stmt, ok := c.stmts[query]; ok {
+		return stmt, nil
+	}
+
+	var (
+		stmt driver.Stmt
+		err  error
+	)
+	if prepareCtx, ok := conn.(driver.ConnPrepareContext); ok {
+		stmt, err = prepareCtx.PrepareContext(ctx, query)
+	} else {
+		stmt, err = conn.Prepare(query)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if len(c.order) >= c.size {
+		c.evict(c.order[0])
+	}
+	c.stmts[query] = stmt
+	c.order = append(c.order, query)
+
+	return stmt, nil
+}
+
+func (c *stmtCache) evict(query string) {
+	stmt, ok := c.stmts[query]
+	if !ok {
+		return
+	}
+	_ = stmt.Close()
+	delete(c.stmts, query)
+	for i, q := range c.order {
+		if q == query {
+			c.order = append(c.order[:i], c.order[i+1:]...)
+			break
+		}
+	}
+}
+
+// close closes all cached statements.
+func (c *stmtCache) close() {
+	if c == nil {
+		return
+	}
+	for _, stmt := range c.stmts {
+		_ = stmt.Close()
+	}
+	c.stmts = nil
+	c.order = nil
+}
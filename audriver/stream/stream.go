@@ -0,0 +1,237 @@
+// Package stream serves a live stream of the audit records written by the process to WebSocket clients,
+// e.g. real-time security monitoring dashboards.
+//
+// A Server is an audriver.Logger, so it is notified of every record once it is written:
+//
+//	server := stream.New(stream.AuthorizerFunc(func(r *http.Request) error {
+//		return verifyToken(r.Header.Get("Authorization"))
+//	}))
+//	auditDriver := audriver.New(baseDriver, audriver.WithLogger(server))
+//	http.Handle("/audit/stream", server)
+//
+// Each record is sent as a text message holding the JSON encoding of audriver.DatabaseModification, or as a binary
+// message encoded with the codec of WithCodec.
+// Clients may subscribe to some tables only with the table query parameter, e.g. /audit/stream?table=users&table=orders.
+package stream
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"sync"
+
+	"github.com/mickamy/go-sql-audit-driver/audriver"
+	"github.com/mickamy/go-sql-audit-driver/audriver/codec"
+	"github.com/mickamy/go-sql-audit-driver/query"
+)
+
+// DefaultBufferSize is the default number of records buffered per client.
+const DefaultBufferSize = 256
+
+// ErrUnauthorized may be returned by authorizers to reject clients with 401 Unauthorized rather than 403 Forbidden.
+var ErrUnauthorized = errors.New("unauthorized")
+
+// Authorizer authorizes clients connecting to the stream, e.g. by verifying a bearer token of the request.
+type Authorizer interface {
+	Authorize(r *http.Request) error
+}
+
+// AuthorizerFunc is a function that implements Authorizer.
+type AuthorizerFunc func(r *http.Request) error
+
+// Authorize calls f(r).
+func (f AuthorizerFunc) Authorize(r *http.Request) error {
+	return f(r)
+}
+
+// Option configures a Server.
+type Option func(*Server)
+
+// WithBufferSize sets the number of records buffered per client. Clients falling further behind are disconnected,
+// so that they notice the gap instead of silently missing records. It defaults to DefaultBufferSize.
+func WithBufferSize(size int) Option {
+	return func(s *Server) {
+		s.bufferSize = size
+	}
+}
+
+// WithReadAuthorizer restricts the records streamed to each client to the scope authorizer grants the context of its
+// request, e.g. to the records of its tenant, as query.Reader does for reads of the audit table.
+// Clients denied a scope are rejected with 403 Forbidden.
+func WithReadAuthorizer(authorizer query.Authorizer) Option {
+	return func(s *Server) {
+		s.readAuthorizer = authorizer
+	}
+}
+
+// WithCodec sets the codec records are encoded with. Records encoded with codecs other than codec.JSON are sent as
+// binary messages. It defaults to codec.JSON.
+func WithCodec(c codec.Codec) Option {
+	return func(s *Server) {
+		s.codec = c
+	}
+}
+
+// WithAllowedOrigins allows browser pages of origins, e.g. "https://dashboard.example.com", to connect besides
+// pages of the origin of the stream itself; "*" allows any origin. Browsers send cookies and other credentials along
+// with cross-origin WebSocket handshakes, so other origins are rejected with 403 Forbidden by default, lest any page
+// a user visits reads the stream with their credentials. Clients other than browsers send no Origin and are not
+// affected.
+func WithAllowedOrigins(origins ...string) Option {
+	return func(s *Server) {
+		s.allowedOrigins = append(s.allowedOrigins, origins...)
+	}
+}
+
+// Server streams audit records to WebSocket clients. It implements audriver.Logger and http.Handler.
+type Server struct {
+	authorizer     Authorizer
+	readAuthorizer query.Authorizer
+	bufferSize     int
+	codec          codec.Codec
+	allowedOrigins []string
+
+	mu      sync.Mutex
+	clients map[*client]struct{}
+}
+
+// New returns a server streaming to the clients authorized by authorizer.
+// Every client is rejected if authorizer is nil, as audit records are sensitive.
+func New(authorizer Authorizer, opts ...Option) *Server {
+	s := &Server{
+		authorizer: authorizer,
+		bufferSize: DefaultBufferSize,
+		codec:      codec.JSON,
+		clients:    map[*client]struct{}{},
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// client is a connected WebSocket client.
+type client struct {
+	tables []string
+	scope  query.Scope
+	events chan []byte
+
+	// lagged is closed when the client falls behind by more than the buffer.
+	lagged  chan struct{}
+	lagOnce sync.Once
+}
+
+// Log sends mod to the connected clients subscribed to its table. It never blocks on slow clients.
+func (s *Server) Log(_ context.Context, mod audriver.DatabaseModification) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.clients) == 0 {
+		return
+	}
+
+	event, err := s.codec.Encode(mod)
+	if err != nil {
+		return
+	}
+	for c := range s.clients {
+		if len(c.tables) > 0 && !slices.Contains(c.tables, mod.TableName) || !c.scope.Allows(mod) {
+			continue
+		}
+		select {
+		case c.events <- event:
+		default:
+			c.lagOnce.Do(func() {
+				close(c.lagged)
+			})
+		}
+	}
+}
+
+// Clients returns the number of connected clients.
+func (s *Server) Clients() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.clients)
+}
+
+// ServeHTTP checks the origin of the client, authorizes it, upgrades the connection to WebSocket, and streams records until the client
+// disconnects or the request context is done.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if s.authorizer == nil || !originAllowed(r, s.allowedOrigins) {
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return
+	}
+	if err := s.authorizer.Authorize(r); err != nil {
+		status := http.StatusForbidden
+		if errors.Is(err, ErrUnauthorized) {
+			status = http.StatusUnauthorized
+		}
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+	var scope query.Scope
+	if s.readAuthorizer != nil {
+		var err error
+		if scope, err = s.readAuthorizer.Authorize(r.Context()); err != nil {
+			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+			return
+		}
+	}
+
+	conn, err := upgrade(w, r)
+	if err != nil {
+		return
+	}
+	defer func(conn *wsConn) {
+		_ = conn.Close()
+	}(conn)
+
+	c := &client{
+		tables: r.URL.Query()["table"],
+		scope:  scope,
+		events: make(chan []byte, s.bufferSize),
+		lagged: make(chan struct{}),
+	}
+	s.add(c)
+	defer s.remove(c)
+
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		conn.readUntilClose()
+	}()
+
+	write := conn.writeBinary
+	if s.codec == codec.JSON {
+		write = conn.writeText
+	}
+	for {
+		select {
+		case event := <-c.events:
+			if err := write(event); err != nil {
+				return
+			}
+		case <-c.lagged:
+			_ = conn.writeClose(closeTryAgainLater, "client fell behind")
+			return
+		case <-closed:
+			return
+		case <-r.Context().Done():
+			_ = conn.writeClose(closeGoingAway, "")
+			return
+		}
+	}
+}
+
+func (s *Server) add(c *client) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.clients[c] = struct{}{}
+}
+
+func (s *Server) remove(c *client) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.clients, c)
+}
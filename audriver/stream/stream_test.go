@@ -0,0 +1,283 @@
+package stream_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mickamy/go-sql-audit-driver/audriver"
+	"github.com/mickamy/go-sql-audit-driver/audriver/codec"
+	"github.com/mickamy/go-sql-audit-driver/audriver/stream"
+	"github.com/mickamy/go-sql-audit-driver/query"
+)
+
+// dial opens a WebSocket connection to path of server with the given bearer token.
+func dial(t *testing.T, server *httptest.Server, path, token string) (*http.Response, *bufio.Reader, net.Conn) {
+	t.Helper()
+	return dialFrom(t, server, path, token, "")
+}
+
+// dialFrom opens a WebSocket connection like dial, from a browser page of origin if it is not empty.
+func dialFrom(t *testing.T, server *httptest.Server, path, token, origin string) (*http.Response, *bufio.Reader, net.Conn) {
+	t.Helper()
+
+	conn, err := net.Dial("tcp", server.Listener.Addr().String())
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = conn.Close()
+	})
+
+	request := "GET " + path + " HTTP/1.1\r\nHost: " + server.Listener.Addr().String() + "\r\n" +
+		"Upgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Version: 13\r\n" +
+		"Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\nAuthorization: Bearer " + token + "\r\n"
+	if origin != "" {
+		request += "Origin: " + origin + "\r\n"
+	}
+	_, err = conn.Write([]byte(request + "\r\n"))
+	require.NoError(t, err)
+
+	reader := bufio.NewReader(conn)
+	res, err := http.ReadResponse(reader, nil)
+	require.NoError(t, err)
+	return res, reader, conn
+}
+
+// readMessage reads a text message sent by the server.
+func readMessage(t *testing.T, conn net.Conn, reader *bufio.Reader) audriver.DatabaseModification {
+	t.Helper()
+
+	header, payload := readFrame(t, conn, reader)
+	require.Equal(t, byte(0x81), header)
+
+	var mod audriver.DatabaseModification
+	require.NoError(t, json.Unmarshal(payload, &mod))
+	return mod
+}
+
+// readFrame reads an unfragmented frame sent by the server, returning its first byte and its payload.
+func readFrame(t *testing.T, conn net.Conn, reader *bufio.Reader) (byte, []byte) {
+	t.Helper()
+
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	var header [2]byte
+	_, err := io.ReadFull(reader, header[:])
+	require.NoError(t, err)
+
+	length := int(header[1])
+	if length == 126 {
+		var extended [2]byte
+		_, err = io.ReadFull(reader, extended[:])
+		require.NoError(t, err)
+		length = int(binary.BigEndian.Uint16(extended[:]))
+	}
+	payload := make([]byte, length)
+	_, err = io.ReadFull(reader, payload)
+	require.NoError(t, err)
+	return header[0], payload
+}
+
+// TestServer tests streaming audit records to authorized WebSocket clients
+func TestServer(t *testing.T) {
+	t.Parallel()
+
+	authorizer := stream.AuthorizerFunc(func(r *http.Request) error {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			return stream.ErrUnauthorized
+		}
+		return nil
+	})
+
+	t.Run("unauthorized", func(t *testing.T) {
+		t.Parallel()
+
+		// arrange
+		server := stream.New(authorizer)
+		httpServer := httptest.NewServer(server)
+		t.Cleanup(httpServer.Close)
+
+		// act
+		res, _, _ := dial(t, httpServer, "/", "wrong")
+
+		// assert
+		assert.Equal(t, http.StatusUnauthorized, res.StatusCode)
+		assert.Zero(t, server.Clients())
+	})
+
+	t.Run("no_authorizer", func(t *testing.T) {
+		t.Parallel()
+
+		// arrange
+		httpServer := httptest.NewServer(stream.New(nil))
+		t.Cleanup(httpServer.Close)
+
+		// act
+		res, _, _ := dial(t, httpServer, "/", "secret")
+
+		// assert
+		assert.Equal(t, http.StatusForbidden, res.StatusCode)
+	})
+
+	t.Run("not_websocket", func(t *testing.T) {
+		t.Parallel()
+
+		// arrange
+		httpServer := httptest.NewServer(stream.New(authorizer))
+		t.Cleanup(httpServer.Close)
+		req, err := http.NewRequestWithContext(t.Context(), http.MethodGet, httpServer.URL, nil)
+		require.NoError(t, err)
+		req.Header.Set("Authorization", "Bearer secret")
+
+		// act
+		res, err := http.DefaultClient.Do(req)
+
+		// assert
+		require.NoError(t, err)
+		_ = res.Body.Close()
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+	})
+
+	t.Run("stream", func(t *testing.T) {
+		t.Parallel()
+
+		// arrange
+		server := stream.New(authorizer)
+		httpServer := httptest.NewServer(server)
+		t.Cleanup(httpServer.Close)
+		res, reader, conn := dial(t, httpServer, "/?table=users", "secret")
+		require.Equal(t, http.StatusSwitchingProtocols, res.StatusCode)
+		require.Equal(t, "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=", res.Header.Get("Sec-WebSocket-Accept"))
+		require.Eventually(t, func() bool { return server.Clients() == 1 }, 5*time.Second, 10*time.Millisecond)
+
+		// act
+		server.Log(t.Context(), audriver.DatabaseModification{ID: "1", TableName: "orders"})
+		server.Log(t.Context(), audriver.DatabaseModification{ID: "2", TableName: "users", SQL: strings.Repeat("x", 200)})
+
+		// assert
+		mod := readMessage(t, conn, reader)
+		assert.Equal(t, "2", mod.ID)
+		assert.Equal(t, "users", mod.TableName)
+
+		// a masked close frame of the client
+		_, err := conn.Write([]byte{0x88, 0x80, 0, 0, 0, 0})
+		require.NoError(t, err)
+		assert.Eventually(t, func() bool { return server.Clients() == 0 }, 5*time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("codec", func(t *testing.T) {
+		t.Parallel()
+
+		// arrange
+		server := stream.New(authorizer, stream.WithCodec(codec.Protobuf))
+		httpServer := httptest.NewServer(server)
+		t.Cleanup(httpServer.Close)
+		res, reader, conn := dial(t, httpServer, "/", "secret")
+		require.Equal(t, http.StatusSwitchingProtocols, res.StatusCode)
+		require.Eventually(t, func() bool { return server.Clients() == 1 }, 5*time.Second, 10*time.Millisecond)
+		mod := audriver.DatabaseModification{ID: "1", TableName: "orders"}
+
+		// act
+		server.Log(t.Context(), mod)
+
+		// assert
+		header, payload := readFrame(t, conn, reader)
+		assert.Equal(t, byte(0x82), header, "records should be sent as binary messages")
+		expected, err := codec.Protobuf.Encode(mod)
+		require.NoError(t, err)
+		assert.Equal(t, expected, payload)
+	})
+
+	t.Run("read_scope", func(t *testing.T) {
+		t.Parallel()
+
+		// arrange
+		server := stream.New(authorizer, stream.WithReadAuthorizer(query.AuthorizerFunc(func(context.Context) (query.Scope, error) {
+			return query.Scope{ShardKeys: []string{"tenant-1"}}, nil
+		})))
+		httpServer := httptest.NewServer(server)
+		t.Cleanup(httpServer.Close)
+		res, reader, conn := dial(t, httpServer, "/", "secret")
+		require.Equal(t, http.StatusSwitchingProtocols, res.StatusCode)
+		require.Eventually(t, func() bool { return server.Clients() == 1 }, 5*time.Second, 10*time.Millisecond)
+
+		// act
+		server.Log(t.Context(), audriver.DatabaseModification{ID: "1", TableName: "orders", ShardKey: "tenant-2"})
+		server.Log(t.Context(), audriver.DatabaseModification{ID: "2", TableName: "orders", ShardKey: "tenant-1"})
+
+		// assert
+		mod := readMessage(t, conn, reader)
+		assert.Equal(t, "2", mod.ID)
+	})
+
+	t.Run("origins", func(t *testing.T) {
+		t.Parallel()
+
+		testCases := []struct {
+			name    string
+			origin  func(server *httptest.Server) string
+			allowed []string
+			want    int
+		}{
+			{name: "no_origin", origin: func(*httptest.Server) string { return "" }, want: http.StatusSwitchingProtocols},
+			{name: "same_origin", origin: func(server *httptest.Server) string { return server.URL }, want: http.StatusSwitchingProtocols},
+			{name: "cross_origin", origin: func(*httptest.Server) string { return "https://evil.example.com" }, want: http.StatusForbidden},
+			{
+				name:    "allowed_origin",
+				origin:  func(*httptest.Server) string { return "https://dashboard.example.com" },
+				allowed: []string{"https://evil.example.com", "https://Dashboard.example.com/"},
+				want:    http.StatusSwitchingProtocols,
+			},
+			{
+				name:    "any_origin",
+				origin:  func(*httptest.Server) string { return "https://dashboard.example.com" },
+				allowed: []string{"*"},
+				want:    http.StatusSwitchingProtocols,
+			},
+		}
+
+		for _, tc := range testCases {
+			tc := tc
+			t.Run(tc.name, func(t *testing.T) {
+				t.Parallel()
+
+				// arrange
+				server := stream.New(authorizer, stream.WithAllowedOrigins(tc.allowed...))
+				httpServer := httptest.NewServer(server)
+				t.Cleanup(httpServer.Close)
+
+				// act
+				res, _, _ := dialFrom(t, httpServer, "/", "secret", tc.origin(httpServer))
+
+				// assert
+				assert.Equal(t, tc.want, res.StatusCode)
+			})
+		}
+	})
+
+	t.Run("read_forbidden", func(t *testing.T) {
+		t.Parallel()
+
+		// arrange
+		server := stream.New(authorizer, stream.WithReadAuthorizer(query.AuthorizerFunc(func(context.Context) (query.Scope, error) {
+			return query.Scope{}, query.ErrForbidden
+		})))
+		httpServer := httptest.NewServer(server)
+		t.Cleanup(httpServer.Close)
+
+		// act
+		res, _, _ := dial(t, httpServer, "/", "secret")
+
+		// assert
+		assert.Equal(t, http.StatusForbidden, res.StatusCode)
+	})
+}
@@ -0,0 +1,222 @@
+package stream
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// websocketGUID is the GUID the accept key of the WebSocket handshake is derived with (RFC 6455, section 1.3).
+const websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// Opcodes of WebSocket frames.
+const (
+	opText   = 0x1
+	opBinary = 0x2
+	opClose  = 0x8
+	opPing   = 0x9
+	opPong   = 0xA
+)
+
+// Status codes of WebSocket close frames.
+const (
+	closeNormal        = 1000
+	closeGoingAway     = 1001
+	closeTryAgainLater = 1013
+)
+
+// maxControlPayload is the maximum payload of control frames; clients only send control frames to the stream.
+const maxControlPayload = 125
+
+// wsConn is the server side of a WebSocket connection that only sends data messages.
+type wsConn struct {
+	conn net.Conn
+	rw   *bufio.ReadWriter
+
+	// mu serializes writes of the streaming loop and of replies to control frames.
+	mu sync.Mutex
+}
+
+// upgrade performs the WebSocket opening handshake and hijacks the connection of w.
+// It responds with 400 Bad Request to requests that are not WebSocket handshakes.
+func upgrade(w http.ResponseWriter, r *http.Request) (*wsConn, error) {
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if r.Method != http.MethodGet || !headerContains(r.Header, "Connection", "upgrade") ||
+		!headerContains(r.Header, "Upgrade", "websocket") || r.Header.Get("Sec-WebSocket-Version") != "13" || key == "" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		http.Error(w, "WebSocket handshake expected", http.StatusBadRequest)
+		return nil, errors.New("not a WebSocket handshake")
+	}
+
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return nil, errors.New("response writer does not support hijacking")
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, fmt.Errorf("failed to hijack connection: %w", err)
+	}
+
+	sum := sha1.Sum([]byte(key + websocketGUID))
+	_, _ = rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n")
+	_, _ = rw.WriteString("Sec-WebSocket-Accept: " + base64.StdEncoding.EncodeToString(sum[:]) + "\r\n\r\n")
+	if err := rw.Flush(); err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("failed to complete handshake: %w", err)
+	}
+	return &wsConn{conn: conn, rw: rw}, nil
+}
+
+// originAllowed reports whether the origin of the handshake r is allowed. Handshakes without Origin are not sent by
+// browsers and are allowed, as are those of the origin of the request itself and of the allowed origins.
+func originAllowed(r *http.Request, allowed []string) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	for _, a := range allowed {
+		if a == "*" || strings.EqualFold(strings.TrimSuffix(a, "/"), origin) {
+			return true
+		}
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(u.Host, r.Host)
+}
+
+// headerContains reports whether the comma-separated values of header name contain token, case-insensitively.
+func headerContains(header http.Header, name, token string) bool {
+	for _, value := range header.Values(name) {
+		for _, v := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(v), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// writeText sends payload as a text message.
+func (c *wsConn) writeText(payload []byte) error {
+	return c.writeFrame(opText, payload)
+}
+
+// writeBinary sends payload as a binary message.
+func (c *wsConn) writeBinary(payload []byte) error {
+	return c.writeFrame(opBinary, payload)
+}
+
+// writeClose sends a close frame with status code and reason.
+func (c *wsConn) writeClose(code int, reason string) error {
+	payload := binary.BigEndian.AppendUint16(nil, uint16(code))
+	return c.writeFrame(opClose, append(payload, reason...))
+}
+
+// writeFrame sends an unfragmented, unmasked frame, as servers do.
+func (c *wsConn) writeFrame(opcode byte, payload []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	header := []byte{0x80 | opcode}
+	switch n := len(payload); {
+	case n <= 125:
+		header = append(header, byte(n))
+	case n <= 0xFFFF:
+		header = binary.BigEndian.AppendUint16(append(header, 126), uint16(n))
+	default:
+		header = binary.BigEndian.AppendUint64(append(header, 127), uint64(n))
+	}
+	if _, err := c.rw.Write(header); err != nil {
+		return err
+	}
+	if _, err := c.rw.Write(payload); err != nil {
+		return err
+	}
+	return c.rw.Flush()
+}
+
+// readUntilClose reads frames of the client, answering pings, until the client closes the connection or breaks the
+// protocol. Data messages of the client are discarded.
+func (c *wsConn) readUntilClose() {
+	for {
+		opcode, payload, err := c.readFrame()
+		if err != nil {
+			return
+		}
+		switch opcode {
+		case opClose:
+			_ = c.writeClose(closeNormal, "")
+			return
+		case opPing:
+			if err := c.writeFrame(opPong, payload); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// readFrame reads a frame of the client, unmasking its payload.
+// Payloads of data frames are discarded, as the stream does not accept messages.
+func (c *wsConn) readFrame() (byte, []byte, error) {
+	var header [2]byte
+	if _, err := io.ReadFull(c.rw, header[:]); err != nil {
+		return 0, nil, err
+	}
+	opcode := header[0] & 0x0F
+	if header[1]&0x80 == 0 {
+		return 0, nil, errors.New("unmasked client frame")
+	}
+
+	length := uint64(header[1] & 0x7F)
+	switch length {
+	case 126:
+		var extended [2]byte
+		if _, err := io.ReadFull(c.rw, extended[:]); err != nil {
+			return 0, nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(extended[:]))
+	case 127:
+		var extended [8]byte
+		if _, err := io.ReadFull(c.rw, extended[:]); err != nil {
+			return 0, nil, err
+		}
+		length = binary.BigEndian.Uint64(extended[:])
+	}
+
+	var mask [4]byte
+	if _, err := io.ReadFull(c.rw, mask[:]); err != nil {
+		return 0, nil, err
+	}
+	if opcode < opClose {
+		_, err := io.CopyN(io.Discard, c.rw, int64(length))
+		return opcode, nil, err
+	}
+	if length > maxControlPayload {
+		return 0, nil, errors.New("control frame too large")
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(c.rw, payload); err != nil {
+		return 0, nil, err
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return opcode, payload, nil
+}
+
+// Close closes the underlying connection.
+func (c *wsConn) Close() error {
+	return c.conn.Close()
+}
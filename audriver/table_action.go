@@ -3,31 +3,86 @@ package audriver
 import (
 	"fmt"
 	"regexp"
+	"strings"
 )
 
 var (
-	insertRegexp = regexp.MustCompile(`(?i)\bINSERT\s+INTO\s+(?:[` + "`" + `"\[]?)([^` + "`" + `"\]\s]+)(?:[` + "`" + `"\]]?)`)
-	updateRegexp = regexp.MustCompile(`(?i)\bUPDATE\s+(?:[` + "`" + `"\[]?)([^` + "`" + `"\]\s]+)(?:[` + "`" + `"\]]?)`)
-	deleteRegexp = regexp.MustCompile(`(?i)\bDELETE\s+FROM\s+(?:[` + "`" + `"\[]?)([^` + "`" + `"\]\s]+)(?:[` + "`" + `"\]]?)`)
+	insertRegexp = regexp.MustCompile(`(?i)\bINSERT\s+INTO\s+`)
+	updateRegexp = regexp.MustCompile(`(?i)\bUPDATE\s+(?:ONLY\s+)?`)
+	deleteRegexp = regexp.MustCompile(`(?i)\bDELETE\s+FROM\s+(?:ONLY\s+)?`)
 )
 
 // tableAction represents a parsed SQL action and its associated table.
 type tableAction struct {
+	// schema is the schema the table is qualified with in the statement, if any.
+	schema string
 	table  string
 	action DatabaseModificationAction
+
+	// end is the index in the statement right after the target table.
+	end int
 }
 
-// actionAndResourceType extracts the action and resource type from the SQL statement.
+// parseTableAction extracts the action and target table from the SQL statement.
+// Keywords in string literals and comments are ignored. Of several modifications, e.g. of a WITH query with
+// data-modifying common table expressions, the outermost one is taken, which is the main statement if it modifies data.
 func parseTableAction(sql string) (tableAction, error) {
-	if match := insertRegexp.FindStringSubmatch(sql); len(match) > 1 {
-		return tableAction{match[1], DatabaseModificationActionInsert}, nil
-	}
-	if match := updateRegexp.FindStringSubmatch(sql); len(match) > 1 {
-		return tableAction{match[1], DatabaseModificationActionUpdate}, nil
+	masked := maskLiterals(sql)
+
+	var (
+		found     bool
+		ta        tableAction
+		start     int
+		bestDepth int
+	)
+	for _, candidate := range []struct {
+		regexp *regexp.Regexp
+		action DatabaseModificationAction
+	}{
+		{insertRegexp, DatabaseModificationActionInsert},
+		{updateRegexp, DatabaseModificationActionUpdate},
+		{deleteRegexp, DatabaseModificationActionDelete},
+	} {
+		pos, depth := 0, 0
+		for _, loc := range candidate.regexp.FindAllStringIndex(masked, -1) {
+			depth, pos = parenDepth(masked, pos, loc[0], depth), loc[0]
+			if candidate.action == DatabaseModificationActionUpdate && isLockingClause(masked, loc[0]) {
+				continue
+			}
+			if found && (depth > bestDepth || depth == bestDepth && loc[0] > start) {
+				continue
+			}
+			parts, end := readIdentifierParts(sql, loc[1])
+			if len(parts) == 0 || parts[len(parts)-1] == "" {
+				continue
+			}
+			found, start, bestDepth = true, loc[0], depth
+			ta = tableAction{
+				schema: strings.Join(parts[:len(parts)-1], "."),
+				table:  parts[len(parts)-1],
+				action: candidate.action,
+				end:    end,
+			}
+		}
 	}
-	if match := deleteRegexp.FindStringSubmatch(sql); len(match) > 1 {
-		return tableAction{match[1], DatabaseModificationActionDelete}, nil
+	if !found {
+		return tableAction{}, fmt.Errorf("could not parse action from SQL: %s", sql)
 	}
 
-	return tableAction{}, fmt.Errorf("could not parse action from SQL: %s", sql)
+	return ta, nil
+}
+
+// isLockingClause reports whether the UPDATE keyword at i of masked SQL is part of a locking clause,
+// i.e. FOR UPDATE or FOR NO KEY UPDATE.
+func isLockingClause(masked string, i int) bool {
+	end := i
+	for end > 0 && isSpace(masked[end-1]) {
+		end--
+	}
+	start := end
+	for start > 0 && isWordChar(masked[start-1]) {
+		start--
+	}
+	word := masked[start:end]
+	return strings.EqualFold(word, "FOR") || strings.EqualFold(word, "KEY")
 }
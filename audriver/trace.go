@@ -0,0 +1,44 @@
+package audriver
+
+import (
+	"context"
+	"encoding/hex"
+	"strings"
+)
+
+// TraceExecutionIDExtractor returns an ExecutionIDExtractor using the trace ID of the active trace as execution ID,
+// unifying audit correlation with distributed tracing. traceID returns the trace ID of ctx as 32 hex digits,
+// or a W3C traceparent header value, e.g. for OpenTelemetry:
+//
+//	audriver.WithExecutionIDExtractor(audriver.TraceExecutionIDExtractor(func(ctx context.Context) string {
+//		return trace.SpanContextFromContext(ctx).TraceID().String()
+//	}))
+//
+// Trace IDs are recorded in UUID format, so that they fit a UUID execution_id column.
+// Without a valid trace, the execution ID set with WithExecutionID is used.
+func TraceExecutionIDExtractor(traceID func(ctx context.Context) string) ExecutionIDExtractor {
+	return ExecutionIDExtractorFunc(func(ctx context.Context) (string, error) {
+		if id, ok := traceIDToUUID(traceID(ctx)); ok {
+			return id, nil
+		}
+		return GetExecutionID(ctx)
+	})
+}
+
+// traceIDToUUID formats a trace ID or traceparent as a UUID.
+// It reports false for malformed and all-zero trace IDs, which are invalid per the W3C Trace Context specification.
+func traceIDToUUID(value string) (string, bool) {
+	// traceparent: version "-" trace-id "-" parent-id "-" trace-flags
+	if parts := strings.Split(value, "-"); len(parts) == 4 {
+		value = parts[1]
+	}
+	if len(value) != 32 || strings.Trim(value, "0") == "" {
+		return "", false
+	}
+	if _, err := hex.DecodeString(value); err != nil {
+		return "", false
+	}
+
+	value = strings.ToLower(value)
+	return value[:8] + "-" + value[8:12] + "-" + value[12:16] + "-" + value[16:20] + "-" + value[20:], true
+}
@@ -0,0 +1,68 @@
+package audriver_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mickamy/go-sql-audit-driver/audriver"
+)
+
+// TestTraceExecutionIDExtractor tests deriving execution IDs from trace IDs
+func TestTraceExecutionIDExtractor(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name        string
+		traceID     string
+		executionID string
+		want        string
+		wantErr     error
+	}{
+		{
+			name:    "trace_id",
+			traceID: "4BF92F3577B34DA6A3CE929D0E0E4736",
+			want:    "4bf92f35-77b3-4da6-a3ce-929d0e0e4736",
+		},
+		{
+			name:    "traceparent",
+			traceID: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+			want:    "4bf92f35-77b3-4da6-a3ce-929d0e0e4736",
+		},
+		{
+			name:        "invalid_trace_falls_back_to_context",
+			traceID:     "00000000000000000000000000000000",
+			executionID: "exec-1",
+			want:        "exec-1",
+		},
+		{
+			name:    "no_trace_nor_execution_id",
+			traceID: "",
+			wantErr: audriver.ErrMissingExecutionID,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// arrange
+			ctx := t.Context()
+			if tc.executionID != "" {
+				ctx = audriver.WithExecutionID(ctx, tc.executionID)
+			}
+			extractor := audriver.TraceExecutionIDExtractor(func(context.Context) string {
+				return tc.traceID
+			})
+
+			// act
+			got, err := extractor.ExtractExecutionID(ctx)
+
+			// assert
+			assert.ErrorIs(t, err, tc.wantErr)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
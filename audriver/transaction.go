@@ -0,0 +1,58 @@
+package audriver
+
+import (
+	"context"
+	"time"
+)
+
+// TransactionOutcome is how a transaction finished.
+type TransactionOutcome string
+
+const (
+	// TransactionCommitted transactions were committed along with their audit records.
+	TransactionCommitted TransactionOutcome = "committed"
+	// TransactionRolledBack transactions were rolled back by the application.
+	TransactionRolledBack TransactionOutcome = "rolled_back"
+	// TransactionFailed transactions failed to commit, e.g. because their audit records could not be written.
+	TransactionFailed TransactionOutcome = "failed"
+)
+
+// TransactionSummary summarizes a finished transaction, giving a quick view of heavyweight transactions.
+type TransactionSummary struct {
+	// ExecutionID is the execution ID of the first audited modification of the transaction, if any.
+	ExecutionID string
+
+	// Outcome is how the transaction finished.
+	Outcome TransactionOutcome
+
+	// Duration is the time from the beginning of the transaction until it finished.
+	Duration time.Duration
+
+	// Statements is the number of statements executed in the transaction, excluding queries.
+	Statements int
+
+	// RowsAffected is the total number of rows affected by the statements, as reported by the driver.
+	RowsAffected int64
+
+	// Modifications is the number of audited modifications of the transaction.
+	Modifications int
+
+	// CommitLSN is the WAL insert location right after the transaction was committed, e.g. "0/16B3748",
+	// if captured with WithCommitLSN. Recovering to it as recovery_target_lsn includes the transaction.
+	CommitLSN string
+}
+
+// TransactionHandler is invoked with the summary of each finished transaction.
+type TransactionHandler func(ctx context.Context, summary TransactionSummary)
+
+// handleTransaction invokes the transaction handler, if any.
+func (b *databaseModificationBuilder) handleTransaction(ctx context.Context, summary TransactionSummary) {
+	if b.transactionHandler == nil {
+		return
+	}
+	defer func() {
+		// the transaction has already finished, so a panicking handler has nothing left to abort
+		_ = recover()
+	}()
+	b.transactionHandler(ctx, summary)
+}
@@ -0,0 +1,113 @@
+package audriver
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/mickamy/go-sql-audit-driver/internal/postgres"
+)
+
+// DefaultTriggerOperatorID is the operator ID recorded by triggers for writes that bypass the application.
+// It is a UUID, as operator_id is a UUID column, reserved for writes not made by an operator of the application.
+const DefaultTriggerOperatorID = "00000000-0000-0000-0000-0000000000db"
+
+// TriggerConfig configures the database triggers generated by GenerateTriggerSQL.
+type TriggerConfig struct {
+	// Tables are the tables to install triggers on, optionally schema-qualified.
+	Tables []string
+
+	// OperatorID is recorded as operator_id for writes captured by the triggers.
+	// It defaults to DefaultTriggerOperatorID, and must be a UUID if operator_id is a UUID column.
+	OperatorID string
+
+	// AuditTable is the audit table written to, optionally schema-qualified. It defaults to DefaultAuditTable.
+	// Unqualified names are qualified with the public schema, as the trigger function runs with a fixed search_path.
+	AuditTable string
+
+	// ExcludedRoles are login roles whose writes are not recorded, typically the application roles
+	// already audited by audriver, so that application writes are not recorded twice.
+	ExcludedRoles []string
+}
+
+// GenerateTriggerSQL generates SQL installing statement-level triggers that write rows compatible with
+// database_modifications for writes bypassing the application, e.g. from psql or migrations.
+// Rows of a single transaction share an execution ID derived from the transaction ID.
+//
+// The trigger function is SECURITY DEFINER, so that roles writing the tables need no privileges on the audit table.
+// It runs with search_path set to pg_catalog and pg_temp, so that writers cannot shadow the objects it refers to.
+func GenerateTriggerSQL(cfg TriggerConfig) string {
+	operatorID := cfg.OperatorID
+	if operatorID == "" {
+		operatorID = DefaultTriggerOperatorID
+	}
+	auditTable := cfg.AuditTable
+	if auditTable == "" {
+		auditTable = DefaultAuditTable
+	}
+	if !strings.Contains(auditTable, ".") {
+		auditTable = "public." + auditTable
+	}
+	auditTable = quoteQualifiedIdentifier(auditTable)
+
+	excluded := make([]string, len(cfg.ExcludedRoles))
+	for i, role := range cfg.ExcludedRoles {
+		excluded[i] = postgres.QuoteLiteral(role)
+	}
+
+	var b strings.Builder
+	_, _ = fmt.Fprintf(&b, `CREATE OR REPLACE FUNCTION audriver_record_out_of_band() RETURNS trigger
+    SECURITY DEFINER
+    SET search_path = pg_catalog, pg_temp AS $$
+DECLARE
+    v_operator_id  %[1]s.operator_id%%TYPE;
+    v_execution_id %[1]s.execution_id%%TYPE;
+    v_action       %[1]s.action%%TYPE;
+BEGIN
+    IF session_user = ANY (ARRAY[%[3]s]::text[]) THEN
+        RETURN NULL;
+    END IF;
+
+    v_operator_id := %[2]s;
+    v_execution_id := md5(txid_current()::text)::uuid;
+    v_action := lower(TG_OP);
+
+    INSERT INTO %[1]s (id, operator_id, execution_id, table_name, action, sql, modified_at)
+    VALUES (gen_random_uuid(), v_operator_id, v_execution_id, TG_TABLE_NAME, v_action, current_query(), now());
+
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+`, auditTable, postgres.QuoteLiteral(operatorID), strings.Join(excluded, ", "))
+
+	for _, table := range cfg.Tables {
+		quoted := quoteQualifiedIdentifier(table)
+		_, _ = fmt.Fprintf(&b, `
+DROP TRIGGER IF EXISTS audriver_record_out_of_band ON %[1]s;
+CREATE TRIGGER audriver_record_out_of_band
+    AFTER INSERT OR UPDATE OR DELETE ON %[1]s
+    FOR EACH STATEMENT EXECUTE FUNCTION audriver_record_out_of_band();
+`, quoted)
+	}
+
+	return b.String()
+}
+
+// InstallTriggers executes the SQL generated by GenerateTriggerSQL on db.
+// It must be run by a role allowed to create functions and triggers on the tables.
+func InstallTriggers(ctx context.Context, db *sql.DB, cfg TriggerConfig) error {
+	if _, err := db.ExecContext(ctx, GenerateTriggerSQL(cfg)); err != nil {
+		return fmt.Errorf("failed to install audit triggers: %w", err)
+	}
+	return nil
+}
+
+// quoteQualifiedIdentifier quotes each part of a possibly schema-qualified identifier.
+func quoteQualifiedIdentifier(name string) string {
+	parts := strings.Split(name, ".")
+	for i, part := range parts {
+		parts[i] = postgres.QuoteIdentifier(part)
+	}
+	return strings.Join(parts, ".")
+}
@@ -0,0 +1,111 @@
+package audriver_test
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mickamy/go-sql-audit-driver/audriver"
+)
+
+// TestGenerateTriggerSQL tests the generated trigger installation SQL
+func TestGenerateTriggerSQL(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name     string
+		cfg      audriver.TriggerConfig
+		contains []string
+	}{
+		{
+			name: "defaults",
+			cfg: audriver.TriggerConfig{
+				Tables: []string{"users"},
+			},
+			contains: []string{
+				`v_operator_id  "public"."database_modifications".operator_id%TYPE;`,
+				`v_operator_id := '00000000-0000-0000-0000-0000000000db';`,
+				`SET search_path = pg_catalog, pg_temp`,
+				`INSERT INTO "public"."database_modifications" (id, operator_id`,
+				`session_user = ANY (ARRAY[]::text[])`,
+				`AFTER INSERT OR UPDATE OR DELETE ON "users"`,
+			},
+		},
+		{
+			name: "custom_operator_and_excluded_roles",
+			cfg: audriver.TriggerConfig{
+				Tables:        []string{"users", "billing.invoices"},
+				OperatorID:    "00000000-0000-0000-0000-000000000000",
+				AuditTable:    "audit.database_modifications",
+				ExcludedRoles: []string{"audriver_writer", "o'brien"},
+			},
+			contains: []string{
+				`v_operator_id := '00000000-0000-0000-0000-000000000000';`,
+				`INSERT INTO "audit"."database_modifications" (id, operator_id`,
+				`session_user = ANY (ARRAY['audriver_writer', 'o''brien']::text[])`,
+				`AFTER INSERT OR UPDATE OR DELETE ON "users"`,
+				`AFTER INSERT OR UPDATE OR DELETE ON "billing"."invoices"`,
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// act
+			sql := audriver.GenerateTriggerSQL(tc.cfg)
+
+			// assert
+			for _, s := range tc.contains {
+				assert.Contains(t, sql, s)
+			}
+		})
+	}
+}
+
+// TestInstallTriggers tests that installed triggers record writes bypassing the application
+func TestInstallTriggers(t *testing.T) {
+	t.Parallel()
+
+	// arrange
+	db, err := sql.Open("txdb_admin", uuid.New().String())
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = db.Close()
+	})
+	require.NoError(t, audriver.InstallTriggers(t.Context(), db, audriver.TriggerConfig{Tables: []string{"users"}}))
+	id := uuid.New().String()
+
+	// act
+	_, err = db.ExecContext(t.Context(), "INSERT INTO users (id, name, email) VALUES ($1, 'trigger', 'trigger@example.com')", id)
+	require.NoError(t, err)
+	_, err = db.ExecContext(t.Context(), "UPDATE users SET name = 'triggered' WHERE id = $1", id)
+	require.NoError(t, err)
+	_, err = db.ExecContext(t.Context(), "DELETE FROM users WHERE id = $1", id)
+	require.NoError(t, err)
+
+	// assert
+	rows, err := db.QueryContext(t.Context(), `SELECT action, sql FROM database_modifications
+		WHERE operator_id = $1 AND table_name = 'users'`, audriver.DefaultTriggerOperatorID)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = rows.Close()
+	})
+	var actions, statements []string
+	for rows.Next() {
+		var action, statement string
+		require.NoError(t, rows.Scan(&action, &statement))
+		actions = append(actions, action)
+		statements = append(statements, statement)
+	}
+	require.NoError(t, rows.Err())
+	assert.ElementsMatch(t, []string{"insert", "update", "delete"}, actions)
+	for _, statement := range statements {
+		assert.Contains(t, statement, "users")
+	}
+}
@@ -0,0 +1,201 @@
+package audriver
+
+import (
+	"strings"
+)
+
+// WherePredicatesMetadataKey is the key of the WHERE predicates in the metadata of database modifications.
+const WherePredicatesMetadataKey = "where"
+
+// WherePredicate is a simple comparison of a column with literal values in the WHERE clause of an UPDATE or DELETE,
+// e.g. user_id = 'X', recorded with WithWherePredicates.
+type WherePredicate struct {
+	// Column is the compared column, without the table or alias it is qualified with.
+	Column string `json:"column"`
+
+	// Op is the comparison operator in upper case: =, <>, <, <=, >, >=, LIKE, ILIKE, IN, IS NULL,
+	// or one of them negated with NOT, e.g. NOT IN. != is recorded as <>.
+	Op string `json:"op"`
+
+	// Value is the value the column is compared to. Values are recorded as text, e.g. "42" for user_id = 42.
+	Value string `json:"value,omitempty"`
+
+	// Values are the values of an IN list.
+	Values []string `json:"values,omitempty"`
+}
+
+// comparisonOperators are the operators of comparisons recorded as predicates, longest first.
+var comparisonOperators = []string{"<=", ">=", "<>", "!=", "=", "<", ">"}
+
+// parseWherePredicates returns the simple comparisons of the WHERE clause of an interpolated UPDATE or DELETE statement.
+// The clause must be a conjunction, so that each predicate holds for every affected row: clauses with a top-level OR
+// yield nothing, and conditions other than comparisons of a column with literals, e.g. subqueries, are left out.
+func parseWherePredicates(sql string) []WherePredicate {
+	ta, err := parseTableAction(sql)
+	if err != nil || ta.action != DatabaseModificationActionUpdate && ta.action != DatabaseModificationActionDelete {
+		return nil
+	}
+	masked := maskLiterals(sql)
+	where := indexTopLevelKeyword(masked[ta.end:], "WHERE")
+	if where < 0 {
+		return nil
+	}
+
+	var (
+		predicates []WherePredicate
+		start      = ta.end + where + len("WHERE")
+		depth      int
+		between    bool
+	)
+	add := func(end int) {
+		if predicate, ok := parseWherePredicate(sql[start:end]); ok {
+			predicates = append(predicates, predicate)
+		}
+	}
+	for i := start; i < len(masked); i++ {
+		switch c := masked[i]; {
+		case c == '"' || c == '`':
+			i = skipQuoted(masked, i)
+		case c == '(':
+			depth++
+		case c == ')':
+			if depth == 0 {
+				// the end of a data-modifying common table expression
+				add(i)
+				return predicates
+			}
+			depth--
+		case depth > 0:
+			// within parenthesized conditions or expressions
+		case c == ';':
+			add(i)
+			return predicates
+		case isWordChar(c) && isWordBoundary(masked, i-1):
+			word, end := readWord(masked, i)
+			switch strings.ToUpper(word) {
+			case "OR":
+				return nil
+			case "RETURNING":
+				add(i)
+				return predicates
+			case "BETWEEN":
+				between = true
+			case "AND":
+				if between {
+					between = false
+					break
+				}
+				add(i)
+				start = end
+			}
+			i = end - 1
+		}
+	}
+	add(len(sql))
+	return predicates
+}
+
+// parseWherePredicate parses a comparison of a column with literals, e.g. t.user_id = '42' or status IN ('a', 'b').
+func parseWherePredicate(condition string) (WherePredicate, bool) {
+	i := skipSpaces(condition, 0)
+	if i >= len(condition) || !isIdentifierStart(condition[i]) {
+		return WherePredicate{}, false
+	}
+	column, i := readIdentifier(condition, i)
+	if column == "" {
+		return WherePredicate{}, false
+	}
+	predicate := WherePredicate{Column: column[strings.LastIndexByte(column, '.')+1:]}
+	i = skipSpaces(condition, i)
+
+	for _, op := range comparisonOperators {
+		if !strings.HasPrefix(condition[i:], op) {
+			continue
+		}
+		value, ok := readLiteralValue(condition, skipSpaces(condition, i+len(op)))
+		if !ok {
+			return WherePredicate{}, false
+		}
+		if op == "!=" {
+			op = "<>"
+		}
+		predicate.Op, predicate.Value = op, value
+		return predicate, true
+	}
+
+	word, i := readWord(condition, i)
+	negated := strings.EqualFold(word, "NOT")
+	if negated {
+		word, i = readWord(condition, skipSpaces(condition, i))
+	}
+	switch op := strings.ToUpper(word); op {
+	case "IS":
+		word, i = readWord(condition, skipSpaces(condition, i))
+		if strings.EqualFold(word, "NOT") {
+			negated = !negated
+			word, i = readWord(condition, skipSpaces(condition, i))
+		}
+		if !strings.EqualFold(word, "NULL") || skipSpaces(condition, i) != len(condition) {
+			return WherePredicate{}, false
+		}
+		predicate.Op = "IS NULL"
+	case "LIKE", "ILIKE":
+		value, ok := readLiteralValue(condition, skipSpaces(condition, i))
+		if !ok {
+			return WherePredicate{}, false
+		}
+		predicate.Op, predicate.Value = op, value
+	case "IN":
+		open := skipSpaces(condition, i)
+		if open >= len(condition) || condition[open] != '(' {
+			return WherePredicate{}, false
+		}
+		closing := indexClosingParen(condition, open)
+		if closing < 0 || skipSpaces(condition, closing+1) != len(condition) {
+			return WherePredicate{}, false
+		}
+		for _, item := range splitTopLevel(condition[open+1 : closing]) {
+			value, ok := readLiteralValue(item, skipSpaces(item, 0))
+			if !ok {
+				return WherePredicate{}, false
+			}
+			predicate.Values = append(predicate.Values, value)
+		}
+		predicate.Op = op
+	default:
+		return WherePredicate{}, false
+	}
+	if negated {
+		predicate.Op = "NOT " + predicate.Op
+	}
+	return predicate, true
+}
+
+// readLiteralValue returns the value of the string or numeric literal starting at i, which must be all that is left
+// of expr but spaces. NULL and other expressions, e.g. columns, functions, or casts, are not literal values.
+func readLiteralValue(expr string, i int) (string, bool) {
+	if i >= len(expr) {
+		return "", false
+	}
+	end := readLiteral(expr, i)
+	if skipSpaces(expr, end) != len(expr) {
+		return "", false
+	}
+	literal := expr[i:end]
+	if expr[i] == '\'' {
+		if len(literal) < 2 || literal[len(literal)-1] != '\'' {
+			return "", false
+		}
+		return strings.ReplaceAll(literal[1:len(literal)-1], "''", "'"), true
+	}
+	digits := strings.TrimPrefix(literal, "-")
+	if digits == "" || digits[0] < '0' || digits[0] > '9' {
+		return "", false
+	}
+	return literal, true
+}
+
+// isIdentifierStart reports whether c may start a column reference.
+func isIdentifierStart(c byte) bool {
+	return c == '"' || c == '`' || c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= 0x80
+}
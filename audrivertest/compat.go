@@ -0,0 +1,248 @@
+// Package audrivertest provides helpers for testing applications and drivers used with audriver.
+package audrivertest
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/mickamy/go-sql-audit-driver/audriver"
+)
+
+// Feature is an audit feature checked by RunDriverCompat.
+type Feature string
+
+const (
+	// FeatureExec is auditing of statements executed outside of transactions.
+	FeatureExec Feature = "exec"
+	// FeatureQuery is passing queries through without auditing them.
+	FeatureQuery Feature = "query"
+	// FeatureTransaction is auditing of statements of committed transactions.
+	FeatureTransaction Feature = "transaction"
+	// FeatureRollback is discarding audit records of rolled back transactions.
+	FeatureRollback Feature = "rollback"
+	// FeaturePrepare is auditing of statements prepared explicitly, e.g. with sql.DB.Prepare.
+	FeaturePrepare Feature = "prepare"
+	// FeatureRecordIDs is recording the IDs of inserted records, which requires driver.Result.LastInsertId.
+	FeatureRecordIDs Feature = "record_ids"
+)
+
+// requiredFeatures are the features audriver cannot work without. The others are reported only.
+var requiredFeatures = map[Feature]bool{
+	FeatureExec:        true,
+	FeatureQuery:       true,
+	FeatureTransaction: true,
+	FeatureRollback:    true,
+}
+
+// CompatReport is the result of RunDriverCompat: nil for each working feature, or the reason it does not work.
+type CompatReport map[Feature]error
+
+// Supported reports whether feature works with the driver.
+func (r CompatReport) Supported(feature Feature) bool {
+	err, ok := r[feature]
+	return ok && err == nil
+}
+
+// RunDriverCompat exercises the Exec, Query, transaction, and Prepare paths of base, wrapped with audriver and options,
+// against the database of dsn, and reports which audit features work. Each feature runs as a subtest;
+// required features fail the test if they do not work, optional ones such as FeaturePrepare are only logged.
+//
+// The statements, like audriver's audit inserts, are written for PostgreSQL, and modify a temporary table.
+// The database needs the audit table, and the audit records written by the checks are left in it,
+// so run the suite against a scratch database:
+//
+//	func TestPgxCompat(t *testing.T) {
+//		report := audrivertest.RunDriverCompat(t, stdlib.GetDefaultDriver(), os.Getenv("TEST_DSN"))
+//		if !report.Supported(audrivertest.FeaturePrepare) {
+//			t.Log("prepare statements through db.ExecContext only")
+//		}
+//	}
+func RunDriverCompat(t *testing.T, base driver.Driver, dsn string, options ...audriver.Option) CompatReport {
+	t.Helper()
+
+	// a single connection keeps the temporary table visible to every check
+	db := sql.OpenDB(dsnConnector{driver: audriver.New(base, options...), dsn: dsn})
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() {
+		_ = db.Close()
+	})
+
+	c := &compat{db: db, table: "audrivertest_compat_" + uuid.New().String()[:8]}
+	query := "CREATE TEMPORARY TABLE " + c.table + " (id INTEGER PRIMARY KEY, name VARCHAR(64))"
+	if _, err := db.ExecContext(t.Context(), query); err != nil {
+		t.Fatalf("failed to create table: %v", err)
+	}
+
+	operatorID := uuid.New().String()
+	report := CompatReport{}
+
+	checks := []struct {
+		feature Feature
+		check   func(ctx context.Context) error
+	}{
+		{FeatureExec, c.exec},
+		{FeatureQuery, c.query},
+		{FeatureTransaction, c.transaction},
+		{FeatureRollback, c.rollback},
+		{FeaturePrepare, c.prepare},
+		{FeatureRecordIDs, c.recordIDs},
+	}
+	for _, check := range checks {
+		t.Run(string(check.feature), func(t *testing.T) {
+			ctx := audriver.WithOperatorID(t.Context(), operatorID)
+			ctx = audriver.WithExecutionID(ctx, uuid.New().String())
+
+			err := check.check(ctx)
+			report[check.feature] = err
+			switch {
+			case err == nil:
+			case requiredFeatures[check.feature]:
+				t.Errorf("%s is not supported: %v", check.feature, err)
+			default:
+				t.Logf("%s is not supported: %v", check.feature, err)
+			}
+		})
+	}
+
+	return report
+}
+
+// compat holds the state shared by the checks of RunDriverCompat.
+type compat struct {
+	db     *sql.DB
+	table  string
+	nextID int
+}
+
+// insert inserts the next record into the table with exec, returning its ID.
+func (c *compat) insert(ctx context.Context, exec func(context.Context, string, ...any) (sql.Result, error)) (int, error) {
+	c.nextID++
+	id := c.nextID
+	if _, err := exec(ctx, "INSERT INTO "+c.table+" (id, name) VALUES ($1, $2)", id, fmt.Sprintf("name-%d", id)); err != nil {
+		return 0, fmt.Errorf("failed to insert: %w", err)
+	}
+	return id, nil
+}
+
+func (c *compat) exec(ctx context.Context) error {
+	if _, err := c.insert(ctx, c.db.ExecContext); err != nil {
+		return err
+	}
+	return c.expectRecords(ctx, 1)
+}
+
+func (c *compat) query(ctx context.Context) error {
+	id, err := c.insert(audriver.WithExecutionID(ctx, uuid.New().String()), c.db.ExecContext)
+	if err != nil {
+		return err
+	}
+
+	var name string
+	if err := c.db.QueryRowContext(ctx, "SELECT name FROM "+c.table+" WHERE id = $1", id).Scan(&name); err != nil {
+		return fmt.Errorf("failed to query: %w", err)
+	}
+	if want := fmt.Sprintf("name-%d", id); name != want {
+		return fmt.Errorf("queried %q, want %q", name, want)
+	}
+	return c.expectRecords(ctx, 0)
+}
+
+func (c *compat) transaction(ctx context.Context) error {
+	tx, err := c.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	for range 2 {
+		if _, err := c.insert(ctx, tx.ExecContext); err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit: %w", err)
+	}
+	return c.expectRecords(ctx, 2)
+}
+
+func (c *compat) rollback(ctx context.Context) error {
+	tx, err := c.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	if _, err := c.insert(ctx, tx.ExecContext); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	if err := tx.Rollback(); err != nil {
+		return fmt.Errorf("failed to roll back: %w", err)
+	}
+	return c.expectRecords(ctx, 0)
+}
+
+func (c *compat) prepare(ctx context.Context) error {
+	stmt, err := c.db.PrepareContext(ctx, "INSERT INTO "+c.table+" (id, name) VALUES ($1, $2)")
+	if err != nil {
+		return fmt.Errorf("failed to prepare: %w", err)
+	}
+	defer func(stmt *sql.Stmt) {
+		_ = stmt.Close()
+	}(stmt)
+
+	_, err = c.insert(ctx, func(ctx context.Context, _ string, args ...any) (sql.Result, error) {
+		return stmt.ExecContext(ctx, args...)
+	})
+	if err != nil {
+		return err
+	}
+	return c.expectRecords(ctx, 1)
+}
+
+func (c *compat) recordIDs(ctx context.Context) error {
+	if _, err := c.insert(ctx, c.db.ExecContext); err != nil {
+		return err
+	}
+	executionID, _ := audriver.GetExecutionID(ctx)
+	var recordIDs sql.NullString
+	query := "SELECT record_ids FROM " + audriver.DefaultAuditTable + " WHERE execution_id = $1"
+	if err := c.db.QueryRowContext(ctx, query, executionID).Scan(&recordIDs); err != nil {
+		return fmt.Errorf("failed to query audit records: %w", err)
+	}
+	if !recordIDs.Valid {
+		return errors.New("no record IDs recorded; the driver does not support LastInsertId")
+	}
+	return nil
+}
+
+// expectRecords checks that want audit records were written for the execution ID of ctx.
+func (c *compat) expectRecords(ctx context.Context, want int) error {
+	executionID, _ := audriver.GetExecutionID(ctx)
+	var got int
+	query := "SELECT count(*) FROM " + audriver.DefaultAuditTable + " WHERE execution_id = $1"
+	if err := c.db.QueryRowContext(ctx, query, executionID).Scan(&got); err != nil {
+		return fmt.Errorf("failed to query audit records: %w", err)
+	}
+	if got != want {
+		return fmt.Errorf("%d audit records written, want %d", got, want)
+	}
+	return nil
+}
+
+// dsnConnector opens connections of a driver with a fixed data source name, without registering the driver.
+type dsnConnector struct {
+	driver driver.Driver
+	dsn    string
+}
+
+func (c dsnConnector) Connect(context.Context) (driver.Conn, error) {
+	return c.driver.Open(c.dsn)
+}
+
+func (c dsnConnector) Driver() driver.Driver {
+	return c.driver
+}
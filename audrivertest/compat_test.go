@@ -0,0 +1,28 @@
+package audrivertest_test
+
+import (
+	"testing"
+
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mickamy/go-sql-audit-driver/audrivertest"
+)
+
+const writerDSN = "user=audriver_writer password=password dbname=audriver host=localhost port=5432 sslmode=disable"
+
+// TestRunDriverCompat tests the compatibility suite against lib/pq
+func TestRunDriverCompat(t *testing.T) {
+	t.Parallel()
+
+	// act
+	report := audrivertest.RunDriverCompat(t, &pq.Driver{}, writerDSN)
+
+	// assert
+	assert.True(t, report.Supported(audrivertest.FeatureExec))
+	assert.True(t, report.Supported(audrivertest.FeatureQuery))
+	assert.True(t, report.Supported(audrivertest.FeatureTransaction))
+	assert.True(t, report.Supported(audrivertest.FeatureRollback))
+	assert.False(t, report.Supported(audrivertest.FeaturePrepare), "explicitly prepared statements bypass auditing")
+	assert.False(t, report.Supported(audrivertest.FeatureRecordIDs), "lib/pq does not support LastInsertId")
+}
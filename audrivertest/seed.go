@@ -0,0 +1,241 @@
+package audrivertest
+
+import (
+	"context"
+	"database/sql"
+	"encoding/binary"
+	"fmt"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/mickamy/go-sql-audit-driver/audriver"
+)
+
+// defaultSeedTables are the tables modified by the records of Seed, from the most to the least frequently modified.
+var defaultSeedTables = []string{"users", "orders", "order_items", "payments", "sessions", "products", "addresses", "coupons"}
+
+// defaultSeedActions are the relative frequencies of the actions of the records of Seed.
+var defaultSeedActions = map[audriver.DatabaseModificationAction]int{
+	audriver.DatabaseModificationActionInsert: 6,
+	audriver.DatabaseModificationActionUpdate: 3,
+	audriver.DatabaseModificationActionDelete: 1,
+}
+
+// hourWeights are the relative frequencies of modifications per hour of the day, peaking in business hours.
+var hourWeights = [24]int{1, 1, 1, 1, 1, 2, 3, 5, 8, 10, 10, 10, 9, 10, 10, 10, 9, 8, 6, 4, 3, 2, 2, 1}
+
+// seedColumns are the columns written by Seed, which are the ones every version of the audit table has.
+var seedColumns = []string{"id", "operator_id", "execution_id", "table_name", "action", "sql", "modified_at"}
+
+// SeedOptions configures the audit records generated by Seed. Zero fields take their defaults.
+type SeedOptions struct {
+	// AuditTable is the table the records are written into. It defaults to audriver.DefaultAuditTable.
+	AuditTable string
+
+	// Tables are the tables the records modify, from the most to the least frequently modified.
+	// It defaults to a set of tables of a typical web shop.
+	Tables []string
+
+	// Actions are the relative frequencies of the actions of the records. It defaults to 60% inserts, 30% updates,
+	// and 10% deletes.
+	Actions map[audriver.DatabaseModificationAction]int
+
+	// Since and Until bound the modification times of the records, which follow a daily cycle peaking in business
+	// hours. Until defaults to now and Since to 30 days before Until.
+	Since time.Time
+	Until time.Time
+
+	// Operators is the number of distinct operators, a few of which modify most records. It defaults to 50.
+	Operators int
+
+	// MaxExecutionSize is the maximum number of records sharing an execution ID. It defaults to 5.
+	MaxExecutionSize int
+
+	// BatchSize is the number of records written per INSERT statement. It defaults to 500.
+	BatchSize int
+
+	// RandSeed seeds the generator, so that equal options generate equal records apart from their IDs.
+	RandSeed uint64
+}
+
+// withDefaults returns o with the defaults of its zero fields.
+func (o SeedOptions) withDefaults() SeedOptions {
+	if o.AuditTable == "" {
+		o.AuditTable = audriver.DefaultAuditTable
+	}
+	if len(o.Tables) == 0 {
+		o.Tables = defaultSeedTables
+	}
+	if len(o.Actions) == 0 {
+		o.Actions = defaultSeedActions
+	}
+	if o.Until.IsZero() {
+		o.Until = time.Now()
+	}
+	if o.Since.IsZero() {
+		o.Since = o.Until.AddDate(0, 0, -30)
+	}
+	if o.Operators <= 0 {
+		o.Operators = 50
+	}
+	if o.MaxExecutionSize <= 0 {
+		o.MaxExecutionSize = 5
+	}
+	if o.BatchSize <= 0 {
+		o.BatchSize = 500
+	}
+	return o
+}
+
+// Seed writes n generated audit records into the audit table of db, to test the performance of retention jobs,
+// queries, and dashboards against realistically sized and distributed audit tables:
+//
+//	err := audrivertest.Seed(ctx, db, 1_000_000, audrivertest.SeedOptions{RandSeed: 1})
+//
+// Records are spread across tables and operators with a long tail, as in production, and grouped into executions
+// of a few records each. Only the columns every version of the audit table has are written.
+func Seed(ctx context.Context, db *sql.DB, n int, opts SeedOptions) error {
+	opts = opts.withDefaults()
+	if !opts.Since.Before(opts.Until) {
+		return fmt.Errorf("invalid seed period: %s is not before %s", opts.Since, opts.Until)
+	}
+
+	g := newSeedGenerator(opts)
+	batch := make([]audriver.DatabaseModification, 0, min(n, opts.BatchSize))
+	for written := 0; written < n; {
+		batch = g.execution(batch, min(n-written, 1+g.rand.IntN(opts.MaxExecutionSize)))
+		if len(batch) < opts.BatchSize && written+len(batch) < n {
+			continue
+		}
+		if err := insertSeed(ctx, db, opts.AuditTable, batch); err != nil {
+			return err
+		}
+		written += len(batch)
+		batch = batch[:0]
+	}
+	return nil
+}
+
+// seedGenerator generates the audit records of Seed.
+type seedGenerator struct {
+	opts      SeedOptions
+	rand      *rand.Rand
+	tables    *rand.Zipf
+	operators *rand.Zipf
+
+	operatorIDs []string
+	actions     []audriver.DatabaseModificationAction
+	days        int
+	hourTotal   int
+}
+
+func newSeedGenerator(opts SeedOptions) *seedGenerator {
+	var seed [32]byte
+	binary.LittleEndian.PutUint64(seed[:], opts.RandSeed)
+	source := rand.NewChaCha8(seed)
+	r := rand.New(source)
+	g := &seedGenerator{
+		opts:      opts,
+		rand:      r,
+		tables:    rand.NewZipf(r, 1.1, 1, uint64(len(opts.Tables)-1)),
+		operators: rand.NewZipf(r, 1.2, 1, uint64(opts.Operators-1)),
+		days:      max(1, int(opts.Until.Sub(opts.Since).Hours()/24)),
+	}
+	for range opts.Operators {
+		g.operatorIDs = append(g.operatorIDs, uuid.Must(uuid.NewRandomFromReader(source)).String())
+	}
+	// in a fixed order, so that equal seeds pick equal actions
+	actions := []audriver.DatabaseModificationAction{
+		audriver.DatabaseModificationActionInsert,
+		audriver.DatabaseModificationActionUpdate,
+		audriver.DatabaseModificationActionDelete,
+	}
+	for _, action := range actions {
+		for range opts.Actions[action] {
+			g.actions = append(g.actions, action)
+		}
+	}
+	for _, weight := range hourWeights {
+		g.hourTotal += weight
+	}
+	return g
+}
+
+// execution appends size records of a single execution of one operator to mods.
+func (g *seedGenerator) execution(mods []audriver.DatabaseModification, size int) []audriver.DatabaseModification {
+	executionID := uuid.NewString()
+	operatorID := g.operatorIDs[g.operators.Uint64()]
+	modifiedAt := g.modifiedAt()
+	for range size {
+		table := g.opts.Tables[g.tables.Uint64()]
+		action := g.actions[g.rand.IntN(len(g.actions))]
+		mods = append(mods, audriver.DatabaseModification{
+			ID:          uuid.NewString(),
+			OperatorID:  operatorID,
+			ExecutionID: executionID,
+			TableName:   table,
+			Action:      action,
+			SQL:         g.sql(table, action, modifiedAt),
+			ModifiedAt:  modifiedAt,
+		})
+		modifiedAt = modifiedAt.Add(time.Duration(1+g.rand.IntN(50)) * time.Millisecond)
+	}
+	return mods
+}
+
+// modifiedAt returns a random time within the seed period, following the daily cycle of hourWeights.
+func (g *seedGenerator) modifiedAt() time.Time {
+	pick, hour := g.rand.IntN(g.hourTotal), 0
+	for ; pick >= hourWeights[hour]; hour++ {
+		pick -= hourWeights[hour]
+	}
+	day := g.opts.Since.Truncate(24*time.Hour).AddDate(0, 0, g.rand.IntN(g.days+1))
+	t := day.Add(time.Duration(hour)*time.Hour + time.Duration(g.rand.Int64N(int64(time.Hour))))
+	for t.Before(g.opts.Since) || !t.Before(g.opts.Until) {
+		t = g.opts.Since.Add(time.Duration(g.rand.Int64N(int64(g.opts.Until.Sub(g.opts.Since)))))
+	}
+	return t
+}
+
+// sql returns a statement of action modifying a record of table at modifiedAt.
+func (g *seedGenerator) sql(table string, action audriver.DatabaseModificationAction, modifiedAt time.Time) string {
+	id := strconv.Itoa(1 + g.rand.IntN(1_000_000))
+	timestamp := modifiedAt.UTC().Format("2006-01-02 15:04:05.000000")
+	switch action {
+	case audriver.DatabaseModificationActionInsert:
+		return "INSERT INTO " + table + " (id, name, created_at) VALUES (" + id + ", '" + table + "-" + id + "', '" + timestamp + "')"
+	case audriver.DatabaseModificationActionUpdate:
+		return "UPDATE " + table + " SET name = '" + table + "-" + id + "', updated_at = '" + timestamp + "' WHERE id = " + id
+	default:
+		return "DELETE FROM " + table + " WHERE id = " + id
+	}
+}
+
+// insertSeed writes mods into auditTable with a single INSERT statement.
+func insertSeed(ctx context.Context, db *sql.DB, auditTable string, mods []audriver.DatabaseModification) error {
+	var query strings.Builder
+	query.WriteString("INSERT INTO " + auditTable + " (" + strings.Join(seedColumns, ", ") + ") VALUES ")
+	args := make([]any, 0, len(mods)*len(seedColumns))
+	for i, mod := range mods {
+		if i > 0 {
+			query.WriteString(", ")
+		}
+		query.WriteByte('(')
+		for j := range seedColumns {
+			if j > 0 {
+				query.WriteString(", ")
+			}
+			query.WriteString("$" + strconv.Itoa(len(args)+j+1))
+		}
+		query.WriteByte(')')
+		args = append(args, mod.ID, mod.OperatorID, mod.ExecutionID, mod.TableName, mod.Action.String(), mod.SQL, mod.ModifiedAt)
+	}
+	if _, err := db.ExecContext(ctx, query.String(), args...); err != nil {
+		return fmt.Errorf("failed to write seed records: %w", err)
+	}
+	return nil
+}
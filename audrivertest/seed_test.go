@@ -0,0 +1,70 @@
+package audrivertest_test
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	_ "github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mickamy/go-sql-audit-driver/audriver"
+	"github.com/mickamy/go-sql-audit-driver/audrivertest"
+)
+
+// TestSeed tests generating audit records spread across tables, actions, and the seed period
+func TestSeed(t *testing.T) {
+	t.Parallel()
+
+	// arrange
+	db, err := sql.Open("postgres", writerDSN)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = db.Close()
+	})
+	table := "seed_" + uuid.New().String()[:8]
+	until := time.Now().Truncate(time.Second)
+	since := until.Add(-72 * time.Hour)
+
+	// act
+	err = audrivertest.Seed(t.Context(), db, 1200, audrivertest.SeedOptions{
+		Tables:    []string{table + "_a", table + "_b", table + "_c"},
+		Since:     since,
+		Until:     until,
+		BatchSize: 100,
+		RandSeed:  1,
+	})
+
+	// assert
+	require.NoError(t, err)
+	rows, err := db.QueryContext(t.Context(), "SELECT table_name, action, count(*), min(modified_at), max(modified_at) FROM "+
+		audriver.DefaultAuditTable+" WHERE table_name LIKE $1 GROUP BY table_name, action", table+"_%")
+	require.NoError(t, err)
+	defer func(rows *sql.Rows) {
+		_ = rows.Close()
+	}(rows)
+
+	total := 0
+	perTable := map[string]int{}
+	perAction := map[string]int{}
+	for rows.Next() {
+		var (
+			tableName, action string
+			count             int
+			first, last       time.Time
+		)
+		require.NoError(t, rows.Scan(&tableName, &action, &count, &first, &last))
+		total += count
+		perTable[tableName] += count
+		perAction[action] += count
+		assert.False(t, first.Before(since), "records should not precede the seed period")
+		assert.True(t, last.Before(until.Add(time.Second)), "records should not follow the seed period")
+	}
+	require.NoError(t, rows.Err())
+	assert.Equal(t, 1200, total)
+	assert.Greater(t, perTable[table+"_a"], perTable[table+"_c"], "earlier tables should be modified more frequently")
+	assert.Greater(t, perAction["insert"], perAction["update"])
+	assert.Greater(t, perAction["update"], perAction["delete"])
+}
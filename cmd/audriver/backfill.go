@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/mickamy/go-sql-audit-driver/audriver"
+)
+
+func runBackfill(ctx context.Context, args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("backfill", flag.ContinueOnError)
+	dsn := flags.String("dsn", os.Getenv("AUDRIVER_DSN"), "data source name of the database holding database_modifications and the slot (default $AUDRIVER_DSN)")
+	from := flags.String("from", "", "backfill transactions committed at or after this RFC 3339 time")
+	to := flags.String("to", "", "stop at the first transaction committed at or after this RFC 3339 time")
+	tables := flags.String("tables", "", "comma-separated tables to backfill, optionally schema-qualified (default all)")
+	format := flags.String("format", "text", "output format: text or json")
+	var cfg audriver.BackfillConfig
+	flags.StringVar(&cfg.Slot, "slot", "", "logical replication slot created with the wal2json output plugin")
+	flags.StringVar(&cfg.OperatorID, "operator-id", audriver.DefaultTriggerOperatorID, "operator ID recorded for backfilled modifications")
+	flags.StringVar(&cfg.AuditTable, "table", audriver.DefaultAuditTable, "audit table to write to")
+	flags.IntVar(&cfg.BatchSize, "batch-size", audriver.DefaultBackfillBatchSize, "changes decoded per round trip")
+	flags.BoolVar(&cfg.DryRun, "dry-run", false, "report what would be backfilled without writing records or consuming the slot")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if *dsn == "" {
+		return errors.New("-dsn or $AUDRIVER_DSN is required")
+	}
+	if cfg.Slot == "" {
+		return errors.New("-slot is required")
+	}
+	if *format != "text" && *format != "json" {
+		return fmt.Errorf("unsupported format: %s", *format)
+	}
+
+	cfg.Tables = splitList(*tables)
+	var err error
+	if cfg.From, err = parseTime(*from); err != nil {
+		return err
+	}
+	if cfg.To, err = parseTime(*to); err != nil {
+		return err
+	}
+
+	db, err := sql.Open("postgres", *dsn)
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer func(db *sql.DB) {
+		_ = db.Close()
+	}(db)
+
+	result, err := audriver.Backfill(ctx, db, cfg)
+	if *format == "json" {
+		encoder := json.NewEncoder(stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(result); err != nil {
+			return err
+		}
+	} else {
+		_, _ = fmt.Fprintf(stdout, "Backfilled %d modifications of %d transactions\n", result.Modifications, result.Transactions)
+		_, _ = fmt.Fprintf(stdout, "Skipped %d audited and %d other transactions\n", result.Audited, result.Skipped)
+		if result.LSN != "" {
+			_, _ = fmt.Fprintf(stdout, "Consumed up to %s\n", result.LSN)
+		}
+	}
+	// the result of a failed run tells how far it got
+	return err
+}
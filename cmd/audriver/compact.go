@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/mickamy/go-sql-audit-driver/audriver"
+)
+
+func runCompact(ctx context.Context, args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("compact", flag.ContinueOnError)
+	dsn := flags.String("dsn", os.Getenv("AUDRIVER_DSN"), "data source name of the database holding database_modifications (default $AUDRIVER_DSN)")
+	olderThan := flags.Int("older-than-days", 0, "compact records older than this number of days")
+	granularity := flags.String("granularity", string(audriver.CompactByTransaction), "what aggregates stand for: transaction or day")
+	tables := flags.String("tables", "", "comma-separated tables to compact (default all)")
+	table := flags.String("table", audriver.DefaultAuditTable, "audit table to compact")
+	format := flags.String("format", "text", "output format: text or json")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if *dsn == "" {
+		return errors.New("-dsn or $AUDRIVER_DSN is required")
+	}
+	if *olderThan <= 0 {
+		return errors.New("-older-than-days is required")
+	}
+	if *format != "text" && *format != "json" {
+		return fmt.Errorf("unsupported format: %s", *format)
+	}
+
+	db, err := sql.Open("postgres", *dsn)
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer func(db *sql.DB) {
+		_ = db.Close()
+	}(db)
+
+	result, err := audriver.Compact(ctx, db, audriver.CompactionConfig{
+		OlderThan:   time.Duration(*olderThan) * 24 * time.Hour,
+		Granularity: audriver.CompactionGranularity(*granularity),
+		Tables:      splitList(*tables),
+		AuditTable:  *table,
+	})
+	if *format == "json" {
+		encoder := json.NewEncoder(stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(result); err != nil {
+			return err
+		}
+	} else {
+		_, _ = fmt.Fprintf(stdout, "Compacted %d records into %d aggregates\n", result.Compacted, result.Aggregates)
+	}
+	// the result of a failed run tells how far it got
+	return err
+}
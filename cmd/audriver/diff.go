@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/mickamy/go-sql-audit-driver/audriver"
+	"github.com/mickamy/go-sql-audit-driver/query"
+)
+
+func runDiff(ctx context.Context, args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("diff", flag.ContinueOnError)
+	dsn := flags.String("dsn", os.Getenv("AUDRIVER_DSN"), "data source name of the database holding database_modifications (default $AUDRIVER_DSN)")
+	auditTable := flags.String("audit-table", audriver.DefaultAuditTable, "audit table to read, optionally schema-qualified")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if *dsn == "" {
+		return errors.New("-dsn or $AUDRIVER_DSN is required")
+	}
+	if flags.NArg() != 2 {
+		return errors.New("usage: audriver diff [flags] <execution-id> <other-execution-id>")
+	}
+
+	db, err := sql.Open("postgres", *dsn)
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer func(db *sql.DB) {
+		_ = db.Close()
+	}(db)
+
+	diff, err := query.DiffExecutions(ctx, db, query.Filter{AuditTable: *auditTable}, flags.Arg(0), flags.Arg(1))
+	if err != nil {
+		return err
+	}
+
+	writeChanges(stdout, "unreverted", diff.Unreverted)
+	writeChanges(stdout, "additional", diff.Additional)
+
+	if len(diff.Unreverted) > 0 {
+		return fmt.Errorf("%d tables have unreverted changes", len(diff.Unreverted))
+	}
+	return nil
+}
+
+func writeChanges(w io.Writer, kind string, changes []query.TableChange) {
+	for _, change := range changes {
+		if len(change.RecordIDs) == 0 {
+			_, _ = fmt.Fprintf(w, "%s\t%s\n", kind, change.Table)
+			continue
+		}
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\n", kind, change.Table, strings.Join(change.RecordIDs, ","))
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/mickamy/go-sql-audit-driver/audriver"
+	"github.com/mickamy/go-sql-audit-driver/query"
+)
+
+func runVerifyDualWrite(ctx context.Context, args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("verify-dual-write", flag.ContinueOnError)
+	dsn := flags.String("dsn", os.Getenv("AUDRIVER_DSN"), "data source name of the database holding database_modifications and the legacy table (default $AUDRIVER_DSN)")
+	from := flags.String("from", "", "compare records modified at or after this RFC 3339 time (default an hour before -to)")
+	to := flags.String("to", "", "compare records modified before this RFC 3339 time (default now)")
+	format := flags.String("format", "text", "output format: text or json")
+	exclude := flags.String("exclude", "", "comma-separated tables not expected to be audited by audriver")
+	auditTable := flags.String("audit-table", audriver.DefaultAuditTable, "audit table to compare, optionally schema-qualified")
+	var legacy query.LegacyTable
+	flags.StringVar(&legacy.Name, "legacy-table", "audit.logged_actions", "table written by the legacy audit triggers")
+	flags.StringVar(&legacy.TableColumn, "legacy-table-column", "table_name", "column of the legacy table holding the modified table")
+	flags.StringVar(&legacy.ActionColumn, "legacy-action-column", "action", "column of the legacy table holding the action")
+	flags.StringVar(&legacy.TransactionColumn, "legacy-transaction-column", "transaction_id", "column of the legacy table holding the transaction ID")
+	flags.StringVar(&legacy.TimeColumn, "legacy-time-column", "action_tstamp_tx", "column of the legacy table holding the time of the modification")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if *dsn == "" {
+		return errors.New("-dsn or $AUDRIVER_DSN is required")
+	}
+	if *format != "text" && *format != "json" {
+		return fmt.Errorf("unsupported format: %s", *format)
+	}
+
+	opts := query.DualWriteOptions{Legacy: legacy, ExcludedTables: splitList(*exclude), AuditTable: *auditTable}
+	var err error
+	if opts.From, err = parseTime(*from); err != nil {
+		return err
+	}
+	if opts.To, err = parseTime(*to); err != nil {
+		return err
+	}
+
+	db, err := sql.Open("postgres", *dsn)
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer func(db *sql.DB) {
+		_ = db.Close()
+	}(db)
+
+	report, err := query.VerifyDualWrite(ctx, db, opts)
+	if err != nil {
+		return err
+	}
+
+	discrepancies := report.Discrepancies()
+	if *format == "json" {
+		encoder := json.NewEncoder(stdout)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(struct {
+			query.DualWriteReport
+			Discrepancies []query.DualWriteCount `json:"discrepancies"`
+		}{report, discrepancies})
+	} else {
+		err = writeDualWriteReport(stdout, report, discrepancies)
+	}
+	if err != nil {
+		return err
+	}
+
+	// a failing exit status lets the verification gate a rollout, e.g. in CI
+	if len(discrepancies) > 0 {
+		return fmt.Errorf("%d discrepancies with %s", len(discrepancies), legacy.Name)
+	}
+	return nil
+}
+
+// writeDualWriteReport writes report as plain text.
+func writeDualWriteReport(w io.Writer, report query.DualWriteReport, discrepancies []query.DualWriteCount) error {
+	_, _ = fmt.Fprintf(w, "Dual write verification\n")
+	_, _ = fmt.Fprintf(w, "Window: %s - %s\n\n", report.From.Format(time.RFC3339), report.To.Format(time.RFC3339))
+
+	_, _ = fmt.Fprintf(w, "Transactions per table and action (legacy / audriver):\n")
+	for _, count := range report.Counts {
+		_, _ = fmt.Fprintf(w, "  %s\t%s\t%d / %d\n", count.Table, count.Action, count.Legacy, count.Audited)
+	}
+
+	_, _ = fmt.Fprintf(w, "\nDiscrepancies: %d\n", len(discrepancies))
+	for _, count := range discrepancies {
+		_, _ = fmt.Fprintf(w, "  %s\t%s\t%+d\n", count.Table, count.Action, count.Audited-count.Legacy)
+	}
+
+	_, err := fmt.Fprintln(w)
+	return err
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/x509"
+	"database/sql"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/mickamy/go-sql-audit-driver/audriver"
+	"github.com/mickamy/go-sql-audit-driver/evidence"
+)
+
+func runEvidence(ctx context.Context, args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("evidence", flag.ContinueOnError)
+	dsn := flags.String("dsn", os.Getenv("AUDRIVER_DSN"), "data source name of the database holding database_modifications (default $AUDRIVER_DSN)")
+	from := flags.String("from", "", "bundle records modified at or after this RFC 3339 time (default 24 hours before -to)")
+	to := flags.String("to", "", "bundle records modified before this RFC 3339 time (default now)")
+	exclude := flags.String("exclude", "", "comma-separated tables not expected to be audited")
+	config := flags.String("config", "", "JSON file with a snapshot of the audit configuration to include")
+	signingKey := flags.String("signing-key", "", "PEM file with the PKCS #8 Ed25519 private key to sign the bundle with")
+	output := flags.String("output", "", "file to write the bundle to (default stdout)")
+	auditTable := flags.String("audit-table", audriver.DefaultAuditTable, "audit table to bundle, optionally schema-qualified")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if *dsn == "" {
+		return errors.New("-dsn or $AUDRIVER_DSN is required")
+	}
+	if *signingKey == "" {
+		return errors.New("-signing-key is required")
+	}
+
+	opts := evidence.Options{ExcludedTables: splitList(*exclude), AuditTable: *auditTable}
+	var err error
+	if opts.From, err = parseTime(*from); err != nil {
+		return err
+	}
+	if opts.To, err = parseTime(*to); err != nil {
+		return err
+	}
+	if opts.SigningKey, err = readSigningKey(*signingKey); err != nil {
+		return err
+	}
+	if *config != "" {
+		data, err := os.ReadFile(*config)
+		if err != nil {
+			return fmt.Errorf("failed to read config: %w", err)
+		}
+		if !json.Valid(data) {
+			return fmt.Errorf("config %s is not valid JSON", *config)
+		}
+		opts.Config = json.RawMessage(data)
+	}
+
+	out := stdout
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			return fmt.Errorf("failed to create output: %w", err)
+		}
+		defer func(f *os.File) {
+			_ = f.Close()
+		}(f)
+		out = f
+	}
+
+	db, err := sql.Open("postgres", *dsn)
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer func(db *sql.DB) {
+		_ = db.Close()
+	}(db)
+
+	_, err = evidence.Export(ctx, db, out, opts)
+	return err
+}
+
+func runVerifyEvidence(args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("verify-evidence", flag.ContinueOnError)
+	publicKey := flags.String("public-key", "", "PEM file with the PKIX Ed25519 public key the bundle was signed with")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if *publicKey == "" {
+		return errors.New("-public-key is required")
+	}
+	if flags.NArg() != 1 {
+		return errors.New("usage: audriver verify-evidence -public-key <file> <bundle>")
+	}
+
+	key, err := readPublicKey(*publicKey)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Open(flags.Arg(0))
+	if err != nil {
+		return fmt.Errorf("failed to open bundle: %w", err)
+	}
+	defer func(f *os.File) {
+		_ = f.Close()
+	}(f)
+
+	manifest, err := evidence.Verify(f, key)
+	if err != nil {
+		return err
+	}
+
+	_, _ = fmt.Fprintf(stdout, "Evidence bundle verified\n")
+	_, _ = fmt.Fprintf(stdout, "Period:       %s - %s\n", manifest.From.Format(time.RFC3339), manifest.To.Format(time.RFC3339))
+	_, _ = fmt.Fprintf(stdout, "Generated at: %s\n", manifest.GeneratedAt.Format(time.RFC3339))
+	for _, file := range manifest.Files {
+		_, _ = fmt.Fprintf(stdout, "  %s\t%d bytes\tsha256:%s\n", file.Name, file.Size, file.SHA256)
+	}
+	return nil
+}
+
+// readSigningKey reads a PEM-encoded PKCS #8 Ed25519 private key, as generated by `openssl genpkey -algorithm ed25519`.
+func readSigningKey(path string) (ed25519.PrivateKey, error) {
+	der, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+	key, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse signing key: %w", err)
+	}
+	edKey, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("signing key is %T, not Ed25519", key)
+	}
+	return edKey, nil
+}
+
+// readPublicKey reads a PEM-encoded PKIX Ed25519 public key, as generated by `openssl pkey -pubout`.
+func readPublicKey(path string) (ed25519.PublicKey, error) {
+	der, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+	key, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse public key: %w", err)
+	}
+	edKey, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("public key is %T, not Ed25519", key)
+	}
+	return edKey, nil
+}
+
+func readPEM(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key: %w", err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s is not PEM-encoded", path)
+	}
+	return block.Bytes, nil
+}
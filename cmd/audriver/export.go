@@ -0,0 +1,333 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	_ "github.com/lib/pq"
+
+	"github.com/mickamy/go-sql-audit-driver/audriver"
+	"github.com/mickamy/go-sql-audit-driver/audriver/codec"
+	"github.com/mickamy/go-sql-audit-driver/internal/parquet"
+	"github.com/mickamy/go-sql-audit-driver/query"
+)
+
+// csvHeader are the columns of CSV exports.
+var csvHeader = []string{
+	"id", "operator_id", "execution_id", "schema_name", "table_name", "foreign_table", "action", "sql",
+	"modified_at", "record_ids", "source_tables", "changed_columns", "exactness", "operator_name", "operator_email",
+	"shard", "shard_key", "backend_pid", "transaction_id", "estimated_rows",
+	"idempotency_key", "parent_execution_id", "step", "client_ip", "user_agent", "device", "checksum", "old_values", "new_values", "metadata",
+}
+
+// parquetColumns are the columns of Parquet exports, named like those of CSV exports.
+var parquetColumns = []parquet.Column{
+	{Name: "id", Type: parquet.String},
+	{Name: "operator_id", Type: parquet.String},
+	{Name: "execution_id", Type: parquet.String},
+	{Name: "schema_name", Type: parquet.String, Optional: true},
+	{Name: "table_name", Type: parquet.String},
+	{Name: "foreign_table", Type: parquet.Boolean},
+	{Name: "action", Type: parquet.String},
+	{Name: "sql", Type: parquet.String},
+	{Name: "modified_at", Type: parquet.Timestamp},
+	{Name: "record_ids", Type: parquet.StringList},
+	{Name: "source_tables", Type: parquet.StringList},
+	{Name: "changed_columns", Type: parquet.StringList},
+	{Name: "exactness", Type: parquet.String, Optional: true},
+	{Name: "operator_name", Type: parquet.String, Optional: true},
+	{Name: "operator_email", Type: parquet.String, Optional: true},
+	{Name: "shard", Type: parquet.String, Optional: true},
+	{Name: "shard_key", Type: parquet.String, Optional: true},
+	{Name: "backend_pid", Type: parquet.Int64, Optional: true},
+	{Name: "transaction_id", Type: parquet.Int64, Optional: true},
+	{Name: "estimated_rows", Type: parquet.Int64, Optional: true},
+	{Name: "idempotency_key", Type: parquet.String, Optional: true},
+	{Name: "parent_execution_id", Type: parquet.String, Optional: true},
+	{Name: "step", Type: parquet.String, Optional: true},
+	{Name: "client_ip", Type: parquet.String, Optional: true},
+	{Name: "user_agent", Type: parquet.String, Optional: true},
+	{Name: "device", Type: parquet.String, Optional: true},
+	{Name: "checksum", Type: parquet.String, Optional: true},
+	{Name: "old_values", Type: parquet.String, Optional: true},
+	{Name: "new_values", Type: parquet.String, Optional: true},
+	{Name: "metadata", Type: parquet.String, Optional: true},
+}
+
+// recordWriter writes audit records in an export format.
+type recordWriter interface {
+	write(mod audriver.DatabaseModification) error
+	flush() error
+}
+
+func runExport(ctx context.Context, args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	dsn := flags.String("dsn", os.Getenv("AUDRIVER_DSN"), "data source name of the database holding database_modifications (default $AUDRIVER_DSN)")
+	from := flags.String("from", "", "export records modified at or after this RFC 3339 time")
+	to := flags.String("to", "", "export records modified before this RFC 3339 time")
+	format := flags.String("format", "json", "output format: json (JSON lines), csv, parquet, msgpack, protobuf (length-delimited), or avro (object container file)")
+	tables := flags.String("table", "", "comma-separated tables to export records of")
+	operator := flags.String("operator", "", "operator ID to export records of")
+	shardKey := flags.String("shard-key", "", "shard key, e.g. tenant ID, to export records of")
+	output := flags.String("output", "", "file to write to (default stdout)")
+	auditTable := flags.String("audit-table", audriver.DefaultAuditTable, "audit table to export, optionally schema-qualified")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if *dsn == "" {
+		return errors.New("-dsn or $AUDRIVER_DSN is required")
+	}
+
+	filter := query.Filter{OperatorID: *operator, ShardKey: *shardKey, AuditTable: *auditTable}
+	var err error
+	if filter.From, err = parseTime(*from); err != nil {
+		return err
+	}
+	if filter.To, err = parseTime(*to); err != nil {
+		return err
+	}
+	if *tables != "" {
+		filter.Tables = strings.Split(*tables, ",")
+	}
+
+	out := stdout
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			return fmt.Errorf("failed to create output: %w", err)
+		}
+		defer func(f *os.File) {
+			_ = f.Close()
+		}(f)
+		out = f
+	}
+
+	w, err := newRecordWriter(*format, out)
+	if err != nil {
+		return err
+	}
+
+	db, err := sql.Open("postgres", *dsn)
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer func(db *sql.DB) {
+		_ = db.Close()
+	}(db)
+
+	if err := query.Each(ctx, db, filter, w.write); err != nil {
+		return err
+	}
+	return w.flush()
+}
+
+func parseTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to parse time %q: %w", value, err)
+	}
+	return t, nil
+}
+
+func newRecordWriter(format string, w io.Writer) (recordWriter, error) {
+	switch format {
+	case "json":
+		return &jsonWriter{encoder: json.NewEncoder(w)}, nil
+	case "csv":
+		cw := csv.NewWriter(w)
+		if err := cw.Write(csvHeader); err != nil {
+			return nil, fmt.Errorf("failed to write CSV header: %w", err)
+		}
+		return &csvWriter{writer: cw}, nil
+	case codec.MsgPack.Name(), codec.Protobuf.Name(), codec.Avro.Name():
+		c, err := codec.ByName(format)
+		if err != nil {
+			return nil, err
+		}
+		return &codecWriter{writer: codec.NewWriter(w, c)}, nil
+	case "parquet":
+		return &parquetWriter{writer: parquet.NewWriter(w, parquetColumns)}, nil
+	default:
+		return nil, fmt.Errorf("unknown format: %s", format)
+	}
+}
+
+type jsonWriter struct {
+	encoder *json.Encoder
+}
+
+func (w *jsonWriter) write(mod audriver.DatabaseModification) error {
+	if err := w.encoder.Encode(mod); err != nil {
+		return fmt.Errorf("failed to write record: %w", err)
+	}
+	return nil
+}
+
+func (w *jsonWriter) flush() error {
+	return nil
+}
+
+// codecWriter writes records encoded with a codec.
+type codecWriter struct {
+	writer *codec.Writer
+}
+
+func (w *codecWriter) write(mod audriver.DatabaseModification) error {
+	return w.writer.Write(mod)
+}
+
+func (w *codecWriter) flush() error {
+	return w.writer.Flush()
+}
+
+type csvWriter struct {
+	writer *csv.Writer
+}
+
+func (w *csvWriter) write(mod audriver.DatabaseModification) error {
+	var metadata string
+	if len(mod.Metadata) > 0 {
+		data, err := json.Marshal(mod.Metadata)
+		if err != nil {
+			return fmt.Errorf("failed to encode metadata: %w", err)
+		}
+		metadata = string(data)
+	}
+
+	record := []string{
+		mod.ID,
+		mod.OperatorID,
+		mod.ExecutionID,
+		mod.SchemaName,
+		mod.TableName,
+		strconv.FormatBool(mod.Foreign),
+		mod.Action.String(),
+		mod.SQL,
+		mod.ModifiedAt.Format(time.RFC3339Nano),
+		strings.Join(mod.RecordIDs, ","),
+		strings.Join(mod.SourceTables, ","),
+		strings.Join(mod.ChangedColumns, ","),
+		string(mod.Exactness),
+		mod.OperatorName,
+		mod.OperatorEmail,
+		mod.Shard,
+		mod.ShardKey,
+		formatInt(mod.BackendPID),
+		formatInt(mod.TransactionID),
+		formatInt(mod.EstimatedRows),
+		mod.IdempotencyKey,
+		mod.ParentExecutionID,
+		mod.Step,
+		mod.ClientIP,
+		mod.UserAgent,
+		mod.Device,
+		mod.Checksum,
+		string(mod.OldValues),
+		string(mod.NewValues),
+		metadata,
+	}
+	if err := w.writer.Write(record); err != nil {
+		return fmt.Errorf("failed to write record: %w", err)
+	}
+	return nil
+}
+
+func (w *csvWriter) flush() error {
+	w.writer.Flush()
+	return w.writer.Error()
+}
+
+// parquetWriter writes records as a Parquet file, leaving unset columns null.
+type parquetWriter struct {
+	writer *parquet.Writer
+}
+
+func (w *parquetWriter) write(mod audriver.DatabaseModification) error {
+	var metadata any
+	if len(mod.Metadata) > 0 {
+		data, err := json.Marshal(mod.Metadata)
+		if err != nil {
+			return fmt.Errorf("failed to encode metadata: %w", err)
+		}
+		metadata = string(data)
+	}
+
+	row := []any{
+		mod.ID,
+		mod.OperatorID,
+		mod.ExecutionID,
+		nullString(mod.SchemaName),
+		mod.TableName,
+		mod.Foreign,
+		mod.Action.String(),
+		mod.SQL,
+		mod.ModifiedAt,
+		mod.RecordIDs,
+		mod.SourceTables,
+		mod.ChangedColumns,
+		nullString(string(mod.Exactness)),
+		nullString(mod.OperatorName),
+		nullString(mod.OperatorEmail),
+		nullString(mod.Shard),
+		nullString(mod.ShardKey),
+		nullInt(mod.BackendPID),
+		nullInt(mod.TransactionID),
+		nullInt(mod.EstimatedRows),
+		nullString(mod.IdempotencyKey),
+		nullString(mod.ParentExecutionID),
+		nullString(mod.Step),
+		nullString(mod.ClientIP),
+		nullString(mod.UserAgent),
+		nullString(mod.Device),
+		nullString(mod.Checksum),
+		nullString(string(mod.OldValues)),
+		nullString(string(mod.NewValues)),
+		metadata,
+	}
+	if err := w.writer.Write(row); err != nil {
+		return fmt.Errorf("failed to write record: %w", err)
+	}
+	return nil
+}
+
+func (w *parquetWriter) flush() error {
+	return w.writer.Close()
+}
+
+// nullString returns s, or nil for a null if it is empty.
+func nullString(s string) any {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+// nullInt returns n, or nil for a null if it is zero.
+func nullInt(n int64) any {
+	if n == 0 {
+		return nil
+	}
+	return n
+}
+
+// formatInt formats n, leaving zero values empty like other unset columns.
+func formatInt(n int64) string {
+	if n == 0 {
+		return ""
+	}
+	return strconv.FormatInt(n, 10)
+}
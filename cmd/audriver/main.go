@@ -0,0 +1,79 @@
+// Command audriver provides tools for working with audit records written by audriver.
+//
+// Usage:
+//
+//	audriver export -dsn postgres://... -from 2025-01-01T00:00:00Z -to 2025-02-01T00:00:00Z -format csv > audit.csv
+//	audriver diff -dsn postgres://... <rollout-execution-id> <rollback-execution-id>
+//	audriver report -dsn postgres://... -from 2025-01-01T00:00:00Z -to 2025-04-01T00:00:00Z
+//	audriver summary -dsn postgres://... -from 2025-01-06T00:00:00Z -format html > summary.html
+//	audriver evidence -dsn postgres://... -from 2025-01-01T00:00:00Z -to 2025-04-01T00:00:00Z -signing-key key.pem -output evidence.tar.gz
+//	audriver verify-evidence -public-key pub.pem evidence.tar.gz
+//	audriver verify-dual-write -dsn postgres://... -legacy-table audit.logged_actions -from 2025-01-01T00:00:00Z -to 2025-01-01T01:00:00Z
+//	audriver backfill -dsn postgres://... -slot audriver_backfill -from 2025-01-01T00:00:00Z -to 2025-01-01T06:00:00Z
+//	audriver compact -dsn postgres://... -older-than-days 90 -granularity day
+//	audriver schema -table audit.modifications -audit-role audriver_auditor -writers app -migrations ./migrations
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+)
+
+const usage = `usage: audriver <command> [flags]
+
+commands:
+  export    export audit records as JSON lines or CSV
+  schema    generate the audit table DDL, indexes, and grants as SQL or golang-migrate migrations
+  diff      report changes of an execution that another execution did not revert
+  report    generate an integrity report of the audit trail
+  summary   render a summary of the audit records of a period as Markdown or HTML
+  evidence  package audit records, the integrity report, schema, and configuration into a signed archive
+  verify-evidence
+            verify the signature and digests of an evidence archive
+  verify-dual-write
+            compare audit records with the records of legacy audit triggers
+  compact   collapse old audit records into aggregates
+  backfill  reconstruct audit records of unaudited changes from a wal2json replication slot
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		_, _ = fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	ctx := context.Background()
+
+	var err error
+	switch os.Args[1] {
+	case "export":
+		err = runExport(ctx, os.Args[2:], os.Stdout)
+	case "schema":
+		err = runSchema(os.Args[2:], os.Stdout)
+	case "diff":
+		err = runDiff(ctx, os.Args[2:], os.Stdout)
+	case "report":
+		err = runReport(ctx, os.Args[2:], os.Stdout)
+	case "summary":
+		err = runSummary(ctx, os.Args[2:], os.Stdout)
+	case "evidence":
+		err = runEvidence(ctx, os.Args[2:], os.Stdout)
+	case "verify-evidence":
+		err = runVerifyEvidence(os.Args[2:], os.Stdout)
+	case "verify-dual-write":
+		err = runVerifyDualWrite(ctx, os.Args[2:], os.Stdout)
+	case "compact":
+		err = runCompact(ctx, os.Args[2:], os.Stdout)
+	case "backfill":
+		err = runBackfill(ctx, os.Args[2:], os.Stdout)
+	default:
+		_, _ = fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	if err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/mickamy/go-sql-audit-driver/audriver"
+	"github.com/mickamy/go-sql-audit-driver/query"
+)
+
+func runReport(ctx context.Context, args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("report", flag.ContinueOnError)
+	dsn := flags.String("dsn", os.Getenv("AUDRIVER_DSN"), "data source name of the database holding database_modifications (default $AUDRIVER_DSN)")
+	from := flags.String("from", "", "report on records modified at or after this RFC 3339 time (default 24 hours before -to)")
+	to := flags.String("to", "", "report on records modified before this RFC 3339 time (default now)")
+	format := flags.String("format", "text", "output format: text or json")
+	exclude := flags.String("exclude", "", "comma-separated tables not expected to be audited")
+	auditTable := flags.String("audit-table", audriver.DefaultAuditTable, "audit table to report on, optionally schema-qualified")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if *dsn == "" {
+		return errors.New("-dsn or $AUDRIVER_DSN is required")
+	}
+	if *format != "text" && *format != "json" {
+		return fmt.Errorf("unsupported format: %s", *format)
+	}
+
+	opts := query.ReportOptions{ExcludedTables: splitList(*exclude), AuditTable: *auditTable}
+	var err error
+	if opts.From, err = parseTime(*from); err != nil {
+		return err
+	}
+	if opts.To, err = parseTime(*to); err != nil {
+		return err
+	}
+
+	db, err := sql.Open("postgres", *dsn)
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer func(db *sql.DB) {
+		_ = db.Close()
+	}(db)
+
+	report, err := query.GenerateReport(ctx, db, opts)
+	if err != nil {
+		return err
+	}
+
+	if *format == "json" {
+		encoder := json.NewEncoder(stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(struct {
+			query.Report
+			EmptyDays []time.Time `json:"empty_days"`
+		}{report, report.EmptyDays()})
+	}
+	return writeReport(stdout, report)
+}
+
+// writeReport writes report as plain text.
+func writeReport(w io.Writer, report query.Report) error {
+	_, _ = fmt.Fprintf(w, "Audit trail integrity report\n")
+	_, _ = fmt.Fprintf(w, "Period:       %s - %s\n", report.From.Format(time.RFC3339), report.To.Format(time.RFC3339))
+	_, _ = fmt.Fprintf(w, "Generated at: %s\n\n", report.GeneratedAt.Format(time.RFC3339))
+
+	_, _ = fmt.Fprintf(w, "Records per day:\n")
+	var total int64
+	for _, count := range report.DailyCounts {
+		_, _ = fmt.Fprintf(w, "  %s\t%d\n", count.Day.Format(time.DateOnly), count.Records)
+		total += count.Records
+	}
+	_, _ = fmt.Fprintf(w, "  total\t%d\n\n", total)
+
+	emptyDays := report.EmptyDays()
+	_, _ = fmt.Fprintf(w, "Days without records: %d\n", len(emptyDays))
+	for _, day := range emptyDays {
+		_, _ = fmt.Fprintf(w, "  %s\n", day.Format(time.DateOnly))
+	}
+
+	_, _ = fmt.Fprintf(w, "\nRecords with missing operators: %d\n", report.MissingOperators)
+
+	_, _ = fmt.Fprintf(w, "\nTables written without audit records: %d\n", len(report.UncoveredTables))
+	for _, table := range report.UncoveredTables {
+		_, _ = fmt.Fprintf(w, "  %s\t%d writes\n", table.Table, table.Writes)
+	}
+
+	_, err := fmt.Fprintln(w)
+	return err
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mickamy/go-sql-audit-driver/audriver"
+)
+
+func runSchema(args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("schema", flag.ContinueOnError)
+	table := flags.String("table", audriver.DefaultAuditTable, "audit table, optionally schema-qualified")
+	columns := flags.String("columns", "", "comma-separated optional columns to create (default all)")
+	auditRole := flags.String("audit-role", "", "role to create and grant INSERT on the audit table")
+	writers := flags.String("writers", "", "comma-separated application roles writing audit records")
+	readers := flags.String("readers", "", "comma-separated roles granted SELECT on the audit table")
+	migrations := flags.String("migrations", "", "directory to write golang-migrate up and down migrations to (default print SQL to stdout)")
+	version := flags.String("version", "000001", "version of the golang-migrate migrations")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	cfg := audriver.SchemaConfig{
+		Table:     *table,
+		AuditRole: *auditRole,
+		Writers:   splitList(*writers),
+		Readers:   splitList(*readers),
+	}
+	if *columns != "" {
+		cfg.OptionalColumns = splitList(*columns)
+	}
+
+	up, err := audriver.GenerateSchemaSQL(cfg)
+	if err != nil {
+		return err
+	}
+
+	if *migrations == "" {
+		_, err := io.WriteString(stdout, up)
+		return err
+	}
+
+	if *version == "" {
+		return errors.New("-version must not be empty")
+	}
+	name := *version + "_create_" + strings.ReplaceAll(cfg.Table, ".", "_")
+	files := []struct {
+		name    string
+		content string
+	}{
+		{name: name + ".up.sql", content: up},
+		{name: name + ".down.sql", content: audriver.GenerateSchemaDownSQL(cfg)},
+	}
+	for _, file := range files {
+		path := filepath.Join(*migrations, file.name)
+		if err := os.WriteFile(path, []byte(file.content), 0o644); err != nil {
+			return fmt.Errorf("failed to write migration: %w", err)
+		}
+		_, _ = fmt.Fprintln(stdout, path)
+	}
+	return nil
+}
+
+// splitList splits a comma-separated flag value, returning nil for an empty value.
+func splitList(value string) []string {
+	if value == "" {
+		return nil
+	}
+	return strings.Split(value, ",")
+}
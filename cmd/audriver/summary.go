@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/mickamy/go-sql-audit-driver/audriver"
+	"github.com/mickamy/go-sql-audit-driver/summary"
+)
+
+func runSummary(ctx context.Context, args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("summary", flag.ContinueOnError)
+	dsn := flags.String("dsn", os.Getenv("AUDRIVER_DSN"), "data source name of the database holding database_modifications (default $AUDRIVER_DSN)")
+	from := flags.String("from", "", "summarize records modified at or after this RFC 3339 time (default 7 days before -to)")
+	to := flags.String("to", "", "summarize records modified before this RFC 3339 time (default now)")
+	tables := flags.String("tables", "", "comma-separated tables to summarize (default all)")
+	format := flags.String("format", "markdown", "output format: markdown or html")
+	var opts summary.Options
+	flags.StringVar(&opts.AuditTable, "audit-table", audriver.DefaultAuditTable, "audit table to summarize, optionally schema-qualified")
+	flags.Int64Var(&opts.LargeOperation, "large-operation", summary.DefaultLargeOperation, "number of modified records from which an execution is a large operation")
+	flags.IntVar(&opts.Top, "top", summary.DefaultTop, "number of operators and large operations listed")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if *dsn == "" {
+		return errors.New("-dsn or $AUDRIVER_DSN is required")
+	}
+	if *format != "markdown" && *format != "html" {
+		return fmt.Errorf("unsupported format: %s", *format)
+	}
+
+	opts.Tables = splitList(*tables)
+	var err error
+	if opts.From, err = parseTime(*from); err != nil {
+		return err
+	}
+	if opts.To, err = parseTime(*to); err != nil {
+		return err
+	}
+
+	db, err := sql.Open("postgres", *dsn)
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer func(db *sql.DB) {
+		_ = db.Close()
+	}(db)
+
+	s, err := summary.Generate(ctx, db, opts)
+	if err != nil {
+		return err
+	}
+	if *format == "html" {
+		return s.HTML(stdout)
+	}
+	return s.Markdown(stdout)
+}
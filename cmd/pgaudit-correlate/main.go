@@ -0,0 +1,116 @@
+// Command pgaudit-correlate correlates pgAudit log lines with audriver records and reports
+// writes that bypassed audriver and audit records missing from the log.
+//
+// Usage:
+//
+//	pgaudit-correlate -dsn postgres://... -since 2025-01-01T00:00:00Z postgresql.log
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	_ "github.com/lib/pq"
+
+	"github.com/mickamy/go-sql-audit-driver/audriver"
+	"github.com/mickamy/go-sql-audit-driver/pgaudit"
+	"github.com/mickamy/go-sql-audit-driver/query"
+)
+
+func main() {
+	dsn := flag.String("dsn", "", "data source name of the database holding database_modifications")
+	since := flag.String("since", "", "only correlate audit records modified at or after this RFC 3339 time")
+	until := flag.String("until", "", "only correlate audit records modified before this RFC 3339 time")
+	auditTable := flag.String("audit-table", audriver.DefaultAuditTable, "audit table to read, optionally schema-qualified")
+	flag.Parse()
+
+	complete, err := run(context.Background(), *dsn, *auditTable, *since, *until, flag.Args())
+	if err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if !complete {
+		os.Exit(1)
+	}
+}
+
+// run prints the correlation report and reports whether all logged writes were audited.
+func run(ctx context.Context, dsn, auditTable, since, until string, files []string) (bool, error) {
+	if dsn == "" {
+		return false, fmt.Errorf("-dsn is required")
+	}
+
+	from, err := parseTime(since, time.Time{})
+	if err != nil {
+		return false, err
+	}
+	to, err := parseTime(until, time.Now())
+	if err != nil {
+		return false, err
+	}
+
+	entries, err := readEntries(files)
+	if err != nil {
+		return false, err
+	}
+
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return false, fmt.Errorf("failed to open database: %w", err)
+	}
+	defer func(db *sql.DB) {
+		_ = db.Close()
+	}(db)
+
+	mods, err := query.List(ctx, db, query.Filter{From: from, To: to, AuditTable: auditTable})
+	if err != nil {
+		return false, err
+	}
+
+	report := pgaudit.Correlate(entries, mods, auditTable, auditTable+"_staging")
+	fmt.Printf("matched: %d, unaudited: %d, unlogged: %d\n", len(report.Matched), len(report.Unaudited), len(report.Unlogged))
+	for _, entry := range report.Unaudited {
+		fmt.Printf("unaudited: %s %s: %s\n", entry.Command, entry.ObjectName, entry.Statement)
+	}
+	for _, mod := range report.Unlogged {
+		fmt.Printf("unlogged: %s %s %s (execution %s)\n", mod.ID, mod.Action, mod.TableName, mod.ExecutionID)
+	}
+
+	return len(report.Unaudited) == 0, nil
+}
+
+func parseTime(value string, fallback time.Time) (time.Time, error) {
+	if value == "" {
+		return fallback, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to parse time %q: %w", value, err)
+	}
+	return t, nil
+}
+
+func readEntries(files []string) ([]pgaudit.Entry, error) {
+	if len(files) == 0 {
+		return pgaudit.Parse(os.Stdin)
+	}
+
+	readers := make([]io.Reader, 0, len(files))
+	for _, name := range files {
+		f, err := os.Open(name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open log: %w", err)
+		}
+		defer func(f *os.File) {
+			_ = f.Close()
+		}(f)
+		readers = append(readers, f)
+	}
+
+	return pgaudit.Parse(io.MultiReader(readers...))
+}
@@ -0,0 +1,299 @@
+// Package evidence packages audit records with their integrity report, the audit table schema,
+// and a configuration snapshot into a signed archive, for handing over to external auditors, e.g. for SOC 2 or ISO 27001.
+//
+// The archive is a gzip-compressed tar file. Its manifest.json lists the SHA-256 digest of every other file
+// and is signed with Ed25519 in manifest.sig, so that auditors can verify the archive with Verify.
+package evidence
+
+import (
+	"archive/tar"
+	"bytes"
+	"cmp"
+	"compress/gzip"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/mickamy/go-sql-audit-driver/audriver"
+	"github.com/mickamy/go-sql-audit-driver/query"
+)
+
+const (
+	// ManifestName is the name of the manifest in the archive.
+	ManifestName = "manifest.json"
+
+	// SignatureName is the name of the Ed25519 signature of the manifest in the archive.
+	SignatureName = "manifest.sig"
+)
+
+// ErrInvalidBundle is returned by Verify if the archive is malformed, its signature is invalid,
+// or its files do not match the manifest.
+var ErrInvalidBundle = errors.New("invalid evidence bundle")
+
+// Options configures an evidence bundle.
+type Options struct {
+	// From and To are the period of the audit records and the integrity report.
+	From time.Time
+	To   time.Time
+
+	// ExcludedTables are tables not expected to be audited, passed to the integrity report.
+	ExcludedTables []string
+
+	// AuditTable is the audit table bundled, optionally schema-qualified. It defaults to audriver.DefaultAuditTable.
+	AuditTable string
+
+	// Config is a snapshot of the audit configuration, e.g. table filters and sampling rates, stored as config.json.
+	// It must be JSON-encodable. No config.json is written if it is nil.
+	Config any
+
+	// SigningKey signs the manifest.
+	SigningKey ed25519.PrivateKey
+}
+
+// Manifest describes the files of an evidence bundle.
+type Manifest struct {
+	// From and To are the period of the audit records.
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+
+	// GeneratedAt is when the bundle was generated.
+	GeneratedAt time.Time `json:"generated_at"`
+
+	// Files are the files of the bundle besides the manifest and its signature, in order of the archive.
+	Files []FileDigest `json:"files"`
+}
+
+// FileDigest is the digest of a file of an evidence bundle.
+type FileDigest struct {
+	Name   string `json:"name"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// File is a file of an evidence bundle.
+type File struct {
+	Name string
+	Data []byte
+}
+
+// Export writes an evidence bundle of the audit records in db to w: records.jsonl with the audit records of the
+// period as JSON lines, report.json with the integrity report, schema.json with the columns and indexes of the audit
+// table, and config.json with the configuration snapshot.
+func Export(ctx context.Context, db *sql.DB, w io.Writer, opts Options) (Manifest, error) {
+	report, err := query.GenerateReport(ctx, db, query.ReportOptions{From: opts.From, To: opts.To, ExcludedTables: opts.ExcludedTables, AuditTable: opts.AuditTable})
+	if err != nil {
+		return Manifest{}, err
+	}
+
+	var records bytes.Buffer
+	encoder := json.NewEncoder(&records)
+	err = query.Each(ctx, db, query.Filter{From: report.From, To: report.To, AuditTable: opts.AuditTable}, func(mod audriver.DatabaseModification) error {
+		return encoder.Encode(mod)
+	})
+	if err != nil {
+		return Manifest{}, err
+	}
+
+	reportJSON, err := json.MarshalIndent(struct {
+		query.Report
+		EmptyDays []time.Time `json:"empty_days"`
+	}{report, report.EmptyDays()}, "", "  ")
+	if err != nil {
+		return Manifest{}, fmt.Errorf("failed to encode report: %w", err)
+	}
+
+	schema, err := auditSchema(ctx, db, cmp.Or(opts.AuditTable, audriver.DefaultAuditTable))
+	if err != nil {
+		return Manifest{}, err
+	}
+
+	files := []File{
+		{Name: "records.jsonl", Data: records.Bytes()},
+		{Name: "report.json", Data: reportJSON},
+		{Name: "schema.json", Data: schema},
+	}
+	if opts.Config != nil {
+		config, err := json.MarshalIndent(opts.Config, "", "  ")
+		if err != nil {
+			return Manifest{}, fmt.Errorf("failed to encode config: %w", err)
+		}
+		files = append(files, File{Name: "config.json", Data: config})
+	}
+
+	return Write(w, Manifest{From: report.From, To: report.To}, files, opts.SigningKey)
+}
+
+// Write writes files as an evidence bundle to w, with a manifest listing their digests signed with key.
+// The manifest's files are filled in, and its generation time if zero.
+func Write(w io.Writer, manifest Manifest, files []File, key ed25519.PrivateKey) (Manifest, error) {
+	if len(key) != ed25519.PrivateKeySize {
+		return Manifest{}, errors.New("an Ed25519 signing key is required")
+	}
+	if manifest.GeneratedAt.IsZero() {
+		manifest.GeneratedAt = time.Now()
+	}
+
+	manifest.Files = make([]FileDigest, 0, len(files))
+	for _, file := range files {
+		if file.Name == ManifestName || file.Name == SignatureName {
+			return Manifest{}, fmt.Errorf("file name %s is reserved", file.Name)
+		}
+		digest := sha256.Sum256(file.Data)
+		manifest.Files = append(manifest.Files, FileDigest{Name: file.Name, Size: int64(len(file.Data)), SHA256: hex.EncodeToString(digest[:])})
+	}
+	manifestJSON, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return Manifest{}, fmt.Errorf("failed to encode manifest: %w", err)
+	}
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	files = append(slices.Clone(files),
+		File{Name: ManifestName, Data: manifestJSON},
+		File{Name: SignatureName, Data: ed25519.Sign(key, manifestJSON)},
+	)
+	for _, file := range files {
+		header := &tar.Header{Name: file.Name, Mode: 0o644, Size: int64(len(file.Data)), ModTime: manifest.GeneratedAt}
+		if err := tw.WriteHeader(header); err != nil {
+			return Manifest{}, fmt.Errorf("failed to write %s: %w", file.Name, err)
+		}
+		if _, err := tw.Write(file.Data); err != nil {
+			return Manifest{}, fmt.Errorf("failed to write %s: %w", file.Name, err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return Manifest{}, fmt.Errorf("failed to write archive: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return Manifest{}, fmt.Errorf("failed to write archive: %w", err)
+	}
+
+	return manifest, nil
+}
+
+// Verify verifies the evidence bundle read from r against the public key of its signer:
+// the signature of the manifest, and the digests of all files listed in it. It returns the verified manifest.
+func Verify(r io.Reader, key ed25519.PublicKey) (Manifest, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return Manifest{}, fmt.Errorf("%w: %w", ErrInvalidBundle, err)
+	}
+	tr := tar.NewReader(gz)
+
+	contents := map[string][]byte{}
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return Manifest{}, fmt.Errorf("%w: %w", ErrInvalidBundle, err)
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return Manifest{}, fmt.Errorf("%w: %w", ErrInvalidBundle, err)
+		}
+		contents[header.Name] = data
+	}
+
+	manifestJSON, ok := contents[ManifestName]
+	if !ok {
+		return Manifest{}, fmt.Errorf("%w: %s is missing", ErrInvalidBundle, ManifestName)
+	}
+	if !ed25519.Verify(key, manifestJSON, contents[SignatureName]) {
+		return Manifest{}, fmt.Errorf("%w: signature does not match", ErrInvalidBundle)
+	}
+
+	var manifest Manifest
+	if err := json.Unmarshal(manifestJSON, &manifest); err != nil {
+		return Manifest{}, fmt.Errorf("%w: %w", ErrInvalidBundle, err)
+	}
+	if len(contents) != len(manifest.Files)+2 {
+		return Manifest{}, fmt.Errorf("%w: archive has files not listed in the manifest", ErrInvalidBundle)
+	}
+	for _, file := range manifest.Files {
+		data, ok := contents[file.Name]
+		if !ok {
+			return Manifest{}, fmt.Errorf("%w: %s is missing", ErrInvalidBundle, file.Name)
+		}
+		digest := sha256.Sum256(data)
+		if hex.EncodeToString(digest[:]) != file.SHA256 {
+			return Manifest{}, fmt.Errorf("%w: digest of %s does not match", ErrInvalidBundle, file.Name)
+		}
+	}
+
+	return manifest, nil
+}
+
+// auditSchema returns the columns and indexes of the audit table as JSON.
+// Unqualified tables are looked up in the current schema.
+func auditSchema(ctx context.Context, db *sql.DB, table string) ([]byte, error) {
+	type column struct {
+		Name     string  `json:"name"`
+		Type     string  `json:"type"`
+		Nullable bool    `json:"nullable"`
+		Default  *string `json:"default,omitempty"`
+	}
+	var schema struct {
+		Table   string   `json:"table"`
+		Columns []column `json:"columns"`
+		Indexes []string `json:"indexes"`
+	}
+	schema.Table = table
+	schemaName, tableName := "", table
+	if i := strings.LastIndexByte(table, '.'); i >= 0 {
+		schemaName, tableName = table[:i], table[i+1:]
+	}
+
+	rows, err := db.QueryContext(ctx, `SELECT column_name, data_type, is_nullable = 'YES', column_default
+FROM information_schema.columns
+WHERE table_name = $1 AND table_schema = coalesce(nullif($2, ''), current_schema())
+ORDER BY ordinal_position`, tableName, schemaName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query audit table columns: %w", err)
+	}
+	for rows.Next() {
+		var c column
+		if err := rows.Scan(&c.Name, &c.Type, &c.Nullable, &c.Default); err != nil {
+			_ = rows.Close()
+			return nil, fmt.Errorf("failed to scan audit table column: %w", err)
+		}
+		schema.Columns = append(schema.Columns, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read audit table columns: %w", err)
+	}
+
+	rows, err = db.QueryContext(ctx, `SELECT indexdef FROM pg_indexes
+WHERE tablename = $1 AND schemaname = coalesce(nullif($2, ''), current_schema())
+ORDER BY indexname`, tableName, schemaName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query audit table indexes: %w", err)
+	}
+	for rows.Next() {
+		var index string
+		if err := rows.Scan(&index); err != nil {
+			_ = rows.Close()
+			return nil, fmt.Errorf("failed to scan audit table index: %w", err)
+		}
+		schema.Indexes = append(schema.Indexes, index)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read audit table indexes: %w", err)
+	}
+
+	data, err := json.MarshalIndent(schema, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode schema: %w", err)
+	}
+	return data, nil
+}
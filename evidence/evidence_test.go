@@ -0,0 +1,148 @@
+package evidence_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/ed25519"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mickamy/go-sql-audit-driver/evidence"
+)
+
+// TestVerify tests verifying signed evidence bundles and detecting tampered ones
+func TestVerify(t *testing.T) {
+	t.Parallel()
+
+	publicKey, privateKey, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	otherKey, _, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+
+	files := []evidence.File{
+		{Name: "records.jsonl", Data: []byte(`{"id":"1"}` + "\n")},
+		{Name: "report.json", Data: []byte(`{"missing_operators":0}`)},
+	}
+	from := time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2025, time.April, 1, 0, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		name   string
+		key    ed25519.PublicKey
+		tamper func(name string, data []byte) []byte
+		extra  *evidence.File
+		valid  bool
+	}{
+		{
+			name:  "valid",
+			key:   publicKey,
+			valid: true,
+		},
+		{
+			name:  "other key",
+			key:   otherKey,
+			valid: false,
+		},
+		{
+			name: "tampered file",
+			key:  publicKey,
+			tamper: func(name string, data []byte) []byte {
+				if name == "records.jsonl" {
+					return []byte(`{"id":"2"}` + "\n")
+				}
+				return data
+			},
+			valid: false,
+		},
+		{
+			name: "tampered manifest",
+			key:  publicKey,
+			tamper: func(name string, data []byte) []byte {
+				if name == evidence.ManifestName {
+					return bytes.Replace(data, []byte("2025-04-01"), []byte("2025-05-01"), 1)
+				}
+				return data
+			},
+			valid: false,
+		},
+		{
+			name:  "unlisted file",
+			key:   publicKey,
+			extra: &evidence.File{Name: "notes.txt", Data: []byte("added later")},
+			valid: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// arrange
+			var bundle bytes.Buffer
+			manifest, err := evidence.Write(&bundle, evidence.Manifest{From: from, To: to}, files, privateKey)
+			require.NoError(t, err)
+			if tc.tamper != nil || tc.extra != nil {
+				bundle = rewrite(t, &bundle, tc.tamper, tc.extra)
+			}
+
+			// act
+			verified, err := evidence.Verify(&bundle, tc.key)
+
+			// assert
+			if !tc.valid {
+				assert.ErrorIs(t, err, evidence.ErrInvalidBundle)
+				return
+			}
+			require.NoError(t, err)
+			assert.True(t, manifest.GeneratedAt.Equal(verified.GeneratedAt))
+			assert.True(t, from.Equal(verified.From))
+			assert.True(t, to.Equal(verified.To))
+			require.Len(t, verified.Files, 2)
+			assert.Equal(t, "records.jsonl", verified.Files[0].Name)
+			assert.Equal(t, int64(len(files[0].Data)), verified.Files[0].Size)
+		})
+	}
+}
+
+// rewrite copies the bundle read from r, replacing file contents with tamper and appending extra.
+func rewrite(t *testing.T, r io.Reader, tamper func(name string, data []byte) []byte, extra *evidence.File) bytes.Buffer {
+	t.Helper()
+
+	gz, err := gzip.NewReader(r)
+	require.NoError(t, err)
+	tr := tar.NewReader(gz)
+
+	var out bytes.Buffer
+	gw := gzip.NewWriter(&out)
+	tw := tar.NewWriter(gw)
+	write := func(name string, data []byte) {
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(len(data))}))
+		_, err := tw.Write(data)
+		require.NoError(t, err)
+	}
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		data, err := io.ReadAll(tr)
+		require.NoError(t, err)
+		if tamper != nil {
+			data = tamper(header.Name, data)
+		}
+		write(header.Name, data)
+	}
+	if extra != nil {
+		write(extra.Name, extra.Data)
+	}
+	require.NoError(t, tw.Close())
+	require.NoError(t, gw.Close())
+	return out
+}
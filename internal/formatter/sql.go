@@ -2,30 +2,65 @@ package formatter
 
 import (
 	"database/sql/driver"
+	"encoding/hex"
 	"fmt"
-	"strings"
+	"strconv"
 	"time"
 )
 
 // SQLValue formats a driver.NamedValue for SQL interpolation.
 func SQLValue(arg driver.NamedValue) string {
+	return string(AppendSQLValue(nil, arg))
+}
+
+// AppendSQLValue appends the SQL interpolation of a driver.NamedValue to dst and returns the extended buffer.
+func AppendSQLValue(dst []byte, arg driver.NamedValue) []byte {
 	switch v := arg.Value.(type) {
 	case nil:
-		return "NULL"
+		return append(dst, "NULL"...)
 	case string:
-		return fmt.Sprintf("'%s'", escapeString(v))
+		return appendQuoted(dst, v)
 	case []byte:
-		return fmt.Sprintf("'%x'", v)
+		dst = append(dst, '\'')
+		dst = hex.AppendEncode(dst, v)
+		return append(dst, '\'')
+	case int64:
+		dst = append(dst, '\'')
+		dst = strconv.AppendInt(dst, v, 10)
+		return append(dst, '\'')
+	case int:
+		dst = append(dst, '\'')
+		dst = strconv.AppendInt(dst, int64(v), 10)
+		return append(dst, '\'')
+	case float64:
+		dst = append(dst, '\'')
+		dst = strconv.AppendFloat(dst, v, 'g', -1, 64)
+		return append(dst, '\'')
+	case bool:
+		dst = append(dst, '\'')
+		dst = strconv.AppendBool(dst, v)
+		return append(dst, '\'')
 	case time.Time:
-		return fmt.Sprintf("'%s'", v.Format("2006-01-02 15:04:05-07:00"))
+		dst = append(dst, '\'')
+		dst = v.AppendFormat(dst, "2006-01-02 15:04:05-07:00")
+		return append(dst, '\'')
 	case fmt.Stringer:
-		return fmt.Sprintf("'%s'", escapeString(v.String()))
+		return appendQuoted(dst, v.String())
 	default:
-		return fmt.Sprintf("'%v'", v)
+		dst = append(dst, '\'')
+		dst = fmt.Appendf(dst, "%v", v)
+		return append(dst, '\'')
 	}
 }
 
-// escapeString escapes single quotes in a string for SQL.
-func escapeString(s string) string {
-	return strings.ReplaceAll(s, "'", "''")
+// appendQuoted appends s as a quoted SQL string, escaping single quotes.
+func appendQuoted(dst []byte, s string) []byte {
+	dst = append(dst, '\'')
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\'' {
+			dst = append(dst, '\'')
+		}
+		dst = append(dst, s[i])
+	}
+	return append(dst, '\'')
 }
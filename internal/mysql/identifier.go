@@ -0,0 +1,17 @@
+package mysql
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// QuoteIdentifier quotes a MySQL identifier such as a role or table name.
+func QuoteIdentifier(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
+
+// FormatArray formats values as a JSON array, e.g. ["a","b"], as MySQL has no array types.
+func FormatArray(values []string) string {
+	data, _ := json.Marshal(values)
+	return string(data)
+}
@@ -0,0 +1,91 @@
+package mysql
+
+import (
+	"database/sql/driver"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mickamy/go-sql-audit-driver/internal/formatter"
+)
+
+// InterpolateSQL replaces MySQL question mark placeholders with actual values, the first one with the argument of
+// ordinal 1 and so on. Placeholders without an argument are kept. String literals, quoted identifiers, and comments
+// are kept as is.
+func InterpolateSQL(query string, args []driver.NamedValue) string {
+	if len(args) == 0 {
+		return query
+	}
+
+	var (
+		buf      []byte
+		last, n  int
+		replaced bool
+	)
+	for i := 0; i < len(query); i++ {
+		if end := SkipLiteral(query, i); end > i {
+			i = end - 1
+			continue
+		}
+		if query[i] != '?' {
+			continue
+		}
+		n++
+		arg, ok := argument(args, n)
+		if !ok {
+			continue
+		}
+		if buf == nil {
+			buf = make([]byte, 0, len(query)+len(args)*16)
+		}
+		buf = append(buf, query[last:i]...)
+		buf = appendValue(buf, arg)
+		replaced = true
+		last = i + 1
+	}
+
+	if !replaced {
+		return query
+	}
+	return string(append(buf, query[last:]...))
+}
+
+// argument returns the argument of the nth placeholder. Arguments without ordinals are taken by position.
+func argument(args []driver.NamedValue, n int) (driver.NamedValue, bool) {
+	if n <= len(args) && (args[n-1].Ordinal == n || args[n-1].Ordinal == 0) {
+		return args[n-1], true
+	}
+	for _, arg := range args {
+		if arg.Ordinal == n {
+			return arg, true
+		}
+	}
+	return driver.NamedValue{}, false
+}
+
+// stringReplacer escapes strings for MySQL string literals, where backslashes are escape characters.
+var stringReplacer = strings.NewReplacer(`\`, `\\`, `'`, `''`)
+
+// appendValue appends the SQL interpolation of arg to dst. Strings escape backslashes and bytes are hexadecimal
+// literals; other values are formatted as for PostgreSQL.
+func appendValue(dst []byte, arg driver.NamedValue) []byte {
+	var s string
+	switch v := arg.Value.(type) {
+	case string:
+		s = v
+	case []byte:
+		dst = append(dst, "X'"...)
+		dst = hex.AppendEncode(dst, v)
+		return append(dst, '\'')
+	case time.Time:
+		return formatter.AppendSQLValue(dst, arg)
+	case fmt.Stringer:
+		s = v.String()
+	default:
+		return formatter.AppendSQLValue(dst, arg)
+	}
+	dst = append(dst, '\'')
+	dst = append(dst, stringReplacer.Replace(s)...)
+	return append(dst, '\'')
+}
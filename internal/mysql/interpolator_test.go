@@ -0,0 +1,128 @@
+package mysql_test
+
+import (
+	"database/sql/driver"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mickamy/go-sql-audit-driver/internal/mysql"
+)
+
+// TestInterpolateSQL tests interpolation of question mark placeholders
+func TestInterpolateSQL(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name  string
+		query string
+		args  []driver.NamedValue
+		want  string
+	}{
+		{
+			name:  "no_args",
+			query: "DELETE FROM users",
+			want:  "DELETE FROM users",
+		},
+		{
+			name:  "values",
+			query: "INSERT INTO `users` (id, name, age, active, created_at, data) VALUES (?, ?, ?, ?, ?, ?)",
+			args: []driver.NamedValue{
+				{Ordinal: 1, Value: "1"},
+				{Ordinal: 2, Value: `O'Brien \ Sons`},
+				{Ordinal: 3, Value: int64(42)},
+				{Ordinal: 4, Value: true},
+				{Ordinal: 5, Value: time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)},
+				{Ordinal: 6, Value: []byte{0xde, 0xad}},
+			},
+			want: "INSERT INTO `users` (id, name, age, active, created_at, data) VALUES ('1', 'O''Brien \\\\ Sons', '42', 'true', '2025-01-02 03:04:05+00:00', X'dead')",
+		},
+		{
+			name:  "null_and_missing_args",
+			query: "UPDATE users SET name = ? WHERE id = ?",
+			args:  []driver.NamedValue{{Ordinal: 1, Value: nil}},
+			want:  "UPDATE users SET name = NULL WHERE id = ?",
+		},
+		{
+			name:  "placeholders_in_literals_and_comments",
+			query: "UPDATE users SET note = 'it\\'s ?', body = \"?\", `col?` = ? /* ? */ WHERE a = ? # ?\nAND b = ? -- ?",
+			args:  []driver.NamedValue{{Ordinal: 1, Value: "a"}, {Ordinal: 2, Value: "b"}, {Ordinal: 3, Value: "c"}},
+			want:  "UPDATE users SET note = 'it\\'s ?', body = \"?\", `col?` = 'a' /* ? */ WHERE a = 'b' # ?\nAND b = 'c' -- ?",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// act
+			got := mysql.InterpolateSQL(tc.query, tc.args)
+
+			// assert
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+// FuzzInterpolateSQL tests that interpolation does not panic and leaves statements without placeholders as is
+func FuzzInterpolateSQL(f *testing.F) {
+	f.Add("UPDATE users SET name = ? WHERE id = ?", "O'Brien")
+	f.Add("UPDATE users SET note = 'it\\'s ?', x = \"?\" WHERE id = ? # ?", "1")
+	f.Add("/* ? DELETE FROM users WHERE id = ?", "1")
+
+	f.Fuzz(func(t *testing.T, query string, arg string) {
+		got := mysql.InterpolateSQL(query, []driver.NamedValue{{Ordinal: 1, Value: arg}})
+		if !strings.Contains(query, "?") && got != query {
+			t.Errorf("InterpolateSQL(%q) = %q, want the query unchanged", query, got)
+		}
+	})
+}
+
+// TestNormalize tests blanking out MySQL string literals and comments
+func TestNormalize(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name  string
+		query string
+		want  string
+	}{
+		{
+			name:  "nothing_to_normalize",
+			query: "UPDATE `users` SET name = ? WHERE id = ?",
+			want:  "UPDATE `users` SET name = ? WHERE id = ?",
+		},
+		{
+			name:  "strings",
+			query: `UPDATE users SET a = 'it\'s', b = "say ""hi"""`,
+			want:  `UPDATE users SET a = '     ', b = '          '`,
+		},
+		{
+			name:  "comments",
+			query: "DELETE FROM users # UPDATE t\nWHERE id = 1 -- x\n/* INSERT INTO t */",
+			want:  "DELETE FROM users           \nWHERE id = 1     \n                   ",
+		},
+		{
+			name:  "not_a_comment",
+			query: "UPDATE t SET n = n--1",
+			want:  "UPDATE t SET n = n--1",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// act
+			got := mysql.Normalize(tc.query)
+
+			// assert
+			assert.Equal(t, tc.want, got)
+			assert.Len(t, got, len(tc.query), "offsets should be kept")
+		})
+	}
+}
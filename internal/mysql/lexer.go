@@ -0,0 +1,97 @@
+package mysql
+
+import (
+	"strings"
+)
+
+// SkipLiteral returns the index right after the string literal, quoted identifier, or comment starting at i,
+// or i if none starts there. Unterminated ones extend to the end of sql.
+// Double quotes delimit strings and backslashes escape characters in them, as in MySQL without ANSI_QUOTES and
+// NO_BACKSLASH_ESCAPES; comments start with #, "-- ", or /* and do not nest.
+func SkipLiteral(sql string, i int) int {
+	if i >= len(sql) {
+		return i
+	}
+	switch c := sql[i]; {
+	case c == '\'' || c == '"':
+		return skipQuoted(sql, i, true)
+	case c == '`':
+		return skipQuoted(sql, i, false)
+	case c == '#' || c == '-' && isLineComment(sql, i):
+		end := strings.IndexByte(sql[i:], '\n')
+		if end < 0 {
+			return len(sql)
+		}
+		return i + end + 1
+	case c == '/' && strings.HasPrefix(sql[i:], "/*"):
+		end := strings.Index(sql[i+2:], "*/")
+		if end < 0 {
+			return len(sql)
+		}
+		return i + 2 + end + 2
+	default:
+		return i
+	}
+}
+
+// isLineComment reports whether a "-- " comment starts at i. MySQL requires whitespace after the dashes,
+// so that e.g. 1--1 is a subtraction.
+func isLineComment(sql string, i int) bool {
+	if !strings.HasPrefix(sql[i:], "--") {
+		return false
+	}
+	return i+2 == len(sql) || sql[i+2] == ' ' || sql[i+2] == '\t' || sql[i+2] == '\n' || sql[i+2] == '\r'
+}
+
+// skipQuoted returns the index right after the quote closing the quoted string or identifier starting at start.
+// Doubled quotes are escaped quotes, as are backslash-escaped ones if escapes is set.
+func skipQuoted(sql string, start int, escapes bool) int {
+	quote := sql[start]
+	for i := start + 1; i < len(sql); i++ {
+		switch {
+		case escapes && sql[i] == '\\':
+			i++
+		case sql[i] != quote:
+		case i+1 < len(sql) && sql[i+1] == quote:
+			i++
+		default:
+			return i + 1
+		}
+	}
+	return len(sql)
+}
+
+// Normalize returns sql with string literals blanked out as single-quoted strings of spaces and comments replaced
+// by spaces, so that statements can be scanned with the PostgreSQL lexical rules, e.g. for table names.
+// Offsets, line breaks, and quoted identifiers are kept as is.
+func Normalize(sql string) string {
+	var normalized []byte
+	for i := 0; i < len(sql); i++ {
+		end := SkipLiteral(sql, i)
+		if end == i || sql[i] == '`' {
+			if end > i {
+				i = end - 1
+			}
+			continue
+		}
+		if normalized == nil {
+			normalized = []byte(sql)
+		}
+		for j := i; j < end; j++ {
+			if sql[j] != '\n' {
+				normalized[j] = ' '
+			}
+		}
+		if sql[i] == '\'' || sql[i] == '"' {
+			normalized[i] = '\''
+			if end-1 > i && sql[end-1] == sql[i] {
+				normalized[end-1] = '\''
+			}
+		}
+		i = end - 1
+	}
+	if normalized == nil {
+		return sql
+	}
+	return string(normalized)
+}
@@ -0,0 +1,106 @@
+package parquet
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// Parquet metadata is serialized with the Thrift compact protocol. Structs are built as lists of fields and encoded
+// by appendStruct, which supports the Thrift types the metadata of Writer uses.
+
+// thriftStruct is a Thrift struct, its fields in ascending order of their IDs.
+type thriftStruct []thriftField
+
+// thriftField is a field of a Thrift struct. value is a bool, int32, int64, string, thriftStruct, or thriftList.
+type thriftField struct {
+	id    int16
+	value any
+}
+
+// thriftList is a Thrift list of int32, string, or thriftStruct elements.
+type thriftList []any
+
+// Thrift compact protocol types.
+const (
+	thriftTypeTrue   = 1
+	thriftTypeFalse  = 2
+	thriftTypeI32    = 5
+	thriftTypeI64    = 6
+	thriftTypeBinary = 8
+	thriftTypeList   = 9
+	thriftTypeStruct = 12
+)
+
+// appendStruct appends s to dst in the Thrift compact protocol.
+func appendStruct(dst []byte, s thriftStruct) []byte {
+	var last int16
+	for _, field := range s {
+		typ := thriftType(field.value)
+		if b, ok := field.value.(bool); ok && !b {
+			typ = thriftTypeFalse
+		}
+		if delta := field.id - last; delta > 0 && delta <= 15 {
+			dst = append(dst, byte(delta)<<4|typ)
+		} else {
+			dst = append(dst, typ)
+			dst = binary.AppendVarint(dst, int64(field.id))
+		}
+		last = field.id
+		if _, ok := field.value.(bool); !ok {
+			dst = appendThriftValue(dst, field.value)
+		}
+	}
+	return append(dst, 0)
+}
+
+// appendThriftValue appends value to dst, without a field header.
+func appendThriftValue(dst []byte, value any) []byte {
+	switch v := value.(type) {
+	case int32:
+		return binary.AppendVarint(dst, int64(v))
+	case int64:
+		return binary.AppendVarint(dst, v)
+	case string:
+		dst = binary.AppendUvarint(dst, uint64(len(v)))
+		return append(dst, v...)
+	case thriftStruct:
+		return appendStruct(dst, v)
+	case thriftList:
+		var elem byte = thriftTypeStruct
+		if len(v) > 0 {
+			elem = thriftType(v[0])
+		}
+		if len(v) < 15 {
+			dst = append(dst, byte(len(v))<<4|elem)
+		} else {
+			dst = append(dst, 0xf0|elem)
+			dst = binary.AppendUvarint(dst, uint64(len(v)))
+		}
+		for _, e := range v {
+			dst = appendThriftValue(dst, e)
+		}
+		return dst
+	default:
+		panic(fmt.Sprintf("parquet: unsupported thrift value %T", value))
+	}
+}
+
+// thriftType returns the compact protocol type of value.
+func thriftType(value any) byte {
+	switch value.(type) {
+	case bool:
+		return thriftTypeTrue
+	case int32:
+		return thriftTypeI32
+	case int64:
+		return thriftTypeI64
+	case string:
+		return thriftTypeBinary
+	case thriftList:
+		return thriftTypeList
+	case thriftStruct:
+		return thriftTypeStruct
+	default:
+		panic(fmt.Sprintf("parquet: unsupported thrift value %T", value))
+	}
+}
@@ -0,0 +1,434 @@
+// Package parquet writes Apache Parquet files of flat rows, for archives read by analytical tools such as DuckDB.
+//
+// Values are PLAIN encoded in one gzip-compressed data page per column and row group; rows are buffered in memory
+// until a row group is complete.
+package parquet
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Type is the type of the values of a column.
+type Type int
+
+const (
+	// String columns hold UTF-8 strings, written as values of type string.
+	String Type = iota
+
+	// Int64 columns hold 64-bit integers, written as values of type int64.
+	Int64
+
+	// Boolean columns hold booleans, written as values of type bool.
+	Boolean
+
+	// Timestamp columns hold UTC timestamps with microsecond precision, written as values of type time.Time.
+	Timestamp
+
+	// StringList columns hold lists of strings, written as values of type []string. Lists are always optional:
+	// nil slices are null, and empty ones empty lists.
+	StringList
+)
+
+// Column is a column of the rows of a file.
+type Column struct {
+	Name string
+	Type Type
+
+	// Optional columns take nil values, written as nulls.
+	Optional bool
+}
+
+// DefaultRowGroupSize is the approximate size of the values of a row group, after which it is written.
+const DefaultRowGroupSize = 64 << 20
+
+// magic starts and ends Parquet files.
+var magic = []byte("PAR1")
+
+// Parquet physical types, repetitions, converted types, encodings, and compression codecs used by Writer.
+const (
+	typeBoolean   = 0
+	typeInt64     = 2
+	typeByteArray = 6
+
+	repetitionRequired = 0
+	repetitionOptional = 1
+	repetitionRepeated = 2
+
+	convertedUTF8            = 0
+	convertedList            = 3
+	convertedTimestampMicros = 10
+
+	encodingPlain = 0
+	encodingRLE   = 3
+
+	codecGzip = 2
+
+	pageTypeData = 0
+)
+
+// Writer writes rows to a Parquet file. Close must be called to write the buffered rows and the footer.
+type Writer struct {
+	w       io.Writer
+	columns []Column
+
+	// RowGroupSize is the approximate size of the values of a row group, DefaultRowGroupSize by default.
+	RowGroupSize int
+
+	offset    int64
+	chunks    []columnChunk
+	rows      int64
+	rowGroups thriftList
+	numRows   int64
+	err       error
+}
+
+// columnChunk is the buffered levels and PLAIN encoded values of a column of the current row group.
+type columnChunk struct {
+	repetitions []uint8
+	definitions []uint8
+	values      []byte
+
+	// booleans counts the values of Boolean columns, which are bit-packed into values.
+	booleans int
+}
+
+// NewWriter returns a Writer writing rows of columns to w.
+func NewWriter(w io.Writer, columns []Column) *Writer {
+	return &Writer{
+		w:            w,
+		columns:      columns,
+		RowGroupSize: DefaultRowGroupSize,
+		chunks:       make([]columnChunk, len(columns)),
+	}
+}
+
+// Write buffers a row, its values in the order of the columns, and writes the row group once it is complete.
+func (w *Writer) Write(row []any) error {
+	if w.err != nil {
+		return w.err
+	}
+	if len(row) != len(w.columns) {
+		return fmt.Errorf("row has %d values, want %d", len(row), len(w.columns))
+	}
+	for i, column := range w.columns {
+		if err := w.chunks[i].append(column, row[i]); err != nil {
+			// earlier columns of the row are buffered already, so the row group cannot be written consistently
+			w.err = fmt.Errorf("failed to write column %s: %w", column.Name, err)
+			return w.err
+		}
+	}
+	w.rows++
+	var buffered int
+	for i := range w.chunks {
+		buffered += len(w.chunks[i].values)
+	}
+	if buffered >= w.RowGroupSize {
+		return w.flushRowGroup()
+	}
+	return nil
+}
+
+// Close writes the buffered rows and the footer. It does not close the underlying writer.
+func (w *Writer) Close() error {
+	if w.err != nil {
+		return w.err
+	}
+	if err := w.writeHeader(); err != nil {
+		return err
+	}
+	if w.rows > 0 {
+		if err := w.flushRowGroup(); err != nil {
+			return err
+		}
+	}
+
+	footer := appendStruct(nil, thriftStruct{
+		{1, int32(1)},
+		{2, w.schema()},
+		{3, w.numRows},
+		{4, w.rowGroups},
+		{6, "github.com/mickamy/go-sql-audit-driver"},
+	})
+	footer = binary.LittleEndian.AppendUint32(footer, uint32(len(footer)))
+	if err := w.write(append(footer, magic...)); err != nil {
+		return err
+	}
+	w.err = errors.New("parquet writer is closed")
+	return nil
+}
+
+// writeHeader writes the magic starting the file, unless it is written already.
+func (w *Writer) writeHeader() error {
+	if w.offset > 0 {
+		return nil
+	}
+	return w.write(magic)
+}
+
+// flushRowGroup writes the buffered rows as a row group.
+func (w *Writer) flushRowGroup() error {
+	if err := w.writeHeader(); err != nil {
+		return err
+	}
+
+	columns := make(thriftList, len(w.columns))
+	var totalSize int64
+	for i, column := range w.columns {
+		chunk := &w.chunks[i]
+		page, uncompressedSize, err := chunk.page(column)
+		if err != nil {
+			return err
+		}
+		offset := w.offset
+		if err := w.write(page); err != nil {
+			return err
+		}
+		totalSize += uncompressedSize
+		columns[i] = thriftStruct{
+			{2, offset},
+			{3, thriftStruct{
+				{1, physicalType(column.Type)},
+				{2, thriftList{int32(encodingPlain), int32(encodingRLE)}},
+				{3, path(column)},
+				{4, int32(codecGzip)},
+				{5, int64(len(chunk.definitions))},
+				{6, uncompressedSize},
+				{7, int64(len(page))},
+				{9, offset},
+			}},
+		}
+		*chunk = columnChunk{}
+	}
+
+	w.rowGroups = append(w.rowGroups, thriftStruct{
+		{1, columns},
+		{2, totalSize},
+		{3, w.rows},
+	})
+	w.numRows += w.rows
+	w.rows = 0
+	return nil
+}
+
+func (w *Writer) write(p []byte) error {
+	n, err := w.w.Write(p)
+	w.offset += int64(n)
+	if err != nil {
+		w.err = fmt.Errorf("failed to write parquet file: %w", err)
+		return w.err
+	}
+	return nil
+}
+
+// schema returns the schema elements of the file: the root, followed by the columns depth-first.
+func (w *Writer) schema() thriftList {
+	elements := thriftList{thriftStruct{{4, "schema"}, {5, int32(len(w.columns))}}}
+	for _, column := range w.columns {
+		repetition := int32(repetitionRequired)
+		if column.Optional {
+			repetition = repetitionOptional
+		}
+		switch column.Type {
+		case String:
+			elements = append(elements, stringElement(column.Name, repetition))
+		case Int64:
+			elements = append(elements, thriftStruct{{1, int32(typeInt64)}, {3, repetition}, {4, column.Name}})
+		case Boolean:
+			elements = append(elements, thriftStruct{{1, int32(typeBoolean)}, {3, repetition}, {4, column.Name}})
+		case Timestamp:
+			elements = append(elements, thriftStruct{
+				{1, int32(typeInt64)},
+				{3, repetition},
+				{4, column.Name},
+				{6, int32(convertedTimestampMicros)},
+				// TIMESTAMP(isAdjustedToUTC = true, unit = MICROS)
+				{10, thriftStruct{{8, thriftStruct{{1, true}, {2, thriftStruct{{2, thriftStruct{}}}}}}}},
+			})
+		case StringList:
+			// the three-level LIST structure: <name> (LIST) > repeated list > element
+			elements = append(elements,
+				thriftStruct{
+					{3, int32(repetitionOptional)},
+					{4, column.Name},
+					{5, int32(1)},
+					{6, int32(convertedList)},
+					{10, thriftStruct{{3, thriftStruct{}}}},
+				},
+				thriftStruct{{3, int32(repetitionRepeated)}, {4, "list"}, {5, int32(1)}},
+				stringElement("element", repetitionRequired),
+			)
+		}
+	}
+	return elements
+}
+
+// stringElement returns the schema element of a UTF-8 string column.
+func stringElement(name string, repetition int32) thriftStruct {
+	return thriftStruct{
+		{1, int32(typeByteArray)},
+		{3, repetition},
+		{4, name},
+		{6, int32(convertedUTF8)},
+		{10, thriftStruct{{1, thriftStruct{}}}},
+	}
+}
+
+// path returns the path of the leaf of column in the schema.
+func path(column Column) thriftList {
+	if column.Type == StringList {
+		return thriftList{column.Name, "list", "element"}
+	}
+	return thriftList{column.Name}
+}
+
+// physicalType returns the physical type of the values of t.
+func physicalType(t Type) int32 {
+	switch t {
+	case Int64, Timestamp:
+		return typeInt64
+	case Boolean:
+		return typeBoolean
+	default:
+		return typeByteArray
+	}
+}
+
+// append buffers value of column.
+func (c *columnChunk) append(column Column, value any) error {
+	if column.Type == StringList {
+		return c.appendList(value)
+	}
+	if value == nil {
+		if !column.Optional {
+			return errors.New("null value of a required column")
+		}
+		c.definitions = append(c.definitions, 0)
+		return nil
+	}
+
+	switch v := value.(type) {
+	case string:
+		if column.Type != String {
+			return fmt.Errorf("unexpected value of type %T", value)
+		}
+		c.appendString(v)
+	case int64:
+		if column.Type != Int64 {
+			return fmt.Errorf("unexpected value of type %T", value)
+		}
+		c.values = binary.LittleEndian.AppendUint64(c.values, uint64(v))
+	case time.Time:
+		if column.Type != Timestamp {
+			return fmt.Errorf("unexpected value of type %T", value)
+		}
+		c.values = binary.LittleEndian.AppendUint64(c.values, uint64(v.UnixMicro()))
+	case bool:
+		if column.Type != Boolean {
+			return fmt.Errorf("unexpected value of type %T", value)
+		}
+		if c.booleans%8 == 0 {
+			c.values = append(c.values, 0)
+		}
+		if v {
+			c.values[len(c.values)-1] |= 1 << (c.booleans % 8)
+		}
+		c.booleans++
+	default:
+		return fmt.Errorf("unexpected value of type %T", value)
+	}
+	c.definitions = append(c.definitions, 1)
+	return nil
+}
+
+// appendList buffers a list of strings: null lists are defined at level 0, empty lists at 1, and elements at 2,
+// repeating the list of the previous element.
+func (c *columnChunk) appendList(value any) error {
+	var list []string
+	switch v := value.(type) {
+	case nil:
+	case []string:
+		list = v
+	default:
+		return fmt.Errorf("unexpected value of type %T", value)
+	}
+
+	switch {
+	case list == nil:
+		c.repetitions = append(c.repetitions, 0)
+		c.definitions = append(c.definitions, 0)
+	case len(list) == 0:
+		c.repetitions = append(c.repetitions, 0)
+		c.definitions = append(c.definitions, 1)
+	default:
+		for i, element := range list {
+			c.repetitions = append(c.repetitions, min(uint8(i), 1))
+			c.definitions = append(c.definitions, 2)
+			c.appendString(element)
+		}
+	}
+	return nil
+}
+
+func (c *columnChunk) appendString(v string) {
+	c.values = binary.LittleEndian.AppendUint32(c.values, uint32(len(v)))
+	c.values = append(c.values, v...)
+}
+
+// page returns the buffered values of column as a data page with its header, and the uncompressed size of the page.
+func (c *columnChunk) page(column Column) ([]byte, int64, error) {
+	var body []byte
+	if column.Type == StringList {
+		body = appendLevels(body, c.repetitions)
+		body = appendLevels(body, c.definitions)
+	} else if column.Optional {
+		body = appendLevels(body, c.definitions)
+	}
+	body = append(body, c.values...)
+
+	var compressed bytes.Buffer
+	zw := gzip.NewWriter(&compressed)
+	if _, err := zw.Write(body); err != nil {
+		return nil, 0, fmt.Errorf("failed to compress parquet page: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		return nil, 0, fmt.Errorf("failed to compress parquet page: %w", err)
+	}
+
+	header := appendStruct(nil, thriftStruct{
+		{1, int32(pageTypeData)},
+		{2, int32(len(body))},
+		{3, int32(compressed.Len())},
+		{5, thriftStruct{
+			{1, int32(len(c.definitions))},
+			{2, int32(encodingPlain)},
+			{3, int32(encodingRLE)},
+			{4, int32(encodingRLE)},
+		}},
+	})
+	return append(header, compressed.Bytes()...), int64(len(header) + len(body)), nil
+}
+
+// appendLevels appends levels to dst in the RLE/bit-packed hybrid encoding of data pages,
+// as RLE runs prefixed by their length.
+func appendLevels(dst []byte, levels []uint8) []byte {
+	start := len(dst)
+	dst = append(dst, 0, 0, 0, 0)
+	for i := 0; i < len(levels); {
+		run := 1
+		for i+run < len(levels) && levels[i+run] == levels[i] {
+			run++
+		}
+		dst = binary.AppendUvarint(dst, uint64(run)<<1)
+		// levels are at most 2, so values of runs are a byte wide
+		dst = append(dst, levels[i])
+		i += run
+	}
+	binary.LittleEndian.PutUint32(dst[start:], uint32(len(dst)-start-4))
+	return dst
+}
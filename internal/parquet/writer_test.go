@@ -0,0 +1,328 @@
+package parquet_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mickamy/go-sql-audit-driver/internal/parquet"
+)
+
+// TestWriter tests that written rows are read back from the file
+func TestWriter(t *testing.T) {
+	t.Parallel()
+
+	modifiedAt := time.Date(2025, 1, 2, 3, 4, 5, 678901000, time.UTC)
+	columns := []parquet.Column{
+		{Name: "id", Type: parquet.String},
+		{Name: "shard", Type: parquet.String, Optional: true},
+		{Name: "backend_pid", Type: parquet.Int64, Optional: true},
+		{Name: "foreign_table", Type: parquet.Boolean},
+		{Name: "modified_at", Type: parquet.Timestamp},
+		{Name: "record_ids", Type: parquet.StringList},
+	}
+
+	testCases := []struct {
+		name         string
+		rows         [][]any
+		rowGroupSize int
+		rowGroups    int
+	}{
+		{name: "empty"},
+		{
+			name: "values",
+			rows: [][]any{
+				{"1", "eu", int64(42), true, modifiedAt, []string{"10", "11"}},
+				{"2", nil, nil, false, modifiedAt.Add(time.Hour), []string{}},
+				{"3", "", int64(-1), true, modifiedAt, nil},
+			},
+			rowGroups: 1,
+		},
+		{
+			name:         "row_groups",
+			rows:         rows(20, modifiedAt),
+			rowGroupSize: 1,
+			rowGroups:    20,
+		},
+		{
+			name:      "bit_packed_booleans",
+			rows:      rows(20, modifiedAt),
+			rowGroups: 1,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// arrange
+			var buf bytes.Buffer
+			w := parquet.NewWriter(&buf, columns)
+			if tc.rowGroupSize > 0 {
+				w.RowGroupSize = tc.rowGroupSize
+			}
+
+			// act
+			for _, row := range tc.rows {
+				require.NoError(t, w.Write(row))
+			}
+			require.NoError(t, w.Close())
+
+			// assert
+			file := readFile(t, buf.Bytes())
+			assert.Equal(t, []string{"id", "shard", "backend_pid", "foreign_table", "modified_at", "record_ids"}, file.names)
+			assert.Equal(t, tc.rowGroups, file.rowGroups)
+			assert.Equal(t, len(tc.rows), file.numRows)
+			require.Len(t, file.rows, len(tc.rows))
+			for i, row := range tc.rows {
+				assert.Equal(t, row, file.rows[i], "row %d", i)
+			}
+		})
+	}
+}
+
+// TestWriter_Invalid tests that rows not matching the columns are rejected
+func TestWriter_Invalid(t *testing.T) {
+	t.Parallel()
+
+	columns := []parquet.Column{{Name: "id", Type: parquet.String}, {Name: "n", Type: parquet.Int64, Optional: true}}
+	testCases := []struct {
+		name string
+		row  []any
+	}{
+		{name: "missing_value", row: []any{"1"}},
+		{name: "null_required", row: []any{nil, int64(1)}},
+		{name: "wrong_type", row: []any{"1", 1}},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// arrange
+			w := parquet.NewWriter(io.Discard, columns)
+
+			// act
+			err := w.Write(tc.row)
+
+			// assert
+			assert.Error(t, err)
+		})
+	}
+}
+
+func rows(n int, modifiedAt time.Time) [][]any {
+	rows := make([][]any, n)
+	for i := range rows {
+		rows[i] = []any{fmt.Sprint(i), "eu", int64(i), i%3 == 0, modifiedAt.Add(time.Duration(i) * time.Second), []string{fmt.Sprint(i)}}
+	}
+	return rows
+}
+
+// file is a Parquet file read by readFile.
+type file struct {
+	names     []string
+	numRows   int
+	rowGroups int
+	rows      [][]any
+}
+
+// readFile reads the files written for the columns of TestWriter, decoding the Thrift metadata and the data pages.
+func readFile(t *testing.T, data []byte) file {
+	t.Helper()
+
+	require.True(t, bytes.HasPrefix(data, []byte("PAR1")))
+	require.True(t, bytes.HasSuffix(data, []byte("PAR1")))
+	footerSize := int(binary.LittleEndian.Uint32(data[len(data)-8:]))
+	footer := &thriftDecoder{data: data[len(data)-8-footerSize : len(data)-8]}
+	metadata := footer.readStruct()
+	require.Empty(t, footer.data, "the footer should be read to its end")
+
+	var f file
+	f.numRows = int(metadata[3].(int64))
+	schema := metadata[2].([]any)
+	var leaves []map[int16]any
+	for i := 1; i < len(schema); i++ {
+		element := schema[i].(map[int16]any)
+		if _, ok := element[5]; ok {
+			// the LIST group and its repeated group
+			f.names = append(f.names, string(element[4].([]byte)))
+			i += 2
+			leaves = append(leaves, schema[i].(map[int16]any))
+			continue
+		}
+		f.names = append(f.names, string(element[4].([]byte)))
+		leaves = append(leaves, element)
+	}
+
+	for _, rowGroup := range metadata[4].([]any) {
+		f.rowGroups++
+		numRows := int(rowGroup.(map[int16]any)[3].(int64))
+		rows := make([][]any, numRows)
+		for i := range rows {
+			rows[i] = make([]any, len(leaves))
+		}
+		for c, chunk := range rowGroup.(map[int16]any)[1].([]any) {
+			meta := chunk.(map[int16]any)[3].(map[int16]any)
+			page := &thriftDecoder{data: data[meta[9].(int64):]}
+			header := page.readStruct()
+			compressed := page.data[:header[3].(int32)]
+			zr, err := gzip.NewReader(bytes.NewReader(compressed))
+			require.NoError(t, err)
+			body, err := io.ReadAll(zr)
+			require.NoError(t, err)
+			require.Len(t, body, int(header[2].(int32)))
+			numValues := int(header[5].(map[int16]any)[1].(int32))
+
+			leaf := leaves[c]
+			optional := leaf[3].(int32) == 1
+			var repetitions, definitions []int
+			if f.names[c] == "record_ids" {
+				repetitions, body = readLevels(t, body, numValues)
+				definitions, body = readLevels(t, body, numValues)
+			} else if optional {
+				definitions, body = readLevels(t, body, numValues)
+			}
+
+			row, booleans := -1, 0
+			for i := range numValues {
+				if repetitions == nil || repetitions[i] == 0 {
+					row++
+				}
+				if definitions != nil && definitions[i] == 0 {
+					continue
+				}
+				if repetitions != nil {
+					list, _ := rows[row][c].([]string)
+					if list == nil {
+						list = []string{}
+					}
+					if definitions[i] == 2 {
+						n := binary.LittleEndian.Uint32(body)
+						list = append(list, string(body[4:4+n]))
+						body = body[4+n:]
+					}
+					rows[row][c] = list
+					continue
+				}
+				switch leaf[1].(int32) {
+				case 0:
+					rows[row][c] = body[booleans/8]&(1<<(booleans%8)) != 0
+					booleans++
+				case 2:
+					v := int64(binary.LittleEndian.Uint64(body))
+					body = body[8:]
+					if _, ok := leaf[6]; ok {
+						rows[row][c] = time.UnixMicro(v).UTC()
+					} else {
+						rows[row][c] = v
+					}
+				case 6:
+					n := binary.LittleEndian.Uint32(body)
+					rows[row][c] = string(body[4 : 4+n])
+					body = body[4+n:]
+				}
+			}
+			require.Equal(t, numRows-1, row)
+		}
+		f.rows = append(f.rows, rows...)
+	}
+	return f
+}
+
+// readLevels reads n levels in the RLE/bit-packed hybrid encoding prefixed by their length, returning the rest of data.
+func readLevels(t *testing.T, data []byte, n int) ([]int, []byte) {
+	t.Helper()
+
+	length := binary.LittleEndian.Uint32(data)
+	encoded, rest := data[4:4+length], data[4+length:]
+	var levels []int
+	for len(encoded) > 0 {
+		header, size := binary.Uvarint(encoded)
+		require.Zero(t, header&1, "only RLE runs are expected")
+		for range header >> 1 {
+			levels = append(levels, int(encoded[size]))
+		}
+		encoded = encoded[size+1:]
+	}
+	require.Len(t, levels, n)
+	return levels, rest
+}
+
+// thriftDecoder decodes the Thrift compact protocol into maps of field IDs to values.
+type thriftDecoder struct {
+	data []byte
+}
+
+func (d *thriftDecoder) readStruct() map[int16]any {
+	fields := make(map[int16]any)
+	var last int16
+	for {
+		b := d.data[0]
+		d.data = d.data[1:]
+		if b == 0 {
+			return fields
+		}
+		typ := b & 0x0f
+		if delta := int16(b >> 4); delta > 0 {
+			last += delta
+		} else {
+			last = int16(d.readVarint())
+		}
+		switch typ {
+		case 1:
+			fields[last] = true
+		case 2:
+			fields[last] = false
+		default:
+			fields[last] = d.readValue(typ)
+		}
+	}
+}
+
+func (d *thriftDecoder) readValue(typ byte) any {
+	switch typ {
+	case 5:
+		return int32(d.readVarint())
+	case 6:
+		return d.readVarint()
+	case 8:
+		n, size := binary.Uvarint(d.data)
+		v := d.data[size : size+int(n)]
+		d.data = d.data[size+int(n):]
+		return v
+	case 9:
+		b := d.data[0]
+		d.data = d.data[1:]
+		n := int(b >> 4)
+		if n == 15 {
+			size, read := binary.Uvarint(d.data)
+			n = int(size)
+			d.data = d.data[read:]
+		}
+		list := make([]any, n)
+		for i := range list {
+			list[i] = d.readValue(b & 0x0f)
+		}
+		return list
+	case 12:
+		return d.readStruct()
+	default:
+		panic(fmt.Sprintf("unexpected thrift type %d", typ))
+	}
+}
+
+func (d *thriftDecoder) readVarint() int64 {
+	v, size := binary.Varint(d.data)
+	d.data = d.data[size:]
+	return v
+}
@@ -0,0 +1,71 @@
+package postgres
+
+import (
+	"fmt"
+	"strings"
+)
+
+var arrayElementReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+
+// FormatArray formats values as a PostgreSQL text array literal, e.g. {"a","b"}.
+func FormatArray(values []string) string {
+	var builder strings.Builder
+	builder.WriteByte('{')
+	for i, v := range values {
+		if i > 0 {
+			builder.WriteByte(',')
+		}
+		builder.WriteByte('"')
+		builder.WriteString(arrayElementReplacer.Replace(v))
+		builder.WriteByte('"')
+	}
+	builder.WriteByte('}')
+	return builder.String()
+}
+
+// ParseArray parses a one-dimensional PostgreSQL text array literal, e.g. {a,"b c"}, as returned by the database.
+// NULL elements are returned as empty strings.
+func ParseArray(literal string) ([]string, error) {
+	if len(literal) < 2 || literal[0] != '{' || literal[len(literal)-1] != '}' {
+		return nil, fmt.Errorf("invalid array literal: %q", literal)
+	}
+	body := literal[1 : len(literal)-1]
+	if body == "" {
+		return []string{}, nil
+	}
+
+	var (
+		values  []string
+		element strings.Builder
+		quoted  bool
+	)
+	for i := 0; i < len(body); i++ {
+		c := body[i]
+		switch {
+		case quoted && c == '\\' && i+1 < len(body):
+			i++
+			element.WriteByte(body[i])
+		case c == '"':
+			quoted = !quoted
+		case !quoted && c == ',':
+			values = append(values, arrayElement(element.String(), body, i))
+			element.Reset()
+		default:
+			element.WriteByte(c)
+		}
+	}
+	if quoted {
+		return nil, fmt.Errorf("invalid array literal: %q", literal)
+	}
+	values = append(values, arrayElement(element.String(), body, len(body)))
+
+	return values, nil
+}
+
+// arrayElement returns the parsed element ending at end of body, mapping an unquoted NULL to an empty string.
+func arrayElement(element string, body string, end int) string {
+	if element == "NULL" && end >= 4 && body[end-4:end] == "NULL" {
+		return ""
+	}
+	return element
+}
@@ -0,0 +1,48 @@
+package postgres_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mickamy/go-sql-audit-driver/internal/postgres"
+)
+
+// TestParseArray tests parsing of text array literals
+func TestParseArray(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name    string
+		literal string
+		want    []string
+		wantErr bool
+	}{
+		{name: "empty", literal: "{}", want: []string{}},
+		{name: "unquoted", literal: "{a,b}", want: []string{"a", "b"}},
+		{name: "quoted", literal: `{"a b","c,d","e\"f","g\\h"}`, want: []string{"a b", "c,d", `e"f`, `g\h`}},
+		{name: "null", literal: `{a,NULL,"NULL"}`, want: []string{"a", "", "NULL"}},
+		{name: "round_trip", literal: postgres.FormatArray([]string{`x"y`, `z\`}), want: []string{`x"y`, `z\`}},
+		{name: "invalid", literal: "a,b", wantErr: true},
+		{name: "unterminated_quote", literal: `{"a}`, wantErr: true},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// act
+			got, err := postgres.ParseArray(tc.literal)
+
+			// assert
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
@@ -0,0 +1,15 @@
+package postgres
+
+import (
+	"strings"
+)
+
+// QuoteIdentifier quotes a PostgreSQL identifier such as a role or table name.
+func QuoteIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// QuoteLiteral quotes a string as a PostgreSQL string literal.
+func QuoteLiteral(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+}
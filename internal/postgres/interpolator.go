@@ -2,35 +2,90 @@ package postgres
 
 import (
 	"database/sql/driver"
-	"regexp"
-	"strings"
+	"strconv"
+	"sync"
 
 	"github.com/mickamy/go-sql-audit-driver/internal/formatter"
 )
 
-var (
-	dollarPlaceholderRegexp = regexp.MustCompile(`\$\d+`)
-)
+// maxPooledBufferSize is the capacity up to which interpolation buffers are reused.
+const maxPooledBufferSize = 64 * 1024
+
+// interpolationBuffers reuses the byte buffers interpolated statements are built in.
+var interpolationBuffers = sync.Pool{
+	New: func() any {
+		buf := make([]byte, 0, 1024)
+		return &buf
+	},
+}
 
-// InterpolateSQL replaces PostgreSQL dollar placeholders with actual values.
+// InterpolateSQL replaces PostgreSQL dollar placeholders with actual values, $1 with the argument of ordinal 1 and so on.
+// Placeholders without an argument are replaced with ?. String literals, quoted identifiers, and comments are kept as is.
 func InterpolateSQL(query string, args []driver.NamedValue) string {
-	matches := dollarPlaceholderRegexp.FindAllStringIndex(query, -1)
-	if len(matches) == 0 || len(args) == 0 {
+	if len(args) == 0 {
 		return query
 	}
 
-	var builder strings.Builder
-	last := 0
-	for i, match := range matches {
-		builder.WriteString(query[last:match[0]])
-		if i < len(args) {
-			builder.WriteString(formatter.SQLValue(args[i]))
+	bufp := interpolationBuffers.Get().(*[]byte)
+	buf := (*bufp)[:0]
+
+	last, replaced := 0, false
+	for i := 0; i < len(query); i++ {
+		if end := SkipLiteral(query, i); end > i {
+			i = end - 1
+			continue
+		}
+		if query[i] != '$' || i > 0 && isIdentChar(query[i-1]) {
+			continue
+		}
+		end := i + 1
+		for end < len(query) && query[end] >= '0' && query[end] <= '9' {
+			end++
+		}
+		if end == i+1 {
+			continue
+		}
+
+		buf = append(buf, query[last:i]...)
+		if arg, ok := argument(args, query[i+1:end]); ok {
+			buf = formatter.AppendSQLValue(buf, arg)
 		} else {
-			builder.WriteString("?")
+			buf = append(buf, '?')
 		}
-		last = match[1]
+		replaced = true
+		last = end
+		i = end - 1
 	}
-	builder.WriteString(query[last:])
 
-	return builder.String()
+	result := query
+	if replaced {
+		buf = append(buf, query[last:]...)
+		result = string(buf)
+	}
+
+	// large buffers are dropped rather than retained by the pool
+	if cap(buf) <= maxPooledBufferSize {
+		*bufp = buf
+		interpolationBuffers.Put(bufp)
+	}
+
+	return result
+}
+
+// argument returns the argument of the placeholder number, e.g. "1" for $1. Arguments without ordinals are taken
+// by position.
+func argument(args []driver.NamedValue, number string) (driver.NamedValue, bool) {
+	n, err := strconv.Atoi(number)
+	if err != nil || n < 1 {
+		return driver.NamedValue{}, false
+	}
+	if n <= len(args) && (args[n-1].Ordinal == n || args[n-1].Ordinal == 0) {
+		return args[n-1], true
+	}
+	for _, arg := range args {
+		if arg.Ordinal == n {
+			return arg, true
+		}
+	}
+	return driver.NamedValue{}, false
 }
@@ -0,0 +1,117 @@
+package postgres_test
+
+import (
+	"database/sql/driver"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mickamy/go-sql-audit-driver/internal/postgres"
+)
+
+// TestInterpolateSQL tests interpolation of dollar placeholders
+func TestInterpolateSQL(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name  string
+		query string
+		args  []driver.NamedValue
+		want  string
+	}{
+		{
+			name:  "no_args",
+			query: "DELETE FROM users",
+			want:  "DELETE FROM users",
+		},
+		{
+			name:  "values",
+			query: "INSERT INTO users (id, name, age, active, created_at, data) VALUES ($1, $2, $3, $4, $5, $6)",
+			args: []driver.NamedValue{
+				{Ordinal: 1, Value: "1"},
+				{Ordinal: 2, Value: "O'Brien"},
+				{Ordinal: 3, Value: int64(42)},
+				{Ordinal: 4, Value: true},
+				{Ordinal: 5, Value: time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)},
+				{Ordinal: 6, Value: []byte{0xde, 0xad}},
+			},
+			want: "INSERT INTO users (id, name, age, active, created_at, data) VALUES ('1', 'O''Brien', '42', 'true', '2025-01-02 03:04:05+00:00', 'dead')",
+		},
+		{
+			name:  "null_and_missing_args",
+			query: "UPDATE users SET name = $1 WHERE id = $2",
+			args:  []driver.NamedValue{{Ordinal: 1, Value: nil}},
+			want:  "UPDATE users SET name = NULL WHERE id = ?",
+		},
+		{
+			name:  "dollar_without_digits",
+			query: "UPDATE users SET price = $1 WHERE tag = '$'",
+			args:  []driver.NamedValue{{Ordinal: 1, Value: 1.5}},
+			want:  "UPDATE users SET price = '1.5' WHERE tag = '$'",
+		},
+		{
+			name:  "placeholders_out_of_order",
+			query: "UPDATE users SET name = $2 WHERE id = $1 OR parent_id = $1",
+			args:  []driver.NamedValue{{Ordinal: 1, Value: "1"}, {Ordinal: 2, Value: "John"}},
+			want:  "UPDATE users SET name = 'John' WHERE id = '1' OR parent_id = '1'",
+		},
+		{
+			name:  "placeholders_in_literals_and_comments",
+			query: "UPDATE users SET note = '$1', body = $tag$ $1 $tag$, \"col$1\" = $1 /* $1 */ WHERE price$1 = $2 -- $2",
+			args:  []driver.NamedValue{{Ordinal: 1, Value: "a"}, {Ordinal: 2, Value: "b"}},
+			want:  "UPDATE users SET note = '$1', body = $tag$ $1 $tag$, \"col$1\" = 'a' /* $1 */ WHERE price$1 = 'b' -- $2",
+		},
+		{
+			name:  "escape_string",
+			query: "UPDATE users SET note = E'it\\'s $1' WHERE id = $1",
+			args:  []driver.NamedValue{{Ordinal: 1, Value: "1"}},
+			want:  "UPDATE users SET note = E'it\\'s $1' WHERE id = '1'",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// act
+			got := postgres.InterpolateSQL(tc.query, tc.args)
+
+			// assert
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+// FuzzInterpolateSQL tests that interpolation does not panic and leaves statements without placeholders as is
+func FuzzInterpolateSQL(f *testing.F) {
+	f.Add("UPDATE users SET name = $2 WHERE id = $1", "O'Brien")
+	f.Add("UPDATE users SET note = $$ $1 $$, x = E'\\' $1' WHERE id = $1", "1")
+	f.Add("/* $1 /* $2 */ */ DELETE FROM users WHERE id = $99999999999999999999", "1")
+	f.Add("INSERT INTO t VALUES ($0, $1, $", "")
+
+	f.Fuzz(func(t *testing.T, query string, arg string) {
+		got := postgres.InterpolateSQL(query, []driver.NamedValue{{Ordinal: 1, Value: arg}})
+		if !strings.Contains(query, "$") && got != query {
+			t.Errorf("InterpolateSQL(%q) = %q, want the query unchanged", query, got)
+		}
+	})
+}
+
+func BenchmarkInterpolateSQL(b *testing.B) {
+	query := "UPDATE users SET name = $1, email = $2, age = $3, updated_at = $4 WHERE id = $5"
+	args := []driver.NamedValue{
+		{Ordinal: 1, Value: "John O'Brien"},
+		{Ordinal: 2, Value: "john@example.com"},
+		{Ordinal: 3, Value: int64(42)},
+		{Ordinal: 4, Value: time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)},
+		{Ordinal: 5, Value: "3f1c5a52-8d4e-4d1b-9a57-6f0e2b7c9d11"},
+	}
+
+	b.ReportAllocs()
+	for b.Loop() {
+		_ = postgres.InterpolateSQL(query, args)
+	}
+}
@@ -0,0 +1,96 @@
+package postgres
+
+import (
+	"strings"
+)
+
+// SkipLiteral returns the index right after the string literal, dollar-quoted string, quoted identifier, or comment
+// starting at i, or i if none starts there. Unterminated ones extend to the end of sql.
+// Block comments nest, and backslashes escape characters in escape strings (E'...'), as in PostgreSQL.
+func SkipLiteral(sql string, i int) int {
+	if i >= len(sql) {
+		return i
+	}
+	switch c := sql[i]; {
+	case c == '\'':
+		escapes := i > 0 && (sql[i-1] == 'E' || sql[i-1] == 'e') && (i < 2 || !isIdentChar(sql[i-2]))
+		return skipQuoted(sql, i, escapes)
+	case c == '"' || c == '`':
+		return skipQuoted(sql, i, false)
+	case c == '-' && strings.HasPrefix(sql[i:], "--"):
+		end := strings.IndexByte(sql[i:], '\n')
+		if end < 0 {
+			return len(sql)
+		}
+		return i + end + 1
+	case c == '/' && strings.HasPrefix(sql[i:], "/*"):
+		depth := 0
+		for j := i; j < len(sql)-1; j++ {
+			switch {
+			case sql[j] == '/' && sql[j+1] == '*':
+				depth++
+				j++
+			case sql[j] == '*' && sql[j+1] == '/':
+				depth--
+				j++
+				if depth == 0 {
+					return j + 1
+				}
+			}
+		}
+		return len(sql)
+	case c == '$' && (i == 0 || !isIdentChar(sql[i-1])):
+		tag := dollarQuoteTag(sql, i)
+		if tag == "" {
+			return i
+		}
+		end := strings.Index(sql[i+len(tag):], tag)
+		if end < 0 {
+			return len(sql)
+		}
+		return i + len(tag) + end + len(tag)
+	default:
+		return i
+	}
+}
+
+// skipQuoted returns the index right after the quote closing the quoted string or identifier starting at start.
+// Doubled quotes are escaped quotes, as are backslash-escaped ones if escapes is set.
+func skipQuoted(sql string, start int, escapes bool) int {
+	quote := sql[start]
+	for i := start + 1; i < len(sql); i++ {
+		switch {
+		case escapes && sql[i] == '\\':
+			i++
+		case sql[i] != quote:
+		case i+1 < len(sql) && sql[i+1] == quote:
+			i++
+		default:
+			return i + 1
+		}
+	}
+	return len(sql)
+}
+
+// dollarQuoteTag returns the opening delimiter of the dollar-quoted string starting at i, e.g. $$ or $body$,
+// or an empty string if there is none. Placeholders such as $1 are not dollar quotes.
+func dollarQuoteTag(sql string, i int) string {
+	for j := i + 1; j < len(sql); j++ {
+		c := sql[j]
+		switch {
+		case c == '$':
+			return sql[i : j+1]
+		case c >= '0' && c <= '9':
+			if j == i+1 {
+				return ""
+			}
+		case !isIdentChar(c):
+			return ""
+		}
+	}
+	return ""
+}
+
+func isIdentChar(c byte) bool {
+	return c == '_' || c == '$' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= 0x80
+}
@@ -0,0 +1,11 @@
+package sqlite
+
+import (
+	"encoding/json"
+)
+
+// FormatArray formats values as a JSON array, e.g. ["a","b"], as SQLite has no array types.
+func FormatArray(values []string) string {
+	data, _ := json.Marshal(values)
+	return string(data)
+}
@@ -0,0 +1,142 @@
+package sqlite
+
+import (
+	"database/sql/driver"
+	"encoding/hex"
+	"strconv"
+
+	"github.com/mickamy/go-sql-audit-driver/internal/formatter"
+)
+
+// InterpolateSQL replaces SQLite placeholders with actual values. Parameters are numbered as SQLite numbers them:
+// ?NNN is parameter NNN, ? the parameter after the largest one so far, and a named parameter such as :name, @name, or
+// $name the one after the largest one at its first occurrence. Named parameters take the argument of their name, if
+// any, or the unnamed one of their number, as go-sqlite3 binds them. Placeholders without an argument are kept. String literals, quoted identifiers, and
+// comments are kept as is.
+func InterpolateSQL(query string, args []driver.NamedValue) string {
+	if len(args) == 0 {
+		return query
+	}
+
+	var (
+		buf      []byte
+		last     int
+		largest  int
+		named    map[string]int
+		replaced bool
+	)
+	for i := 0; i < len(query); i++ {
+		if end := SkipLiteral(query, i); end > i {
+			i = end - 1
+			continue
+		}
+
+		var (
+			n   int
+			end = i + 1
+		)
+		switch query[i] {
+		case '?':
+			for end < len(query) && query[end] >= '0' && query[end] <= '9' {
+				end++
+			}
+			if end == i+1 {
+				largest++
+				n = largest
+				break
+			}
+			number, err := strconv.Atoi(query[i+1 : end])
+			if err != nil {
+				continue
+			}
+			n, largest = number, max(largest, number)
+		case ':', '@', '$':
+			if i > 0 && isIdentChar(query[i-1]) {
+				continue
+			}
+			for end < len(query) && isIdentChar(query[end]) {
+				end++
+			}
+			if end == i+1 || query[i] == ':' && end < len(query) && query[end] == ':' {
+				continue
+			}
+			name := query[i+1 : end]
+			if named == nil {
+				named = map[string]int{}
+			}
+			if n = named[name]; n == 0 {
+				largest++
+				n, named[name] = largest, largest
+			}
+			if arg, ok := argumentByName(args, name); ok {
+				buf = appendArg(buf, query, last, i, arg, len(args))
+				last, replaced, i = end, true, end-1
+				continue
+			}
+		default:
+			continue
+		}
+
+		if arg, ok := argument(args, n); ok {
+			buf = appendArg(buf, query, last, i, arg, len(args))
+			last, replaced = end, true
+		}
+		i = end - 1
+	}
+
+	if !replaced {
+		return query
+	}
+	return string(append(buf, query[last:]...))
+}
+
+// appendArg appends the query from last up to the placeholder at i, and the value of arg.
+func appendArg(buf []byte, query string, last int, i int, arg driver.NamedValue, args int) []byte {
+	if buf == nil {
+		buf = make([]byte, 0, len(query)+args*16)
+	}
+	buf = append(buf, query[last:i]...)
+	return appendValue(buf, arg)
+}
+
+// argument returns the unnamed argument of parameter n, as named ones are bound by name.
+// Arguments without ordinals are taken by position.
+func argument(args []driver.NamedValue, n int) (driver.NamedValue, bool) {
+	if n < 1 {
+		return driver.NamedValue{}, false
+	}
+	if n <= len(args) && args[n-1].Name == "" && (args[n-1].Ordinal == n || args[n-1].Ordinal == 0) {
+		return args[n-1], true
+	}
+	for _, arg := range args {
+		if arg.Name == "" && arg.Ordinal == n {
+			return arg, true
+		}
+	}
+	return driver.NamedValue{}, false
+}
+
+// argumentByName returns the argument named name, e.g. with sql.Named.
+func argumentByName(args []driver.NamedValue, name string) (driver.NamedValue, bool) {
+	for _, arg := range args {
+		if arg.Name != "" && arg.Name == name {
+			return arg, true
+		}
+	}
+	return driver.NamedValue{}, false
+}
+
+// appendValue appends the SQL interpolation of arg to dst. Bytes are blob literals; other values are formatted as
+// for PostgreSQL.
+func appendValue(dst []byte, arg driver.NamedValue) []byte {
+	if v, ok := arg.Value.([]byte); ok {
+		dst = append(dst, "X'"...)
+		dst = hex.AppendEncode(dst, v)
+		return append(dst, '\'')
+	}
+	return formatter.AppendSQLValue(dst, arg)
+}
+
+func isIdentChar(c byte) bool {
+	return c == '_' || c == '$' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= 0x80
+}
@@ -0,0 +1,99 @@
+package sqlite_test
+
+import (
+	"database/sql/driver"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mickamy/go-sql-audit-driver/internal/sqlite"
+)
+
+// TestInterpolateSQL tests interpolation of numbered, anonymous, and named placeholders
+func TestInterpolateSQL(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name  string
+		query string
+		args  []driver.NamedValue
+		want  string
+	}{
+		{
+			name:  "no_args",
+			query: "DELETE FROM users",
+			want:  "DELETE FROM users",
+		},
+		{
+			name:  "anonymous",
+			query: "INSERT INTO [users] (id, name, data) VALUES (?, ?, ?)",
+			args: []driver.NamedValue{
+				{Ordinal: 1, Value: int64(1)},
+				{Ordinal: 2, Value: `O'Brien \`},
+				{Ordinal: 3, Value: []byte{0xde, 0xad}},
+			},
+			want: `INSERT INTO [users] (id, name, data) VALUES ('1', 'O''Brien \', X'dead')`,
+		},
+		{
+			name:  "numbered",
+			query: "UPDATE users SET name = ?2, nick = ? WHERE id = ?1",
+			args:  []driver.NamedValue{{Ordinal: 1, Value: "1"}, {Ordinal: 2, Value: "John"}, {Ordinal: 3, Value: "Johnny"}},
+			want:  "UPDATE users SET name = 'John', nick = 'Johnny' WHERE id = '1'",
+		},
+		{
+			name:  "named",
+			query: "UPDATE users SET name = :name, nick = @nick, age = $age WHERE id = $id OR parent_id = :name",
+			args:  []driver.NamedValue{{Name: "id", Ordinal: 1, Value: "1"}, {Name: "name", Ordinal: 2, Value: "John"}, {Ordinal: 3, Value: nil}},
+			want:  "UPDATE users SET name = 'John', nick = @nick, age = NULL WHERE id = '1' OR parent_id = 'John'",
+		},
+		{
+			name:  "placeholders_in_literals_and_comments",
+			query: "UPDATE users SET note = 'it''s ?', \"col?\" = ?, [x?] = ? /* ? */ WHERE id = ? -- ?",
+			args:  []driver.NamedValue{{Ordinal: 1, Value: "a"}, {Ordinal: 2, Value: "b"}},
+			want:  "UPDATE users SET note = 'it''s ?', \"col?\" = 'a', [x?] = 'b' /* ? */ WHERE id = ? -- ?",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// act
+			got := sqlite.InterpolateSQL(tc.query, tc.args)
+
+			// assert
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+// FuzzInterpolateSQL tests that interpolation does not panic and leaves statements without placeholders as is
+func FuzzInterpolateSQL(f *testing.F) {
+	f.Add("UPDATE users SET name = ?2 WHERE id = ?", "O'Brien")
+	f.Add("UPDATE users SET note = :note, x = '?' WHERE id = ?99999999999999999999", "1")
+	f.Add("/* ? DELETE FROM [users WHERE id = @", "1")
+
+	f.Fuzz(func(t *testing.T, query string, arg string) {
+		got := sqlite.InterpolateSQL(query, []driver.NamedValue{{Ordinal: 1, Value: arg}})
+		if !strings.ContainsAny(query, "?:@$") && got != query {
+			t.Errorf("InterpolateSQL(%q) = %q, want the query unchanged", query, got)
+		}
+	})
+}
+
+// TestNormalize tests blanking out SQLite string literals and comments
+func TestNormalize(t *testing.T) {
+	t.Parallel()
+
+	// arrange
+	query := "UPDATE [users] SET a = 'x -- y', \"b\" = ? -- DELETE FROM t\nWHERE id = 1 /* INSERT INTO t */"
+
+	// act
+	got := sqlite.Normalize(query)
+
+	// assert
+	assert.Equal(t, "UPDATE [users] SET a = '      ', \"b\" = ?                 \nWHERE id = 1                    ", got)
+	assert.Len(t, got, len(query), "offsets should be kept")
+}
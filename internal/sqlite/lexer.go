@@ -0,0 +1,90 @@
+package sqlite
+
+import (
+	"strings"
+)
+
+// SkipLiteral returns the index right after the string literal, quoted identifier, or comment starting at i,
+// or i if none starts there. Unterminated ones extend to the end of sql.
+// Identifiers are quoted with double quotes, backticks, or brackets, and block comments do not nest, as in SQLite.
+func SkipLiteral(sql string, i int) int {
+	if i >= len(sql) {
+		return i
+	}
+	switch c := sql[i]; {
+	case c == '\'' || c == '"' || c == '`':
+		return skipQuoted(sql, i)
+	case c == '[':
+		end := strings.IndexByte(sql[i:], ']')
+		if end < 0 {
+			return len(sql)
+		}
+		return i + end + 1
+	case c == '-' && strings.HasPrefix(sql[i:], "--"):
+		end := strings.IndexByte(sql[i:], '\n')
+		if end < 0 {
+			return len(sql)
+		}
+		return i + end + 1
+	case c == '/' && strings.HasPrefix(sql[i:], "/*"):
+		end := strings.Index(sql[i+2:], "*/")
+		if end < 0 {
+			return len(sql)
+		}
+		return i + 2 + end + 2
+	default:
+		return i
+	}
+}
+
+// skipQuoted returns the index right after the quote closing the quoted string or identifier starting at start.
+// Doubled quotes are escaped quotes.
+func skipQuoted(sql string, start int) int {
+	quote := sql[start]
+	for i := start + 1; i < len(sql); i++ {
+		if sql[i] != quote {
+			continue
+		}
+		if i+1 < len(sql) && sql[i+1] == quote {
+			i++
+			continue
+		}
+		return i + 1
+	}
+	return len(sql)
+}
+
+// Normalize returns sql with string literals blanked out as single-quoted strings of spaces and comments replaced
+// by spaces, so that statements can be scanned with the PostgreSQL lexical rules, e.g. for table names.
+// Offsets, line breaks, and quoted identifiers are kept as is.
+func Normalize(sql string) string {
+	var normalized []byte
+	for i := 0; i < len(sql); i++ {
+		end := SkipLiteral(sql, i)
+		if end == i || sql[i] == '"' || sql[i] == '`' || sql[i] == '[' {
+			if end > i {
+				i = end - 1
+			}
+			continue
+		}
+		if normalized == nil {
+			normalized = []byte(sql)
+		}
+		for j := i; j < end; j++ {
+			if sql[j] != '\n' {
+				normalized[j] = ' '
+			}
+		}
+		if sql[i] == '\'' {
+			normalized[i] = '\''
+			if end-1 > i && sql[end-1] == '\'' {
+				normalized[end-1] = '\''
+			}
+		}
+		i = end - 1
+	}
+	if normalized == nil {
+		return sql
+	}
+	return string(normalized)
+}
@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS database_modifications
+(
+    id           UUID        NOT NULL PRIMARY KEY,
+    operator_id  UUID        NOT NULL,
+    execution_id UUID        NOT NULL,
+    table_name   VARCHAR(63) NOT NULL,
+    action       VARCHAR(10) NOT NULL,
+    sql          TEXT        NOT NULL,
+    modified_at  TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_database_modifications_operator_id ON database_modifications (operator_id);
+CREATE INDEX IF NOT EXISTS idx_database_modifications_execution_id ON database_modifications (execution_id);
+CREATE INDEX IF NOT EXISTS idx_database_modifications_table_name ON database_modifications (table_name);
+CREATE INDEX IF NOT EXISTS idx_database_modifications_modified_at ON database_modifications (modified_at);
@@ -0,0 +1,6 @@
+ALTER TABLE database_modifications
+    DROP COLUMN IF EXISTS record_ids,
+    DROP COLUMN IF EXISTS schema_name,
+    DROP COLUMN IF EXISTS foreign_table,
+    DROP COLUMN IF EXISTS source_tables,
+    DROP COLUMN IF EXISTS exactness;
@@ -0,0 +1,6 @@
+ALTER TABLE database_modifications
+    ADD COLUMN IF NOT EXISTS record_ids    TEXT[],
+    ADD COLUMN IF NOT EXISTS schema_name   VARCHAR(63),
+    ADD COLUMN IF NOT EXISTS foreign_table BOOLEAN,
+    ADD COLUMN IF NOT EXISTS source_tables TEXT[],
+    ADD COLUMN IF NOT EXISTS exactness     VARCHAR(16) NOT NULL DEFAULT 'exact';
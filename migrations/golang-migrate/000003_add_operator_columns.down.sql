@@ -0,0 +1,3 @@
+ALTER TABLE database_modifications
+    DROP COLUMN IF EXISTS operator_name,
+    DROP COLUMN IF EXISTS operator_email;
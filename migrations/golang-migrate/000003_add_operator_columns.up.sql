@@ -0,0 +1,3 @@
+ALTER TABLE database_modifications
+    ADD COLUMN IF NOT EXISTS operator_name  TEXT,
+    ADD COLUMN IF NOT EXISTS operator_email TEXT;
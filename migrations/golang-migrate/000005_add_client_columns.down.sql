@@ -0,0 +1,4 @@
+ALTER TABLE database_modifications
+    DROP COLUMN IF EXISTS client_ip,
+    DROP COLUMN IF EXISTS user_agent,
+    DROP COLUMN IF EXISTS device;
@@ -0,0 +1,4 @@
+ALTER TABLE database_modifications
+    ADD COLUMN IF NOT EXISTS client_ip  INET,
+    ADD COLUMN IF NOT EXISTS user_agent TEXT,
+    ADD COLUMN IF NOT EXISTS device     TEXT;
@@ -0,0 +1,2 @@
+ALTER TABLE database_modifications
+    DROP COLUMN IF EXISTS shard;
@@ -0,0 +1,4 @@
+ALTER TABLE database_modifications
+    ADD COLUMN IF NOT EXISTS shard_key TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_database_modifications_shard_key ON database_modifications (shard_key);
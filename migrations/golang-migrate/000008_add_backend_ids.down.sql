@@ -0,0 +1,3 @@
+ALTER TABLE database_modifications
+    DROP COLUMN IF EXISTS backend_pid,
+    DROP COLUMN IF EXISTS transaction_id;
@@ -0,0 +1,3 @@
+ALTER TABLE database_modifications
+    ADD COLUMN IF NOT EXISTS backend_pid    INTEGER,
+    ADD COLUMN IF NOT EXISTS transaction_id BIGINT;
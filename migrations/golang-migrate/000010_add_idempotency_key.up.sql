@@ -0,0 +1,2 @@
+ALTER TABLE database_modifications
+    ADD COLUMN IF NOT EXISTS idempotency_key TEXT;
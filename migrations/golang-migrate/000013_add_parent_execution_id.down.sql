@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_database_modifications_parent_execution_id;
+
+ALTER TABLE database_modifications
+    DROP COLUMN IF EXISTS parent_execution_id;
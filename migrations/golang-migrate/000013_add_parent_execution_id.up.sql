@@ -0,0 +1,4 @@
+ALTER TABLE database_modifications
+    ADD COLUMN IF NOT EXISTS parent_execution_id UUID;
+
+CREATE INDEX IF NOT EXISTS idx_database_modifications_parent_execution_id ON database_modifications (parent_execution_id);
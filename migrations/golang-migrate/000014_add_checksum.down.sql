@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_database_modifications_checksum;
+
+ALTER TABLE database_modifications
+    DROP COLUMN IF EXISTS checksum;
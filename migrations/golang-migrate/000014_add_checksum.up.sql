@@ -0,0 +1,4 @@
+ALTER TABLE database_modifications
+    ADD COLUMN IF NOT EXISTS checksum CHAR(64);
+
+CREATE INDEX IF NOT EXISTS idx_database_modifications_checksum ON database_modifications (checksum);
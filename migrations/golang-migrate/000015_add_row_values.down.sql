@@ -0,0 +1,3 @@
+ALTER TABLE database_modifications
+    DROP COLUMN IF EXISTS old_values,
+    DROP COLUMN IF EXISTS new_values;
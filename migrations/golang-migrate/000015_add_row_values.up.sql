@@ -0,0 +1,3 @@
+ALTER TABLE database_modifications
+    ADD COLUMN IF NOT EXISTS old_values JSONB,
+    ADD COLUMN IF NOT EXISTS new_values JSONB;
@@ -0,0 +1,15 @@
+-- +goose Up
+ALTER TABLE database_modifications
+    ADD COLUMN IF NOT EXISTS record_ids    TEXT[],
+    ADD COLUMN IF NOT EXISTS schema_name   VARCHAR(63),
+    ADD COLUMN IF NOT EXISTS foreign_table BOOLEAN,
+    ADD COLUMN IF NOT EXISTS source_tables TEXT[],
+    ADD COLUMN IF NOT EXISTS exactness     VARCHAR(16) NOT NULL DEFAULT 'exact';
+
+-- +goose Down
+ALTER TABLE database_modifications
+    DROP COLUMN IF EXISTS record_ids,
+    DROP COLUMN IF EXISTS schema_name,
+    DROP COLUMN IF EXISTS foreign_table,
+    DROP COLUMN IF EXISTS source_tables,
+    DROP COLUMN IF EXISTS exactness;
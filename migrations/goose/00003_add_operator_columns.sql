@@ -0,0 +1,9 @@
+-- +goose Up
+ALTER TABLE database_modifications
+    ADD COLUMN IF NOT EXISTS operator_name  TEXT,
+    ADD COLUMN IF NOT EXISTS operator_email TEXT;
+
+-- +goose Down
+ALTER TABLE database_modifications
+    DROP COLUMN IF EXISTS operator_name,
+    DROP COLUMN IF EXISTS operator_email;
@@ -0,0 +1,11 @@
+-- +goose Up
+ALTER TABLE database_modifications
+    ADD COLUMN IF NOT EXISTS client_ip  INET,
+    ADD COLUMN IF NOT EXISTS user_agent TEXT,
+    ADD COLUMN IF NOT EXISTS device     TEXT;
+
+-- +goose Down
+ALTER TABLE database_modifications
+    DROP COLUMN IF EXISTS client_ip,
+    DROP COLUMN IF EXISTS user_agent,
+    DROP COLUMN IF EXISTS device;
@@ -0,0 +1,7 @@
+-- +goose Up
+ALTER TABLE database_modifications
+    ADD COLUMN IF NOT EXISTS shard VARCHAR(63);
+
+-- +goose Down
+ALTER TABLE database_modifications
+    DROP COLUMN IF EXISTS shard;
@@ -0,0 +1,11 @@
+-- +goose Up
+ALTER TABLE database_modifications
+    ADD COLUMN IF NOT EXISTS shard_key TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_database_modifications_shard_key ON database_modifications (shard_key);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_database_modifications_shard_key;
+
+ALTER TABLE database_modifications
+    DROP COLUMN IF EXISTS shard_key;
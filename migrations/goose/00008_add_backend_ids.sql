@@ -0,0 +1,9 @@
+-- +goose Up
+ALTER TABLE database_modifications
+    ADD COLUMN IF NOT EXISTS backend_pid    INTEGER,
+    ADD COLUMN IF NOT EXISTS transaction_id BIGINT;
+
+-- +goose Down
+ALTER TABLE database_modifications
+    DROP COLUMN IF EXISTS backend_pid,
+    DROP COLUMN IF EXISTS transaction_id;
@@ -0,0 +1,7 @@
+-- +goose Up
+ALTER TABLE database_modifications
+    ADD COLUMN IF NOT EXISTS estimated_rows BIGINT;
+
+-- +goose Down
+ALTER TABLE database_modifications
+    DROP COLUMN IF EXISTS estimated_rows;
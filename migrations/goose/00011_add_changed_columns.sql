@@ -0,0 +1,7 @@
+-- +goose Up
+ALTER TABLE database_modifications
+    ADD COLUMN IF NOT EXISTS changed_columns TEXT[];
+
+-- +goose Down
+ALTER TABLE database_modifications
+    DROP COLUMN IF EXISTS changed_columns;
@@ -0,0 +1,11 @@
+-- +goose Up
+ALTER TABLE database_modifications
+    ADD COLUMN IF NOT EXISTS parent_execution_id UUID;
+
+CREATE INDEX IF NOT EXISTS idx_database_modifications_parent_execution_id ON database_modifications (parent_execution_id);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_database_modifications_parent_execution_id;
+
+ALTER TABLE database_modifications
+    DROP COLUMN IF EXISTS parent_execution_id;
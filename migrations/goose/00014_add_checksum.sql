@@ -0,0 +1,11 @@
+-- +goose Up
+ALTER TABLE database_modifications
+    ADD COLUMN IF NOT EXISTS checksum CHAR(64);
+
+CREATE INDEX IF NOT EXISTS idx_database_modifications_checksum ON database_modifications (checksum);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_database_modifications_checksum;
+
+ALTER TABLE database_modifications
+    DROP COLUMN IF EXISTS checksum;
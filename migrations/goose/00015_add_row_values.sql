@@ -0,0 +1,9 @@
+-- +goose Up
+ALTER TABLE database_modifications
+    ADD COLUMN IF NOT EXISTS old_values JSONB,
+    ADD COLUMN IF NOT EXISTS new_values JSONB;
+
+-- +goose Down
+ALTER TABLE database_modifications
+    DROP COLUMN IF EXISTS old_values,
+    DROP COLUMN IF EXISTS new_values;
@@ -0,0 +1,41 @@
+// Package migrations embeds the audit schema as versioned migrations, so that applications can create and upgrade
+// database_modifications with the migration tool they already use:
+//
+//	// golang-migrate
+//	source, err := iofs.New(migrations.GolangMigrate, ".")
+//	m, err := migrate.NewWithSourceInstance("iofs", source, dsn)
+//
+//	// pressly/goose
+//	goose.SetBaseFS(migrations.Goose)
+//	err := goose.Up(db, ".")
+//
+// Future releases add columns as new migrations, so running the migrations of a newer release upgrades the schema.
+// Tables created by hand from the README are adopted: the migrations only create what is missing.
+package migrations
+
+import (
+	"embed"
+	"io/fs"
+)
+
+// LatestVersion is the version of the latest migration.
+const LatestVersion = 15
+
+//go:embed golang-migrate/*.sql goose/*.sql
+var files embed.FS
+
+// GolangMigrate contains the migrations in the format of github.com/golang-migrate/migrate,
+// e.g. 000001_create_database_modifications.up.sql and .down.sql.
+var GolangMigrate = sub("golang-migrate")
+
+// Goose contains the migrations in the format of github.com/pressly/goose,
+// e.g. 00001_create_database_modifications.sql with up and down annotations.
+var Goose = sub("goose")
+
+func sub(dir string) fs.FS {
+	f, err := fs.Sub(files, dir)
+	if err != nil {
+		panic(err)
+	}
+	return f
+}
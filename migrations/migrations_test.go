@@ -0,0 +1,53 @@
+package migrations_test
+
+import (
+	"fmt"
+	"io/fs"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mickamy/go-sql-audit-driver/audriver"
+	"github.com/mickamy/go-sql-audit-driver/migrations"
+)
+
+// TestMigrations tests that the golang-migrate and goose migrations are complete and in sync
+func TestMigrations(t *testing.T) {
+	t.Parallel()
+
+	// arrange
+	migrateFiles, err := fs.Glob(migrations.GolangMigrate, "*.sql")
+	require.NoError(t, err)
+	gooseFiles, err := fs.Glob(migrations.Goose, "*.sql")
+	require.NoError(t, err)
+
+	// assert
+	require.Len(t, migrateFiles, migrations.LatestVersion*2)
+	require.Len(t, gooseFiles, migrations.LatestVersion)
+
+	var ups strings.Builder
+	for version := 1; version <= migrations.LatestVersion; version++ {
+		gooseName := gooseFiles[version-1]
+		require.True(t, strings.HasPrefix(gooseName, fmt.Sprintf("%05d_", version)), gooseName)
+		name := strings.TrimSuffix(gooseName[len("00000_"):], ".sql")
+
+		up, err := fs.ReadFile(migrations.GolangMigrate, fmt.Sprintf("%06d_%s.up.sql", version, name))
+		require.NoError(t, err)
+		down, err := fs.ReadFile(migrations.GolangMigrate, fmt.Sprintf("%06d_%s.down.sql", version, name))
+		require.NoError(t, err)
+		goose, err := fs.ReadFile(migrations.Goose, gooseName)
+		require.NoError(t, err)
+
+		assert.Equal(t, "-- +goose Up\n"+string(up)+"\n-- +goose Down\n"+string(down), string(goose), gooseName)
+		ups.Write(up)
+	}
+
+	schema, err := audriver.GenerateSchemaSQL(audriver.SchemaConfig{})
+	require.NoError(t, err)
+	for _, match := range regexp.MustCompile(`(?m)^    (\w+) `).FindAllStringSubmatch(schema, -1) {
+		assert.Contains(t, ups.String(), " "+match[1]+" ", "column %s is not created by the migrations", match[1])
+	}
+}
@@ -0,0 +1,40 @@
+CREATE TABLE database_modifications
+(
+    id                  CHAR(36)     NOT NULL PRIMARY KEY,
+    operator_id         CHAR(36)     NOT NULL,
+    execution_id        CHAR(36)     NOT NULL,
+    table_name          VARCHAR(64)  NOT NULL,
+    action              ENUM ('insert', 'update', 'delete', 'unknown') NOT NULL,
+    `sql`               LONGTEXT     NOT NULL,
+    modified_at         DATETIME(6)  NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+    record_ids          JSON,
+    schema_name         VARCHAR(64),
+    foreign_table       BOOLEAN,
+    source_tables       JSON,
+    changed_columns     JSON,
+    operator_name       TEXT,
+    operator_email      TEXT,
+    shard               VARCHAR(63),
+    shard_key           VARCHAR(255),
+    backend_pid         INTEGER,
+    transaction_id      BIGINT,
+    estimated_rows      BIGINT,
+    idempotency_key     TEXT,
+    parent_execution_id CHAR(36),
+    step                TEXT,
+    client_ip           VARCHAR(45),
+    user_agent          TEXT,
+    device              TEXT,
+    checksum            CHAR(64),
+    old_values          JSON,
+    new_values          JSON,
+    metadata            JSON,
+    exactness           VARCHAR(16)  NOT NULL DEFAULT 'exact'
+);
+
+CREATE INDEX idx_database_modifications_execution_id ON database_modifications (execution_id);
+CREATE INDEX idx_database_modifications_operator_id ON database_modifications (operator_id);
+CREATE INDEX idx_database_modifications_shard_key ON database_modifications (shard_key);
+CREATE INDEX idx_database_modifications_parent_execution_id ON database_modifications (parent_execution_id);
+CREATE INDEX idx_database_modifications_checksum ON database_modifications (checksum);
+CREATE INDEX idx_database_modifications_table_name_action ON database_modifications (table_name, action);
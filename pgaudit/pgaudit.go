@@ -0,0 +1,233 @@
+// Package pgaudit correlates pgAudit log lines with audriver records, to report writes
+// that reached the database without being audited and audit records without a matching log line.
+//
+// Statements must be executed with audriver.WithLogCorrelation enabled, so that pgAudit logs them
+// with the execution ID comment.
+package pgaudit
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/mickamy/go-sql-audit-driver/audriver"
+)
+
+// ClassWrite is the pgAudit class of INSERT, UPDATE, DELETE, TRUNCATE, and COPY statements.
+const ClassWrite = "WRITE"
+
+const auditPrefix = "AUDIT: "
+
+var executionIDRegexp = regexp.MustCompile(`/\* ` + audriver.ExecutionIDCommentKey + `=([^ ]+) \*/`)
+
+// targetTableRegexp matches the table written by a statement, for entries logged without the object name.
+var targetTableRegexp = regexp.MustCompile(
+	`(?is)^\s*(?:/\*.*?\*/\s*)*(?:INSERT\s+INTO|UPDATE(?:\s+ONLY)?|DELETE\s+FROM(?:\s+ONLY)?|COPY)\s+` +
+		`((?:"[^"]*"|[^\s(".;]+)(?:\.(?:"[^"]*"|[^\s(".;]+))?)`,
+)
+
+// Entry is a single pgAudit log entry.
+type Entry struct {
+	AuditType      string
+	StatementID    int
+	SubstatementID int
+	Class          string
+	Command        string
+	ObjectType     string
+	ObjectName     string
+	Statement      string
+
+	// ExecutionID is the execution ID of the statement comment, or empty if the statement has none.
+	ExecutionID string
+}
+
+// ParseLine parses a log line into an Entry.
+// It reports false if the line is not a pgAudit entry.
+func ParseLine(line string) (Entry, bool, error) {
+	i := strings.Index(line, auditPrefix)
+	if i < 0 {
+		return Entry{}, false, nil
+	}
+
+	r := csv.NewReader(strings.NewReader(line[i+len(auditPrefix):]))
+	r.FieldsPerRecord = -1
+	fields, err := r.Read()
+	if err != nil {
+		return Entry{}, true, fmt.Errorf("failed to parse pgAudit entry: %w", err)
+	}
+	if len(fields) < 8 {
+		return Entry{}, true, fmt.Errorf("failed to parse pgAudit entry: expected at least 8 fields, got %d", len(fields))
+	}
+
+	statementID, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return Entry{}, true, fmt.Errorf("failed to parse pgAudit statement ID: %w", err)
+	}
+	substatementID, err := strconv.Atoi(fields[2])
+	if err != nil {
+		return Entry{}, true, fmt.Errorf("failed to parse pgAudit substatement ID: %w", err)
+	}
+
+	entry := Entry{
+		AuditType:      fields[0],
+		StatementID:    statementID,
+		SubstatementID: substatementID,
+		Class:          fields[3],
+		Command:        fields[4],
+		ObjectType:     fields[5],
+		ObjectName:     fields[6],
+		Statement:      fields[7],
+	}
+	if m := executionIDRegexp.FindStringSubmatch(entry.Statement); m != nil {
+		entry.ExecutionID = m[1]
+	}
+
+	return entry, true, nil
+}
+
+// Parse parses the pgAudit entries of a log stream, skipping lines that are not pgAudit entries.
+// Statements spanning multiple lines are joined.
+func Parse(r io.Reader) ([]Entry, error) {
+	var entries []Entry
+	var pending string
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if pending != "" {
+			line = pending + "\n" + line
+			pending = ""
+		}
+
+		entry, ok, err := ParseLine(line)
+		if err != nil {
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) && errors.Is(parseErr.Err, csv.ErrQuote) {
+				// the quoted statement continues on the next line
+				pending = line
+				continue
+			}
+			return nil, err
+		}
+		if ok {
+			entries = append(entries, entry)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read log: %w", err)
+	}
+	if pending != "" {
+		return nil, fmt.Errorf("failed to parse pgAudit entry: unterminated statement")
+	}
+
+	return entries, nil
+}
+
+// Match is a pgAudit entry and the audit records of its execution.
+type Match struct {
+	Entry         Entry
+	Modifications []audriver.DatabaseModification
+}
+
+// Report is the result of correlating pgAudit entries with audit records.
+type Report struct {
+	// Matched are write entries with audit records of the same execution.
+	Matched []Match
+
+	// Unaudited are write entries without audit records, i.e. writes that bypassed audriver.
+	Unaudited []Entry
+
+	// Unlogged are audit records whose execution does not appear in the log.
+	Unlogged []audriver.DatabaseModification
+}
+
+// Correlate matches write entries with audit records by execution ID.
+// Entries of other classes are ignored, as are entries writing auditTables, which are the inserts of audit records
+// themselves. auditTables default to audriver.DefaultAuditTable and its staging table.
+func Correlate(entries []Entry, mods []audriver.DatabaseModification, auditTables ...string) Report {
+	if len(auditTables) == 0 {
+		auditTables = []string{audriver.DefaultAuditTable, audriver.DefaultAuditTable + "_staging"}
+	}
+
+	byExecution := make(map[string][]audriver.DatabaseModification)
+	for _, mod := range mods {
+		byExecution[mod.ExecutionID] = append(byExecution[mod.ExecutionID], mod)
+	}
+
+	var report Report
+	logged := make(map[string]bool)
+	for _, entry := range entries {
+		if entry.Class != ClassWrite || entry.writes(auditTables) {
+			continue
+		}
+		matched, ok := byExecution[entry.ExecutionID]
+		if entry.ExecutionID == "" || !ok {
+			report.Unaudited = append(report.Unaudited, entry)
+			continue
+		}
+		logged[entry.ExecutionID] = true
+		report.Matched = append(report.Matched, Match{Entry: entry, Modifications: matched})
+	}
+
+	for _, mod := range mods {
+		if !logged[mod.ExecutionID] {
+			report.Unlogged = append(report.Unlogged, mod)
+		}
+	}
+
+	return report
+}
+
+// writes reports whether the entry writes one of tables, which are optionally schema-qualified.
+// Unqualified tables match the table in any schema.
+func (e Entry) writes(tables []string) bool {
+	target := e.ObjectName
+	if target == "" {
+		m := targetTableRegexp.FindStringSubmatch(e.Statement)
+		if m == nil {
+			return false
+		}
+		target = m[1]
+	}
+	schema, table := splitQualifiedName(target)
+	for _, t := range tables {
+		s, name := splitQualifiedName(t)
+		if name == table && (s == "" || schema == "" || s == schema) {
+			return true
+		}
+	}
+	return false
+}
+
+// splitQualifiedName splits a possibly schema-qualified name on its last unquoted dot, unquoting quoted parts and
+// folding unquoted ones to lower case as PostgreSQL does.
+func splitQualifiedName(name string) (string, string) {
+	split := -1
+	quoted := false
+	for i := 0; i < len(name); i++ {
+		switch {
+		case name[i] == '"':
+			quoted = !quoted
+		case name[i] == '.' && !quoted:
+			split = i
+		}
+	}
+	if split < 0 {
+		return "", normalizeIdentifier(name)
+	}
+	return normalizeIdentifier(name[:split]), normalizeIdentifier(name[split+1:])
+}
+
+// normalizeIdentifier unquotes a quoted identifier, or folds an unquoted one to lower case.
+func normalizeIdentifier(identifier string) string {
+	if len(identifier) >= 2 && identifier[0] == '"' && identifier[len(identifier)-1] == '"' {
+		return strings.ReplaceAll(identifier[1:len(identifier)-1], `""`, `"`)
+	}
+	return strings.ToLower(identifier)
+}
@@ -0,0 +1,116 @@
+package pgaudit_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mickamy/go-sql-audit-driver/audriver"
+	"github.com/mickamy/go-sql-audit-driver/pgaudit"
+)
+
+// TestParse tests parsing of pgAudit log lines
+func TestParse(t *testing.T) {
+	t.Parallel()
+
+	// arrange
+	log := strings.Join([]string{
+		`2025-01-01 00:00:00 UTC [42] LOG:  connection authorized: user=audriver_writer`,
+		`2025-01-01 00:00:01 UTC [42] LOG:  AUDIT: SESSION,1,1,WRITE,INSERT,TABLE,public.users,"INSERT INTO users (id) VALUES ($1) /* audriver_execution_id=exec-1 */",<not logged>`,
+		`2025-01-01 00:00:02 UTC [43] LOG:  AUDIT: SESSION,2,1,WRITE,UPDATE,TABLE,public.users,"UPDATE users`,
+		`SET name = ""x""",<not logged>`,
+		`2025-01-01 00:00:03 UTC [43] LOG:  AUDIT: SESSION,3,1,READ,SELECT,TABLE,public.users,SELECT 1,<not logged>`,
+	}, "\n")
+
+	// act
+	entries, err := pgaudit.Parse(strings.NewReader(log))
+
+	// assert
+	require.NoError(t, err)
+	require.Len(t, entries, 3)
+	assert.Equal(t, pgaudit.Entry{
+		AuditType:      "SESSION",
+		StatementID:    1,
+		SubstatementID: 1,
+		Class:          pgaudit.ClassWrite,
+		Command:        "INSERT",
+		ObjectType:     "TABLE",
+		ObjectName:     "public.users",
+		Statement:      "INSERT INTO users (id) VALUES ($1) /* audriver_execution_id=exec-1 */",
+		ExecutionID:    "exec-1",
+	}, entries[0])
+	assert.Equal(t, "UPDATE users\nSET name = \"x\"", entries[1].Statement)
+	assert.Empty(t, entries[1].ExecutionID)
+	assert.Equal(t, "READ", entries[2].Class)
+}
+
+// TestCorrelate tests correlation of pgAudit entries with audit records
+func TestCorrelate(t *testing.T) {
+	t.Parallel()
+
+	// arrange
+	entries := []pgaudit.Entry{
+		{Class: pgaudit.ClassWrite, Command: "INSERT", ExecutionID: "exec-1"},
+		{Class: pgaudit.ClassWrite, Command: "UPDATE"},
+		{Class: pgaudit.ClassWrite, Command: "DELETE", ExecutionID: "exec-unknown"},
+		{Class: "READ", Command: "SELECT"},
+		{Class: pgaudit.ClassWrite, Command: "INSERT", ObjectName: "public.database_modifications"},
+		{Class: pgaudit.ClassWrite, Command: "INSERT", Statement: "INSERT INTO database_modifications (id) VALUES ($1)"},
+		{Class: pgaudit.ClassWrite, Command: "DELETE", Statement: `DELETE FROM "public"."database_modifications_staging"`},
+	}
+	mods := []audriver.DatabaseModification{
+		{ID: "mod-1", ExecutionID: "exec-1"},
+		{ID: "mod-2", ExecutionID: "exec-2"},
+	}
+
+	// act
+	report := pgaudit.Correlate(entries, mods)
+
+	// assert
+	require.Len(t, report.Matched, 1)
+	assert.Equal(t, entries[0], report.Matched[0].Entry)
+	assert.Equal(t, mods[:1], report.Matched[0].Modifications)
+	assert.Equal(t, []pgaudit.Entry{entries[1], entries[2]}, report.Unaudited)
+	assert.Equal(t, mods[1:], report.Unlogged)
+}
+
+// TestCorrelate_AuditTables tests that writes to custom audit tables are ignored
+func TestCorrelate_AuditTables(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name      string
+		entry     pgaudit.Entry
+		unaudited bool
+	}{
+		{name: "object_name", entry: pgaudit.Entry{ObjectName: "audit.modifications"}},
+		{name: "statement", entry: pgaudit.Entry{Statement: "INSERT INTO audit.modifications (id) VALUES ($1)"}},
+		{name: "quoted_statement", entry: pgaudit.Entry{Statement: `INSERT INTO "audit"."modifications" (id) VALUES ($1)`}},
+		{name: "unqualified_statement", entry: pgaudit.Entry{Statement: "INSERT INTO modifications (id) VALUES ($1)"}},
+		{name: "other_schema", entry: pgaudit.Entry{ObjectName: "public.modifications"}, unaudited: true},
+		{name: "default_audit_table", entry: pgaudit.Entry{ObjectName: "public.database_modifications"}, unaudited: true},
+		{name: "other_table", entry: pgaudit.Entry{Statement: "UPDATE users SET name = $1"}, unaudited: true},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// arrange
+			tc.entry.Class = pgaudit.ClassWrite
+
+			// act
+			report := pgaudit.Correlate([]pgaudit.Entry{tc.entry}, nil, "audit.modifications")
+
+			// assert
+			if tc.unaudited {
+				assert.Equal(t, []pgaudit.Entry{tc.entry}, report.Unaudited)
+			} else {
+				assert.Empty(t, report.Unaudited)
+			}
+		})
+	}
+}
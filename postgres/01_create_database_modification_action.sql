@@ -1,5 +1,6 @@
 CREATE TYPE database_modification_action AS ENUM (
     'insert',
     'update',
-    'delete'
+    'delete',
+    'unknown'
 );
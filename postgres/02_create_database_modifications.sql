@@ -1,14 +1,40 @@
 CREATE TABLE database_modifications
 (
-    id           UUID                         NOT NULL PRIMARY KEY,
-    operator_id  UUID                         NOT NULL,
-    execution_id UUID                         NOT NULL,
-    table_name   VARCHAR(63)                  NOT NULL,
-    action       database_modification_action NOT NULL,
-    sql          TEXT                         NOT NULL,
-    modified_at  TIMESTAMPTZ                  NOT NULL DEFAULT CURRENT_TIMESTAMP
+    id                  UUID                         NOT NULL PRIMARY KEY,
+    operator_id         UUID                         NOT NULL,
+    execution_id        UUID                         NOT NULL,
+    table_name          VARCHAR(63)                  NOT NULL,
+    action              database_modification_action NOT NULL,
+    sql                 TEXT                         NOT NULL,
+    modified_at         TIMESTAMPTZ                  NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    record_ids          TEXT[],
+    schema_name         VARCHAR(63),
+    foreign_table       BOOLEAN,
+    source_tables       TEXT[],
+    changed_columns     TEXT[],
+    operator_name       TEXT,
+    operator_email      TEXT,
+    shard               VARCHAR(63),
+    shard_key           TEXT,
+    backend_pid         INTEGER,
+    transaction_id      BIGINT,
+    estimated_rows      BIGINT,
+    idempotency_key     TEXT,
+    parent_execution_id UUID,
+    step                TEXT,
+    client_ip           INET,
+    user_agent          TEXT,
+    device              TEXT,
+    checksum            CHAR(64),
+    old_values          JSONB,
+    new_values          JSONB,
+    metadata            JSONB,
+    exactness           VARCHAR(16)                  NOT NULL DEFAULT 'exact'
 );
 
 CREATE INDEX idx_database_modifications_execution_id ON database_modifications (execution_id);
 CREATE INDEX idx_database_modifications_operator_id ON database_modifications (operator_id);
+CREATE INDEX idx_database_modifications_shard_key ON database_modifications (shard_key);
+CREATE INDEX idx_database_modifications_parent_execution_id ON database_modifications (parent_execution_id);
+CREATE INDEX idx_database_modifications_checksum ON database_modifications (checksum);
 CREATE INDEX idx_database_modifications_table_name_action ON database_modifications (table_name, action);
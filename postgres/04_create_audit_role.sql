@@ -0,0 +1,7 @@
+CREATE ROLE audriver_auditor NOLOGIN;
+
+GRANT INSERT ON database_modifications TO audriver_auditor;
+
+GRANT audriver_auditor TO audriver_writer;
+
+\echo '✅ Successfully created audit role.'
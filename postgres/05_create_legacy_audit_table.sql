@@ -0,0 +1,14 @@
+-- The audit table as created by the first release, without the optional columns added since,
+-- for tests that audit records can still be written to audit tables created back then.
+CREATE TABLE legacy_database_modifications
+(
+    id           UUID                         NOT NULL PRIMARY KEY,
+    operator_id  UUID                         NOT NULL,
+    execution_id UUID                         NOT NULL,
+    table_name   VARCHAR(63)                  NOT NULL,
+    action       database_modification_action NOT NULL,
+    sql          TEXT                         NOT NULL,
+    modified_at  TIMESTAMPTZ                  NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+\echo '✅ Successfully created legacy audit table.'
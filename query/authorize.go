@@ -0,0 +1,119 @@
+package query
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/mickamy/go-sql-audit-driver/audriver"
+	"github.com/mickamy/go-sql-audit-driver/internal/postgres"
+)
+
+// ErrForbidden may be returned by authorizers to deny a reader access to audit records.
+var ErrForbidden = errors.New("forbidden to read audit records")
+
+// Scope restricts the audit records a reader may read, e.g. to the records of its tenant.
+// Zero fields do not restrict the records; a zero Scope grants access to all records.
+type Scope struct {
+	// ShardKeys are the shard keys of the records the reader may read, e.g. the IDs of its tenants.
+	ShardKeys []string
+
+	// OperatorIDs are the operators whose records the reader may read, e.g. only its own.
+	OperatorIDs []string
+
+	// Tables are the tables whose records the reader may read.
+	Tables []string
+}
+
+// Allows reports whether mod is within the scope.
+func (s Scope) Allows(mod audriver.DatabaseModification) bool {
+	return (len(s.ShardKeys) == 0 || slices.Contains(s.ShardKeys, mod.ShardKey)) &&
+		(len(s.OperatorIDs) == 0 || slices.Contains(s.OperatorIDs, mod.OperatorID)) &&
+		(len(s.Tables) == 0 || slices.Contains(s.Tables, mod.TableName))
+}
+
+// conditions adds the conditions restricting records to the scope with add.
+func (s Scope) conditions(add func(condition string, arg any)) {
+	if len(s.ShardKeys) > 0 {
+		add("shard_key = ANY (?::text[])", postgres.FormatArray(s.ShardKeys))
+	}
+	if len(s.OperatorIDs) > 0 {
+		add("operator_id::text = ANY (?::text[])", postgres.FormatArray(s.OperatorIDs))
+	}
+	if len(s.Tables) > 0 {
+		add("table_name = ANY (?::text[])", postgres.FormatArray(s.Tables))
+	}
+}
+
+// Authorizer grants the reader of a context, e.g. the operator or tenant of an HTTP request, a scope of audit records.
+// Returning an error, e.g. ErrForbidden, denies the read.
+type Authorizer interface {
+	Authorize(ctx context.Context) (Scope, error)
+}
+
+// AuthorizerFunc is a function that implements Authorizer.
+type AuthorizerFunc func(ctx context.Context) (Scope, error)
+
+// Authorize calls f(ctx).
+func (f AuthorizerFunc) Authorize(ctx context.Context) (Scope, error) {
+	return f(ctx)
+}
+
+// Reader reads audit records from a database within the scope its authorizer grants the reader of each call,
+// so that applications exposing audit reads, e.g. to tenants, enforce the scope in one place.
+type Reader struct {
+	db         *sql.DB
+	authorizer Authorizer
+}
+
+// NewReader returns a reader of the audit records in db authorized by authorizer.
+// Every read is denied if authorizer is nil.
+func NewReader(db *sql.DB, authorizer Authorizer) *Reader {
+	return &Reader{db: db, authorizer: authorizer}
+}
+
+// Each is Each restricted to the scope of the reader of ctx.
+func (r *Reader) Each(ctx context.Context, filter Filter, fn func(audriver.DatabaseModification) error) error {
+	scope, err := r.authorize(ctx)
+	if err != nil {
+		return err
+	}
+	return each(ctx, r.db, filter, scope, fn)
+}
+
+// List is List restricted to the scope of the reader of ctx.
+func (r *Reader) List(ctx context.Context, filter Filter) ([]audriver.DatabaseModification, error) {
+	var mods []audriver.DatabaseModification
+	err := r.Each(ctx, filter, func(mod audriver.DatabaseModification) error {
+		mods = append(mods, mod)
+		return nil
+	})
+	return mods, err
+}
+
+// DiffExecutions is DiffExecutions restricted to the scope of the reader of ctx.
+func (r *Reader) DiffExecutions(ctx context.Context, filter Filter, executionID, otherExecutionID string) (Diff, error) {
+	mods, err := r.List(ctx, filter.execution(executionID))
+	if err != nil {
+		return Diff{}, err
+	}
+	otherMods, err := r.List(ctx, filter.execution(otherExecutionID))
+	if err != nil {
+		return Diff{}, err
+	}
+	return Compare(mods, otherMods), nil
+}
+
+// authorize returns the scope of the reader of ctx.
+func (r *Reader) authorize(ctx context.Context) (Scope, error) {
+	if r.authorizer == nil {
+		return Scope{}, fmt.Errorf("%w: no authorizer", ErrForbidden)
+	}
+	scope, err := r.authorizer.Authorize(ctx)
+	if err != nil {
+		return Scope{}, fmt.Errorf("failed to authorize audit read: %w", err)
+	}
+	return scope, nil
+}
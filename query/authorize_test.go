@@ -0,0 +1,102 @@
+package query_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mickamy/go-sql-audit-driver/audriver"
+	"github.com/mickamy/go-sql-audit-driver/query"
+)
+
+// TestScope_Allows tests restricting audit records to the scope of a reader
+func TestScope_Allows(t *testing.T) {
+	t.Parallel()
+
+	mod := audriver.DatabaseModification{OperatorID: "operator", TableName: "orders", ShardKey: "tenant-1"}
+
+	testCases := []struct {
+		name     string
+		scope    query.Scope
+		expected bool
+	}{
+		{name: "unrestricted", scope: query.Scope{}, expected: true},
+		{name: "own_tenant", scope: query.Scope{ShardKeys: []string{"tenant-1", "tenant-2"}}, expected: true},
+		{name: "other_tenant", scope: query.Scope{ShardKeys: []string{"tenant-2"}}, expected: false},
+		{name: "own_records", scope: query.Scope{OperatorIDs: []string{"operator"}, Tables: []string{"orders"}}, expected: true},
+		{name: "other_table", scope: query.Scope{OperatorIDs: []string{"operator"}, Tables: []string{"users"}}, expected: false},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// act
+			got := tc.scope.Allows(mod)
+
+			// assert
+			assert.Equal(t, tc.expected, got)
+		})
+	}
+}
+
+type tenantKey struct{}
+
+// TestReader tests reading audit records within the scope granted to the reader
+func TestReader(t *testing.T) {
+	t.Parallel()
+
+	// arrange
+	db := setUpTestDB(t)
+	db.SetMaxOpenConns(1)
+
+	executionID := uuid.New().String()
+	ctx := audriver.WithOperatorID(audriver.WithExecutionID(t.Context(), executionID), uuid.New().String())
+	_, err := db.ExecContext(ctx, `INSERT INTO users (id, name, email) VALUES ($1, $2, $3)`, uuid.New().String(), "name", "email@example.com")
+	require.NoError(t, err)
+
+	reader := query.NewReader(db, query.AuthorizerFunc(func(ctx context.Context) (query.Scope, error) {
+		tenant, ok := ctx.Value(tenantKey{}).(string)
+		switch {
+		case !ok:
+			return query.Scope{}, query.ErrForbidden
+		case tenant == "admin":
+			return query.Scope{}, nil
+		}
+		return query.Scope{ShardKeys: []string{tenant}}, nil
+	}))
+
+	testCases := []struct {
+		name    string
+		ctx     context.Context
+		records int
+		wantErr error
+	}{
+		{name: "anonymous", ctx: t.Context(), wantErr: query.ErrForbidden},
+		{name: "other_tenant", ctx: context.WithValue(t.Context(), tenantKey{}, "tenant-2")},
+		{name: "admin", ctx: context.WithValue(t.Context(), tenantKey{}, "admin"), records: 1},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// act
+			mods, err := reader.List(tc.ctx, query.Filter{ExecutionID: executionID})
+
+			// assert
+			if tc.wantErr != nil {
+				assert.ErrorIs(t, err, tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Len(t, mods, tc.records)
+		})
+	}
+
+	mods, err := query.NewReader(db, nil).List(t.Context(), query.Filter{ExecutionID: executionID})
+	assert.ErrorIs(t, err, query.ErrForbidden)
+	assert.Empty(t, mods)
+}
@@ -0,0 +1,122 @@
+package query
+
+import (
+	"context"
+	"database/sql"
+	"slices"
+
+	"github.com/mickamy/go-sql-audit-driver/audriver"
+)
+
+// TableChange is a set of changes to a table.
+type TableChange struct {
+	// Table is the modified table, qualified with its schema if known.
+	Table string
+
+	// RecordIDs are the IDs of the modified records, if known.
+	RecordIDs []string
+}
+
+// Diff is the difference between the changes of two executions, e.g. a rollout and its rollback.
+type Diff struct {
+	// Unreverted are changes of the first execution that the second execution did not touch:
+	// tables it did not modify at all, or records of a table it modified whose IDs it did not modify.
+	Unreverted []TableChange
+
+	// Additional are changes of the second execution that the first execution did not touch.
+	Additional []TableChange
+}
+
+// DiffExecutions compares the audit records of two executions read from db.
+// filter restricts the records compared, e.g. to an audit table with AuditTable; its ExecutionID is ignored.
+func DiffExecutions(ctx context.Context, db *sql.DB, filter Filter, executionID, otherExecutionID string) (Diff, error) {
+	mods, err := List(ctx, db, filter.execution(executionID))
+	if err != nil {
+		return Diff{}, err
+	}
+	otherMods, err := List(ctx, db, filter.execution(otherExecutionID))
+	if err != nil {
+		return Diff{}, err
+	}
+	return Compare(mods, otherMods), nil
+}
+
+// execution returns the filter selecting the records of executionID.
+func (f Filter) execution(executionID string) Filter {
+	f.ExecutionID = executionID
+	return f
+}
+
+// Compare compares the modified tables and records of two sets of audit records.
+// Records are only compared by ID if IDs were recorded; otherwise tables are compared.
+func Compare(mods, otherMods []audriver.DatabaseModification) Diff {
+	changes := changesByTable(mods)
+	otherChanges := changesByTable(otherMods)
+	return Diff{
+		Unreverted: subtract(changes, otherChanges),
+		Additional: subtract(otherChanges, changes),
+	}
+}
+
+func changesByTable(mods []audriver.DatabaseModification) map[string]map[string]bool {
+	tables := make(map[string]map[string]bool)
+	for _, mod := range mods {
+		table := mod.TableName
+		if mod.SchemaName != "" {
+			table = mod.SchemaName + "." + table
+		}
+		if tables[table] == nil {
+			tables[table] = make(map[string]bool)
+		}
+		for _, id := range mod.RecordIDs {
+			tables[table][id] = true
+		}
+	}
+	return tables
+}
+
+// subtract returns the changes of a that b did not touch, sorted by table and record ID.
+func subtract(a, b map[string]map[string]bool) []TableChange {
+	var changes []TableChange
+	for table, ids := range a {
+		otherIDs, ok := b[table]
+		if !ok {
+			changes = append(changes, TableChange{Table: table, RecordIDs: sortedKeys(ids)})
+			continue
+		}
+
+		var missing []string
+		for id := range ids {
+			if !otherIDs[id] {
+				missing = append(missing, id)
+			}
+		}
+		if len(missing) > 0 {
+			slices.Sort(missing)
+			changes = append(changes, TableChange{Table: table, RecordIDs: missing})
+		}
+	}
+
+	slices.SortFunc(changes, func(x, y TableChange) int {
+		if x.Table < y.Table {
+			return -1
+		}
+		if x.Table > y.Table {
+			return 1
+		}
+		return 0
+	})
+	return changes
+}
+
+func sortedKeys(m map[string]bool) []string {
+	if len(m) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
@@ -0,0 +1,41 @@
+package query_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mickamy/go-sql-audit-driver/audriver"
+	"github.com/mickamy/go-sql-audit-driver/query"
+)
+
+// TestCompare tests comparison of the changes of two executions
+func TestCompare(t *testing.T) {
+	t.Parallel()
+
+	// arrange
+	rollout := []audriver.DatabaseModification{
+		{TableName: "users", RecordIDs: []string{"1", "2"}},
+		{TableName: "orders"},
+		{SchemaName: "billing", TableName: "invoices", RecordIDs: []string{"10"}},
+	}
+	rollback := []audriver.DatabaseModification{
+		{TableName: "users", RecordIDs: []string{"1"}},
+		{SchemaName: "billing", TableName: "invoices", RecordIDs: []string{"10"}},
+		{TableName: "audit_notes"},
+	}
+
+	// act
+	diff := query.Compare(rollout, rollback)
+
+	// assert
+	assert.Equal(t, query.Diff{
+		Unreverted: []query.TableChange{
+			{Table: "orders"},
+			{Table: "users", RecordIDs: []string{"2"}},
+		},
+		Additional: []query.TableChange{
+			{Table: "audit_notes"},
+		},
+	}, diff)
+}
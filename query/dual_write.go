@@ -0,0 +1,191 @@
+package query
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mickamy/go-sql-audit-driver/audriver"
+	"github.com/mickamy/go-sql-audit-driver/internal/postgres"
+)
+
+// LegacyTable describes the table written by the audit triggers audriver replaces.
+// The defaults match audit.logged_actions of the audit trigger published on the PostgreSQL wiki.
+type LegacyTable struct {
+	// Name is the table, optionally schema-qualified. It defaults to audit.logged_actions.
+	Name string
+
+	// TableColumn is the column holding the name of the modified table. It defaults to table_name.
+	TableColumn string
+
+	// ActionColumn is the column holding the action. Actions are compared by their first letter, case-insensitively,
+	// so that both I, U, and D and INSERT, UPDATE, and DELETE match. It defaults to action.
+	ActionColumn string
+
+	// TransactionColumn is the column holding the transaction ID, e.g. txid_current(). It defaults to transaction_id.
+	TransactionColumn string
+
+	// TimeColumn is the column holding the time of the modification. It defaults to action_tstamp_tx.
+	TimeColumn string
+}
+
+// DualWriteOptions configures the comparison of audit records with a legacy trigger table.
+type DualWriteOptions struct {
+	// Legacy is the table written by the legacy triggers.
+	Legacy LegacyTable
+
+	// From and To are the window compared. To defaults to now and From to an hour before To.
+	// Triggers timestamp modifications when their transaction started, while audriver does when the statement was
+	// executed, so transactions spanning the bounds of the window may be counted on one side only.
+	From time.Time
+	To   time.Time
+
+	// ExcludedTables are tables not expected to be audited by audriver, e.g. tables excluded by table filters.
+	ExcludedTables []string
+
+	// AuditTable is the audit table compared, optionally schema-qualified. It defaults to audriver.DefaultAuditTable.
+	AuditTable string
+}
+
+// DualWriteReport compares the audit records of a window with the records of legacy triggers written alongside them.
+type DualWriteReport struct {
+	// From and To are the window compared.
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+
+	// Counts are the numbers of transactions recorded per table and action, ordered by table and action.
+	Counts []DualWriteCount `json:"counts"`
+}
+
+// DualWriteCount is the number of transactions modifying a table with an action recorded by each side.
+// Transactions are counted rather than records, since row-level triggers record every row while audriver records
+// every statement. audriver identifies transactions by transaction_id, recorded with WithBackendIDs;
+// without it, every record counts as a transaction of its own.
+type DualWriteCount struct {
+	Table  string `json:"table"`
+	Action string `json:"action"`
+
+	// Legacy is the number of transactions recorded by the legacy triggers.
+	Legacy int64 `json:"legacy"`
+
+	// Audited is the number of transactions recorded by audriver.
+	Audited int64 `json:"audited"`
+}
+
+// Discrepancies returns the counts of tables and actions that the legacy triggers and audriver recorded differently.
+// Writes bypassing the application, e.g. from psql or migrations, are only recorded by the triggers.
+func (r DualWriteReport) Discrepancies() []DualWriteCount {
+	var discrepancies []DualWriteCount
+	for _, count := range r.Counts {
+		if count.Legacy != count.Audited {
+			discrepancies = append(discrepancies, count)
+		}
+	}
+	return discrepancies
+}
+
+// legacyActions map the first letters of legacy actions to the actions of audriver.
+var legacyActions = map[string]string{"i": "insert", "u": "update", "d": "delete"}
+
+// VerifyDualWrite compares the audit records in db with the records of the legacy triggers in the window of opts,
+// to gain confidence that audriver records every modification before the triggers are dropped.
+func VerifyDualWrite(ctx context.Context, db *sql.DB, opts DualWriteOptions) (DualWriteReport, error) {
+	report := DualWriteReport{From: opts.From, To: opts.To}
+	if report.To.IsZero() {
+		report.To = time.Now()
+	}
+	if report.From.IsZero() {
+		report.From = report.To.Add(-time.Hour)
+	}
+	legacy := opts.Legacy.withDefaults()
+
+	rows, err := db.QueryContext(ctx, fmt.Sprintf(`WITH legacy AS (
+    SELECT %[2]s::text AS table_name, lower(left(%[3]s::text, 1)) AS action, count(DISTINCT %[4]s) AS transactions
+    FROM %[1]s
+    WHERE %[5]s >= $1 AND %[5]s < $2
+      AND lower(left(%[3]s::text, 1)) IN ('i', 'u', 'd')
+      AND NOT %[2]s::text = ANY ($3::text[])
+    GROUP BY 1, 2
+), audited AS (
+    SELECT table_name, left(action, 1) AS action, count(DISTINCT coalesce(transaction_id::text, id::text)) AS transactions
+    FROM %[6]s
+    WHERE modified_at >= $1 AND modified_at < $2
+      AND action IN ('insert', 'update', 'delete')
+      AND NOT table_name = ANY ($3::text[])
+    GROUP BY 1, 2
+)
+SELECT coalesce(l.table_name, a.table_name), coalesce(l.action, a.action),
+       coalesce(l.transactions, 0), coalesce(a.transactions, 0)
+FROM legacy l
+FULL JOIN audited a ON a.table_name = l.table_name AND a.action = l.action
+ORDER BY 1, 2`,
+		quoteQualifiedIdentifier(legacy.Name),
+		postgres.QuoteIdentifier(legacy.TableColumn),
+		postgres.QuoteIdentifier(legacy.ActionColumn),
+		postgres.QuoteIdentifier(legacy.TransactionColumn),
+		postgres.QuoteIdentifier(legacy.TimeColumn),
+		auditTable(opts.AuditTable),
+	), report.From, report.To, postgres.FormatArray(opts.ExcludedTables))
+	if err != nil {
+		return report, fmt.Errorf("failed to compare with %s: %w", legacy.Name, err)
+	}
+	defer func(rows *sql.Rows) {
+		_ = rows.Close()
+	}(rows)
+
+	for rows.Next() {
+		var count DualWriteCount
+		if err := rows.Scan(&count.Table, &count.Action, &count.Legacy, &count.Audited); err != nil {
+			return report, fmt.Errorf("failed to scan dual write count: %w", err)
+		}
+		count.Action = legacyActions[count.Action]
+		report.Counts = append(report.Counts, count)
+	}
+	if err := rows.Err(); err != nil {
+		return report, fmt.Errorf("failed to read dual write counts: %w", err)
+	}
+
+	return report, nil
+}
+
+// withDefaults returns t with the defaults of unset fields.
+func (t LegacyTable) withDefaults() LegacyTable {
+	if t.Name == "" {
+		t.Name = "audit.logged_actions"
+	}
+	if t.TableColumn == "" {
+		t.TableColumn = "table_name"
+	}
+	if t.ActionColumn == "" {
+		t.ActionColumn = "action"
+	}
+	if t.TransactionColumn == "" {
+		t.TransactionColumn = "transaction_id"
+	}
+	if t.TimeColumn == "" {
+		t.TimeColumn = "action_tstamp_tx"
+	}
+	return t
+}
+
+// quoteQualifiedIdentifier quotes each part of a possibly schema-qualified identifier.
+func quoteQualifiedIdentifier(name string) string {
+	parts := strings.Split(name, ".")
+	for i, part := range parts {
+		parts[i] = postgres.QuoteIdentifier(part)
+	}
+	return strings.Join(parts, ".")
+}
+
+// auditTable returns the quoted audit table, defaulting to audriver.DefaultAuditTable.
+func auditTable(name string) string {
+	return quoteQualifiedIdentifier(cmp.Or(name, audriver.DefaultAuditTable))
+}
+
+// unqualifiedName returns name without its schema.
+func unqualifiedName(name string) string {
+	return name[strings.LastIndexByte(name, '.')+1:]
+}
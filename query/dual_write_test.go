@@ -0,0 +1,54 @@
+package query_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mickamy/go-sql-audit-driver/query"
+)
+
+// TestDualWriteReport_Discrepancies tests finding tables and actions recorded differently by legacy triggers and audriver
+func TestDualWriteReport_Discrepancies(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name     string
+		report   query.DualWriteReport
+		expected []query.DualWriteCount
+	}{
+		{
+			name: "discrepancies",
+			report: query.DualWriteReport{
+				Counts: []query.DualWriteCount{
+					{Table: "orders", Action: "insert", Legacy: 10, Audited: 10},
+					{Table: "orders", Action: "update", Legacy: 4, Audited: 3},
+					{Table: "users", Action: "delete", Legacy: 0, Audited: 1},
+				},
+			},
+			expected: []query.DualWriteCount{
+				{Table: "orders", Action: "update", Legacy: 4, Audited: 3},
+				{Table: "users", Action: "delete", Legacy: 0, Audited: 1},
+			},
+		},
+		{
+			name: "consistent",
+			report: query.DualWriteReport{
+				Counts: []query.DualWriteCount{{Table: "orders", Action: "insert", Legacy: 10, Audited: 10}},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// act
+			got := tc.report.Discrepancies()
+
+			// assert
+			assert.Equal(t, tc.expected, got)
+		})
+	}
+}
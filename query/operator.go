@@ -0,0 +1,32 @@
+package query
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/mickamy/go-sql-audit-driver/audriver"
+)
+
+// ResolveOperators sets the name and email of the operators of mods that were recorded without them,
+// resolving each operator ID once with resolver, so that reports are human-readable.
+func ResolveOperators(ctx context.Context, mods []audriver.DatabaseModification, resolver audriver.OperatorResolver) error {
+	operators := make(map[string]audriver.Operator)
+	for i := range mods {
+		if mods[i].OperatorName != "" || mods[i].OperatorEmail != "" {
+			continue
+		}
+
+		operator, ok := operators[mods[i].OperatorID]
+		if !ok {
+			var err error
+			if operator, err = resolver.ResolveOperator(ctx, mods[i].OperatorID); err != nil {
+				return fmt.Errorf("failed to resolve operator %s: %w", mods[i].OperatorID, err)
+			}
+			operators[mods[i].OperatorID] = operator
+		}
+
+		mods[i].OperatorName = operator.Name
+		mods[i].OperatorEmail = operator.Email
+	}
+	return nil
+}
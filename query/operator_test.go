@@ -0,0 +1,40 @@
+package query_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mickamy/go-sql-audit-driver/audriver"
+	"github.com/mickamy/go-sql-audit-driver/query"
+)
+
+// TestResolveOperators tests read-time resolution of operators
+func TestResolveOperators(t *testing.T) {
+	t.Parallel()
+
+	// arrange
+	mods := []audriver.DatabaseModification{
+		{OperatorID: "1"},
+		{OperatorID: "1"},
+		{OperatorID: "2", OperatorName: "Recorded"},
+	}
+	var calls int
+	resolver := audriver.OperatorResolverFunc(func(_ context.Context, operatorID string) (audriver.Operator, error) {
+		calls++
+		return audriver.Operator{Name: "Operator " + operatorID, Email: operatorID + "@example.com"}, nil
+	})
+
+	// act
+	err := query.ResolveOperators(t.Context(), mods, resolver)
+
+	// assert
+	require.NoError(t, err)
+	assert.Equal(t, 1, calls)
+	assert.Equal(t, "Operator 1", mods[0].OperatorName)
+	assert.Equal(t, "1@example.com", mods[1].OperatorEmail)
+	assert.Equal(t, "Recorded", mods[2].OperatorName)
+	assert.Empty(t, mods[2].OperatorEmail)
+}
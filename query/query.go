@@ -0,0 +1,304 @@
+// Package query reads audit records from the audit table, database_modifications unless configured otherwise.
+package query
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mickamy/go-sql-audit-driver/audriver"
+	"github.com/mickamy/go-sql-audit-driver/internal/postgres"
+)
+
+// Filter selects audit records. Zero fields do not restrict the selection.
+type Filter struct {
+	// From selects records modified at or after this time.
+	From time.Time
+
+	// To selects records modified before this time.
+	To time.Time
+
+	// Tables selects records of any of these tables.
+	Tables []string
+
+	// OperatorID selects records of this operator.
+	OperatorID string
+
+	// ExecutionID selects records of this execution.
+	ExecutionID string
+
+	// Descendants additionally selects, with ExecutionID, the records of the executions descending from the execution
+	// through parent_execution_id, e.g. of the workers an operation fanned out to.
+	Descendants bool
+
+	// ShardKey selects records of this shard key, e.g. of a tenant.
+	ShardKey string
+
+	// AuditTable is the audit table read, optionally schema-qualified, e.g. as configured with
+	// audriver.WithAuditTable. It defaults to audriver.DefaultAuditTable.
+	AuditTable string
+}
+
+// optionalColumns are columns of database_modifications that older audit tables may lack.
+var optionalColumns = []string{
+	"schema_name", "foreign_table", "record_ids", "source_tables", "changed_columns", "exactness", "operator_name", "operator_email",
+	"shard", "shard_key", "backend_pid", "transaction_id", "estimated_rows",
+	"idempotency_key", "parent_execution_id", "step", "client_ip", "user_agent", "device", "checksum", "old_values", "new_values", "metadata",
+}
+
+// Each calls fn for each audit record selected by filter in order of modification, streaming rows from db.
+// Optional columns missing from the audit table are left empty.
+func Each(ctx context.Context, db *sql.DB, filter Filter, fn func(audriver.DatabaseModification) error) error {
+	return each(ctx, db, filter, Scope{}, fn)
+}
+
+// each is Each restricted to scope.
+func each(ctx context.Context, db *sql.DB, filter Filter, scope Scope, fn func(audriver.DatabaseModification) error) error {
+	table := filter.auditTable()
+	available, err := columns(ctx, db, table)
+	if err != nil {
+		return err
+	}
+
+	selected := []string{"id", "operator_id", "execution_id", "table_name", "action", "sql", "modified_at"}
+	for _, column := range optionalColumns {
+		if slices.Contains(available, column) {
+			selected = append(selected, column)
+		}
+	}
+
+	where, args := filter.where(scope)
+	query := "SELECT " + strings.Join(selected, ", ") + " FROM " + table + where + " ORDER BY modified_at, id"
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to query database modifications: %w", err)
+	}
+	defer func(rows *sql.Rows) {
+		_ = rows.Close()
+	}(rows)
+
+	for rows.Next() {
+		mod, err := scan(rows, selected)
+		if err != nil {
+			return err
+		}
+		if err := fn(mod); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read database modifications: %w", err)
+	}
+
+	return nil
+}
+
+// List returns the audit records selected by filter in order of modification.
+func List(ctx context.Context, db *sql.DB, filter Filter) ([]audriver.DatabaseModification, error) {
+	var mods []audriver.DatabaseModification
+	err := Each(ctx, db, filter, func(mod audriver.DatabaseModification) error {
+		mods = append(mods, mod)
+		return nil
+	})
+	return mods, err
+}
+
+func columns(ctx context.Context, db *sql.DB, table string) ([]string, error) {
+	rows, err := db.QueryContext(ctx, "SELECT * FROM "+table+" LIMIT 0")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query %s columns: %w", table, err)
+	}
+	defer func(rows *sql.Rows) {
+		_ = rows.Close()
+	}(rows)
+
+	return rows.Columns()
+}
+
+// auditTable returns the quoted audit table of the filter.
+func (f Filter) auditTable() string {
+	return auditTable(f.AuditTable)
+}
+
+func (f Filter) where(scope Scope) (string, []any) {
+	var (
+		conditions []string
+		args       []any
+	)
+	add := func(condition string, arg any) {
+		args = append(args, arg)
+		conditions = append(conditions, strings.ReplaceAll(condition, "?", "$"+strconv.Itoa(len(args))))
+	}
+
+	if !f.From.IsZero() {
+		add("modified_at >= ?", f.From)
+	}
+	if !f.To.IsZero() {
+		add("modified_at < ?", f.To)
+	}
+	if len(f.Tables) > 0 {
+		add("table_name = ANY (?::text[])", postgres.FormatArray(f.Tables))
+	}
+	if f.OperatorID != "" {
+		add("operator_id::text = ?", f.OperatorID)
+	}
+	switch {
+	case f.ExecutionID != "" && f.Descendants:
+		add(`execution_id::text IN (
+    WITH RECURSIVE executions (id) AS (
+        SELECT ?::text
+        UNION
+        SELECT m.execution_id::text FROM `+f.auditTable()+` m JOIN executions e ON m.parent_execution_id::text = e.id
+    )
+    SELECT id FROM executions
+)`, f.ExecutionID)
+	case f.ExecutionID != "":
+		add("execution_id::text = ?", f.ExecutionID)
+	}
+	if f.ShardKey != "" {
+		add("shard_key = ?", f.ShardKey)
+	}
+	scope.conditions(add)
+
+	if len(conditions) == 0 {
+		return "", nil
+	}
+	return " WHERE " + strings.Join(conditions, " AND "), args
+}
+
+func scan(rows *sql.Rows, selected []string) (audriver.DatabaseModification, error) {
+	var (
+		mod           audriver.DatabaseModification
+		schemaName    sql.NullString
+		foreign       sql.NullBool
+		recordIDs     sql.NullString
+		sourceTables  sql.NullString
+		changed       sql.NullString
+		exactness     sql.NullString
+		operatorName  sql.NullString
+		operatorEmail sql.NullString
+		shard         sql.NullString
+		shardKey      sql.NullString
+		backendPID    sql.NullInt64
+		transactionID sql.NullInt64
+		estimatedRows sql.NullInt64
+		idempotency   sql.NullString
+		parent        sql.NullString
+		step          sql.NullString
+		clientIP      sql.NullString
+		userAgent     sql.NullString
+		device        sql.NullString
+		checksum      sql.NullString
+		oldValues     sql.NullString
+		newValues     sql.NullString
+		metadata      sql.NullString
+	)
+
+	dest := []any{&mod.ID, &mod.OperatorID, &mod.ExecutionID, &mod.TableName, &mod.Action, &mod.SQL, &mod.ModifiedAt}
+	for _, column := range selected[len(dest):] {
+		switch column {
+		case "schema_name":
+			dest = append(dest, &schemaName)
+		case "foreign_table":
+			dest = append(dest, &foreign)
+		case "record_ids":
+			dest = append(dest, &recordIDs)
+		case "source_tables":
+			dest = append(dest, &sourceTables)
+		case "changed_columns":
+			dest = append(dest, &changed)
+		case "exactness":
+			dest = append(dest, &exactness)
+		case "operator_name":
+			dest = append(dest, &operatorName)
+		case "operator_email":
+			dest = append(dest, &operatorEmail)
+		case "shard":
+			dest = append(dest, &shard)
+		case "shard_key":
+			dest = append(dest, &shardKey)
+		case "backend_pid":
+			dest = append(dest, &backendPID)
+		case "transaction_id":
+			dest = append(dest, &transactionID)
+		case "estimated_rows":
+			dest = append(dest, &estimatedRows)
+		case "idempotency_key":
+			dest = append(dest, &idempotency)
+		case "parent_execution_id":
+			dest = append(dest, &parent)
+		case "step":
+			dest = append(dest, &step)
+		case "client_ip":
+			dest = append(dest, &clientIP)
+		case "user_agent":
+			dest = append(dest, &userAgent)
+		case "device":
+			dest = append(dest, &device)
+		case "checksum":
+			dest = append(dest, &checksum)
+		case "old_values":
+			dest = append(dest, &oldValues)
+		case "new_values":
+			dest = append(dest, &newValues)
+		case "metadata":
+			dest = append(dest, &metadata)
+		}
+	}
+	if err := rows.Scan(dest...); err != nil {
+		return mod, fmt.Errorf("failed to scan database modification: %w", err)
+	}
+
+	mod.SchemaName = schemaName.String
+	mod.Foreign = foreign.Bool
+	mod.Exactness = audriver.Exactness(exactness.String)
+	mod.OperatorName = operatorName.String
+	mod.OperatorEmail = operatorEmail.String
+	mod.Shard = shard.String
+	mod.ShardKey = shardKey.String
+	mod.BackendPID = backendPID.Int64
+	mod.TransactionID = transactionID.Int64
+	mod.EstimatedRows = estimatedRows.Int64
+	mod.IdempotencyKey = idempotency.String
+	mod.ParentExecutionID = parent.String
+	mod.Step = step.String
+	mod.ClientIP = clientIP.String
+	mod.UserAgent = userAgent.String
+	mod.Device = device.String
+	mod.Checksum = checksum.String
+	if oldValues.Valid {
+		mod.OldValues = json.RawMessage(oldValues.String)
+	}
+	if newValues.Valid {
+		mod.NewValues = json.RawMessage(newValues.String)
+	}
+	var err error
+	if recordIDs.Valid {
+		if mod.RecordIDs, err = postgres.ParseArray(recordIDs.String); err != nil {
+			return mod, fmt.Errorf("failed to parse record_ids: %w", err)
+		}
+	}
+	if sourceTables.Valid {
+		if mod.SourceTables, err = postgres.ParseArray(sourceTables.String); err != nil {
+			return mod, fmt.Errorf("failed to parse source_tables: %w", err)
+		}
+	}
+	if changed.Valid {
+		if mod.ChangedColumns, err = postgres.ParseArray(changed.String); err != nil {
+			return mod, fmt.Errorf("failed to parse changed_columns: %w", err)
+		}
+	}
+	if metadata.Valid {
+		if err := json.Unmarshal([]byte(metadata.String), &mod.Metadata); err != nil {
+			return mod, fmt.Errorf("failed to parse metadata: %w", err)
+		}
+	}
+
+	return mod, nil
+}
@@ -0,0 +1,130 @@
+package query_test
+
+import (
+	"database/sql"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-txdb"
+	"github.com/brianvoe/gofakeit/v7"
+	"github.com/google/uuid"
+	_ "github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mickamy/go-sql-audit-driver/audriver"
+	"github.com/mickamy/go-sql-audit-driver/query"
+)
+
+const writerDSN = "user=audriver_writer password=password dbname=audriver host=localhost port=5432 sslmode=disable"
+
+func setUpTestDB(t *testing.T, options ...audriver.Option) *sql.DB {
+	t.Helper()
+
+	driverName := fmt.Sprintf("query_test_%s_%d", t.Name(), gofakeit.Number(1000, 9999))
+	sql.Register(driverName, audriver.New(txdb.New("postgres", writerDSN), options...))
+
+	db, err := sql.Open(driverName, driverName)
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		_ = db.Close()
+	})
+
+	return db
+}
+
+// TestList tests filtering of audit records
+func TestList(t *testing.T) {
+	t.Parallel()
+
+	// arrange
+	db := setUpTestDB(t)
+	db.SetMaxOpenConns(1)
+
+	operatorID := uuid.New().String()
+	executionID := uuid.New().String()
+	ctx := audriver.WithExecutionID(t.Context(), executionID)
+
+	userID := uuid.New().String()
+	_, err := db.ExecContext(audriver.WithOperatorID(ctx, operatorID), `INSERT INTO users (id, name, email) VALUES ($1, $2, $3)`, userID, gofakeit.Name(), gofakeit.Email())
+	require.NoError(t, err)
+	_, err = db.ExecContext(audriver.WithOperatorID(ctx, uuid.New().String()), `UPDATE users SET name = $1 WHERE id = $2`, gofakeit.Name(), userID)
+	require.NoError(t, err)
+	childCtx := audriver.WithParentExecutionID(audriver.WithExecutionID(ctx, uuid.New().String()), executionID)
+	_, err = db.ExecContext(audriver.WithOperatorID(childCtx, operatorID), `DELETE FROM users WHERE id = $1`, userID)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name    string
+		filter  query.Filter
+		actions []audriver.DatabaseModificationAction
+	}{
+		{
+			name:    "execution",
+			filter:  query.Filter{ExecutionID: executionID},
+			actions: []audriver.DatabaseModificationAction{audriver.DatabaseModificationActionInsert, audriver.DatabaseModificationActionUpdate},
+		},
+		{
+			name:    "descendants",
+			filter:  query.Filter{ExecutionID: executionID, Descendants: true},
+			actions: []audriver.DatabaseModificationAction{audriver.DatabaseModificationActionInsert, audriver.DatabaseModificationActionUpdate, audriver.DatabaseModificationActionDelete},
+		},
+		{
+			name:    "operator",
+			filter:  query.Filter{ExecutionID: executionID, OperatorID: operatorID},
+			actions: []audriver.DatabaseModificationAction{audriver.DatabaseModificationActionInsert},
+		},
+		{
+			name:   "other_table",
+			filter: query.Filter{ExecutionID: executionID, Tables: []string{"orders"}},
+		},
+		{
+			name:   "time_range",
+			filter: query.Filter{ExecutionID: executionID, From: time.Now().Add(time.Hour)},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// act
+			mods, err := query.List(t.Context(), db, tc.filter)
+
+			// assert
+			require.NoError(t, err)
+			actions := make([]audriver.DatabaseModificationAction, 0, len(mods))
+			for _, mod := range mods {
+				assert.Equal(t, "users", mod.TableName)
+				actions = append(actions, mod.Action)
+			}
+			assert.ElementsMatch(t, tc.actions, actions)
+		})
+	}
+}
+
+// TestList_AuditTable tests reading audit records from a table configured with WithAuditTable
+func TestList_AuditTable(t *testing.T) {
+	t.Parallel()
+
+	// arrange
+	const auditTable = "legacy_database_modifications"
+	db := setUpTestDB(t, audriver.WithAuditTable(auditTable))
+	db.SetMaxOpenConns(1)
+
+	executionID := uuid.New().String()
+	ctx := audriver.WithOperatorID(audriver.WithExecutionID(t.Context(), executionID), uuid.New().String())
+	_, err := db.ExecContext(ctx, `INSERT INTO users (id, name, email) VALUES ($1, $2, $3)`, uuid.New().String(), gofakeit.Name(), gofakeit.Email())
+	require.NoError(t, err)
+
+	// act
+	mods, err := query.List(t.Context(), db, query.Filter{ExecutionID: executionID, AuditTable: auditTable})
+	require.NoError(t, err)
+	defaultMods, err := query.List(t.Context(), db, query.Filter{ExecutionID: executionID})
+	require.NoError(t, err)
+
+	// assert
+	require.Len(t, mods, 1)
+	assert.Equal(t, audriver.DatabaseModificationActionInsert, mods[0].Action)
+	assert.Empty(t, defaultMods, "records should not be written to the default audit table")
+}
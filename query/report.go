@@ -0,0 +1,172 @@
+package query
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/mickamy/go-sql-audit-driver/audriver"
+	"github.com/mickamy/go-sql-audit-driver/internal/postgres"
+)
+
+// DefaultAnonymousOperatorIDs are operator IDs that do not identify an operator:
+// the nil UUID and the default operator ID of writes recorded by audit triggers.
+var DefaultAnonymousOperatorIDs = []string{"00000000-0000-0000-0000-000000000000", audriver.DefaultTriggerOperatorID}
+
+// ReportOptions configures an integrity report.
+type ReportOptions struct {
+	// From and To are the period of the report. To defaults to now and From to 24 hours before To.
+	From time.Time
+	To   time.Time
+
+	// AnonymousOperatorIDs are operator IDs counted as missing operators. They default to DefaultAnonymousOperatorIDs.
+	AnonymousOperatorIDs []string
+
+	// ExcludedTables are tables not expected to be audited, e.g. session tables excluded by table filters.
+	// The audit table is always excluded.
+	ExcludedTables []string
+
+	// AuditTable is the audit table reported on, optionally schema-qualified. It defaults to audriver.DefaultAuditTable.
+	AuditTable string
+}
+
+// Report is an integrity report of the audit trail, suitable as evidence for SOC 2 or ISO 27001 audits.
+type Report struct {
+	// From and To are the period of the report.
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+
+	// GeneratedAt is when the report was generated.
+	GeneratedAt time.Time `json:"generated_at"`
+
+	// DailyCounts are the numbers of audit records per day (UTC) of the period, excluding days without records.
+	DailyCounts []DailyCount `json:"daily_counts"`
+
+	// MissingOperators is the number of audit records of the period without an identifiable operator.
+	MissingOperators int64 `json:"missing_operators"`
+
+	// UncoveredTables are tables with writes known to PostgreSQL but without audit records in the period.
+	UncoveredTables []TableActivity `json:"uncovered_tables"`
+}
+
+// DailyCount is the number of audit records of a day.
+type DailyCount struct {
+	Day     time.Time `json:"day"`
+	Records int64     `json:"records"`
+}
+
+// TableActivity is the number of rows written to a table.
+type TableActivity struct {
+	Table string `json:"table"`
+
+	// Writes is the number of rows inserted, updated, and deleted according to pg_stat_user_tables.
+	// The counters are cumulative since statistics were last reset, so writes may predate the period.
+	Writes int64 `json:"writes"`
+}
+
+// EmptyDays returns the days (UTC) of the period without audit records, i.e. gaps in the audit trail.
+func (r Report) EmptyDays() []time.Time {
+	counted := make(map[time.Time]bool, len(r.DailyCounts))
+	for _, count := range r.DailyCounts {
+		counted[count.Day.UTC()] = true
+	}
+
+	var days []time.Time
+	for day := r.From.UTC().Truncate(24 * time.Hour); day.Before(r.To); day = day.AddDate(0, 0, 1) {
+		if !counted[day] {
+			days = append(days, day)
+		}
+	}
+	return days
+}
+
+// GenerateReport generates an integrity report of the audit records in db.
+func GenerateReport(ctx context.Context, db *sql.DB, opts ReportOptions) (Report, error) {
+	report := Report{From: opts.From, To: opts.To, GeneratedAt: time.Now()}
+	if report.To.IsZero() {
+		report.To = report.GeneratedAt
+	}
+	if report.From.IsZero() {
+		report.From = report.To.Add(-24 * time.Hour)
+	}
+	anonymous := opts.AnonymousOperatorIDs
+	if anonymous == nil {
+		anonymous = DefaultAnonymousOperatorIDs
+	}
+	table := auditTable(opts.AuditTable)
+
+	rows, err := db.QueryContext(ctx, `SELECT date_trunc('day', modified_at AT TIME ZONE 'UTC'), count(*)
+FROM `+table+`
+WHERE modified_at >= $1 AND modified_at < $2
+GROUP BY 1
+ORDER BY 1`, report.From, report.To)
+	if err != nil {
+		return report, fmt.Errorf("failed to count database modifications: %w", err)
+	}
+	defer func(rows *sql.Rows) {
+		_ = rows.Close()
+	}(rows)
+	for rows.Next() {
+		var count DailyCount
+		if err := rows.Scan(&count.Day, &count.Records); err != nil {
+			return report, fmt.Errorf("failed to scan daily count: %w", err)
+		}
+		count.Day = time.Date(count.Day.Year(), count.Day.Month(), count.Day.Day(), 0, 0, 0, 0, time.UTC)
+		report.DailyCounts = append(report.DailyCounts, count)
+	}
+	if err := rows.Err(); err != nil {
+		return report, fmt.Errorf("failed to read daily counts: %w", err)
+	}
+
+	err = db.QueryRowContext(ctx, `SELECT count(*)
+FROM `+table+`
+WHERE modified_at >= $1 AND modified_at < $2 AND operator_id::text = ANY ($3::text[])`,
+		report.From, report.To, postgres.FormatArray(anonymous),
+	).Scan(&report.MissingOperators)
+	if err != nil {
+		return report, fmt.Errorf("failed to count missing operators: %w", err)
+	}
+
+	excluded := append([]string{unqualifiedName(cmp.Or(opts.AuditTable, audriver.DefaultAuditTable))}, opts.ExcludedTables...)
+	report.UncoveredTables, err = uncoveredTables(ctx, db, table, report.From, report.To, excluded)
+	if err != nil {
+		return report, err
+	}
+
+	return report, nil
+}
+
+// uncoveredTables returns the tables with writes according to pg_stat_user_tables but without audit records in
+// auditTable modified between from and to.
+func uncoveredTables(ctx context.Context, db *sql.DB, auditTable string, from, to time.Time, excluded []string) ([]TableActivity, error) {
+	rows, err := db.QueryContext(ctx, `SELECT s.relname, s.n_tup_ins + s.n_tup_upd + s.n_tup_del
+FROM pg_stat_user_tables s
+WHERE s.n_tup_ins + s.n_tup_upd + s.n_tup_del > 0
+  AND NOT s.relname = ANY ($3::text[])
+  AND NOT EXISTS (
+    SELECT 1 FROM `+auditTable+` m
+    WHERE m.table_name = s.relname AND m.modified_at >= $1 AND m.modified_at < $2
+  )
+ORDER BY s.relname`, from, to, postgres.FormatArray(excluded))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query table activity: %w", err)
+	}
+	defer func(rows *sql.Rows) {
+		_ = rows.Close()
+	}(rows)
+
+	var tables []TableActivity
+	for rows.Next() {
+		var table TableActivity
+		if err := rows.Scan(&table.Table, &table.Writes); err != nil {
+			return nil, fmt.Errorf("failed to scan table activity: %w", err)
+		}
+		tables = append(tables, table)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read table activity: %w", err)
+	}
+	return tables, nil
+}
@@ -0,0 +1,56 @@
+package query_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mickamy/go-sql-audit-driver/query"
+)
+
+// TestReport_EmptyDays tests finding days without audit records in the period of a report
+func TestReport_EmptyDays(t *testing.T) {
+	t.Parallel()
+
+	day := func(d int) time.Time {
+		return time.Date(2025, time.March, d, 0, 0, 0, 0, time.UTC)
+	}
+
+	testCases := []struct {
+		name     string
+		report   query.Report
+		expected []time.Time
+	}{
+		{
+			name: "gaps",
+			report: query.Report{
+				From:        day(1).Add(6 * time.Hour),
+				To:          day(5),
+				DailyCounts: []query.DailyCount{{Day: day(1), Records: 3}, {Day: day(3), Records: 1}},
+			},
+			expected: []time.Time{day(2), day(4)},
+		},
+		{
+			name: "complete",
+			report: query.Report{
+				From:        day(1),
+				To:          day(2).Add(time.Hour),
+				DailyCounts: []query.DailyCount{{Day: day(1), Records: 3}, {Day: day(2), Records: 1}},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// act
+			got := tc.report.EmptyDays()
+
+			// assert
+			assert.Equal(t, tc.expected, got)
+		})
+	}
+}
@@ -0,0 +1,40 @@
+CREATE TABLE database_modifications
+(
+    id                  TEXT    NOT NULL PRIMARY KEY,
+    operator_id         TEXT    NOT NULL,
+    execution_id        TEXT    NOT NULL,
+    table_name          TEXT    NOT NULL,
+    action              TEXT    NOT NULL,
+    sql                 TEXT    NOT NULL,
+    modified_at         TEXT    NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    record_ids          TEXT,
+    schema_name         TEXT,
+    foreign_table       INTEGER,
+    source_tables       TEXT,
+    changed_columns     TEXT,
+    operator_name       TEXT,
+    operator_email      TEXT,
+    shard               TEXT,
+    shard_key           TEXT,
+    backend_pid         INTEGER,
+    transaction_id      INTEGER,
+    estimated_rows      INTEGER,
+    idempotency_key     TEXT,
+    parent_execution_id TEXT,
+    step                TEXT,
+    client_ip           TEXT,
+    user_agent          TEXT,
+    device              TEXT,
+    checksum            TEXT,
+    old_values          TEXT,
+    new_values          TEXT,
+    metadata            TEXT,
+    exactness           TEXT    NOT NULL DEFAULT 'exact'
+);
+
+CREATE INDEX idx_database_modifications_execution_id ON database_modifications (execution_id);
+CREATE INDEX idx_database_modifications_operator_id ON database_modifications (operator_id);
+CREATE INDEX idx_database_modifications_shard_key ON database_modifications (shard_key);
+CREATE INDEX idx_database_modifications_parent_execution_id ON database_modifications (parent_execution_id);
+CREATE INDEX idx_database_modifications_checksum ON database_modifications (checksum);
+CREATE INDEX idx_database_modifications_table_name_action ON database_modifications (table_name, action);
@@ -0,0 +1,87 @@
+package summary
+
+import (
+	htmltemplate "html/template"
+	"io"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// funcs are the functions of the templates rendering summaries.
+var funcs = map[string]any{
+	"time": func(t time.Time) string {
+		return t.UTC().Format("2006-01-02 15:04 UTC")
+	},
+	"operator": func(id, name string) string {
+		if name == "" {
+			return id
+		}
+		return name + " (" + id + ")"
+	},
+	"join": strings.Join,
+	// cell escapes the characters of a Markdown table cell
+	"cell": strings.NewReplacer("|", `\|`, "\n", " ", "\r", "").Replace,
+}
+
+var markdownTemplate = template.Must(template.New("markdown").Funcs(funcs).Parse(`# Audit summary
+
+{{time .From}} – {{time .To}}: {{.Records}} audit records
+
+## Tables
+{{if .Tables}}
+| Table | Inserts | Updates | Deletes | Total |
+| --- | ---: | ---: | ---: | ---: |
+{{range .Tables}}| {{cell .Table}} | {{.Inserts}} | {{.Updates}} | {{.Deletes}} | {{.Total}} |
+{{end}}{{else}}
+No modifications.
+{{end}}
+## Operators
+{{if .Operators}}
+| Operator | Records | Tables |
+| --- | ---: | --- |
+{{range .Operators}}| {{cell (operator .OperatorID .OperatorName)}} | {{.Records}} | {{cell (join .Tables ", ")}} |
+{{end}}{{else}}
+No operators.
+{{end}}
+## Large operations
+{{if .LargeOperations}}
+| Started | Execution | Operator | Records | Tables |
+| --- | --- | --- | ---: | --- |
+{{range .LargeOperations}}| {{time .StartedAt}} | {{cell .ExecutionID}} | {{cell (operator .OperatorID .OperatorName)}} | {{.Records}} | {{cell (join .Tables ", ")}} |
+{{end}}{{else}}
+No large operations.
+{{end}}`))
+
+var htmlTemplate = htmltemplate.Must(htmltemplate.New("html").Funcs(funcs).Parse(`<h1>Audit summary</h1>
+<p>{{time .From}} – {{time .To}}: {{.Records}} audit records</p>
+<h2>Tables</h2>
+{{if .Tables}}<table>
+<tr><th>Table</th><th>Inserts</th><th>Updates</th><th>Deletes</th><th>Total</th></tr>
+{{range .Tables}}<tr><td>{{.Table}}</td><td>{{.Inserts}}</td><td>{{.Updates}}</td><td>{{.Deletes}}</td><td>{{.Total}}</td></tr>
+{{end}}</table>
+{{else}}<p>No modifications.</p>
+{{end}}<h2>Operators</h2>
+{{if .Operators}}<table>
+<tr><th>Operator</th><th>Records</th><th>Tables</th></tr>
+{{range .Operators}}<tr><td>{{operator .OperatorID .OperatorName}}</td><td>{{.Records}}</td><td>{{join .Tables ", "}}</td></tr>
+{{end}}</table>
+{{else}}<p>No operators.</p>
+{{end}}<h2>Large operations</h2>
+{{if .LargeOperations}}<table>
+<tr><th>Started</th><th>Execution</th><th>Operator</th><th>Records</th><th>Tables</th></tr>
+{{range .LargeOperations}}<tr><td>{{time .StartedAt}}</td><td>{{.ExecutionID}}</td><td>{{operator .OperatorID .OperatorName}}</td><td>{{.Records}}</td><td>{{join .Tables ", "}}</td></tr>
+{{end}}</table>
+{{else}}<p>No large operations.</p>
+{{end}}`))
+
+// Markdown writes the summary as a Markdown document with a table per section.
+func (s Summary) Markdown(w io.Writer) error {
+	return markdownTemplate.Execute(w, s)
+}
+
+// HTML writes the summary as an HTML fragment with a table per section, suitable as the body of an email.
+// Values are escaped.
+func (s Summary) HTML(w io.Writer) error {
+	return htmlTemplate.Execute(w, s)
+}
@@ -0,0 +1,238 @@
+// Package summary renders a summary of the audit records of a period, per table, per operator, and of notably large
+// operations, as Markdown or HTML, e.g. for weekly change-review emails:
+//
+//	s, err := summary.Generate(ctx, db, summary.Options{From: time.Now().AddDate(0, 0, -7)})
+//	if err != nil {
+//		return err
+//	}
+//	var body bytes.Buffer
+//	if err := s.HTML(&body); err != nil {
+//		return err
+//	}
+package summary
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"slices"
+	"time"
+
+	"github.com/mickamy/go-sql-audit-driver/audriver"
+	"github.com/mickamy/go-sql-audit-driver/query"
+)
+
+// DefaultLargeOperation is the default number of modified records from which an execution is a notable large operation.
+const DefaultLargeOperation = 1000
+
+// DefaultTop is the default number of operators and large operations listed.
+const DefaultTop = 10
+
+// Options configures a summary.
+type Options struct {
+	// From and To are the period of the summary. To defaults to now and From to 7 days before To.
+	From time.Time
+	To   time.Time
+
+	// Tables restricts the summary to these tables. All tables are summarized if empty.
+	Tables []string
+
+	// LargeOperation is the number of modified records from which an execution is listed as a large operation.
+	// Records count as the rows their statements were estimated to modify, if recorded, e.g. with
+	// WithRowEstimateGuard. It defaults to DefaultLargeOperation.
+	LargeOperation int64
+
+	// Top is the number of most active operators and largest operations listed. It defaults to DefaultTop.
+	Top int
+
+	// AuditTable is the audit table summarized, optionally schema-qualified. It defaults to audriver.DefaultAuditTable.
+	AuditTable string
+}
+
+// Summary summarizes the audit records of a period.
+type Summary struct {
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+
+	// Records is the number of audit records of the period.
+	Records int64 `json:"records"`
+
+	// Tables are the modified tables, most modified first.
+	Tables []TableSummary `json:"tables"`
+
+	// Operators are the most active operators, most active first.
+	Operators []OperatorSummary `json:"operators"`
+
+	// LargeOperations are the largest executions modifying at least Options.LargeOperation records, largest first.
+	LargeOperations []LargeOperation `json:"large_operations"`
+}
+
+// TableSummary is the number of audit records of a table.
+type TableSummary struct {
+	Table   string `json:"table"`
+	Inserts int64  `json:"inserts"`
+	Updates int64  `json:"updates"`
+	Deletes int64  `json:"deletes"`
+	Total   int64  `json:"total"`
+}
+
+// OperatorSummary is the number of audit records of an operator.
+type OperatorSummary struct {
+	OperatorID   string `json:"operator_id"`
+	OperatorName string `json:"operator_name,omitempty"`
+	Records      int64  `json:"records"`
+
+	// Tables are the tables the operator modified.
+	Tables []string `json:"tables"`
+}
+
+// LargeOperation is an execution modifying many records.
+type LargeOperation struct {
+	ExecutionID  string    `json:"execution_id"`
+	OperatorID   string    `json:"operator_id"`
+	OperatorName string    `json:"operator_name,omitempty"`
+	StartedAt    time.Time `json:"started_at"`
+
+	// Records is the number of records the execution modified, estimated for statements with estimated rows.
+	Records int64 `json:"records"`
+
+	// Tables are the tables the execution modified.
+	Tables []string `json:"tables"`
+}
+
+// Generate summarizes the audit records in db of the period of opts.
+func Generate(ctx context.Context, db *sql.DB, opts Options) (Summary, error) {
+	opts = opts.withDefaults(time.Now())
+	s := newSummarizer(opts)
+	err := query.Each(ctx, db, query.Filter{From: opts.From, To: opts.To, Tables: opts.Tables, AuditTable: opts.AuditTable}, func(mod audriver.DatabaseModification) error {
+		s.add(mod)
+		return nil
+	})
+	if err != nil {
+		return Summary{}, err
+	}
+	return s.summary(), nil
+}
+
+// Summarize summarizes mods, e.g. exported audit records, as the records of the period of opts.
+// Records outside of the period are summarized nonetheless.
+func Summarize(mods []audriver.DatabaseModification, opts Options) Summary {
+	opts = opts.withDefaults(time.Now())
+	s := newSummarizer(opts)
+	for _, mod := range mods {
+		s.add(mod)
+	}
+	return s.summary()
+}
+
+// withDefaults returns o with the defaults of its zero fields, relative to now.
+func (o Options) withDefaults(now time.Time) Options {
+	if o.To.IsZero() {
+		o.To = now
+	}
+	if o.From.IsZero() {
+		o.From = o.To.AddDate(0, 0, -7)
+	}
+	if o.LargeOperation <= 0 {
+		o.LargeOperation = DefaultLargeOperation
+	}
+	if o.Top <= 0 {
+		o.Top = DefaultTop
+	}
+	return o
+}
+
+// summarizer accumulates the audit records of a summary.
+type summarizer struct {
+	opts       Options
+	records    int64
+	tables     map[string]*TableSummary
+	operators  map[string]*OperatorSummary
+	executions map[string]*LargeOperation
+}
+
+func newSummarizer(opts Options) *summarizer {
+	return &summarizer{
+		opts:       opts,
+		tables:     map[string]*TableSummary{},
+		operators:  map[string]*OperatorSummary{},
+		executions: map[string]*LargeOperation{},
+	}
+}
+
+func (s *summarizer) add(mod audriver.DatabaseModification) {
+	s.records++
+
+	table, ok := s.tables[mod.TableName]
+	if !ok {
+		table = &TableSummary{Table: mod.TableName}
+		s.tables[mod.TableName] = table
+	}
+	table.Total++
+	switch mod.Action {
+	case audriver.DatabaseModificationActionInsert:
+		table.Inserts++
+	case audriver.DatabaseModificationActionUpdate:
+		table.Updates++
+	case audriver.DatabaseModificationActionDelete:
+		table.Deletes++
+	}
+
+	operator, ok := s.operators[mod.OperatorID]
+	if !ok {
+		operator = &OperatorSummary{OperatorID: mod.OperatorID}
+		s.operators[mod.OperatorID] = operator
+	}
+	operator.Records++
+	operator.OperatorName = cmp.Or(operator.OperatorName, mod.OperatorName)
+	if !slices.Contains(operator.Tables, mod.TableName) {
+		operator.Tables = append(operator.Tables, mod.TableName)
+	}
+
+	execution, ok := s.executions[mod.ExecutionID]
+	if !ok {
+		execution = &LargeOperation{ExecutionID: mod.ExecutionID, OperatorID: mod.OperatorID, StartedAt: mod.ModifiedAt}
+		s.executions[mod.ExecutionID] = execution
+	}
+	execution.Records += max(mod.EstimatedRows, 1)
+	execution.OperatorName = cmp.Or(execution.OperatorName, mod.OperatorName)
+	if mod.ModifiedAt.Before(execution.StartedAt) {
+		execution.StartedAt = mod.ModifiedAt
+	}
+	if !slices.Contains(execution.Tables, mod.TableName) {
+		execution.Tables = append(execution.Tables, mod.TableName)
+	}
+}
+
+func (s *summarizer) summary() Summary {
+	summary := Summary{From: s.opts.From, To: s.opts.To, Records: s.records}
+
+	for _, table := range s.tables {
+		summary.Tables = append(summary.Tables, *table)
+	}
+	slices.SortFunc(summary.Tables, func(a, b TableSummary) int {
+		return cmp.Or(cmp.Compare(b.Total, a.Total), cmp.Compare(a.Table, b.Table))
+	})
+
+	for _, operator := range s.operators {
+		slices.Sort(operator.Tables)
+		summary.Operators = append(summary.Operators, *operator)
+	}
+	slices.SortFunc(summary.Operators, func(a, b OperatorSummary) int {
+		return cmp.Or(cmp.Compare(b.Records, a.Records), cmp.Compare(a.OperatorID, b.OperatorID))
+	})
+	summary.Operators = summary.Operators[:min(len(summary.Operators), s.opts.Top)]
+
+	for _, execution := range s.executions {
+		if execution.Records >= s.opts.LargeOperation {
+			slices.Sort(execution.Tables)
+			summary.LargeOperations = append(summary.LargeOperations, *execution)
+		}
+	}
+	slices.SortFunc(summary.LargeOperations, func(a, b LargeOperation) int {
+		return cmp.Or(cmp.Compare(b.Records, a.Records), a.StartedAt.Compare(b.StartedAt), cmp.Compare(a.ExecutionID, b.ExecutionID))
+	})
+	summary.LargeOperations = summary.LargeOperations[:min(len(summary.LargeOperations), s.opts.Top)]
+
+	return summary
+}
@@ -0,0 +1,88 @@
+package summary_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mickamy/go-sql-audit-driver/audriver"
+	"github.com/mickamy/go-sql-audit-driver/summary"
+)
+
+// TestSummarize tests summarizing audit records per table, per operator, and of large operations
+func TestSummarize(t *testing.T) {
+	t.Parallel()
+
+	// arrange
+	from := time.Date(2025, 1, 6, 0, 0, 0, 0, time.UTC)
+	at := from.Add(10 * time.Hour)
+	mods := []audriver.DatabaseModification{
+		{ExecutionID: "e1", OperatorID: "alice", OperatorName: "Alice", TableName: "users", Action: audriver.DatabaseModificationActionInsert, ModifiedAt: at},
+		{ExecutionID: "e1", OperatorID: "alice", TableName: "orders", Action: audriver.DatabaseModificationActionUpdate, ModifiedAt: at.Add(-time.Minute)},
+		{ExecutionID: "e2", OperatorID: "bob", TableName: "orders", Action: audriver.DatabaseModificationActionDelete, ModifiedAt: at, EstimatedRows: 5000},
+		{ExecutionID: "e3", OperatorID: "<script>", TableName: "orders|archive", Action: audriver.DatabaseModificationActionUpdate, ModifiedAt: at},
+	}
+
+	// act
+	s := summary.Summarize(mods, summary.Options{From: from, To: from.AddDate(0, 0, 7), LargeOperation: 2})
+
+	// assert
+	assert.Equal(t, int64(4), s.Records)
+	assert.Equal(t, []summary.TableSummary{
+		{Table: "orders", Updates: 1, Deletes: 1, Total: 2},
+		{Table: "orders|archive", Updates: 1, Total: 1},
+		{Table: "users", Inserts: 1, Total: 1},
+	}, s.Tables)
+	require.Len(t, s.Operators, 3)
+	assert.Equal(t, summary.OperatorSummary{OperatorID: "alice", OperatorName: "Alice", Records: 2, Tables: []string{"orders", "users"}}, s.Operators[0])
+	assert.Equal(t, []summary.LargeOperation{
+		{ExecutionID: "e2", OperatorID: "bob", StartedAt: at, Records: 5000, Tables: []string{"orders"}},
+		{ExecutionID: "e1", OperatorID: "alice", OperatorName: "Alice", StartedAt: at.Add(-time.Minute), Records: 2, Tables: []string{"orders", "users"}},
+	}, s.LargeOperations)
+
+	t.Run("markdown", func(t *testing.T) {
+		t.Parallel()
+
+		// act
+		var buf bytes.Buffer
+		err := s.Markdown(&buf)
+
+		// assert
+		require.NoError(t, err)
+		assert.Contains(t, buf.String(), "2025-01-06 00:00 UTC – 2025-01-13 00:00 UTC: 4 audit records")
+		assert.Contains(t, buf.String(), "| orders | 0 | 1 | 1 | 2 |\n")
+		assert.Contains(t, buf.String(), `| orders\|archive | 0 | 1 | 0 | 1 |`)
+		assert.Contains(t, buf.String(), "| Alice (alice) | 2 | orders, users |\n")
+		assert.Contains(t, buf.String(), "| 2025-01-06 10:00 UTC | e2 | bob | 5000 | orders |\n")
+	})
+
+	t.Run("html", func(t *testing.T) {
+		t.Parallel()
+
+		// act
+		var buf bytes.Buffer
+		err := s.HTML(&buf)
+
+		// assert
+		require.NoError(t, err)
+		assert.Contains(t, buf.String(), "<tr><td>orders</td><td>0</td><td>1</td><td>1</td><td>2</td></tr>")
+		assert.Contains(t, buf.String(), "&lt;script&gt;")
+		assert.NotContains(t, buf.String(), "<script>")
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		t.Parallel()
+
+		// act
+		var buf bytes.Buffer
+		err := summary.Summarize(nil, summary.Options{}).Markdown(&buf)
+
+		// assert
+		require.NoError(t, err)
+		assert.Contains(t, buf.String(), "No modifications.")
+		assert.Contains(t, buf.String(), "No large operations.")
+	})
+}